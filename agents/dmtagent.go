@@ -21,20 +21,27 @@ package agents
 import (
 	"fmt"
 	"strconv"
-	"strings"
 	"sync"
+	"time"
 
+	"github.com/cenkalti/rpc2"
 	"github.com/cgrates/cgrates/config"
 	"github.com/cgrates/cgrates/engine"
+	"github.com/cgrates/cgrates/sessionmanager"
 	"github.com/cgrates/cgrates/utils"
 	"github.com/cgrates/rpcclient"
 	"github.com/fiorix/go-diameter/diam"
+	"github.com/fiorix/go-diameter/diam/avp"
 	"github.com/fiorix/go-diameter/diam/datatype"
+	"github.com/fiorix/go-diameter/diam/dict"
 	"github.com/fiorix/go-diameter/diam/sm"
 )
 
 func NewDiameterAgent(cgrCfg *config.CGRConfig, smg rpcclient.RpcClientConnection, pubsubs rpcclient.RpcClientConnection) (*DiameterAgent, error) {
-	da := &DiameterAgent{cgrCfg: cgrCfg, smg: smg, pubsubs: pubsubs, connMux: new(sync.Mutex)}
+	da := &DiameterAgent{cgrCfg: cgrCfg, smg: smg, pubsubs: pubsubs, connMux: new(sync.Mutex),
+		peers: make(map[string]diam.Conn), peersMux: new(sync.RWMutex),
+		sessions: make(map[string]*dmtSession), sessionsMux: new(sync.RWMutex),
+		pendingAnswers: make(map[uint32]chan *diam.Message), pendingMux: new(sync.Mutex)}
 	dictsDir := cgrCfg.DiameterAgentCfg().DictionariesDir
 	if len(dictsDir) != 0 {
 		if err := loadDictionaries(dictsDir, "DiameterAgent"); err != nil {
@@ -49,6 +56,13 @@ type DiameterAgent struct {
 	smg     rpcclient.RpcClientConnection // Connection towards CGR-SMG component
 	pubsubs rpcclient.RpcClientConnection // Connection towards CGR-PubSub component
 	connMux *sync.Mutex                   // Protect connection for read/write
+
+	peers          map[string]diam.Conn          // Client connections indexed on Origin-Host
+	peersMux       *sync.RWMutex                 // Protects peers
+	sessions       map[string]*dmtSession        // Active sessions indexed on SMG OriginID
+	sessionsMux    *sync.RWMutex                 // Protects sessions
+	pendingAnswers map[uint32]chan *diam.Message // Server initiated requests waiting for answers, indexed on Hop-by-Hop-Id
+	pendingMux     *sync.Mutex                   // Protects pendingAnswers
 }
 
 // Creates the message handlers
@@ -60,15 +74,21 @@ func (self *DiameterAgent) handlers() diam.Handler {
 		ProductName:      datatype.UTF8String(self.cgrCfg.DiameterAgentCfg().ProductName),
 		FirmwareRevision: datatype.Unsigned32(utils.DIAMETER_FIRMWARE_REVISION),
 	}
-	dSM := sm.New(settings)
+	dSM := sm.New(settings) // CER and DWR are answered by the state machine
 	dSM.HandleFunc("CCR", self.handleCCR)
+	dSM.HandleFunc("DPR", self.handleDPR)
+	dSM.HandleFunc("ASA", self.handleAnswer)
+	dSM.HandleFunc("RAA", self.handleAnswer)
 	dSM.HandleFunc("ALL", self.handleALL)
 	go func() {
 		for err := range dSM.ErrorReports() {
 			utils.Logger.Err(fmt.Sprintf("<DiameterAgent> StateMachine error: %+v", err))
 		}
 	}()
-	return dSM
+	return diam.HandlerFunc(func(c diam.Conn, m *diam.Message) {
+		self.indexPeer(c, m) // Keep track of the peers out of every request, including CER and DWR
+		dSM.ServeDIAM(c, m)
+	})
 }
 
 func (self DiameterAgent) processCCR(ccr *CCR, reqProcessor *config.DARequestProcessor, processorVars map[string]string, cca *CCA) (bool, error) {
@@ -88,15 +108,122 @@ func (self DiameterAgent) processCCR(ccr *CCR, reqProcessor *config.DARequestPro
 	if !reqProcessor.AppendCCA {
 		*cca = *NewBareCCAFromCCR(ccr, self.cgrCfg.DiameterAgentCfg().OriginHost, self.cgrCfg.DiameterAgentCfg().OriginRealm)
 	}
-	smgEv, err := ccr.AsSMGenericEvent(reqProcessor.CCRFields)
+	if reqProcessor.Flags[utils.FlagMSCC] {
+		if msccAVPs, err := ccr.diamMessage.FindAVPsWithPath([]interface{}{"Multiple-Services-Credit-Control"}, dict.UndefinedVendorID); err == nil && len(msccAVPs) != 0 {
+			return self.processMSCCs(ccr, msccAVPs, reqProcessor, processorVars, cca)
+		}
+	}
+	smgEv, err := self.smgEventFromCCR(ccr, reqProcessor, cca)
+	if err != nil {
+		return false, err
+	}
+	var maxUsage float64
+	processorVars[CGRResultCode] = strconv.Itoa(diam.Success)
+	processorVars[CGRError] = ""
+	if reqProcessor.DryRun { // DryRun does not send over network
+		utils.Logger.Info(fmt.Sprintf("<DiameterAgent> SMGenericEvent: %+v", smgEv))
+		processorVars[CGRResultCode] = strconv.Itoa(diam.LimitedSuccess)
+	} else { // Find out maxUsage over APIs
+		maxUsage, err = self.dispatchSMGEvent(ccr, smgEv, cca)
+		if err != nil {
+			utils.Logger.Err(fmt.Sprintf("<DiameterAgent> Processing message: %+v, API error: %s", ccr.diamMessage, err))
+			processorVars[CGRError], processorVars[CGRResultCode] = cgrErrorAndResultCode(err)
+		}
+		setProcessorMaxUsage(processorVars, maxUsage)
+	}
+	return self.setCCAProcessorAVPs(ccr, reqProcessor, processorVars, cca)
+}
+
+// processMSCCs handles each Multiple-Services-Credit-Control in the CCR as an independent SMG session or charge,
+// answering with one Multiple-Services-Credit-Control per request one
+func (self DiameterAgent) processMSCCs(ccr *CCR, msccAVPs []*diam.AVP, reqProcessor *config.DARequestProcessor, processorVars map[string]string, cca *CCA) (bool, error) {
+	processorVars[CGRResultCode] = strconv.Itoa(diam.Success)
+	processorVars[CGRError] = ""
+	var succeeded bool
+	for idx, msccAVP := range msccAVPs {
+		ratingGroup, serviceIdentifier, grantedUnitCode := msccAnswerDetails(msccAVP)
+		msccKey := strconv.Itoa(idx)
+		if ratingGroup != nil {
+			msccKey = avpValAsString(ratingGroup)
+		} else if serviceIdentifier != nil {
+			msccKey = avpValAsString(serviceIdentifier)
+		}
+		var maxUsage float64
+		msccResultCode := diam.Success
+		if smgEv, err := self.buildSMGEvent(ccr.msccCCR(msccAVP), reqProcessor); err != nil { // Fail only this MSCC, the others are answered independently
+			msccResultCode = DiameterRatingFailed
+			if processorVars[CGRError] == "" {
+				processorVars[CGRError] = err.Error()
+			}
+		} else {
+			smgEv[utils.ACCID] = utils.ConcatenatedKey(smgEv.GetUUID(), msccKey) // Each MSCC has it's own session in SMG
+			if reqProcessor.DryRun {
+				utils.Logger.Info(fmt.Sprintf("<DiameterAgent> SMGenericEvent: %+v", smgEv))
+				msccResultCode = diam.LimitedSuccess
+			} else if maxUsage, err = self.dispatchSMGEvent(ccr, smgEv, cca); err != nil {
+				utils.Logger.Err(fmt.Sprintf("<DiameterAgent> Processing message: %+v, MSCC: %s, API error: %s", ccr.diamMessage, msccKey, err))
+				var cgrErr string
+				cgrErr, _ = cgrErrorAndResultCode(err)
+				msccResultCode = msccResultCodeForError(cgrErr)
+				if processorVars[CGRError] == "" {
+					processorVars[CGRError] = cgrErr
+				}
+			}
+		}
+		if maxUsage < 0 {
+			maxUsage = 0
+		}
+		if msccResultCode == diam.Success || msccResultCode == diam.LimitedSuccess {
+			succeeded = true
+		}
+		msccAnswer := make([]*diam.AVP, 0)
+		if ratingGroup != nil {
+			msccAnswer = append(msccAnswer, ratingGroup)
+		}
+		if serviceIdentifier != nil {
+			msccAnswer = append(msccAnswer, serviceIdentifier)
+		}
+		if msccResultCode == diam.Success && ccr.CCRequestType != 3 {
+			msccAnswer = append(msccAnswer, diam.NewAVP(431, avp.Mbit, 0, &diam.GroupedAVP{ // Granted-Service-Unit
+				AVP: []*diam.AVP{grantedUnitsAVP(grantedUnitCode, maxUsage)}}))
+		}
+		msccAnswer = append(msccAnswer, diam.NewAVP(avp.ResultCode, avp.Mbit, 0, datatype.Unsigned32(msccResultCode)))
+		if _, err := cca.diamMessage.NewAVP(456, avp.Mbit, 0, &diam.GroupedAVP{AVP: msccAnswer}); err != nil { // Multiple-Services-Credit-Control
+			return false, err
+		}
+		if !reqProcessor.DryRun {
+			setProcessorMaxUsage(processorVars, maxUsage)
+		}
+	}
+	if reqProcessor.DryRun {
+		processorVars[CGRResultCode] = strconv.Itoa(diam.LimitedSuccess)
+	} else if !succeeded { // None of the services could be served
+		processorVars[CGRResultCode] = strconv.Itoa(DiameterRatingFailed)
+	}
+	return self.setCCAProcessorAVPs(ccr, reqProcessor, processorVars, cca)
+}
+
+// smgEventFromCCR builds the SMGenericEvent out of CCR, publishing it if requested
+// On errors the CCA is replaced with a rating failed one
+func (self DiameterAgent) smgEventFromCCR(ccr *CCR, reqProcessor *config.DARequestProcessor, cca *CCA) (sessionmanager.SMGenericEvent, error) {
+	smgEv, err := self.buildSMGEvent(ccr, reqProcessor)
 	if err != nil {
 		*cca = *NewBareCCAFromCCR(ccr, self.cgrCfg.DiameterAgentCfg().OriginHost, self.cgrCfg.DiameterAgentCfg().OriginRealm)
 		if err := messageSetAVPsWithPath(cca.diamMessage, []interface{}{"Result-Code"}, strconv.Itoa(DiameterRatingFailed),
 			false, self.cgrCfg.DiameterAgentCfg().Timezone); err != nil {
-			return false, err
+			return nil, err
 		}
+		return nil, ErrDiameterRatingFailed
+	}
+	return smgEv, nil
+}
+
+// buildSMGEvent converts the CCR into SMGenericEvent and publishes it if requested, logging the errors
+func (self DiameterAgent) buildSMGEvent(ccr *CCR, reqProcessor *config.DARequestProcessor) (sessionmanager.SMGenericEvent, error) {
+	smgEv, err := ccr.AsSMGenericEvent(reqProcessor.CCRFields)
+	if err != nil {
 		utils.Logger.Err(fmt.Sprintf("<DiameterAgent> Processing message: %+v AsSMGenericEvent, error: %s", ccr.diamMessage, err))
-		return false, ErrDiameterRatingFailed
+		return nil, err
 	}
 	if len(reqProcessor.Flags) != 0 {
 		smgEv[utils.CGRFlags] = reqProcessor.Flags.String() // Populate CGRFlags automatically
@@ -104,84 +231,62 @@ func (self DiameterAgent) processCCR(ccr *CCR, reqProcessor *config.DARequestPro
 	if reqProcessor.PublishEvent && self.pubsubs != nil {
 		evt, err := smgEv.AsMapStringString()
 		if err != nil {
-			*cca = *NewBareCCAFromCCR(ccr, self.cgrCfg.DiameterAgentCfg().OriginHost, self.cgrCfg.DiameterAgentCfg().OriginRealm)
-			if err := messageSetAVPsWithPath(cca.diamMessage, []interface{}{"Result-Code"}, strconv.Itoa(DiameterRatingFailed),
-				false, self.cgrCfg.DiameterAgentCfg().Timezone); err != nil {
-				return false, err
-			}
 			utils.Logger.Err(fmt.Sprintf("<DiameterAgent> Processing message: %+v failed converting SMGEvent to pubsub one, error: %s", ccr.diamMessage, err))
-			return false, ErrDiameterRatingFailed
+			return nil, err
 		}
 		var reply string
 		if err := self.pubsubs.Call("PubSubV1.Publish", engine.CgrEvent(evt), &reply); err != nil {
-			*cca = *NewBareCCAFromCCR(ccr, self.cgrCfg.DiameterAgentCfg().OriginHost, self.cgrCfg.DiameterAgentCfg().OriginRealm)
-			if err := messageSetAVPsWithPath(cca.diamMessage, []interface{}{"Result-Code"}, strconv.Itoa(DiameterRatingFailed),
-				false, self.cgrCfg.DiameterAgentCfg().Timezone); err != nil {
-				return false, err
-			}
 			utils.Logger.Err(fmt.Sprintf("<DiameterAgent> Processing message: %+v failed publishing event, error: %s", ccr.diamMessage, err))
-			return false, ErrDiameterRatingFailed
+			return nil, err
 		}
 	}
-	var maxUsage float64
-	processorVars[CGRResultCode] = strconv.Itoa(diam.Success)
-	processorVars[CGRError] = ""
-	if reqProcessor.DryRun { // DryRun does not send over network
-		utils.Logger.Info(fmt.Sprintf("<DiameterAgent> SMGenericEvent: %+v", smgEv))
-		processorVars[CGRResultCode] = strconv.Itoa(diam.LimitedSuccess)
-	} else { // Find out maxUsage over APIs
-		switch ccr.CCRequestType {
-		case 1:
-			err = self.smg.Call("SMGenericV1.InitiateSession", smgEv, &maxUsage)
-		case 2:
-			err = self.smg.Call("SMGenericV1.UpdateSession", smgEv, &maxUsage)
-		case 3, 4: // Handle them together since we generate CDR for them
-			var rpl string
-			if ccr.CCRequestType == 3 {
-				err = self.smg.Call("SMGenericV1.TerminateSession", smgEv, &rpl)
-			} else if ccr.CCRequestType == 4 {
+	return smgEv, nil
+}
+
+// dispatchSMGEvent calls the SMG API corresponding to CC-Request-Type and Requested-Action, returning the maximum usage allowed
+// Balance checks are answered with Check-Balance-Result in the CCA
+func (self DiameterAgent) dispatchSMGEvent(ccr *CCR, smgEv sessionmanager.SMGenericEvent, cca *CCA) (maxUsage float64, err error) {
+	switch ccr.CCRequestType {
+	case 1:
+		if err = self.smg.Call("SMGenericV1.InitiateSession", smgEv, &maxUsage); err == nil {
+			self.indexSession(smgEv.GetUUID(), newDmtSessionFromCCR(ccr))
+		}
+	case 2:
+		if err = self.smg.Call("SMGenericV1.UpdateSession", smgEv, &maxUsage); err == nil {
+			self.indexSession(smgEv.GetUUID(), newDmtSessionFromCCR(ccr)) // Peer might have changed connection meanwhile
+		}
+	case 3, 4: // Handle them together since we generate CDR for them
+		var rpl string
+		if ccr.CCRequestType == 3 {
+			self.unindexSession(smgEv.GetUUID())
+			err = self.smg.Call("SMGenericV1.TerminateSession", smgEv, &rpl)
+		} else if ccr.CCRequestType == 4 {
+			switch ccr.RequestedAction {
+			case DiameterDirectDebiting:
 				err = self.smg.Call("SMGenericV1.ChargeEvent", smgEv, &maxUsage)
 				if maxUsage == 0 {
 					smgEv[utils.USAGE] = 0 // For CDR not to debit
 				}
-			}
-			if self.cgrCfg.DiameterAgentCfg().CreateCDR {
-				if errCdr := self.smg.Call("SMGenericV1.ProcessCDR", smgEv, &rpl); errCdr != nil {
-					err = errCdr
+			case DiameterCheckBalance: // Only query, no CDR since nothing was charged
+				if err = self.smg.Call("SMGenericV1.MaxUsage", smgEv, &maxUsage); err == nil {
+					err = setCheckBalanceResult(cca, maxUsage)
 				}
+				return
+			default:
+				return 0, fmt.Errorf("unsupported Requested-Action: %d", ccr.RequestedAction)
 			}
 		}
-		if err != nil {
-			utils.Logger.Err(fmt.Sprintf("<DiameterAgent> Processing message: %+v, API error: %s", ccr.diamMessage, err))
-			switch { // Prettify some errors
-			case strings.HasSuffix(err.Error(), utils.ErrAccountNotFound.Error()):
-				processorVars[CGRError] = utils.ErrAccountNotFound.Error()
-			case strings.HasSuffix(err.Error(), utils.ErrUserNotFound.Error()):
-				processorVars[CGRError] = utils.ErrUserNotFound.Error()
-			case strings.HasSuffix(err.Error(), utils.ErrInsufficientCredit.Error()):
-				processorVars[CGRError] = utils.ErrInsufficientCredit.Error()
-			case strings.HasSuffix(err.Error(), utils.ErrAccountDisabled.Error()):
-				processorVars[CGRError] = utils.ErrAccountDisabled.Error()
-			case strings.HasSuffix(err.Error(), utils.ErrRatingPlanNotFound.Error()):
-				processorVars[CGRError] = utils.ErrRatingPlanNotFound.Error()
-			case strings.HasSuffix(err.Error(), utils.ErrUnauthorizedDestination.Error()):
-				processorVars[CGRError] = utils.ErrUnauthorizedDestination.Error()
-			default: // Unknown error
-				processorVars[CGRError] = err.Error()
-				processorVars[CGRResultCode] = strconv.Itoa(DiameterRatingFailed)
-			}
-		}
-		if maxUsage < 0 {
-			maxUsage = 0
-		}
-		if prevMaxUsageStr, hasKey := processorVars[CGRMaxUsage]; hasKey {
-			prevMaxUsage, _ := strconv.ParseFloat(prevMaxUsageStr, 64)
-			if prevMaxUsage < maxUsage {
-				maxUsage = prevMaxUsage
+		if self.cgrCfg.DiameterAgentCfg().CreateCDR {
+			if errCdr := self.smg.Call("SMGenericV1.ProcessCDR", smgEv, &rpl); errCdr != nil {
+				err = errCdr
 			}
 		}
-		processorVars[CGRMaxUsage] = strconv.FormatFloat(maxUsage, 'f', -1, 64)
 	}
+	return
+}
+
+// setCCAProcessorAVPs populates the Result-Code and the AVPs configured in the processor
+func (self DiameterAgent) setCCAProcessorAVPs(ccr *CCR, reqProcessor *config.DARequestProcessor, processorVars map[string]string, cca *CCA) (bool, error) {
 	if err := messageSetAVPsWithPath(cca.diamMessage, []interface{}{"Result-Code"}, processorVars[CGRResultCode],
 		false, self.cgrCfg.DiameterAgentCfg().Timezone); err != nil {
 		return false, err
@@ -236,6 +341,157 @@ func (self *DiameterAgent) handleCCR(c diam.Conn, m *diam.Message) {
 	go self.handlerCCR(c, m)
 }
 
+// Disconnect-Peer-Request: answer it, forget the peer and close the connection
+func (self *DiameterAgent) handleDPR(c diam.Conn, m *diam.Message) {
+	dpa := m.Answer(diam.Success)
+	dpa.NewAVP(avp.OriginHost, avp.Mbit, 0, datatype.DiameterIdentity(self.cgrCfg.DiameterAgentCfg().OriginHost))
+	dpa.NewAVP(avp.OriginRealm, avp.Mbit, 0, datatype.DiameterIdentity(self.cgrCfg.DiameterAgentCfg().OriginRealm))
+	self.connMux.Lock()
+	_, err := dpa.WriteTo(c)
+	self.connMux.Unlock()
+	if err != nil {
+		utils.Logger.Err(fmt.Sprintf("<DiameterAgent> Failed to write DPA to %s: %s", c.RemoteAddr(), err))
+	}
+	self.unindexPeer(originHostFromMessage(m), c)
+	c.Close()
+}
+
+// Answers to server initiated requests (ASA, RAA), passed to the requester waiting for them
+func (self *DiameterAgent) handleAnswer(c diam.Conn, m *diam.Message) {
+	self.pendingMux.Lock()
+	ansChan, hasIt := self.pendingAnswers[m.Header.HopByHopID]
+	self.pendingMux.Unlock()
+	if !hasIt {
+		utils.Logger.Warning(fmt.Sprintf("<DiameterAgent> Received unexpected answer from %s:\n%s", c.RemoteAddr(), m))
+		return
+	}
+	select {
+	case ansChan <- m:
+	default: // Requester is not waiting anymore
+	}
+}
+
+// Index the connection of the peer sending the request so we can reach it later with server initiated requests
+func (self *DiameterAgent) indexPeer(c diam.Conn, m *diam.Message) {
+	if m.Header.CommandFlags&diam.RequestFlag == 0 || m.Header.CommandCode == diam.DisconnectPeer {
+		return
+	}
+	originHost := originHostFromMessage(m)
+	if originHost == "" {
+		return
+	}
+	self.peersMux.Lock()
+	defer self.peersMux.Unlock()
+	if self.peers[originHost] == c {
+		return
+	}
+	self.peers[originHost] = c
+	if cn, canNotify := c.(diam.CloseNotifier); canNotify {
+		go func() {
+			<-cn.CloseNotify()
+			self.unindexPeer(originHost, c)
+		}()
+	}
+}
+
+// Remove the peer connection if it was not replaced meanwhile
+func (self *DiameterAgent) unindexPeer(originHost string, c diam.Conn) {
+	self.peersMux.Lock()
+	defer self.peersMux.Unlock()
+	if self.peers[originHost] == c {
+		delete(self.peers, originHost)
+	}
+}
+
+func (self *DiameterAgent) getPeer(originHost string) diam.Conn {
+	self.peersMux.RLock()
+	defer self.peersMux.RUnlock()
+	return self.peers[originHost]
+}
+
+func (self *DiameterAgent) indexSession(originID string, dmtSess *dmtSession) {
+	self.sessionsMux.Lock()
+	self.sessions[originID] = dmtSess
+	self.sessionsMux.Unlock()
+}
+
+func (self *DiameterAgent) unindexSession(originID string) {
+	self.sessionsMux.Lock()
+	delete(self.sessions, originID)
+	self.sessionsMux.Unlock()
+}
+
+func (self *DiameterAgent) getSession(originID string) *dmtSession {
+	self.sessionsMux.RLock()
+	defer self.sessionsMux.RUnlock()
+	return self.sessions[originID]
+}
+
+// Writes a server initiated request towards the peer and waits for it's answer
+func (self *DiameterAgent) sendRequest(c diam.Conn, m *diam.Message) (*diam.Message, error) {
+	ansChan := make(chan *diam.Message, 1)
+	self.pendingMux.Lock()
+	self.pendingAnswers[m.Header.HopByHopID] = ansChan
+	self.pendingMux.Unlock()
+	defer func() {
+		self.pendingMux.Lock()
+		delete(self.pendingAnswers, m.Header.HopByHopID)
+		self.pendingMux.Unlock()
+	}()
+	self.connMux.Lock()
+	_, err := m.WriteTo(c)
+	self.connMux.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	select {
+	case ans := <-ansChan:
+		return ans, nil
+	case <-time.After(self.cgrCfg.ReplyTimeout):
+		return nil, utils.ErrTimedOut
+	}
+}
+
+// V1DisconnectSession is called by SMG when it terminates a session, sends ASR or RAR to the client and checks it's answer
+func (self *DiameterAgent) V1DisconnectSession(args utils.AttrDisconnectSession, reply *string) error {
+	originID := sessionmanager.SMGenericEvent(args.EventStart).GetUUID()
+	dmtSess := self.getSession(originID)
+	if dmtSess == nil {
+		return utils.ErrNotFound
+	}
+	self.unindexSession(originID) // SMG is not tracking it anymore
+	c := self.getPeer(dmtSess.OriginHost)
+	if c == nil {
+		return sessionmanager.ErrConnectionNotFound
+	}
+	req, err := dmtSess.AsDisconnectRequest(self.cgrCfg.DiameterAgentCfg().DisconnectMethod,
+		self.cgrCfg.DiameterAgentCfg().OriginHost, self.cgrCfg.DiameterAgentCfg().OriginRealm)
+	if err != nil {
+		return err
+	}
+	ans, err := self.sendRequest(c, req)
+	if err != nil {
+		utils.Logger.Err(fmt.Sprintf("<DiameterAgent> Disconnecting session: %s, reason: %s, error: %s", dmtSess.SessionId, args.Reason, err))
+		return err
+	}
+	if resultCode, err := resultCodeFromAnswer(ans); err != nil {
+		return err
+	} else if resultCode != diam.Success {
+		return fmt.Errorf("unexpected Result-Code: %d", resultCode)
+	}
+	*reply = utils.OK
+	return nil
+}
+
+// BiRPCHandlers are served on the BiRPC connections towards SMG so it can ask for session disconnects
+func (self *DiameterAgent) BiRPCHandlers() map[string]interface{} {
+	return map[string]interface{}{
+		"SMGClientV1.DisconnectSession": func(clnt *rpc2.Client, args utils.AttrDisconnectSession, reply *string) error {
+			return self.V1DisconnectSession(args, reply)
+		},
+	}
+}
+
 func (self *DiameterAgent) handleALL(c diam.Conn, m *diam.Message) {
 	utils.Logger.Warning(fmt.Sprintf("<DiameterAgent> Received unexpected message from %s:\n%s", c.RemoteAddr(), m))
 }
@@ -59,6 +59,16 @@ const (
 	CGRResultCode        = "CGRResultCode"
 )
 
+// Diameter Credit Control specific values, RFC 4006
+const (
+	DiameterCreditLimitReached = 4012
+	DiameterUserUnknown        = 5030
+	DiameterDirectDebiting     = 0 // Requested-Action
+	DiameterCheckBalance       = 2 // Requested-Action
+	DiameterEnoughCredit       = 0 // Check-Balance-Result
+	DiameterNoCredit           = 1 // Check-Balance-Result
+)
+
 var (
 	ErrFilterNotPassing     = errors.New("Filter not passing")
 	ErrDiameterRatingFailed = errors.New("Diameter rating failed")
@@ -507,6 +517,7 @@ type CCR struct {
 	ServiceContextId  string    `avp:"Service-Context-Id"`
 	CCRequestType     int       `avp:"CC-Request-Type"`
 	CCRequestNumber   int       `avp:"CC-Request-Number"`
+	RequestedAction   int       `avp:"Requested-Action"` // Only considered for CCR-Event, defaults to DIRECT_DEBITING
 	EventTimestamp    time.Time `avp:"Event-Timestamp"`
 	SubscriptionId    []struct {
 		SubscriptionIdType int    `avp:"Subscription-Id-Type"`
@@ -613,6 +624,23 @@ func (self *CCR) AsDiameterMessage() (*diam.Message, error) {
 	return m, nil
 }
 
+// msccCCR returns a copy of the CCR where the diameter message contains only one of the Multiple-Services-Credit-Control AVPs,
+// so the processor templates apply to that service only
+func (self *CCR) msccCCR(msccAVP *diam.AVP) *CCR {
+	msccCCR := *self
+	m := diam.NewMessage(self.diamMessage.Header.CommandCode, self.diamMessage.Header.CommandFlags, self.diamMessage.Header.ApplicationID,
+		self.diamMessage.Header.HopByHopID, self.diamMessage.Header.EndToEndID, self.diamMessage.Dictionary())
+	for _, a := range self.diamMessage.AVP {
+		if a.Code == 456 && a != msccAVP { // Other Multiple-Services-Credit-Control
+			continue
+		}
+		m.AVP = append(m.AVP, a)
+		m.Header.MessageLength += uint32(a.Len())
+	}
+	msccCCR.diamMessage = m
+	return &msccCCR
+}
+
 // Extracts data out of CCR into a SMGenericEvent based on the configured template
 func (self *CCR) AsSMGenericEvent(cfgFlds []*config.CfgCdrField) (sessionmanager.SMGenericEvent, error) {
 	outMap := make(map[string]string) // work with it so we can append values to keys
@@ -635,6 +663,89 @@ func (self *CCR) AsSMGenericEvent(cfgFlds []*config.CfgCdrField) (sessionmanager
 	return sessionmanager.SMGenericEvent(utils.ConvertMapValStrIf(outMap)), nil
 }
 
+// msccAnswerDetails returns the identifiers of the Multiple-Services-Credit-Control, to be copied in the answer,
+// and the code of the AVP granting units, depending on the units requested (or used) by the client
+func msccAnswerDetails(msccAVP *diam.AVP) (ratingGroup, serviceIdentifier *diam.AVP, grantedUnitCode uint32) {
+	grantedUnitCode = 420 // CC-Time
+	grpAVP, canCast := msccAVP.Data.(*diam.GroupedAVP)
+	if !canCast {
+		return
+	}
+	var unitsRequested bool
+	for _, subAVP := range grpAVP.AVP {
+		switch subAVP.Code {
+		case 432: // Rating-Group
+			ratingGroup = subAVP
+		case 439: // Service-Identifier
+			serviceIdentifier = subAVP
+		case 437, 446: // Requested-Service-Unit, Used-Service-Unit
+			if unitsRequested {
+				continue // Requested-Service-Unit has priority
+			}
+			unitsGrp, canCast := subAVP.Data.(*diam.GroupedAVP)
+			if !canCast {
+				continue
+			}
+			for _, unitAVP := range unitsGrp.AVP {
+				switch unitAVP.Code {
+				case 421, 412, 414: // CC-Total-Octets, CC-Input-Octets, CC-Output-Octets
+					grantedUnitCode = 421
+				case 417: // CC-Service-Specific-Units
+					grantedUnitCode = 417
+				}
+			}
+			unitsRequested = subAVP.Code == 437
+		}
+	}
+	return
+}
+
+// grantedUnitsAVP builds the AVP within Granted-Service-Unit
+func grantedUnitsAVP(grantedUnitCode uint32, units float64) *diam.AVP {
+	if grantedUnitCode == 420 { // CC-Time
+		return diam.NewAVP(grantedUnitCode, avp.Mbit, 0, datatype.Unsigned32(units))
+	}
+	return diam.NewAVP(grantedUnitCode, avp.Mbit, 0, datatype.Unsigned64(units))
+}
+
+// cgrErrorAndResultCode converts the API error into CGRError and CGRResultCode processor variables
+// Known errors keep the success code so they can be treated with CCA templates
+func cgrErrorAndResultCode(err error) (cgrError, resultCode string) {
+	resultCode = strconv.Itoa(diam.Success)
+	for _, knownErr := range []error{utils.ErrAccountNotFound, utils.ErrUserNotFound, utils.ErrInsufficientCredit,
+		utils.ErrAccountDisabled, utils.ErrRatingPlanNotFound, utils.ErrUnauthorizedDestination} { // Prettify some errors
+		if strings.HasSuffix(err.Error(), knownErr.Error()) {
+			return knownErr.Error(), resultCode
+		}
+	}
+	return err.Error(), strconv.Itoa(DiameterRatingFailed) // Unknown error
+}
+
+// msccResultCodeForError returns the Result-Code within Multiple-Services-Credit-Control for a CGRError
+func msccResultCodeForError(cgrError string) int {
+	switch cgrError {
+	case utils.ErrInsufficientCredit.Error(), utils.ErrAccountDisabled.Error():
+		return DiameterCreditLimitReached
+	case utils.ErrAccountNotFound.Error(), utils.ErrUserNotFound.Error():
+		return DiameterUserUnknown
+	}
+	return DiameterRatingFailed
+}
+
+// setProcessorMaxUsage keeps in processorVars the smallest maxUsage out of the processed ones
+func setProcessorMaxUsage(processorVars map[string]string, maxUsage float64) {
+	if maxUsage < 0 {
+		maxUsage = 0
+	}
+	if prevMaxUsageStr, hasKey := processorVars[CGRMaxUsage]; hasKey {
+		prevMaxUsage, _ := strconv.ParseFloat(prevMaxUsageStr, 64)
+		if prevMaxUsage < maxUsage {
+			maxUsage = prevMaxUsage
+		}
+	}
+	processorVars[CGRMaxUsage] = strconv.FormatFloat(maxUsage, 'f', -1, 64)
+}
+
 func NewBareCCAFromCCR(ccr *CCR, originHost, originRealm string) *CCA {
 	cca := &CCA{SessionId: ccr.SessionId, AuthApplicationId: ccr.AuthApplicationId, CCRequestType: ccr.CCRequestType, CCRequestNumber: ccr.CCRequestNumber,
 		OriginHost: originHost, OriginRealm: originRealm,
@@ -701,3 +812,74 @@ func (self *CCA) SetProcessorAVPs(reqProcessor *config.DARequestProcessor, proce
 	}
 	return nil
 }
+
+// Details of a Diameter session needed to build server initiated requests (ASR/RAR) towards the client
+type dmtSession struct {
+	SessionId         string
+	OriginHost        string // Origin-Host of the client, used to locate the peer connection
+	OriginRealm       string
+	AuthApplicationId int
+}
+
+func newDmtSessionFromCCR(ccr *CCR) *dmtSession {
+	return &dmtSession{SessionId: ccr.SessionId, OriginHost: ccr.OriginHost, OriginRealm: ccr.OriginRealm, AuthApplicationId: ccr.AuthApplicationId}
+}
+
+// AsDisconnectRequest builds the Abort-Session-Request or Re-Auth-Request used to terminate the session from server side
+func (self *dmtSession) AsDisconnectRequest(method, originHost, originRealm string) (*diam.Message, error) {
+	var m *diam.Message
+	switch method {
+	case utils.MetaASR:
+		m = diam.NewRequest(diam.AbortSession, uint32(self.AuthApplicationId), nil)
+	case utils.MetaRAR:
+		m = diam.NewRequest(diam.ReAuth, uint32(self.AuthApplicationId), nil)
+	default:
+		return nil, fmt.Errorf("unsupported disconnect method: %s", method)
+	}
+	m.NewAVP(avp.SessionID, avp.Mbit, 0, datatype.UTF8String(self.SessionId))
+	m.NewAVP(avp.OriginHost, avp.Mbit, 0, datatype.DiameterIdentity(originHost))
+	m.NewAVP(avp.OriginRealm, avp.Mbit, 0, datatype.DiameterIdentity(originRealm))
+	m.NewAVP(avp.DestinationRealm, avp.Mbit, 0, datatype.DiameterIdentity(self.OriginRealm))
+	m.NewAVP(avp.DestinationHost, avp.Mbit, 0, datatype.DiameterIdentity(self.OriginHost))
+	m.NewAVP(avp.AuthApplicationID, avp.Mbit, 0, datatype.Unsigned32(self.AuthApplicationId))
+	if method == utils.MetaRAR {
+		m.NewAVP(avp.ReAuthRequestType, avp.Mbit, 0, datatype.Enumerated(0)) // AUTHORIZE_ONLY, client needs to come back with CCR
+	}
+	return m, nil
+}
+
+// setCheckBalanceResult answers a balance check out of maxUsage, NO_CREDIT once any of the services checked has no usage left
+func setCheckBalanceResult(cca *CCA, maxUsage float64) error {
+	result := datatype.Enumerated(DiameterEnoughCredit)
+	if maxUsage <= 0 {
+		result = datatype.Enumerated(DiameterNoCredit)
+	}
+	if cbrAVP, err := cca.diamMessage.FindAVP("Check-Balance-Result", dict.UndefinedVendorID); err == nil && cbrAVP != nil { // Set already by a previous MSCC
+		if result == DiameterNoCredit {
+			cbrAVP.Data = result
+		}
+		return nil
+	}
+	_, err := cca.diamMessage.NewAVP(422, avp.Mbit, 0, result) // Check-Balance-Result
+	return err
+}
+
+// Returns the Origin-Host of a Diameter message, empty if not present
+func originHostFromMessage(m *diam.Message) string {
+	ohAVP, err := m.FindAVP("Origin-Host", dict.UndefinedVendorID)
+	if err != nil || ohAVP == nil {
+		return ""
+	}
+	return avpValAsString(ohAVP)
+}
+
+// Returns the Result-Code out of a Diameter answer
+func resultCodeFromAnswer(m *diam.Message) (int, error) {
+	rcAVP, err := m.FindAVP("Result-Code", dict.UndefinedVendorID)
+	if err != nil {
+		return 0, err
+	} else if rcAVP == nil {
+		return 0, utils.ErrNotFound
+	}
+	return strconv.Atoi(avpValAsString(rcAVP))
+}
@@ -21,6 +21,7 @@ package agents
 import (
 	"bytes"
 	"encoding/binary"
+	"errors"
 	"fmt"
 	"reflect"
 	"testing"
@@ -480,3 +481,171 @@ func TestPassesFieldFilter(t *testing.T) {
 		t.Error("Does not pass")
 	}
 }
+
+func TestCCRMsccCCR(t *testing.T) {
+	ccr := &CCR{
+		SessionId:         "msccccr1",
+		AuthApplicationId: 4,
+		CCRequestType:     2,
+	}
+	ccr.diamMessage = ccr.AsBareDiameterMessage()
+	ccr.diamMessage.NewAVP("Multiple-Services-Credit-Control", avp.Mbit, 0, &diam.GroupedAVP{
+		AVP: []*diam.AVP{
+			diam.NewAVP(437, avp.Mbit, 0, &diam.GroupedAVP{ // Requested-Service-Unit
+				AVP: []*diam.AVP{
+					diam.NewAVP(421, avp.Mbit, 0, datatype.Unsigned64(1024)), // CC-Total-Octets
+				},
+			}),
+			diam.NewAVP(432, avp.Mbit, 0, datatype.Unsigned32(1)), // Rating-Group
+		},
+	})
+	ccr.diamMessage.NewAVP("Multiple-Services-Credit-Control", avp.Mbit, 0, &diam.GroupedAVP{
+		AVP: []*diam.AVP{
+			diam.NewAVP(437, avp.Mbit, 0, &diam.GroupedAVP{ // Requested-Service-Unit
+				AVP: []*diam.AVP{
+					diam.NewAVP(417, avp.Mbit, 0, datatype.Unsigned64(1)), // CC-Service-Specific-Units
+				},
+			}),
+			diam.NewAVP(432, avp.Mbit, 0, datatype.Unsigned32(2)), // Rating-Group
+		},
+	})
+	msccAVPs, err := ccr.diamMessage.FindAVPsWithPath([]interface{}{"Multiple-Services-Credit-Control"}, dict.UndefinedVendorID)
+	if err != nil {
+		t.Fatal(err)
+	} else if len(msccAVPs) != 2 {
+		t.Fatalf("Unexpected MSCCs: %+v", msccAVPs)
+	}
+	msccCCR := ccr.msccCCR(msccAVPs[1])
+	if msccCCR.SessionId != ccr.SessionId || msccCCR.CCRequestType != ccr.CCRequestType {
+		t.Errorf("Unexpected CCR: %+v", msccCCR)
+	}
+	if avps, err := msccCCR.diamMessage.FindAVPsWithPath([]interface{}{"Multiple-Services-Credit-Control", "Rating-Group"}, dict.UndefinedVendorID); err != nil {
+		t.Error(err)
+	} else if len(avps) != 1 || avpValAsString(avps[0]) != "2" {
+		t.Errorf("Unexpected Rating-Group AVPs: %+v", avps)
+	}
+	if avps, err := msccCCR.diamMessage.FindAVPsWithPath([]interface{}{"Session-Id"}, dict.UndefinedVendorID); err != nil {
+		t.Error(err)
+	} else if len(avps) != 1 {
+		t.Errorf("Unexpected Session-Id AVPs: %+v", avps)
+	}
+	if rg, si, grantedUnitCode := msccAnswerDetails(msccAVPs[0]); rg == nil || avpValAsString(rg) != "1" || si != nil || grantedUnitCode != 421 {
+		t.Errorf("Unexpected details: %+v, %+v, %d", rg, si, grantedUnitCode)
+	}
+	if rg, si, grantedUnitCode := msccAnswerDetails(msccAVPs[1]); rg == nil || avpValAsString(rg) != "2" || si != nil || grantedUnitCode != 417 {
+		t.Errorf("Unexpected details: %+v, %+v, %d", rg, si, grantedUnitCode)
+	}
+}
+
+func TestCgrErrorAndResultCode(t *testing.T) {
+	if cgrErr, resCode := cgrErrorAndResultCode(fmt.Errorf("SERVER_ERROR: %s", utils.ErrInsufficientCredit.Error())); cgrErr != utils.ErrInsufficientCredit.Error() || resCode != "2001" {
+		t.Errorf("Received: %s, %s", cgrErr, resCode)
+	}
+	if cgrErr, resCode := cgrErrorAndResultCode(errors.New("UNKNOWN")); cgrErr != "UNKNOWN" || resCode != "5031" {
+		t.Errorf("Received: %s, %s", cgrErr, resCode)
+	}
+	if resCode := msccResultCodeForError(utils.ErrInsufficientCredit.Error()); resCode != DiameterCreditLimitReached {
+		t.Error("Received: ", resCode)
+	}
+	if resCode := msccResultCodeForError(utils.ErrUserNotFound.Error()); resCode != DiameterUserUnknown {
+		t.Error("Received: ", resCode)
+	}
+}
+
+func TestSetProcessorMaxUsage(t *testing.T) {
+	processorVars := make(map[string]string)
+	setProcessorMaxUsage(processorVars, 300)
+	setProcessorMaxUsage(processorVars, 120)
+	setProcessorMaxUsage(processorVars, 180)
+	if processorVars[CGRMaxUsage] != "120" {
+		t.Error("Received: ", processorVars[CGRMaxUsage])
+	}
+	setProcessorMaxUsage(processorVars, -1)
+	if processorVars[CGRMaxUsage] != "0" {
+		t.Error("Received: ", processorVars[CGRMaxUsage])
+	}
+}
+
+func TestDmtSessionAsDisconnectRequest(t *testing.T) {
+	ccr := &CCR{SessionId: "routinga;1442095190;1476802709", OriginHost: "CGR-DA-CLNT", OriginRealm: "cgrates.net", AuthApplicationId: 4}
+	dmtSess := newDmtSessionFromCCR(ccr)
+	if _, err := dmtSess.AsDisconnectRequest("*unsupported", "CGR-DA", "cgrates.org"); err == nil {
+		t.Error("Should not accept unsupported methods")
+	}
+	asr, err := dmtSess.AsDisconnectRequest(utils.MetaASR, "CGR-DA", "cgrates.org")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if asr.Header.CommandCode != diam.AbortSession || asr.Header.CommandFlags&diam.RequestFlag == 0 || asr.Header.ApplicationID != 4 {
+		t.Errorf("Unexpected header: %+v", asr.Header)
+	}
+	if avps, err := asr.FindAVPsWithPath([]interface{}{"Session-Id"}, dict.UndefinedVendorID); err != nil {
+		t.Error(err)
+	} else if len(avps) != 1 || avpValAsString(avps[0]) != ccr.SessionId {
+		t.Errorf("Unexpected Session-Id AVPs: %+v", avps)
+	}
+	if avps, err := asr.FindAVPsWithPath([]interface{}{"Destination-Host"}, dict.UndefinedVendorID); err != nil {
+		t.Error(err)
+	} else if len(avps) != 1 || avpValAsString(avps[0]) != ccr.OriginHost {
+		t.Errorf("Unexpected Destination-Host AVPs: %+v", avps)
+	}
+	if originHost := originHostFromMessage(asr); originHost != "CGR-DA" {
+		t.Error("Received: ", originHost)
+	}
+	if avps, _ := asr.FindAVPsWithPath([]interface{}{"Re-Auth-Request-Type"}, dict.UndefinedVendorID); len(avps) != 0 {
+		t.Errorf("Unexpected Re-Auth-Request-Type AVPs: %+v", avps)
+	}
+	rar, err := dmtSess.AsDisconnectRequest(utils.MetaRAR, "CGR-DA", "cgrates.org")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rar.Header.CommandCode != diam.ReAuth {
+		t.Errorf("Unexpected header: %+v", rar.Header)
+	}
+	if avps, err := rar.FindAVPsWithPath([]interface{}{"Re-Auth-Request-Type"}, dict.UndefinedVendorID); err != nil {
+		t.Error(err)
+	} else if len(avps) != 1 {
+		t.Errorf("Unexpected Re-Auth-Request-Type AVPs: %+v", avps)
+	}
+}
+
+func TestResultCodeFromAnswer(t *testing.T) {
+	asr, err := newDmtSessionFromCCR(&CCR{SessionId: "session1", OriginHost: "CGR-DA-CLNT", OriginRealm: "cgrates.net", AuthApplicationId: 4}).AsDisconnectRequest(utils.MetaASR, "CGR-DA", "cgrates.org")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := resultCodeFromAnswer(asr); err == nil {
+		t.Error("Requests should not have Result-Code")
+	}
+	if resultCode, err := resultCodeFromAnswer(asr.Answer(diam.UnableToComply)); err != nil {
+		t.Error(err)
+	} else if resultCode != diam.UnableToComply {
+		t.Error("Received: ", resultCode)
+	}
+}
+
+func TestSetCheckBalanceResult(t *testing.T) {
+	ccr := &CCR{SessionId: "routinga;1442095190;1476802709", AuthApplicationId: 4, CCRequestType: 4, CCRequestNumber: 0,
+		RequestedAction: DiameterCheckBalance}
+	ccr.diamMessage = ccr.AsBareDiameterMessage()
+	cca := NewBareCCAFromCCR(ccr, "CGR-DA", "cgrates.org")
+	for _, tc := range []struct {
+		maxUsage float64
+		eResult  string
+	}{
+		{maxUsage: 300, eResult: "0"}, // ENOUGH_CREDIT
+		{maxUsage: 0, eResult: "1"},   // NO_CREDIT
+		{maxUsage: 300, eResult: "1"}, // No credit for one of the services checks the whole balance out
+	} {
+		if err := setCheckBalanceResult(cca, tc.maxUsage); err != nil {
+			t.Fatal(err)
+		}
+		if cbrAVPs, err := cca.diamMessage.FindAVPsWithPath([]interface{}{"Check-Balance-Result"}, dict.UndefinedVendorID); err != nil {
+			t.Error(err)
+		} else if len(cbrAVPs) != 1 {
+			t.Errorf("Unexpected Check-Balance-Result AVPs: %+v", cbrAVPs)
+		} else if result := avpValAsString(cbrAVPs[0]); result != tc.eResult {
+			t.Errorf("MaxUsage: %v, expecting: %s, received: %s", tc.maxUsage, tc.eResult, result)
+		}
+	}
+}
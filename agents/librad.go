@@ -0,0 +1,783 @@
+/*
+Real-time Charging System for Telecom & ISP environments
+Copyright (C) ITsysCOM GmbH
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>
+*/
+
+package agents
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/hmac"
+	"crypto/md5"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/cgrates/cgrates/config"
+	"github.com/cgrates/cgrates/sessionmanager"
+	"github.com/cgrates/cgrates/utils"
+)
+
+// RADIUS packet codes, RFC 2865 and RFC 2866
+const (
+	RadiusAccessRequest      = 1
+	RadiusAccessAccept       = 2
+	RadiusAccessReject       = 3
+	RadiusAccountingRequest  = 4
+	RadiusAccountingResponse = 5
+)
+
+// Acct-Status-Type values, RFC 2866
+const (
+	RadiusAcctStart         = 1
+	RadiusAcctStop          = 2
+	RadiusAcctInterimUpdate = 3
+)
+
+// Attribute types understood by the RADIUS dictionary
+const (
+	RadiusTypeString  = "string"
+	RadiusTypeOctets  = "octets"
+	RadiusTypeInteger = "integer"
+	RadiusTypeIPAddr  = "ipaddr"
+	RadiusTypeDate    = "date"
+)
+
+const (
+	RADIUS_REQUEST       = "RADIUS_REQUEST"
+	MetaRadReqType       = "*radReqType" // processor variable identifying the type of request
+	MetaRadAuth          = "*radAuth"
+	MetaRadAcctStart     = "*radAcctStart"
+	MetaRadAcctUpdate    = "*radAcctUpdate"
+	MetaRadAcctStop      = "*radAcctStop"
+	radiusHeaderLen      = 20
+	radiusMaxPacketLen   = 4096
+	radiusVendorSpecific = 26
+	radiusMessageAuth    = 80 // Message-Authenticator, RFC 3579
+)
+
+var ErrRadiusNotAuthentic = errors.New("RADIUS authenticator not matching")
+
+// Attributes defined in RFC 2865, RFC 2866 and RFC 3579, always available in the dictionary
+var radiusDefaultDictionary = `
+ATTRIBUTE	User-Name		1	string
+ATTRIBUTE	User-Password		2	string
+ATTRIBUTE	CHAP-Password		3	octets
+ATTRIBUTE	NAS-IP-Address		4	ipaddr
+ATTRIBUTE	NAS-Port		5	integer
+ATTRIBUTE	Service-Type		6	integer
+ATTRIBUTE	Framed-Protocol		7	integer
+ATTRIBUTE	Framed-IP-Address	8	ipaddr
+ATTRIBUTE	Framed-IP-Netmask	9	ipaddr
+ATTRIBUTE	Framed-Routing		10	integer
+ATTRIBUTE	Filter-Id		11	string
+ATTRIBUTE	Framed-MTU		12	integer
+ATTRIBUTE	Framed-Compression	13	integer
+ATTRIBUTE	Login-IP-Host		14	ipaddr
+ATTRIBUTE	Login-Service		15	integer
+ATTRIBUTE	Login-TCP-Port		16	integer
+ATTRIBUTE	Reply-Message		18	string
+ATTRIBUTE	Callback-Number		19	string
+ATTRIBUTE	Callback-Id		20	string
+ATTRIBUTE	Framed-Route		22	string
+ATTRIBUTE	Framed-IPX-Network	23	ipaddr
+ATTRIBUTE	State			24	octets
+ATTRIBUTE	Class			25	octets
+ATTRIBUTE	Vendor-Specific		26	octets
+ATTRIBUTE	Session-Timeout		27	integer
+ATTRIBUTE	Idle-Timeout		28	integer
+ATTRIBUTE	Termination-Action	29	integer
+ATTRIBUTE	Called-Station-Id	30	string
+ATTRIBUTE	Calling-Station-Id	31	string
+ATTRIBUTE	NAS-Identifier		32	string
+ATTRIBUTE	Proxy-State		33	octets
+ATTRIBUTE	Login-LAT-Service	34	string
+ATTRIBUTE	Login-LAT-Node		35	string
+ATTRIBUTE	Login-LAT-Group		36	octets
+ATTRIBUTE	Framed-AppleTalk-Link	37	integer
+ATTRIBUTE	Framed-AppleTalk-Network 38	integer
+ATTRIBUTE	Framed-AppleTalk-Zone	39	string
+ATTRIBUTE	Acct-Status-Type	40	integer
+ATTRIBUTE	Acct-Delay-Time		41	integer
+ATTRIBUTE	Acct-Input-Octets	42	integer
+ATTRIBUTE	Acct-Output-Octets	43	integer
+ATTRIBUTE	Acct-Session-Id		44	string
+ATTRIBUTE	Acct-Authentic		45	integer
+ATTRIBUTE	Acct-Session-Time	46	integer
+ATTRIBUTE	Acct-Input-Packets	47	integer
+ATTRIBUTE	Acct-Output-Packets	48	integer
+ATTRIBUTE	Acct-Terminate-Cause	49	integer
+ATTRIBUTE	Acct-Multi-Session-Id	50	string
+ATTRIBUTE	Acct-Link-Count		51	integer
+ATTRIBUTE	CHAP-Challenge		60	octets
+ATTRIBUTE	NAS-Port-Type		61	integer
+ATTRIBUTE	Port-Limit		62	integer
+ATTRIBUTE	Login-LAT-Port		63	string
+ATTRIBUTE	Message-Authenticator	80	octets
+
+VALUE	Service-Type		Login-User		1
+VALUE	Service-Type		Framed-User		2
+VALUE	Service-Type		Callback-Login-User	3
+VALUE	Service-Type		Callback-Framed-User	4
+VALUE	Service-Type		Outbound-User		5
+VALUE	Service-Type		Administrative-User	6
+VALUE	Service-Type		NAS-Prompt-User		7
+VALUE	Service-Type		Authenticate-Only	8
+VALUE	Service-Type		Call-Check		10
+VALUE	Termination-Action	Default			0
+VALUE	Termination-Action	RADIUS-Request		1
+VALUE	Acct-Status-Type	Start			1
+VALUE	Acct-Status-Type	Stop			2
+VALUE	Acct-Status-Type	Interim-Update		3
+VALUE	Acct-Status-Type	Accounting-On		7
+VALUE	Acct-Status-Type	Accounting-Off		8
+VALUE	Acct-Authentic		RADIUS			1
+VALUE	Acct-Authentic		Local			2
+VALUE	Acct-Authentic		Remote			3
+VALUE	Acct-Terminate-Cause	User-Request		1
+VALUE	Acct-Terminate-Cause	Lost-Carrier		2
+VALUE	Acct-Terminate-Cause	Lost-Service		3
+VALUE	Acct-Terminate-Cause	Idle-Timeout		4
+VALUE	Acct-Terminate-Cause	Session-Timeout		5
+VALUE	Acct-Terminate-Cause	Admin-Reset		6
+VALUE	Acct-Terminate-Cause	Admin-Reboot		7
+VALUE	Acct-Terminate-Cause	Port-Error		8
+VALUE	Acct-Terminate-Cause	NAS-Error		9
+VALUE	Acct-Terminate-Cause	NAS-Request		10
+VALUE	Acct-Terminate-Cause	NAS-Reboot		11
+VALUE	Acct-Terminate-Cause	Port-Unneeded		12
+VALUE	Acct-Terminate-Cause	Port-Preempted		13
+VALUE	Acct-Terminate-Cause	Port-Suspended		14
+VALUE	Acct-Terminate-Cause	Service-Unavailable	15
+VALUE	Acct-Terminate-Cause	Callback		16
+VALUE	Acct-Terminate-Cause	User-Error		17
+VALUE	Acct-Terminate-Cause	Host-Request		18
+VALUE	NAS-Port-Type		Async			0
+VALUE	NAS-Port-Type		Sync			1
+VALUE	NAS-Port-Type		ISDN			2
+VALUE	NAS-Port-Type		Virtual			5
+VALUE	NAS-Port-Type		Ethernet		15
+VALUE	NAS-Port-Type		Wireless-802.11		19
+`
+
+// One attribute definition out of RADIUS dictionary
+type RadiusDictAttribute struct {
+	Name     string
+	VendorID uint32 // 0 for standard attributes
+	Code     uint8
+	Type     string
+	values   map[uint32]string // VALUE definitions, indexed on numeric value
+	valueIDs map[string]uint32 // VALUE definitions, indexed on name
+}
+
+// ValueName returns the name of an integer value if defined in dictionary, the number otherwise
+func (self *RadiusDictAttribute) ValueName(val uint32) string {
+	if name, hasIt := self.values[val]; hasIt {
+		return name
+	}
+	return strconv.FormatUint(uint64(val), 10)
+}
+
+// RADIUS dictionary in FreeRADIUS format (ATTRIBUTE, VALUE, VENDOR, BEGIN-VENDOR and END-VENDOR are understood)
+type RadiusDictionary struct {
+	attrsByName map[string]*RadiusDictAttribute
+	attrsByCode map[uint32]map[uint8]*RadiusDictAttribute // indexed on vendor id, then attribute code
+	vendors     map[string]uint32
+}
+
+func NewRadiusDictionary() *RadiusDictionary {
+	return &RadiusDictionary{attrsByName: make(map[string]*RadiusDictAttribute),
+		attrsByCode: make(map[uint32]map[uint8]*RadiusDictAttribute), vendors: make(map[string]uint32)}
+}
+
+// NewRadiusDictionaryWithDefaults returns a dictionary populated with RFC 2865, RFC 2866 and RFC 3579 attributes
+func NewRadiusDictionaryWithDefaults() *RadiusDictionary {
+	dict := NewRadiusDictionary()
+	if err := dict.ParseFromReader(strings.NewReader(radiusDefaultDictionary)); err != nil {
+		panic(err) // Should never happen since it is our own dictionary
+	}
+	return dict
+}
+
+// ParseFromReader loads dictionary definitions, overwriting the ones with the same name
+func (self *RadiusDictionary) ParseFromReader(rdr io.Reader) error {
+	var vendorID uint32 // set within BEGIN-VENDOR/END-VENDOR blocks
+	scanner := bufio.NewScanner(rdr)
+	lineNr := 0
+	for scanner.Scan() {
+		lineNr++
+		line := scanner.Text()
+		if idx := strings.Index(line, "#"); idx != -1 {
+			line = line[:idx]
+		}
+		flds := strings.Fields(line)
+		if len(flds) == 0 {
+			continue
+		}
+		switch flds[0] {
+		case "VENDOR":
+			if len(flds) < 3 {
+				return fmt.Errorf("line %d: incomplete VENDOR definition", lineNr)
+			}
+			vID, err := strconv.ParseUint(flds[2], 10, 32)
+			if err != nil {
+				return fmt.Errorf("line %d: %s", lineNr, err.Error())
+			}
+			self.vendors[flds[1]] = uint32(vID)
+		case "BEGIN-VENDOR":
+			if len(flds) < 2 {
+				return fmt.Errorf("line %d: incomplete BEGIN-VENDOR definition", lineNr)
+			}
+			vID, hasIt := self.vendors[flds[1]]
+			if !hasIt {
+				return fmt.Errorf("line %d: unknown vendor: %s", lineNr, flds[1])
+			}
+			vendorID = vID
+		case "END-VENDOR":
+			vendorID = 0
+		case "ATTRIBUTE":
+			if len(flds) < 4 {
+				return fmt.Errorf("line %d: incomplete ATTRIBUTE definition", lineNr)
+			}
+			code, err := strconv.ParseUint(flds[2], 10, 8)
+			if err != nil {
+				return fmt.Errorf("line %d: %s", lineNr, err.Error())
+			}
+			attrVendorID := vendorID
+			if len(flds) > 4 { // Old style vendor definition, flags are ignored
+				if vID, hasIt := self.vendors[flds[4]]; hasIt {
+					attrVendorID = vID
+				}
+			}
+			self.addAttribute(&RadiusDictAttribute{Name: flds[1], VendorID: attrVendorID, Code: uint8(code), Type: flds[3],
+				values: make(map[uint32]string), valueIDs: make(map[string]uint32)})
+		case "VALUE":
+			if len(flds) < 4 {
+				return fmt.Errorf("line %d: incomplete VALUE definition", lineNr)
+			}
+			dictAttr, hasIt := self.attrsByName[flds[1]]
+			if !hasIt {
+				return fmt.Errorf("line %d: VALUE for unknown attribute: %s", lineNr, flds[1])
+			}
+			val, err := strconv.ParseUint(flds[3], 0, 32)
+			if err != nil {
+				return fmt.Errorf("line %d: %s", lineNr, err.Error())
+			}
+			dictAttr.values[uint32(val)] = flds[2]
+			dictAttr.valueIDs[flds[2]] = uint32(val)
+		}
+	}
+	return scanner.Err()
+}
+
+func (self *RadiusDictionary) addAttribute(dictAttr *RadiusDictAttribute) {
+	self.attrsByName[dictAttr.Name] = dictAttr
+	if _, hasIt := self.attrsByCode[dictAttr.VendorID]; !hasIt {
+		self.attrsByCode[dictAttr.VendorID] = make(map[uint8]*RadiusDictAttribute)
+	}
+	self.attrsByCode[dictAttr.VendorID][dictAttr.Code] = dictAttr
+}
+
+func (self *RadiusDictionary) AttributeWithName(name string) *RadiusDictAttribute {
+	return self.attrsByName[name]
+}
+
+func (self *RadiusDictionary) AttributeWithCode(vendorID uint32, code uint8) *RadiusDictAttribute {
+	return self.attrsByCode[vendorID][code]
+}
+
+// Loads all the dictionary files out of a folder into dict
+func loadRadiusDictionaries(dict *RadiusDictionary, dictsDir, componentId string) error {
+	fi, err := os.Stat(dictsDir)
+	if err != nil {
+		if strings.HasSuffix(err.Error(), "no such file or directory") {
+			return fmt.Errorf("<%s> Invalid dictionaries folder: <%s>", componentId, dictsDir)
+		}
+		return err
+	} else if !fi.IsDir() { // If config dir defined, needs to exist
+		return fmt.Errorf("<%s> Path: <%s> is not a directory", componentId, dictsDir)
+	}
+	files, err := ioutil.ReadDir(dictsDir)
+	if err != nil {
+		return err
+	}
+	for _, fi := range files {
+		if fi.IsDir() {
+			continue
+		}
+		dictPath := filepath.Join(dictsDir, fi.Name())
+		utils.Logger.Info(fmt.Sprintf("<%s> Loading dictionary out of file %s", componentId, dictPath))
+		fd, err := os.Open(dictPath)
+		if err != nil {
+			return err
+		}
+		err = dict.ParseFromReader(fd)
+		fd.Close()
+		if err != nil {
+			return fmt.Errorf("<%s> Dictionary: %s, error: %s", componentId, dictPath, err.Error())
+		}
+	}
+	return nil
+}
+
+// One attribute within RADIUS packet, Vendor-Specific ones are stored disassembled
+type RadiusAttribute struct {
+	Type       uint8
+	VendorID   uint32 // Vendor-Id for Vendor-Specific attributes, 0 otherwise
+	VendorType uint8
+	RawValue   []byte
+}
+
+// RADIUS packet, the Authenticator of replies holds the one of the request
+type RadiusPacket struct {
+	Code          uint8
+	Identifier    uint8
+	Authenticator [16]byte
+	Attributes    []*RadiusAttribute
+	dict          *RadiusDictionary
+	secret        string
+}
+
+func NewRadiusPacket(code, identifier uint8, dict *RadiusDictionary, secret string) *RadiusPacket {
+	return &RadiusPacket{Code: code, Identifier: identifier, dict: dict, secret: secret}
+}
+
+// DecodeRadiusPacket parses the wire format of a RADIUS packet
+func DecodeRadiusPacket(buf []byte, dict *RadiusDictionary, secret string) (*RadiusPacket, error) {
+	if len(buf) < radiusHeaderLen {
+		return nil, errors.New("RADIUS packet too short")
+	}
+	pktLen := int(binary.BigEndian.Uint16(buf[2:4]))
+	if pktLen < radiusHeaderLen || pktLen > len(buf) || pktLen > radiusMaxPacketLen {
+		return nil, fmt.Errorf("invalid RADIUS packet length: %d", pktLen)
+	}
+	pkt := NewRadiusPacket(buf[0], buf[1], dict, secret)
+	copy(pkt.Authenticator[:], buf[4:radiusHeaderLen])
+	attrsBuf := buf[radiusHeaderLen:pktLen]
+	for len(attrsBuf) != 0 {
+		if len(attrsBuf) < 2 || attrsBuf[1] < 2 || int(attrsBuf[1]) > len(attrsBuf) {
+			return nil, errors.New("malformed RADIUS attribute")
+		}
+		attrType, attrVal := attrsBuf[0], attrsBuf[2:attrsBuf[1]]
+		attrsBuf = attrsBuf[attrsBuf[1]:]
+		if attrType != radiusVendorSpecific || len(attrVal) < 6 {
+			pkt.Attributes = append(pkt.Attributes, &RadiusAttribute{Type: attrType, RawValue: attrVal})
+			continue
+		}
+		vendorID := binary.BigEndian.Uint32(attrVal[:4])
+		for vsaBuf := attrVal[4:]; len(vsaBuf) != 0; { // One Vendor-Specific can carry more vendor attributes
+			if len(vsaBuf) < 2 || vsaBuf[1] < 2 || int(vsaBuf[1]) > len(vsaBuf) {
+				return nil, errors.New("malformed RADIUS Vendor-Specific attribute")
+			}
+			pkt.Attributes = append(pkt.Attributes, &RadiusAttribute{Type: attrType, VendorID: vendorID, VendorType: vsaBuf[0], RawValue: vsaBuf[2:vsaBuf[1]]})
+			vsaBuf = vsaBuf[vsaBuf[1]:]
+		}
+	}
+	return pkt, nil
+}
+
+// Encode returns the wire format of the packet, computing the authenticator out of the shared secret.
+// Access-Request authenticator is expected to be populated by the sender, the one of Accounting-Request is saved in the packet.
+// Access-Request, Access-Accept and Access-Reject are signed with Message-Authenticator (RFC 3579).
+func (self *RadiusPacket) Encode() ([]byte, error) {
+	var attrsBuf bytes.Buffer
+	for _, attr := range self.Attributes {
+		if attr.VendorID == 0 && attr.Type == radiusMessageAuth {
+			continue // Computed below
+		}
+		if attr.VendorID == 0 {
+			if len(attr.RawValue) > 253 {
+				return nil, fmt.Errorf("RADIUS attribute %d value too long", attr.Type)
+			}
+			attrsBuf.Write([]byte{attr.Type, uint8(len(attr.RawValue) + 2)})
+		} else {
+			if len(attr.RawValue) > 247 {
+				return nil, fmt.Errorf("RADIUS vendor attribute %d value too long", attr.VendorType)
+			}
+			attrsBuf.Write([]byte{radiusVendorSpecific, uint8(len(attr.RawValue) + 8)})
+			binary.Write(&attrsBuf, binary.BigEndian, attr.VendorID)
+			attrsBuf.Write([]byte{attr.VendorType, uint8(len(attr.RawValue) + 2)})
+		}
+		attrsBuf.Write(attr.RawValue)
+	}
+	msgAuthIdx := -1 // Index of Message-Authenticator value within buf
+	if radiusSignsMessage(self.Code) {
+		msgAuthIdx = radiusHeaderLen + attrsBuf.Len() + 2
+		attrsBuf.Write([]byte{radiusMessageAuth, 18})
+		attrsBuf.Write(make([]byte, 16))
+	}
+	pktLen := radiusHeaderLen + attrsBuf.Len()
+	if pktLen > radiusMaxPacketLen {
+		return nil, errors.New("RADIUS packet too long")
+	}
+	buf := make([]byte, pktLen)
+	buf[0], buf[1] = self.Code, self.Identifier
+	binary.BigEndian.PutUint16(buf[2:4], uint16(pktLen))
+	copy(buf[radiusHeaderLen:], attrsBuf.Bytes())
+	switch self.Code {
+	case RadiusAccessRequest:
+		copy(buf[4:radiusHeaderLen], self.Authenticator[:])
+		copy(buf[msgAuthIdx:], radiusMessageAuthenticator(buf, self.secret))
+	case RadiusAccountingRequest: // Authenticator computed over zeroed one
+		auth := radiusAuthenticator(buf, self.secret)
+		copy(buf[4:radiusHeaderLen], auth[:])
+		self.Authenticator = auth
+	default: // Replies, computed over the request authenticator
+		copy(buf[4:radiusHeaderLen], self.Authenticator[:])
+		if msgAuthIdx != -1 { // Signed before the authenticator since it is covered by it
+			copy(buf[msgAuthIdx:], radiusMessageAuthenticator(buf, self.secret))
+		}
+		auth := radiusAuthenticator(buf, self.secret)
+		copy(buf[4:radiusHeaderLen], auth[:])
+	}
+	return buf, nil
+}
+
+// radiusSignsMessage returns true for the packet codes carrying Message-Authenticator
+func radiusSignsMessage(code uint8) bool {
+	return code == RadiusAccessRequest || code == RadiusAccessAccept || code == RadiusAccessReject
+}
+
+// MD5 over the packet and shared secret, as defined by RFC 2865 and RFC 2866
+func radiusAuthenticator(buf []byte, secret string) (auth [16]byte) {
+	hash := md5.New()
+	hash.Write(buf)
+	hash.Write([]byte(secret))
+	copy(auth[:], hash.Sum(nil))
+	return
+}
+
+// HMAC-MD5 over the packet keyed with the shared secret, as defined by RFC 3579
+func radiusMessageAuthenticator(buf []byte, secret string) []byte {
+	mac := hmac.New(md5.New, []byte(secret))
+	mac.Write(buf)
+	return mac.Sum(nil)
+}
+
+// radiusMessageAuthIndex returns the index of Message-Authenticator value in buf, -1 if not present
+func radiusMessageAuthIndex(buf []byte) int {
+	pktLen := int(binary.BigEndian.Uint16(buf[2:4]))
+	if pktLen > len(buf) {
+		return -1
+	}
+	for idx := radiusHeaderLen; idx+2 <= pktLen && buf[idx+1] >= 2; idx += int(buf[idx+1]) {
+		if buf[idx] == radiusMessageAuth {
+			if buf[idx+1] != 18 || idx+18 > pktLen {
+				return -1
+			}
+			return idx + 2
+		}
+	}
+	return -1
+}
+
+// IsAuthentic checks the authenticator in buf (wire format of the packet) against the shared secret.
+// reqAuthenticator is used when checking replies. Access-Request is only accepted with a valid Message-Authenticator,
+// on Access-Accept and Access-Reject it is checked when present.
+func (self *RadiusPacket) IsAuthentic(buf []byte, reqAuthenticator [16]byte) bool {
+	if len(buf) < radiusHeaderLen {
+		return false
+	}
+	chkBuf := make([]byte, len(buf))
+	copy(chkBuf, buf)
+	msgAuthIdx := -1
+	if radiusSignsMessage(self.Code) {
+		msgAuthIdx = radiusMessageAuthIndex(buf)
+	}
+	switch self.Code {
+	case RadiusAccessRequest: // Nothing to check without Message-Authenticator
+		if msgAuthIdx == -1 {
+			return false
+		}
+		copy(chkBuf[msgAuthIdx:msgAuthIdx+16], make([]byte, 16))
+		return hmac.Equal(radiusMessageAuthenticator(chkBuf, self.secret), buf[msgAuthIdx:msgAuthIdx+16])
+	case RadiusAccountingRequest:
+		copy(chkBuf[4:radiusHeaderLen], make([]byte, 16))
+	default:
+		copy(chkBuf[4:radiusHeaderLen], reqAuthenticator[:])
+	}
+	auth := radiusAuthenticator(chkBuf, self.secret)
+	if !bytes.Equal(auth[:], buf[4:radiusHeaderLen]) {
+		return false
+	}
+	if msgAuthIdx != -1 {
+		copy(chkBuf[msgAuthIdx:msgAuthIdx+16], make([]byte, 16))
+		return hmac.Equal(radiusMessageAuthenticator(chkBuf, self.secret), buf[msgAuthIdx:msgAuthIdx+16])
+	}
+	return true
+}
+
+// Reply builds an empty reply with the given code, ready for encoding
+func (self *RadiusPacket) Reply(code uint8) *RadiusPacket {
+	rply := NewRadiusPacket(code, self.Identifier, self.dict, self.secret)
+	rply.Authenticator = self.Authenticator
+	return rply
+}
+
+// AttributeValues returns the values of the attributes with name, converted to string based on their dictionary type
+func (self *RadiusPacket) AttributeValues(name string) (vals []string) {
+	dictAttr := self.dict.AttributeWithName(name)
+	if dictAttr == nil {
+		return
+	}
+	for _, attr := range self.Attributes {
+		if attr.code() == dictAttr.Code && attr.VendorID == dictAttr.VendorID {
+			vals = append(vals, radiusValueAsString(dictAttr, attr.RawValue))
+		}
+	}
+	return
+}
+
+// SetAttribute adds the attribute with name to the packet, on appnd false replacing the existing ones
+func (self *RadiusPacket) SetAttribute(name, valStr string, appnd bool, timezone string) error {
+	dictAttr := self.dict.AttributeWithName(name)
+	if dictAttr == nil {
+		return fmt.Errorf("unknown RADIUS attribute: %s", name)
+	}
+	rawVal, err := radiusValueFromString(dictAttr, valStr, timezone)
+	if err != nil {
+		return err
+	}
+	if !appnd {
+		attrs := make([]*RadiusAttribute, 0, len(self.Attributes))
+		for _, attr := range self.Attributes {
+			if attr.code() != dictAttr.Code || attr.VendorID != dictAttr.VendorID {
+				attrs = append(attrs, attr)
+			}
+		}
+		self.Attributes = attrs
+	}
+	attr := &RadiusAttribute{Type: dictAttr.Code, RawValue: rawVal}
+	if dictAttr.VendorID != 0 {
+		attr.Type, attr.VendorID, attr.VendorType = radiusVendorSpecific, dictAttr.VendorID, dictAttr.Code
+	}
+	self.Attributes = append(self.Attributes, attr)
+	return nil
+}
+
+// String returns a human readable representation of the packet, used in logs
+func (self *RadiusPacket) String() string {
+	attrStrs := make([]string, len(self.Attributes))
+	for i, attr := range self.Attributes {
+		if dictAttr := self.dict.AttributeWithCode(attr.VendorID, attr.code()); dictAttr != nil {
+			attrStrs[i] = dictAttr.Name + "=" + radiusValueAsString(dictAttr, attr.RawValue)
+		} else {
+			attrStrs[i] = fmt.Sprintf("%d:%d=0x%s", attr.VendorID, attr.code(), hex.EncodeToString(attr.RawValue))
+		}
+	}
+	return fmt.Sprintf("Code: %d, Identifier: %d, Attributes: [%s]", self.Code, self.Identifier, strings.Join(attrStrs, ", "))
+}
+
+// Code of the attribute as defined in dictionary (vendor type for Vendor-Specific ones)
+func (self *RadiusAttribute) code() uint8 {
+	if self.VendorID != 0 {
+		return self.VendorType
+	}
+	return self.Type
+}
+
+func radiusValueAsString(dictAttr *RadiusDictAttribute, rawVal []byte) string {
+	switch dictAttr.Type {
+	case RadiusTypeString:
+		return string(rawVal)
+	case RadiusTypeInteger:
+		if len(rawVal) == 4 {
+			return dictAttr.ValueName(binary.BigEndian.Uint32(rawVal))
+		}
+	case RadiusTypeDate:
+		if len(rawVal) == 4 {
+			return strconv.FormatUint(uint64(binary.BigEndian.Uint32(rawVal)), 10)
+		}
+	case RadiusTypeIPAddr:
+		if len(rawVal) == 4 {
+			return net.IP(rawVal).String()
+		}
+	}
+	return "0x" + hex.EncodeToString(rawVal)
+}
+
+func radiusValueFromString(dictAttr *RadiusDictAttribute, valStr, timezone string) ([]byte, error) {
+	switch dictAttr.Type {
+	case RadiusTypeString:
+		return []byte(valStr), nil
+	case RadiusTypeInteger:
+		val, hasIt := dictAttr.valueIDs[valStr]
+		if !hasIt {
+			fltVal, err := strconv.ParseFloat(valStr, 64) // Accept decimals, eg: CGRMaxUsage
+			if err != nil || fltVal < 0 || fltVal > float64(^uint32(0)) {
+				return nil, fmt.Errorf("invalid integer value: %s for RADIUS attribute: %s", valStr, dictAttr.Name)
+			}
+			val = uint32(fltVal)
+		}
+		rawVal := make([]byte, 4)
+		binary.BigEndian.PutUint32(rawVal, val)
+		return rawVal, nil
+	case RadiusTypeDate:
+		tm, err := utils.ParseTimeDetectLayout(valStr, timezone)
+		if err != nil {
+			return nil, err
+		}
+		rawVal := make([]byte, 4)
+		binary.BigEndian.PutUint32(rawVal, uint32(tm.Unix()))
+		return rawVal, nil
+	case RadiusTypeIPAddr:
+		ip := net.ParseIP(valStr).To4()
+		if ip == nil {
+			return nil, fmt.Errorf("invalid IPv4 value: %s for RADIUS attribute: %s", valStr, dictAttr.Name)
+		}
+		return []byte(ip), nil
+	}
+	if strings.HasPrefix(valStr, "0x") {
+		return hex.DecodeString(valStr[2:])
+	}
+	return []byte(valStr), nil
+}
+
+// radReqType returns the processor request type, empty for requests not to be sent to SMG (eg: Accounting-On)
+func radReqType(pkt *RadiusPacket) (string, error) {
+	switch pkt.Code {
+	case RadiusAccessRequest:
+		return MetaRadAuth, nil
+	case RadiusAccountingRequest:
+		dictAttr := pkt.dict.AttributeWithName("Acct-Status-Type")
+		for _, attr := range pkt.Attributes {
+			if attr.VendorID != 0 || attr.Type != dictAttr.Code || len(attr.RawValue) != 4 {
+				continue
+			}
+			switch binary.BigEndian.Uint32(attr.RawValue) {
+			case RadiusAcctStart:
+				return MetaRadAcctStart, nil
+			case RadiusAcctInterimUpdate:
+				return MetaRadAcctUpdate, nil
+			case RadiusAcctStop:
+				return MetaRadAcctStop, nil
+			}
+			return "", nil
+		}
+		return "", errors.New("missing Acct-Status-Type")
+	}
+	return "", fmt.Errorf("unsupported RADIUS packet code: %d", pkt.Code)
+}
+
+func radPassesFieldFilter(pkt *RadiusPacket, fieldFilter *utils.RSRField, processorVars map[string]string) bool {
+	if fieldFilter == nil {
+		return true
+	}
+	if val, hasIt := processorVars[fieldFilter.Id]; hasIt { // ProcessorVars have priority
+		return fieldFilter.FilterPasses(val)
+	}
+	vals := pkt.AttributeValues(fieldFilter.Id)
+	if len(vals) == 0 { // No attribute found in request, treat it same as empty
+		return fieldFilter.FilterPasses("")
+	}
+	for _, val := range vals {
+		if fieldFilter.FilterPasses(val) {
+			return true
+		}
+	}
+	return false
+}
+
+func radComposedFieldValue(pkt *RadiusPacket, outTpl utils.RSRFields, processorVars map[string]string) string {
+	var outVal string
+	for _, rsrTpl := range outTpl {
+		if rsrTpl.IsStatic() {
+			outVal += rsrTpl.ParseValue("")
+			continue
+		}
+		if val, hasIt := processorVars[rsrTpl.Id]; hasIt { // ProcessorVars have priority
+			outVal += rsrTpl.ParseValue(val)
+			continue
+		}
+		vals := pkt.AttributeValues(rsrTpl.Id)
+		if len(vals) == 0 {
+			utils.Logger.Warning(fmt.Sprintf("<RADIUS> Cannot find attribute for field template with id: %s, ignoring.", rsrTpl.Id))
+			continue
+		}
+		outVal += rsrTpl.ParseValue(vals[0])
+	}
+	return outVal
+}
+
+func radFieldOutVal(pkt *RadiusPacket, cfgFld *config.CfgCdrField, processorVars map[string]string) (string, error) {
+	for _, fldFilter := range cfgFld.FieldFilter {
+		if !radPassesFieldFilter(pkt, fldFilter, processorVars) {
+			return "", ErrFilterNotPassing // Not matching field filters, will have it empty
+		}
+	}
+	var outVal string
+	switch cfgFld.Type {
+	case utils.META_CONSTANT:
+		outVal = cfgFld.Value.Id()
+	case utils.META_COMPOSED:
+		outVal = radComposedFieldValue(pkt, cfgFld.Value, processorVars)
+	default:
+		return "", fmt.Errorf("unsupported field type: %s", cfgFld.Type)
+	}
+	fmtValOut, err := utils.FmtFieldWidth(outVal, cfgFld.Width, cfgFld.Strip, cfgFld.Padding, cfgFld.Mandatory)
+	if err != nil {
+		utils.Logger.Warning(fmt.Sprintf("<RADIUS> Error when processing field template with tag: %s, error: %s", cfgFld.Tag, err.Error()))
+		return "", err
+	}
+	return fmtValOut, nil
+}
+
+// radReqAsSMGenericEvent maps the request attributes into SMGenericEvent based on field templates
+func radReqAsSMGenericEvent(pkt *RadiusPacket, cfgFlds []*config.CfgCdrField, processorVars map[string]string) (sessionmanager.SMGenericEvent, error) {
+	outMap := make(map[string]string) // work with it so we can append values to keys
+	outMap[utils.EVENT_NAME] = RADIUS_REQUEST
+	for _, cfgFld := range cfgFlds {
+		fmtOut, err := radFieldOutVal(pkt, cfgFld, processorVars)
+		if err != nil {
+			if err == ErrFilterNotPassing {
+				continue // Do nothing in case of Filter not passing
+			}
+			return nil, err
+		}
+		if _, hasKey := outMap[cfgFld.FieldId]; hasKey && cfgFld.Append {
+			outMap[cfgFld.FieldId] += fmtOut
+		} else {
+			outMap[cfgFld.FieldId] = fmtOut
+		}
+	}
+	return sessionmanager.SMGenericEvent(utils.ConvertMapValStrIf(outMap)), nil
+}
+
+// radReplySetAttributes populates the reply with attributes out of field templates
+func radReplySetAttributes(rply, req *RadiusPacket, cfgFlds []*config.CfgCdrField, processorVars map[string]string, timezone string) error {
+	for _, cfgFld := range cfgFlds {
+		fmtOut, err := radFieldOutVal(req, cfgFld, processorVars)
+		if err != nil {
+			if err == ErrFilterNotPassing {
+				continue
+			}
+			return err
+		}
+		if err := rply.SetAttribute(cfgFld.FieldId, fmtOut, cfgFld.Append, timezone); err != nil {
+			return err
+		}
+	}
+	return nil
+}
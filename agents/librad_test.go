@@ -0,0 +1,234 @@
+/*
+Real-time Charging System for Telecom & ISP environments
+Copyright (C) ITsysCOM GmbH
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>
+*/
+
+package agents
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/cgrates/cgrates/config"
+	"github.com/cgrates/cgrates/utils"
+)
+
+var radTestVendorDict = `
+VENDOR		Cisco		9
+
+BEGIN-VENDOR	Cisco
+ATTRIBUTE	Cisco-AVPair		1	string
+ATTRIBUTE	Cisco-NAS-Port		2	string
+END-VENDOR	Cisco
+
+ATTRIBUTE	Acct-Interim-Interval	85	integer # RFC 2869
+`
+
+func TestRadiusDictionaryParse(t *testing.T) {
+	dict := NewRadiusDictionaryWithDefaults()
+	if dictAttr := dict.AttributeWithName("Acct-Status-Type"); dictAttr == nil || dictAttr.Code != 40 || dictAttr.Type != RadiusTypeInteger {
+		t.Errorf("Received: %+v", dictAttr)
+	} else if dictAttr.ValueName(3) != "Interim-Update" || dictAttr.ValueName(100) != "100" {
+		t.Errorf("Unexpected values: %+v", dictAttr.values)
+	}
+	if err := dict.ParseFromReader(strings.NewReader(radTestVendorDict)); err != nil {
+		t.Fatal(err)
+	}
+	if dictAttr := dict.AttributeWithName("Cisco-AVPair"); dictAttr == nil || dictAttr.VendorID != 9 || dictAttr.Code != 1 {
+		t.Errorf("Received: %+v", dictAttr)
+	}
+	if dictAttr := dict.AttributeWithCode(9, 2); dictAttr == nil || dictAttr.Name != "Cisco-NAS-Port" {
+		t.Errorf("Received: %+v", dictAttr)
+	}
+	if dictAttr := dict.AttributeWithCode(0, 85); dictAttr == nil || dictAttr.Name != "Acct-Interim-Interval" {
+		t.Errorf("Received: %+v", dictAttr)
+	}
+	if err := dict.ParseFromReader(strings.NewReader("VALUE	Unknown-Attribute	Value1	1")); err == nil {
+		t.Error("Should not accept values for unknown attributes")
+	}
+	if err := dict.ParseFromReader(strings.NewReader("BEGIN-VENDOR	Unknown")); err == nil {
+		t.Error("Should not accept unknown vendors")
+	}
+}
+
+func TestRadiusPacketEncodeDecode(t *testing.T) {
+	dict := NewRadiusDictionaryWithDefaults()
+	if err := dict.ParseFromReader(strings.NewReader(radTestVendorDict)); err != nil {
+		t.Fatal(err)
+	}
+	req := NewRadiusPacket(RadiusAccountingRequest, 10, dict, "CGRateS.org")
+	for _, attr := range [][]string{
+		[]string{"User-Name", "1001"},
+		[]string{"Acct-Status-Type", "Start"},
+		[]string{"Acct-Session-Id", "e4921177ab0e3586c37f6a185864b71a@0:0:0:0:0:0:0:0"},
+		[]string{"NAS-IP-Address", "192.168.1.1"},
+		[]string{"Cisco-AVPair", "h323-conf-id=1234"},
+		[]string{"Cisco-AVPair", "h323-call-origin=originate"},
+	} {
+		if err := req.SetAttribute(attr[0], attr[1], true, ""); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := req.SetAttribute("Event-Timestamp", "1476802709", true, ""); err == nil {
+		t.Error("Event-Timestamp should not be in default dictionary")
+	}
+	if err := req.SetAttribute("Acct-Status-Type", "Unknown", false, ""); err == nil {
+		t.Error("Should not accept unknown values")
+	}
+	buf, err := req.Encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	rcvReq, err := DecodeRadiusPacket(buf, dict, "CGRateS.org")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !rcvReq.IsAuthentic(buf, rcvReq.Authenticator) {
+		t.Error("Request should be authentic")
+	}
+	if rcvReq.Code != RadiusAccountingRequest || rcvReq.Identifier != 10 || rcvReq.Authenticator != req.Authenticator {
+		t.Errorf("Received: %s", rcvReq)
+	}
+	if !reflect.DeepEqual(rcvReq.Attributes, req.Attributes) {
+		t.Errorf("Expecting: %s, received: %s", req, rcvReq)
+	}
+	if vals := rcvReq.AttributeValues("Acct-Status-Type"); !reflect.DeepEqual(vals, []string{"Start"}) {
+		t.Error("Received: ", vals)
+	}
+	if vals := rcvReq.AttributeValues("NAS-IP-Address"); !reflect.DeepEqual(vals, []string{"192.168.1.1"}) {
+		t.Error("Received: ", vals)
+	}
+	if vals := rcvReq.AttributeValues("Cisco-AVPair"); !reflect.DeepEqual(vals, []string{"h323-conf-id=1234", "h323-call-origin=originate"}) {
+		t.Error("Received: ", vals)
+	}
+	if reqType, err := radReqType(rcvReq); err != nil || reqType != MetaRadAcctStart {
+		t.Error(reqType, err)
+	}
+	if wrongSecretReq, err := DecodeRadiusPacket(buf, dict, "wrong"); err != nil {
+		t.Error(err)
+	} else if wrongSecretReq.IsAuthentic(buf, wrongSecretReq.Authenticator) {
+		t.Error("Request should not be authentic")
+	}
+	rply := rcvReq.Reply(RadiusAccountingResponse)
+	rplyBuf, err := rply.Encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rcvRply, err := DecodeRadiusPacket(rplyBuf, dict, "CGRateS.org"); err != nil {
+		t.Error(err)
+	} else if !rcvRply.IsAuthentic(rplyBuf, req.Authenticator) {
+		t.Error("Reply should be authentic")
+	}
+	authReq := NewRadiusPacket(RadiusAccessRequest, 11, dict, "CGRateS.org")
+	authReq.Authenticator = [16]byte{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16}
+	authReq.SetAttribute("User-Name", "1001", false, "")
+	authBuf, err := authReq.Encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	rcvAuthReq, err := DecodeRadiusPacket(authBuf, dict, "CGRateS.org")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !rcvAuthReq.IsAuthentic(authBuf, rcvAuthReq.Authenticator) {
+		t.Error("Access-Request should be authentic")
+	} else if vals := rcvAuthReq.AttributeValues("Message-Authenticator"); len(vals) != 1 {
+		t.Errorf("Received: %s", rcvAuthReq)
+	}
+	if wrongSecretReq, err := DecodeRadiusPacket(authBuf, dict, "wrong"); err != nil {
+		t.Error(err)
+	} else if wrongSecretReq.IsAuthentic(authBuf, wrongSecretReq.Authenticator) {
+		t.Error("Access-Request should not be authentic")
+	}
+	unsignedBuf := append([]byte{RadiusAccessRequest, 12, 0, 26}, authReq.Authenticator[:]...)
+	unsignedBuf = append(unsignedBuf, 1, 6, '1', '0', '0', '1') // User-Name only
+	if unsignedReq, err := DecodeRadiusPacket(unsignedBuf, dict, "CGRateS.org"); err != nil {
+		t.Error(err)
+	} else if unsignedReq.IsAuthentic(unsignedBuf, unsignedReq.Authenticator) {
+		t.Error("Access-Request without Message-Authenticator should not be authentic")
+	}
+	authRply := rcvAuthReq.Reply(RadiusAccessAccept)
+	authRplyBuf, err := authRply.Encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rcvRply, err := DecodeRadiusPacket(authRplyBuf, dict, "CGRateS.org"); err != nil {
+		t.Error(err)
+	} else if vals := rcvRply.AttributeValues("Message-Authenticator"); len(vals) != 1 {
+		t.Errorf("Reply not signed: %s", rcvRply)
+	} else if !rcvRply.IsAuthentic(authRplyBuf, authReq.Authenticator) {
+		t.Error("Reply should be authentic")
+	}
+	authRplyBuf[len(authRplyBuf)-1]++ // Tamper with Message-Authenticator, keeping the rest
+	copy(authRplyBuf[4:radiusHeaderLen], authReq.Authenticator[:])
+	rplyAuth := radiusAuthenticator(authRplyBuf, "CGRateS.org")
+	copy(authRplyBuf[4:radiusHeaderLen], rplyAuth[:])
+	if rcvRply, err := DecodeRadiusPacket(authRplyBuf, dict, "CGRateS.org"); err != nil {
+		t.Error(err)
+	} else if rcvRply.IsAuthentic(authRplyBuf, authReq.Authenticator) {
+		t.Error("Reply with wrong Message-Authenticator should not be authentic")
+	}
+	if _, err := DecodeRadiusPacket(buf[:radiusHeaderLen-1], dict, "CGRateS.org"); err == nil {
+		t.Error("Should not decode short packets")
+	}
+	buf[radiusHeaderLen+1] = 100 // Attribute length over the packet one
+	if _, err := DecodeRadiusPacket(buf, dict, "CGRateS.org"); err == nil {
+		t.Error("Should not decode malformed attributes")
+	}
+}
+
+func TestRadReqAsSMGenericEvent(t *testing.T) {
+	dict := NewRadiusDictionaryWithDefaults()
+	req := NewRadiusPacket(RadiusAccountingRequest, 1, dict, "CGRateS.org")
+	req.SetAttribute("User-Name", "1001", false, "")
+	req.SetAttribute("Called-Station-Id", "+4986517174963", false, "")
+	req.SetAttribute("Acct-Session-Id", "session1", false, "")
+	req.SetAttribute("Acct-Status-Type", "Stop", false, "")
+	req.SetAttribute("Acct-Session-Time", "120", false, "")
+	processorVars := map[string]string{MetaRadReqType: MetaRadAcctStop}
+	cfgFlds := []*config.CfgCdrField{
+		&config.CfgCdrField{Tag: "TOR", FieldId: utils.TOR, Type: utils.META_COMPOSED, Value: utils.ParseRSRFieldsMustCompile("^*voice", utils.INFIELD_SEP)},
+		&config.CfgCdrField{Tag: "OriginID", FieldId: utils.ACCID, Type: utils.META_COMPOSED, Value: utils.ParseRSRFieldsMustCompile("Acct-Session-Id", utils.INFIELD_SEP)},
+		&config.CfgCdrField{Tag: "Account", FieldId: utils.ACCOUNT, Type: utils.META_COMPOSED, Value: utils.ParseRSRFieldsMustCompile("User-Name", utils.INFIELD_SEP)},
+		&config.CfgCdrField{Tag: "Destination", FieldId: utils.DESTINATION, Type: utils.META_COMPOSED,
+			Value: utils.ParseRSRFieldsMustCompile(`~Called-Station-Id:s/^\+49(\d+)/0$1/`, utils.INFIELD_SEP)},
+		&config.CfgCdrField{Tag: "Usage", FieldId: utils.USAGE, Type: utils.META_COMPOSED, Value: utils.ParseRSRFieldsMustCompile("Acct-Session-Time", utils.INFIELD_SEP)},
+		&config.CfgCdrField{Tag: "StartUsage", FieldFilter: utils.ParseRSRFieldsMustCompile("*radReqType(*radAcctStart)", utils.INFIELD_SEP),
+			FieldId: utils.USAGE, Type: utils.META_CONSTANT, Value: utils.ParseRSRFieldsMustCompile("^0", utils.INFIELD_SEP)},
+	}
+	eSMGEv := map[string]interface{}{utils.EVENT_NAME: RADIUS_REQUEST, utils.TOR: utils.VOICE, utils.ACCID: "session1",
+		utils.ACCOUNT: "1001", utils.DESTINATION: "086517174963", utils.USAGE: "120"}
+	if smgEv, err := radReqAsSMGenericEvent(req, cfgFlds, processorVars); err != nil {
+		t.Error(err)
+	} else if !reflect.DeepEqual(eSMGEv, map[string]interface{}(smgEv)) {
+		t.Errorf("Expecting: %+v, received: %+v", eSMGEv, smgEv)
+	}
+	rply := req.Reply(RadiusAccessAccept)
+	processorVars[CGRMaxUsage] = "300"
+	rplyFlds := []*config.CfgCdrField{
+		&config.CfgCdrField{Tag: "SessionTimeout", FieldId: "Session-Timeout", Type: utils.META_COMPOSED, Value: utils.ParseRSRFieldsMustCompile("CGRMaxUsage", utils.INFIELD_SEP)},
+		&config.CfgCdrField{Tag: "ReplyMessage", FieldFilter: utils.ParseRSRFieldsMustCompile("CGRError(!^$)", utils.INFIELD_SEP),
+			FieldId: "Reply-Message", Type: utils.META_COMPOSED, Value: utils.ParseRSRFieldsMustCompile("CGRError", utils.INFIELD_SEP)},
+	}
+	if err := radReplySetAttributes(rply, req, rplyFlds, processorVars, ""); err != nil {
+		t.Error(err)
+	} else if vals := rply.AttributeValues("Session-Timeout"); !reflect.DeepEqual(vals, []string{"300"}) {
+		t.Errorf("Received: %s", rply)
+	} else if vals := rply.AttributeValues("Reply-Message"); len(vals) != 0 {
+		t.Errorf("Received: %s", rply)
+	}
+}
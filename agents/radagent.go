@@ -0,0 +1,287 @@
+/*
+Real-time Charging System for Telecom & ISP environments
+Copyright (C) ITsysCOM GmbH
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>
+*/
+
+package agents
+
+import (
+	"encoding/hex"
+	"fmt"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/cgrates/cgrates/config"
+	"github.com/cgrates/cgrates/sessionmanager"
+	"github.com/cgrates/cgrates/utils"
+	"github.com/cgrates/rpcclient"
+)
+
+func NewRadiusAgent(cgrCfg *config.CGRConfig, smg rpcclient.RpcClientConnection) (*RadiusAgent, error) {
+	ra := &RadiusAgent{cgrCfg: cgrCfg, smg: smg, dict: NewRadiusDictionaryWithDefaults(),
+		rplyCache: newRadiusReplyCache(radiusDuplicateTTL)}
+	dictsDir := cgrCfg.RadiusAgentCfg().DictionariesDir
+	if len(dictsDir) != 0 {
+		if err := loadRadiusDictionaries(ra.dict, dictsDir, "RadiusAgent"); err != nil {
+			return nil, err
+		}
+	}
+	return ra, nil
+}
+
+type RadiusAgent struct {
+	cgrCfg    *config.CGRConfig
+	smg       rpcclient.RpcClientConnection // Connection towards CGR-SMG component
+	dict      *RadiusDictionary
+	rplyCache *radiusReplyCache // Replies to recent requests, answering retransmissions
+}
+
+// How long a request is remembered for duplicate detection, covering the client retransmissions
+var radiusDuplicateTTL = 30 * time.Second
+
+func newRadiusReplyCache(ttl time.Duration) *radiusReplyCache {
+	return &radiusReplyCache{ttl: ttl, replies: make(map[string]*radiusCachedReply), lastCleanup: time.Now()}
+}
+
+// Replies indexed on client address, request Identifier and Authenticator (RFC 5080 duplicate detection)
+type radiusReplyCache struct {
+	sync.Mutex
+	ttl         time.Duration
+	replies     map[string]*radiusCachedReply
+	lastCleanup time.Time
+}
+
+type radiusCachedReply struct {
+	expiresAt time.Time
+	rplyBuf   []byte // nil while the original request is processed
+}
+
+func radiusDuplicateKey(clntAddr net.Addr, req *RadiusPacket) string {
+	return clntAddr.String() + utils.CONCATENATED_KEY_SEP + strconv.Itoa(int(req.Identifier)) +
+		utils.CONCATENATED_KEY_SEP + hex.EncodeToString(req.Authenticator[:])
+}
+
+// startRequest registers the request under key, returning the cached reply with isDuplicate true on retransmissions
+func (self *radiusReplyCache) startRequest(key string) (rplyBuf []byte, isDuplicate bool) {
+	self.Lock()
+	defer self.Unlock()
+	now := time.Now()
+	if now.Sub(self.lastCleanup) >= self.ttl { // Avoid walking the cache on every request
+		for k, cached := range self.replies {
+			if cached.expiresAt.Before(now) {
+				delete(self.replies, k)
+			}
+		}
+		self.lastCleanup = now
+	}
+	if cached, hasIt := self.replies[key]; hasIt && cached.expiresAt.After(now) {
+		return cached.rplyBuf, true
+	}
+	self.replies[key] = &radiusCachedReply{expiresAt: now.Add(self.ttl)}
+	return nil, false
+}
+
+// finishRequest saves the reply sent for key, on nil rplyBuf forgets the request so the client retry gets processed
+func (self *radiusReplyCache) finishRequest(key string, rplyBuf []byte) {
+	self.Lock()
+	defer self.Unlock()
+	if rplyBuf == nil {
+		delete(self.replies, key)
+		return
+	}
+	if cached, hasIt := self.replies[key]; hasIt {
+		cached.rplyBuf = rplyBuf
+	}
+}
+
+// processRequest runs the request through the processors, returning the reply to be sent back
+func (self *RadiusAgent) processRequest(req *RadiusPacket) (*RadiusPacket, error) {
+	reqType, err := radReqType(req)
+	if err != nil {
+		return nil, err
+	}
+	var rply *RadiusPacket
+	if req.Code == RadiusAccessRequest {
+		rply = req.Reply(RadiusAccessAccept)
+	} else {
+		rply = req.Reply(RadiusAccountingResponse)
+	}
+	if reqType == "" { // Accounting-On/Off, nothing to charge, just acknowledge
+		return rply, nil
+	}
+	processorVars := map[string]string{MetaRadReqType: reqType} // Shared between processors
+	var processed bool
+	for _, reqProcessor := range self.cgrCfg.RadiusAgentCfg().RequestProcessors {
+		lclProcessed, err := self.processRequestWithProcessor(req, reqProcessor, processorVars, rply)
+		if err != nil {
+			return nil, err
+		}
+		if lclProcessed {
+			processed = true
+			if !reqProcessor.ContinueOnSuccess {
+				break
+			}
+		}
+	}
+	if !processed {
+		return nil, fmt.Errorf("no request processor enabled for %s", reqType)
+	}
+	if req.Code == RadiusAccessRequest && processorVars[CGRError] != "" {
+		rply.Code = RadiusAccessReject
+	}
+	return rply, nil
+}
+
+func (self *RadiusAgent) processRequestWithProcessor(req *RadiusPacket, reqProcessor *config.RARequestProcessor,
+	processorVars map[string]string, rply *RadiusPacket) (bool, error) {
+	for _, fldFilter := range reqProcessor.RequestFilter {
+		if !radPassesFieldFilter(req, fldFilter, processorVars) {
+			return false, nil // Not going with this processor further
+		}
+	}
+	if reqProcessor.DryRun { // DryRun should log the matching processor as well as the received request
+		utils.Logger.Info(fmt.Sprintf("<RadiusAgent> RequestProcessor: %s", reqProcessor.Id))
+		utils.Logger.Info(fmt.Sprintf("<RadiusAgent> Request: %s", req))
+	}
+	if !reqProcessor.AppendReply {
+		rply.Attributes = nil
+	}
+	smgEv, err := radReqAsSMGenericEvent(req, reqProcessor.RequestFields, processorVars)
+	if err != nil {
+		return false, err
+	}
+	if len(reqProcessor.Flags) != 0 {
+		smgEv[utils.CGRFlags] = reqProcessor.Flags.String() // Populate CGRFlags automatically
+	}
+	if reqProcessor.DryRun { // DryRun does not send over network
+		utils.Logger.Info(fmt.Sprintf("<RadiusAgent> SMGenericEvent: %+v", smgEv))
+	} else {
+		reqType := processorVars[MetaRadReqType]
+		maxUsage, err := self.dispatchSMGEvent(reqType, smgEv)
+		if err != nil {
+			if reqType != MetaRadAuth { // Accounting not recorded, client should retry
+				return false, err
+			}
+			utils.Logger.Err(fmt.Sprintf("<RadiusAgent> Processing request: %s, API error: %s", utils.ToJSON(smgEv), err))
+			processorVars[CGRError] = err.Error()
+		} else if reqType != MetaRadAcctStop {
+			setProcessorMaxUsage(processorVars, maxUsage)
+		}
+	}
+	if err := radReplySetAttributes(rply, req, reqProcessor.ReplyFields, processorVars, self.cgrCfg.RadiusAgentCfg().Timezone); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// dispatchSMGEvent calls the SMG API corresponding to the request type, returning the maximum usage allowed
+func (self *RadiusAgent) dispatchSMGEvent(reqType string, smgEv sessionmanager.SMGenericEvent) (maxUsage float64, err error) {
+	switch reqType {
+	case MetaRadAuth:
+		if err = self.smg.Call("SMGenericV1.MaxUsage", smgEv, &maxUsage); err == nil && maxUsage == 0 {
+			err = utils.ErrInsufficientCredit // Nothing to authorize
+		}
+	case MetaRadAcctStart:
+		err = self.smg.Call("SMGenericV1.InitiateSession", smgEv, &maxUsage)
+	case MetaRadAcctUpdate:
+		err = self.smg.Call("SMGenericV1.UpdateSession", smgEv, &maxUsage)
+	case MetaRadAcctStop:
+		var rpl string
+		if err = self.smg.Call("SMGenericV1.TerminateSession", smgEv, &rpl); err != nil {
+			return
+		}
+		if self.cgrCfg.RadiusAgentCfg().CreateCDR {
+			err = self.smg.Call("SMGenericV1.ProcessCDR", smgEv, &rpl)
+		}
+	}
+	return
+}
+
+// handlePacket decodes and authenticates the request, writing back the reply
+func (self *RadiusAgent) handlePacket(pc net.PacketConn, clntAddr net.Addr, buf []byte) {
+	clntIP, _, err := net.SplitHostPort(clntAddr.String())
+	if err != nil {
+		utils.Logger.Err(fmt.Sprintf("<RadiusAgent> Invalid client address: %s, error: %s", clntAddr, err))
+		return
+	}
+	secret, hasIt := self.cgrCfg.RadiusAgentCfg().ClientSecret(clntIP)
+	if !hasIt {
+		utils.Logger.Warning(fmt.Sprintf("<RadiusAgent> No secret defined for client: %s, ignoring request", clntIP))
+		return
+	}
+	req, err := DecodeRadiusPacket(buf, self.dict, secret)
+	if err != nil {
+		utils.Logger.Err(fmt.Sprintf("<RadiusAgent> Decoding request from %s, error: %s", clntAddr, err))
+		return
+	}
+	if !req.IsAuthentic(buf, req.Authenticator) {
+		utils.Logger.Warning(fmt.Sprintf("<RadiusAgent> Request from %s, error: %s", clntAddr, ErrRadiusNotAuthentic))
+		return
+	}
+	dupKey := radiusDuplicateKey(clntAddr, req)
+	if cachedRply, isDuplicate := self.rplyCache.startRequest(dupKey); isDuplicate {
+		if cachedRply == nil { // Original still in processing, its reply will answer the client
+			return
+		}
+		if _, err := pc.WriteTo(cachedRply, clntAddr); err != nil {
+			utils.Logger.Err(fmt.Sprintf("<RadiusAgent> Failed to write reply to %s: %s", clntAddr, err))
+		}
+		return
+	}
+	var rplyBuf []byte
+	defer func() { self.rplyCache.finishRequest(dupKey, rplyBuf) }()
+	rply, err := self.processRequest(req)
+	if err != nil {
+		utils.Logger.Err(fmt.Sprintf("<RadiusAgent> Processing request from %s, error: %s", clntAddr, err))
+		return
+	}
+	if rplyBuf, err = rply.Encode(); err != nil {
+		utils.Logger.Err(fmt.Sprintf("<RadiusAgent> Encoding reply to %s, error: %s", clntAddr, err))
+		return
+	}
+	if _, err := pc.WriteTo(rplyBuf, clntAddr); err != nil {
+		utils.Logger.Err(fmt.Sprintf("<RadiusAgent> Failed to write reply to %s: %s", clntAddr, err))
+	}
+}
+
+// Serve reads requests out of pc, handling each of them in it's own goroutine
+func (self *RadiusAgent) Serve(pc net.PacketConn) error {
+	for {
+		buf := make([]byte, radiusMaxPacketLen)
+		n, clntAddr, err := pc.ReadFrom(buf)
+		if err != nil {
+			return err
+		}
+		go self.handlePacket(pc, clntAddr, buf[:n])
+	}
+}
+
+// ListenAndServe listens for authentication and accounting requests, returning on the first listener error
+func (self *RadiusAgent) ListenAndServe() error {
+	errChan := make(chan error, 2)
+	for _, addr := range []string{self.cgrCfg.RadiusAgentCfg().ListenAuth, self.cgrCfg.RadiusAgentCfg().ListenAcct} {
+		pc, err := net.ListenPacket("udp", addr)
+		if err != nil {
+			return err
+		}
+		go func(pc net.PacketConn) {
+			errChan <- self.Serve(pc)
+		}(pc)
+	}
+	return <-errChan
+}
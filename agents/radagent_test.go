@@ -0,0 +1,182 @@
+/*
+Real-time Charging System for Telecom & ISP environments
+Copyright (C) ITsysCOM GmbH
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>
+*/
+
+package agents
+
+import (
+	"net"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cgrates/cgrates/config"
+	"github.com/cgrates/cgrates/sessionmanager"
+	"github.com/cgrates/cgrates/utils"
+)
+
+// Records the SMG API calls, replying with configured maxUsage
+type radTestSMG struct {
+	sync.Mutex
+	calls    []string
+	maxUsage float64
+}
+
+func (self *radTestSMG) Call(serviceMethod string, args interface{}, reply interface{}) error {
+	self.Lock()
+	defer self.Unlock()
+	self.calls = append(self.calls, serviceMethod)
+	switch rpl := reply.(type) {
+	case *float64:
+		*rpl = self.maxUsage
+	case *string:
+		*rpl = utils.OK
+	}
+	if _, canCast := args.(sessionmanager.SMGenericEvent); !canCast {
+		return utils.ErrServerError
+	}
+	return nil
+}
+
+func (self *radTestSMG) Calls() []string {
+	self.Lock()
+	defer self.Unlock()
+	return append([]string{}, self.calls...)
+}
+
+var radTestCfgJson = `{
+"radius_agent": {
+	"enabled": true,
+	"client_secrets": {"*default": "CGRateS.org"},
+	"dictionaries_dir": "",
+	"request_processors": [
+		{
+			"id": "Auth",
+			"request_filter": "*radReqType(*radAuth)",
+			"request_fields":[
+				{"tag": "OriginID", "field_id": "OriginID", "type": "*composed", "value": "Acct-Session-Id", "mandatory": true},
+				{"tag": "Account", "field_id": "Account", "type": "*composed", "value": "User-Name", "mandatory": true},
+				{"tag": "Destination", "field_id": "Destination", "type": "*composed", "value": "Called-Station-Id", "mandatory": true},
+			],
+			"reply_fields":[
+				{"tag": "SessionTimeout", "field_filter": "CGRError(^$)", "field_id": "Session-Timeout", "type": "*composed", "value": "CGRMaxUsage"},
+				{"tag": "ReplyMessage", "field_filter": "CGRError(!^$)", "field_id": "Reply-Message", "type": "*composed", "value": "CGRError"},
+			],
+		},
+		{
+			"id": "Acct",
+			"request_filter": "*radReqType(!*radAuth)",
+			"request_fields":[
+				{"tag": "OriginID", "field_id": "OriginID", "type": "*composed", "value": "Acct-Session-Id", "mandatory": true},
+				{"tag": "Account", "field_id": "Account", "type": "*composed", "value": "User-Name", "mandatory": true},
+				{"tag": "Usage", "field_id": "Usage", "type": "*composed", "value": "Acct-Session-Time"},
+			],
+		},
+	],
+},
+}`
+
+func TestRadiusAgentProcessRequests(t *testing.T) {
+	cfg, err := config.NewCGRConfigFromJsonStringWithDefaults(radTestCfgJson)
+	if err != nil {
+		t.Fatal(err)
+	}
+	smg := &radTestSMG{maxUsage: 3600}
+	ra, err := NewRadiusAgent(cfg, smg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pc.Close()
+	go ra.Serve(pc)
+	clnt, err := NewRadiusClient(pc.LocalAddr().String(), "CGRateS.org", NewRadiusDictionaryWithDefaults())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer clnt.Close()
+	req := clnt.NewRequest(RadiusAccessRequest)
+	req.SetAttribute("User-Name", "1001", false, "")
+	req.SetAttribute("Called-Station-Id", "1002", false, "")
+	req.SetAttribute("Acct-Session-Id", "session1", false, "")
+	if rply, err := clnt.SendRequest(req, time.Second); err != nil {
+		t.Fatal(err)
+	} else if rply.Code != RadiusAccessAccept {
+		t.Errorf("Received: %s", rply)
+	} else if vals := rply.AttributeValues("Session-Timeout"); !reflect.DeepEqual(vals, []string{"3600"}) {
+		t.Errorf("Received: %s", rply)
+	}
+	if rply, err := clnt.SendRequest(req, time.Second); err != nil { // Retransmission answered out of cache
+		t.Fatal(err)
+	} else if rply.Code != RadiusAccessAccept {
+		t.Errorf("Received: %s", rply)
+	}
+	smg.Lock()
+	smg.maxUsage = 0
+	smg.Unlock()
+	req = clnt.NewRequest(RadiusAccessRequest)
+	req.SetAttribute("User-Name", "1001", false, "")
+	req.SetAttribute("Called-Station-Id", "1002", false, "")
+	req.SetAttribute("Acct-Session-Id", "session1", false, "")
+	if rply, err := clnt.SendRequest(req, time.Second); err != nil {
+		t.Fatal(err)
+	} else if rply.Code != RadiusAccessReject {
+		t.Errorf("Received: %s", rply)
+	} else if vals := rply.AttributeValues("Reply-Message"); !reflect.DeepEqual(vals, []string{utils.ErrInsufficientCredit.Error()}) {
+		t.Errorf("Received: %s", rply)
+	}
+	for _, status := range []string{"Start", "Interim-Update", "Stop", "Accounting-On"} {
+		req := clnt.NewRequest(RadiusAccountingRequest)
+		req.SetAttribute("User-Name", "1001", false, "")
+		req.SetAttribute("Acct-Session-Id", "session1", false, "")
+		req.SetAttribute("Acct-Status-Type", status, false, "")
+		req.SetAttribute("Acct-Session-Time", "120", false, "")
+		for i := 0; i < 2; i++ { // Second one is a retransmission, should not reach SMG
+			if rply, err := clnt.SendRequest(req, time.Second); err != nil {
+				t.Fatalf("Status: %s, error: %s", status, err)
+			} else if rply.Code != RadiusAccountingResponse {
+				t.Errorf("Received: %s", rply)
+			}
+		}
+	}
+	eCalls := []string{"SMGenericV1.MaxUsage", "SMGenericV1.MaxUsage", "SMGenericV1.InitiateSession",
+		"SMGenericV1.UpdateSession", "SMGenericV1.TerminateSession", "SMGenericV1.ProcessCDR"}
+	if calls := smg.Calls(); !reflect.DeepEqual(eCalls, calls) {
+		t.Errorf("Expecting: %+v, received: %+v", eCalls, calls)
+	}
+	wrongClnt, err := NewRadiusClient(pc.LocalAddr().String(), "wrong", NewRadiusDictionaryWithDefaults())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer wrongClnt.Close()
+	req = wrongClnt.NewRequest(RadiusAccountingRequest)
+	req.SetAttribute("Acct-Status-Type", "Start", false, "")
+	if _, err := wrongClnt.SendRequest(req, 100*time.Millisecond); err == nil {
+		t.Error("Requests with wrong secret should not be answered")
+	}
+	req = wrongClnt.NewRequest(RadiusAccessRequest)
+	req.SetAttribute("User-Name", "1001", false, "")
+	if _, err := wrongClnt.SendRequest(req, 100*time.Millisecond); err == nil {
+		t.Error("Access-Request with wrong Message-Authenticator should not be answered")
+	}
+	if calls := smg.Calls(); !reflect.DeepEqual(eCalls, calls) {
+		t.Errorf("Expecting: %+v, received: %+v", eCalls, calls)
+	}
+}
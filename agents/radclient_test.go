@@ -0,0 +1,95 @@
+/*
+Real-time Charging System for Telecom & ISP environments
+Copyright (C) ITsysCOM GmbH
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>
+*/
+
+package agents
+
+import (
+	"crypto/rand"
+	"errors"
+	"net"
+	"sync"
+	"time"
+)
+
+func NewRadiusClient(addr, secret string, dict *RadiusDictionary) (*RadiusClient, error) {
+	conn, err := net.Dial("udp", addr)
+	if err != nil {
+		return nil, err
+	}
+	return &RadiusClient{conn: conn, secret: secret, dict: dict, reqMux: new(sync.Mutex)}, nil
+}
+
+// Simple RADIUS client over UDP, sending one request at a time
+type RadiusClient struct {
+	conn   net.Conn
+	secret string
+	dict   *RadiusDictionary
+	lastID uint8
+	reqMux *sync.Mutex // Only one request on the wire
+}
+
+// NewRequest returns an empty request with the next identifier and, for Access-Request, a random authenticator
+func (self *RadiusClient) NewRequest(code uint8) *RadiusPacket {
+	self.reqMux.Lock()
+	self.lastID++
+	req := NewRadiusPacket(code, self.lastID, self.dict, self.secret)
+	self.reqMux.Unlock()
+	if code == RadiusAccessRequest {
+		rand.Read(req.Authenticator[:])
+	}
+	return req
+}
+
+// SendRequest writes the request and waits for it's authenticated reply
+func (self *RadiusClient) SendRequest(req *RadiusPacket, rplyTimeout time.Duration) (*RadiusPacket, error) {
+	self.reqMux.Lock()
+	defer self.reqMux.Unlock()
+	reqBuf, err := req.Encode()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := self.conn.Write(reqBuf); err != nil {
+		return nil, err
+	}
+	self.conn.SetReadDeadline(time.Now().Add(rplyTimeout))
+	for {
+		buf := make([]byte, radiusMaxPacketLen)
+		n, err := self.conn.Read(buf)
+		if err != nil {
+			return nil, err
+		}
+		rply, err := DecodeRadiusPacket(buf[:n], self.dict, self.secret)
+		if err != nil {
+			return nil, err
+		}
+		if rply.Identifier != req.Identifier { // Late reply to a previous request
+			continue
+		}
+		if !rply.IsAuthentic(buf[:n], req.Authenticator) {
+			return nil, ErrRadiusNotAuthentic
+		}
+		return rply, nil
+	}
+}
+
+func (self *RadiusClient) Close() error {
+	if self.conn == nil {
+		return errors.New("not connected")
+	}
+	return self.conn.Close()
+}
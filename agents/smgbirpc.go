@@ -0,0 +1,77 @@
+/*
+Real-time Charging System for Telecom & ISP environments
+Copyright (C) 2012-2015 ITsysCOM GmbH
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>
+*/
+
+package agents
+
+import (
+	"io"
+	"sync"
+
+	"github.com/cenkalti/rpc2"
+	rpc2_jsonrpc "github.com/cenkalti/rpc2/jsonrpc"
+)
+
+// NewSMGBiRPCClient connects to SMG over bidirectional JSON-RPC, serving handlers for the requests SMG sends back (eg: session disconnects).
+// dial opens the underlying connection, called again on requests once the previous one was lost.
+func NewSMGBiRPCClient(dial func() (io.ReadWriteCloser, error), handlers map[string]interface{}) (*SMGBiRPCClient, error) {
+	smgClnt := &SMGBiRPCClient{dial: dial, handlers: handlers}
+	if _, err := smgClnt.client(); err != nil {
+		return smgClnt, err
+	}
+	return smgClnt, nil
+}
+
+// SMGBiRPCClient implements rpcclient.RpcClientConnection over a BiRPC connection towards SMG.
+// SMG identifies the sessions controller out of the connection so it can reach back only the agent owning them.
+type SMGBiRPCClient struct {
+	sync.Mutex
+	dial     func() (io.ReadWriteCloser, error)
+	handlers map[string]interface{}
+	clnt     *rpc2.Client
+}
+
+// client returns the connected BiRPC client, reconnecting if the connection was lost
+func (self *SMGBiRPCClient) client() (*rpc2.Client, error) {
+	self.Lock()
+	defer self.Unlock()
+	if self.clnt != nil {
+		select {
+		case <-self.clnt.DisconnectNotify():
+		default:
+			return self.clnt, nil
+		}
+	}
+	conn, err := self.dial()
+	if err != nil {
+		return nil, err
+	}
+	self.clnt = rpc2.NewClientWithCodec(rpc2_jsonrpc.NewJSONCodec(conn))
+	for method, handlerFunc := range self.handlers {
+		self.clnt.Handle(method, handlerFunc)
+	}
+	go self.clnt.Run()
+	return self.clnt, nil
+}
+
+func (self *SMGBiRPCClient) Call(serviceMethod string, args interface{}, reply interface{}) error {
+	clnt, err := self.client()
+	if err != nil {
+		return err
+	}
+	return clnt.Call(serviceMethod, args, reply)
+}
@@ -228,6 +228,12 @@ func (self *ApierV1) SetAccount(attr utils.AttrSetAccount, reply *string) error
 		if attr.AllowNegative != nil {
 			ub.AllowNegative = *attr.AllowNegative
 		}
+		if attr.CreditLimit != nil {
+			if *attr.CreditLimit < 0 {
+				return 0, fmt.Errorf("negative CreditLimit: %v", *attr.CreditLimit)
+			}
+			ub.CreditLimit = *attr.CreditLimit
+		}
 		if attr.Disabled != nil {
 			ub.Disabled = *attr.Disabled
 		}
@@ -235,6 +241,7 @@ func (self *ApierV1) SetAccount(attr utils.AttrSetAccount, reply *string) error
 		if err := self.AccountDb.SetAccount(ub); err != nil {
 			return 0, err
 		}
+		engine.RecordAccountHistory(ub)
 		return 0, nil
 	}, 0, accID)
 	if err != nil {
@@ -381,6 +388,7 @@ type AttrAddBalance struct {
 	Overwrite      bool // When true it will reset if the balance is already there
 	Blocker        *bool
 	Disabled       *bool
+	Currency       *string
 }
 
 func (self *ApierV1) AddBalance(attr *AttrAddBalance, reply *string) error {
@@ -413,6 +421,7 @@ func (self *ApierV1) modifyBalance(aType string, attr *AttrAddBalance, reply *st
 			*reply = err.Error()
 			return err
 		}
+		engine.RecordAccountHistory(account)
 	}
 	at := &engine.ActionTiming{}
 	at.SetAccountIDs(utils.StringMap{accID: true})
@@ -432,6 +441,7 @@ func (self *ApierV1) modifyBalance(aType string, attr *AttrAddBalance, reply *st
 			Weight:         attr.Weight,
 			Blocker:        attr.Blocker,
 			Disabled:       attr.Disabled,
+			Currency:       attr.Currency,
 		},
 	}
 	if attr.Directions != nil {
@@ -485,6 +495,7 @@ func (self *ApierV1) SetBalance(attr *utils.AttrSetBalance, reply *string) error
 			*reply = err.Error()
 			return err
 		}
+		engine.RecordAccountHistory(account)
 	}
 	at := &engine.ActionTiming{}
 	at.SetAccountIDs(utils.StringMap{accID: true})
@@ -500,6 +511,7 @@ func (self *ApierV1) SetBalance(attr *utils.AttrSetBalance, reply *string) error
 			Weight:         attr.Weight,
 			Blocker:        attr.Blocker,
 			Disabled:       attr.Disabled,
+			Currency:       attr.Currency,
 		},
 	}
 	if attr.Value != nil {
@@ -560,6 +572,7 @@ func (self *ApierV1) RemoveBalances(attr *utils.AttrSetBalance, reply *string) e
 			Weight:         attr.Weight,
 			Blocker:        attr.Blocker,
 			Disabled:       attr.Disabled,
+			Currency:       attr.Currency,
 		},
 	}
 	if attr.Value != nil {
@@ -670,7 +683,10 @@ func (self *ApierV1) SetBalance(attr *AttrSetBalance, reply *string) error {
 
 		account.InitCounters()
 		account.ExecuteActionTriggers(nil)
-		self.AccountDb.SetAccount(account)
+		if err := self.AccountDb.SetAccount(account); err != nil {
+			return 0, err
+		}
+		engine.RecordAccountHistory(account)
 		return 0, nil
 	}, 0, accID)
 	if err != nil {
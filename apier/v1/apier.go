@@ -49,6 +49,7 @@ type ApierV1 struct {
 	CdrStatsSrv rpcclient.RpcClientConnection
 	Users       rpcclient.RpcClientConnection
 	CDRs        rpcclient.RpcClientConnection // FixMe: populate it from cgr-engine
+	RLs         rpcclient.RpcClientConnection // ResourceLimiter, indexing the ResourceLimits again after loads
 }
 
 func (self *ApierV1) GetDestination(dstId string, reply *engine.Destination) error {
@@ -352,9 +353,20 @@ func (self *ApierV1) LoadTariffPlanFromStorDb(attrs AttrLoadTpFromStorDb, reply
 	for idx, dc := range dcs {
 		dcsKeys[idx] = utils.DERIVEDCHARGERS_PREFIX + dc
 	}
+	exrs, _ := dbReader.GetLoadedIds(utils.ExchangeRatesPrefix)
+	exrKeys := make([]string, len(exrs))
+	for idx, exr := range exrs {
+		exrKeys[idx] = utils.ExchangeRatesPrefix + exr
+	}
+	txrs, _ := dbReader.GetLoadedIds(utils.TaxRulesPrefix)
+	txrKeys := make([]string, len(txrs))
+	for idx, txr := range txrs {
+		txrKeys[idx] = utils.TaxRulesPrefix + txr
+	}
 	aps, _ := dbReader.GetLoadedIds(utils.ACTION_PLAN_PREFIX)
 	cstKeys, _ := dbReader.GetLoadedIds(utils.CDR_STATS_PREFIX)
 	userKeys, _ := dbReader.GetLoadedIds(utils.USERS_PREFIX)
+	rlIDs, _ := dbReader.GetLoadedIds(utils.ResourceLimitsPrefix)
 
 	// relase tp data
 	dbReader.Init()
@@ -369,6 +381,8 @@ func (self *ApierV1) LoadTariffPlanFromStorDb(attrs AttrLoadTpFromStorDb, reply
 		utils.ACTION_PREFIX:          actKeys,
 		utils.ACTION_PLAN_PREFIX:     aplKeys,
 		utils.SHARED_GROUP_PREFIX:    shgKeys,
+		utils.ExchangeRatesPrefix:    exrKeys,
+		utils.TaxRulesPrefix:         txrKeys,
 	}); err != nil {
 		return err
 	}
@@ -395,6 +409,12 @@ func (self *ApierV1) LoadTariffPlanFromStorDb(attrs AttrLoadTpFromStorDb, reply
 			return err
 		}
 	}
+	if len(rlIDs) != 0 && self.RLs != nil {
+		var r string
+		if err := self.RLs.Call("RLsV1.ReloadResourceLimits", rlIDs, &r); err != nil {
+			return err
+		}
+	}
 	*reply = OK
 	return nil
 }
@@ -549,6 +569,9 @@ func (self *ApierV1) SetActions(attrs utils.AttrSetActions, reply *string) error
 				SharedGroups:   utils.StringMapPointer(utils.ParseStringMap(apiAct.SharedGroups)),
 			},
 		}
+		if apiAct.BalanceCurrency != "" {
+			a.Balance.Currency = utils.StringPointer(apiAct.BalanceCurrency)
+		}
 		storeActions[idx] = a
 	}
 	if err := self.RatingDb.SetActions(attrs.ActionsId, storeActions); err != nil {
@@ -591,6 +614,7 @@ func (self *ApierV1) GetActions(actsId string, reply *[]*utils.TPAction) error {
 			act.Categories = bf.GetCategories().String()
 			act.BalanceBlocker = strconv.FormatBool(bf.GetBlocker())
 			act.BalanceDisabled = strconv.FormatBool(bf.GetDisabled())
+			act.BalanceCurrency = bf.GetCurrency()
 		}
 		acts = append(acts, act)
 	}
@@ -730,7 +754,7 @@ func (self *ApierV1) ReloadScheduler(input string, reply *string) error {
 }
 
 func (self *ApierV1) ReloadCache(attrs utils.AttrReloadCache, reply *string) error {
-	var dstKeys, rpKeys, rpfKeys, actKeys, aplKeys, shgKeys, lcrKeys, dcsKeys, alsKeys []string
+	var dstKeys, rpKeys, rpfKeys, actKeys, aplKeys, shgKeys, lcrKeys, dcsKeys, alsKeys, exrKeys, txrKeys []string
 	if len(attrs.DestinationIds) > 0 {
 		dstKeys = make([]string, len(attrs.DestinationIds))
 		for idx, dId := range attrs.DestinationIds {
@@ -786,6 +810,18 @@ func (self *ApierV1) ReloadCache(attrs utils.AttrReloadCache, reply *string) err
 			dcsKeys[idx] = utils.DERIVEDCHARGERS_PREFIX + dc
 		}
 	}
+	if len(attrs.ExchangeRateIds) > 0 {
+		exrKeys = make([]string, len(attrs.ExchangeRateIds))
+		for idx, exrId := range attrs.ExchangeRateIds {
+			exrKeys[idx] = utils.ExchangeRatesPrefix + exrId
+		}
+	}
+	if len(attrs.TaxRuleIds) > 0 {
+		txrKeys = make([]string, len(attrs.TaxRuleIds))
+		for idx, txrId := range attrs.TaxRuleIds {
+			txrKeys[idx] = utils.TaxRulesPrefix + txrId
+		}
+	}
 	if err := self.RatingDb.CacheRatingPrefixValues("ReloadCacheAPI", map[string][]string{
 		utils.DESTINATION_PREFIX:     dstKeys,
 		utils.RATING_PLAN_PREFIX:     rpKeys,
@@ -795,6 +831,8 @@ func (self *ApierV1) ReloadCache(attrs utils.AttrReloadCache, reply *string) err
 		utils.ACTION_PREFIX:          actKeys,
 		utils.ACTION_PLAN_PREFIX:     aplKeys,
 		utils.SHARED_GROUP_PREFIX:    shgKeys,
+		utils.ExchangeRatesPrefix:    exrKeys,
+		utils.TaxRulesPrefix:         txrKeys,
 	}); err != nil {
 		return err
 	}
@@ -803,7 +841,12 @@ func (self *ApierV1) ReloadCache(attrs utils.AttrReloadCache, reply *string) err
 	}); err != nil {
 		return err
 	}
-
+	if self.RLs != nil { // All ResourceLimits on empty ResourceLimitIds, same as for the cache
+		var r string
+		if err := self.RLs.Call("RLsV1.ReloadResourceLimits", attrs.ResourceLimitIds, &r); err != nil {
+			return err
+		}
+	}
 	*reply = utils.OK
 	return nil
 }
@@ -881,6 +924,8 @@ func (self *ApierV1) LoadTariffPlanFromFolder(attrs utils.AttrLoadTpFromFolder,
 		path.Join(attrs.FolderPath, utils.USERS_CSV),
 		path.Join(attrs.FolderPath, utils.ALIASES_CSV),
 		path.Join(attrs.FolderPath, utils.ResourceLimitsCsv),
+		path.Join(attrs.FolderPath, utils.ExchangeRatesCsv),
+		path.Join(attrs.FolderPath, utils.TaxRulesCsv),
 	), "", self.Config.DefaultTimezone)
 	if err := loader.LoadAll(); err != nil {
 		return utils.NewErrServerError(err)
@@ -946,10 +991,21 @@ func (self *ApierV1) LoadTariffPlanFromFolder(attrs utils.AttrLoadTpFromFolder,
 	for idx, dc := range dcs {
 		dcsKeys[idx] = utils.DERIVEDCHARGERS_PREFIX + dc
 	}
+	exrs, _ := loader.GetLoadedIds(utils.ExchangeRatesPrefix)
+	exrKeys := make([]string, len(exrs))
+	for idx, exr := range exrs {
+		exrKeys[idx] = utils.ExchangeRatesPrefix + exr
+	}
+	txrs, _ := loader.GetLoadedIds(utils.TaxRulesPrefix)
+	txrKeys := make([]string, len(txrs))
+	for idx, txr := range txrs {
+		txrKeys[idx] = utils.TaxRulesPrefix + txr
+	}
 	aps, _ := loader.GetLoadedIds(utils.ACTION_PLAN_PREFIX)
 	utils.Logger.Info("ApierV1.LoadTariffPlanFromFolder, reloading cache.")
 	cstKeys, _ := loader.GetLoadedIds(utils.CDR_STATS_PREFIX)
 	userKeys, _ := loader.GetLoadedIds(utils.USERS_PREFIX)
+	rlIDs, _ := loader.GetLoadedIds(utils.ResourceLimitsPrefix)
 
 	// relase the tp data
 	loader.Init()
@@ -963,6 +1019,8 @@ func (self *ApierV1) LoadTariffPlanFromFolder(attrs utils.AttrLoadTpFromFolder,
 		utils.ACTION_PREFIX:          actKeys,
 		utils.ACTION_PLAN_PREFIX:     aplKeys,
 		utils.SHARED_GROUP_PREFIX:    shgKeys,
+		utils.ExchangeRatesPrefix:    exrKeys,
+		utils.TaxRulesPrefix:         txrKeys,
 	}); err != nil {
 		return err
 	}
@@ -987,6 +1045,12 @@ func (self *ApierV1) LoadTariffPlanFromFolder(attrs utils.AttrLoadTpFromFolder,
 			return err
 		}
 	}
+	if len(rlIDs) != 0 && self.RLs != nil {
+		var r string
+		if err := self.RLs.Call("RLsV1.ReloadResourceLimits", rlIDs, &r); err != nil {
+			return err
+		}
+	}
 	*reply = utils.OK
 	return nil
 }
@@ -128,7 +128,7 @@ func (self *ApierV1) ExportCdrsToFile(attr utils.AttrExpFileCdrs, reply *utils.E
 	if attr.ExportId != nil && len(*attr.ExportId) != 0 {
 		exportId = *attr.ExportId
 	}
-	fileName := fmt.Sprintf("cdre_%s.%s", exportId, cdrFormat)
+	fileName := fmt.Sprintf("cdre_%s.%s", exportId, strings.TrimPrefix(cdrFormat, "*")) // *jsonl and *xml formats get plain extensions
 	if attr.ExportFileName != nil && len(*attr.ExportFileName) != 0 {
 		fileName = *attr.ExportFileName
 	}
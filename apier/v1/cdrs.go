@@ -94,3 +94,11 @@ func (apier *ApierV1) RateCDRs(attrs utils.AttrRateCDRs, reply *string) error {
 	}
 	return apier.CDRs.Call("CDRsV1.RateCDRs", attrs, reply)
 }
+
+// Replays the CDR replications which could not be delivered
+func (apier *ApierV1) ReplayFailedReplications(attrs utils.AttrReplayFailedReplications, reply *utils.ReplayedReplications) error {
+	if apier.CDRs == nil {
+		return errors.New("CDRS_NOT_ENABLED")
+	}
+	return apier.CDRs.Call("CDRsV1.ReplayFailedReplications", attrs, reply)
+}
@@ -73,6 +73,11 @@ func (self *CdrsV1) RateCDRs(attrs utils.AttrRateCdrs, reply *string) error {
 	return nil
 }
 
+// Replays the CDR replications kept in spool after failing delivery
+func (self *CdrsV1) ReplayFailedReplications(attrs utils.AttrReplayFailedReplications, reply *utils.ReplayedReplications) error {
+	return self.CdrSrv.V1ReplayFailedReplications(attrs, reply)
+}
+
 func (self *CdrsV1) StoreSMCost(attr engine.AttrCDRSStoreSMCost, reply *string) error {
 	return self.CdrSrv.V1StoreSMCost(attr, reply)
 }
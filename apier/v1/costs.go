@@ -57,3 +57,37 @@ func (apier *ApierV1) GetDataCost(attrs AttrGetDataCost, reply *engine.DataCost)
 	}
 	return nil
 }
+
+type AttrSimulateTariffPlan struct {
+	TPid string // tariff plan in StorDB to rate the CDRs with
+	utils.RPCCDRsFilter
+}
+
+// Re-rates CDRs out of CDR storage against a tariff plan in StorDB, without loading it into DataDB.
+// The CDRs are all loaded in memory, hence Limit is mandatory, use Offset to go through more of them.
+func (apier *ApierV1) SimulateTariffPlan(attrs AttrSimulateTariffPlan, reply *engine.RatingSimulation) error {
+	missing := utils.MissingStructFields(&attrs, []string{"TPid"})
+	if attrs.Limit == nil {
+		missing = append(missing, "Limit")
+	}
+	if len(missing) != 0 {
+		return utils.NewErrMandatoryIeMissing(missing...)
+	}
+	cdrsFltr, err := attrs.RPCCDRsFilter.AsCDRsFilter(apier.Config.DefaultTimezone)
+	if err != nil {
+		return utils.NewErrServerError(err)
+	}
+	rs, err := engine.NewRatingSimulator(apier.StorDb, attrs.TPid, apier.Config.DefaultTimezone)
+	if err != nil {
+		if err == utils.ErrNotFound {
+			return err
+		}
+		return utils.NewErrServerError(err)
+	}
+	cdrs, _, err := apier.CdrDb.GetCDRs(cdrsFltr, false)
+	if err != nil {
+		return utils.NewErrServerError(err)
+	}
+	*reply = *rs.SimulateCDRs(cdrs)
+	return nil
+}
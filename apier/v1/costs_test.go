@@ -0,0 +1,34 @@
+/*
+Real-time Charging System for Telecom & ISP environments
+Copyright (C) ITsysCOM GmbH
+
+This program is free software: you can Storagetribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITH*out ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>
+*/
+
+package v1
+
+import (
+	"testing"
+
+	"github.com/cgrates/cgrates/engine"
+	"github.com/cgrates/cgrates/utils"
+)
+
+func TestSimulateTariffPlanMandatoryLimit(t *testing.T) {
+	var reply engine.RatingSimulation
+	if err := new(ApierV1).SimulateTariffPlan(AttrSimulateTariffPlan{TPid: "TP_SIM"}, &reply); err == nil ||
+		err.Error() != utils.NewErrMandatoryIeMissing("Limit").Error() {
+		t.Errorf("Received: %v", err)
+	}
+}
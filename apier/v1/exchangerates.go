@@ -0,0 +1,82 @@
+/*
+Rating system designed to be used in VoIP Carriers World
+Copyright (C) 2012-2015 ITsysCOM
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>
+*/
+
+package v1
+
+import (
+	"fmt"
+
+	"github.com/cgrates/cgrates/engine"
+	"github.com/cgrates/cgrates/utils"
+)
+
+type AttrSetExchangeRate struct {
+	FromCurrency string
+	ToCurrency   string
+	Rate         float64 // units of ToCurrency for one unit of FromCurrency
+}
+
+// Sets the exchange rate between two currencies in the rating database
+func (self *ApierV1) SetExchangeRate(attrs AttrSetExchangeRate, reply *string) error {
+	if missing := utils.MissingStructFields(&attrs, []string{"FromCurrency", "ToCurrency"}); len(missing) != 0 {
+		return utils.NewErrMandatoryIeMissing(missing...)
+	}
+	if attrs.Rate <= 0 {
+		return utils.NewErrMandatoryIeMissing("Rate")
+	}
+	if attrs.FromCurrency == attrs.ToCurrency {
+		return utils.NewErrServerError(fmt.Errorf("same currency on both sides: %s", attrs.FromCurrency))
+	}
+	if err := self.RatingDb.SetExchangeRate(&engine.ExchangeRate{FromCurrency: attrs.FromCurrency, ToCurrency: attrs.ToCurrency, Rate: attrs.Rate}); err != nil {
+		return utils.NewErrServerError(err)
+	}
+	*reply = utils.OK
+	return nil
+}
+
+type AttrGetExchangeRate struct {
+	FromCurrency string
+	ToCurrency   string
+}
+
+// Returns the rate used to convert amounts from one currency into the other, the opposite rate is inverted when needed
+func (self *ApierV1) GetExchangeRate(attrs AttrGetExchangeRate, reply *float64) error {
+	if missing := utils.MissingStructFields(&attrs, []string{"FromCurrency", "ToCurrency"}); len(missing) != 0 {
+		return utils.NewErrMandatoryIeMissing(missing...)
+	}
+	rate, err := engine.GetExchangeRate(attrs.FromCurrency, attrs.ToCurrency)
+	if err == utils.ErrExchangeRateNotFound {
+		return utils.ErrNotFound
+	} else if err != nil {
+		return utils.NewErrServerError(err)
+	}
+	*reply = rate
+	return nil
+}
+
+// Removes the exchange rate defined from one currency to the other
+func (self *ApierV1) RemoveExchangeRate(attrs AttrGetExchangeRate, reply *string) error {
+	if missing := utils.MissingStructFields(&attrs, []string{"FromCurrency", "ToCurrency"}); len(missing) != 0 {
+		return utils.NewErrMandatoryIeMissing(missing...)
+	}
+	if err := self.RatingDb.RemoveExchangeRate(utils.ConcatenatedKey(attrs.FromCurrency, attrs.ToCurrency)); err != nil {
+		return utils.NewErrServerError(err)
+	}
+	*reply = utils.OK
+	return nil
+}
@@ -0,0 +1,96 @@
+/*
+Real-time Charging System for Telecom & ISP environments
+Copyright (C) ITsysCOM GmbH
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>
+*/
+
+package v1
+
+import (
+	"github.com/cgrates/cgrates/engine"
+	"github.com/cgrates/cgrates/utils"
+)
+
+type AttrGetBalanceLedger struct {
+	Tenant      string
+	Account     string
+	BalanceUUID string
+	CGRID       string
+	ActionsID   string
+	TimeStart   string // Changes performed at or after this time
+	TimeEnd     string // Changes performed before this time
+	utils.Paginator
+}
+
+// Queries the balance changes written into the ledger
+func (self *ApierV1) GetBalanceLedger(attrs AttrGetBalanceLedger, reply *[]*engine.BalanceLedgerEntry) error {
+	filter := &engine.BalanceLedgerFilter{BalanceUUID: attrs.BalanceUUID, CGRID: attrs.CGRID, ActionsID: attrs.ActionsID, Paginator: attrs.Paginator}
+	if attrs.Account != "" {
+		filter.Account = utils.AccountKey(attrs.Tenant, attrs.Account)
+	}
+	var err error
+	if attrs.TimeStart != "" {
+		if filter.TimeStart, err = utils.ParseTimeDetectLayout(attrs.TimeStart, self.Config.DefaultTimezone); err != nil {
+			return utils.NewErrServerError(err)
+		}
+	}
+	if attrs.TimeEnd != "" {
+		if filter.TimeEnd, err = utils.ParseTimeDetectLayout(attrs.TimeEnd, self.Config.DefaultTimezone); err != nil {
+			return utils.NewErrServerError(err)
+		}
+	}
+	entries, err := self.CdrDb.GetBalanceLedger(filter)
+	if err != nil {
+		return utils.NewErrServerError(err)
+	}
+	if len(entries) == 0 {
+		return utils.ErrNotFound
+	}
+	*reply = entries
+	return nil
+}
+
+type AttrReconcileBalanceLedger struct {
+	Tenant  string
+	Account string
+}
+
+// Checks the ledger of an account against its current balances, an empty reply means they are in sync
+func (self *ApierV1) ReconcileBalanceLedger(attrs AttrReconcileBalanceLedger, reply *[]*engine.BalanceLedgerMismatch) error {
+	if missing := utils.MissingStructFields(&attrs, []string{"Tenant", "Account"}); len(missing) != 0 {
+		return utils.NewErrMandatoryIeMissing(missing...)
+	}
+	accID := utils.AccountKey(attrs.Tenant, attrs.Account)
+	var mismatches []*engine.BalanceLedgerMismatch
+	if _, err := engine.Guardian.Guard(func() (interface{}, error) { // no balance changes while comparing
+		acc, err := self.AccountDb.GetAccount(accID)
+		if err != nil {
+			return 0, err
+		}
+		entries, err := self.CdrDb.GetBalanceLedger(&engine.BalanceLedgerFilter{Account: accID})
+		if err != nil {
+			return 0, err
+		}
+		mismatches = engine.ReconcileBalanceLedger(acc, entries)
+		return 0, nil
+	}, 0, accID); err != nil {
+		return utils.NewErrServerError(err)
+	}
+	if mismatches == nil {
+		mismatches = make([]*engine.BalanceLedgerMismatch, 0)
+	}
+	*reply = mismatches
+	return nil
+}
@@ -0,0 +1,109 @@
+/*
+Real-time Charging System for Telecom & ISP environments
+Copyright (C) ITsysCOM GmbH
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>
+*/
+
+package v1
+
+import (
+	"time"
+
+	"github.com/cgrates/cgrates/engine"
+	"github.com/cgrates/cgrates/utils"
+)
+
+type AttrReserveBalance struct {
+	Tenant        string
+	Account       string
+	ReservationID string // Generated when empty
+	BalanceType   string // Defaults to *monetary
+	Value         float64
+	ExpiryTime    string // Absolute (eg: 2016-10-01T12:00:00Z) or relative (eg: +10m) time the reservation is released automatically
+}
+
+// Holds a value out of the account balances until it is captured, released or it expires
+func (self *ApierV1) ReserveBalance(attrs AttrReserveBalance, reply *engine.BalanceReservation) error {
+	if missing := utils.MissingStructFields(&attrs, []string{"Tenant", "Account", "ExpiryTime"}); len(missing) != 0 {
+		return utils.NewErrMandatoryIeMissing(missing...)
+	}
+	expiryTime, err := utils.ParseDate(attrs.ExpiryTime)
+	if err != nil {
+		return utils.NewErrServerError(err)
+	}
+	r := &engine.BalanceReservation{ID: attrs.ReservationID, BalanceType: attrs.BalanceType, Value: attrs.Value,
+		ExpiryTime: expiryTime, CreatedAt: time.Now()}
+	if r.ID == "" {
+		r.ID = utils.GenUUID()
+	}
+	if r.BalanceType == "" {
+		r.BalanceType = utils.MONETARY
+	}
+	accID := utils.AccountKey(attrs.Tenant, attrs.Account)
+	if err := engine.ReserveBalance(accID, r); err != nil {
+		if err == utils.ErrInsufficientCredit || err == utils.ErrExists || err == utils.ErrNotFound {
+			return err
+		}
+		return utils.NewErrServerError(err)
+	}
+	if self.Sched != nil {
+		self.Sched.QueueActionTiming(engine.NewReservationExpiryTiming(accID, r))
+	}
+	*reply = *r
+	return nil
+}
+
+type AttrCaptureReservation struct {
+	Tenant        string
+	Account       string
+	ReservationID string
+	Value         float64 // Debited value, at most the reserved one
+}
+
+// Debits the captured value and releases the reservation
+func (self *ApierV1) CaptureReservation(attrs AttrCaptureReservation, reply *string) error {
+	if missing := utils.MissingStructFields(&attrs, []string{"Tenant", "Account", "ReservationID"}); len(missing) != 0 {
+		return utils.NewErrMandatoryIeMissing(missing...)
+	}
+	if err := engine.CaptureReservation(utils.AccountKey(attrs.Tenant, attrs.Account), attrs.ReservationID, attrs.Value); err != nil {
+		if err == utils.ErrNotFound || err == utils.ErrReservationExceeded {
+			return err
+		}
+		return utils.NewErrServerError(err)
+	}
+	*reply = utils.OK
+	return nil
+}
+
+type AttrReleaseReservation struct {
+	Tenant        string
+	Account       string
+	ReservationID string
+}
+
+// Releases the reservation without debiting anything
+func (self *ApierV1) ReleaseReservation(attrs AttrReleaseReservation, reply *string) error {
+	if missing := utils.MissingStructFields(&attrs, []string{"Tenant", "Account", "ReservationID"}); len(missing) != 0 {
+		return utils.NewErrMandatoryIeMissing(missing...)
+	}
+	if err := engine.ReleaseReservation(utils.AccountKey(attrs.Tenant, attrs.Account), attrs.ReservationID); err != nil {
+		if err == utils.ErrNotFound {
+			return err
+		}
+		return utils.NewErrServerError(err)
+	}
+	*reply = utils.OK
+	return nil
+}
@@ -0,0 +1,58 @@
+/*
+Real-time Charging System for Telecom & ISP environments
+Copyright (C) ITsysCOM GmbH
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>
+*/
+
+package v1
+
+import (
+	"github.com/cgrates/cgrates/engine"
+	"github.com/cgrates/cgrates/utils"
+)
+
+func NewResourceLimiterV1(rls *engine.ResourceLimiterService) *ResourceLimiterV1 {
+	return &ResourceLimiterV1{rls: rls}
+}
+
+// Exports RPC from ResourceLimiterService
+type ResourceLimiterV1 struct {
+	rls *engine.ResourceLimiterService
+}
+
+// Returns the ResourceLimits matching the event
+func (self *ResourceLimiterV1) ResourceLimitsForEvent(ev map[string]interface{}, reply *[]*engine.ResourceLimit) error {
+	return self.rls.V1ResourceLimitsForEvent(ev, reply)
+}
+
+// Checks if the units requested fit into all the ResourceLimits matching the event, without recording the usage
+func (self *ResourceLimiterV1) AllowUsage(attrs utils.AttrRLsResourceUsage, allow *bool) error {
+	return self.rls.V1AllowUsage(attrs, allow)
+}
+
+// Records the usage on the matching ResourceLimits, called on session start
+func (self *ResourceLimiterV1) InitiateResourceUsage(attrs utils.AttrRLsResourceUsage, reply *string) error {
+	return self.rls.V1InitiateResourceUsage(attrs, reply)
+}
+
+// Releases the usage recorded under UsageID, called on session end
+func (self *ResourceLimiterV1) TerminateResourceUsage(attrs utils.AttrRLsResourceUsage, reply *string) error {
+	return self.rls.V1TerminateResourceUsage(attrs, reply)
+}
+
+// Reads the ResourceLimits again out of DataDB and indexes them, all of them on empty rlIDs
+func (self *ResourceLimiterV1) ReloadResourceLimits(rlIDs []string, reply *string) error {
+	return self.rls.V1ReloadResourceLimits(rlIDs, reply)
+}
@@ -42,6 +42,7 @@ func (self *SMGenericBiRpcV1) Handlers() map[string]interface{} {
 		"SMGenericV1.InitiateSession":  self.InitiateSession,
 		"SMGenericV1.UpdateSession":    self.UpdateSession,
 		"SMGenericV1.TerminateSession": self.TerminateSession,
+		"SMGenericV1.ChargeEvent":      self.ChargeEvent,
 		"SMGenericV1.ProcessCDR":       self.ProcessCDR,
 	}
 }
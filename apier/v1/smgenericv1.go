@@ -3,6 +3,7 @@ package v1
 import (
 	"time"
 
+	"github.com/cgrates/cgrates/engine"
 	"github.com/cgrates/cgrates/sessionmanager"
 	"github.com/cgrates/cgrates/utils"
 	"github.com/cgrates/rpcclient"
@@ -140,6 +141,24 @@ func (self *SMGenericV1) ActiveSessionsCount(attrs utils.AttrSMGGetActiveSession
 	return nil
 }
 
+// Receives the state of a session run from the peer handling it, so we can take over the session on failover
+func (self *SMGenericV1) SetPassiveSession(smgCp engine.SMGSessionCheckpoint, reply *string) error {
+	if err := self.sm.SetPassiveSession(&smgCp); err != nil {
+		return err
+	}
+	*reply = utils.OK
+	return nil
+}
+
+// Removes a session run replicated by a peer
+func (self *SMGenericV1) RemovePassiveSession(smgCp engine.SMGSessionCheckpoint, reply *string) error {
+	if err := self.sm.RemovePassiveSession(smgCp.SessionID, smgCp.RunID); err != nil {
+		return err
+	}
+	*reply = utils.OK
+	return nil
+}
+
 // rpcclient.RpcClientConnection interface
 func (self *SMGenericV1) Call(serviceMethod string, args interface{}, reply interface{}) error {
 	switch serviceMethod {
@@ -234,6 +253,26 @@ func (self *SMGenericV1) Call(serviceMethod string, args interface{}, reply inte
 			return rpcclient.ErrWrongReplyType
 		}
 		return self.ActiveSessionsCount(argsConverted, replyConverted)
+	case "SMGenericV1.SetPassiveSession":
+		argsConverted, canConvert := args.(engine.SMGSessionCheckpoint)
+		if !canConvert {
+			return rpcclient.ErrWrongArgsType
+		}
+		replyConverted, canConvert := reply.(*string)
+		if !canConvert {
+			return rpcclient.ErrWrongReplyType
+		}
+		return self.SetPassiveSession(argsConverted, replyConverted)
+	case "SMGenericV1.RemovePassiveSession":
+		argsConverted, canConvert := args.(engine.SMGSessionCheckpoint)
+		if !canConvert {
+			return rpcclient.ErrWrongArgsType
+		}
+		replyConverted, canConvert := reply.(*string)
+		if !canConvert {
+			return rpcclient.ErrWrongReplyType
+		}
+		return self.RemovePassiveSession(argsConverted, replyConverted)
 	}
 	return rpcclient.ErrUnsupporteServiceMethod
 }
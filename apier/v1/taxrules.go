@@ -0,0 +1,67 @@
+/*
+Rating system designed to be used in VoIP Carriers World
+Copyright (C) 2012-2015 ITsysCOM
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>
+*/
+
+package v1
+
+import (
+	"github.com/cgrates/cgrates/engine"
+	"github.com/cgrates/cgrates/utils"
+)
+
+type AttrGetTaxRules struct {
+	IDs []string // Return all the tax rules if empty
+}
+
+// Returns the tax rules loaded in the rating database
+func (self *ApierV1) GetTaxRules(attr AttrGetTaxRules, reply *[]*engine.TaxRules) error {
+	allTxrs, err := self.RatingDb.GetAllTaxRules()
+	if err != nil {
+		return utils.NewErrServerError(err)
+	}
+	var txrsIDs utils.StringMap
+	if len(attr.IDs) != 0 {
+		txrsIDs = utils.NewStringMap(attr.IDs...)
+	}
+	var txrs []*engine.TaxRules
+	for _, txr := range allTxrs {
+		if txrsIDs == nil || txrsIDs[txr.ID] {
+			txrs = append(txrs, txr)
+		}
+	}
+	if len(txrs) == 0 {
+		return utils.ErrNotFound
+	}
+	*reply = txrs
+	return nil
+}
+
+type AttrRemoveTaxRules struct {
+	ID string
+}
+
+// Removes the tax rules out of the rating database
+func (self *ApierV1) RemoveTaxRules(attr AttrRemoveTaxRules, reply *string) error {
+	if missing := utils.MissingStructFields(&attr, []string{"ID"}); len(missing) != 0 {
+		return utils.NewErrMandatoryIeMissing(missing...)
+	}
+	if err := self.RatingDb.RemoveTaxRules(attr.ID); err != nil {
+		return utils.NewErrServerError(err)
+	}
+	*reply = utils.OK
+	return nil
+}
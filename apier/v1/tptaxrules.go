@@ -0,0 +1,101 @@
+/*
+Rating system designed to be used in VoIP Carriers World
+Copyright (C) 2012-2015 ITsysCOM
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>
+*/
+
+package v1
+
+import (
+	"github.com/cgrates/cgrates/engine"
+	"github.com/cgrates/cgrates/utils"
+)
+
+// Creates a new TaxRules profile within a tariff plan
+func (self *ApierV1) SetTPTaxRules(attrs utils.TPTaxRules, reply *string) error {
+	if missing := utils.MissingStructFields(&attrs, []string{"TPid", "TaxRulesId", "TaxRules"}); len(missing) != 0 {
+		return utils.NewErrMandatoryIeMissing(missing...)
+	}
+	for _, txr := range attrs.TaxRules {
+		if missing := utils.MissingStructFields(txr, []string{"Tenant", "TaxName"}); len(missing) != 0 {
+			return utils.NewErrMandatoryIeMissing(missing...)
+		}
+		if txr.Percent == 0 && txr.PerMinute == 0 {
+			return utils.NewErrMandatoryIeMissing("Percent", "PerMinute")
+		}
+	}
+	if err := self.StorDb.SetTpTaxRules(engine.APItoModelTaxRule(&attrs)); err != nil {
+		return utils.NewErrServerError(err)
+	}
+	*reply = utils.OK
+	return nil
+}
+
+type AttrGetTPTaxRules struct {
+	TPid       string // Tariff plan id
+	TaxRulesId string // TaxRules id
+}
+
+// Queries specific TaxRules on tariff plan
+func (self *ApierV1) GetTPTaxRules(attrs AttrGetTPTaxRules, reply *utils.TPTaxRules) error {
+	if missing := utils.MissingStructFields(&attrs, []string{"TPid", "TaxRulesId"}); len(missing) != 0 { //Params missing
+		return utils.NewErrMandatoryIeMissing(missing...)
+	}
+	if txrs, err := self.StorDb.GetTpTaxRules(attrs.TPid, attrs.TaxRulesId); err != nil {
+		return utils.NewErrServerError(err)
+	} else if len(txrs) == 0 {
+		return utils.ErrNotFound
+	} else {
+		txrMap, err := engine.TpTaxRules(txrs).GetTaxRules()
+		if err != nil {
+			return err
+		}
+		*reply = utils.TPTaxRules{TPid: attrs.TPid, TaxRulesId: attrs.TaxRulesId, TaxRules: txrMap[attrs.TaxRulesId]}
+	}
+	return nil
+}
+
+type AttrGetTPTaxRuleIds struct {
+	TPid string // Tariff plan id
+	utils.Paginator
+}
+
+// Queries TaxRules identities on specific tariff plan.
+func (self *ApierV1) GetTPTaxRuleIds(attrs AttrGetTPTaxRuleIds, reply *[]string) error {
+	if missing := utils.MissingStructFields(&attrs, []string{"TPid"}); len(missing) != 0 { //Params missing
+		return utils.NewErrMandatoryIeMissing(missing...)
+	}
+	if ids, err := self.StorDb.GetTpTableIds(attrs.TPid, utils.TBLTPTaxRules, utils.TPDistinctIds{"tag"}, nil, &attrs.Paginator); err != nil {
+		return utils.NewErrServerError(err)
+	} else if ids == nil {
+		return utils.ErrNotFound
+	} else {
+		*reply = ids
+	}
+	return nil
+}
+
+// Removes specific TaxRules on Tariff plan
+func (self *ApierV1) RemTPTaxRules(attrs AttrGetTPTaxRules, reply *string) error {
+	if missing := utils.MissingStructFields(&attrs, []string{"TPid", "TaxRulesId"}); len(missing) != 0 { //Params missing
+		return utils.NewErrMandatoryIeMissing(missing...)
+	}
+	if err := self.StorDb.RemTpData(utils.TBLTPTaxRules, attrs.TPid, map[string]string{"tag": attrs.TaxRulesId}); err != nil {
+		return utils.NewErrServerError(err)
+	} else {
+		*reply = utils.OK
+	}
+	return nil
+}
@@ -78,6 +78,7 @@ func (self *ApierV1) AddAccountActionTriggers(attr AttrAddAccountActionTriggers,
 		if err := self.AccountDb.SetAccount(account); err != nil {
 			return 0, err
 		}
+		engine.RecordAccountHistory(account)
 		return 0, nil
 	}, 0, accID)
 	if err != nil {
@@ -121,6 +122,7 @@ func (self *ApierV1) RemoveAccountActionTriggers(attr AttrRemoveAccountActionTri
 		if err := self.AccountDb.SetAccount(account); err != nil {
 			return 0, err
 		}
+		engine.RecordAccountHistory(account)
 		return 0, nil
 	}, 0, accID)
 	if err != nil {
@@ -166,6 +168,7 @@ func (self *ApierV1) ResetAccountActionTriggers(attr AttrResetAccountActionTrigg
 		if err := self.AccountDb.SetAccount(account); err != nil {
 			return 0, err
 		}
+		engine.RecordAccountHistory(account)
 		return 0, nil
 	}, 0, accID)
 	if err != nil {
@@ -308,6 +311,7 @@ func (self *ApierV1) SetAccountActionTriggers(attr AttrSetAccountActionTriggers,
 		if err := self.AccountDb.SetAccount(account); err != nil {
 			return 0, err
 		}
+		engine.RecordAccountHistory(account)
 		return 0, nil
 	}, 0, accID)
 	if err != nil {
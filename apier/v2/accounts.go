@@ -92,6 +92,7 @@ type AttrSetAccount struct {
 	ActionTriggerIDs       *[]string
 	ActionTriggerOverwrite bool
 	AllowNegative          *bool
+	CreditLimit            *float64
 	Disabled               *bool
 	ReloadScheduler        bool
 }
@@ -202,6 +203,12 @@ func (self *ApierV2) SetAccount(attr AttrSetAccount, reply *string) error {
 		if attr.AllowNegative != nil {
 			ub.AllowNegative = *attr.AllowNegative
 		}
+		if attr.CreditLimit != nil {
+			if *attr.CreditLimit < 0 {
+				return 0, fmt.Errorf("negative CreditLimit: %v", *attr.CreditLimit)
+			}
+			ub.CreditLimit = *attr.CreditLimit
+		}
 		if attr.Disabled != nil {
 			ub.Disabled = *attr.Disabled
 		}
@@ -209,6 +216,7 @@ func (self *ApierV2) SetAccount(attr AttrSetAccount, reply *string) error {
 		if err := self.AccountDb.SetAccount(ub); err != nil {
 			return 0, err
 		}
+		engine.RecordAccountHistory(ub)
 		return 0, nil
 	}, 0, accID)
 	if err != nil {
@@ -157,6 +157,8 @@ func (self *ApierV2) LoadTariffPlanFromFolder(attrs utils.AttrLoadTpFromFolder,
 		path.Join(attrs.FolderPath, utils.USERS_CSV),
 		path.Join(attrs.FolderPath, utils.ALIASES_CSV),
 		path.Join(attrs.FolderPath, utils.ResourceLimitsCsv),
+		path.Join(attrs.FolderPath, utils.ExchangeRatesCsv),
+		path.Join(attrs.FolderPath, utils.TaxRulesCsv),
 	), "", self.Config.DefaultTimezone)
 	if err := loader.LoadAll(); err != nil {
 		return utils.NewErrServerError(err)
@@ -221,6 +223,16 @@ func (self *ApierV2) LoadTariffPlanFromFolder(attrs utils.AttrLoadTpFromFolder,
 	for idx, dc := range dcs {
 		dcsKeys[idx] = utils.DERIVEDCHARGERS_PREFIX + dc
 	}
+	exrs, _ := loader.GetLoadedIds(utils.ExchangeRatesPrefix)
+	exrKeys := make([]string, len(exrs))
+	for idx, exr := range exrs {
+		exrKeys[idx] = utils.ExchangeRatesPrefix + exr
+	}
+	txrs, _ := loader.GetLoadedIds(utils.TaxRulesPrefix)
+	txrKeys := make([]string, len(txrs))
+	for idx, txr := range txrs {
+		txrKeys[idx] = utils.TaxRulesPrefix + txr
+	}
 	aps, _ := loader.GetLoadedIds(utils.ACTION_PLAN_PREFIX)
 	utils.Logger.Info("ApierV2.LoadTariffPlanFromFolder, reloading cache.")
 
@@ -236,6 +248,8 @@ func (self *ApierV2) LoadTariffPlanFromFolder(attrs utils.AttrLoadTpFromFolder,
 		utils.ACTION_PREFIX:          actKeys,
 		utils.ACTION_PLAN_PREFIX:     aplKeys,
 		utils.SHARED_GROUP_PREFIX:    shgKeys,
+		utils.ExchangeRatesPrefix:    exrKeys,
+		utils.TaxRulesPrefix:         txrKeys,
 	}); err != nil {
 		return err
 	}
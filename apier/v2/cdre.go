@@ -27,19 +27,33 @@ import (
 	"unicode/utf8"
 
 	"github.com/cgrates/cgrates/cdre"
+	"github.com/cgrates/cgrates/engine"
 	"github.com/cgrates/cgrates/utils"
 )
 
 // Export Cdrs to file
 func (self *ApierV2) ExportCdrsToFile(attr utils.AttrExportCdrsToFile, reply *utils.ExportedFileCdrs) error {
-	var err error
+	cdrsFltr, err := attr.RPCCDRsFilter.AsCDRsFilter(self.Config.DefaultTimezone)
+	if err != nil {
+		return utils.NewErrServerError(err)
+	}
+	cdrs, _, err := self.CdrDb.GetCDRs(cdrsFltr, false)
+	if err != nil {
+		return err
+	}
+	_, err = self.exportCdrsToFile(attr, cdrs, reply)
+	return err
+}
+
+// Exports the cdrs using the options in attr, returning the exporter so the caller can find out the CDRs which failed, nil if there were no cdrs
+func (self *ApierV2) exportCdrsToFile(attr utils.AttrExportCdrsToFile, cdrs []*engine.CDR, reply *utils.ExportedFileCdrs) (cdrexp *cdre.CdrExporter, err error) {
 	cdreReloadStruct := <-self.Config.ConfigReloads[utils.CDRE]                  // Read the content of the channel, locking it
 	defer func() { self.Config.ConfigReloads[utils.CDRE] <- cdreReloadStruct }() // Unlock reloads at exit
 	exportTemplate := self.Config.CdreProfiles[utils.META_DEFAULT]
 	if attr.ExportTemplate != nil && len(*attr.ExportTemplate) != 0 { // Export template prefered, use it
 		var hasIt bool
 		if exportTemplate, hasIt = self.Config.CdreProfiles[*attr.ExportTemplate]; !hasIt {
-			return fmt.Errorf("%s:ExportTemplate", utils.ErrNotFound)
+			return nil, fmt.Errorf("%s:ExportTemplate", utils.ErrNotFound)
 		}
 	}
 	cdrFormat := exportTemplate.CdrFormat
@@ -47,13 +61,13 @@ func (self *ApierV2) ExportCdrsToFile(attr utils.AttrExportCdrsToFile, reply *ut
 		cdrFormat = strings.ToLower(*attr.CdrFormat)
 	}
 	if !utils.IsSliceMember(utils.CdreCdrFormats, cdrFormat) {
-		return utils.NewErrMandatoryIeMissing("CdrFormat")
+		return nil, utils.NewErrMandatoryIeMissing("CdrFormat")
 	}
 	fieldSep := exportTemplate.FieldSeparator
 	if attr.FieldSeparator != nil && len(*attr.FieldSeparator) != 0 {
 		fieldSep, _ = utf8.DecodeRuneInString(*attr.FieldSeparator)
 		if fieldSep == utf8.RuneError {
-			return fmt.Errorf("%s:FieldSeparator:%s", utils.ErrServerError, "Invalid")
+			return nil, fmt.Errorf("%s:FieldSeparator:%s", utils.ErrServerError, "Invalid")
 		}
 	}
 	ExportFolder := exportTemplate.ExportFolder
@@ -64,7 +78,7 @@ func (self *ApierV2) ExportCdrsToFile(attr utils.AttrExportCdrsToFile, reply *ut
 	if attr.ExportID != nil && len(*attr.ExportID) != 0 {
 		ExportID = *attr.ExportID
 	}
-	fileName := fmt.Sprintf("cdre_%s.%s", ExportID, cdrFormat)
+	fileName := fmt.Sprintf("cdre_%s.%s", ExportID, strings.TrimPrefix(cdrFormat, "*")) // *jsonl and *xml formats get plain extensions
 	if attr.ExportFileName != nil && len(*attr.ExportFileName) != 0 {
 		fileName = *attr.ExportFileName
 	}
@@ -108,32 +122,25 @@ func (self *ApierV2) ExportCdrsToFile(attr utils.AttrExportCdrsToFile, reply *ut
 	if attr.MaskLength != nil {
 		maskLen = *attr.MaskLength
 	}
-	cdrsFltr, err := attr.RPCCDRsFilter.AsCDRsFilter(self.Config.DefaultTimezone)
-	if err != nil {
-		return utils.NewErrServerError(err)
-	}
-	cdrs, _, err := self.CdrDb.GetCDRs(cdrsFltr, false)
-	if err != nil {
-		return err
-	} else if len(cdrs) == 0 {
+	if len(cdrs) == 0 {
 		*reply = utils.ExportedFileCdrs{ExportedFilePath: ""}
-		return nil
+		return
 	}
-	cdrexp, err := cdre.NewCdrExporter(cdrs, self.CdrDb, exportTemplate, cdrFormat, fieldSep, ExportID, dataUsageMultiplyFactor, SMSUsageMultiplyFactor, MMSUsageMultiplyFactor, genericUsageMultiplyFactor, costMultiplyFactor, costShiftDigits, roundingDecimals, self.Config.RoundingDecimals, maskDestId, maskLen, self.Config.HttpSkipTlsVerify, self.Config.DefaultTimezone)
+	cdrexp, err = cdre.NewCdrExporter(cdrs, self.CdrDb, exportTemplate, cdrFormat, fieldSep, ExportID, dataUsageMultiplyFactor, SMSUsageMultiplyFactor, MMSUsageMultiplyFactor, genericUsageMultiplyFactor, costMultiplyFactor, costShiftDigits, roundingDecimals, self.Config.RoundingDecimals, maskDestId, maskLen, self.Config.HttpSkipTlsVerify, self.Config.DefaultTimezone)
 	if err != nil {
-		return utils.NewErrServerError(err)
+		return nil, utils.NewErrServerError(err)
 	}
 	if cdrexp.TotalExportedCdrs() == 0 {
 		*reply = utils.ExportedFileCdrs{ExportedFilePath: ""}
-		return nil
+		return
 	}
 	if err := cdrexp.WriteToFile(filePath); err != nil {
-		return utils.NewErrServerError(err)
+		return nil, utils.NewErrServerError(err)
 	}
 	*reply = utils.ExportedFileCdrs{ExportedFilePath: filePath, TotalRecords: len(cdrs), TotalCost: cdrexp.TotalCost(), FirstOrderId: cdrexp.FirstOrderId(), LastOrderId: cdrexp.LastOrderId()}
 	if !attr.Verbose {
 		reply.ExportedCgrIds = cdrexp.PositiveExports()
 		reply.UnexportedCgrIds = cdrexp.NegativeExports()
 	}
-	return nil
+	return
 }
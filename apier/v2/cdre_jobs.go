@@ -0,0 +1,208 @@
+/*
+Real-time Charging System for Telecom & ISP environments
+Copyright (C) 2012-2015 ITsysCOM GmbH
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>
+*/
+
+package v2
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/cgrates/cgrates/config"
+	"github.com/cgrates/cgrates/engine"
+	"github.com/cgrates/cgrates/utils"
+)
+
+type AttrRunCdrExportJob struct {
+	JobID string
+}
+
+// Data available to the file_name template of a CDR export job
+type cdrExportFileName struct {
+	JobID      string
+	ExportID   string
+	RunTime    time.Time
+	RangeStart time.Time // Start of the relative time range, zero if the job has no time_range
+	RangeEnd   time.Time
+}
+
+// Makes the export jobs in config runnable via *cdr_export action and queues them into the scheduler
+func (self *ApierV2) ScheduleCdrExportJobs() {
+	engine.SetCdrExportJobRunner(func(jobID string) error {
+		var reply utils.ExportedFileCdrs
+		return self.RunCdrExportJob(AttrRunCdrExportJob{JobID: jobID}, &reply)
+	})
+	if self.Sched == nil {
+		return
+	}
+	for _, job := range self.Config.SchedulerCdrExports {
+		self.Sched.QueueStaticActionTiming(engine.NewCdrExportTiming(job.ID, job.Timing))
+	}
+}
+
+// Runs one of the CDR export jobs in config, exporting the CDRs stored since its previous run
+func (self *ApierV2) RunCdrExportJob(attr AttrRunCdrExportJob, reply *utils.ExportedFileCdrs) error {
+	if missing := utils.MissingStructFields(&attr, []string{"JobID"}); len(missing) != 0 {
+		return utils.NewErrMandatoryIeMissing(missing...)
+	}
+	var job *config.CdrExportJobCfg
+	for _, jobCfg := range self.Config.SchedulerCdrExports {
+		if jobCfg.ID == attr.JobID {
+			job = jobCfg
+			break
+		}
+	}
+	if job == nil {
+		return utils.ErrNotFound
+	}
+	// One run at a time per job so the same CDRs are not picked twice
+	_, err := engine.Guardian.Guard(func() (interface{}, error) {
+		return nil, self.runCdrExportJob(job, time.Now(), reply)
+	}, 0, utils.CdrExportJobsPrefix+job.ID)
+	return err
+}
+
+func (self *ApierV2) runCdrExportJob(job *config.CdrExportJobCfg, now time.Time, reply *utils.ExportedFileCdrs) error {
+	jobState, err := self.AccountDb.GetCdrExportJobState(job.ID)
+	if err != nil && err != utils.ErrNotFound {
+		return utils.NewErrServerError(err)
+	}
+	loc, err := time.LoadLocation(self.Config.DefaultTimezone)
+	if err != nil {
+		return utils.NewErrServerError(err)
+	}
+	rangeStart, rangeEnd, err := utils.RelativeTimeRange(job.TimeRange, now.In(loc))
+	if err != nil {
+		return utils.NewErrServerError(err)
+	}
+	var fltr utils.RPCCDRsFilter
+	if job.CdrsFilter != nil {
+		fltr = *job.CdrsFilter
+	}
+	// The range is matched on creation time which grows together with the OrderID,
+	// so CDRs created after the end of the range are picked by the next run
+	if jobState == nil {
+		jobState = &engine.CdrExportJobState{JobID: job.ID}
+	}
+	if jobState.LastOrderID == 0 { // Nothing processed yet, continue from where the previous range ended
+		if !jobState.RangeEnd.IsZero() {
+			fltr.CreatedAtStart = jobState.RangeEnd.Format(time.RFC3339)
+		} else if !rangeStart.IsZero() {
+			fltr.CreatedAtStart = rangeStart.Format(time.RFC3339)
+		}
+	} else if fltr.OrderIDStart == nil || *fltr.OrderIDStart <= jobState.LastOrderID {
+		fltr.OrderIDStart = utils.Int64Pointer(jobState.LastOrderID + 1)
+	}
+	if !rangeEnd.IsZero() {
+		fltr.CreatedAtEnd = rangeEnd.Format(time.RFC3339)
+	}
+	cdrsFltr, err := fltr.AsCDRsFilter(self.Config.DefaultTimezone)
+	if err != nil {
+		return utils.NewErrServerError(err)
+	}
+	cdrs, _, err := self.CdrDb.GetCDRs(cdrsFltr, false)
+	if err != nil {
+		return utils.NewErrServerError(err)
+	}
+	lastOrderID := jobState.LastOrderID
+	for _, cdr := range cdrs {
+		if cdr.OrderID > lastOrderID {
+			lastOrderID = cdr.OrderID
+		}
+	}
+	// CDRs failed in previous runs are retried without holding back the new ones
+	retryCDRs, err := self.cdrExportJobRetries(jobState.FailedCDRs)
+	if err != nil {
+		return utils.NewErrServerError(err)
+	}
+	cdrs = append(retryCDRs, cdrs...)
+	exportID := job.ID + "_" + strconv.FormatInt(now.Unix(), 10)
+	attr := utils.AttrExportCdrsToFile{
+		ExportTemplate: utils.StringPointer(job.ExportTemplate),
+		ExportFolder:   utils.StringPointer(job.ExportFolder),
+		ExportID:       utils.StringPointer(exportID),
+	}
+	if len(job.FileName) != 0 {
+		tmpl, err := template.New(job.ID).Parse(job.FileName)
+		if err != nil {
+			return utils.NewErrServerError(err)
+		}
+		var fileName bytes.Buffer
+		if err := tmpl.Execute(&fileName, &cdrExportFileName{JobID: job.ID, ExportID: exportID, RunTime: now,
+			RangeStart: rangeStart, RangeEnd: rangeEnd}); err != nil {
+			return utils.NewErrServerError(err)
+		}
+		attr.ExportFileName = utils.StringPointer(fileName.String())
+	}
+	cdrexp, err := self.exportCdrsToFile(attr, cdrs, reply)
+	if err != nil {
+		utils.Logger.Err(fmt.Sprintf("<CDRE> Export job: %s failed: %s", job.ID, err.Error()))
+		return err
+	}
+	jobState.FailedCDRs = nil
+	if cdrexp != nil {
+		for _, cdr := range cdrexp.FailedCdrs() {
+			jobState.FailedCDRs = append(jobState.FailedCDRs, utils.ConcatenatedKey(cdr.CGRID, cdr.RunID))
+		}
+	}
+	if len(jobState.FailedCDRs) != 0 {
+		utils.Logger.Warning(fmt.Sprintf("<CDRE> Export job: %s, could not export CDRs: %+v, retrying on next run", job.ID, jobState.FailedCDRs))
+	}
+	jobState.LastOrderID = lastOrderID
+	jobState.RangeEnd = rangeEnd
+	jobState.LastRun = now
+	if len(reply.ExportedFilePath) != 0 {
+		jobState.LastFile = reply.ExportedFilePath
+	}
+	if err := self.AccountDb.SetCdrExportJobState(jobState); err != nil {
+		utils.Logger.Err(fmt.Sprintf("<CDRE> Export job: %s, cannot save state: %s", job.ID, err.Error()))
+		return utils.NewErrServerError(err)
+	}
+	utils.Logger.Info(fmt.Sprintf("<CDRE> Export job: %s, exported %d CDRs to: %s, last OrderID: %d",
+		job.ID, reply.TotalRecords, reply.ExportedFilePath, lastOrderID))
+	return nil
+}
+
+// Fetches the CDRs which failed in previous runs, out of their CGRID:RunID keys
+func (self *ApierV2) cdrExportJobRetries(failedCDRs []string) ([]*engine.CDR, error) {
+	if len(failedCDRs) == 0 {
+		return nil, nil
+	}
+	isFailed := make(map[string]bool)
+	var cgrIDs []string
+	for _, cdrKey := range failedCDRs {
+		isFailed[cdrKey] = true
+		if cgrID := strings.Split(cdrKey, utils.CONCATENATED_KEY_SEP)[0]; !utils.IsSliceMember(cgrIDs, cgrID) {
+			cgrIDs = append(cgrIDs, cgrID)
+		}
+	}
+	cdrs, _, err := self.CdrDb.GetCDRs(&utils.CDRsFilter{CGRIDs: cgrIDs}, false)
+	if err != nil {
+		return nil, err
+	}
+	var retryCDRs []*engine.CDR
+	for _, cdr := range cdrs { // Other runs of the same CGRID were exported already
+		if isFailed[utils.ConcatenatedKey(cdr.CGRID, cdr.RunID)] {
+			retryCDRs = append(retryCDRs, cdr)
+		}
+	}
+	return retryCDRs, nil
+}
@@ -0,0 +1,222 @@
+/*
+Real-time Charging System for Telecom & ISP environments
+Copyright (C) 2012-2015 ITsysCOM GmbH
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>
+*/
+
+package v2
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cgrates/cgrates/apier/v1"
+	"github.com/cgrates/cgrates/config"
+	"github.com/cgrates/cgrates/engine"
+	"github.com/cgrates/cgrates/utils"
+)
+
+// CdrStorage keeping the CDRs in memory, filters only on CGRID, OrderID and creation time
+type cdreJobsCdrStorage struct {
+	engine.CdrStorage
+	cdrs      []*engine.CDR
+	createdAt map[int64]time.Time
+}
+
+func (cdrDb *cdreJobsCdrStorage) addCDR(orderID int64, createdAt time.Time) {
+	cdrDb.cdrs = append(cdrDb.cdrs, &engine.CDR{CGRID: utils.Sha1(createdAt.String()), OrderID: orderID, RunID: utils.META_DEFAULT,
+		ToR: utils.VOICE, OriginID: "dsafdsaf", RequestType: utils.META_RATED, Direction: utils.OUT, Tenant: "cgrates.org",
+		Category: "call", Account: "1001", Subject: "1001", Destination: "1002", SetupTime: createdAt, AnswerTime: createdAt,
+		Usage: time.Duration(10) * time.Second, ExtraFields: map[string]string{}, Cost: 1.01})
+	cdrDb.createdAt[orderID] = createdAt
+}
+
+func (cdrDb *cdreJobsCdrStorage) GetCDRs(fltr *utils.CDRsFilter, remove bool) (cdrs []*engine.CDR, cnt int64, err error) {
+	for _, cdr := range cdrDb.cdrs {
+		createdAt := cdrDb.createdAt[cdr.OrderID]
+		if (len(fltr.CGRIDs) != 0 && !utils.IsSliceMember(fltr.CGRIDs, cdr.CGRID)) ||
+			(fltr.OrderIDStart != nil && cdr.OrderID < *fltr.OrderIDStart) ||
+			(fltr.CreatedAtStart != nil && createdAt.Before(*fltr.CreatedAtStart)) ||
+			(fltr.CreatedAtEnd != nil && !createdAt.Before(*fltr.CreatedAtEnd)) {
+			continue
+		}
+		cdrs = append(cdrs, cdr.Clone())
+	}
+	return
+}
+
+func TestRunCdrExportJob(t *testing.T) {
+	exportDir, err := ioutil.TempDir("", "cdre_jobs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(exportDir)
+	cfg, _ := config.NewDefaultCGRConfig()
+	cfg.DefaultTimezone = "UTC"
+	cfg.SchedulerCdrExports = []*config.CdrExportJobCfg{&config.CdrExportJobCfg{ID: "daily", ExportTemplate: utils.META_DEFAULT,
+		Timing: utils.NewTiming("daily", utils.ANY, utils.ANY, utils.ANY, utils.ANY, "00:05:00"), TimeRange: utils.MetaYesterday,
+		ExportFolder: exportDir, FileName: `{{.JobID}}_{{.RangeStart.Format "20060102"}}.csv`}}
+	cdrDb := &cdreJobsCdrStorage{createdAt: make(map[int64]time.Time)}
+	cdrDb.addCDR(1, time.Date(2016, 6, 30, 23, 0, 0, 0, time.UTC)) // before the first range
+	cdrDb.addCDR(2, time.Date(2016, 7, 1, 10, 0, 0, 0, time.UTC))
+	cdrDb.addCDR(3, time.Date(2016, 7, 1, 20, 0, 0, 0, time.UTC))
+	cdrDb.addCDR(4, time.Date(2016, 7, 2, 0, 1, 0, 0, time.UTC)) // after the end of the first range
+	accountDb, _ := engine.NewMapStorage()
+	apierV2 := &ApierV2{ApierV1: v1.ApierV1{CdrDb: cdrDb, AccountDb: accountDb, Config: cfg}}
+	job := cfg.SchedulerCdrExports[0]
+	var reply utils.ExportedFileCdrs
+	if err := apierV2.runCdrExportJob(job, time.Date(2016, 7, 2, 0, 5, 0, 0, time.UTC), &reply); err != nil {
+		t.Fatal(err)
+	} else if eFile := path.Join(exportDir, "daily_20160701.csv"); reply.ExportedFilePath != eFile || reply.TotalRecords != 2 ||
+		reply.FirstOrderId != 2 || reply.LastOrderId != 3 {
+		t.Errorf("Received: %s", utils.ToJSON(reply))
+	}
+	if jobState, err := accountDb.GetCdrExportJobState("daily"); err != nil {
+		t.Error(err)
+	} else if jobState.LastOrderID != 3 || jobState.LastFile != path.Join(exportDir, "daily_20160701.csv") {
+		t.Errorf("Received: %s", utils.ToJSON(jobState))
+	}
+	// Running again in the same day does not export twice
+	reply = utils.ExportedFileCdrs{}
+	if err := apierV2.runCdrExportJob(job, time.Date(2016, 7, 2, 10, 0, 0, 0, time.UTC), &reply); err != nil {
+		t.Fatal(err)
+	} else if reply.ExportedFilePath != "" {
+		t.Errorf("Received: %s", utils.ToJSON(reply))
+	}
+	// Next day picks what was created since, without the range start
+	cdrDb.addCDR(5, time.Date(2016, 7, 2, 12, 0, 0, 0, time.UTC))
+	cdrDb.addCDR(6, time.Date(2016, 7, 3, 0, 2, 0, 0, time.UTC))
+	reply = utils.ExportedFileCdrs{}
+	if err := apierV2.runCdrExportJob(job, time.Date(2016, 7, 3, 0, 5, 0, 0, time.UTC), &reply); err != nil {
+		t.Fatal(err)
+	} else if eFile := path.Join(exportDir, "daily_20160702.csv"); reply.ExportedFilePath != eFile || reply.TotalRecords != 2 ||
+		reply.FirstOrderId != 4 || reply.LastOrderId != 5 {
+		t.Errorf("Received: %s", utils.ToJSON(reply))
+	}
+	if content, err := ioutil.ReadFile(path.Join(exportDir, "daily_20160702.csv")); err != nil {
+		t.Error(err)
+	} else if lines := strings.Split(strings.TrimSpace(string(content)), "\n"); len(lines) != 2 {
+		t.Errorf("Exported: %s", content)
+	}
+	if jobState, err := accountDb.GetCdrExportJobState("daily"); err != nil {
+		t.Error(err)
+	} else if jobState.LastOrderID != 5 {
+		t.Errorf("Received: %s", utils.ToJSON(jobState))
+	}
+	if err := apierV2.RunCdrExportJob(AttrRunCdrExportJob{JobID: "weekly"}, &reply); err != utils.ErrNotFound {
+		t.Error("Expecting not found, received: ", err)
+	}
+}
+
+func TestRunCdrExportJobUnexported(t *testing.T) {
+	exportDir, err := ioutil.TempDir("", "cdre_jobs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(exportDir)
+	cfg, _ := config.NewDefaultCGRConfig()
+	cfg.DefaultTimezone = "UTC"
+	expTpl := cfg.CdreProfiles[utils.META_DEFAULT].Clone()
+	expTpl.ContentFields = append(expTpl.ContentFields, &config.CfgCdrField{Tag: "Expire", Type: utils.MetaDateTime,
+		Value: utils.ParseRSRFieldsMustCompile("expire", utils.INFIELD_SEP)})
+	cfg.CdreProfiles["expire"] = expTpl
+	cfg.SchedulerCdrExports = []*config.CdrExportJobCfg{&config.CdrExportJobCfg{ID: "hourly", ExportTemplate: "expire",
+		Timing: utils.NewTiming("hourly", utils.ANY, utils.ANY, utils.ANY, utils.ANY, "*every 1h"), ExportFolder: exportDir}}
+	cdrDb := &cdreJobsCdrStorage{createdAt: make(map[int64]time.Time)}
+	cdrDb.addCDR(1, time.Date(2016, 7, 1, 10, 0, 0, 0, time.UTC))
+	cdrDb.addCDR(2, time.Date(2016, 7, 1, 11, 0, 0, 0, time.UTC))
+	cdrDb.addCDR(3, time.Date(2016, 7, 1, 12, 0, 0, 0, time.UTC))
+	cdrDb.cdrs[1].ExtraFields["expire"] = "invalid" // Fails the export of CDR 2
+	accountDb, _ := engine.NewMapStorage()
+	apierV2 := &ApierV2{ApierV1: v1.ApierV1{CdrDb: cdrDb, AccountDb: accountDb, Config: cfg}}
+	job := cfg.SchedulerCdrExports[0]
+	var reply utils.ExportedFileCdrs
+	if err := apierV2.runCdrExportJob(job, time.Date(2016, 7, 1, 13, 0, 0, 0, time.UTC), &reply); err != nil {
+		t.Fatal(err)
+	} else if reply.TotalRecords != 3 || len(reply.UnexportedCgrIds) != 1 || reply.FirstOrderId != 1 || reply.LastOrderId != 3 {
+		t.Errorf("Received: %s", utils.ToJSON(reply))
+	}
+	eFailed := []string{utils.ConcatenatedKey(cdrDb.cdrs[1].CGRID, utils.META_DEFAULT)}
+	if jobState, err := accountDb.GetCdrExportJobState("hourly"); err != nil {
+		t.Error(err)
+	} else if jobState.LastOrderID != 3 || !reflect.DeepEqual(eFailed, jobState.FailedCDRs) {
+		t.Errorf("Received: %s", utils.ToJSON(jobState))
+	}
+	// Failing CDR does not hold back the new ones and these are not exported twice
+	cdrDb.addCDR(4, time.Date(2016, 7, 1, 13, 30, 0, 0, time.UTC))
+	reply = utils.ExportedFileCdrs{}
+	if err := apierV2.runCdrExportJob(job, time.Date(2016, 7, 1, 14, 0, 0, 0, time.UTC), &reply); err != nil {
+		t.Fatal(err)
+	} else if reply.FirstOrderId != 4 || reply.LastOrderId != 4 || len(reply.UnexportedCgrIds) != 1 {
+		t.Errorf("Received: %s", utils.ToJSON(reply))
+	}
+	if jobState, err := accountDb.GetCdrExportJobState("hourly"); err != nil {
+		t.Error(err)
+	} else if jobState.LastOrderID != 4 || !reflect.DeepEqual(eFailed, jobState.FailedCDRs) {
+		t.Errorf("Received: %s", utils.ToJSON(jobState))
+	}
+	// Once fixed, the failed CDR is exported alone
+	cdrDb.cdrs[1].ExtraFields["expire"] = ""
+	reply = utils.ExportedFileCdrs{}
+	if err := apierV2.runCdrExportJob(job, time.Date(2016, 7, 1, 15, 0, 0, 0, time.UTC), &reply); err != nil {
+		t.Fatal(err)
+	} else if reply.TotalRecords != 1 || reply.FirstOrderId != 2 || reply.LastOrderId != 2 || len(reply.UnexportedCgrIds) != 0 {
+		t.Errorf("Received: %s", utils.ToJSON(reply))
+	}
+	if jobState, err := accountDb.GetCdrExportJobState("hourly"); err != nil {
+		t.Error(err)
+	} else if jobState.LastOrderID != 4 || len(jobState.FailedCDRs) != 0 {
+		t.Errorf("Received: %s", utils.ToJSON(jobState))
+	}
+}
+
+func TestRunCdrExportJobEmptyFirstRun(t *testing.T) {
+	exportDir, err := ioutil.TempDir("", "cdre_jobs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(exportDir)
+	cfg, _ := config.NewDefaultCGRConfig()
+	cfg.DefaultTimezone = "UTC"
+	cfg.SchedulerCdrExports = []*config.CdrExportJobCfg{&config.CdrExportJobCfg{ID: "daily", ExportTemplate: utils.META_DEFAULT,
+		Timing: utils.NewTiming("daily", utils.ANY, utils.ANY, utils.ANY, utils.ANY, "00:05:00"), TimeRange: utils.MetaYesterday,
+		ExportFolder: exportDir}}
+	cdrDb := &cdreJobsCdrStorage{createdAt: make(map[int64]time.Time)}
+	accountDb, _ := engine.NewMapStorage()
+	apierV2 := &ApierV2{ApierV1: v1.ApierV1{CdrDb: cdrDb, AccountDb: accountDb, Config: cfg}}
+	job := cfg.SchedulerCdrExports[0]
+	var reply utils.ExportedFileCdrs
+	if err := apierV2.runCdrExportJob(job, time.Date(2016, 7, 2, 0, 5, 0, 0, time.UTC), &reply); err != nil {
+		t.Fatal(err)
+	}
+	if jobState, err := accountDb.GetCdrExportJobState("daily"); err != nil {
+		t.Error(err)
+	} else if !jobState.RangeEnd.Equal(time.Date(2016, 7, 2, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("Received: %s", utils.ToJSON(jobState))
+	}
+	// Run of 3rd July missed, the next one still picks the CDRs created on 2nd
+	cdrDb.addCDR(1, time.Date(2016, 7, 2, 10, 0, 0, 0, time.UTC))
+	reply = utils.ExportedFileCdrs{}
+	if err := apierV2.runCdrExportJob(job, time.Date(2016, 7, 4, 0, 5, 0, 0, time.UTC), &reply); err != nil {
+		t.Fatal(err)
+	} else if reply.TotalRecords != 1 || reply.FirstOrderId != 1 {
+		t.Errorf("Received: %s", utils.ToJSON(reply))
+	}
+}
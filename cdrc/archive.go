@@ -0,0 +1,198 @@
+/*
+Real-time Charging System for Telecom & ISP environments
+Copyright (C) ITsysCOM GmbH
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>
+*/
+
+package cdrc
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+)
+
+// Compressed and archived CDR files, detected out of magic bytes with fallback on file extension
+const (
+	GZIP_FORMAT = "gzip"
+	ZIP_FORMAT  = "zip"
+	TAR_FORMAT  = "tar"
+)
+
+var (
+	gzipMagic     = []byte{0x1f, 0x8b}
+	zipMagic      = []byte("PK\x03\x04")
+	tarMagic      = []byte("ustar")
+	tarMagicIdx   = 257
+	tarHeaderSize = 512
+)
+
+// Detects the compression or archive format out of the first bytes of a file and it's name, empty for plain files
+func archiveFormat(fileName string, header []byte) string {
+	switch {
+	case bytes.HasPrefix(header, gzipMagic):
+		return GZIP_FORMAT
+	case bytes.HasPrefix(header, zipMagic):
+		return ZIP_FORMAT
+	case len(header) >= tarMagicIdx+len(tarMagic) && bytes.Equal(header[tarMagicIdx:tarMagicIdx+len(tarMagic)], tarMagic):
+		return TAR_FORMAT
+	}
+	switch strings.ToLower(path.Ext(fileName)) {
+	case ".gz", ".tgz":
+		return GZIP_FORMAT
+	case ".zip":
+		return ZIP_FORMAT
+	case ".tar":
+		return TAR_FORMAT
+	}
+	return ""
+}
+
+// Name of the content within a gzip file, eg: cdrs.csv out of cdrs.csv.gz
+func gunzippedName(fileName string) string {
+	switch strings.ToLower(path.Ext(fileName)) {
+	case ".gz":
+		return fileName[:len(fileName)-len(".gz")]
+	case ".tgz":
+		return fileName[:len(fileName)-len(".tgz")] + ".tar"
+	}
+	return fileName
+}
+
+// Keeps the first read error so corrupted content is reported after processing, records processors see only io.EOF
+type memberReader struct {
+	rdr io.Reader
+	err error
+}
+
+func (mr *memberReader) Read(p []byte) (n int, err error) {
+	if mr.err != nil {
+		return 0, io.EOF
+	}
+	n, err = mr.rdr.Read(p)
+	if err != nil && err != io.EOF {
+		mr.err = err
+		err = io.EOF
+	}
+	return
+}
+
+// Calls processMember for every CDR file out of file, one call for plain files or one per member, in order, for archives
+// Plain files are passed unchanged so processors needing seek support can use them
+func walkCdrFile(file *os.File, fileName string, processMember func(memberName string, rdr io.Reader) error) error {
+	header := make([]byte, tarHeaderSize)
+	nRead, err := io.ReadFull(file, header)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return err
+	}
+	if _, err := file.Seek(0, 0); err != nil {
+		return err
+	}
+	switch archiveFormat(fileName, header[:nRead]) {
+	case GZIP_FORMAT:
+		gzRdr, err := gzip.NewReader(file)
+		if err != nil {
+			return err
+		}
+		defer gzRdr.Close()
+		gzName := gunzippedName(fileName)
+		bufRdr := bufio.NewReaderSize(gzRdr, tarHeaderSize)
+		if innerHeader, _ := bufRdr.Peek(tarHeaderSize); archiveFormat(gzName, innerHeader) == TAR_FORMAT {
+			if err := walkTar(bufRdr, processMember); err != nil {
+				return err
+			}
+			_, err := io.Copy(ioutil.Discard, bufRdr) // Tar stops before the end of the gzip stream, read it out so the checksum is verified
+			return err
+		}
+		return processArchiveMember(gzName, bufRdr, processMember)
+	case ZIP_FORMAT:
+		fi, err := file.Stat()
+		if err != nil {
+			return err
+		}
+		zipRdr, err := zip.NewReader(file, fi.Size())
+		if err != nil {
+			return err
+		}
+		for _, zipFile := range zipRdr.File {
+			if zipFile.FileInfo().IsDir() {
+				continue
+			}
+			zipFileRdr, err := zipFile.Open()
+			if err != nil {
+				return err
+			}
+			err = processArchiveMember(zipFile.Name, zipFileRdr, processMember)
+			zipFileRdr.Close()
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	case TAR_FORMAT:
+		return walkTar(file, processMember)
+	}
+	return processMember(fileName, file)
+}
+
+func walkTar(rdr io.Reader, processMember func(memberName string, rdr io.Reader) error) error {
+	tarRdr := tar.NewReader(rdr)
+	for {
+		hdr, err := tarRdr.Next()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if hdr.Typeflag != tar.TypeReg && hdr.Typeflag != tar.TypeRegA {
+			continue
+		}
+		if err := processArchiveMember(hdr.Name, tarRdr, processMember); err != nil {
+			return err
+		}
+	}
+}
+
+func processArchiveMember(memberName string, rdr io.Reader, processMember func(memberName string, rdr io.Reader) error) error {
+	mbrRdr := &memberReader{rdr: rdr}
+	if err := processMember(path.Base(memberName), mbrRdr); err != nil {
+		return err
+	}
+	return mbrRdr.err
+}
+
+// Copies the content of rdr into a temporary file, positioned at start, for processors requiring a real file
+func spoolToTempFile(rdr io.Reader) (*os.File, error) {
+	tmpFile, err := ioutil.TempFile("", "cgr_cdrc_")
+	if err != nil {
+		return nil, err
+	}
+	if _, err = io.Copy(tmpFile, rdr); err == nil {
+		_, err = tmpFile.Seek(0, 0)
+	}
+	if err != nil {
+		tmpFile.Close()
+		os.Remove(tmpFile.Name())
+		return nil, err
+	}
+	return tmpFile, nil
+}
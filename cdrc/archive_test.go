@@ -0,0 +1,262 @@
+/*
+Real-time Charging System for Telecom & ISP environments
+Copyright (C) ITsysCOM GmbH
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>
+*/
+
+package cdrc
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"reflect"
+	"testing"
+
+	"github.com/cgrates/cgrates/config"
+	"github.com/cgrates/cgrates/engine"
+	"github.com/cgrates/cgrates/utils"
+)
+
+var archiveCsv1 = "ignored,ignored,*voice,acc1,*prepaid,*out,cgrates.org,call,1001,1001,+4986517174963,2013-02-03 19:50:00,2013-02-03 19:54:00,62\n"
+var archiveCsv2 = "ignored,ignored,*voice,acc2,*prepaid,*out,cgrates.org,call,1002,1002,+4986517174964,2013-02-03 20:50:00,2013-02-03 20:54:00,10\n" +
+	"ignored,ignored,*voice,acc3,*prepaid,*out,cgrates.org,call,1003,1003,+4986517174965,2013-02-03 21:50:00,2013-02-03 21:54:00,20\n"
+
+func gzipContent(t *testing.T, content []byte) []byte {
+	var buf bytes.Buffer
+	gzWrtr := gzip.NewWriter(&buf)
+	if _, err := gzWrtr.Write(content); err != nil {
+		t.Fatal(err)
+	}
+	if err := gzWrtr.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func tarContent(t *testing.T, names []string, contents []string) []byte {
+	var buf bytes.Buffer
+	tarWrtr := tar.NewWriter(&buf)
+	if err := tarWrtr.WriteHeader(&tar.Header{Name: "cdrs/", Typeflag: tar.TypeDir, Mode: 0755}); err != nil {
+		t.Fatal(err)
+	}
+	for i, name := range names {
+		if err := tarWrtr.WriteHeader(&tar.Header{Name: name, Typeflag: tar.TypeReg, Mode: 0644, Size: int64(len(contents[i]))}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tarWrtr.Write([]byte(contents[i])); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tarWrtr.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func zipContent(t *testing.T, names []string, contents []string) []byte {
+	var buf bytes.Buffer
+	zipWrtr := zip.NewWriter(&buf)
+	if _, err := zipWrtr.Create("cdrs/"); err != nil {
+		t.Fatal(err)
+	}
+	for i, name := range names {
+		fWrtr, err := zipWrtr.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := fWrtr.Write([]byte(contents[i])); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zipWrtr.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// Writes content in a temporary folder, returning the path to it
+func writeTmpCdrFile(t *testing.T, dir, fileName string, content []byte) string {
+	fPath := path.Join(dir, fileName)
+	if err := ioutil.WriteFile(fPath, content, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return fPath
+}
+
+// Collects the members out of a file as seen by the records processors
+func walkedMembers(fPath string) (names []string, contents []string, err error) {
+	file, err := os.Open(fPath)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer file.Close()
+	err = walkCdrFile(file, path.Base(fPath), func(memberName string, rdr io.Reader) error {
+		content, err := ioutil.ReadAll(rdr)
+		if err != nil {
+			return err
+		}
+		names = append(names, memberName)
+		contents = append(contents, string(content))
+		return nil
+	})
+	return
+}
+
+func TestArchiveFormat(t *testing.T) {
+	if aFmt := archiveFormat("cdrs.csv", []byte(archiveCsv1)); aFmt != "" {
+		t.Errorf("Received: %s", aFmt)
+	}
+	if aFmt := archiveFormat("cdrs.csv", gzipContent(t, []byte(archiveCsv1))); aFmt != GZIP_FORMAT {
+		t.Errorf("Received: %s", aFmt)
+	}
+	if aFmt := archiveFormat("cdrs", zipContent(t, []string{"cdrs.csv"}, []string{archiveCsv1})); aFmt != ZIP_FORMAT {
+		t.Errorf("Received: %s", aFmt)
+	}
+	if aFmt := archiveFormat("cdrs", tarContent(t, []string{"cdrs.csv"}, []string{archiveCsv1})); aFmt != TAR_FORMAT {
+		t.Errorf("Received: %s", aFmt)
+	}
+	if aFmt := archiveFormat("cdrs.CSV.GZ", nil); aFmt != GZIP_FORMAT {
+		t.Errorf("Received: %s", aFmt)
+	}
+	if gzName := gunzippedName("cdrs.csv.gz"); gzName != "cdrs.csv" {
+		t.Errorf("Received: %s", gzName)
+	}
+	if gzName := gunzippedName("cdrs.tgz"); gzName != "cdrs.tar" {
+		t.Errorf("Received: %s", gzName)
+	}
+}
+
+func TestWalkCdrFile(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "cdrc_archive")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+	eNames := []string{"cdrs1.csv", "cdrs2.csv"}
+	eContents := []string{archiveCsv1, archiveCsv2}
+	tarredNames := []string{"cdrs/cdrs1.csv", "cdrs/cdrs2.csv"}
+	for _, fPath := range []string{
+		writeTmpCdrFile(t, tmpDir, "cdrs.zip", zipContent(t, tarredNames, eContents)),
+		writeTmpCdrFile(t, tmpDir, "cdrs.tar", tarContent(t, tarredNames, eContents)),
+		writeTmpCdrFile(t, tmpDir, "cdrs.tar.gz", gzipContent(t, tarContent(t, tarredNames, eContents))),
+		writeTmpCdrFile(t, tmpDir, "cdrs_archive", gzipContent(t, tarContent(t, tarredNames, eContents))), // magic bytes only
+	} {
+		if names, contents, err := walkedMembers(fPath); err != nil {
+			t.Errorf("File: %s, error: %v", fPath, err)
+		} else if !reflect.DeepEqual(eNames, names) {
+			t.Errorf("File: %s, expecting: %+v, received: %+v", fPath, eNames, names)
+		} else if !reflect.DeepEqual(eContents, contents) {
+			t.Errorf("File: %s, expecting: %+v, received: %+v", fPath, eContents, contents)
+		}
+	}
+	fPath := writeTmpCdrFile(t, tmpDir, "cdrs1.csv.gz", gzipContent(t, []byte(archiveCsv1)))
+	if names, contents, err := walkedMembers(fPath); err != nil {
+		t.Error(err)
+	} else if !reflect.DeepEqual([]string{"cdrs1.csv"}, names) || !reflect.DeepEqual([]string{archiveCsv1}, contents) {
+		t.Errorf("Received names: %+v, contents: %+v", names, contents)
+	}
+	fPath = writeTmpCdrFile(t, tmpDir, "cdrs1.csv", []byte(archiveCsv1))
+	if names, contents, err := walkedMembers(fPath); err != nil {
+		t.Error(err)
+	} else if !reflect.DeepEqual([]string{"cdrs1.csv"}, names) || !reflect.DeepEqual([]string{archiveCsv1}, contents) {
+		t.Errorf("Received names: %+v, contents: %+v", names, contents)
+	}
+	gzipped := gzipContent(t, []byte(archiveCsv1))
+	gzipped[len(gzipped)-5] ^= 0xff // Corrupt the checksum
+	fPath = writeTmpCdrFile(t, tmpDir, "corrupted.csv.gz", gzipped)
+	if _, _, err := walkedMembers(fPath); err == nil {
+		t.Error("Expecting checksum error")
+	}
+}
+
+type archiveCdrsConn struct {
+	cdrs []*engine.CDR
+}
+
+func (conn *archiveCdrsConn) Call(serviceMethod string, args interface{}, reply interface{}) error {
+	conn.cdrs = append(conn.cdrs, args.(*engine.CDR))
+	*reply.(*string) = utils.OK
+	return nil
+}
+
+func TestCdrcProcessArchive(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "cdrc_archive")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+	inDir, outDir := path.Join(tmpDir, "in"), path.Join(tmpDir, "out")
+	for _, dir := range []string{inDir, outDir} {
+		if err := os.Mkdir(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	cgrConfig, _ := config.NewDefaultCGRConfig()
+	cdrcCfg := cgrConfig.CdrcProfiles["/var/spool/cgrates/cdrc/in"][0]
+	cdrcCfg.CdrInDir = inDir
+	cdrcCfg.CdrOutDir = outDir
+	cdrsConn := new(archiveCdrsConn)
+	cdrc := &Cdrc{cdrcCfgs: []*config.CdrcConfig{cdrcCfg}, dfltCdrcCfg: cdrcCfg, timezone: "UTC", cdrs: cdrsConn}
+	fPath := writeTmpCdrFile(t, inDir, "cdrs.zip", zipContent(t, []string{"cdrs1.csv", "cdrs2.csv"}, []string{archiveCsv1, archiveCsv2}))
+	if err := cdrc.processFile(fPath); err != nil {
+		t.Fatal(err)
+	}
+	if len(cdrsConn.cdrs) != 3 {
+		t.Fatalf("Received CDRs: %+v", cdrsConn.cdrs)
+	}
+	for i, eOriginID := range []string{"acc1", "acc2", "acc3"} {
+		if cdrsConn.cdrs[i].OriginID != eOriginID {
+			t.Errorf("Expecting: %s, received: %s", eOriginID, cdrsConn.cdrs[i].OriginID)
+		}
+	}
+	if _, err := os.Stat(path.Join(outDir, "cdrs.zip")); err != nil {
+		t.Error("Archive not moved to out folder: ", err)
+	}
+	// Corrupted archive should stay in the in folder
+	gzipped := gzipContent(t, []byte(archiveCsv1))
+	gzipped[len(gzipped)-5] ^= 0xff
+	fPath = writeTmpCdrFile(t, inDir, "corrupted.csv.gz", gzipped)
+	if err := cdrc.processFile(fPath); err == nil {
+		t.Error("Expecting error")
+	}
+	if _, err := os.Stat(fPath); err != nil {
+		t.Error("Corrupted archive moved out: ", err)
+	}
+	if _, err := os.Stat(path.Join(outDir, "corrupted.csv.gz")); !os.IsNotExist(err) {
+		t.Error("Corrupted archive in out folder: ", err)
+	}
+	// Corruption detected only after the first member was read should not post any CDR
+	cdrsConn.cdrs = nil
+	gzipped = gzipContent(t, tarContent(t, []string{"cdrs/cdrs1.csv", "cdrs/cdrs2.csv"}, []string{archiveCsv1, archiveCsv2}))
+	gzipped[len(gzipped)-5] ^= 0xff
+	fPath = writeTmpCdrFile(t, inDir, "corrupted.tar.gz", gzipped)
+	for i := 0; i < 2; i++ { // reprocessing does not post either
+		if err := cdrc.processFile(fPath); err == nil {
+			t.Error("Expecting error")
+		}
+	}
+	if len(cdrsConn.cdrs) != 0 {
+		t.Errorf("Posted CDRs: %+v", cdrsConn.cdrs)
+	}
+	if _, err := os.Stat(fPath); err != nil {
+		t.Error("Corrupted archive moved out: ", err)
+	}
+}
@@ -71,7 +71,7 @@ func NewCdrc(cdrcCfgs []*config.CdrcConfig, httpSkipTlsCheck bool, cdrs rpcclien
 	if cdrc.unpairedRecordsCache, err = NewUnpairedRecordsCache(cdrcCfg.PartialRecordCache, cdrcCfg.CdrOutDir, cdrcCfg.FieldSeparator); err != nil {
 		return nil, err
 	}
-	if cdrc.partialRecordsCache, err = NewPartialRecordsCache(cdrcCfg.PartialRecordCache, cdrcCfg.PartialCacheExpiryAction, cdrcCfg.CdrOutDir, cdrcCfg.FieldSeparator, roundDecimals, cdrc.timezone, cdrc.httpSkipTlsCheck, cdrc.cdrs); err != nil {
+	if cdrc.partialRecordsCache, err = NewPartialRecordsCache(cdrcCfg.PartialRecordCache, cdrcCfg.PartialCacheExpiryAction, cdrcCfg.CdrOutDir, cdrcCfg.CdrFormat, cdrcCfg.FieldSeparator, roundDecimals, cdrc.timezone, cdrc.httpSkipTlsCheck, cdrc.cdrs); err != nil {
 		return nil, err
 	}
 	// Before processing, make sure in and out folders exist
@@ -162,8 +162,17 @@ func (self *Cdrc) processCdrDir() error {
 	return nil
 }
 
+var cdrcFilesMetric = utils.Metrics.NewCounter("cgrates_cdrc_files_processed_total", "Files processed by CDRC, per CDRC instance and status.", "cdrc_id", "status")
+
 // Processe file at filePath and posts the valid cdr rows out of it
-func (self *Cdrc) processFile(filePath string) error {
+func (self *Cdrc) processFile(filePath string) (err error) {
+	defer func() {
+		if err != nil {
+			cdrcFilesMetric.Inc(self.dfltCdrcCfg.ID, "failed")
+		} else {
+			cdrcFilesMetric.Inc(self.dfltCdrcCfg.ID, "processed")
+		}
+	}()
 	if cap(self.maxOpenFiles) != 0 { // 0 goes for no limit
 		processFile := <-self.maxOpenFiles // Queue here for maxOpenFiles
 		defer func() { self.maxOpenFiles <- processFile }()
@@ -176,25 +185,94 @@ func (self *Cdrc) processFile(filePath string) error {
 		utils.Logger.Crit(err.Error())
 		return err
 	}
-	var recordsProcessor RecordsProcessor
+	timeStart := time.Now()
+	// Members are extracted and their processors built before posting any CDR,
+	// so a broken member does not leave the CDRs out of the previous ones posted with the file reprocessed later
+	var members []*cdrFileMember
+	defer func() {
+		for _, mbr := range members {
+			if mbr.file != file {
+				mbr.file.Close()
+				os.Remove(mbr.file.Name())
+			}
+		}
+	}()
+	if err = walkCdrFile(file, fn, func(memberName string, rdr io.Reader) error {
+		mbrFile, isFile := rdr.(*os.File)
+		if !isFile {
+			var err error
+			if mbrFile, err = spoolToTempFile(rdr); err != nil {
+				return err
+			}
+		}
+		members = append(members, &cdrFileMember{name: memberName, file: mbrFile})
+		return nil
+	}); err != nil { // Leave the file in place so it can be fixed and reprocessed
+		return err
+	}
+	for _, mbr := range members {
+		if mbr.recordsProcessor, err = self.newRecordsProcessor(mbr.file, mbr.name); err != nil {
+			return fmt.Errorf("%s: %s", mbr.name, err.Error())
+		}
+	}
+	var recordsNr int64
+	var cdrsPosted int
+	for _, mbr := range members {
+		if mbr.name != fn {
+			utils.Logger.Info(fmt.Sprintf("<Cdrc> Parsing %s out of %s", mbr.name, fn))
+		}
+		mbrRecordsNr, mbrCdrsPosted := self.processRecords(mbr.recordsProcessor)
+		recordsNr += mbrRecordsNr
+		cdrsPosted += mbrCdrsPosted
+	}
+	// Finished with file, move it to processed folder
+	newPath := path.Join(self.dfltCdrcCfg.CdrOutDir, fn)
+	if err := os.Rename(filePath, newPath); err != nil {
+		utils.Logger.Err(err.Error())
+		return err
+	}
+	utils.Logger.Info(fmt.Sprintf("Finished processing %s, moved to %s. Total records processed: %d, CDRs posted: %d, run duration: %s",
+		fn, newPath, recordsNr, cdrsPosted, time.Now().Sub(timeStart)))
+	return nil
+}
+
+// One CDR file out of the processed one, the file itself when plain or an archive member extracted into a temporary file
+type cdrFileMember struct {
+	name             string
+	file             *os.File
+	recordsProcessor RecordsProcessor
+}
+
+// Builds the records processor for one CDR file, plain or extracted out of an archive
+func (self *Cdrc) newRecordsProcessor(file *os.File, fileName string) (RecordsProcessor, error) {
 	switch self.dfltCdrcCfg.CdrFormat {
 	case CSV, FS_CSV, utils.KAM_FLATSTORE, utils.OSIPS_FLATSTORE, utils.PartialCSV:
 		csvReader := csv.NewReader(bufio.NewReader(file))
 		csvReader.Comma = self.dfltCdrcCfg.FieldSeparator
-		recordsProcessor = NewCsvRecordsProcessor(csvReader, self.timezone, fn, self.dfltCdrcCfg, self.cdrcCfgs,
-			self.httpSkipTlsCheck, self.unpairedRecordsCache, self.partialRecordsCache, self.dfltCdrcCfg.CacheDumpFields)
+		return NewCsvRecordsProcessor(csvReader, self.timezone, fileName, self.dfltCdrcCfg, self.cdrcCfgs,
+			self.httpSkipTlsCheck, self.unpairedRecordsCache, self.partialRecordsCache, self.dfltCdrcCfg.CacheDumpFields), nil
 	case utils.FWV:
-		recordsProcessor = NewFwvRecordsProcessor(file, self.dfltCdrcCfg, self.cdrcCfgs, self.httpClient, self.httpSkipTlsCheck, self.timezone)
+		return NewFwvRecordsProcessor(file, self.dfltCdrcCfg, self.cdrcCfgs, self.httpClient, self.httpSkipTlsCheck, self.timezone), nil
 	case utils.XML:
-		if recordsProcessor, err = NewXMLRecordsProcessor(file, self.dfltCdrcCfg.CDRPath, self.timezone, self.httpSkipTlsCheck, self.cdrcCfgs); err != nil {
-			return err
+		xmlProc, err := NewXMLRecordsProcessor(file, self.dfltCdrcCfg.CDRPath, self.timezone, self.httpSkipTlsCheck, self.cdrcCfgs)
+		if err != nil {
+			return nil, err
 		}
-	default:
-		return fmt.Errorf("Unsupported CDR format: %s", self.dfltCdrcCfg.CdrFormat)
+		return xmlProc, nil
+	case utils.JSON, utils.PartialJSON:
+		jsonProc, err := NewJSONRecordsProcessor(file, self.timezone, self.dfltCdrcCfg, self.cdrcCfgs,
+			self.httpSkipTlsCheck, self.partialRecordsCache, self.dfltCdrcCfg.CacheDumpFields)
+		if err != nil {
+			return nil, err
+		}
+		return jsonProc, nil
 	}
+	return nil, fmt.Errorf("Unsupported CDR format: %s", self.dfltCdrcCfg.CdrFormat)
+}
+
+// Processes the records out of one CDR file and sends resulting CDRs to CDRS
+func (self *Cdrc) processRecords(recordsProcessor RecordsProcessor) (recordsNr int64, cdrsPosted int) {
 	rowNr := 0 // This counts the rows in the file, not really number of CDRs
-	cdrsPosted := 0
-	timeStart := time.Now()
 	for {
 		cdrs, err := recordsProcessor.ProcessNextRecord()
 		if err != nil && err == io.EOF {
@@ -218,13 +296,5 @@ func (self *Cdrc) processFile(filePath string) error {
 			cdrsPosted += 1
 		}
 	}
-	// Finished with file, move it to processed folder
-	newPath := path.Join(self.dfltCdrcCfg.CdrOutDir, fn)
-	if err := os.Rename(filePath, newPath); err != nil {
-		utils.Logger.Err(err.Error())
-		return err
-	}
-	utils.Logger.Info(fmt.Sprintf("Finished processing %s, moved to %s. Total records processed: %d, CDRs posted: %d, run duration: %s",
-		fn, newPath, recordsProcessor.ProcessedRecordsNr(), cdrsPosted, time.Now().Sub(timeStart)))
-	return nil
+	return recordsProcessor.ProcessedRecordsNr(), cdrsPosted
 }
@@ -0,0 +1,253 @@
+/*
+Real-time Charging System for Telecom & ISP environments
+Copyright (C) ITsysCOM GmbH
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>
+*/
+
+package cdrc
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/cgrates/cgrates/config"
+	"github.com/cgrates/cgrates/engine"
+	"github.com/cgrates/cgrates/utils"
+)
+
+const jsonMaxLineSize = 10 * 1024 * 1024 // Longest JSON line accepted out of a file
+
+// Value of the element found at path within the JSON record, returns utils.ErrNotFound if the element is not present
+// Array elements are addressed by their index, eg: legs>0>duration
+func jsonElementValue(record interface{}, elmntPath utils.HierarchyPath) (string, error) {
+	elmnt := record
+	for _, key := range elmntPath {
+		switch node := elmnt.(type) {
+		case map[string]interface{}:
+			var hasIt bool
+			if elmnt, hasIt = node[key]; !hasIt {
+				return "", utils.ErrNotFound
+			}
+		case []interface{}:
+			idx, err := strconv.Atoi(key)
+			if err != nil || idx < 0 || idx >= len(node) {
+				return "", utils.ErrNotFound
+			}
+			elmnt = node[idx]
+		default:
+			return "", utils.ErrNotFound
+		}
+	}
+	switch val := elmnt.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return val, nil
+	case json.Number:
+		return val.String(), nil
+	case bool:
+		return strconv.FormatBool(val), nil
+	default: // Objects and arrays are returned in their JSON form
+		jsn, err := json.Marshal(val)
+		return string(jsn), err
+	}
+}
+
+// Splits the JSON value into CDR records found at cdrPath, arrays on the way are iterated
+func jsonRecords(val interface{}, cdrPath utils.HierarchyPath) (records []interface{}) {
+	switch node := val.(type) {
+	case []interface{}:
+		for _, elmnt := range node {
+			records = append(records, jsonRecords(elmnt, cdrPath)...)
+		}
+	case map[string]interface{}:
+		if len(cdrPath) == 0 {
+			return []interface{}{node}
+		}
+		if elmnt, hasIt := node[cdrPath[0]]; hasIt {
+			return jsonRecords(elmnt, cdrPath[1:])
+		}
+	}
+	return
+}
+
+func NewJSONRecordsProcessor(recordsReader io.Reader, timezone string, dfltCdrcCfg *config.CdrcConfig, cdrcCfgs []*config.CdrcConfig,
+	httpSkipTlsCheck bool, partialRecordsCache *PartialRecordsCache, cacheDumpFields []*config.CfgCdrField) (*JSONRecordsProcessor, error) {
+	var cdrPath utils.HierarchyPath
+	for _, elmnt := range dfltCdrcCfg.CDRPath {
+		if len(elmnt) != 0 { // Empty cdr_path parses to one empty element
+			cdrPath = append(cdrPath, elmnt)
+		}
+	}
+	bufReader := bufio.NewReader(recordsReader)
+	jsonProc := &JSONRecordsProcessor{cdrPath: cdrPath, timezone: timezone, dfltCdrcCfg: dfltCdrcCfg, cdrcCfgs: cdrcCfgs,
+		httpSkipTlsCheck: httpSkipTlsCheck, partialRecordsCache: partialRecordsCache, partialCacheDumpFields: cacheDumpFields}
+	// A top level array is streamed element by element, otherwise the file holds one object per line (JSON Lines)
+	for {
+		b, err := bufReader.ReadByte()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		if strings.ContainsRune(" \t\r\n", rune(b)) {
+			continue
+		}
+		if err := bufReader.UnreadByte(); err != nil {
+			return nil, err
+		}
+		jsonProc.inArray = b == '['
+		break
+	}
+	if !jsonProc.inArray {
+		jsonProc.lineScanner = bufio.NewScanner(bufReader)
+		jsonProc.lineScanner.Buffer(make([]byte, bufio.MaxScanTokenSize), jsonMaxLineSize)
+		return jsonProc, nil
+	}
+	jsonProc.decoder = json.NewDecoder(bufReader)
+	jsonProc.decoder.UseNumber() // Keep numbers as they are written in the file
+	if _, err := jsonProc.decoder.Token(); err != nil {
+		return nil, err // Opening bracket could not be consumed
+	}
+	return jsonProc, nil
+}
+
+// Decodes the JSON value on one line, keeping numbers as they are written
+func decodeJSONLine(line []byte) (val interface{}, err error) {
+	decoder := json.NewDecoder(bytes.NewReader(line))
+	decoder.UseNumber()
+	if err = decoder.Decode(&val); err != nil {
+		return nil, err
+	}
+	if decoder.More() {
+		return nil, errors.New("unexpected content after JSON value")
+	}
+	return
+}
+
+// Processes files containing an array of JSON CDR objects or one object per line
+type JSONRecordsProcessor struct {
+	decoder                *json.Decoder       // decodes the top level array
+	inArray                bool                // streaming the elements of a top level array
+	decodeErr              error               // decoding the array cannot recover after errors
+	lineScanner            *bufio.Scanner      // reads the JSON Lines files, a broken line does not affect the next ones
+	lineNr                 int64               // last line read, reported in errors
+	records                []interface{}       // CDR records extracted out of the last decoded JSON value
+	cdrPath                utils.HierarchyPath // path towards CDR objects
+	processedRecordsNr     int64
+	timezone               string
+	dfltCdrcCfg            *config.CdrcConfig
+	cdrcCfgs               []*config.CdrcConfig
+	httpSkipTlsCheck       bool
+	partialRecordsCache    *PartialRecordsCache
+	partialCacheDumpFields []*config.CfgCdrField
+}
+
+func (self *JSONRecordsProcessor) ProcessedRecordsNr() int64 {
+	return self.processedRecordsNr
+}
+
+// Returns the next CDR record, decoding a new JSON value out of file when needed
+func (self *JSONRecordsProcessor) nextRecord() (interface{}, error) {
+	for len(self.records) == 0 {
+		if self.decodeErr != nil {
+			return nil, io.EOF // Error was already returned, stop processing the file
+		}
+		var val interface{}
+		if self.lineScanner != nil {
+			if !self.lineScanner.Scan() {
+				if err := self.lineScanner.Err(); err != nil {
+					self.decodeErr = err
+					return nil, err
+				}
+				return nil, io.EOF
+			}
+			self.lineNr += 1
+			line := bytes.TrimSpace(self.lineScanner.Bytes())
+			if len(line) == 0 {
+				continue
+			}
+			var err error
+			if val, err = decodeJSONLine(line); err != nil {
+				return nil, fmt.Errorf("Line %d, error: %s", self.lineNr, err.Error())
+			}
+			self.records = jsonRecords(val, self.cdrPath)
+			continue
+		}
+		if self.inArray && !self.decoder.More() {
+			if _, err := self.decoder.Token(); err != nil { // Consume the closing bracket
+				self.decodeErr = err
+				return nil, err
+			}
+			self.inArray = false
+		}
+		if err := self.decoder.Decode(&val); err != nil {
+			if err != io.EOF {
+				self.decodeErr = err
+			}
+			return nil, err
+		}
+		self.records = jsonRecords(val, self.cdrPath)
+	}
+	record := self.records[0]
+	self.records = self.records[1:]
+	return record, nil
+}
+
+// Value of the field with path in template, paths starting with cdr_path are considered absolute, otherwise relative to the CDR object
+func (self *JSONRecordsProcessor) fieldValue(record interface{}, fldPath string) (string, error) {
+	elmntPath := utils.ParseHierarchyPath(fldPath, "")
+	if len(self.cdrPath) != 0 && len(elmntPath) > len(self.cdrPath) &&
+		utils.HierarchyPath(elmntPath[:len(self.cdrPath)]).AsString(utils.HIERARCHY_SEP, false) == self.cdrPath.AsString(utils.HIERARCHY_SEP, false) {
+		elmntPath = elmntPath[len(self.cdrPath):]
+	}
+	return jsonElementValue(record, elmntPath)
+}
+
+func (self *JSONRecordsProcessor) ProcessNextRecord() ([]*engine.CDR, error) {
+	record, err := self.nextRecord()
+	if err != nil {
+		return nil, err
+	}
+	self.processedRecordsNr += 1
+	fldVal := func(fldPath string) (string, error) { return self.fieldValue(record, fldPath) }
+	recordCdrs := make([]*engine.CDR, 0)    // More CDRs based on the number of filters and field templates
+	for _, cdrcCfg := range self.cdrcCfgs { // cdrFields coming from more templates will produce individual CDRs
+		if !recordPassesFilters(fldVal, cdrcCfg.CdrFilter) {
+			continue
+		}
+		cdr, err := structuredRecordToCDR(record, fldVal, cdrcCfg, self.timezone, self.httpSkipTlsCheck)
+		if err != nil {
+			return nil, fmt.Errorf("<CDRC> Failed converting to CDR, error: %s", err.Error())
+		} else if self.dfltCdrcCfg.CdrFormat == utils.PartialJSON {
+			if cdr, err = self.partialRecordsCache.MergePartialCDRRecord(NewPartialCDRRecord(cdr, self.partialCacheDumpFields)); err != nil {
+				return nil, fmt.Errorf("Failed merging PartialCDR, error: %s", err.Error())
+			} else if cdr == nil { // CDR was absorbed by cache since it was partial
+				continue
+			}
+		}
+		recordCdrs = append(recordCdrs, cdr)
+		if !cdrcCfg.ContinueOnSuccess {
+			break
+		}
+	}
+	return recordCdrs, nil
+}
@@ -0,0 +1,264 @@
+/*
+Real-time Charging System for Telecom & ISP environments
+Copyright (C) ITsysCOM GmbH
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>
+*/
+
+package cdrc
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/cgrates/cgrates/config"
+	"github.com/cgrates/cgrates/engine"
+	"github.com/cgrates/cgrates/utils"
+)
+
+var cdrJSONArray = `[
+	{"type": "start", "callId": "dsafdsaf", "from": {"user": "1001", "domain": "cgrates.org"}, "to": "1002",
+		"setupTime": "2016-04-19T21:00:05Z", "answerTime": "2016-04-19T21:00:06Z"},
+	{"type": "stop", "callId": "dsafdsaf", "from": {"user": "1001", "domain": "cgrates.org"}, "to": "1002",
+		"setupTime": "2016-04-19T21:00:05Z", "answerTime": "2016-04-19T21:00:06Z", "releaseTime": "2016-04-19T21:00:20Z",
+		"legs": [{"duration": 14}], "cost": 0.0123456789012}
+]`
+
+var cdrJSONLines = `{"cdrs": [{"callId": "call1", "account": "1001", "duration": "10"}, {"callId": "call2", "account": "1002", "duration": "20"}]}
+{"cdrs": {"callId": "call3", "account": "1003", "duration": "30"}}
+
+{"other": {"callId": "call4"}}
+`
+
+func TestJSONElementValue(t *testing.T) {
+	var record interface{}
+	if err := json.Unmarshal([]byte(`{"from": {"user": "1001"}, "legs": [{"duration": 14}], "answered": true, "extra": null}`), &record); err != nil {
+		t.Fatal(err)
+	}
+	for path, eVal := range map[string]string{
+		"from>user":       "1001",
+		"legs>0>duration": "14",
+		"answered":        "true",
+		"extra":           "",
+		"from":            `{"user":"1001"}`,
+	} {
+		if val, err := jsonElementValue(record, utils.ParseHierarchyPath(path, "")); err != nil {
+			t.Errorf("%s: %v", path, err)
+		} else if val != eVal {
+			t.Errorf("%s, expecting: <%s>, received: <%s>", path, eVal, val)
+		}
+	}
+	for _, path := range []string{"from>domain", "legs>1>duration", "answered>value"} {
+		if _, err := jsonElementValue(record, utils.ParseHierarchyPath(path, "")); err != utils.ErrNotFound {
+			t.Errorf("%s, expecting not found, received: %v", path, err)
+		}
+	}
+}
+
+func TestJSONRPProcessArray(t *testing.T) {
+	cdrcCfg := &config.CdrcConfig{
+		ID:          "TestJSON",
+		Enabled:     true,
+		CdrFormat:   utils.JSON,
+		CDRPath:     utils.ParseHierarchyPath("", ""),
+		CdrSourceId: "TestJSON",
+		CdrFilter:   utils.ParseRSRFieldsMustCompile("type(stop)", utils.INFIELD_SEP),
+		ContentFields: []*config.CfgCdrField{
+			&config.CfgCdrField{Tag: "TOR", Type: utils.META_COMPOSED, FieldId: utils.TOR,
+				Value: utils.ParseRSRFieldsMustCompile("^*voice", utils.INFIELD_SEP), Mandatory: true},
+			&config.CfgCdrField{Tag: "OriginID", Type: utils.META_COMPOSED, FieldId: utils.ACCID,
+				Value: utils.ParseRSRFieldsMustCompile("callId", utils.INFIELD_SEP), Mandatory: true},
+			&config.CfgCdrField{Tag: "RequestType", Type: utils.META_COMPOSED, FieldId: utils.REQTYPE,
+				Value: utils.ParseRSRFieldsMustCompile("^*rated", utils.INFIELD_SEP), Mandatory: true},
+			&config.CfgCdrField{Tag: "Direction", Type: utils.META_COMPOSED, FieldId: utils.DIRECTION,
+				Value: utils.ParseRSRFieldsMustCompile("^*out", utils.INFIELD_SEP), Mandatory: true},
+			&config.CfgCdrField{Tag: "Tenant", Type: utils.META_COMPOSED, FieldId: utils.TENANT,
+				Value: utils.ParseRSRFieldsMustCompile("from>domain", utils.INFIELD_SEP), Mandatory: true},
+			&config.CfgCdrField{Tag: "Category", Type: utils.META_COMPOSED, FieldId: utils.CATEGORY,
+				Value: utils.ParseRSRFieldsMustCompile("^call", utils.INFIELD_SEP), Mandatory: true},
+			&config.CfgCdrField{Tag: "Account", Type: utils.META_COMPOSED, FieldId: utils.ACCOUNT,
+				Value: utils.ParseRSRFieldsMustCompile("from>user", utils.INFIELD_SEP), Mandatory: true},
+			&config.CfgCdrField{Tag: "Destination", Type: utils.META_COMPOSED, FieldId: utils.DESTINATION,
+				Value: utils.ParseRSRFieldsMustCompile("to", utils.INFIELD_SEP), Mandatory: true},
+			&config.CfgCdrField{Tag: "SetupTime", Type: utils.META_COMPOSED, FieldId: utils.SETUP_TIME,
+				Value: utils.ParseRSRFieldsMustCompile("setupTime", utils.INFIELD_SEP), Mandatory: true},
+			&config.CfgCdrField{Tag: "AnswerTime", Type: utils.META_COMPOSED, FieldId: utils.ANSWER_TIME,
+				Value: utils.ParseRSRFieldsMustCompile("answerTime", utils.INFIELD_SEP), Mandatory: true},
+			&config.CfgCdrField{Tag: "Usage", Type: utils.META_HANDLER, FieldId: utils.USAGE, HandlerId: utils.HandlerSubstractUsage,
+				Value: utils.ParseRSRFieldsMustCompile("releaseTime;^|;answerTime", utils.INFIELD_SEP), Mandatory: true},
+			&config.CfgCdrField{Tag: "LegDuration", Type: utils.META_COMPOSED, FieldId: "leg_duration",
+				Value: utils.ParseRSRFieldsMustCompile("legs>0>duration", utils.INFIELD_SEP)},
+			&config.CfgCdrField{Tag: "SupplierCost", Type: utils.META_COMPOSED, FieldId: "supplier_cost",
+				Value: utils.ParseRSRFieldsMustCompile("cost", utils.INFIELD_SEP)},
+		},
+	}
+	jsonRP, err := NewJSONRecordsProcessor(bytes.NewBufferString(cdrJSONArray), "UTC", cdrcCfg, []*config.CdrcConfig{cdrcCfg}, true, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cdrs, err := jsonRP.ProcessNextRecord(); err != nil { // start record is filtered out
+		t.Error(err)
+	} else if len(cdrs) != 0 {
+		t.Errorf("Received: %+v", cdrs)
+	}
+	eCDRs := []*engine.CDR{
+		&engine.CDR{CGRID: utils.Sha1("dsafdsaf", time.Date(2016, 4, 19, 21, 0, 5, 0, time.UTC).String()), OriginHost: "0.0.0.0", Source: "TestJSON",
+			OriginID: "dsafdsaf", ToR: utils.VOICE, RequestType: utils.META_RATED, Direction: utils.OUT, Tenant: "cgrates.org", Category: "call",
+			Account: "1001", Destination: "1002", SetupTime: time.Date(2016, 4, 19, 21, 0, 5, 0, time.UTC),
+			AnswerTime: time.Date(2016, 4, 19, 21, 0, 6, 0, time.UTC), Usage: time.Duration(14) * time.Second,
+			ExtraFields: map[string]string{"leg_duration": "14", "supplier_cost": "0.0123456789012"}, Cost: -1},
+	}
+	if cdrs, err := jsonRP.ProcessNextRecord(); err != nil {
+		t.Error(err)
+	} else if !reflect.DeepEqual(eCDRs, cdrs) {
+		t.Errorf("Expecting: %s, received: %s", utils.ToJSON(eCDRs), utils.ToJSON(cdrs))
+	}
+	if _, err := jsonRP.ProcessNextRecord(); err != io.EOF {
+		t.Error("Expecting EOF, received: ", err)
+	}
+	if jsonRP.ProcessedRecordsNr() != 2 {
+		t.Errorf("Processed records: %d", jsonRP.ProcessedRecordsNr())
+	}
+}
+
+func TestJSONRPProcessLines(t *testing.T) {
+	cdrcCfg := &config.CdrcConfig{
+		ID:          "TestJSONL",
+		Enabled:     true,
+		CdrFormat:   utils.JSON,
+		CDRPath:     utils.ParseHierarchyPath("cdrs", ""),
+		CdrSourceId: "TestJSONL",
+		ContentFields: []*config.CfgCdrField{
+			&config.CfgCdrField{Tag: "OriginID", Type: utils.META_COMPOSED, FieldId: utils.ACCID,
+				Value: utils.ParseRSRFieldsMustCompile("cdrs>callId", utils.INFIELD_SEP), Mandatory: true}, // absolute path
+			&config.CfgCdrField{Tag: "Account", Type: utils.META_COMPOSED, FieldId: utils.ACCOUNT,
+				Value: utils.ParseRSRFieldsMustCompile("account", utils.INFIELD_SEP), Mandatory: true}, // relative to the CDR object
+			&config.CfgCdrField{Tag: "Usage", Type: utils.META_COMPOSED, FieldId: utils.USAGE,
+				Value: utils.ParseRSRFieldsMustCompile("duration", utils.INFIELD_SEP), Mandatory: true},
+		},
+	}
+	jsonRP, err := NewJSONRecordsProcessor(bytes.NewBufferString(cdrJSONLines), "UTC", cdrcCfg, []*config.CdrcConfig{cdrcCfg}, true, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var rcvCDRs []*engine.CDR
+	for {
+		cdrs, err := jsonRP.ProcessNextRecord()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		rcvCDRs = append(rcvCDRs, cdrs...)
+	}
+	if len(rcvCDRs) != 3 {
+		t.Fatalf("Received: %s", utils.ToJSON(rcvCDRs))
+	}
+	for i, eAcnt := range []string{"1001", "1002", "1003"} {
+		if rcvCDRs[i].Account != eAcnt || rcvCDRs[i].Usage != time.Duration(10*(i+1))*time.Second {
+			t.Errorf("Received: %s", utils.ToJSON(rcvCDRs[i]))
+		}
+	}
+	if rcvCDRs[2].OriginID != "call3" {
+		t.Errorf("Received: %s", utils.ToJSON(rcvCDRs[2]))
+	}
+}
+
+func TestJSONRPMalformed(t *testing.T) {
+	cdrcCfg := &config.CdrcConfig{ID: "TestJSON", CdrFormat: utils.JSON, CDRPath: utils.ParseHierarchyPath("", ""),
+		ContentFields: []*config.CfgCdrField{&config.CfgCdrField{Tag: "OriginID", Type: utils.META_COMPOSED, FieldId: utils.ACCID,
+			Value: utils.ParseRSRFieldsMustCompile("callId", utils.INFIELD_SEP)}}}
+	jsonRP, err := NewJSONRecordsProcessor(bytes.NewBufferString(`{"callId": "call1"}
+{"callId": `), "UTC", cdrcCfg, []*config.CdrcConfig{cdrcCfg}, true, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cdrs, err := jsonRP.ProcessNextRecord(); err != nil || len(cdrs) != 1 || cdrs[0].OriginID != "call1" {
+		t.Errorf("Received: %+v, %v", cdrs, err)
+	}
+	if _, err := jsonRP.ProcessNextRecord(); err == nil || err == io.EOF {
+		t.Error("Expecting decoding error, received: ", err)
+	}
+	if _, err := jsonRP.ProcessNextRecord(); err != io.EOF { // no retries on the same broken content
+		t.Error("Expecting EOF, received: ", err)
+	}
+}
+
+func TestJSONRPMalformedLine(t *testing.T) {
+	cdrcCfg := &config.CdrcConfig{ID: "TestJSONL", CdrFormat: utils.JSON, CDRPath: utils.ParseHierarchyPath("", ""),
+		ContentFields: []*config.CfgCdrField{&config.CfgCdrField{Tag: "OriginID", Type: utils.META_COMPOSED, FieldId: utils.ACCID,
+			Value: utils.ParseRSRFieldsMustCompile("callId", utils.INFIELD_SEP)}}}
+	jsonRP, err := NewJSONRecordsProcessor(bytes.NewBufferString(`{"callId": "call1"}
+{"callId": "call2"
+{"callId": "call3"} {"callId": "call4"}
+
+{"callId": "call5"}
+`), "UTC", cdrcCfg, []*config.CdrcConfig{cdrcCfg}, true, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var rcvIDs []string
+	var errs int
+	for {
+		cdrs, err := jsonRP.ProcessNextRecord()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			errs += 1
+			continue
+		}
+		for _, cdr := range cdrs {
+			rcvIDs = append(rcvIDs, cdr.OriginID)
+		}
+	}
+	if eIDs := []string{"call1", "call5"}; !reflect.DeepEqual(eIDs, rcvIDs) || errs != 2 {
+		t.Errorf("Expecting: %+v, received: %+v, errors: %d", eIDs, rcvIDs, errs)
+	}
+}
+
+func TestJSONRPPartialRecords(t *testing.T) {
+	cdrcCfg := &config.CdrcConfig{ID: "TestPartialJSON", CdrFormat: utils.PartialJSON, CDRPath: utils.ParseHierarchyPath("", ""),
+		ContentFields: []*config.CfgCdrField{
+			&config.CfgCdrField{Tag: "OrderID", Type: utils.META_COMPOSED, FieldId: utils.ORDERID,
+				Value: utils.ParseRSRFieldsMustCompile("seq", utils.INFIELD_SEP)},
+			&config.CfgCdrField{Tag: "OriginID", Type: utils.META_COMPOSED, FieldId: utils.ACCID,
+				Value: utils.ParseRSRFieldsMustCompile("callId", utils.INFIELD_SEP)},
+			&config.CfgCdrField{Tag: "Account", Type: utils.META_COMPOSED, FieldId: utils.ACCOUNT,
+				Value: utils.ParseRSRFieldsMustCompile("account", utils.INFIELD_SEP), FieldFilter: utils.ParseRSRFieldsMustCompile("type(start)", utils.INFIELD_SEP)},
+			&config.CfgCdrField{Tag: "Usage", Type: utils.META_COMPOSED, FieldId: utils.USAGE,
+				Value: utils.ParseRSRFieldsMustCompile("duration", utils.INFIELD_SEP), FieldFilter: utils.ParseRSRFieldsMustCompile("type(stop)", utils.INFIELD_SEP)},
+			&config.CfgCdrField{Tag: "Partial", Type: utils.META_COMPOSED, FieldId: utils.PartialField,
+				Value: utils.ParseRSRFieldsMustCompile("^true", utils.INFIELD_SEP), FieldFilter: utils.ParseRSRFieldsMustCompile("type(start)", utils.INFIELD_SEP)},
+		}}
+	partialCache, _ := NewPartialRecordsCache(time.Duration(10)*time.Second, utils.MetaDumpToFile, "/tmp", utils.PartialJSON, ',', 4, "UTC", true, nil)
+	jsonRP, err := NewJSONRecordsProcessor(bytes.NewBufferString(`{"seq": 1, "type": "start", "callId": "call1", "account": "1001"}
+{"seq": 2, "type": "stop", "callId": "call1", "duration": 62}`), "UTC", cdrcCfg, []*config.CdrcConfig{cdrcCfg}, true, partialCache, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cdrs, err := jsonRP.ProcessNextRecord(); err != nil {
+		t.Error(err)
+	} else if len(cdrs) != 0 { // cached
+		t.Errorf("Received: %s", utils.ToJSON(cdrs))
+	}
+	if cdrs, err := jsonRP.ProcessNextRecord(); err != nil {
+		t.Error(err)
+	} else if len(cdrs) != 1 || cdrs[0].Account != "1001" || cdrs[0].Usage != time.Duration(62)*time.Second || cdrs[0].Partial {
+		t.Errorf("Received: %s", utils.ToJSON(cdrs))
+	}
+}
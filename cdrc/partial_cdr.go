@@ -20,6 +20,7 @@ package cdrc
 
 import (
 	"encoding/csv"
+	"encoding/json"
 	"fmt"
 	"os"
 	"path"
@@ -37,8 +38,8 @@ const (
 	PartialRecordsSuffix = "partial"
 )
 
-func NewPartialRecordsCache(ttl time.Duration, expiryAction string, cdrOutDir string, csvSep rune, roundDecimals int, timezone string, httpSkipTlsCheck bool, cdrs rpcclient.RpcClientConnection) (*PartialRecordsCache, error) {
-	return &PartialRecordsCache{ttl: ttl, expiryAction: expiryAction, cdrOutDir: cdrOutDir, csvSep: csvSep, roundDecimals: roundDecimals, timezone: timezone, httpSkipTlsCheck: httpSkipTlsCheck, cdrs: cdrs,
+func NewPartialRecordsCache(ttl time.Duration, expiryAction string, cdrOutDir string, cdrFormat string, csvSep rune, roundDecimals int, timezone string, httpSkipTlsCheck bool, cdrs rpcclient.RpcClientConnection) (*PartialRecordsCache, error) {
+	return &PartialRecordsCache{ttl: ttl, expiryAction: expiryAction, cdrOutDir: cdrOutDir, cdrFormat: cdrFormat, csvSep: csvSep, roundDecimals: roundDecimals, timezone: timezone, httpSkipTlsCheck: httpSkipTlsCheck, cdrs: cdrs,
 		partialRecords: make(map[string]*PartialCDRRecord), dumpTimers: make(map[string]*time.Timer), guard: engine.Guardian}, nil
 }
 
@@ -46,6 +47,7 @@ type PartialRecordsCache struct {
 	ttl              time.Duration
 	expiryAction     string
 	cdrOutDir        string
+	cdrFormat        string // partial_json records are dumped as JSON Lines, others as CSV
 	csvSep           rune
 	roundDecimals    int
 	timezone         string
@@ -66,14 +68,24 @@ func (prc *PartialRecordsCache) dumpPartialRecords(originID string) {
 				utils.Logger.Err(fmt.Sprintf("<Cdrc> Failed creating %s, error: %s", dumpFilePath, err.Error()))
 				return nil, err
 			}
+			defer fileOut.Close()
 			csvWriter := csv.NewWriter(fileOut)
 			csvWriter.Comma = prc.csvSep
+			cacheDumpFields := prc.partialRecords[originID].cacheDumpFields
 			for _, cdr := range prc.partialRecords[originID].cdrs {
-				expRec, err := cdr.AsExportRecord(prc.partialRecords[originID].cacheDumpFields, 0, prc.roundDecimals, prc.timezone, prc.httpSkipTlsCheck, 0, "", nil)
+				expRec, err := cdr.AsExportRecord(cacheDumpFields, 0, prc.roundDecimals, prc.timezone, prc.httpSkipTlsCheck, 0, "", nil)
 				if err != nil {
 					return nil, err
 				}
-				if err := csvWriter.Write(expRec); err != nil {
+				if prc.cdrFormat == utils.PartialJSON {
+					var jsnRec []byte
+					if jsnRec, err = jsonDumpRecord(cacheDumpFields, expRec); err == nil {
+						_, err = fileOut.Write(jsnRec)
+					}
+				} else {
+					err = csvWriter.Write(expRec)
+				}
+				if err != nil {
 					utils.Logger.Err(fmt.Sprintf("<Cdrc> Failed writing partial CDR %v to file: %s, error: %s", cdr, dumpFilePath, err.Error()))
 					return nil, err
 				}
@@ -88,6 +100,19 @@ func (prc *PartialRecordsCache) dumpPartialRecords(originID string) {
 	}
 }
 
+// One JSON object per line, keyed by the tags of the cache dump fields
+func jsonDumpRecord(cacheDumpFields []*config.CfgCdrField, expRec []string) ([]byte, error) {
+	jsnRec := make(map[string]string)
+	for idx, cfgFld := range cacheDumpFields {
+		jsnRec[cfgFld.Tag] = expRec[idx]
+	}
+	jsn, err := json.Marshal(jsnRec)
+	if err != nil {
+		return nil, err
+	}
+	return append(jsn, '\n'), nil
+}
+
 // Called when record expires in cache, will send the CDR merged (forcing it's completion) to the CDRS
 func (prc *PartialRecordsCache) postCDR(originID string) {
 	_, err := prc.guard.Guard(func() (interface{}, error) {
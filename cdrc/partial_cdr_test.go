@@ -19,11 +19,15 @@ along with this program.  If not, see <http://www.gnu.org/licenses/>
 package cdrc
 
 import (
+	"io/ioutil"
+	"os"
+	"path"
 	"reflect"
 	"sort"
 	"testing"
 	"time"
 
+	"github.com/cgrates/cgrates/config"
 	"github.com/cgrates/cgrates/engine"
 	"github.com/cgrates/cgrates/utils"
 )
@@ -66,3 +70,37 @@ func TestPartialCDRRecordMergeCDRs(t *testing.T) {
 		t.Errorf("Expecting: %+v, received: %+v", eCDR, mCdr)
 	}
 }
+
+func TestPartialRecordsCacheDumpJSON(t *testing.T) {
+	outDir, err := ioutil.TempDir("", "TestPartialRecordsCacheDumpJSON")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(outDir)
+	prc, _ := NewPartialRecordsCache(time.Duration(10)*time.Second, utils.MetaDumpToFile, outDir, utils.PartialJSON, ',', 4, "UTC", true, nil)
+	dumpFlds := []*config.CfgCdrField{
+		&config.CfgCdrField{Tag: "OriginID", Type: utils.META_COMPOSED, Value: utils.ParseRSRFieldsMustCompile(utils.ACCID, utils.INFIELD_SEP)},
+		&config.CfgCdrField{Tag: "Account", Type: utils.META_COMPOSED, Value: utils.ParseRSRFieldsMustCompile(utils.ACCOUNT, utils.INFIELD_SEP)},
+	}
+	prc.partialRecords["call1"] = &PartialCDRRecord{cdrs: []*engine.CDR{
+		&engine.CDR{OriginID: "call1", Account: "1001", Partial: true},
+		&engine.CDR{OriginID: "call1", Account: "1002", Partial: true}}, cacheDumpFields: dumpFlds}
+	prc.dumpPartialRecords("call1")
+	if _, hasIt := prc.partialRecords["call1"]; hasIt {
+		t.Error("Partial records not removed from cache")
+	}
+	fNames, err := ioutil.ReadDir(outDir)
+	if err != nil {
+		t.Fatal(err)
+	} else if len(fNames) != 1 {
+		t.Fatalf("Dumped files: %+v", fNames)
+	}
+	eOut := `{"Account":"1001","OriginID":"call1"}
+{"Account":"1002","OriginID":"call1"}
+`
+	if out, err := ioutil.ReadFile(path.Join(outDir, fNames[0].Name())); err != nil {
+		t.Error(err)
+	} else if string(out) != eOut {
+		t.Errorf("Expecting: %s, received: %s", eOut, string(out))
+	}
+}
@@ -0,0 +1,158 @@
+/*
+Real-time Charging System for Telecom & ISP environments
+Copyright (C) ITsysCOM GmbH
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>
+*/
+
+package cdrc
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cgrates/cgrates/config"
+	"github.com/cgrates/cgrates/engine"
+	"github.com/cgrates/cgrates/utils"
+)
+
+// Common processing of structured records (XML, JSON), where fields are addressed by their path within the record
+
+// Returns the value of the element at fldPath within the record being processed, utils.ErrNotFound if not present
+type recordFieldValue func(fldPath string) (string, error)
+
+// Checks the record against the filters, values are extracted using the filter Id as path
+func recordPassesFilters(fldVal recordFieldValue, filters utils.RSRFields) bool {
+	for _, rsrFltr := range filters {
+		if rsrFltr == nil {
+			continue // Pass
+		}
+		fieldVal, _ := fldVal(rsrFltr.Id)
+		if !rsrFltr.FilterPasses(fieldVal) {
+			return false
+		}
+	}
+	return true
+}
+
+// Builds the CDR out of the content fields in template, record is only used in error messages
+func structuredRecordToCDR(record interface{}, fldVal recordFieldValue, cdrcCfg *config.CdrcConfig, timezone string, httpSkipTlsCheck bool) (*engine.CDR, error) {
+	cdr := &engine.CDR{OriginHost: "0.0.0.0", Source: cdrcCfg.CdrSourceId, ExtraFields: make(map[string]string), Cost: -1}
+	var lazyHttpFields []*config.CfgCdrField
+	for _, cdrFldCfg := range cdrcCfg.ContentFields {
+		if !recordPassesFilters(fldVal, cdrFldCfg.FieldFilter) { // Stop processing this field template since it's filters are not matching
+			continue
+		}
+		var fieldVal string
+		switch cdrFldCfg.Type {
+		case utils.META_COMPOSED, utils.MetaUnixTimestamp:
+			for _, cfgFieldRSR := range cdrFldCfg.Value {
+				if cfgFieldRSR.IsStatic() {
+					fieldVal += cfgFieldRSR.ParseValue("")
+					continue
+				}
+				elmntVal, err := fldVal(cfgFieldRSR.Id)
+				if err != nil {
+					return nil, fmt.Errorf("Ignoring record: %v - cannot extract field %s, err: %s", record, cdrFldCfg.Tag, err.Error())
+				}
+				strVal := cfgFieldRSR.ParseValue(elmntVal)
+				if cdrFldCfg.Type == utils.MetaUnixTimestamp {
+					t, _ := utils.ParseTimeDetectLayout(strVal, timezone)
+					strVal = strconv.Itoa(int(t.Unix()))
+				}
+				fieldVal += strVal
+			}
+		case utils.META_HTTP_POST:
+			lazyHttpFields = append(lazyHttpFields, cdrFldCfg) // Will process later so we can send an estimation of cdr to http server
+		case utils.META_HANDLER:
+			if cdrFldCfg.HandlerId != utils.HandlerSubstractUsage {
+				return nil, fmt.Errorf("Unsupported handler: %s", cdrFldCfg.HandlerId)
+			}
+			usage, err := substractUsage(fldVal, cdrFldCfg.Value, timezone)
+			if err != nil {
+				return nil, fmt.Errorf("Ignoring record: %v - cannot extract field %s, err: %s", record, cdrFldCfg.Tag, err.Error())
+			}
+			fieldVal += strconv.FormatFloat(usage.Seconds(), 'f', -1, 64)
+		default:
+			return nil, fmt.Errorf("Unsupported field type: %s", cdrFldCfg.Type)
+		}
+		if err := cdr.ParseFieldValue(cdrFldCfg.FieldId, fieldVal, timezone); err != nil {
+			return nil, err
+		}
+	}
+	cdr.CGRID = utils.Sha1(cdr.OriginID, cdr.SetupTime.UTC().String())
+	if cdr.ToR == utils.DATA && cdrcCfg.DataUsageMultiplyFactor != 0 {
+		cdr.Usage = time.Duration(float64(cdr.Usage.Nanoseconds()) * cdrcCfg.DataUsageMultiplyFactor)
+	}
+	if err := processLazyHttpFields(cdr, lazyHttpFields, timezone, httpSkipTlsCheck); err != nil {
+		return nil, err
+	}
+	return cdr, nil
+}
+
+// Posts the CDR built so far to the http servers in template, populating the fields with their answers
+func processLazyHttpFields(cdr *engine.CDR, lazyHttpFields []*config.CfgCdrField, timezone string, httpSkipTlsCheck bool) error {
+	for _, httpFieldCfg := range lazyHttpFields {
+		var httpAddr string
+		for _, rsrFld := range httpFieldCfg.Value {
+			httpAddr += rsrFld.ParseValue("")
+		}
+		jsn, err := json.Marshal(cdr)
+		if err != nil {
+			return err
+		}
+		outValByte, err := utils.HttpJsonPost(httpAddr, httpSkipTlsCheck, jsn)
+		if err != nil && httpFieldCfg.Mandatory {
+			return err
+		}
+		fieldVal := string(outValByte)
+		if len(fieldVal) == 0 && httpFieldCfg.Mandatory {
+			return fmt.Errorf("MandatoryIeMissing: Empty result for http_post field: %s", httpFieldCfg.Tag)
+		}
+		if err := cdr.ParseFieldValue(httpFieldCfg.FieldId, fieldVal, timezone); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Usage as difference between the end and start times in template, separated by |
+func substractUsage(fldVal recordFieldValue, argsTpl utils.RSRFields, timezone string) (time.Duration, error) {
+	var argsStr string
+	for _, rsrArg := range argsTpl {
+		if rsrArg.Id == utils.HandlerArgSep {
+			argsStr += rsrArg.Id
+			continue
+		}
+		argStr, _ := fldVal(rsrArg.Id)
+		argsStr += argStr
+	}
+	handlerArgs := strings.Split(argsStr, utils.HandlerArgSep)
+	if len(handlerArgs) != 2 {
+		return time.Duration(0), errors.New("Unexpected number of arguments")
+	}
+	tEnd, err := utils.ParseTimeDetectLayout(handlerArgs[0], timezone)
+	if err != nil {
+		return time.Duration(0), err
+	}
+	tStart, err := utils.ParseTimeDetectLayout(handlerArgs[1], timezone)
+	if err != nil {
+		return time.Duration(0), err
+	}
+	return tEnd.Sub(tStart), nil
+}
@@ -19,11 +19,15 @@ along with this program.  If not, see <http://www.gnu.org/licenses/>
 package cdre
 
 import (
+	"bytes"
 	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
 	"fmt"
 	"io"
 	"os"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/cgrates/cgrates/config"
@@ -44,6 +48,11 @@ const (
 	META_DATAUSAGE     = "*data_usage"
 	META_COSTCDRS      = "*cdrs_cost"
 	META_FORMATCOST    = "*format_cost"
+
+	XML_ROOT    = "CDRs"
+	XML_HEADER  = "Header"
+	XML_CDR     = "CDR"
+	XML_TRAILER = "Trailer"
 )
 
 var err error
@@ -83,7 +92,7 @@ type CdrExporter struct {
 	cdrs           []*engine.CDR
 	cdrDb          engine.CdrStorage // Used to extract cost_details if these are requested
 	exportTemplate *config.CdreConfig
-	cdrFormat      string // csv, fwv
+	cdrFormat      string // csv, fwv, *jsonl, *xml
 	fieldSeparator rune
 	exportId       string // Unique identifier or this export
 	dataUsageMultiplyFactor,
@@ -106,6 +115,7 @@ type CdrExporter struct {
 	firstExpOrderId, lastExpOrderId int64
 	positiveExports                 []string          // CGRIDs of successfully exported CDRs
 	negativeExports                 map[string]string // CGRIDs of failed exports
+	failedCdrs                      []*engine.CDR     // CDRs which could not be exported
 }
 
 // Handle various meta functions used in header/trailer
@@ -272,6 +282,7 @@ func (cdre *CdrExporter) processCdrs() error {
 	for _, cdr := range cdre.cdrs {
 		if err := cdre.processCdr(cdr); err != nil {
 			cdre.negativeExports[cdr.CGRID] = err.Error()
+			cdre.failedCdrs = append(cdre.failedCdrs, cdr)
 		} else {
 			cdre.positiveExports = append(cdre.positiveExports, cdr.CGRID)
 		}
@@ -338,6 +349,143 @@ func (cdre *CdrExporter) writeCsv(csvWriter *csv.Writer) error {
 	return nil
 }
 
+// Nested representation of one exported record, used by *jsonl and *xml formats.
+// Field tags separated by utils.HIERARCHY_SEP define the path inside the record, eg: Account>ID
+type exportNode struct {
+	tag      string
+	value    string
+	children []*exportNode // Kept in template order
+}
+
+func newExportNode(tag string, cfgFlds []*config.CfgCdrField, fldVals []string) *exportNode {
+	root := &exportNode{tag: tag}
+	for idx, cfgFld := range cfgFlds {
+		if cfgFld.Type == utils.META_FILLER || idx >= len(fldVals) { // Fillers only make sense for positional formats
+			continue
+		}
+		node := root
+		for _, fldTag := range strings.Split(cfgFld.Tag, utils.HIERARCHY_SEP) {
+			node = node.child(fldTag)
+		}
+		node.value = fldVals[idx]
+	}
+	return root
+}
+
+// Returns the child with the tag, creating it if not already there
+func (en *exportNode) child(tag string) *exportNode {
+	for _, chld := range en.children {
+		if chld.tag == tag {
+			return chld
+		}
+	}
+	chld := &exportNode{tag: tag}
+	en.children = append(en.children, chld)
+	return chld
+}
+
+// Leafs are marshalled as strings, branches as objects with keys in template order
+func (en *exportNode) MarshalJSON() ([]byte, error) {
+	if len(en.children) == 0 {
+		return json.Marshal(en.value)
+	}
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for idx, chld := range en.children {
+		if idx != 0 {
+			buf.WriteByte(',')
+		}
+		key, err := json.Marshal(chld.tag)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(key)
+		buf.WriteByte(':')
+		val, err := chld.MarshalJSON()
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(val)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+func (en *exportNode) encodeXML(xmlEnc *xml.Encoder) error {
+	start := xml.StartElement{Name: xml.Name{Local: en.tag}}
+	if err := xmlEnc.EncodeToken(start); err != nil {
+		return err
+	}
+	if len(en.value) != 0 {
+		if err := xmlEnc.EncodeToken(xml.CharData(en.value)); err != nil {
+			return err
+		}
+	}
+	for _, chld := range en.children {
+		if err := chld.encodeXML(xmlEnc); err != nil {
+			return err
+		}
+	}
+	return xmlEnc.EncodeToken(start.End())
+}
+
+// Header, cdrs and trailer as nested records, in export order
+func (cdre *CdrExporter) exportNodes() (nodes []*exportNode) {
+	if len(cdre.header) != 0 {
+		nodes = append(nodes, newExportNode(XML_HEADER, cdre.exportTemplate.HeaderFields, cdre.header))
+	}
+	for _, cdrContent := range cdre.content {
+		nodes = append(nodes, newExportNode(XML_CDR, cdre.exportTemplate.ContentFields, cdrContent))
+	}
+	if len(cdre.trailer) != 0 {
+		nodes = append(nodes, newExportNode(XML_TRAILER, cdre.exportTemplate.TrailerFields, cdre.trailer))
+	}
+	return
+}
+
+// One JSON object per line, header and trailer lines included if configured
+func (cdre *CdrExporter) writeJSONL(ioWriter io.Writer) error {
+	for _, node := range cdre.exportNodes() {
+		if len(node.children) == 0 { // Nothing to write out of fillers
+			continue
+		}
+		jsn, err := node.MarshalJSON()
+		if err != nil {
+			return err
+		}
+		if _, err := ioWriter.Write(append(jsn, '\n')); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Single XML document with one element per header, cdr and trailer
+func (cdre *CdrExporter) writeXML(ioWriter io.Writer) error {
+	if _, err := io.WriteString(ioWriter, xml.Header); err != nil {
+		return err
+	}
+	xmlEnc := xml.NewEncoder(ioWriter)
+	xmlEnc.Indent("", "  ")
+	root := xml.StartElement{Name: xml.Name{Local: XML_ROOT}}
+	if err := xmlEnc.EncodeToken(root); err != nil {
+		return err
+	}
+	for _, node := range cdre.exportNodes() {
+		if err := node.encodeXML(xmlEnc); err != nil {
+			return err
+		}
+	}
+	if err := xmlEnc.EncodeToken(root.End()); err != nil {
+		return err
+	}
+	if err := xmlEnc.Flush(); err != nil {
+		return err
+	}
+	_, err := io.WriteString(ioWriter, "\n")
+	return err
+}
+
 // General method to write the content out to a file
 func (cdre *CdrExporter) WriteToFile(filePath string) error {
 	fileOut, err := os.Create(filePath)
@@ -357,6 +505,14 @@ func (cdre *CdrExporter) WriteToFile(filePath string) error {
 		if err := cdre.writeCsv(csvWriter); err != nil {
 			return utils.NewErrServerError(err)
 		}
+	case utils.MetaJSONL:
+		if err := cdre.writeJSONL(fileOut); err != nil {
+			return utils.NewErrServerError(err)
+		}
+	case utils.MetaXML:
+		if err := cdre.writeXML(fileOut); err != nil {
+			return utils.NewErrServerError(err)
+		}
 	}
 	return nil
 }
@@ -371,6 +527,11 @@ func (cdre *CdrExporter) LastOrderId() int64 {
 	return cdre.lastExpOrderId
 }
 
+// Return the Cdrs which could not be exported
+func (cdre *CdrExporter) FailedCdrs() []*engine.CDR {
+	return cdre.failedCdrs
+}
+
 // Return total cost in the exported cdrs
 func (cdre *CdrExporter) TotalCost() float64 {
 	return cdre.totalCost
@@ -0,0 +1,136 @@
+/*
+Real-time Charging System for Telecom & ISP environments
+Copyright (C) 2012-2015 ITsysCOM GmbH
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>
+*/
+
+package cdre
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/cgrates/cgrates/config"
+	"github.com/cgrates/cgrates/engine"
+	"github.com/cgrates/cgrates/utils"
+)
+
+var nestedHdrJsnCfgFlds = []*config.CdrFieldJsonCfg{
+	&config.CdrFieldJsonCfg{Tag: utils.StringPointer("ExportID"), Type: utils.StringPointer(utils.META_HANDLER), Value: utils.StringPointer(META_EXPORTID)},
+	&config.CdrFieldJsonCfg{Tag: utils.StringPointer("Filler"), Type: utils.StringPointer(utils.META_FILLER), Width: utils.IntPointer(3)},
+}
+
+var nestedContentJsnCfgFlds = []*config.CdrFieldJsonCfg{
+	&config.CdrFieldJsonCfg{Tag: utils.StringPointer("CGRID"), Type: utils.StringPointer(utils.META_COMPOSED), Value: utils.StringPointer(utils.CGRID)},
+	&config.CdrFieldJsonCfg{Tag: utils.StringPointer("Account>ID"), Type: utils.StringPointer(utils.META_COMPOSED), Value: utils.StringPointer(utils.ACCOUNT)},
+	&config.CdrFieldJsonCfg{Tag: utils.StringPointer("Destination"), Type: utils.StringPointer(utils.MetaMaskedDestination)},
+	&config.CdrFieldJsonCfg{Tag: utils.StringPointer("Account>Tenant"), Type: utils.StringPointer(utils.META_COMPOSED), Value: utils.StringPointer(utils.TENANT)},
+	&config.CdrFieldJsonCfg{Tag: utils.StringPointer("Cost"), Type: utils.StringPointer(utils.META_COMPOSED), Value: utils.StringPointer(utils.COST)},
+}
+
+var nestedTrailerJsnCfgFlds = []*config.CdrFieldJsonCfg{
+	&config.CdrFieldJsonCfg{Tag: utils.StringPointer("Totals>CDRs"), Type: utils.StringPointer(utils.META_HANDLER), Value: utils.StringPointer(META_NRCDRS)},
+	&config.CdrFieldJsonCfg{Tag: utils.StringPointer("Totals>Cost"), Type: utils.StringPointer(utils.META_HANDLER), Value: utils.StringPointer(META_COSTCDRS)},
+}
+
+func newNestedCdrExporter(t *testing.T, cdrFormat string) *CdrExporter {
+	cfg, _ := config.NewDefaultCGRConfig()
+	cdreCfg := &config.CdreConfig{CdrFormat: cdrFormat}
+	var err error
+	if cdreCfg.HeaderFields, err = config.CfgCdrFieldsFromCdrFieldsJsonCfg(nestedHdrJsnCfgFlds); err != nil {
+		t.Fatal(err)
+	}
+	if cdreCfg.ContentFields, err = config.CfgCdrFieldsFromCdrFieldsJsonCfg(nestedContentJsnCfgFlds); err != nil {
+		t.Fatal(err)
+	}
+	if cdreCfg.TrailerFields, err = config.CfgCdrFieldsFromCdrFieldsJsonCfg(nestedTrailerJsnCfgFlds); err != nil {
+		t.Fatal(err)
+	}
+	cdrs := []*engine.CDR{
+		&engine.CDR{CGRID: "cgrid1", ToR: utils.VOICE, OrderID: 1, OriginID: "dsafdsaf", RequestType: utils.META_RATED, Direction: utils.OUT,
+			Tenant: "cgrates.org", Category: "call", Account: "1001", Subject: "1001", Destination: "1002",
+			SetupTime: time.Unix(1383813745, 0).UTC(), AnswerTime: time.Unix(1383813746, 0).UTC(),
+			Usage: time.Duration(10) * time.Second, RunID: utils.DEFAULT_RUNID, Cost: 1.01},
+		&engine.CDR{CGRID: "cgrid2", ToR: utils.VOICE, OrderID: 2, OriginID: "asdfasdf", RequestType: utils.META_RATED, Direction: utils.OUT,
+			Tenant: "cgrates.org", Category: "call", Account: "1002", Subject: "1002", Destination: "1001",
+			SetupTime: time.Unix(1383813745, 0).UTC(), AnswerTime: time.Unix(1383813746, 0).UTC(),
+			Usage: time.Duration(20) * time.Second, RunID: utils.DEFAULT_RUNID, Cost: 2.02},
+	}
+	cdre, err := NewCdrExporter(cdrs, nil, cdreCfg, cdrFormat, ',', "nested_1", 0.0, 0.0, 0.0, 0.0, 0.0, 2, 4,
+		cfg.RoundingDecimals, "", -1, cfg.HttpSkipTlsVerify, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cdre
+}
+
+func TestJSONLCdrWriter(t *testing.T) {
+	cdre := newNestedCdrExporter(t, utils.MetaJSONL)
+	writer := &bytes.Buffer{}
+	if err := cdre.writeJSONL(writer); err != nil {
+		t.Error(err)
+	}
+	expected := `{"ExportID":"nested_1"}
+{"CGRID":"cgrid1","Account":{"ID":"1001","Tenant":"cgrates.org"},"Destination":"0","Cost":"101.0000"}
+{"CGRID":"cgrid2","Account":{"ID":"1002","Tenant":"cgrates.org"},"Destination":"0","Cost":"202.0000"}
+{"Totals":{"CDRs":"2","Cost":"3.03"}}
+`
+	if writer.String() != expected {
+		t.Errorf("Expected: \n%s received: \n%s", expected, writer.String())
+	}
+}
+
+func TestXMLCdrWriter(t *testing.T) {
+	cdre := newNestedCdrExporter(t, utils.MetaXML)
+	writer := &bytes.Buffer{}
+	if err := cdre.writeXML(writer); err != nil {
+		t.Error(err)
+	}
+	expected := `<?xml version="1.0" encoding="UTF-8"?>
+<CDRs>
+  <Header>
+    <ExportID>nested_1</ExportID>
+  </Header>
+  <CDR>
+    <CGRID>cgrid1</CGRID>
+    <Account>
+      <ID>1001</ID>
+      <Tenant>cgrates.org</Tenant>
+    </Account>
+    <Destination>0</Destination>
+    <Cost>101.0000</Cost>
+  </CDR>
+  <CDR>
+    <CGRID>cgrid2</CGRID>
+    <Account>
+      <ID>1002</ID>
+      <Tenant>cgrates.org</Tenant>
+    </Account>
+    <Destination>0</Destination>
+    <Cost>202.0000</Cost>
+  </CDR>
+  <Trailer>
+    <Totals>
+      <CDRs>2</CDRs>
+      <Cost>3.03</Cost>
+    </Totals>
+  </Trailer>
+</CDRs>
+`
+	if writer.String() != expected {
+		t.Errorf("Expected: \n%s received: \n%s", expected, writer.String())
+	}
+}
@@ -19,9 +19,12 @@ along with this program.  If not, see <http://www.gnu.org/licenses/>
 package main
 
 import (
+	"crypto/tls"
 	"flag"
 	"fmt"
+	"io"
 	"log"
+	"net"
 	//	_ "net/http/pprof"
 	"os"
 	"runtime"
@@ -129,7 +132,8 @@ func startCdrc(internalCdrSChan, internalRaterChan chan rpcclient.RpcClientConne
 	}
 }
 
-func startSmGeneric(internalSMGChan chan rpcclient.RpcClientConnection, internalRaterChan, internalCDRSChan chan rpcclient.RpcClientConnection, server *utils.Server, exitChan chan bool) {
+func startSmGeneric(internalSMGChan chan rpcclient.RpcClientConnection, internalRaterChan, internalCDRSChan chan rpcclient.RpcClientConnection,
+	accountDb engine.AccountingStorage, server *utils.Server, serverTLSCfg *tls.Config, exitChan chan bool) {
 	utils.Logger.Info("Starting CGRateS SMGeneric service.")
 	var ralsConns, cdrsConn *rpcclient.RpcClientPool
 	if len(cfg.SmGenericConfig.RALsConns) != 0 {
@@ -151,14 +155,30 @@ func startSmGeneric(internalSMGChan chan rpcclient.RpcClientConnection, internal
 		}
 	}
 	smg_econns := sessionmanager.NewSMGExternalConnections()
-	sm := sessionmanager.NewSMGeneric(cfg, ralsConns, cdrsConn, cfg.DefaultTimezone, smg_econns)
+	var sessionsDb engine.AccountingStorage
+	if cfg.SmGenericConfig.PersistSessions {
+		sessionsDb = accountDb
+	}
+	var replConns rpcclient.RpcClientConnection
+	if len(cfg.SmGenericConfig.SessionReplicationConns) != 0 {
+		if replConns, err = engine.NewRPCPool(rpcclient.POOL_BROADCAST, cfg.ConnectAttempts, cfg.Reconnects, cfg.ConnectTimeout, cfg.ReplyTimeout,
+			cfg.SmGenericConfig.SessionReplicationConns, nil, cfg.InternalTtl); err != nil {
+			utils.Logger.Crit(fmt.Sprintf("<SMGeneric> Could not connect to session replication peers: %s", err.Error()))
+			exitChan <- true
+			return
+		}
+	}
+	sm := sessionmanager.NewSMGeneric(cfg, ralsConns, cdrsConn, sessionsDb, replConns, cfg.DefaultTimezone, smg_econns)
+	utils.Metrics.SetGaugeFunc("cgrates_smg_active_sessions", "Sessions handled by SMGeneric.",
+		func() float64 { return float64(sm.ActiveSessionsCount()) })
+	utils.Metrics.SetGaugeFunc("cgrates_smg_passive_sessions", "Sessions replicated to SMGeneric by peers.",
+		func() float64 { return float64(sm.PassiveSessionsCount()) })
 	if err = sm.Connect(); err != nil {
 		utils.Logger.Err(fmt.Sprintf("<SMGeneric> error: %s!", err))
 	}
 	// Register RPC handler
 	smgRpc := v1.NewSMGenericV1(sm)
 	server.RpcRegister(smgRpc)
-	internalSMGChan <- smgRpc
 	// Register BiRpc handlers
 	smgBiRpc := v1.NewSMGenericBiRpcV1(sm)
 	for method, handler := range smgBiRpc.Handlers() {
@@ -167,20 +187,18 @@ func startSmGeneric(internalSMGChan chan rpcclient.RpcClientConnection, internal
 	// Register OnConnect handlers so we can intercept connections for session disconnects
 	server.BijsonRegisterOnConnect(smg_econns.OnClientConnect)
 	server.BijsonRegisterOnDisconnect(smg_econns.OnClientDisconnect)
+	internalSMGChan <- smgRpc // After BiRPC handlers so in-process agents can connect over BiJSON
+	if cfg.SmGenericConfig.ListenBijson != "" {
+		go server.ServeBiJSON(cfg.SmGenericConfig.ListenBijson)
+	}
+	if cfg.SmGenericConfig.ListenBijsonTLS != "" {
+		go server.ServeBiJSONTLS(cfg.SmGenericConfig.ListenBijsonTLS, serverTLSCfg)
+	}
 }
 
-func startDiameterAgent(internalSMGChan, internalPubSubSChan chan rpcclient.RpcClientConnection, exitChan chan bool) {
+func startDiameterAgent(internalSMGChan, internalPubSubSChan chan rpcclient.RpcClientConnection, server *utils.Server, exitChan chan bool) {
 	utils.Logger.Info("Starting CGRateS DiameterAgent service.")
-	var smgConn, pubsubConn *rpcclient.RpcClientPool
-	if len(cfg.DiameterAgentCfg().SMGenericConns) != 0 {
-		smgConn, err = engine.NewRPCPool(rpcclient.POOL_BROADCAST, cfg.ConnectAttempts, cfg.Reconnects, cfg.ConnectTimeout, cfg.ReplyTimeout,
-			cfg.DiameterAgentCfg().SMGenericConns, internalSMGChan, cfg.InternalTtl)
-		if err != nil {
-			utils.Logger.Crit(fmt.Sprintf("<DiameterAgent> Could not connect to SMG: %s", err.Error()))
-			exitChan <- true
-			return
-		}
-	}
+	var pubsubConn *rpcclient.RpcClientPool
 	if len(cfg.DiameterAgentCfg().PubSubConns) != 0 {
 		pubsubConn, err = engine.NewRPCPool(rpcclient.POOL_FIRST, cfg.ConnectAttempts, cfg.Reconnects, cfg.ConnectTimeout, cfg.ReplyTimeout,
 			cfg.DiameterAgentCfg().PubSubConns, internalPubSubSChan, cfg.InternalTtl)
@@ -190,18 +208,88 @@ func startDiameterAgent(internalSMGChan, internalPubSubSChan chan rpcclient.RpcC
 			return
 		}
 	}
+	// SMG connections are created once the agent is available so they can serve the disconnect requests
+	smgConn := rpcclient.NewRpcClientPool(rpcclient.POOL_BROADCAST, cfg.ReplyTimeout)
 	da, err := agents.NewDiameterAgent(cfg, smgConn, pubsubConn)
 	if err != nil {
 		utils.Logger.Err(fmt.Sprintf("<DiameterAgent> error: %s!", err))
 		exitChan <- true
 		return
 	}
+	var plainSMGConnCfgs []*config.HaPoolConfig
+	for _, smgConnCfg := range cfg.DiameterAgentCfg().SMGenericConns {
+		var dial func() (io.ReadWriteCloser, error)
+		switch {
+		case smgConnCfg.Address == utils.MetaInternal: // Served in-process over the BiJSON server so SMG can identify us as owner of the sessions
+			select {
+			case internalSMG := <-internalSMGChan: // Wait for SMG to start and register its handlers
+				internalSMGChan <- internalSMG
+			case <-time.After(cfg.InternalTtl):
+				utils.Logger.Crit("<DiameterAgent> Could not connect to SMG: TTL triggered")
+				exitChan <- true
+				return
+			}
+			dial = func() (io.ReadWriteCloser, error) {
+				srvConn, clntConn := net.Pipe()
+				go server.ServeBiJSONConn(srvConn)
+				return clntConn, nil
+			}
+		case smgConnCfg.Transport == utils.MetaBiJSONrpc:
+			smgAddr := smgConnCfg.Address
+			dial = func() (io.ReadWriteCloser, error) {
+				return net.DialTimeout("tcp", smgAddr, cfg.ConnectTimeout)
+			}
+		default:
+			utils.Logger.Warning(fmt.Sprintf("<DiameterAgent> SMG connection to %s is not bidirectional, session disconnects will not be available", smgConnCfg.Address))
+			plainSMGConnCfgs = append(plainSMGConnCfgs, smgConnCfg)
+			continue
+		}
+		smgBiRPCConn, err := agents.NewSMGBiRPCClient(dial, da.BiRPCHandlers())
+		if err != nil {
+			utils.Logger.Err(fmt.Sprintf("<DiameterAgent> Could not connect to SMG at %s: %s", smgConnCfg.Address, err.Error()))
+		}
+		smgConn.AddClient(smgBiRPCConn)
+	}
+	if len(plainSMGConnCfgs) != 0 {
+		plainSMGConns, err := engine.NewRPCPool(rpcclient.POOL_BROADCAST, cfg.ConnectAttempts, cfg.Reconnects, cfg.ConnectTimeout, cfg.ReplyTimeout,
+			plainSMGConnCfgs, internalSMGChan, cfg.InternalTtl)
+		if err != nil {
+			utils.Logger.Crit(fmt.Sprintf("<DiameterAgent> Could not connect to SMG: %s", err.Error()))
+			exitChan <- true
+			return
+		}
+		smgConn.AddClient(plainSMGConns)
+	}
 	if err = da.ListenAndServe(); err != nil {
 		utils.Logger.Err(fmt.Sprintf("<DiameterAgent> error: %s!", err))
 	}
 	exitChan <- true
 }
 
+func startRadiusAgent(internalSMGChan chan rpcclient.RpcClientConnection, exitChan chan bool) {
+	utils.Logger.Info("Starting CGRateS RadiusAgent service.")
+	var smgConn *rpcclient.RpcClientPool
+	if len(cfg.RadiusAgentCfg().SMGenericConns) != 0 {
+		smgConn, err = engine.NewRPCPool(rpcclient.POOL_BROADCAST, cfg.ConnectAttempts, cfg.Reconnects, cfg.ConnectTimeout, cfg.ReplyTimeout,
+			cfg.RadiusAgentCfg().SMGenericConns, internalSMGChan, cfg.InternalTtl)
+		if err != nil {
+			utils.Logger.Crit(fmt.Sprintf("<RadiusAgent> Could not connect to SMG: %s", err.Error()))
+			exitChan <- true
+			return
+		}
+	}
+	ra, err := agents.NewRadiusAgent(cfg, smgConn)
+	if err != nil {
+		utils.Logger.Err(fmt.Sprintf("<RadiusAgent> error: %s!", err))
+		exitChan <- true
+		return
+	}
+	if err = ra.ListenAndServe(); err != nil {
+		utils.Logger.Err(fmt.Sprintf("<RadiusAgent> error: %s!", err))
+	}
+	exitChan <- true
+}
+
 func startSmFreeSWITCH(internalRaterChan, internalCDRSChan chan rpcclient.RpcClientConnection, cdrDb engine.CdrStorage, exitChan chan bool) {
 	utils.Logger.Info("Starting CGRateS SMFreeSWITCH service.")
 	var ralsConn, cdrsConn *rpcclient.RpcClientPool
@@ -341,8 +429,15 @@ func startCDRS(internalCdrSChan chan rpcclient.RpcClientConnection, cdrDb engine
 			return
 		}
 	}
-	cdrServer, _ := engine.NewCdrServer(cfg, cdrDb, ralConn, pubSubConn, usersConn, aliasesConn, statsConn)
+	cdrServer, err := engine.NewCdrServer(cfg, cdrDb, ralConn, pubSubConn, usersConn, aliasesConn, statsConn)
+	if err != nil {
+		utils.Logger.Crit(fmt.Sprintf("<CDRS> Could not start, error: %s", err.Error()))
+		exitChan <- true
+		return
+	}
 	cdrServer.SetTimeToLive(cfg.ResponseCacheTTL, nil)
+	utils.Metrics.SetGaugeFunc("cgrates_cdrs_replication_spooled", "CDR replications waiting in the local spool.",
+		func() float64 { return float64(cdrServer.SpooledReplications()) })
 	utils.Logger.Info("Registering CDRS HTTP Handlers.")
 	cdrServer.RegisterHandlersToServer(server)
 	utils.Logger.Info("Registering CDRS RPC service.")
@@ -360,6 +455,8 @@ func startScheduler(internalSchedulerChan chan *scheduler.Scheduler, cacheDoneCh
 	cacheDoneChan <- cacheDone
 	utils.Logger.Info("Starting CGRateS Scheduler.")
 	sched := scheduler.NewScheduler(ratingDb)
+	utils.Metrics.SetGaugeFunc("cgrates_scheduler_queue_length", "Action timings queued in the scheduler.",
+		func() float64 { return float64(sched.QueueLength()) })
 	go reloadSchedulerSingnalHandler(sched, ratingDb)
 	time.Sleep(1)
 	internalSchedulerChan <- sched
@@ -375,11 +472,24 @@ func startCdrStats(internalCdrStatSChan chan rpcclient.RpcClientConnection, rati
 	internalCdrStatSChan <- cdrStats
 }
 
-func startHistoryServer(internalHistorySChan chan rpcclient.RpcClientConnection, server *utils.Server, exitChan chan bool) {
-	scribeServer, err := history.NewFileScribe(cfg.HistoryDir, cfg.HistorySaveInterval)
+func startHistoryServer(internalHistorySChan chan rpcclient.RpcClientConnection, cdrDb engine.CdrStorage, server *utils.Server, exitChan chan bool) {
+	var scribeServer rpcclient.RpcClientConnection
+	var err error
+	switch cfg.HistoryBackend {
+	case utils.MetaFile:
+		var recordStorage *history.FileRecordStorage
+		if recordStorage, err = history.NewFileRecordStorage(cfg.HistoryDir); err == nil {
+			scribeServer = history.NewRecorder(recordStorage)
+		}
+	case utils.MetaStorDB:
+		scribeServer = history.NewRecorder(cdrDb)
+	default:
+		scribeServer, err = history.NewFileScribe(cfg.HistoryDir, cfg.HistorySaveInterval)
+	}
 	if err != nil {
 		utils.Logger.Crit(fmt.Sprintf("<HistoryServer> Could not start, error: %s", err.Error()))
 		exitChan <- true
+		return
 	}
 	server.RpcRegisterName("HistoryV1", scribeServer)
 	internalHistorySChan <- scribeServer
@@ -429,9 +539,32 @@ func startUsersServer(internalUserSChan chan rpcclient.RpcClientConnection, acco
 	internalUserSChan <- userServer
 }
 
+func startResourceLimiterService(internalRLSChan, internalCdrStatSChan chan rpcclient.RpcClientConnection, accountDb engine.AccountingStorage,
+	server *utils.Server, exitChan chan bool) {
+	var statsConn rpcclient.RpcClientConnection
+	if len(cfg.ResourceLimiterCfg().CDRStatConns) != 0 { // Stats connection init
+		var err error
+		statsConn, err = engine.NewRPCPool(rpcclient.POOL_FIRST, cfg.ConnectAttempts, cfg.Reconnects, cfg.ConnectTimeout, cfg.ReplyTimeout,
+			cfg.ResourceLimiterCfg().CDRStatConns, internalCdrStatSChan, cfg.InternalTtl)
+		if err != nil {
+			utils.Logger.Crit(fmt.Sprintf("<RLs> Could not connect to StatS: %s", err.Error()))
+			exitChan <- true
+			return
+		}
+	}
+	rls := engine.NewResourceLimiterService(accountDb, statsConn, cfg.ResourceLimiterCfg().UsageTTL)
+	if err := rls.Start(); err != nil {
+		utils.Logger.Crit(fmt.Sprintf("<RLs> Could not start, error: %s", err.Error()))
+		exitChan <- true
+		return
+	}
+	server.RpcRegisterName("RLsV1", v1.NewResourceLimiterV1(rls))
+	internalRLSChan <- rls
+}
+
 func startRpc(server *utils.Server, internalRaterChan,
 	internalCdrSChan, internalCdrStatSChan, internalHistorySChan, internalPubSubSChan, internalUserSChan,
-	internalAliaseSChan chan rpcclient.RpcClientConnection) {
+	internalAliaseSChan chan rpcclient.RpcClientConnection, serverTLSCfg *tls.Config) {
 	select { // Any of the rpc methods will unlock listening to rpc requests
 	case resp := <-internalRaterChan:
 		internalRaterChan <- resp
@@ -451,6 +584,15 @@ func startRpc(server *utils.Server, internalRaterChan,
 	go server.ServeJSON(cfg.RPCJSONListen)
 	go server.ServeGOB(cfg.RPCGOBListen)
 	go server.ServeHTTP(cfg.HTTPListen)
+	if cfg.RPCJSONTLSListen != "" {
+		go server.ServeJSONTLS(cfg.RPCJSONTLSListen, serverTLSCfg)
+	}
+	if cfg.RPCGOBTLSListen != "" {
+		go server.ServeGOBTLS(cfg.RPCGOBTLSListen, serverTLSCfg)
+	}
+	if cfg.HTTPTLSListen != "" {
+		go server.ServeHTTPTLS(cfg.HTTPTLSListen, serverTLSCfg)
+	}
 }
 
 func writePid() {
@@ -526,7 +668,8 @@ func main() {
 		defer ratingDb.Close()
 		engine.SetRatingStorage(ratingDb)
 	}
-	if cfg.RALsEnabled || cfg.CDRStatsEnabled || cfg.PubSubServerEnabled || cfg.AliasesServerEnabled || cfg.UserServerEnabled {
+	if cfg.RALsEnabled || cfg.CDRStatsEnabled || cfg.PubSubServerEnabled || cfg.AliasesServerEnabled || cfg.UserServerEnabled || cfg.ResourceLimiterCfg().Enabled ||
+		(cfg.SmGenericConfig.Enabled && cfg.SmGenericConfig.PersistSessions) {
 		accountDb, err = engine.ConfigureAccountingStorage(cfg.DataDbType, cfg.DataDbHost, cfg.DataDbPort,
 			cfg.DataDbName, cfg.DataDbUser, cfg.DataDbPass, cfg.DBDataEncoding, cfg.CacheDumpDir, cfg.LoadHistorySize)
 		if err != nil { // Cannot configure getter database, show stopper
@@ -540,7 +683,8 @@ func main() {
 			return
 		}
 	}
-	if cfg.RALsEnabled || cfg.CDRSEnabled || cfg.SchedulerEnabled { // Only connect to storDb if necessary
+	if cfg.RALsEnabled || cfg.CDRSEnabled || cfg.SchedulerEnabled ||
+		(cfg.HistoryServerEnabled && cfg.HistoryBackend == utils.MetaStorDB) { // Only connect to storDb if necessary
 		storDb, err := engine.ConfigureStorStorage(cfg.StorDBType, cfg.StorDBHost, cfg.StorDBPort,
 			cfg.StorDBName, cfg.StorDBUser, cfg.StorDBPass, cfg.DBDataEncoding, cfg.StorDBMaxOpenConns, cfg.StorDBMaxIdleConns, cfg.StorDBCDRSIndexes)
 		if err != nil { // Cannot configure logger database, show stopper
@@ -552,15 +696,31 @@ func main() {
 		loadDb = storDb.(engine.LoadStorage)
 		cdrDb = storDb.(engine.CdrStorage)
 		engine.SetCdrStorage(cdrDb)
+		if cfg.RALsBalanceLedger {
+			engine.SetBalanceLedgerStorage(cdrDb)
+		}
 	}
 
 	engine.SetRoundingDecimals(cfg.RoundingDecimals)
 	engine.SetRpSubjectPrefixMatching(cfg.RpSubjectPrefixMatching)
 	engine.SetLcrSubjectPrefixMatching(cfg.LcrSubjectPrefixMatching)
+	hostname, _ := os.Hostname()
+	engine.SetHistoryAuthor("cgr-engine@" + hostname)
 	stopHandled := false
 
 	// Rpc/http server
 	server := new(utils.Server)
+	if cfg.RPCAuthEnabled {
+		server.SetAuthorizer(utils.NewRPCAuthorizer(cfg.RPCApiKeys, cfg.RPCAuthTrustedAddrs))
+	}
+	server.RegisterHttpFunc("/metrics", server.AuthorizeHttpFunc(utils.Metrics.HTTPHandler))
+	var serverTLSCfg *tls.Config // shared by all TLS listeners, nil if none configured
+	if cfg.RPCJSONTLSListen != "" || cfg.RPCGOBTLSListen != "" || cfg.HTTPTLSListen != "" || cfg.SmGenericConfig.ListenBijsonTLS != "" {
+		if serverTLSCfg, err = utils.NewTLSServerConfig(cfg.TLSServerCertificate, cfg.TLSServerKey, cfg.TLSCACertificate, cfg.TLSClientAuth); err != nil {
+			utils.Logger.Crit(fmt.Sprintf("Could not load TLS certificates: %s exiting!", err))
+			return
+		}
+	}
 
 	// Async starts here, will follow cgrates.json start order
 
@@ -576,6 +736,7 @@ func main() {
 	internalUserSChan := make(chan rpcclient.RpcClientConnection, 1)
 	internalAliaseSChan := make(chan rpcclient.RpcClientConnection, 1)
 	internalSMGChan := make(chan rpcclient.RpcClientConnection, 1)
+	internalRLSChan := make(chan rpcclient.RpcClientConnection, 1)
 	// Start balancer service
 	if cfg.BalancerEnabled {
 		go startBalancer(internalBalancerChan, &stopHandled, exitChan) // Not really needed async here but to cope with uniformity
@@ -584,7 +745,7 @@ func main() {
 	// Start rater service
 	if cfg.RALsEnabled {
 		go startRater(internalRaterChan, cacheDoneChan, internalBalancerChan, internalSchedulerChan, internalCdrStatSChan, internalHistorySChan, internalPubSubSChan, internalUserSChan, internalAliaseSChan,
-			server, ratingDb, accountDb, loadDb, cdrDb, &stopHandled, exitChan)
+			internalRLSChan, server, ratingDb, accountDb, loadDb, cdrDb, &stopHandled, exitChan)
 	}
 
 	// Start Scheduler
@@ -607,7 +768,7 @@ func main() {
 
 	// Start SM-Generic
 	if cfg.SmGenericConfig.Enabled {
-		go startSmGeneric(internalSMGChan, internalRaterChan, internalCdrSChan, server, exitChan)
+		go startSmGeneric(internalSMGChan, internalRaterChan, internalCdrSChan, accountDb, server, serverTLSCfg, exitChan)
 	}
 	// Start SM-FreeSWITCH
 	if cfg.SmFsConfig.Enabled {
@@ -633,12 +794,16 @@ func main() {
 	}
 
 	if cfg.DiameterAgentCfg().Enabled {
-		go startDiameterAgent(internalSMGChan, internalPubSubSChan, exitChan)
+		go startDiameterAgent(internalSMGChan, internalPubSubSChan, server, exitChan)
+	}
+
+	if cfg.RadiusAgentCfg().Enabled {
+		go startRadiusAgent(internalSMGChan, exitChan)
 	}
 
 	// Start HistoryS service
 	if cfg.HistoryServerEnabled {
-		go startHistoryServer(internalHistorySChan, server, exitChan)
+		go startHistoryServer(internalHistorySChan, cdrDb, server, exitChan)
 	}
 
 	// Start PubSubS service
@@ -656,9 +821,14 @@ func main() {
 		go startUsersServer(internalUserSChan, accountDb, server, exitChan)
 	}
 
+	// Start ResourceLimiter service
+	if cfg.ResourceLimiterCfg().Enabled {
+		go startResourceLimiterService(internalRLSChan, internalCdrStatSChan, accountDb, server, exitChan)
+	}
+
 	// Serve rpc connections
 	go startRpc(server, internalRaterChan, internalCdrSChan, internalCdrStatSChan, internalHistorySChan,
-		internalPubSubSChan, internalUserSChan, internalAliaseSChan)
+		internalPubSubSChan, internalUserSChan, internalAliaseSChan, serverTLSCfg)
 	<-exitChan
 
 	if *pidFile != "" {
@@ -54,7 +54,7 @@ func startBalancer(internalBalancerChan chan *balancer2go.Balancer, stopHandled
 func startRater(internalRaterChan chan rpcclient.RpcClientConnection, cacheDoneChan chan struct{}, internalBalancerChan chan *balancer2go.Balancer, internalSchedulerChan chan *scheduler.Scheduler,
 	internalCdrStatSChan chan rpcclient.RpcClientConnection, internalHistorySChan chan rpcclient.RpcClientConnection,
 	internalPubSubSChan chan rpcclient.RpcClientConnection, internalUserSChan chan rpcclient.RpcClientConnection, internalAliaseSChan chan rpcclient.RpcClientConnection,
-	server *utils.Server,
+	internalRLSChan chan rpcclient.RpcClientConnection, server *utils.Server,
 	ratingDb engine.RatingStorage, accountDb engine.AccountingStorage, loadDb engine.LoadStorage, cdrDb engine.CdrStorage, stopHandled *bool, exitChan chan bool) {
 	var waitTasks []chan struct{}
 
@@ -105,6 +105,22 @@ func startRater(internalRaterChan chan rpcclient.RpcClientConnection, cacheDoneC
 
 		}()
 	}
+	var rls rpcclient.RpcClientConnection // ResourceLimits are indexed again on tariff plan loads
+	if cfg.ResourceLimiterCfg().Enabled {
+		rlsTaskChan := make(chan struct{})
+		waitTasks = append(waitTasks, rlsTaskChan)
+		go func() {
+			defer close(rlsTaskChan)
+			select {
+			case rls = <-internalRLSChan:
+				internalRLSChan <- rls
+			case <-time.After(cfg.InternalTtl):
+				utils.Logger.Crit("<Rater>: Internal ResourceLimiter connection timeout.")
+				exitChan <- true
+				return
+			}
+		}()
+	}
 	var bal *balancer2go.Balancer
 	if cfg.RALsBalancer != "" { // Connection to balancer
 		balTaskChan := make(chan struct{})
@@ -217,8 +233,12 @@ func startRater(internalRaterChan chan rpcclient.RpcClientConnection, cacheDoneC
 	if usersConns != nil {
 		apierRpcV1.Users = usersConns
 	}
+	if rls != nil {
+		apierRpcV1.RLs = rls
+	}
 	apierRpcV2 := &v2.ApierV2{
 		ApierV1: *apierRpcV1}
+	apierRpcV2.ScheduleCdrExportJobs()
 
 	// internalSchedulerChan shared here
 	server.RpcRegister(responder)
@@ -23,6 +23,7 @@ import (
 	"fmt"
 	"log"
 	"net/rpc"
+	"os"
 	"path"
 	"strconv"
 	"strings"
@@ -271,6 +272,8 @@ func main() {
 			path.Join(*dataPath, utils.USERS_CSV),
 			path.Join(*dataPath, utils.ALIASES_CSV),
 			path.Join(*dataPath, utils.ResourceLimitsCsv),
+			path.Join(*dataPath, utils.ExchangeRatesCsv),
+			path.Join(*dataPath, utils.TaxRulesCsv),
 		)
 	}
 	tpReader := engine.NewTpReader(ratingDb, accountDb, loader, *tpid, *timezone)
@@ -295,6 +298,9 @@ func main() {
 			return
 		} else {
 			engine.SetHistoryScribe(scribeAgent)
+			defer engine.FlushHistory() // Changes are sent in background, do not exit before they reach the history server
+			hostname, _ := os.Hostname()
+			engine.SetHistoryAuthor("cgr-loader@" + hostname)
 			//defer scribeAgent.Client.Close()
 		}
 	} else {
@@ -343,7 +349,7 @@ func main() {
 	if len(*historyServer) != 0 && *verbose {
 		log.Print("Wrote history.")
 	}
-	var dstIds, rplIds, rpfIds, actIds, shgIds, alsIds, lcrIds, dcsIds []string
+	var dstIds, rplIds, rpfIds, actIds, shgIds, alsIds, lcrIds, dcsIds, exrIds, txrIds, rlIds []string
 	if rater != nil {
 		dstIds, _ = tpReader.GetLoadedIds(utils.DESTINATION_PREFIX)
 		rplIds, _ = tpReader.GetLoadedIds(utils.RATING_PLAN_PREFIX)
@@ -353,6 +359,9 @@ func main() {
 		alsIds, _ = tpReader.GetLoadedIds(utils.ALIASES_PREFIX)
 		lcrIds, _ = tpReader.GetLoadedIds(utils.LCR_PREFIX)
 		dcsIds, _ = tpReader.GetLoadedIds(utils.DERIVEDCHARGERS_PREFIX)
+		exrIds, _ = tpReader.GetLoadedIds(utils.ExchangeRatesPrefix)
+		txrIds, _ = tpReader.GetLoadedIds(utils.TaxRulesPrefix)
+		rlIds, _ = tpReader.GetLoadedIds(utils.ResourceLimitsPrefix)
 	}
 	actTmgIds, _ := tpReader.GetLoadedIds(utils.ACTION_PLAN_PREFIX)
 	var statsQueueIds []string
@@ -375,7 +384,7 @@ func main() {
 			log.Print("Reloading cache")
 		}
 		if *flush {
-			dstIds, rplIds, rpfIds, lcrIds = nil, nil, nil, nil // Should reload all these on flush
+			dstIds, rplIds, rpfIds, lcrIds, exrIds, txrIds, rlIds = nil, nil, nil, nil, nil, nil, nil // Should reload all these on flush
 		}
 		if err = rater.Call("ApierV1.ReloadCache", utils.AttrReloadCache{
 			DestinationIds:   dstIds,
@@ -386,6 +395,9 @@ func main() {
 			Aliases:          alsIds,
 			LCRIds:           lcrIds,
 			DerivedChargers:  dcsIds,
+			ExchangeRateIds:  exrIds,
+			TaxRuleIds:       txrIds,
+			ResourceLimitIds: rlIds,
 		}, &reply); err != nil {
 			log.Printf("WARNING: Got error on cache reload: %s\n", err.Error())
 		}
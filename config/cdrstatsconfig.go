@@ -26,6 +26,7 @@ type CdrStatsConfig struct {
 	Id               string        // Config id, unique per config instance
 	QueueLength      int           // Number of items in the stats buffer
 	TimeWindow       time.Duration // Will only keep the CDRs who's call setup time is not older than time.Now()-TimeWindow
+	BucketInterval   time.Duration // Aggregate the CDRs in buckets of this interval instead of keeping them individually
 	SaveInterval     time.Duration
 	Metrics          []string    // ASR, ACD, ACC
 	SetupInterval    []time.Time // 2 or less items (>= start interval,< stop_interval)
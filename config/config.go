@@ -25,6 +25,7 @@ import (
 	"path/filepath"
 	"strconv"
 	"strings"
+	"text/template"
 	"time"
 
 	"github.com/cgrates/cgrates/utils"
@@ -67,6 +68,8 @@ func NewDefaultCGRConfig() (*CGRConfig, error) {
 	cfg.SmKamConfig = new(SmKamConfig)
 	cfg.SmOsipsConfig = new(SmOsipsConfig)
 	cfg.diameterAgentCfg = new(DiameterAgentCfg)
+	cfg.radiusAgentCfg = new(RadiusAgentCfg)
+	cfg.resourceLimiterCfg = new(ResourceLimiterConfig)
 	cfg.ConfigReloads = make(map[string]chan struct{})
 	cfg.ConfigReloads[utils.CDRC] = make(chan struct{}, 1)
 	cfg.ConfigReloads[utils.CDRC] <- struct{}{} // Unlock the channel
@@ -76,6 +79,8 @@ func NewDefaultCGRConfig() (*CGRConfig, error) {
 	cfg.ConfigReloads[utils.SURETAX] <- struct{}{} // Unlock the channel
 	cfg.ConfigReloads[utils.DIAMETER_AGENT] = make(chan struct{}, 1)
 	cfg.ConfigReloads[utils.DIAMETER_AGENT] <- struct{}{} // Unlock the channel
+	cfg.ConfigReloads[utils.RADIUS_AGENT] = make(chan struct{}, 1)
+	cfg.ConfigReloads[utils.RADIUS_AGENT] <- struct{}{} // Unlock the channel
 	cgrJsonCfg, err := NewCgrJsonCfgFromReader(strings.NewReader(CGRATES_CFG_JSON))
 	if err != nil {
 		return nil, err
@@ -195,6 +200,9 @@ type CGRConfig struct {
 	RPCJSONListen            string        // RPC JSON listening address
 	RPCGOBListen             string        // RPC GOB listening address
 	HTTPListen               string        // HTTP listening address
+	RPCJSONTLSListen         string        // RPC JSON over TLS listening address, empty to disable
+	RPCGOBTLSListen          string        // RPC GOB over TLS listening address, empty to disable
+	HTTPTLSListen            string        // HTTPS listening address, empty to disable
 	DefaultReqType           string        // Use this request type if not defined on top
 	DefaultCategory          string        // set default type of record
 	DefaultTenant            string        // set default tenant
@@ -222,8 +230,10 @@ type CGRConfig struct {
 	RALsAliasSConns          []*HaPoolConfig
 	RpSubjectPrefixMatching  bool // enables prefix matching for the rating profile subject
 	LcrSubjectPrefixMatching bool // enables prefix matching for the lcr subject
+	RALsBalanceLedger        bool // write every balance change into the StorDB ledger
 	BalancerEnabled          bool
 	SchedulerEnabled         bool
+	SchedulerCdrExports      []*CdrExportJobCfg   // CDR export jobs run by the scheduler
 	CDRSEnabled              bool                 // Enable CDR Server service
 	CDRSExtraFields          []*utils.RSRField    // Extra fields to store in CDRs
 	CDRSStoreCdrs            bool                 // store cdrs in storDb
@@ -233,6 +243,8 @@ type CGRConfig struct {
 	CDRSAliaseSConns         []*HaPoolConfig      // address where to reach the aliases service: <""|internal|x.y.z.y:1234>
 	CDRSStatSConns           []*HaPoolConfig      // address where to reach the cdrstats service. Empty to disable stats gathering  <""|internal|x.y.z.y:1234>
 	CDRSCdrReplication       []*CdrReplicationCfg // Replicate raw CDRs to a number of servers
+	CDRSReplSpoolDir         string               // Directory where failed replications are kept until replayed
+	CDRSReplRetryInterval    time.Duration        // Interval to automatically replay failed replications, 0 to disable
 	CDRStatsEnabled          bool                 // Enable CDR Stats service
 	CDRStatsSaveInterval     time.Duration        // Save interval duration
 	CdreProfiles             map[string]*CdreConfig
@@ -242,20 +254,32 @@ type CGRConfig struct {
 	SmKamConfig              *SmKamConfig             // SM-Kamailio Configuration
 	SmOsipsConfig            *SmOsipsConfig           // SMOpenSIPS Configuration
 	diameterAgentCfg         *DiameterAgentCfg        // DiameterAgent configuration
+	radiusAgentCfg           *RadiusAgentCfg          // RadiusAgent configuration
 	HistoryServer            string                   // Address where to reach the master history server: <internal|x.y.z.y:1234>
 	HistoryServerEnabled     bool                     // Starts History as server: <true|false>.
+	HistoryBackend           string                   // Where the history records are stored: <*file|*git|*stordb>
 	HistoryDir               string                   // Location on disk where to store history files.
 	HistorySaveInterval      time.Duration            // The timout duration between pubsub writes
 	PubSubServerEnabled      bool                     // Starts PubSub as server: <true|false>.
 	AliasesServerEnabled     bool                     // Starts PubSub as server: <true|false>.
 	UserServerEnabled        bool                     // Starts User as server: <true|false>
 	UserServerIndexes        []string                 // List of user profile field indexes
+	resourceLimiterCfg       *ResourceLimiterConfig   // Configuration for resource limiter
 	MailerServer             string                   // The server to use when sending emails out
 	MailerAuthUser           string                   // Authenticate to email server using this user
 	MailerAuthPass           string                   // Authenticate to email server with this password
 	MailerFromAddr           string                   // From address used when sending emails out
 	DataFolderPath           string                   // Path towards data folder, for tests internal usage, not loading out of .json options
 	sureTaxCfg               *SureTaxCfg              // Load here SureTax configuration, as pointer so we can have runtime reloads in the future
+	RPCAuthEnabled           bool                     // Authorize RPC requests with API keys
+	RPCAuthTrustedAddrs      []string                 // Hosts whose RPC requests are not authorized
+	RPCApiKeys               map[string]*utils.ApiKey // Authorizations per API key
+	TLSServerCertificate     string                   // Certificate presented by the TLS listeners
+	TLSServerKey             string                   // Private key of the server certificate
+	TLSClientAuth            bool                     // Require and verify client certificates on the TLS listeners
+	TLSCACertificate         string                   // CA certificates verifying the peers, system ones if empty
+	TLSClientCertificate     string                   // Certificate presented on outbound TLS connections, empty for none
+	TLSClientKey             string                   // Private key of the client certificate
 	ConfigReloads            map[string]chan struct{} // Signals to specific entities that a config reload should occur
 	// Cache defaults loaded from json and needing clones
 	dfltCdreProfile *CdreConfig // Default cdreConfig profile
@@ -263,6 +287,19 @@ type CGRConfig struct {
 }
 
 func (self *CGRConfig) checkConfigSanity() error {
+	// TLS listeners checks
+	if (self.RPCJSONTLSListen != "" || self.RPCGOBTLSListen != "" || self.HTTPTLSListen != "" ||
+		(self.SmGenericConfig != nil && self.SmGenericConfig.ListenBijsonTLS != "")) &&
+		(self.TLSServerCertificate == "" || self.TLSServerKey == "") {
+		return errors.New("TLS listeners require server_certificate and server_key in the tls section.")
+	}
+	if self.TLSClientAuth && self.TLSCACertificate == "" {
+		return errors.New("TLS client_auth requires ca_certificate in the tls section.")
+	}
+	// History server checks
+	if self.HistoryServerEnabled && !utils.IsSliceMember([]string{utils.MetaFile, utils.MetaGit, utils.MetaStorDB}, self.HistoryBackend) {
+		return fmt.Errorf("Unsupported history backend: %s", self.HistoryBackend)
+	}
 	// Rater checks
 	if self.RALsEnabled {
 		if self.RALsBalancer == utils.MetaInternal && !self.BalancerEnabled {
@@ -322,6 +359,28 @@ func (self *CGRConfig) checkConfigSanity() error {
 			}
 		}
 	}
+	// Scheduler checks
+	if len(self.SchedulerCdrExports) != 0 && (!self.SchedulerEnabled || !self.RALsEnabled) {
+		return errors.New("<Scheduler> CDR export jobs require both Scheduler and RALs enabled.")
+	}
+	jobIDs := make(map[string]bool)
+	for _, job := range self.SchedulerCdrExports {
+		if len(job.ID) == 0 {
+			return errors.New("<Scheduler> CDR export job without id")
+		} else if jobIDs[job.ID] {
+			return fmt.Errorf("<Scheduler> Duplicate CDR export job: %s", job.ID)
+		}
+		jobIDs[job.ID] = true
+		if _, hasIt := self.CdreProfiles[job.ExportTemplate]; !hasIt {
+			return fmt.Errorf("<Scheduler> CDR export job: %s, export_template not found: %s", job.ID, job.ExportTemplate)
+		}
+		if _, _, err := utils.RelativeTimeRange(job.TimeRange, time.Now()); err != nil {
+			return fmt.Errorf("<Scheduler> CDR export job: %s, %s", job.ID, err.Error())
+		}
+		if _, err := template.New(job.ID).Parse(job.FileName); err != nil {
+			return fmt.Errorf("<Scheduler> CDR export job: %s, invalid file_name: %s", job.ID, err.Error())
+		}
+	}
 	// CDRC sanity checks
 	for _, cdrcCfgs := range self.CdrcProfiles {
 		for _, cdrcInst := range cdrcCfgs {
@@ -439,6 +498,25 @@ func (self *CGRConfig) checkConfigSanity() error {
 				return errors.New("PubSubS not enabled but requested by DiameterAgent component.")
 			}
 		}
+		if !utils.IsSliceMember([]string{utils.MetaASR, utils.MetaRAR}, self.diameterAgentCfg.DisconnectMethod) {
+			return fmt.Errorf("<DiameterAgent> unsupported disconnect_method: %s", self.diameterAgentCfg.DisconnectMethod)
+		}
+	}
+	// RAgent checks
+	if self.radiusAgentCfg.Enabled {
+		for _, raSMGConn := range self.radiusAgentCfg.SMGenericConns {
+			if raSMGConn.Address == utils.MetaInternal && !self.SmGenericConfig.Enabled {
+				return errors.New("SMGeneric not enabled but referenced by RadiusAgent component")
+			}
+		}
+	}
+	// ResourceLimiter checks
+	if self.resourceLimiterCfg != nil && self.resourceLimiterCfg.Enabled {
+		for _, connCfg := range self.resourceLimiterCfg.CDRStatConns {
+			if connCfg.Address == utils.MetaInternal && !self.CDRStatsEnabled {
+				return errors.New("CDRStats not enabled but requested by ResourceLimiter component.")
+			}
+		}
 	}
 	return nil
 }
@@ -457,6 +535,16 @@ func (self *CGRConfig) loadFromJsonCfg(jsnCfg *CgrJsonCfg) error {
 		return err
 	}
 
+	jsnRpcAuthCfg, err := jsnCfg.RpcAuthJsonCfg()
+	if err != nil {
+		return err
+	}
+
+	jsnTlsCfg, err := jsnCfg.TlsJsonCfg()
+	if err != nil {
+		return err
+	}
+
 	jsnTpDbCfg, err := jsnCfg.DbJsonCfg(TPDB_JSN)
 	if err != nil {
 		return err
@@ -532,6 +620,11 @@ func (self *CGRConfig) loadFromJsonCfg(jsnCfg *CgrJsonCfg) error {
 		return err
 	}
 
+	jsnRACfg, err := jsnCfg.RadiusAgentJsonCfg()
+	if err != nil {
+		return err
+	}
+
 	jsnHistServCfg, err := jsnCfg.HistServJsonCfg()
 	if err != nil {
 		return err
@@ -552,6 +645,11 @@ func (self *CGRConfig) loadFromJsonCfg(jsnCfg *CgrJsonCfg) error {
 		return err
 	}
 
+	jsnRLSCfg, err := jsnCfg.ResourceLimiterServJsonCfg()
+	if err != nil {
+		return err
+	}
+
 	jsnMailerCfg, err := jsnCfg.MailerJsonCfg()
 	if err != nil {
 		return err
@@ -715,6 +813,61 @@ func (self *CGRConfig) loadFromJsonCfg(jsnCfg *CgrJsonCfg) error {
 		if jsnListenCfg.Http != nil {
 			self.HTTPListen = *jsnListenCfg.Http
 		}
+		if jsnListenCfg.Rpc_json_tls != nil {
+			self.RPCJSONTLSListen = *jsnListenCfg.Rpc_json_tls
+		}
+		if jsnListenCfg.Rpc_gob_tls != nil {
+			self.RPCGOBTLSListen = *jsnListenCfg.Rpc_gob_tls
+		}
+		if jsnListenCfg.Http_tls != nil {
+			self.HTTPTLSListen = *jsnListenCfg.Http_tls
+		}
+	}
+
+	if jsnRpcAuthCfg != nil {
+		if jsnRpcAuthCfg.Enabled != nil {
+			self.RPCAuthEnabled = *jsnRpcAuthCfg.Enabled
+		}
+		if jsnRpcAuthCfg.Trusted_addresses != nil {
+			self.RPCAuthTrustedAddrs = *jsnRpcAuthCfg.Trusted_addresses
+		}
+		if jsnRpcAuthCfg.Api_keys != nil {
+			if self.RPCApiKeys == nil {
+				self.RPCApiKeys = make(map[string]*utils.ApiKey)
+			}
+			for key, jsnApiKey := range *jsnRpcAuthCfg.Api_keys {
+				if _, hasKey := self.RPCApiKeys[key]; !hasKey {
+					self.RPCApiKeys[key] = new(utils.ApiKey)
+				}
+				if jsnApiKey.Tenants != nil {
+					self.RPCApiKeys[key].Tenants = *jsnApiKey.Tenants
+				}
+				if jsnApiKey.Methods != nil {
+					self.RPCApiKeys[key].Methods = *jsnApiKey.Methods
+				}
+			}
+		}
+	}
+
+	if jsnTlsCfg != nil {
+		if jsnTlsCfg.Server_certificate != nil {
+			self.TLSServerCertificate = *jsnTlsCfg.Server_certificate
+		}
+		if jsnTlsCfg.Server_key != nil {
+			self.TLSServerKey = *jsnTlsCfg.Server_key
+		}
+		if jsnTlsCfg.Client_auth != nil {
+			self.TLSClientAuth = *jsnTlsCfg.Client_auth
+		}
+		if jsnTlsCfg.Ca_certificate != nil {
+			self.TLSCACertificate = *jsnTlsCfg.Ca_certificate
+		}
+		if jsnTlsCfg.Client_certificate != nil {
+			self.TLSClientCertificate = *jsnTlsCfg.Client_certificate
+		}
+		if jsnTlsCfg.Client_key != nil {
+			self.TLSClientKey = *jsnTlsCfg.Client_key
+		}
 	}
 
 	if jsnRALsCfg != nil {
@@ -765,14 +918,51 @@ func (self *CGRConfig) loadFromJsonCfg(jsnCfg *CgrJsonCfg) error {
 		if jsnRALsCfg.Lcr_subject_prefix_matching != nil {
 			self.LcrSubjectPrefixMatching = *jsnRALsCfg.Lcr_subject_prefix_matching
 		}
+		if jsnRALsCfg.Balance_ledger != nil {
+			self.RALsBalanceLedger = *jsnRALsCfg.Balance_ledger
+		}
 	}
 
 	if jsnBalancerCfg != nil && jsnBalancerCfg.Enabled != nil {
 		self.BalancerEnabled = *jsnBalancerCfg.Enabled
 	}
 
-	if jsnSchedCfg != nil && jsnSchedCfg.Enabled != nil {
-		self.SchedulerEnabled = *jsnSchedCfg.Enabled
+	if jsnSchedCfg != nil {
+		if jsnSchedCfg.Enabled != nil {
+			self.SchedulerEnabled = *jsnSchedCfg.Enabled
+		}
+		if jsnSchedCfg.Cdr_exports != nil {
+			self.SchedulerCdrExports = make([]*CdrExportJobCfg, len(*jsnSchedCfg.Cdr_exports))
+			for idx, jobJsnCfg := range *jsnSchedCfg.Cdr_exports {
+				job := &CdrExportJobCfg{ExportTemplate: utils.META_DEFAULT}
+				if jobJsnCfg.Id != nil {
+					job.ID = *jobJsnCfg.Id
+				}
+				if jobJsnCfg.Export_template != nil {
+					job.ExportTemplate = *jobJsnCfg.Export_template
+				}
+				timingVals := []string{job.ID, utils.ANY, utils.ANY, utils.ANY, utils.ANY, "00:00:00"}
+				for i, val := range []*string{jobJsnCfg.Years, jobJsnCfg.Months, jobJsnCfg.Month_days, jobJsnCfg.Week_days, jobJsnCfg.Time} {
+					if val != nil {
+						timingVals[i+1] = *val
+					}
+				}
+				job.Timing = utils.NewTiming(timingVals...)
+				if jobJsnCfg.Time_range != nil {
+					job.TimeRange = *jobJsnCfg.Time_range
+				}
+				if jobJsnCfg.Cdrs_filter != nil {
+					job.CdrsFilter = jobJsnCfg.Cdrs_filter
+				}
+				if jobJsnCfg.Export_folder != nil {
+					job.ExportFolder = *jobJsnCfg.Export_folder
+				}
+				if jobJsnCfg.File_name != nil {
+					job.FileName = *jobJsnCfg.File_name
+				}
+				self.SchedulerCdrExports[idx] = job
+			}
+		}
 	}
 
 	if jsnCdrsCfg != nil {
@@ -846,6 +1036,14 @@ func (self *CGRConfig) loadFromJsonCfg(jsnCfg *CgrJsonCfg) error {
 				}
 			}
 		}
+		if jsnCdrsCfg.Replication_spool_dir != nil {
+			self.CDRSReplSpoolDir = *jsnCdrsCfg.Replication_spool_dir
+		}
+		if jsnCdrsCfg.Replication_retry_interval != nil {
+			if self.CDRSReplRetryInterval, err = utils.ParseDurationWithSecs(*jsnCdrsCfg.Replication_retry_interval); err != nil {
+				return err
+			}
+		}
 	}
 
 	if jsnCdrstatsCfg != nil {
@@ -924,10 +1122,19 @@ func (self *CGRConfig) loadFromJsonCfg(jsnCfg *CgrJsonCfg) error {
 		}
 	}
 
+	if jsnRACfg != nil {
+		if err := self.radiusAgentCfg.loadFromJsonCfg(jsnRACfg); err != nil {
+			return err
+		}
+	}
+
 	if jsnHistServCfg != nil {
 		if jsnHistServCfg.Enabled != nil {
 			self.HistoryServerEnabled = *jsnHistServCfg.Enabled
 		}
+		if jsnHistServCfg.Backend != nil {
+			self.HistoryBackend = *jsnHistServCfg.Backend
+		}
 		if jsnHistServCfg.History_dir != nil {
 			self.HistoryDir = *jsnHistServCfg.History_dir
 		}
@@ -959,6 +1166,15 @@ func (self *CGRConfig) loadFromJsonCfg(jsnCfg *CgrJsonCfg) error {
 		}
 	}
 
+	if jsnRLSCfg != nil {
+		if self.resourceLimiterCfg == nil {
+			self.resourceLimiterCfg = new(ResourceLimiterConfig)
+		}
+		if err := self.resourceLimiterCfg.loadFromJsonCfg(jsnRLSCfg); err != nil {
+			return err
+		}
+	}
+
 	if jsnMailerCfg != nil {
 		if jsnMailerCfg.Server != nil {
 			self.MailerServer = *jsnMailerCfg.Server
@@ -993,8 +1209,18 @@ func (self *CGRConfig) SureTaxCfg() *SureTaxCfg {
 	return self.sureTaxCfg
 }
 
+func (self *CGRConfig) ResourceLimiterCfg() *ResourceLimiterConfig {
+	return self.resourceLimiterCfg
+}
+
 func (self *CGRConfig) DiameterAgentCfg() *DiameterAgentCfg {
 	cfgChan := <-self.ConfigReloads[utils.DIAMETER_AGENT] // Lock config for read or reloads
 	defer func() { self.ConfigReloads[utils.DIAMETER_AGENT] <- cfgChan }()
 	return self.diameterAgentCfg
 }
+
+func (self *CGRConfig) RadiusAgentCfg() *RadiusAgentCfg {
+	cfgChan := <-self.ConfigReloads[utils.RADIUS_AGENT] // Lock config for read or reloads
+	defer func() { self.ConfigReloads[utils.RADIUS_AGENT] <- cfgChan }()
+	return self.radiusAgentCfg
+}
@@ -54,6 +54,26 @@ const CGRATES_CFG_JSON = `
 	"rpc_json": "127.0.0.1:2012",			// RPC JSON listening address
 	"rpc_gob": "127.0.0.1:2013",			// RPC GOB listening address
 	"http": "127.0.0.1:2080",				// HTTP listening address
+	"rpc_json_tls": "",						// RPC JSON over TLS listening address, empty to disable
+	"rpc_gob_tls": "",						// RPC GOB over TLS listening address, empty to disable
+	"http_tls": "",							// HTTPS listening address, empty to disable
+},
+
+
+"rpc_auth": {
+	"enabled": false,						// enables API keys authorization on the RPC listeners and /metrics: <true|false>
+	"trusted_addresses": ["127.0.0.1"],		// requests coming from these hosts are not authorized, BiRPC connections included
+	"api_keys": {},							// authorizations per API key, eg: {"$api_key": {"tenants": ["cgrates.org"], "methods": ["ApierV1.GetAccount", "CdrsV2."]}}
+},
+
+
+"tls": {
+	"server_certificate": "",				// path towards the certificate presented by the TLS listeners
+	"server_key": "",						// path towards the private key of the server certificate
+	"client_auth": false,					// require and verify client certificates on the TLS listeners, against ca_certificate: <true|false>
+	"ca_certificate": "",					// path towards the CA certificates verifying the peers, system ones if empty
+	"client_certificate": "",				// path towards the certificate presented on outbound TLS connections, empty for none
+	"client_key": "",						// path towards the private key of the client certificate
 },
 
 
@@ -105,12 +125,14 @@ const CGRATES_CFG_JSON = `
 	"users_conns": [],						// address where to reach the user service, empty to disable user profile functionality: <""|*internal|x.y.z.y:1234>
 	"aliases_conns": [],					// address where to reach the aliases service, empty to disable aliases functionality: <""|*internal|x.y.z.y:1234>
 	"rp_subject_prefix_matching": false,	// enables prefix matching for the rating profile subject
-	"lcr_subject_prefix_matching": false	// enables prefix matching for the lcr subject
+	"lcr_subject_prefix_matching": false,	// enables prefix matching for the lcr subject
+	"balance_ledger": false,				// write every balance change into the StorDB ledger: <true|false>
 },
 
 
 "scheduler": {
 	"enabled": false,						// start Scheduler service: <true|false>
+	"cdr_exports": [],						// CDR export jobs run by the scheduler, eg: {"id": "daily", "export_template": "*default", "time": "00:05:00", "time_range": "*yesterday", "cdrs_filter": {}, "export_folder": "", "file_name": ""}
 },
 
 
@@ -125,7 +147,9 @@ const CGRATES_CFG_JSON = `
 	"users_conns": [],						// address where to reach the user service, empty to disable user profile functionality: <""|*internal|x.y.z.y:1234>
 	"aliases_conns": [],					// address where to reach the aliases service, empty to disable aliases functionality: <""|*internal|x.y.z.y:1234>
 	"cdrstats_conns": [],					// address where to reach the cdrstats service, empty to disable stats functionality<""|*internal|x.y.z.y:1234>
-	"cdr_replication":[]					// replicate the raw CDR to a number of servers
+	"cdr_replication":[],					// replicate the raw CDR to a number of servers
+	"replication_spool_dir": "/var/spool/cgrates/cdr_replication",	// directory where failed replications are kept until replayed
+	"replication_retry_interval": "1m",		// interval to automatically replay the failed replications, 0 to disable
 },
 
 
@@ -142,7 +166,7 @@ const CGRATES_CFG_JSON = `
 		"cdrs_conns": [
 			{"address": "*internal"}					// address where to reach CDR server. <*internal|x.y.z.y:1234>
 		],
-		"cdr_format": "csv",							// CDR file format <csv|freeswitch_csv|fwv|opensips_flatstore|partial_csv>
+		"cdr_format": "csv",							// CDR file format <csv|freeswitch_csv|fwv|opensips_flatstore|partial_csv|xml|json|partial_json>
 		"field_separator": ",",							// separator used in case of csv files
 		"timezone": "",									// timezone for timestamps where not specified <""|UTC|Local|$IANA_TZ_DB>
 		"run_delay": 0,									// sleep interval in seconds between consecutive runs, 0 to use automation via inotify
@@ -151,7 +175,7 @@ const CGRATES_CFG_JSON = `
 		"cdr_in_dir": "/var/spool/cgrates/cdrc/in",		// absolute path towards the directory where the CDRs are stored
 		"cdr_out_dir": "/var/spool/cgrates/cdrc/out",	// absolute path towards the directory where processed CDRs will be moved
 		"failed_calls_prefix": "missed_calls",			// used in case of flatstore CDRs to avoid searching for BYE records
-		"cdr_path": "",									// path towards one CDR element in case of XML CDRs
+		"cdr_path": "",									// path towards one CDR element in case of XML or JSON CDRs
 		"cdr_source_id": "freeswitch_csv",				// free form field, tag identifying the source of the CDRs within CDRS database
 		"cdr_filter": "",								// filter CDR records to import
 		"continue_on_success": false,					// continue to the next template if executed
@@ -196,7 +220,7 @@ const CGRATES_CFG_JSON = `
 
 "cdre": {
 	"*default": {
-		"cdr_format": "csv",							// exported CDRs format <csv>
+		"cdr_format": "csv",							// exported CDRs format <csv|fwv|*jsonl|*xml>
 		"field_separator": ",",
 		"data_usage_multiply_factor": 1,				// multiply data usage before export (eg: convert from KBytes to Bytes)
 		"sms_usage_multiply_factor": 1,					// multiply data usage before export (eg: convert from SMS unit to call duration in some billing systems)
@@ -234,8 +258,9 @@ const CGRATES_CFG_JSON = `
 "sm_generic": {
 	"enabled": false,						// starts SessionManager service: <true|false>
 	"listen_bijson": "127.0.0.1:2014",		// address where to listen for bidirectional JSON-RPC requests
+	"listen_bijson_tls": "",				// address where to listen for bidirectional JSON-RPC requests over TLS, empty to disable
 	"rals_conns": [
-		{"address": "*internal"}				// address where to reach the Rater <""|*internal|127.0.0.1:2013>
+		{"address": "*internal"}				// address where to reach the Rater <""|*internal|127.0.0.1:2013>, add "tls": true to connect over TLS, "ca_certificate", "client_certificate" and "client_key" override the tls section per connection
 	],
 	"cdrs_conns": [
 		{"address": "*internal"}				// address where to reach CDR Server, empty to disable CDR capturing <*internal|x.y.z.y:1234>
@@ -246,6 +271,9 @@ const CGRATES_CFG_JSON = `
 	"session_ttl": "0s",					// time after a session with no updates is terminated, not defined by default
 	//"session_ttl_last_used": "",			// tweak LastUsed for sessions timing-out, not defined by default
 	//"session_ttl_usage": "",				// tweak Usage for sessions timing-out, not defined by default
+	"persist_sessions": false,				// checkpoint active sessions in data_db so they are recovered on restart
+	"recovered_session_ttl": "60s",			// recovered sessions not updated within this time are terminated, used when session_ttl is not defined
+	"session_replication_conns": [],		// replicate active sessions as passive ones to peer engines <x.y.z.y:1234>
 },
 
 
@@ -287,6 +315,7 @@ const CGRATES_CFG_JSON = `
 	"debit_interval": "10s",				// interval to perform debits on.
 	"min_call_duration": "0s",				// only authorize calls with allowed duration higher than this
 	"max_call_duration": "3h",				// maximum call duration a prepaid call can last
+	"channel_sync_interval": "5m",			// sync dialogs with kamailio regularly, 0 to disable
 	"evapi_conns":[							// instantiate connections to multiple Kamailio servers
 		{"address": "127.0.0.1:8448", "reconnects": 5}
 	],
@@ -309,6 +338,7 @@ const CGRATES_CFG_JSON = `
 	"max_call_duration": "3h",			// maximum call duration a prepaid call can last
 	"events_subscribe_interval": "60s",	// automatic events subscription to OpenSIPS, 0 to disable it
 	"mi_addr": "127.0.0.1:8020",		// address where to reach OpenSIPS MI to send session disconnects
+	"channel_sync_interval": "5m",		// sync dialogs with opensips regularly over MI, 0 to disable
 },
 
 
@@ -317,7 +347,7 @@ const CGRATES_CFG_JSON = `
 	"listen": "127.0.0.1:3868",									// address where to listen for diameter requests <x.y.z.y:1234>
 	"dictionaries_dir": "/usr/share/cgrates/diameter/dict/",	// path towards directory holding additional dictionaries to load
 	"sm_generic_conns": [
-		{"address": "*internal"}									// connection towards SMG component for session management
+		{"address": "*internal"}									// connection towards SMG component for session management, remote SMGs need "transport": "*bijson" towards their listen_bijson for session disconnects
 	],
 	"pubsubs_conns": [],										// address where to reach the pubusb service, empty to disable pubsub functionality: <""|*internal|x.y.z.y:1234>
 	"create_cdr": true,											// create CDR out of CCR terminate and send it to SMG component
@@ -328,13 +358,14 @@ const CGRATES_CFG_JSON = `
 	"origin_realm": "cgrates.org",								// diameter Origin-Realm AVP used in replies
 	"vendor_id": 0,												// diameter Vendor-Id AVP used in replies
 	"product_name": "CGRateS",									// diameter Product-Name AVP used in replies
+	"disconnect_method": "*asr",								// request sent to the client when SMG disconnects the session: <*asr|*rar>
 	"request_processors": [
 		{
 			"id": "*default",												// formal identifier of this processor
 			"dry_run": false,												// do not send the events to SMG, just log them
 			"publish_event": false,											// if enabled, it will publish internal event to pubsub
 			"request_filter": "Subscription-Id>Subscription-Id-Type(0)",	// filter requests processed by this processor
-			"flags": [],													// flags to influence processing behavior
+			"flags": [],													// flags to influence processing behavior, eg: <mscc> to process each Multiple-Services-Credit-Control separately
 			"continue_on_success": false,				// continue to the next template if executed
 			"append_cca": true,						// when continuing will append cca fields to the previous ones
 			"ccr_fields":[							// import content_fields template, tag will match internally CDR field, in case of .csv value will be represented by index of the field value
@@ -360,10 +391,28 @@ const CGRATES_CFG_JSON = `
 },
 
 
+"radius_agent": {
+	"enabled": false,											// enables the radius agent: <true|false>
+	"listen_auth": "127.0.0.1:1812",							// address where to listen for radius authentication requests <x.y.z.y:1234>
+	"listen_acct": "127.0.0.1:1813",							// address where to listen for radius accounting requests <x.y.z.y:1234>
+	"client_secrets": {											// hash containing secrets for clients connecting here <*default|$client_ip>
+		"*default": "CGRateS.org"
+	},
+	"dictionaries_dir": "/usr/share/cgrates/radius/dict/",		// path towards directory holding additional dictionaries to load
+	"sm_generic_conns": [
+		{"address": "*internal"}									// connection towards SMG component for session management
+	],
+	"create_cdr": true,											// create CDR out of Accounting-Stop and send it to SMG component
+	"timezone": "",												// timezone for timestamps where not specified, empty for general defaults <""|UTC|Local|$IANA_TZ_DB>
+	"request_processors": [],									// request processors, matched against *radReqType and request attributes
+},
+
+
 "historys": {
 	"enabled": false,							// starts History service: <true|false>.
-	"history_dir": "/var/lib/cgrates/history",	// location on disk where to store history files.
-	"save_interval": "1s",						// interval to save changed cache into .git archive
+	"backend": "*file",							// where to store the change records: <*file|*git|*stordb>
+	"history_dir": "/var/lib/cgrates/history",	// location on disk where to store history files, for *file and *git backends
+	"save_interval": "1s",						// interval to save changed cache into .git archive, *git backend only
 },
 
 
@@ -383,6 +432,13 @@ const CGRATES_CFG_JSON = `
 },
 
 
+"rls": {
+	"enabled": false,							// starts ResourceLimiter service: <true|false>.
+	"cdrstats_conns": [],						// address where to reach the cdrstats service, empty to disable stats functionality: <""|*internal|x.y.z.y:1234>
+	"usage_ttl": "3h",							// expire usage records if older than this duration, 0 to disable
+},
+
+
 "mailer": {
 	"server": "localhost",								// the server to use when sending emails out
 	"auth_user": "cgrates",								// authenticate to email server using this user
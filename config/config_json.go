@@ -29,6 +29,8 @@ import (
 const (
 	GENERAL_JSN     = "general"
 	LISTEN_JSN      = "listen"
+	RPCAUTH_JSN     = "rpc_auth"
+	TLS_JSN         = "tls"
 	TPDB_JSN        = "tariffplan_db"
 	DATADB_JSN      = "data_db"
 	STORDB_JSN      = "stor_db"
@@ -49,10 +51,12 @@ const (
 	KAMAILIO_JSN    = "kamailio"
 	OSIPS_JSN       = "opensips"
 	DA_JSN          = "diameter_agent"
+	RA_JSN          = "radius_agent"
 	HISTSERV_JSN    = "historys"
 	PUBSUBSERV_JSN  = "pubsubs"
 	ALIASESSERV_JSN = "aliases"
 	USERSERV_JSN    = "users"
+	RLSSERV_JSN     = "rls"
 	MAILER_JSN      = "mailer"
 	SURETAX_JSON    = "suretax"
 )
@@ -260,6 +264,18 @@ func (self CgrJsonCfg) DiameterAgentJsonCfg() (*DiameterAgentJsonCfg, error) {
 	return cfg, nil
 }
 
+func (self CgrJsonCfg) RadiusAgentJsonCfg() (*RadiusAgentJsonCfg, error) {
+	rawCfg, hasKey := self[RA_JSN]
+	if !hasKey {
+		return nil, nil
+	}
+	cfg := new(RadiusAgentJsonCfg)
+	if err := json.Unmarshal(*rawCfg, cfg); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
 func (self CgrJsonCfg) HistServJsonCfg() (*HistServJsonCfg, error) {
 	rawCfg, hasKey := self[HISTSERV_JSN]
 	if !hasKey {
@@ -296,6 +312,30 @@ func (self CgrJsonCfg) AliasesServJsonCfg() (*AliasesServJsonCfg, error) {
 	return cfg, nil
 }
 
+func (self CgrJsonCfg) RpcAuthJsonCfg() (*RpcAuthJsonCfg, error) {
+	rawCfg, hasKey := self[RPCAUTH_JSN]
+	if !hasKey {
+		return nil, nil
+	}
+	cfg := new(RpcAuthJsonCfg)
+	if err := json.Unmarshal(*rawCfg, cfg); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+func (self CgrJsonCfg) TlsJsonCfg() (*TlsJsonCfg, error) {
+	rawCfg, hasKey := self[TLS_JSN]
+	if !hasKey {
+		return nil, nil
+	}
+	cfg := new(TlsJsonCfg)
+	if err := json.Unmarshal(*rawCfg, cfg); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
 func (self CgrJsonCfg) UserServJsonCfg() (*UserServJsonCfg, error) {
 	rawCfg, hasKey := self[USERSERV_JSN]
 	if !hasKey {
@@ -308,6 +348,18 @@ func (self CgrJsonCfg) UserServJsonCfg() (*UserServJsonCfg, error) {
 	return cfg, nil
 }
 
+func (self CgrJsonCfg) ResourceLimiterServJsonCfg() (*ResourceLimiterServJsonCfg, error) {
+	rawCfg, hasKey := self[RLSSERV_JSN]
+	if !hasKey {
+		return nil, nil
+	}
+	cfg := new(ResourceLimiterServJsonCfg)
+	if err := json.Unmarshal(*rawCfg, cfg); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
 func (self CgrJsonCfg) MailerJsonCfg() (*MailerJsonCfg, error) {
 	rawCfg, hasKey := self[MAILER_JSN]
 	if !hasKey {
@@ -66,9 +66,12 @@ func TestDfGeneralJsonCfg(t *testing.T) {
 
 func TestDfListenJsonCfg(t *testing.T) {
 	eCfg := &ListenJsonCfg{
-		Rpc_json: utils.StringPointer("127.0.0.1:2012"),
-		Rpc_gob:  utils.StringPointer("127.0.0.1:2013"),
-		Http:     utils.StringPointer("127.0.0.1:2080")}
+		Rpc_json:     utils.StringPointer("127.0.0.1:2012"),
+		Rpc_gob:      utils.StringPointer("127.0.0.1:2013"),
+		Http:         utils.StringPointer("127.0.0.1:2080"),
+		Rpc_json_tls: utils.StringPointer(""),
+		Rpc_gob_tls:  utils.StringPointer(""),
+		Http_tls:     utils.StringPointer("")}
 	if cfg, err := dfCgrJsonCfg.ListenJsonCfg(); err != nil {
 		t.Error(err)
 	} else if !reflect.DeepEqual(eCfg, cfg) {
@@ -76,6 +79,35 @@ func TestDfListenJsonCfg(t *testing.T) {
 	}
 }
 
+func TestDfRpcAuthJsonCfg(t *testing.T) {
+	eCfg := &RpcAuthJsonCfg{
+		Enabled:           utils.BoolPointer(false),
+		Trusted_addresses: &[]string{"127.0.0.1"},
+		Api_keys:          &map[string]*ApiKeyJsonCfg{},
+	}
+	if cfg, err := dfCgrJsonCfg.RpcAuthJsonCfg(); err != nil {
+		t.Error(err)
+	} else if !reflect.DeepEqual(eCfg, cfg) {
+		t.Error("Received: ", cfg)
+	}
+}
+
+func TestDfTlsJsonCfg(t *testing.T) {
+	eCfg := &TlsJsonCfg{
+		Server_certificate: utils.StringPointer(""),
+		Server_key:         utils.StringPointer(""),
+		Client_auth:        utils.BoolPointer(false),
+		Ca_certificate:     utils.StringPointer(""),
+		Client_certificate: utils.StringPointer(""),
+		Client_key:         utils.StringPointer(""),
+	}
+	if cfg, err := dfCgrJsonCfg.TlsJsonCfg(); err != nil {
+		t.Error(err)
+	} else if !reflect.DeepEqual(eCfg, cfg) {
+		t.Error("Received: ", cfg)
+	}
+}
+
 func TestDfDbJsonCfg(t *testing.T) {
 	eCfg := &DbJsonCfg{
 		Db_type:     utils.StringPointer("redis"),
@@ -134,7 +166,8 @@ func TestDfBalancerJsonCfg(t *testing.T) {
 func TestDfRalsJsonCfg(t *testing.T) {
 	eCfg := &RalsJsonCfg{Enabled: utils.BoolPointer(false), Balancer: utils.StringPointer(""), Cdrstats_conns: &[]*HaPoolJsonCfg{},
 		Historys_conns: &[]*HaPoolJsonCfg{}, Pubsubs_conns: &[]*HaPoolJsonCfg{}, Users_conns: &[]*HaPoolJsonCfg{}, Aliases_conns: &[]*HaPoolJsonCfg{},
-		Rp_subject_prefix_matching: utils.BoolPointer(false), Lcr_subject_prefix_matching: utils.BoolPointer(false)}
+		Rp_subject_prefix_matching: utils.BoolPointer(false), Lcr_subject_prefix_matching: utils.BoolPointer(false),
+		Balance_ledger: utils.BoolPointer(false)}
 	if cfg, err := dfCgrJsonCfg.RalsJsonCfg(); err != nil {
 		t.Error(err)
 	} else if !reflect.DeepEqual(eCfg, cfg) {
@@ -143,7 +176,7 @@ func TestDfRalsJsonCfg(t *testing.T) {
 }
 
 func TestDfSchedulerJsonCfg(t *testing.T) {
-	eCfg := &SchedulerJsonCfg{Enabled: utils.BoolPointer(false)}
+	eCfg := &SchedulerJsonCfg{Enabled: utils.BoolPointer(false), Cdr_exports: &[]*CdrExportJobJsonCfg{}}
 	if cfg, err := dfCgrJsonCfg.SchedulerJsonCfg(); err != nil {
 		t.Error(err)
 	} else if !reflect.DeepEqual(eCfg, cfg) {
@@ -160,11 +193,13 @@ func TestDfCdrsJsonCfg(t *testing.T) {
 			&HaPoolJsonCfg{
 				Address: utils.StringPointer("*internal"),
 			}},
-		Pubsubs_conns:   &[]*HaPoolJsonCfg{},
-		Users_conns:     &[]*HaPoolJsonCfg{},
-		Aliases_conns:   &[]*HaPoolJsonCfg{},
-		Cdrstats_conns:  &[]*HaPoolJsonCfg{},
-		Cdr_replication: &[]*CdrReplicationJsonCfg{},
+		Pubsubs_conns:              &[]*HaPoolJsonCfg{},
+		Users_conns:                &[]*HaPoolJsonCfg{},
+		Aliases_conns:              &[]*HaPoolJsonCfg{},
+		Cdrstats_conns:             &[]*HaPoolJsonCfg{},
+		Cdr_replication:            &[]*CdrReplicationJsonCfg{},
+		Replication_spool_dir:      utils.StringPointer("/var/spool/cgrates/cdr_replication"),
+		Replication_retry_interval: utils.StringPointer("1m"),
 	}
 	if cfg, err := dfCgrJsonCfg.CdrsJsonCfg(); err != nil {
 		t.Error(err)
@@ -379,8 +414,9 @@ func TestDfCdrcJsonCfg(t *testing.T) {
 
 func TestSmGenericJsonCfg(t *testing.T) {
 	eCfg := &SmGenericJsonCfg{
-		Enabled:       utils.BoolPointer(false),
-		Listen_bijson: utils.StringPointer("127.0.0.1:2014"),
+		Enabled:           utils.BoolPointer(false),
+		Listen_bijson:     utils.StringPointer("127.0.0.1:2014"),
+		Listen_bijson_tls: utils.StringPointer(""),
 		Rals_conns: &[]*HaPoolJsonCfg{
 			&HaPoolJsonCfg{
 				Address: utils.StringPointer(utils.MetaInternal),
@@ -389,10 +425,13 @@ func TestSmGenericJsonCfg(t *testing.T) {
 			&HaPoolJsonCfg{
 				Address: utils.StringPointer(utils.MetaInternal),
 			}},
-		Debit_interval:    utils.StringPointer("0s"),
-		Min_call_duration: utils.StringPointer("0s"),
-		Max_call_duration: utils.StringPointer("3h"),
-		Session_ttl:       utils.StringPointer("0s"),
+		Debit_interval:            utils.StringPointer("0s"),
+		Min_call_duration:         utils.StringPointer("0s"),
+		Max_call_duration:         utils.StringPointer("3h"),
+		Session_ttl:               utils.StringPointer("0s"),
+		Persist_sessions:          utils.BoolPointer(false),
+		Recovered_session_ttl:     utils.StringPointer("60s"),
+		Session_replication_conns: &[]*HaPoolJsonCfg{},
 	}
 	if cfg, err := dfCgrJsonCfg.SmGenericJsonCfg(); err != nil {
 		t.Error(err)
@@ -449,10 +488,11 @@ func TestSmKamJsonCfg(t *testing.T) {
 			&HaPoolJsonCfg{
 				Address: utils.StringPointer(utils.MetaInternal),
 			}},
-		Create_cdr:        utils.BoolPointer(false),
-		Debit_interval:    utils.StringPointer("10s"),
-		Min_call_duration: utils.StringPointer("0s"),
-		Max_call_duration: utils.StringPointer("3h"),
+		Create_cdr:            utils.BoolPointer(false),
+		Debit_interval:        utils.StringPointer("10s"),
+		Min_call_duration:     utils.StringPointer("0s"),
+		Max_call_duration:     utils.StringPointer("3h"),
+		Channel_sync_interval: utils.StringPointer("5m"),
 		Evapi_conns: &[]*KamConnJsonCfg{
 			&KamConnJsonCfg{
 				Address:    utils.StringPointer("127.0.0.1:8448"),
@@ -485,6 +525,7 @@ func TestSmOsipsJsonCfg(t *testing.T) {
 		Max_call_duration:         utils.StringPointer("3h"),
 		Events_subscribe_interval: utils.StringPointer("60s"),
 		Mi_addr:                   utils.StringPointer("127.0.0.1:8020"),
+		Channel_sync_interval:     utils.StringPointer("5m"),
 	}
 	if cfg, err := dfCgrJsonCfg.SmOsipsJsonCfg(); err != nil {
 		t.Error(err)
@@ -502,15 +543,16 @@ func TestDiameterAgentJsonCfg(t *testing.T) {
 			&HaPoolJsonCfg{
 				Address: utils.StringPointer(utils.MetaInternal),
 			}},
-		Pubsubs_conns:  &[]*HaPoolJsonCfg{},
-		Create_cdr:     utils.BoolPointer(true),
-		Debit_interval: utils.StringPointer("5m"),
-		Timezone:       utils.StringPointer(""),
-		Dialect:        utils.StringPointer("huawei"),
-		Origin_host:    utils.StringPointer("CGR-DA"),
-		Origin_realm:   utils.StringPointer("cgrates.org"),
-		Vendor_id:      utils.IntPointer(0),
-		Product_name:   utils.StringPointer("CGRateS"),
+		Pubsubs_conns:     &[]*HaPoolJsonCfg{},
+		Create_cdr:        utils.BoolPointer(true),
+		Debit_interval:    utils.StringPointer("5m"),
+		Timezone:          utils.StringPointer(""),
+		Dialect:           utils.StringPointer("huawei"),
+		Origin_host:       utils.StringPointer("CGR-DA"),
+		Origin_realm:      utils.StringPointer("cgrates.org"),
+		Vendor_id:         utils.IntPointer(0),
+		Product_name:      utils.StringPointer("CGRateS"),
+		Disconnect_method: utils.StringPointer(utils.MetaASR),
 		Request_processors: &[]*DARequestProcessorJsnCfg{
 			&DARequestProcessorJsnCfg{
 				Id:                  utils.StringPointer("*default"),
@@ -563,9 +605,32 @@ func TestDiameterAgentJsonCfg(t *testing.T) {
 	}
 }
 
+func TestRadiusAgentJsonCfg(t *testing.T) {
+	eCfg := &RadiusAgentJsonCfg{
+		Enabled:          utils.BoolPointer(false),
+		Listen_auth:      utils.StringPointer("127.0.0.1:1812"),
+		Listen_acct:      utils.StringPointer("127.0.0.1:1813"),
+		Client_secrets:   &map[string]string{utils.META_DEFAULT: "CGRateS.org"},
+		Dictionaries_dir: utils.StringPointer("/usr/share/cgrates/radius/dict/"),
+		Sm_generic_conns: &[]*HaPoolJsonCfg{
+			&HaPoolJsonCfg{
+				Address: utils.StringPointer(utils.MetaInternal),
+			}},
+		Create_cdr:         utils.BoolPointer(true),
+		Timezone:           utils.StringPointer(""),
+		Request_processors: &[]*RARequestProcessorJsnCfg{},
+	}
+	if cfg, err := dfCgrJsonCfg.RadiusAgentJsonCfg(); err != nil {
+		t.Error(err)
+	} else if !reflect.DeepEqual(eCfg, cfg) {
+		t.Errorf("Expecting: %+v, received: %+v", eCfg, cfg)
+	}
+}
+
 func TestDfHistServJsonCfg(t *testing.T) {
 	eCfg := &HistServJsonCfg{
 		Enabled:       utils.BoolPointer(false),
+		Backend:       utils.StringPointer(utils.MetaFile),
 		History_dir:   utils.StringPointer("/var/lib/cgrates/history"),
 		Save_interval: utils.StringPointer("1s"),
 	}
@@ -610,6 +675,19 @@ func TestDfUserServJsonCfg(t *testing.T) {
 	}
 }
 
+func TestDfResourceLimiterServJsonCfg(t *testing.T) {
+	eCfg := &ResourceLimiterServJsonCfg{
+		Enabled:        utils.BoolPointer(false),
+		Cdrstats_conns: &[]*HaPoolJsonCfg{},
+		Usage_ttl:      utils.StringPointer("3h"),
+	}
+	if cfg, err := dfCgrJsonCfg.ResourceLimiterServJsonCfg(); err != nil {
+		t.Error(err)
+	} else if !reflect.DeepEqual(eCfg, cfg) {
+		t.Errorf("Received: %+v", cfg)
+	}
+}
+
 func TestDfMailerJsonCfg(t *testing.T) {
 	eCfg := &MailerJsonCfg{
 		Server:        utils.StringPointer("localhost"),
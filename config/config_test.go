@@ -21,6 +21,8 @@ package config
 import (
 	"reflect"
 	"testing"
+
+	"github.com/cgrates/cgrates/utils"
 )
 
 var cfg *CGRConfig
@@ -58,3 +60,123 @@ func TestLoadCgrCfgWithDefaults(t *testing.T) {
 		t.Errorf("Expected: %+v, received: %+v", eCgrCfg.SmFsConfig, cgrCfg.SmFsConfig)
 	}
 }
+
+func TestLoadCgrCfgRpcAuth(t *testing.T) {
+	JSN_CFG := `
+{
+"rpc_auth": {
+	"enabled": true,
+	"api_keys": {
+		"reseller1key": {"tenants": ["reseller1.org"], "methods": ["ApierV1.GetAccount", "CdrsV2."]},
+	},
+},
+}`
+	eApiKeys := map[string]*utils.ApiKey{
+		"reseller1key": &utils.ApiKey{Tenants: []string{"reseller1.org"}, Methods: []string{"ApierV1.GetAccount", "CdrsV2."}},
+	}
+	if cgrCfg, err := NewCGRConfigFromJsonStringWithDefaults(JSN_CFG); err != nil {
+		t.Error(err)
+	} else if !cgrCfg.RPCAuthEnabled || !reflect.DeepEqual(cgrCfg.RPCAuthTrustedAddrs, []string{"127.0.0.1"}) {
+		t.Errorf("Received: %v, %v", cgrCfg.RPCAuthEnabled, cgrCfg.RPCAuthTrustedAddrs)
+	} else if !reflect.DeepEqual(eApiKeys, cgrCfg.RPCApiKeys) {
+		t.Errorf("Expected: %s, received: %s", utils.ToJSON(eApiKeys), utils.ToJSON(cgrCfg.RPCApiKeys))
+	}
+}
+
+func TestLoadCgrCfgTLS(t *testing.T) {
+	JSN_CFG := `
+{
+"listen": {
+	"rpc_json_tls": "0.0.0.0:2022",
+},
+"tls": {
+	"server_certificate": "/etc/cgrates/tls/server.crt",
+	"server_key": "/etc/cgrates/tls/server.key",
+	"client_auth": true,
+},
+"sm_generic": {
+	"rals_conns": [
+		{"address": "10.0.0.1:2013", "tls": true},
+		{"address": "10.0.0.2:2013", "tls": true, "ca_certificate": "/etc/cgrates/tls/peer_ca.crt",
+			"client_certificate": "/etc/cgrates/tls/peer.crt", "client_key": "/etc/cgrates/tls/peer.key"},
+	],
+},
+}`
+	if cgrCfg, err := NewCGRConfigFromJsonStringWithDefaults(JSN_CFG); err != nil {
+		t.Error(err)
+	} else if cgrCfg.RPCJSONTLSListen != "0.0.0.0:2022" || cgrCfg.TLSServerCertificate != "/etc/cgrates/tls/server.crt" ||
+		cgrCfg.TLSServerKey != "/etc/cgrates/tls/server.key" || !cgrCfg.TLSClientAuth {
+		t.Errorf("Received: %s", utils.ToJSON(cgrCfg))
+	} else if eConns := []*HaPoolConfig{&HaPoolConfig{Address: "10.0.0.1:2013", TLS: true},
+		&HaPoolConfig{Address: "10.0.0.2:2013", TLS: true, CACertificate: "/etc/cgrates/tls/peer_ca.crt",
+			ClientCertificate: "/etc/cgrates/tls/peer.crt", ClientKey: "/etc/cgrates/tls/peer.key"}}; !reflect.DeepEqual(eConns, cgrCfg.SmGenericConfig.RALsConns) {
+		t.Errorf("Expected: %s, received: %s", utils.ToJSON(eConns), utils.ToJSON(cgrCfg.SmGenericConfig.RALsConns))
+	}
+	JSN_CFG = `
+{
+"listen": {
+	"rpc_json_tls": "0.0.0.0:2022",
+},
+}`
+	if cgrCfg, err := NewCGRConfigFromJsonStringWithDefaults(JSN_CFG); err != nil {
+		t.Error(err)
+	} else if err := cgrCfg.checkConfigSanity(); err == nil {
+		t.Error("Expecting error for TLS listener without certificate")
+	}
+	JSN_CFG = `
+{
+"tls": {
+	"client_auth": true,
+},
+}`
+	if cgrCfg, err := NewCGRConfigFromJsonStringWithDefaults(JSN_CFG); err != nil {
+		t.Error(err)
+	} else if err := cgrCfg.checkConfigSanity(); err == nil {
+		t.Error("Expecting error for TLS client authentication without CA certificate")
+	}
+}
+
+func TestLoadCgrCfgSchedulerCdrExports(t *testing.T) {
+	JSN_CFG := `
+{
+"rals": {"enabled": true},
+"scheduler": {
+	"enabled": true,
+	"cdr_exports": [
+		{"id": "daily", "time": "00:05:00", "time_range": "*yesterday", "cdrs_filter": {"RunIDs": ["*default"]},
+			"export_folder": "/var/spool/cgrates/cdre/daily", "file_name": "{{.JobID}}_{{.RangeStart.Format \"20060102\"}}.csv"},
+	],
+},
+}`
+	eJob := &CdrExportJobCfg{
+		ID:             "daily",
+		ExportTemplate: utils.META_DEFAULT,
+		Timing:         &utils.TPTiming{TimingId: "daily", Years: utils.Years{}, Months: utils.Months{}, MonthDays: utils.MonthDays{}, WeekDays: utils.WeekDays{}, StartTime: "00:05:00"},
+		TimeRange:      utils.MetaYesterday,
+		CdrsFilter:     &utils.RPCCDRsFilter{RunIDs: []string{utils.META_DEFAULT}},
+		ExportFolder:   "/var/spool/cgrates/cdre/daily",
+		FileName:       `{{.JobID}}_{{.RangeStart.Format "20060102"}}.csv`,
+	}
+	if cgrCfg, err := NewCGRConfigFromJsonStringWithDefaults(JSN_CFG); err != nil {
+		t.Error(err)
+	} else if err := cgrCfg.checkConfigSanity(); err != nil {
+		t.Error(err)
+	} else if len(cgrCfg.SchedulerCdrExports) != 1 || !reflect.DeepEqual(eJob, cgrCfg.SchedulerCdrExports[0]) {
+		t.Errorf("Expected: %s, received: %s", utils.ToJSON(eJob), utils.ToJSON(cgrCfg.SchedulerCdrExports))
+	}
+	JSN_CFG = `
+{
+"rals": {"enabled": true},
+"scheduler": {
+	"enabled": true,
+	"cdr_exports": [
+		{"id": "daily", "export_template": "*missing"},
+	],
+},
+}`
+	if cgrCfg, err := NewCGRConfigFromJsonStringWithDefaults(JSN_CFG); err != nil {
+		t.Error(err)
+	} else if err := cgrCfg.checkConfigSanity(); err == nil {
+		t.Error("Expecting error for unknown export template")
+	}
+}
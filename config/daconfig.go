@@ -38,6 +38,7 @@ type DiameterAgentCfg struct {
 	OriginRealm       string
 	VendorId          int
 	ProductName       string
+	DisconnectMethod  string // request sent towards the client when SMG disconnects a session <*asr|*rar>
 	RequestProcessors []*DARequestProcessor
 }
 
@@ -92,6 +93,9 @@ func (self *DiameterAgentCfg) loadFromJsonCfg(jsnCfg *DiameterAgentJsonCfg) erro
 	if jsnCfg.Product_name != nil {
 		self.ProductName = *jsnCfg.Product_name
 	}
+	if jsnCfg.Disconnect_method != nil {
+		self.DisconnectMethod = *jsnCfg.Disconnect_method
+	}
 	if jsnCfg.Request_processors != nil {
 		for _, reqProcJsn := range *jsnCfg.Request_processors {
 			rp := new(DARequestProcessor)
@@ -19,19 +19,24 @@ along with this program.  If not, see <http://www.gnu.org/licenses/>
 package config
 
 import (
-	"fmt"
 	"github.com/cgrates/cgrates/utils"
-	"net/url"
 )
 
+// CDR export job run by the scheduler, exports the CDRs created since its previous run
+type CdrExportJobCfg struct {
+	ID             string
+	ExportTemplate string          // CDRE profile used for the export
+	Timing         *utils.TPTiming // When the job runs
+	TimeRange      string          // Relative range matched on CDR creation time <""|*last_hour|*today|*yesterday|*this_month|*last_month>
+	CdrsFilter     *utils.RPCCDRsFilter
+	ExportFolder   string // Overwrites the export_dir of the template if not empty
+	FileName       string // Template of the exported file name, empty for the default cdre_<ExportID>.<format>
+}
+
 type CdrReplicationCfg struct {
-	Transport   string
+	Transport   string // <*http_post|*http_json|*amqp|*kafka>
 	Address     string
 	Synchronous bool
 	Attempts    int             // Number of attempts if not success
 	CdrFilter   utils.RSRFields // Only replicate if the filters here are matching
 }
-
-func (rplCfg CdrReplicationCfg) FallbackFileName() string {
-	return fmt.Sprintf("cdr_%s_%s_%s.form", rplCfg.Transport, url.QueryEscape(rplCfg.Address), utils.GenUUID())
-}
@@ -18,6 +18,10 @@ along with this program.  If not, see <http://www.gnu.org/licenses/>
 
 package config
 
+import (
+	"github.com/cgrates/cgrates/utils"
+)
+
 // General config section
 type GeneralJsonCfg struct {
 	Http_skip_tls_verify *bool
@@ -42,9 +46,34 @@ type GeneralJsonCfg struct {
 
 // Listen config section
 type ListenJsonCfg struct {
-	Rpc_json *string
-	Rpc_gob  *string
-	Http     *string
+	Rpc_json     *string
+	Rpc_gob      *string
+	Http         *string
+	Rpc_json_tls *string
+	Rpc_gob_tls  *string
+	Http_tls     *string
+}
+
+// API keys authorization for the RPC listeners
+type RpcAuthJsonCfg struct {
+	Enabled           *bool
+	Trusted_addresses *[]string
+	Api_keys          *map[string]*ApiKeyJsonCfg
+}
+
+type ApiKeyJsonCfg struct {
+	Tenants *[]string
+	Methods *[]string
+}
+
+// Certificates used by the TLS listeners and outbound connections
+type TlsJsonCfg struct {
+	Server_certificate *string
+	Server_key         *string
+	Client_auth        *bool
+	Ca_certificate     *string
+	Client_certificate *string
+	Client_key         *string
 }
 
 // Database config
@@ -77,24 +106,43 @@ type RalsJsonCfg struct {
 	Users_conns                 *[]*HaPoolJsonCfg
 	Rp_subject_prefix_matching  *bool
 	Lcr_subject_prefix_matching *bool
+	Balance_ledger              *bool
 }
 
 // Scheduler config section
 type SchedulerJsonCfg struct {
-	Enabled *bool
+	Enabled     *bool
+	Cdr_exports *[]*CdrExportJobJsonCfg
+}
+
+// CDR export job run by the scheduler
+type CdrExportJobJsonCfg struct {
+	Id              *string
+	Export_template *string
+	Years           *string
+	Months          *string
+	Month_days      *string
+	Week_days       *string
+	Time            *string
+	Time_range      *string
+	Cdrs_filter     *utils.RPCCDRsFilter
+	Export_folder   *string
+	File_name       *string
 }
 
 // Cdrs config section
 type CdrsJsonCfg struct {
-	Enabled         *bool
-	Extra_fields    *[]string
-	Store_cdrs      *bool
-	Rals_conns      *[]*HaPoolJsonCfg
-	Pubsubs_conns   *[]*HaPoolJsonCfg
-	Users_conns     *[]*HaPoolJsonCfg
-	Aliases_conns   *[]*HaPoolJsonCfg
-	Cdrstats_conns  *[]*HaPoolJsonCfg
-	Cdr_replication *[]*CdrReplicationJsonCfg
+	Enabled                    *bool
+	Extra_fields               *[]string
+	Store_cdrs                 *bool
+	Rals_conns                 *[]*HaPoolJsonCfg
+	Pubsubs_conns              *[]*HaPoolJsonCfg
+	Users_conns                *[]*HaPoolJsonCfg
+	Aliases_conns              *[]*HaPoolJsonCfg
+	Cdrstats_conns             *[]*HaPoolJsonCfg
+	Cdr_replication            *[]*CdrReplicationJsonCfg
+	Replication_spool_dir      *string
+	Replication_retry_interval *string
 }
 
 type CdrReplicationJsonCfg struct {
@@ -175,16 +223,20 @@ type CdrcJsonCfg struct {
 
 // SM-Generic config section
 type SmGenericJsonCfg struct {
-	Enabled               *bool
-	Listen_bijson         *string
-	Rals_conns            *[]*HaPoolJsonCfg
-	Cdrs_conns            *[]*HaPoolJsonCfg
-	Debit_interval        *string
-	Min_call_duration     *string
-	Max_call_duration     *string
-	Session_ttl           *string
-	Session_ttl_last_used *string
-	Session_ttl_usage     *string
+	Enabled                   *bool
+	Listen_bijson             *string
+	Listen_bijson_tls         *string
+	Rals_conns                *[]*HaPoolJsonCfg
+	Cdrs_conns                *[]*HaPoolJsonCfg
+	Debit_interval            *string
+	Min_call_duration         *string
+	Max_call_duration         *string
+	Session_ttl               *string
+	Session_ttl_last_used     *string
+	Session_ttl_usage         *string
+	Persist_sessions          *bool
+	Recovered_session_ttl     *string
+	Session_replication_conns *[]*HaPoolJsonCfg
 }
 
 // SM-FreeSWITCH config section
@@ -209,8 +261,12 @@ type SmFsJsonCfg struct {
 
 // Represents one connection instance towards a rater/cdrs server
 type HaPoolJsonCfg struct {
-	Address   *string
-	Transport *string
+	Address            *string
+	Transport          *string
+	Tls                *bool
+	Ca_certificate     *string
+	Client_certificate *string
+	Client_key         *string
 }
 
 // Represents one connection instance towards FreeSWITCH
@@ -222,14 +278,15 @@ type FsConnJsonCfg struct {
 
 // SM-Kamailio config section
 type SmKamJsonCfg struct {
-	Enabled           *bool
-	Rals_conns        *[]*HaPoolJsonCfg
-	Cdrs_conns        *[]*HaPoolJsonCfg
-	Create_cdr        *bool
-	Debit_interval    *string
-	Min_call_duration *string
-	Max_call_duration *string
-	Evapi_conns       *[]*KamConnJsonCfg
+	Enabled               *bool
+	Rals_conns            *[]*HaPoolJsonCfg
+	Cdrs_conns            *[]*HaPoolJsonCfg
+	Create_cdr            *bool
+	Debit_interval        *string
+	Min_call_duration     *string
+	Max_call_duration     *string
+	Channel_sync_interval *string
+	Evapi_conns           *[]*KamConnJsonCfg
 }
 
 // Represents one connection instance towards Kamailio
@@ -250,6 +307,7 @@ type SmOsipsJsonCfg struct {
 	Max_call_duration         *string
 	Events_subscribe_interval *string
 	Mi_addr                   *string
+	Channel_sync_interval     *string
 }
 
 // Represents one connection instance towards OpenSIPS
@@ -273,6 +331,7 @@ type DiameterAgentJsonCfg struct {
 	Origin_realm       *string
 	Vendor_id          *int
 	Product_name       *string
+	Disconnect_method  *string // request sent towards the client on SMG disconnect <*asr|*rar>
 	Request_processors *[]*DARequestProcessorJsnCfg
 }
 
@@ -289,9 +348,35 @@ type DARequestProcessorJsnCfg struct {
 	CCA_fields          *[]*CdrFieldJsonCfg
 }
 
+// Radius Agent configuration section
+type RadiusAgentJsonCfg struct {
+	Enabled            *bool              // enables the radius agent: <true|false>
+	Listen_auth        *string            // address where to listen for radius authentication requests <x.y.z.y:1812>
+	Listen_acct        *string            // address where to listen for radius accounting requests <x.y.z.y:1813>
+	Client_secrets     *map[string]string // shared secrets indexed on client IP
+	Dictionaries_dir   *string            // path towards additional dictionaries
+	Sm_generic_conns   *[]*HaPoolJsonCfg  // Connections towards generic SM
+	Create_cdr         *bool
+	Timezone           *string // timezone for timestamps where not specified <""|UTC|Local|$IANA_TZ_DB>
+	Request_processors *[]*RARequestProcessorJsnCfg
+}
+
+// One Radius request processor configuration
+type RARequestProcessorJsnCfg struct {
+	Id                  *string
+	Dry_run             *bool
+	Request_filter      *string
+	Flags               *[]string
+	Continue_on_success *bool
+	Append_reply        *bool
+	Request_fields      *[]*CdrFieldJsonCfg
+	Reply_fields        *[]*CdrFieldJsonCfg
+}
+
 // History server config section
 type HistServJsonCfg struct {
 	Enabled       *bool
+	Backend       *string
 	History_dir   *string
 	Save_interval *string
 }
@@ -312,6 +397,13 @@ type UserServJsonCfg struct {
 	Indexes *[]string
 }
 
+// ResourceLimiter service config section
+type ResourceLimiterServJsonCfg struct {
+	Enabled        *bool
+	Cdrstats_conns *[]*HaPoolJsonCfg
+	Usage_ttl      *string
+}
+
 // Mailer config section
 type MailerJsonCfg struct {
 	Server        *string
@@ -0,0 +1,78 @@
+/*
+Real-time Charging System for Telecom & ISP environments
+Copyright (C) ITsysCOM GmbH
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>
+*/
+
+package config
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/cgrates/cgrates/utils"
+)
+
+func TestRadiusAgentCfgLoadFromJsonCfg(t *testing.T) {
+	raJsnCfg := &RadiusAgentJsonCfg{
+		Enabled:        utils.BoolPointer(true),
+		Listen_auth:    utils.StringPointer("127.0.0.1:1812"),
+		Client_secrets: &map[string]string{utils.META_DEFAULT: "CGRateS.org", "192.168.1.1": "secret1"},
+		Request_processors: &[]*RARequestProcessorJsnCfg{
+			&RARequestProcessorJsnCfg{
+				Id:             utils.StringPointer("Auth"),
+				Request_filter: utils.StringPointer("*radReqType(*radAuth)"),
+				Request_fields: &[]*CdrFieldJsonCfg{
+					&CdrFieldJsonCfg{Tag: utils.StringPointer("Account"), Field_id: utils.StringPointer(utils.ACCOUNT),
+						Type: utils.StringPointer(utils.META_COMPOSED), Value: utils.StringPointer("User-Name")}},
+				Reply_fields: &[]*CdrFieldJsonCfg{
+					&CdrFieldJsonCfg{Tag: utils.StringPointer("SessionTimeout"), Field_id: utils.StringPointer("Session-Timeout"),
+						Type: utils.StringPointer(utils.META_COMPOSED), Value: utils.StringPointer("CGRMaxUsage")}},
+			},
+		},
+	}
+	raCfg := new(RadiusAgentCfg)
+	if err := raCfg.loadFromJsonCfg(raJsnCfg); err != nil {
+		t.Fatal(err)
+	}
+	if !raCfg.Enabled || raCfg.ListenAuth != "127.0.0.1:1812" {
+		t.Errorf("Received: %+v", raCfg)
+	}
+	if len(raCfg.RequestProcessors) != 1 {
+		t.Fatalf("Received processors: %+v", raCfg.RequestProcessors)
+	}
+	reqProc := raCfg.RequestProcessors[0]
+	if reqProc.Id != "Auth" || len(reqProc.RequestFilter) != 1 || reqProc.RequestFilter[0].Id != "*radReqType" ||
+		len(reqProc.RequestFields) != 1 || len(reqProc.ReplyFields) != 1 || reqProc.ReplyFields[0].FieldId != "Session-Timeout" {
+		t.Errorf("Received processor: %+v", reqProc)
+	}
+	// Loading again the same processor id should update it instead of adding a new one
+	if err := raCfg.loadFromJsonCfg(&RadiusAgentJsonCfg{Request_processors: &[]*RARequestProcessorJsnCfg{
+		&RARequestProcessorJsnCfg{Id: utils.StringPointer("Auth"), Dry_run: utils.BoolPointer(true)}}}); err != nil {
+		t.Fatal(err)
+	}
+	if len(raCfg.RequestProcessors) != 1 || !raCfg.RequestProcessors[0].DryRun {
+		t.Errorf("Received processors: %+v", raCfg.RequestProcessors)
+	}
+	if secret, hasIt := raCfg.ClientSecret("192.168.1.1"); !hasIt || secret != "secret1" {
+		t.Error("Received secret: ", secret)
+	}
+	if secret, hasIt := raCfg.ClientSecret("10.0.0.1"); !hasIt || secret != "CGRateS.org" {
+		t.Error("Received secret: ", secret)
+	}
+	if !reflect.DeepEqual(raCfg.ClientSecrets, map[string]string{utils.META_DEFAULT: "CGRateS.org", "192.168.1.1": "secret1"}) {
+		t.Errorf("Received: %+v", raCfg.ClientSecrets)
+	}
+}
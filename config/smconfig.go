@@ -35,8 +35,12 @@ func NewDfltHaPoolConfig() *HaPoolConfig {
 
 // One connection to Rater
 type HaPoolConfig struct {
-	Address   string
-	Transport string
+	Address           string
+	Transport         string
+	TLS               bool   // connect over TLS
+	CACertificate     string // CA certificates verifying this peer, the one in tls section if empty
+	ClientCertificate string // certificate presented to this peer, the one in tls section if empty
+	ClientKey         string // private key of ClientCertificate
 }
 
 func (self *HaPoolConfig) loadFromJsonCfg(jsnCfg *HaPoolJsonCfg) error {
@@ -49,6 +53,18 @@ func (self *HaPoolConfig) loadFromJsonCfg(jsnCfg *HaPoolJsonCfg) error {
 	if jsnCfg.Transport != nil {
 		self.Transport = *jsnCfg.Transport
 	}
+	if jsnCfg.Tls != nil {
+		self.TLS = *jsnCfg.Tls
+	}
+	if jsnCfg.Ca_certificate != nil {
+		self.CACertificate = *jsnCfg.Ca_certificate
+	}
+	if jsnCfg.Client_certificate != nil {
+		self.ClientCertificate = *jsnCfg.Client_certificate
+	}
+	if jsnCfg.Client_key != nil {
+		self.ClientKey = *jsnCfg.Client_key
+	}
 	return nil
 }
 
@@ -85,16 +101,20 @@ func (self *FsConnConfig) loadFromJsonCfg(jsnCfg *FsConnJsonCfg) error {
 }
 
 type SmGenericConfig struct {
-	Enabled            bool
-	ListenBijson       string
-	RALsConns          []*HaPoolConfig
-	CDRsConns          []*HaPoolConfig
-	DebitInterval      time.Duration
-	MinCallDuration    time.Duration
-	MaxCallDuration    time.Duration
-	SessionTTL         time.Duration
-	SessionTTLLastUsed *time.Duration
-	SessionTTLUsage    *time.Duration
+	Enabled                 bool
+	ListenBijson            string
+	ListenBijsonTLS         string
+	RALsConns               []*HaPoolConfig
+	CDRsConns               []*HaPoolConfig
+	DebitInterval           time.Duration
+	MinCallDuration         time.Duration
+	MaxCallDuration         time.Duration
+	SessionTTL              time.Duration
+	SessionTTLLastUsed      *time.Duration
+	SessionTTLUsage         *time.Duration
+	PersistSessions         bool
+	RecoveredSessionTTL     time.Duration // terminates the recovered sessions not updated meanwhile, when SessionTTL is not set
+	SessionReplicationConns []*HaPoolConfig
 }
 
 func (self *SmGenericConfig) loadFromJsonCfg(jsnCfg *SmGenericJsonCfg) error {
@@ -108,6 +128,9 @@ func (self *SmGenericConfig) loadFromJsonCfg(jsnCfg *SmGenericJsonCfg) error {
 	if jsnCfg.Listen_bijson != nil {
 		self.ListenBijson = *jsnCfg.Listen_bijson
 	}
+	if jsnCfg.Listen_bijson_tls != nil {
+		self.ListenBijsonTLS = *jsnCfg.Listen_bijson_tls
+	}
 	if jsnCfg.Rals_conns != nil {
 		self.RALsConns = make([]*HaPoolConfig, len(*jsnCfg.Rals_conns))
 		for idx, jsnHaCfg := range *jsnCfg.Rals_conns {
@@ -156,6 +179,21 @@ func (self *SmGenericConfig) loadFromJsonCfg(jsnCfg *SmGenericJsonCfg) error {
 			self.SessionTTLUsage = &sessionTTLUsage
 		}
 	}
+	if jsnCfg.Persist_sessions != nil {
+		self.PersistSessions = *jsnCfg.Persist_sessions
+	}
+	if jsnCfg.Recovered_session_ttl != nil {
+		if self.RecoveredSessionTTL, err = utils.ParseDurationWithSecs(*jsnCfg.Recovered_session_ttl); err != nil {
+			return err
+		}
+	}
+	if jsnCfg.Session_replication_conns != nil {
+		self.SessionReplicationConns = make([]*HaPoolConfig, len(*jsnCfg.Session_replication_conns))
+		for idx, jsnHaCfg := range *jsnCfg.Session_replication_conns {
+			self.SessionReplicationConns[idx] = NewDfltHaPoolConfig()
+			self.SessionReplicationConns[idx].loadFromJsonCfg(jsnHaCfg)
+		}
+	}
 	return nil
 }
 
@@ -290,14 +328,15 @@ func (self *KamConnConfig) loadFromJsonCfg(jsnCfg *KamConnJsonCfg) error {
 
 // SM-Kamailio config section
 type SmKamConfig struct {
-	Enabled         bool
-	RALsConns       []*HaPoolConfig
-	CDRsConns       []*HaPoolConfig
-	CreateCdr       bool
-	DebitInterval   time.Duration
-	MinCallDuration time.Duration
-	MaxCallDuration time.Duration
-	EvapiConns      []*KamConnConfig
+	Enabled             bool
+	RALsConns           []*HaPoolConfig
+	CDRsConns           []*HaPoolConfig
+	CreateCdr           bool
+	DebitInterval       time.Duration
+	MinCallDuration     time.Duration
+	MaxCallDuration     time.Duration
+	ChannelSyncInterval time.Duration
+	EvapiConns          []*KamConnConfig
 }
 
 func (self *SmKamConfig) loadFromJsonCfg(jsnCfg *SmKamJsonCfg) error {
@@ -340,6 +379,11 @@ func (self *SmKamConfig) loadFromJsonCfg(jsnCfg *SmKamJsonCfg) error {
 			return err
 		}
 	}
+	if jsnCfg.Channel_sync_interval != nil {
+		if self.ChannelSyncInterval, err = utils.ParseDurationWithSecs(*jsnCfg.Channel_sync_interval); err != nil {
+			return err
+		}
+	}
 	if jsnCfg.Evapi_conns != nil {
 		self.EvapiConns = make([]*KamConnConfig, len(*jsnCfg.Evapi_conns))
 		for idx, jsnConnCfg := range *jsnCfg.Evapi_conns {
@@ -378,6 +422,7 @@ type SmOsipsConfig struct {
 	MaxCallDuration         time.Duration
 	EventsSubscribeInterval time.Duration
 	MiAddr                  string
+	ChannelSyncInterval     time.Duration
 }
 
 func (self *SmOsipsConfig) loadFromJsonCfg(jsnCfg *SmOsipsJsonCfg) error {
@@ -428,6 +473,11 @@ func (self *SmOsipsConfig) loadFromJsonCfg(jsnCfg *SmOsipsJsonCfg) error {
 	if jsnCfg.Mi_addr != nil {
 		self.MiAddr = *jsnCfg.Mi_addr
 	}
+	if jsnCfg.Channel_sync_interval != nil {
+		if self.ChannelSyncInterval, err = utils.ParseDurationWithSecs(*jsnCfg.Channel_sync_interval); err != nil {
+			return err
+		}
+	}
 
 	return nil
 }
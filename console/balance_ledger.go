@@ -0,0 +1,66 @@
+/*
+Real-time Charging System for Telecom & ISP environments
+Copyright (C) ITsysCOM GmbH
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>
+*/
+
+package console
+
+import (
+	"github.com/cgrates/cgrates/apier/v1"
+	"github.com/cgrates/cgrates/engine"
+)
+
+func init() {
+	c := &CmdGetBalanceLedger{
+		name:      "balance_ledger",
+		rpcMethod: "ApierV1.GetBalanceLedger",
+		rpcParams: new(v1.AttrGetBalanceLedger),
+	}
+	commands[c.Name()] = c
+	c.CommandExecuter = &CommandExecuter{c}
+}
+
+// Returns the balance changes written into the ledger
+type CmdGetBalanceLedger struct {
+	name      string
+	rpcMethod string
+	rpcParams *v1.AttrGetBalanceLedger
+	*CommandExecuter
+}
+
+func (self *CmdGetBalanceLedger) Name() string {
+	return self.name
+}
+
+func (self *CmdGetBalanceLedger) RpcMethod() string {
+	return self.rpcMethod
+}
+
+func (self *CmdGetBalanceLedger) RpcParams(reset bool) interface{} {
+	if reset || self.rpcParams == nil {
+		self.rpcParams = new(v1.AttrGetBalanceLedger)
+	}
+	return self.rpcParams
+}
+
+func (self *CmdGetBalanceLedger) PostprocessRpcParams() error {
+	return nil
+}
+
+func (self *CmdGetBalanceLedger) RpcResult() interface{} {
+	var entries []*engine.BalanceLedgerEntry
+	return &entries
+}
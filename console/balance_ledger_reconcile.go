@@ -0,0 +1,66 @@
+/*
+Real-time Charging System for Telecom & ISP environments
+Copyright (C) ITsysCOM GmbH
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>
+*/
+
+package console
+
+import (
+	"github.com/cgrates/cgrates/apier/v1"
+	"github.com/cgrates/cgrates/engine"
+)
+
+func init() {
+	c := &CmdReconcileBalanceLedger{
+		name:      "balance_ledger_reconcile",
+		rpcMethod: "ApierV1.ReconcileBalanceLedger",
+		rpcParams: new(v1.AttrReconcileBalanceLedger),
+	}
+	commands[c.Name()] = c
+	c.CommandExecuter = &CommandExecuter{c}
+}
+
+// Checks the ledger of an account against its current balances
+type CmdReconcileBalanceLedger struct {
+	name      string
+	rpcMethod string
+	rpcParams *v1.AttrReconcileBalanceLedger
+	*CommandExecuter
+}
+
+func (self *CmdReconcileBalanceLedger) Name() string {
+	return self.name
+}
+
+func (self *CmdReconcileBalanceLedger) RpcMethod() string {
+	return self.rpcMethod
+}
+
+func (self *CmdReconcileBalanceLedger) RpcParams(reset bool) interface{} {
+	if reset || self.rpcParams == nil {
+		self.rpcParams = new(v1.AttrReconcileBalanceLedger)
+	}
+	return self.rpcParams
+}
+
+func (self *CmdReconcileBalanceLedger) PostprocessRpcParams() error {
+	return nil
+}
+
+func (self *CmdReconcileBalanceLedger) RpcResult() interface{} {
+	var mismatches []*engine.BalanceLedgerMismatch
+	return &mismatches
+}
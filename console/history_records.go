@@ -0,0 +1,63 @@
+/*
+Real-time Charging System for Telecom & ISP environments
+Copyright (C) ITsysCOM GmbH
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>
+*/
+
+package console
+
+import "github.com/cgrates/cgrates/history"
+
+func init() {
+	c := &CmdGetHistoryRecords{
+		name:      "history_records",
+		rpcMethod: "HistoryV1.GetRecords",
+		rpcParams: new(history.RecordFilter),
+	}
+	commands[c.Name()] = c
+	c.CommandExecuter = &CommandExecuter{c}
+}
+
+// Returns the versioned changes of rating data, accounts, actions and action plans
+type CmdGetHistoryRecords struct {
+	name      string
+	rpcMethod string
+	rpcParams *history.RecordFilter
+	*CommandExecuter
+}
+
+func (self *CmdGetHistoryRecords) Name() string {
+	return self.name
+}
+
+func (self *CmdGetHistoryRecords) RpcMethod() string {
+	return self.rpcMethod
+}
+
+func (self *CmdGetHistoryRecords) RpcParams(reset bool) interface{} {
+	if reset || self.rpcParams == nil {
+		self.rpcParams = new(history.RecordFilter)
+	}
+	return self.rpcParams
+}
+
+func (self *CmdGetHistoryRecords) PostprocessRpcParams() error {
+	return nil
+}
+
+func (self *CmdGetHistoryRecords) RpcResult() interface{} {
+	var chRecs []*history.ChangeRecord
+	return &chRecs
+}
@@ -0,0 +1,28 @@
+--
+-- Upgrades the tariff plan tables created by older releases, apply once after updating
+--
+
+USE `cgrates`;
+
+ALTER TABLE `tp_rating_plans`
+	ADD COLUMN `volume_counter` varchar(64) NOT NULL DEFAULT '' AFTER `weight`,
+	ADD COLUMN `currency` varchar(8) NOT NULL DEFAULT '' AFTER `volume_counter`;
+
+ALTER TABLE `tp_actions`
+	ADD COLUMN `balance_currency` varchar(8) NOT NULL DEFAULT '' AFTER `weight`;
+
+ALTER TABLE `tp_cdr_stats`
+	ADD COLUMN `bucket_interval` varchar(8) NOT NULL DEFAULT '' AFTER `action_triggers`;
+
+CREATE TABLE IF NOT EXISTS `tp_exchange_rates` (
+  `id` int(11) NOT NULL AUTO_INCREMENT,
+  `tpid` varchar(64) NOT NULL,
+  `tag` varchar(64) NOT NULL,
+  `from_currency` varchar(8) NOT NULL,
+  `to_currency` varchar(8) NOT NULL,
+  `rate` DECIMAL(20,8) NOT NULL,
+  `created_at` TIMESTAMP,
+  PRIMARY KEY (`id`),
+  KEY `tpid` (`tpid`),
+  UNIQUE KEY `unique_exchange_rate` (`tpid`,`tag`,`from_currency`,`to_currency`)
+);
@@ -53,3 +53,46 @@ CREATE TABLE sm_costs (
   KEY origin_idx (origin_host, origin_id),
   KEY deleted_at_idx (deleted_at)
 );
+
+--
+-- Table structure for table `history_records`
+--
+
+DROP TABLE IF EXISTS history_records;
+CREATE TABLE history_records (
+  id int(11) NOT NULL AUTO_INCREMENT,
+  object_type varchar(64) NOT NULL,
+  object_id varchar(128) NOT NULL,
+  version int(11) NOT NULL,
+  author varchar(64) NOT NULL,
+  change_time TIMESTAMP NOT NULL,
+  deleted BOOLEAN NOT NULL,
+  payload longtext,
+  diff longtext,
+  PRIMARY KEY (`id`),
+  UNIQUE KEY object_version (object_type, object_id, version),
+  KEY change_time_idx (change_time)
+);
+
+--
+-- Table structure for table `balance_ledger`
+--
+
+DROP TABLE IF EXISTS balance_ledger;
+CREATE TABLE balance_ledger (
+  id int(11) NOT NULL AUTO_INCREMENT,
+  account varchar(128) NOT NULL,
+  balance_uuid varchar(64) NOT NULL,
+  balance_id varchar(64) NOT NULL,
+  balance_type varchar(24) NOT NULL,
+  delta DECIMAL(20,4) NOT NULL,
+  value DECIMAL(20,4) NOT NULL,
+  cgrid char(40) NOT NULL,
+  actions_id varchar(64) NOT NULL,
+  source varchar(64) NOT NULL,
+  created_at TIMESTAMP NOT NULL,
+  PRIMARY KEY (`id`),
+  KEY account_idx (account, balance_uuid),
+  KEY cgrid_idx (cgrid),
+  KEY created_at_idx (created_at)
+);
@@ -90,6 +90,8 @@ CREATE TABLE `tp_rating_plans` (
   `destrates_tag` varchar(64) NOT NULL,
   `timing_tag` varchar(64) NOT NULL,
   `weight` DECIMAL(8,2) NOT NULL,
+  `volume_counter` varchar(64) NOT NULL DEFAULT '',
+  `currency` varchar(8) NOT NULL DEFAULT '',
   `created_at` TIMESTAMP,
   PRIMARY KEY (`id`),
   KEY `tpid` (`tpid`),
@@ -139,6 +141,46 @@ CREATE TABLE `tp_shared_groups` (
   UNIQUE KEY `unique_shared_group` (`tpid`,`tag`,`account`,`strategy`,`rating_subject`)
 );
 
+--
+-- Table structure for table `tp_exchange_rates`
+--
+
+DROP TABLE IF EXISTS `tp_exchange_rates`;
+CREATE TABLE `tp_exchange_rates` (
+  `id` int(11) NOT NULL AUTO_INCREMENT,
+  `tpid` varchar(64) NOT NULL,
+  `tag` varchar(64) NOT NULL,
+  `from_currency` varchar(8) NOT NULL,
+  `to_currency` varchar(8) NOT NULL,
+  `rate` DECIMAL(20,8) NOT NULL,
+  `created_at` TIMESTAMP,
+  PRIMARY KEY (`id`),
+  KEY `tpid` (`tpid`),
+  UNIQUE KEY `unique_exchange_rate` (`tpid`,`tag`,`from_currency`,`to_currency`)
+);
+
+--
+-- Table structure for table `tp_tax_rules`
+--
+
+DROP TABLE IF EXISTS `tp_tax_rules`;
+CREATE TABLE `tp_tax_rules` (
+  `id` int(11) NOT NULL AUTO_INCREMENT,
+  `tpid` varchar(64) NOT NULL,
+  `tag` varchar(64) NOT NULL,
+  `tenant` varchar(64) NOT NULL,
+  `destination_ids` varchar(64) NOT NULL,
+  `tax_name` varchar(64) NOT NULL,
+  `percent` DECIMAL(8,4) NOT NULL,
+  `per_minute` DECIMAL(20,4) NOT NULL,
+  `inclusive` BOOLEAN NOT NULL,
+  `weight` DECIMAL(8,2) NOT NULL,
+  `created_at` TIMESTAMP,
+  PRIMARY KEY (`id`),
+  KEY `tpid` (`tpid`),
+  UNIQUE KEY `unique_tax_rule` (`tpid`,`tag`,`tenant`,`destination_ids`,`tax_name`)
+);
+
 --
 -- Table structure for table `tp_actions`
 --
@@ -165,6 +207,7 @@ CREATE TABLE `tp_actions` (
   `extra_parameters` varchar(256) NOT NULL,
   `filter` varchar(256) NOT NULL,
   `weight` DECIMAL(8,2) NOT NULL,
+  `balance_currency` varchar(8) NOT NULL DEFAULT '',
   `created_at` TIMESTAMP,
   PRIMARY KEY (`id`),
   KEY `tpid` (`tpid`),
@@ -342,6 +385,7 @@ CREATE TABLE tp_cdr_stats (
   `rated_subjects` varchar(64) NOT NULL,
   `cost_interval` varchar(24) NOT NULL,
   `action_triggers` varchar(64) NOT NULL,
+  `bucket_interval` varchar(8) NOT NULL DEFAULT '',
   `created_at` TIMESTAMP,
   PRIMARY KEY (`id`),
   KEY `tpid` (`tpid`),
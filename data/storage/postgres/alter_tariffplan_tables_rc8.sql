@@ -0,0 +1,26 @@
+--
+-- Upgrades the tariff plan tables created by older releases, apply once after updating
+--
+
+ALTER TABLE tp_rating_plans
+	ADD COLUMN volume_counter VARCHAR(64) NOT NULL DEFAULT '',
+	ADD COLUMN currency VARCHAR(8) NOT NULL DEFAULT '';
+
+ALTER TABLE tp_actions
+	ADD COLUMN balance_currency VARCHAR(8) NOT NULL DEFAULT '';
+
+ALTER TABLE tp_cdr_stats
+	ADD COLUMN bucket_interval VARCHAR(8) NOT NULL DEFAULT '';
+
+CREATE TABLE IF NOT EXISTS tp_exchange_rates (
+  id SERIAL PRIMARY KEY,
+  tpid VARCHAR(64) NOT NULL,
+  tag VARCHAR(64) NOT NULL,
+  from_currency VARCHAR(8) NOT NULL,
+  to_currency VARCHAR(8) NOT NULL,
+  rate NUMERIC(20,8) NOT NULL,
+  created_at TIMESTAMP,
+  UNIQUE (tpid, tag, from_currency, to_currency)
+);
+CREATE INDEX tpexchangerates_tpid_idx ON tp_exchange_rates (tpid);
+CREATE INDEX tpexchangerates_idx ON tp_exchange_rates (tpid,tag);
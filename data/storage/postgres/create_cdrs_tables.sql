@@ -60,3 +60,47 @@ CREATE INDEX origin_smcost_idx ON sm_costs (origin_host, origin_id);
 DROP INDEX IF EXISTS deleted_at_smcost_idx;
 CREATE INDEX deleted_at_smcost_idx ON sm_costs (deleted_at);
 
+--
+-- Table structure for table `history_records`
+--
+
+DROP TABLE IF EXISTS history_records;
+CREATE TABLE history_records (
+  id SERIAL PRIMARY KEY,
+  object_type VARCHAR(64) NOT NULL,
+  object_id VARCHAR(128) NOT NULL,
+  version INTEGER NOT NULL,
+  author VARCHAR(64) NOT NULL,
+  change_time TIMESTAMP WITH TIME ZONE NOT NULL,
+  deleted BOOLEAN NOT NULL,
+  payload text,
+  diff jsonb,
+  UNIQUE (object_type, object_id, version)
+);
+DROP INDEX IF EXISTS change_time_history_idx;
+CREATE INDEX change_time_history_idx ON history_records (change_time);
+
+--
+-- Table structure for table `balance_ledger`
+--
+
+DROP TABLE IF EXISTS balance_ledger;
+CREATE TABLE balance_ledger (
+  id SERIAL PRIMARY KEY,
+  account VARCHAR(128) NOT NULL,
+  balance_uuid VARCHAR(64) NOT NULL,
+  balance_id VARCHAR(64) NOT NULL,
+  balance_type VARCHAR(24) NOT NULL,
+  delta NUMERIC(20,4) NOT NULL,
+  value NUMERIC(20,4) NOT NULL,
+  cgrid CHAR(40) NOT NULL,
+  actions_id VARCHAR(64) NOT NULL,
+  source VARCHAR(64) NOT NULL,
+  created_at TIMESTAMP WITH TIME ZONE NOT NULL
+);
+DROP INDEX IF EXISTS account_ledger_idx;
+CREATE INDEX account_ledger_idx ON balance_ledger (account, balance_uuid);
+DROP INDEX IF EXISTS cgrid_ledger_idx;
+CREATE INDEX cgrid_ledger_idx ON balance_ledger (cgrid);
+DROP INDEX IF EXISTS created_at_ledger_idx;
+CREATE INDEX created_at_ledger_idx ON balance_ledger (created_at);
@@ -86,6 +86,8 @@ CREATE TABLE tp_rating_plans (
   destrates_tag VARCHAR(64) NOT NULL,
   timing_tag VARCHAR(64) NOT NULL,
   weight NUMERIC(8,2) NOT NULL,
+  volume_counter VARCHAR(64) NOT NULL DEFAULT '',
+  currency VARCHAR(8) NOT NULL DEFAULT '',
   created_at TIMESTAMP,
   UNIQUE (tpid, tag, destrates_tag, timing_tag)
 );
@@ -134,6 +136,46 @@ CREATE TABLE tp_shared_groups (
 CREATE INDEX tpsharedgroups_tpid_idx ON tp_shared_groups (tpid);
 CREATE INDEX tpsharedgroups_idx ON tp_shared_groups (tpid,tag);
 
+--
+-- Table structure for table `tp_exchange_rates`
+--
+
+DROP TABLE IF EXISTS tp_exchange_rates;
+CREATE TABLE tp_exchange_rates (
+  id SERIAL PRIMARY KEY,
+  tpid VARCHAR(64) NOT NULL,
+  tag VARCHAR(64) NOT NULL,
+  from_currency VARCHAR(8) NOT NULL,
+  to_currency VARCHAR(8) NOT NULL,
+  rate NUMERIC(20,8) NOT NULL,
+  created_at TIMESTAMP,
+  UNIQUE (tpid, tag, from_currency, to_currency)
+);
+CREATE INDEX tpexchangerates_tpid_idx ON tp_exchange_rates (tpid);
+CREATE INDEX tpexchangerates_idx ON tp_exchange_rates (tpid,tag);
+
+--
+-- Table structure for table `tp_tax_rules`
+--
+
+DROP TABLE IF EXISTS tp_tax_rules;
+CREATE TABLE tp_tax_rules (
+  id SERIAL PRIMARY KEY,
+  tpid VARCHAR(64) NOT NULL,
+  tag VARCHAR(64) NOT NULL,
+  tenant VARCHAR(64) NOT NULL,
+  destination_ids VARCHAR(64) NOT NULL,
+  tax_name VARCHAR(64) NOT NULL,
+  percent NUMERIC(8,4) NOT NULL,
+  per_minute NUMERIC(20,4) NOT NULL,
+  inclusive BOOLEAN NOT NULL,
+  weight NUMERIC(8,2) NOT NULL,
+  created_at TIMESTAMP,
+  UNIQUE (tpid, tag, tenant, destination_ids, tax_name)
+);
+CREATE INDEX tptaxrules_tpid_idx ON tp_tax_rules (tpid);
+CREATE INDEX tptaxrules_idx ON tp_tax_rules (tpid,tag);
+
 --
 -- Table structure for table `tp_actions`
 --
@@ -160,6 +202,7 @@ CREATE TABLE tp_actions (
   extra_parameters VARCHAR(256) NOT NULL,
   filter VARCHAR(256) NOT NULL,
   weight NUMERIC(8,2) NOT NULL,
+  balance_currency VARCHAR(8) NOT NULL DEFAULT '',
   created_at TIMESTAMP,
   UNIQUE (tpid, tag, action, balance_tag, balance_type, directions, expiry_time, timing_tags, destination_tags, shared_groups, balance_weight, weight)
 );
@@ -337,6 +380,7 @@ CREATE TABLE tp_cdr_stats (
   rated_subjects VARCHAR(64) NOT NULL,
   cost_interval VARCHAR(24) NOT NULL,
   action_triggers VARCHAR(64) NOT NULL,
+  bucket_interval VARCHAR(8) NOT NULL DEFAULT '',
   created_at TIMESTAMP
 );
 CREATE INDEX tpcdrstats_tpid_idx ON tp_cdr_stats (tpid);
@@ -22,8 +22,10 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"math"
 	"time"
 
+	"github.com/cgrates/cgrates/history"
 	"github.com/cgrates/cgrates/structmatcher"
 	"github.com/cgrates/cgrates/utils"
 
@@ -40,8 +42,11 @@ type Account struct {
 	UnitCounters      UnitCounters
 	ActionTriggers    ActionTriggers
 	AllowNegative     bool
+	CreditLimit       float64 // Amount the monetary balance is authorized to go below zero, not considered with AllowNegative
+	Reservations      map[string]*BalanceReservation
 	Disabled          bool
 	executingTriggers bool
+	ledger            *balanceLedger // balance changes not yet written into the ledger
 }
 
 // User's available minutes for the specified destination
@@ -78,13 +83,17 @@ func (ub *Account) getCreditForPrefix(cd *CallDescriptor) (duration time.Duratio
 			extendedMinuteBalances = append(extendedMinuteBalances, mb)
 		}
 	}
-	credit = extendedCreditBalances.GetTotalValue()
+	creditCurrency := ub.getDefaultMoneyCurrency() // balances in other currencies are converted before adding them up
+	credit = math.Max(extendedCreditBalances.GetTotalValueIn(creditCurrency)-ub.getReservedValue(utils.MONETARY), 0)
 	balances = extendedMinuteBalances
 	for _, b := range balances {
-		d, c := b.GetMinutesForCredit(cd, credit)
+		d, c := b.GetMinutesForCredit(cd, credit, creditCurrency)
 		credit = c
 		duration += d
 	}
+	if reserved := ub.getReservedValue(cd.TOR); reserved > 0 {
+		duration -= utils.MinDuration(time.Duration(reserved)*time.Second, duration)
+	}
 	return
 }
 
@@ -379,6 +388,9 @@ func (ub *Account) debitCreditBalance(cd *CallDescriptor, count bool, dryRun boo
 	//log.Printf("%+v, %+v", usefulMoneyBalances, usefulUnitBalances)
 	var leftCC *CallCost
 	cc = cd.CreateCallCost()
+	if cd.volumes == nil { // before any debit so all ratings see the same usage
+		cd.volumes = ub.getVolumes(cd)
+	}
 
 	generalBalanceChecker := true
 	for generalBalanceChecker {
@@ -492,7 +504,7 @@ func (ub *Account) debitCreditBalance(cd *CallDescriptor, count bool, dryRun boo
 		//log.Printf("Left CC: %+v ", leftCC)
 		// get the default money balanance
 		// and go negative on it with the amount still unpaid
-		if len(leftCC.Timespans) > 0 && leftCC.Cost > 0 && !ub.AllowNegative && !dryRun {
+		if len(leftCC.Timespans) > 0 && leftCC.Cost > 0 && !ub.AllowNegative && ub.CreditLimit == 0 && !dryRun {
 			utils.Logger.Err(fmt.Sprintf("<Rater> Going negative on account %s with AllowNegative: false", cd.GetAccountKey()))
 		}
 		leftCC.Timespans.Decompress()
@@ -500,14 +512,19 @@ func (ub *Account) debitCreditBalance(cd *CallDescriptor, count bool, dryRun boo
 			if ts.Increments == nil {
 				ts.createIncrementsSlice()
 			}
+			defaultBalance := ub.GetDefaultMoneyBalance()
+			exchangeRate, err := defaultBalance.getExchangeRate(ts.RateInterval)
+			if err != nil {
+				return nil, err
+			}
 			for _, increment := range ts.Increments {
-				cost := increment.Cost
-				defaultBalance := ub.GetDefaultMoneyBalance()
+				cost := exchangeCost(increment.Cost, exchangeRate)
 				defaultBalance.SubstractValue(cost)
 				increment.BalanceInfo.Monetary = &MonetaryInfo{
-					UUID:  defaultBalance.Uuid,
-					ID:    defaultBalance.ID,
-					Value: defaultBalance.Value,
+					UUID:         defaultBalance.Uuid,
+					ID:           defaultBalance.ID,
+					Value:        defaultBalance.Value,
+					ExchangeRate: exchangeRate,
 				}
 				increment.BalanceInfo.AccountID = ub.ID
 				increment.paid = true
@@ -527,6 +544,9 @@ func (ub *Account) debitCreditBalance(cd *CallDescriptor, count bool, dryRun boo
 	}
 
 COMMIT:
+	if count {
+		ub.countVolumes(cc)
+	}
 	if !dryRun {
 		// save darty shared balances
 		usefulMoneyBalances.SaveDirtyBalances(ub)
@@ -536,6 +556,58 @@ COMMIT:
 	return
 }
 
+// Returns the monetary amount which can still be spent, including the credit limit
+// Only the balances in the currency of the default one are considered, together with the ones shared with them by other accounts
+func (acc *Account) GetAvailableCredit() (credit float64) {
+	currency := acc.getDefaultMoneyCurrency()
+	sharedGroups := make(utils.StringMap)
+	for _, b := range acc.BalanceMap[utils.MONETARY] {
+		if b.IsExpired() || !b.IsActive() || b.Currency != currency {
+			continue
+		}
+		credit += b.GetValue()
+		for sg := range b.SharedGroups {
+			sharedGroups[sg] = true
+		}
+	}
+	members := make(utils.StringMap) // each member counted once even if sharing more groups
+	for sgID := range sharedGroups {
+		sharedGroup, err := ratingStorage.GetSharedGroup(sgID, false)
+		if err != nil {
+			utils.Logger.Warning(fmt.Sprintf("<Rater> Could not get shared group: %s, error: %v", sgID, err))
+			continue
+		}
+		for memberID := range sharedGroup.MemberIds {
+			if memberID != acc.ID {
+				members[memberID] = true
+			}
+		}
+	}
+	for memberID := range members {
+		member, _ := accountingStorage.GetAccount(memberID)
+		if member == nil || member.Disabled {
+			continue
+		}
+		for _, b := range member.BalanceMap[utils.MONETARY] {
+			if b.IsExpired() || !b.IsActive() || b.Currency != currency {
+				continue
+			}
+			for sg := range b.SharedGroups {
+				if sharedGroups[sg] {
+					credit += b.GetValue()
+					break
+				}
+			}
+		}
+	}
+	return roundCredit(credit + acc.CreditLimit)
+}
+
+// Rounds amounts which can go below zero, the negative ones away from zero like the positive ones
+func roundCredit(value float64) float64 {
+	return math.Copysign(utils.Round(math.Abs(value), globalRoundingDecimals, utils.ROUNDING_MIDDLE), value)
+}
+
 func (ub *Account) GetDefaultMoneyBalance() *Balance {
 	for _, balance := range ub.BalanceMap[utils.MONETARY] {
 		if balance.IsDefault() {
@@ -554,6 +626,16 @@ func (ub *Account) GetDefaultMoneyBalance() *Balance {
 	return defaultBalance
 }
 
+// Returns the currency of the default monetary balance without creating it
+func (acc *Account) getDefaultMoneyCurrency() string {
+	for _, balance := range acc.BalanceMap[utils.MONETARY] {
+		if balance.IsDefault() {
+			return balance.Currency
+		}
+	}
+	return ""
+}
+
 // Scans the action trigers and execute the actions for which trigger is met
 func (acc *Account) ExecuteActionTriggers(a *Action) {
 	if acc.executingTriggers {
@@ -570,6 +652,12 @@ func (acc *Account) ExecuteActionTriggers(a *Action) {
 		if at.IsExpired(time.Now()) || !at.IsActive(time.Now()) {
 			continue
 		}
+		if at.ThresholdType == utils.TRIGGER_MIN_CREDIT {
+			if !at.Executed && at.Match(a) && acc.GetAvailableCredit() <= at.ThresholdValue {
+				at.Execute(acc, nil)
+			}
+			continue
+		}
 
 		// sanity check
 		if !strings.Contains(at.ThresholdType, "counter") && !strings.Contains(at.ThresholdType, "balance") {
@@ -659,6 +747,57 @@ func (acc *Account) countUnits(amount float64, kind string, cc *CallCost, b *Bal
 	acc.ExecuteActionTriggers(nil)
 }
 
+// Returns the usage on the counters consulted by volume tiered ratings, relative to the start of the call so the part of it debited already is not considered twice
+func (acc *Account) getVolumes(cd *CallDescriptor) map[string]time.Duration {
+	volumes := make(map[string]time.Duration)
+	var callOffset time.Duration
+	if cd.DurationIndex > cd.GetDuration() {
+		callOffset = cd.DurationIndex - cd.GetDuration()
+	}
+	for _, uc := range acc.UnitCounters[cd.TOR] {
+		if uc == nil {
+			continue
+		}
+		for _, c := range uc.Counters {
+			if c.Filter == nil || c.Filter.ID == nil || *c.Filter.ID == "" {
+				continue
+			}
+			if _, has := volumes[*c.Filter.ID]; has { // first counter wins
+				continue
+			}
+			volumes[*c.Filter.ID] = time.Duration(c.Value*float64(time.Second)) - callOffset
+		}
+	}
+	return volumes
+}
+
+// Adds the usage rated by volume tiered ratings to the counters they consult
+// Increments paid out of unit balances are skipped since they were counted when debited
+func (acc *Account) countVolumes(cc *CallCost) {
+	counted := false
+	for _, ts := range cc.Timespans {
+		if ts.RateInterval == nil || ts.RateInterval.Rating == nil || ts.RateInterval.Rating.VolumeCounter == "" {
+			continue
+		}
+		var usage time.Duration
+		for _, incr := range ts.Increments {
+			if incr.BalanceInfo != nil && incr.BalanceInfo.Unit != nil && incr.BalanceInfo.Unit.UUID != "" {
+				continue
+			}
+			usage += incr.Duration * time.Duration(incr.GetCompressFactor())
+		}
+		if usage == 0 {
+			continue
+		}
+		if acc.UnitCounters.addVolume(usage.Seconds(), cc.TOR, ts.RateInterval.Rating.VolumeCounter) {
+			counted = true
+		}
+	}
+	if counted {
+		acc.ExecuteActionTriggers(nil)
+	}
+}
+
 // Create counters for all triggered actions
 func (acc *Account) InitCounters() {
 	oldUcs := acc.UnitCounters
@@ -728,6 +867,18 @@ func (acc *Account) CleanExpiredStuff() {
 			acc.ActionTriggers = append(acc.ActionTriggers[:i], acc.ActionTriggers[i+1:]...)
 		}
 	}
+	acc.removeExpiredReservations()
+}
+
+// history record method
+func (acc *Account) GetHistoryRecord(deleted bool) history.Record {
+	js, _ := json.Marshal(acc)
+	return history.Record{
+		Id:       acc.ID,
+		Filename: history.ACCOUNTS_FN,
+		Payload:  js,
+		Deleted:  deleted,
+	}
 }
 
 func (acc *Account) allBalancesExpired() bool {
@@ -789,6 +940,8 @@ func (acc *Account) Clone() *Account {
 		UnitCounters:   nil, // not used when cloned (dryRun)
 		ActionTriggers: nil, // not used when cloned (dryRun)
 		AllowNegative:  acc.AllowNegative,
+		CreditLimit:    acc.CreditLimit,
+		Reservations:   acc.Reservations, // read only in dry runs
 		Disabled:       acc.Disabled,
 	}
 	for key, balanceChain := range acc.BalanceMap {
@@ -800,14 +953,19 @@ func (acc *Account) Clone() *Account {
 func (acc *Account) DebitConnectionFee(cc *CallCost, usefulMoneyBalances Balances, count bool, block bool) bool {
 	if cc.deductConnectFee {
 		connectFee := cc.GetConnectFee()
+		var ri *RateInterval
+		if len(cc.Timespans) != 0 {
+			ri = cc.Timespans[0].RateInterval
+		}
 		//log.Print("CONNECT FEE: %f", connectFee)
 		connectFeePaid := false
 		for _, b := range usefulMoneyBalances {
-			if b.GetValue() >= connectFee {
-				b.SubstractValue(connectFee)
+			exchangeRate, err := b.getExchangeRate(ri)
+			if err == nil && b.GetValue() >= exchangeCost(connectFee, exchangeRate) {
+				b.SubstractValue(exchangeCost(connectFee, exchangeRate))
 				// the conect fee is not refundable!
 				if count {
-					acc.countUnits(connectFee, utils.MONETARY, cc, b)
+					acc.countUnits(exchangeCost(connectFee, exchangeRate), utils.MONETARY, cc, b)
 				}
 				connectFeePaid = true
 				break
@@ -821,10 +979,14 @@ func (acc *Account) DebitConnectionFee(cc *CallCost, usefulMoneyBalances Balance
 			cc.negativeConnectFee = true
 			// there are no money for the connect fee; go negative
 			b := acc.GetDefaultMoneyBalance()
-			b.SubstractValue(connectFee)
+			exchangeRate, err := b.getExchangeRate(ri)
+			if err != nil {
+				utils.Logger.Err(fmt.Sprintf("<Rater> Cannot convert the connect fee for account %s: %v", acc.ID, err))
+			}
+			b.SubstractValue(exchangeCost(connectFee, exchangeRate))
 			// the conect fee is not refundable!
 			if count {
-				acc.countUnits(connectFee, utils.MONETARY, cc, b)
+				acc.countUnits(exchangeCost(connectFee, exchangeRate), utils.MONETARY, cc, b)
 			}
 		}
 	}
@@ -1830,6 +1830,38 @@ func TestAccountGetBalancesForPrefixMixedBad(t *testing.T) {
 	}
 }
 
+func TestAccountGetAvailableCredit(t *testing.T) {
+	acc := &Account{ID: "cgrates.org:credit1", CreditLimit: 5, BalanceMap: map[string]Balances{
+		utils.MONETARY: Balances{
+			&Balance{Uuid: "credit1_default", ID: utils.META_DEFAULT, Value: -0.3},
+			&Balance{Uuid: "credit1_usd", Value: 100, Currency: "USD"}, // other currency, left out
+			&Balance{Uuid: "credit1_sg", Value: 0, SharedGroups: utils.NewStringMap("SG_CREDIT")},
+		}}}
+	if credit := acc.GetAvailableCredit(); credit != 4.7 {
+		t.Errorf("Expecting: 4.7, received: %v", credit)
+	}
+	member := &Account{ID: "cgrates.org:credit2", BalanceMap: map[string]Balances{
+		utils.MONETARY: Balances{
+			&Balance{Uuid: "credit2_sg", Value: 10, SharedGroups: utils.NewStringMap("SG_CREDIT", "SG_CREDIT2")},
+			&Balance{Uuid: "credit2_own", Value: 20}, // not shared, left out
+		}}}
+	accountingStorage.SetAccount(member)
+	sg := &SharedGroup{Id: "SG_CREDIT", MemberIds: utils.NewStringMap(acc.ID, member.ID)}
+	ratingStorage.SetSharedGroup(sg)
+	CacheSet(utils.SHARED_GROUP_PREFIX+sg.Id, sg)
+	sg2 := &SharedGroup{Id: "SG_CREDIT2", MemberIds: utils.NewStringMap(acc.ID, member.ID)}
+	ratingStorage.SetSharedGroup(sg2)
+	CacheSet(utils.SHARED_GROUP_PREFIX+sg2.Id, sg2)
+	acc.BalanceMap[utils.MONETARY][0].SharedGroups = utils.NewStringMap("SG_CREDIT2")
+	if credit := acc.GetAvailableCredit(); credit != 14.7 { // shared balance counted once for both groups
+		t.Errorf("Expecting: 14.7, received: %v", credit)
+	}
+	acc.BalanceMap[utils.MONETARY][0].Value = -15.31
+	if credit := acc.GetAvailableCredit(); credit != -0.31 {
+		t.Errorf("Expecting: -0.31, received: %v", credit)
+	}
+}
+
 /*********************************** Benchmarks *******************************/
 
 func BenchmarkGetSecondForPrefix(b *testing.B) {
@@ -33,6 +33,7 @@ import (
 	"time"
 
 	"github.com/cgrates/cgrates/config"
+	"github.com/cgrates/cgrates/history"
 	"github.com/cgrates/cgrates/utils"
 	"github.com/cgrates/rpcclient"
 	"github.com/mitchellh/mapstructure"
@@ -53,23 +54,26 @@ type Action struct {
 }
 
 const (
-	LOG             = "*log"
-	RESET_TRIGGERS  = "*reset_triggers"
-	SET_RECURRENT   = "*set_recurrent"
-	UNSET_RECURRENT = "*unset_recurrent"
-	ALLOW_NEGATIVE  = "*allow_negative"
-	DENY_NEGATIVE   = "*deny_negative"
-	RESET_ACCOUNT   = "*reset_account"
-	REMOVE_ACCOUNT  = "*remove_account"
-	SET_BALANCE     = "*set_balance"
-	REMOVE_BALANCE  = "*remove_balance"
-	TOPUP_RESET     = "*topup_reset"
-	TOPUP           = "*topup"
-	DEBIT_RESET     = "*debit_reset"
-	DEBIT           = "*debit"
-	RESET_COUNTERS  = "*reset_counters"
-	ENABLE_ACCOUNT  = "*enable_account"
-	DISABLE_ACCOUNT = "*disable_account"
+	LOG                         = "*log"
+	RESET_TRIGGERS              = "*reset_triggers"
+	SET_RECURRENT               = "*set_recurrent"
+	UNSET_RECURRENT             = "*unset_recurrent"
+	ALLOW_NEGATIVE              = "*allow_negative"
+	DENY_NEGATIVE               = "*deny_negative"
+	SET_CREDIT_LIMIT            = "*set_credit_limit"
+	ADD_CREDIT_LIMIT            = "*add_credit_limit"
+	REMOVE_EXPIRED_RESERVATIONS = "*remove_expired_reservations"
+	RESET_ACCOUNT               = "*reset_account"
+	REMOVE_ACCOUNT              = "*remove_account"
+	SET_BALANCE                 = "*set_balance"
+	REMOVE_BALANCE              = "*remove_balance"
+	TOPUP_RESET                 = "*topup_reset"
+	TOPUP                       = "*topup"
+	DEBIT_RESET                 = "*debit_reset"
+	DEBIT                       = "*debit"
+	RESET_COUNTERS              = "*reset_counters"
+	ENABLE_ACCOUNT              = "*enable_account"
+	DISABLE_ACCOUNT             = "*disable_account"
 	//ENABLE_DISABLE_BALANCE    = "*enable_disable_balance"
 	CALL_URL                  = "*call_url"
 	CALL_URL_ASYNC            = "*call_url_async"
@@ -79,6 +83,7 @@ const (
 	SET_DDESTINATIONS         = "*set_ddestinations"
 	TRANSFER_MONETARY_DEFAULT = "*transfer_monetary_default"
 	CGR_RPC                   = "*cgr_rpc"
+	CDR_EXPORT                = "*cdr_export"
 )
 
 func (a *Action) Clone() *Action {
@@ -97,21 +102,24 @@ type actionTypeFunc func(*Account, *StatsQueueTriggered, *Action, Actions) error
 
 func getActionFunc(typ string) (actionTypeFunc, bool) {
 	actionFuncMap := map[string]actionTypeFunc{
-		LOG:             logAction,
-		CDRLOG:          cdrLogAction,
-		RESET_TRIGGERS:  resetTriggersAction,
-		SET_RECURRENT:   setRecurrentAction,
-		UNSET_RECURRENT: unsetRecurrentAction,
-		ALLOW_NEGATIVE:  allowNegativeAction,
-		DENY_NEGATIVE:   denyNegativeAction,
-		RESET_ACCOUNT:   resetAccountAction,
-		TOPUP_RESET:     topupResetAction,
-		TOPUP:           topupAction,
-		DEBIT_RESET:     debitResetAction,
-		DEBIT:           debitAction,
-		RESET_COUNTERS:  resetCountersAction,
-		ENABLE_ACCOUNT:  enableAccountAction,
-		DISABLE_ACCOUNT: disableAccountAction,
+		LOG:                         logAction,
+		CDRLOG:                      cdrLogAction,
+		RESET_TRIGGERS:              resetTriggersAction,
+		SET_RECURRENT:               setRecurrentAction,
+		UNSET_RECURRENT:             unsetRecurrentAction,
+		ALLOW_NEGATIVE:              allowNegativeAction,
+		DENY_NEGATIVE:               denyNegativeAction,
+		SET_CREDIT_LIMIT:            setCreditLimitAction,
+		ADD_CREDIT_LIMIT:            addCreditLimitAction,
+		REMOVE_EXPIRED_RESERVATIONS: removeExpiredReservationsAction,
+		RESET_ACCOUNT:               resetAccountAction,
+		TOPUP_RESET:                 topupResetAction,
+		TOPUP:                       topupAction,
+		DEBIT_RESET:                 debitResetAction,
+		DEBIT:                       debitAction,
+		RESET_COUNTERS:              resetCountersAction,
+		ENABLE_ACCOUNT:              enableAccountAction,
+		DISABLE_ACCOUNT:             disableAccountAction,
 		//case ENABLE_DISABLE_BALANCE:
 		//	return enableDisableBalanceAction, true
 		CALL_URL:                  callUrl,
@@ -123,6 +131,7 @@ func getActionFunc(typ string) (actionTypeFunc, bool) {
 		SET_BALANCE:               setBalanceAction,
 		TRANSFER_MONETARY_DEFAULT: transferMonetaryDefaultAction,
 		CGR_RPC:                   cgrRPCAction,
+		CDR_EXPORT:                cdrExportAction,
 	}
 	f, exists := actionFuncMap[typ]
 	return f, exists
@@ -300,6 +309,40 @@ func denyNegativeAction(ub *Account, sq *StatsQueueTriggered, a *Action, acs Act
 	return
 }
 
+// Sets the credit limit of the account to the value of the action balance
+func setCreditLimitAction(ub *Account, sq *StatsQueueTriggered, a *Action, acs Actions) (err error) {
+	if ub == nil {
+		return errors.New("nil account")
+	}
+	if a.Balance.GetValue() < 0 {
+		return errors.New("negative credit limit")
+	}
+	ub.CreditLimit = a.Balance.GetValue()
+	ub.ExecuteActionTriggers(nil)
+	return
+}
+
+// Adjusts the credit limit of the account with the value of the action balance, negative values lower it down to 0
+func addCreditLimitAction(ub *Account, sq *StatsQueueTriggered, a *Action, acs Actions) (err error) {
+	if ub == nil {
+		return errors.New("nil account")
+	}
+	ub.CreditLimit = roundCredit(ub.CreditLimit + a.Balance.GetValue())
+	if ub.CreditLimit < 0 {
+		ub.CreditLimit = 0
+	}
+	ub.ExecuteActionTriggers(nil)
+	return
+}
+
+func removeExpiredReservationsAction(ub *Account, sq *StatsQueueTriggered, a *Action, acs Actions) (err error) {
+	if ub == nil {
+		return errors.New("nil account")
+	}
+	ub.removeExpiredReservations()
+	return
+}
+
 func resetAccountAction(ub *Account, sq *StatsQueueTriggered, a *Action, acs Actions) (err error) {
 	if ub == nil {
 		return errors.New("nil account")
@@ -742,3 +785,14 @@ func (apl Actions) Less(j, i int) bool {
 func (apl Actions) Sort() {
 	sort.Sort(apl)
 }
+
+// history record method, actions are stored under their id
+func (apl Actions) GetHistoryRecord(id string, deleted bool) history.Record {
+	js, _ := json.Marshal(apl)
+	return history.Record{
+		Id:       id,
+		Filename: history.ACTIONS_FN,
+		Payload:  js,
+		Deleted:  deleted,
+	}
+}
@@ -19,10 +19,12 @@ along with this program.  If not, see <http://www.gnu.org/licenses/>
 package engine
 
 import (
+	"encoding/json"
 	"fmt"
 	"sort"
 	"time"
 
+	"github.com/cgrates/cgrates/history"
 	"github.com/cgrates/cgrates/utils"
 	"github.com/gorhill/cronexpr"
 )
@@ -61,6 +63,17 @@ func (apl *ActionPlan) RemoveAccountID(accID string) (found bool) {
 	return
 }
 
+// history record method, the Id inside the plan is informative so the storage key is used
+func (apl *ActionPlan) GetHistoryRecord(key string, deleted bool) history.Record {
+	js, _ := json.Marshal(apl)
+	return history.Record{
+		Id:       key,
+		Filename: history.ACTION_PLANS_FN,
+		Payload:  js,
+		Deleted:  deleted,
+	}
+}
+
 func (t *Task) Execute() error {
 	return (&ActionTiming{
 		Uuid:       t.Uuid,
@@ -320,6 +333,7 @@ func (at *ActionTiming) Execute() (err error) {
 					transactionFailed = true
 					break
 				}
+				acc.setLedgerOrigin(&ledgerOrigin{ActionsID: at.ActionsID, Source: a.ActionType})
 				if err := actionFunction(acc, nil, a, aac); err != nil {
 					utils.Logger.Err(fmt.Sprintf("Error executing action %s: %v!", a.ActionType, err))
 					transactionFailed = true
@@ -330,7 +344,9 @@ func (at *ActionTiming) Execute() (err error) {
 				}
 			}
 			if !transactionFailed && !removeAccountActionFound {
-				accountingStorage.SetAccount(acc)
+				if err := saveAccount(acc); err == nil {
+					RecordAccountHistory(acc)
+				}
 			}
 			return 0, nil
 		}, 0, accID)
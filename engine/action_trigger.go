@@ -30,7 +30,7 @@ import (
 type ActionTrigger struct {
 	ID            string // original csv tag
 	UniqueID      string // individual id
-	ThresholdType string //*min_event_counter, *max_event_counter, *min_balance_counter, *max_balance_counter, *min_balance, *max_balance, *balance_expired
+	ThresholdType string //*min_event_counter, *max_event_counter, *min_balance_counter, *max_balance_counter, *min_balance, *max_balance, *balance_expired, *min_credit
 	// stats: *min_asr, *max_asr, *min_acd, *max_acd, *min_tcd, *max_tcd, *min_acc, *max_acc, *min_tcc, *max_tcc, *min_ddc, *max_ddc
 	ThresholdValue float64
 	Recurrent      bool          // reset excuted flag each run
@@ -66,9 +66,13 @@ func (at *ActionTrigger) Execute(ub *Account, sq *StatsQueueTriggered) (err erro
 	at.Executed = true
 	transactionFailed := false
 	removeAccountActionFound := false
+	var prevLedgerOrigin *ledgerOrigin
+	if ub != nil {
+		prevLedgerOrigin = ub.setLedgerOrigin(&ledgerOrigin{ActionsID: at.ActionsID})
+	}
 	for _, a := range aac {
-		// check action filter
-		if len(a.Filter) > 0 {
+		// check action filter, balance filters are not applicable without an account (eg: ResourceLimit triggers)
+		if len(a.Filter) > 0 && ub != nil {
 			matched, err := ub.matchActionFilter(a.Filter)
 			if err != nil {
 				return err
@@ -94,6 +98,9 @@ func (at *ActionTrigger) Execute(ub *Account, sq *StatsQueueTriggered) (err erro
 			break
 		}
 		//go utils.Logger.Info(fmt.Sprintf("Executing %v, %v: %v", ub, sq, a))
+		if ub != nil {
+			ub.setLedgerOrigin(&ledgerOrigin{ActionsID: at.ActionsID, Source: a.ActionType})
+		}
 		if err := actionFunction(ub, sq, a, aac); err != nil {
 			utils.Logger.Err(fmt.Sprintf("Error executing action %s: %v!", a.ActionType, err))
 			transactionFailed = false
@@ -113,7 +120,12 @@ func (at *ActionTrigger) Execute(ub *Account, sq *StatsQueueTriggered) (err erro
 			"Id":        at.ID,
 			"ActionIds": at.ActionsID,
 		})
-		accountingStorage.SetAccount(ub)
+		if err := saveAccount(ub); err == nil {
+			RecordAccountHistory(ub)
+		}
+	}
+	if ub != nil {
+		ub.setLedgerOrigin(prevLedgerOrigin)
 	}
 	return
 }
@@ -2248,6 +2248,28 @@ func TestValueFormulaDebit(t *testing.T) {
 	}
 }
 
+func TestCdrExportAction(t *testing.T) {
+	at := NewCdrExportTiming("daily", utils.NewTiming("daily", utils.ANY, utils.ANY, utils.ANY, utils.ANY, "00:05:00"))
+	if err := cdrExportAction(nil, nil, at.actions[0], at.actions); err == nil {
+		t.Error("Expecting error without job runner")
+	}
+	var ranJobID string
+	SetCdrExportJobRunner(func(jobID string) error {
+		ranJobID = jobID
+		return nil
+	})
+	defer SetCdrExportJobRunner(nil)
+	if err := at.Execute(); err != nil {
+		t.Error(err)
+	}
+	if ranJobID != "daily" {
+		t.Errorf("Received job: %s", ranJobID)
+	}
+	if st := at.GetNextStartTime(time.Date(2016, 7, 1, 10, 0, 0, 0, time.Local)); !st.Equal(time.Date(2016, 7, 2, 0, 5, 0, 0, time.Local)) {
+		t.Errorf("Next start time: %v", st)
+	}
+}
+
 /**************** Benchmarks ********************************/
 
 func BenchmarkUUID(b *testing.B) {
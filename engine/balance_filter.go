@@ -24,6 +24,7 @@ type BalanceFilter struct {
 	Disabled       *bool
 	Factor         *ValueFactor
 	Blocker        *bool
+	Currency       *string
 }
 
 func (bp *BalanceFilter) CreateBalance() *Balance {
@@ -43,6 +44,7 @@ func (bp *BalanceFilter) CreateBalance() *Balance {
 		Disabled:       bp.GetDisabled(),
 		Factor:         bp.GetFactor(),
 		Blocker:        bp.GetBlocker(),
+		Currency:       bp.GetCurrency(),
 	}
 	return b.Clone()
 }
@@ -85,6 +87,10 @@ func (bf *BalanceFilter) Clone() *BalanceFilter {
 		result.Blocker = new(bool)
 		*result.Blocker = *bf.Blocker
 	}
+	if bf.Currency != nil {
+		result.Currency = new(string)
+		*result.Currency = *bf.Currency
+	}
 	if bf.Factor != nil {
 		result.Factor = new(ValueFactor)
 		*result.Factor = *bf.Factor
@@ -151,6 +157,9 @@ func (bf *BalanceFilter) LoadFromBalance(b *Balance) *BalanceFilter {
 	if b.Blocker {
 		bf.Blocker = &b.Blocker
 	}
+	if b.Currency != "" {
+		bf.Currency = &b.Currency
+	}
 	bf.Timings = b.Timings
 	return bf
 }
@@ -254,6 +263,13 @@ func (bp *BalanceFilter) GetRatingSubject() string {
 	return *bp.RatingSubject
 }
 
+func (bp *BalanceFilter) GetCurrency() string {
+	if bp == nil || bp.Currency == nil {
+		return ""
+	}
+	return *bp.Currency
+}
+
 func (bp *BalanceFilter) GetDisabled() bool {
 	if bp == nil || bp.Disabled == nil {
 		return false
@@ -308,6 +324,9 @@ func (bf *BalanceFilter) ModifyBalance(b *Balance) {
 	if bf.RatingSubject != nil {
 		b.RatingSubject = *bf.RatingSubject
 	}
+	if bf.Currency != nil {
+		b.Currency = *bf.Currency
+	}
 	if bf.Categories != nil {
 		b.Categories = *bf.Categories
 	}
@@ -0,0 +1,252 @@
+/*
+Real-time Charging System for Telecom & ISP environments
+Copyright (C) ITsysCOM GmbH
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>
+*/
+
+package engine
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/cgrates/cgrates/utils"
+)
+
+const (
+	LEDGER_RATING          = "*rating" // debits performed while rating events
+	LEDGER_REFUND          = "*refund"
+	LEDGER_REFUND_ROUNDING = "*refund_rounding"
+	LEDGER_EXPIRED         = "*expired"
+	LEDGER_RESERVATION     = "*reservation"
+	// reconciliation mismatches
+	LEDGER_VALUE_MISMATCH = "*value_mismatch" // last ledger value differs from the balance value
+	LEDGER_BROKEN_CHAIN   = "*broken_chain"   // entry value is not the previous value plus its delta
+	LEDGER_UNRECORDED     = "*unrecorded"     // balance with value but without ledger entries
+)
+
+var ledgerStorage BalanceLedgerStorage
+
+// Enables the balance ledger, nil disables it
+func SetBalanceLedgerStorage(bls BalanceLedgerStorage) {
+	ledgerStorage = bls
+}
+
+// One change of a balance value, written after the account holding it was saved
+type BalanceLedgerEntry struct {
+	Account     string
+	BalanceUUID string
+	BalanceID   string
+	BalanceType string
+	Delta       float64 // Value change, negative for debits
+	Value       float64 // Balance value after the change
+	CGRID       string  // Event originating the change, empty for actions
+	ActionsID   string  // Actions originating the change, empty for rating
+	Source      string  // *rating, *refund, *refund_rounding, *expired, *reservation or the type of the action
+	CreatedAt   time.Time
+}
+
+// Filters the entries returned by ApierV1.GetBalanceLedger, empty fields are not considered
+type BalanceLedgerFilter struct {
+	Account     string    // Account key, eg: cgrates.org:1001
+	BalanceUUID string    // Exact match on the balance uuid
+	CGRID       string    // Changes originated by this event
+	ActionsID   string    // Changes originated by these actions
+	TimeStart   time.Time // Changes performed at or after this time
+	TimeEnd     time.Time // Changes performed before this time
+	utils.Paginator
+}
+
+// Checks if the entry passes the filter, used by storages without query capabilities
+func (filter *BalanceLedgerFilter) Passes(entry *BalanceLedgerEntry) bool {
+	if filter.Account != "" && entry.Account != filter.Account {
+		return false
+	}
+	if filter.BalanceUUID != "" && entry.BalanceUUID != filter.BalanceUUID {
+		return false
+	}
+	if filter.CGRID != "" && entry.CGRID != filter.CGRID {
+		return false
+	}
+	if filter.ActionsID != "" && entry.ActionsID != filter.ActionsID {
+		return false
+	}
+	if !filter.TimeStart.IsZero() && entry.CreatedAt.Before(filter.TimeStart) {
+		return false
+	}
+	if !filter.TimeEnd.IsZero() && !entry.CreatedAt.Before(filter.TimeEnd) {
+		return false
+	}
+	return true
+}
+
+// Operation the balance changes are attributed to
+type ledgerOrigin struct {
+	CGRID     string
+	ActionsID string
+	Source    string
+}
+
+type ledgerBalance struct {
+	ID             string
+	Type           string
+	Value          float64
+	ExpirationDate time.Time
+}
+
+// Tracks the balance changes of an account until they are written into the ledger
+type balanceLedger struct {
+	origin   *ledgerOrigin
+	balances map[string]*ledgerBalance // balances as they were at the last check, indexed on uuid
+	entries  []*BalanceLedgerEntry     // changes waiting for the account to be saved
+}
+
+func (acc *Account) ledgerBalances() map[string]*ledgerBalance {
+	lbs := make(map[string]*ledgerBalance)
+	for balanceType, bc := range acc.BalanceMap {
+		for _, b := range bc {
+			if b == nil {
+				continue
+			}
+			lbs[b.Uuid] = &ledgerBalance{ID: b.ID, Type: balanceType, Value: b.GetValue(), ExpirationDate: b.ExpirationDate}
+		}
+	}
+	return lbs
+}
+
+// Attributes the balance changes performed from now on to origin, the changes performed so far are kept for the previous origin which is returned so it can be restored
+func (acc *Account) setLedgerOrigin(origin *ledgerOrigin) (prev *ledgerOrigin) {
+	if ledgerStorage == nil {
+		return nil
+	}
+	if acc.ledger == nil {
+		acc.ledger = &balanceLedger{balances: acc.ledgerBalances()}
+	} else {
+		acc.collectLedgerEntries()
+	}
+	prev = acc.ledger.origin
+	acc.ledger.origin = origin
+	return
+}
+
+// Compares the balances with the ones at the last check and queues the differences on behalf of the current origin
+func (acc *Account) collectLedgerEntries() {
+	origin := acc.ledger.origin
+	if origin == nil {
+		origin = new(ledgerOrigin)
+	}
+	now := time.Now()
+	crntBalances := acc.ledgerBalances()
+	for uuid, lb := range crntBalances {
+		var prevValue float64
+		if prevLb, has := acc.ledger.balances[uuid]; has {
+			prevValue = prevLb.Value
+		}
+		delta := utils.Round(lb.Value-prevValue, globalRoundingDecimals, utils.ROUNDING_MIDDLE)
+		if delta == 0 {
+			continue
+		}
+		acc.ledger.entries = append(acc.ledger.entries, &BalanceLedgerEntry{Account: acc.ID, BalanceUUID: uuid, BalanceID: lb.ID, BalanceType: lb.Type,
+			Delta: delta, Value: lb.Value, CGRID: origin.CGRID, ActionsID: origin.ActionsID, Source: origin.Source, CreatedAt: now})
+	}
+	for uuid, prevLb := range acc.ledger.balances { // removed balances give back their value
+		if _, has := crntBalances[uuid]; has || prevLb.Value == 0 {
+			continue
+		}
+		source := origin.Source
+		if !prevLb.ExpirationDate.IsZero() && prevLb.ExpirationDate.Before(now) {
+			source = LEDGER_EXPIRED
+		}
+		acc.ledger.entries = append(acc.ledger.entries, &BalanceLedgerEntry{Account: acc.ID, BalanceUUID: uuid, BalanceID: prevLb.ID, BalanceType: prevLb.Type,
+			Delta: -prevLb.Value, Value: 0, CGRID: origin.CGRID, ActionsID: origin.ActionsID, Source: source, CreatedAt: now})
+	}
+	acc.ledger.balances = crntBalances
+}
+
+// Writes the queued balance changes into the ledger, to be called once the account was saved
+func (acc *Account) writeLedger() {
+	if acc.ledger == nil || ledgerStorage == nil {
+		return
+	}
+	acc.collectLedgerEntries()
+	if len(acc.ledger.entries) == 0 {
+		return
+	}
+	if err := ledgerStorage.SetBalanceLedgerEntries(acc.ledger.entries); err != nil {
+		utils.Logger.Err(fmt.Sprintf("<BalanceLedger> Error writing %d entries for account %s: %s", len(acc.ledger.entries), acc.ID, err.Error()))
+	}
+	acc.ledger.entries = nil
+}
+
+// Saves the account and writes the balance changes performed on it into the ledger
+func saveAccount(acc *Account) error {
+	if err := accountingStorage.SetAccount(acc); err != nil {
+		return err
+	}
+	acc.writeLedger()
+	return nil
+}
+
+// Difference found between the ledger and the current state of an account
+type BalanceLedgerMismatch struct {
+	BalanceUUID  string
+	BalanceID    string
+	BalanceType  string
+	LedgerValue  float64 // Value of the last ledger entry
+	AccountValue float64 // Value of the balance in the account, 0 for removed balances
+	Reason       string  // *value_mismatch, *broken_chain or *unrecorded
+}
+
+// Checks the ledger entries of an account, in the order they were written, against the account balances
+func ReconcileBalanceLedger(acc *Account, entries []*BalanceLedgerEntry) (mismatches []*BalanceLedgerMismatch) {
+	var uuids []string // keep the order of the ledger in the report
+	lastEntries := make(map[string]*BalanceLedgerEntry)
+	brokenChains := make(map[string]bool)
+	for _, entry := range entries {
+		if entry.Account != acc.ID {
+			continue
+		}
+		if lastEntry, has := lastEntries[entry.BalanceUUID]; !has {
+			uuids = append(uuids, entry.BalanceUUID)
+		} else if utils.Round(lastEntry.Value+entry.Delta-entry.Value, globalRoundingDecimals, utils.ROUNDING_MIDDLE) != 0 {
+			brokenChains[entry.BalanceUUID] = true
+		}
+		lastEntries[entry.BalanceUUID] = entry
+	}
+	crntBalances := acc.ledgerBalances()
+	for _, uuid := range uuids {
+		lastEntry := lastEntries[uuid]
+		var accValue float64
+		if lb, has := crntBalances[uuid]; has {
+			accValue = lb.Value
+		}
+		if brokenChains[uuid] {
+			mismatches = append(mismatches, &BalanceLedgerMismatch{BalanceUUID: uuid, BalanceID: lastEntry.BalanceID, BalanceType: lastEntry.BalanceType,
+				LedgerValue: lastEntry.Value, AccountValue: accValue, Reason: LEDGER_BROKEN_CHAIN})
+		}
+		if utils.Round(lastEntry.Value-accValue, globalRoundingDecimals, utils.ROUNDING_MIDDLE) != 0 {
+			mismatches = append(mismatches, &BalanceLedgerMismatch{BalanceUUID: uuid, BalanceID: lastEntry.BalanceID, BalanceType: lastEntry.BalanceType,
+				LedgerValue: lastEntry.Value, AccountValue: accValue, Reason: LEDGER_VALUE_MISMATCH})
+		}
+	}
+	for uuid, lb := range crntBalances {
+		if _, has := lastEntries[uuid]; has || lb.Value == 0 {
+			continue
+		}
+		mismatches = append(mismatches, &BalanceLedgerMismatch{BalanceUUID: uuid, BalanceID: lb.ID, BalanceType: lb.Type,
+			AccountValue: lb.Value, Reason: LEDGER_UNRECORDED})
+	}
+	return
+}
@@ -0,0 +1,146 @@
+/*
+Real-time Charging System for Telecom & ISP environments
+Copyright (C) ITsysCOM GmbH
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>
+*/
+
+package engine
+
+import (
+	"testing"
+	"time"
+
+	"github.com/cgrates/cgrates/utils"
+)
+
+func TestBalanceLedgerActions(t *testing.T) {
+	ledgerDb, _ := NewMapStorage()
+	SetBalanceLedgerStorage(ledgerDb)
+	defer SetBalanceLedgerStorage(nil)
+	acc := &Account{
+		ID: "cgrates.org:ledger",
+		BalanceMap: map[string]Balances{
+			utils.MONETARY: Balances{
+				&Balance{Uuid: "ledger_b1", ID: "b1", Value: 10},
+				&Balance{Uuid: "ledger_b2", ID: "b2", Value: 5, ExpirationDate: time.Now().Add(-time.Hour)},
+			},
+		},
+	}
+	accountingStorage.SetAccount(acc)
+	at := &ActionTiming{
+		ActionsID:  "ACT_LEDGER",
+		accountIDs: utils.StringMap{acc.ID: true},
+		actions: Actions{
+			&Action{ActionType: TOPUP, Weight: 20,
+				Balance: &BalanceFilter{Type: utils.StringPointer(utils.MONETARY), ID: utils.StringPointer("b1"), Value: &utils.ValueFormula{Static: 2.5}}},
+			&Action{ActionType: DEBIT, Weight: 10,
+				Balance: &BalanceFilter{Type: utils.StringPointer(utils.MONETARY), ID: utils.StringPointer("b1"), Value: &utils.ValueFormula{Static: 1}}},
+		},
+	}
+	if err := at.Execute(); err != nil {
+		t.Fatal(err)
+	}
+	entries, err := ledgerDb.GetBalanceLedger(&BalanceLedgerFilter{Account: acc.ID})
+	if err != nil {
+		t.Fatal(err)
+	} else if len(entries) != 3 {
+		t.Fatalf("Received: %s", utils.ToJSON(entries))
+	}
+	for i, eEntry := range []*BalanceLedgerEntry{
+		&BalanceLedgerEntry{BalanceUUID: "ledger_b1", Delta: 2.5, Value: 12.5, Source: TOPUP},
+		&BalanceLedgerEntry{BalanceUUID: "ledger_b2", Delta: -5, Value: 0, Source: LEDGER_EXPIRED},
+		&BalanceLedgerEntry{BalanceUUID: "ledger_b1", Delta: -1, Value: 11.5, Source: DEBIT},
+	} {
+		if entries[i].BalanceUUID != eEntry.BalanceUUID || entries[i].Delta != eEntry.Delta || entries[i].Value != eEntry.Value ||
+			entries[i].Source != eEntry.Source || entries[i].ActionsID != "ACT_LEDGER" || entries[i].BalanceType != utils.MONETARY {
+			t.Errorf("Entry %d, received: %s", i, utils.ToJSON(entries[i]))
+		}
+	}
+	if entries, err := ledgerDb.GetBalanceLedger(&BalanceLedgerFilter{BalanceUUID: "ledger_b2"}); err != nil {
+		t.Error(err)
+	} else if len(entries) != 1 {
+		t.Errorf("Received: %s", utils.ToJSON(entries))
+	}
+	acc, _ = accountingStorage.GetAccount(acc.ID)
+	if mismatches := ReconcileBalanceLedger(acc, entries); len(mismatches) != 0 {
+		t.Errorf("Unexpected mismatches: %s", utils.ToJSON(mismatches))
+	}
+}
+
+func TestBalanceLedgerRefund(t *testing.T) {
+	ledgerDb, _ := NewMapStorage()
+	SetBalanceLedgerStorage(ledgerDb)
+	defer SetBalanceLedgerStorage(nil)
+	acc := &Account{
+		ID: "cgrates.org:ledger_refund",
+		BalanceMap: map[string]Balances{
+			utils.MONETARY: Balances{&Balance{Uuid: "ledger_money", Value: 100}},
+			utils.VOICE:    Balances{&Balance{Uuid: "ledger_minutes", Value: 10}},
+		},
+	}
+	accountingStorage.SetAccount(acc)
+	cd := &CallDescriptor{CgrID: "ledger_cgrid", TOR: utils.VOICE, Increments: Increments{
+		&Increment{Cost: 2, BalanceInfo: &DebitInfo{Monetary: &MonetaryInfo{UUID: "ledger_money"}, AccountID: acc.ID}},
+		&Increment{Duration: 4 * time.Second, BalanceInfo: &DebitInfo{Unit: &UnitInfo{UUID: "ledger_minutes"}, AccountID: acc.ID}},
+	}}
+	if err := cd.RefundIncrements(); err != nil {
+		t.Fatal(err)
+	}
+	entries, err := ledgerDb.GetBalanceLedger(&BalanceLedgerFilter{CGRID: "ledger_cgrid"})
+	if err != nil {
+		t.Fatal(err)
+	} else if len(entries) != 2 {
+		t.Fatalf("Received: %s", utils.ToJSON(entries))
+	}
+	for _, entry := range entries {
+		if entry.Source != LEDGER_REFUND ||
+			(entry.BalanceUUID == "ledger_money" && (entry.Delta != 2 || entry.Value != 102)) ||
+			(entry.BalanceUUID == "ledger_minutes" && (entry.Delta != 4 || entry.Value != 14)) {
+			t.Errorf("Received: %s", utils.ToJSON(entry))
+		}
+	}
+	if entries, err := ledgerDb.GetBalanceLedger(&BalanceLedgerFilter{CGRID: "ledger_cgrid", TimeStart: time.Now().Add(time.Minute)}); err != nil {
+		t.Error(err)
+	} else if len(entries) != 0 {
+		t.Errorf("Received: %s", utils.ToJSON(entries))
+	}
+}
+
+func TestBalanceLedgerReconcile(t *testing.T) {
+	acc := &Account{
+		ID: "cgrates.org:ledger_reconcile",
+		BalanceMap: map[string]Balances{
+			utils.MONETARY: Balances{
+				&Balance{Uuid: "rec_b1", Value: 7.5},
+				&Balance{Uuid: "rec_b2", Value: 3},
+				&Balance{Uuid: "rec_b3", Value: 0},
+			},
+		},
+	}
+	entries := []*BalanceLedgerEntry{
+		&BalanceLedgerEntry{Account: acc.ID, BalanceUUID: "rec_b1", Delta: 10, Value: 10},
+		&BalanceLedgerEntry{Account: acc.ID, BalanceUUID: "rec_b1", Delta: -2.5, Value: 7.5},
+		&BalanceLedgerEntry{Account: acc.ID, BalanceUUID: "rec_b4", Delta: 5, Value: 5},
+		&BalanceLedgerEntry{Account: acc.ID, BalanceUUID: "rec_b4", Delta: -1, Value: 3},
+		&BalanceLedgerEntry{Account: "cgrates.org:other", BalanceUUID: "rec_b2", Delta: 3, Value: 3},
+	}
+	mismatches := ReconcileBalanceLedger(acc, entries)
+	if len(mismatches) != 3 ||
+		mismatches[0].BalanceUUID != "rec_b4" || mismatches[0].Reason != LEDGER_BROKEN_CHAIN ||
+		mismatches[1].BalanceUUID != "rec_b4" || mismatches[1].Reason != LEDGER_VALUE_MISMATCH || mismatches[1].LedgerValue != 3 || mismatches[1].AccountValue != 0 ||
+		mismatches[2].BalanceUUID != "rec_b2" || mismatches[2].Reason != LEDGER_UNRECORDED || mismatches[2].AccountValue != 3 {
+		t.Errorf("Received: %s", utils.ToJSON(mismatches))
+	}
+}
@@ -46,6 +46,7 @@ type Balance struct {
 	Disabled       bool
 	Factor         ValueFactor
 	Blocker        bool
+	Currency       string // currency of a monetary balance, empty for the default one
 	precision      int
 	account        *Account // used to store ub reference for shared balances
 	dirty          bool
@@ -68,7 +69,8 @@ func (b *Balance) Equal(o *Balance) bool {
 		b.Categories.Equal(o.Categories) &&
 		b.SharedGroups.Equal(o.SharedGroups) &&
 		b.Disabled == o.Disabled &&
-		b.Blocker == o.Blocker
+		b.Blocker == o.Blocker &&
+		b.Currency == o.Currency
 }
 
 func (b *Balance) MatchFilter(o *BalanceFilter, skipIds bool) bool {
@@ -90,7 +92,8 @@ func (b *Balance) MatchFilter(o *BalanceFilter, skipIds bool) bool {
 		(o.Categories == nil || b.Categories.Includes(*o.Categories)) &&
 		(o.TimingIDs == nil || b.TimingIDs.Includes(*o.TimingIDs)) &&
 		(o.SharedGroups == nil || b.SharedGroups.Includes(*o.SharedGroups)) &&
-		(o.RatingSubject == nil || b.RatingSubject == *o.RatingSubject)
+		(o.RatingSubject == nil || b.RatingSubject == *o.RatingSubject) &&
+		(o.Currency == nil || b.Currency == *o.Currency)
 }
 
 func (b *Balance) HardMatchFilter(o *BalanceFilter, skipIds bool) bool {
@@ -112,7 +115,8 @@ func (b *Balance) HardMatchFilter(o *BalanceFilter, skipIds bool) bool {
 		(o.Categories == nil || b.Categories.Equal(*o.Categories)) &&
 		(o.TimingIDs == nil || b.TimingIDs.Equal(*o.TimingIDs)) &&
 		(o.SharedGroups == nil || b.SharedGroups.Equal(*o.SharedGroups)) &&
-		(o.RatingSubject == nil || b.RatingSubject == *o.RatingSubject)
+		(o.RatingSubject == nil || b.RatingSubject == *o.RatingSubject) &&
+		(o.Currency == nil || b.Currency == *o.Currency)
 }
 
 // the default balance has standard Id
@@ -181,6 +185,7 @@ func (b *Balance) Clone() *Balance {
 		Timings:        b.Timings, // should not be a problem with aliasing
 		Blocker:        b.Blocker,
 		Disabled:       b.Disabled,
+		Currency:       b.Currency,
 		dirty:          b.dirty,
 	}
 	if b.DestinationIDs != nil {
@@ -209,7 +214,8 @@ func (b *Balance) getMatchingPrefixAndDestID(dest string) (prefix, destId string
 }
 
 // Returns the available number of seconds for a specified credit
-func (b *Balance) GetMinutesForCredit(origCD *CallDescriptor, initialCredit float64) (duration time.Duration, credit float64) {
+// The credit is expressed in creditCurrency, the costs are converted into it before comparing
+func (b *Balance) GetMinutesForCredit(origCD *CallDescriptor, initialCredit float64, creditCurrency string) (duration time.Duration, credit float64) {
 	cd := origCD.Clone()
 	availableDuration := time.Duration(b.GetValue()) * time.Second
 	duration = availableDuration
@@ -220,11 +226,19 @@ func (b *Balance) GetMinutesForCredit(origCD *CallDescriptor, initialCredit floa
 		return 0, credit
 	}
 	if cc.deductConnectFee {
-		connectFee := cc.GetConnectFee()
+		var ri *RateInterval
+		if len(cc.Timespans) != 0 {
+			ri = cc.Timespans[0].RateInterval
+		}
+		exchangeRate, err := getCreditExchangeRate(ri, creditCurrency)
+		if err != nil {
+			return 0, credit
+		}
+		connectFee := exchangeCost(cc.GetConnectFee(), exchangeRate)
 		if connectFee <= credit {
 			credit -= connectFee
 			// remove connect fee from the total cost
-			cc.Cost -= connectFee
+			cc.Cost -= cc.GetConnectFee()
 		} else {
 			return 0, credit
 		}
@@ -239,9 +253,13 @@ func (b *Balance) GetMinutesForCredit(origCD *CallDescriptor, initialCredit floa
 					return
 				}
 			}
+			exchangeRate, err := getCreditExchangeRate(ts.RateInterval, creditCurrency)
+			if err != nil {
+				return
+			}
 			for _, incr := range ts.Increments {
-				if incr.Cost <= credit && availableDuration-incr.Duration >= 0 {
-					credit -= incr.Cost
+				if incrCost := exchangeCost(incr.Cost, exchangeRate); incrCost <= credit && availableDuration-incr.Duration >= 0 {
+					credit -= incrCost
 					duration += incr.Duration
 					availableDuration -= incr.Duration
 				} else {
@@ -295,8 +313,7 @@ func (b *Balance) SubstractValue(amount float64) {
 }
 
 func (b *Balance) SetValue(amount float64) {
-	b.Value = amount
-	b.Value = utils.Round(b.GetValue(), globalRoundingDecimals, utils.ROUNDING_MIDDLE)
+	b.Value = roundCredit(amount) // balances go below zero with debts
 	b.dirty = true
 }
 
@@ -450,9 +467,14 @@ func (b *Balance) debitUnits(cd *CallDescriptor, ub *Account, moneyBalances Bala
 					continue
 				}
 				var moneyBal *Balance
+				var moneyCost, exchangeRate float64
 				for _, mb := range moneyBalances {
-					if mb.GetValue() >= cost {
-						moneyBal = mb
+					mbExchangeRate, err := mb.getExchangeRate(ts.RateInterval)
+					if err != nil {
+						continue // cannot pay in this currency
+					}
+					if mbCost := exchangeCost(cost, mbExchangeRate); mb.GetValue() >= mbCost {
+						moneyBal, moneyCost, exchangeRate = mb, mbCost, mbExchangeRate
 						break
 					}
 				}
@@ -469,11 +491,12 @@ func (b *Balance) debitUnits(cd *CallDescriptor, ub *Account, moneyBalances Bala
 					}
 					inc.BalanceInfo.AccountID = ub.ID
 					if cost != 0 {
-						moneyBal.SubstractValue(cost)
+						moneyBal.SubstractValue(moneyCost)
 						inc.BalanceInfo.Monetary = &MonetaryInfo{
-							UUID:  moneyBal.Uuid,
-							ID:    moneyBal.ID,
-							Value: moneyBal.Value,
+							UUID:         moneyBal.Uuid,
+							ID:           moneyBal.ID,
+							Value:        moneyBal.Value,
+							ExchangeRate: exchangeRate,
 						}
 						cd.MaxCostSoFar += cost
 					}
@@ -481,7 +504,7 @@ func (b *Balance) debitUnits(cd *CallDescriptor, ub *Account, moneyBalances Bala
 					if count {
 						ub.countUnits(amount, cc.TOR, cc, b)
 						if cost != 0 {
-							ub.countUnits(cost, utils.MONETARY, cc, moneyBal)
+							ub.countUnits(moneyCost, utils.MONETARY, cc, moneyBal)
 						}
 					}
 				} else {
@@ -539,12 +562,22 @@ func (b *Balance) debitMoney(cd *CallDescriptor, ub *Account, moneyBalances Bala
 			return nil, errors.New("timespan with no rate interval assigned")
 		}
 		maxCost, strategy := ts.RateInterval.GetMaxCost()
+		exchangeRate, err := b.getExchangeRate(ts.RateInterval)
+		if err != nil {
+			utils.Logger.Warning(fmt.Sprintf("<Rater> Cannot convert costs in %s for balance %s: %v", ts.RateInterval.Rating.Currency, b.ID, err))
+			// the balance cannot pay for the rest of the timespans
+			cc.Timespans = cc.Timespans[:tsIndex]
+			if len(cc.Timespans) == 0 {
+				cc = nil
+			}
+			return cc, nil
+		}
 		//log.Printf("Timing: %+v", ts.RateInterval.Timing)
 		//log.Printf("Rate: %+v", ts.RateInterval.Rating)
 		for incIndex, inc := range ts.Increments {
 			// check standard subject tags
 			//log.Printf("INC: %+v", inc)
-			amount := inc.Cost
+			amount := exchangeCost(inc.Cost, exchangeRate)
 			inc.paid = false
 			if strategy == utils.MAX_COST_DISCONNECT && cd.MaxCostSoFar >= maxCost {
 				// cat the entire current timespan
@@ -583,11 +616,12 @@ func (b *Balance) debitMoney(cd *CallDescriptor, ub *Account, moneyBalances Bala
 
 			if b.GetValue() >= amount {
 				b.SubstractValue(amount)
-				cd.MaxCostSoFar += amount
+				cd.MaxCostSoFar += inc.Cost
 				inc.BalanceInfo.Monetary = &MonetaryInfo{
-					UUID:  b.Uuid,
-					ID:    b.ID,
-					Value: b.Value,
+					UUID:         b.Uuid,
+					ID:           b.ID,
+					Value:        b.Value,
+					ExchangeRate: exchangeRate,
 				}
 				inc.BalanceInfo.AccountID = ub.ID
 				if b.RatingSubject != "" {
@@ -645,6 +679,23 @@ func (bc Balances) Sort() {
 	sort.Sort(bc)
 }
 
+// Returns the total value of the active balances converted into currency, balances which cannot be converted are left out
+func (bc Balances) GetTotalValueIn(currency string) (total float64) {
+	for _, b := range bc {
+		if b.IsExpired() || !b.IsActive() {
+			continue
+		}
+		exchangeRate, err := GetExchangeRate(b.Currency, currency)
+		if err != nil {
+			utils.Logger.Warning(fmt.Sprintf("<Rater> Cannot convert balance %s from %s into %s: %v", b.ID, b.Currency, currency, err))
+			continue
+		}
+		total += b.GetValue() * exchangeRate
+	}
+	total = utils.Round(total, globalRoundingDecimals, utils.ROUNDING_MIDDLE)
+	return
+}
+
 func (bc Balances) GetTotalValue() (total float64) {
 	for _, b := range bc {
 		if !b.IsExpired() && b.IsActive() {
@@ -718,6 +769,7 @@ func (bc Balances) SaveDirtyBalances(acc *Account) {
 					"DestinationIDs":       b.DestinationIDs.String(),
 					"Directions":           b.Directions.String(),
 					"RatingSubject":        b.RatingSubject,
+					"Currency":             b.Currency,
 					"Categories":           b.Categories.String(),
 					"SharedGroups":         b.SharedGroups.String(),
 					"TimingIDs":            b.TimingIDs.String(),
@@ -728,7 +780,7 @@ func (bc Balances) SaveDirtyBalances(acc *Account) {
 			}
 		}
 		if b.account != nil && b.account != acc && b.dirty && savedAccounts[b.account.ID] == false {
-			accountingStorage.SetAccount(b.account)
+			saveAccount(b.account)
 			savedAccounts[b.account.ID] = true
 		}
 	}
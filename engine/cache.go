@@ -1,7 +1,11 @@
 //Simple caching library with expiration capabilities
 package engine
 
-import "sync"
+import (
+	"sync"
+
+	"github.com/cgrates/cgrates/utils"
+)
 
 const (
 	PREFIX_LEN   = 4
@@ -22,6 +26,8 @@ var (
 	transactionON     = false
 	transactionLock   = false
 	dumper            *cacheDumper
+
+	cacheGetsMetric = utils.Metrics.NewCounter("cgrates_cache_gets_total", "Cache lookups per key prefix and result.", "prefix", "result")
 )
 
 type transactionItem struct {
@@ -110,8 +116,18 @@ func CacheSet(key string, value interface{}) {
 // The function to extract a value for a key that never expire
 func CacheGet(key string) (v interface{}, err error) {
 	mux.RLock()
-	defer mux.RUnlock()
-	return cache.Get(key)
+	v, err = cache.Get(key)
+	mux.RUnlock()
+	prefix := key
+	if len(key) > PREFIX_LEN {
+		prefix = key[:PREFIX_LEN]
+	}
+	if err != nil {
+		cacheGetsMetric.Inc(prefix, "miss")
+	} else {
+		cacheGetsMetric.Inc(prefix, "hit")
+	}
+	return
 }
 
 // Appends to an existing slice in the cache key
@@ -118,3 +118,16 @@ func TestCachePop(t *testing.T) {
 		t.Error("Error countiong entries: ", CacheCountEntries("dst_"))
 	}
 }*/
+
+func TestCacheGetMetrics(t *testing.T) {
+	hits, misses := cacheGetsMetric.Value("t31_", "hit"), cacheGetsMetric.Value("t31_", "miss")
+	CacheSet("t31_mm", "test")
+	CacheGet("t31_mm")
+	CacheGet("t31_nn")
+	if rcv := cacheGetsMetric.Value("t31_", "hit"); rcv != hits+1 {
+		t.Error("Received hits: ", rcv)
+	}
+	if rcv := cacheGetsMetric.Value("t31_", "miss"); rcv != misses+1 {
+		t.Error("Received misses: ", rcv)
+	}
+}
@@ -27,6 +27,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/cgrates/cgrates/history"
 	"github.com/cgrates/cgrates/utils"
 	"github.com/cgrates/rpcclient"
 )
@@ -72,7 +73,8 @@ var (
 	cdrStorage               CdrStorage
 	debitPeriod              = 10 * time.Second
 	globalRoundingDecimals   = 6
-	historyScribe            rpcclient.RpcClientConnection
+	historyScribe            *historyQueue
+	historyAuthor            string
 	pubSubServer             rpcclient.RpcClientConnection
 	userService              rpcclient.RpcClientConnection
 	aliasService             rpcclient.RpcClientConnection
@@ -111,7 +113,39 @@ func SetCdrStorage(cStorage CdrStorage) {
 
 // Exported method to set the history scribe.
 func SetHistoryScribe(scribe rpcclient.RpcClientConnection) {
-	historyScribe = scribe
+	if scribe == nil {
+		historyScribe = nil
+		return
+	}
+	historyScribe = newHistoryQueue(scribe)
+}
+
+// Sets the author of the changes sent to the history scribe, eg: cgr-loader@hostname
+func SetHistoryAuthor(author string) {
+	historyAuthor = author
+}
+
+// Waits for the changes already queued to be sent to the history scribe, eg: before cgr-loader exits
+func FlushHistory() {
+	if historyScribe != nil {
+		historyScribe.flush()
+	}
+}
+
+// RecordAccountHistory queues the account for the history scribe. SetAccount does not record it since every debit
+// saves the account, the APIs, loader and actions changing accounts call this instead.
+func RecordAccountHistory(acc *Account) {
+	recordHistory(acc.GetHistoryRecord(false))
+}
+
+// Queues the change for the history scribe if one is configured, without waiting for the reply
+func recordHistory(rec history.Record) {
+	if historyScribe == nil {
+		return
+	}
+	rec.Author = historyAuthor
+	rec.Timestamp = time.Now()
+	historyScribe.record(rec)
 }
 
 func SetPubSub(ps rpcclient.RpcClientConnection) {
@@ -158,7 +192,9 @@ type CallDescriptor struct {
 	PerformRounding bool // flag for rating info rounding
 	DryRun          bool
 	account         *Account
-	testCallcost    *CallCost // testing purpose only!
+	volumes         map[string]time.Duration // account usage consulted by volume tiered ratings, indexed on counter ID
+	ratingData      ratingDataGetter         // source of rating data, nil for the engine's RatingStorage
+	testCallcost    *CallCost                // testing purpose only!
 }
 
 func (cd *CallDescriptor) ValidateCallData() error {
@@ -176,6 +212,14 @@ func (cd *CallDescriptor) AddRatingInfo(ris ...*RatingInfo) {
 	cd.RatingInfos = append(cd.RatingInfos, ris...)
 }
 
+// Returns the source of rating data for this call descriptor
+func (cd *CallDescriptor) getRatingData() ratingDataGetter {
+	if cd.ratingData == nil {
+		return storageRatingData{}
+	}
+	return cd.ratingData
+}
+
 // Gets and caches the user balance information.
 func (cd *CallDescriptor) getAccount() (ub *Account, err error) {
 	if cd.account == nil {
@@ -222,7 +266,7 @@ func (cd *CallDescriptor) getRatingPlansForPrefix(key string, recursionDepth int
 	if recursionDepth > RECURSION_MAX_DEPTH {
 		return utils.ErrMaxRecursionDepth, recursionDepth
 	}
-	rpf, err := RatingProfileSubjectPrefixMatching(key)
+	rpf, err := ratingProfileSubjectPrefixMatching(cd.getRatingData(), key)
 	if err != nil || rpf == nil {
 		return utils.ErrNotFound, recursionDepth
 	}
@@ -241,6 +285,7 @@ func (cd *CallDescriptor) getRatingPlansForPrefix(key string, recursionDepth int
 					Direction:   cd.Direction,
 					Tenant:      cd.Tenant,
 					Destination: cd.Destination,
+					ratingData:  cd.ratingData,
 				}
 				if index == 0 {
 					tempCD.TimeStart = cd.TimeStart
@@ -346,6 +391,9 @@ func (cd *CallDescriptor) splitInTimeSpans() (timespans []*TimeSpan) {
 	if len(cd.RatingInfos) == 0 {
 		return
 	}
+	if cd.hasVolumeRatings() {
+		firstSpan.volumes = cd.getVolumes()
+	}
 	firstSpan.setRatingInfo(cd.RatingInfos[0])
 	if cd.TOR == utils.VOICE {
 		// split on rating plans
@@ -430,6 +478,30 @@ func (cd *CallDescriptor) splitInTimeSpans() (timespans []*TimeSpan) {
 	return
 }
 
+// Checks if any of the loaded rating intervals has volume tiers
+func (cd *CallDescriptor) hasVolumeRatings() bool {
+	for _, ri := range cd.RatingInfos {
+		for _, rIntvl := range ri.RateIntervals {
+			if rIntvl.Rating != nil && rIntvl.Rating.VolumeCounter != "" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Returns the account usage for volume tiered ratings, loading it from the account the first time
+func (cd *CallDescriptor) getVolumes() map[string]time.Duration {
+	if cd.volumes == nil {
+		if acc, err := cd.getAccount(); err == nil {
+			cd.volumes = acc.getVolumes(cd)
+		} else {
+			cd.volumes = make(map[string]time.Duration)
+		}
+	}
+	return cd.volumes
+}
+
 // if the rate interval for any timespan has a RatingIncrement larger than the timespan duration
 // the timespan must expand potentially overlaping folowing timespans and may exceed call
 // descriptor's initial duration
@@ -569,6 +641,7 @@ func (origCD *CallDescriptor) getMaxSessionDuration(origAcc *Account) (time.Dura
 	if account.AllowNegative {
 		return -1, nil
 	}
+	account.holdReservations()
 	// for zero duration index
 	if origCD.DurationIndex < origCD.TimeEnd.Sub(origCD.TimeStart) {
 		origCD.DurationIndex = origCD.TimeEnd.Sub(origCD.TimeStart)
@@ -578,6 +651,7 @@ func (origCD *CallDescriptor) getMaxSessionDuration(origAcc *Account) (time.Dura
 	}
 	//utils.Logger.Debug("ORIG: " + utils.ToJSON(origCD))
 	cd := origCD.Clone()
+	cd.volumes = origAcc.getVolumes(cd) // the cloned account has no counters
 	initialDuration := cd.TimeEnd.Sub(cd.TimeStart)
 	//utils.Logger.Debug(fmt.Sprintf("INITIAL_DURATION: %v", initialDuration))
 	defaultBalance := account.GetDefaultMoneyBalance()
@@ -588,7 +662,8 @@ func (origCD *CallDescriptor) getMaxSessionDuration(origAcc *Account) (time.Dura
 	//utils.Logger.Debug("ACCOUNT: " + utils.ToJSON(account))
 	//utils.Logger.Debug("DEFAULT_BALANCE: " + utils.ToJSON(defaultBalance))
 
-	cc, err := cd.debit(account, true, false)
+	// with a credit limit the default balance pays the debt up to it
+	cc, err := cd.debit(account, true, account.CreditLimit > 0)
 	//utils.Logger.Debug("CC: " + utils.ToJSON(cc))
 	//log.Print("CC: ", utils.ToIJSON(cc))
 	//utils.Logger.Debug(fmt.Sprintf("ERR: %v", err))
@@ -599,7 +674,19 @@ func (origCD *CallDescriptor) getMaxSessionDuration(origAcc *Account) (time.Dura
 	//log.Printf("CC: %+v", cc)
 	// not enough credit for connect fee
 	if cc.negativeConnectFee == true {
-		return 0, nil
+		var ri *RateInterval
+		if len(cc.Timespans) != 0 {
+			ri = cc.Timespans[0].RateInterval
+		}
+		exchangeRate, err := defaultBalance.getExchangeRate(ri)
+		if err != nil {
+			return 0, err
+		}
+		initialDefaultBalanceValue -= exchangeCost(cc.GetConnectFee(), exchangeRate) // in the currency of the default balance
+		if account.CreditLimit == 0 ||
+			roundCredit(initialDefaultBalanceValue+account.CreditLimit) < 0 {
+			return 0, nil
+		}
 	}
 
 	var totalCost float64
@@ -625,8 +712,8 @@ func (origCD *CallDescriptor) getMaxSessionDuration(origAcc *Account) (time.Dura
 			//utils.Logger.Debug("INCR: " + utils.ToJSON(incr))
 			totalCost += incr.Cost
 			if incr.BalanceInfo.Monetary != nil && incr.BalanceInfo.Monetary.UUID == defaultBalance.Uuid {
-				initialDefaultBalanceValue -= incr.Cost
-				if initialDefaultBalanceValue < 0 {
+				initialDefaultBalanceValue -= exchangeCost(incr.Cost, incr.BalanceInfo.Monetary.ExchangeRate)
+				if roundCredit(initialDefaultBalanceValue+account.CreditLimit) < 0 {
 					// this increment was payed with debt
 					// TODO: improve this check
 					//utils.Logger.Debug(fmt.Sprintf("1_INIT DUR %v, TOTAL DUR: %v", initialDuration, totalDuration))
@@ -687,6 +774,10 @@ func (cd *CallDescriptor) debit(account *Account, dryRun bool, goNegative bool)
 	if cd.TOR == "" {
 		cd.TOR = utils.VOICE
 	}
+	if !dryRun {
+		prevLedgerOrigin := account.setLedgerOrigin(&ledgerOrigin{CGRID: cd.CgrID, Source: LEDGER_RATING})
+		defer account.setLedgerOrigin(prevLedgerOrigin)
+	}
 	//log.Printf("Debit CD: %+v", cd)
 	cc, err = account.debitCreditBalance(cd, !dryRun, dryRun, goNegative)
 	//log.Printf("HERE: %+v %v", cc, err)
@@ -698,7 +789,7 @@ func (cd *CallDescriptor) debit(account *Account, dryRun bool, goNegative bool)
 	cc.UpdateRatedUsage()
 	cc.Timespans.Compress()
 	if !dryRun {
-		accountingStorage.SetAccount(account)
+		saveAccount(account)
 	}
 	if cd.PerformRounding {
 		cc.Round()
@@ -807,8 +898,9 @@ func (cd *CallDescriptor) RefundIncrements() error {
 				if acc, err := accountingStorage.GetAccount(increment.BalanceInfo.AccountID); err == nil && acc != nil {
 					account = acc
 					accountsCache[increment.BalanceInfo.AccountID] = account
+					account.setLedgerOrigin(&ledgerOrigin{CGRID: cd.CgrID, Source: LEDGER_REFUND})
 					// will save the account only once at the end of the function
-					defer accountingStorage.SetAccount(account)
+					defer saveAccount(account)
 				}
 			}
 			if account == nil {
@@ -831,8 +923,9 @@ func (cd *CallDescriptor) RefundIncrements() error {
 				if balance = account.BalanceMap[utils.MONETARY].GetBalance(increment.BalanceInfo.Monetary.UUID); balance == nil {
 					return 0, nil
 				}
-				balance.AddValue(increment.Cost)
-				account.countUnits(-increment.Cost, utils.MONETARY, cc, balance)
+				refundCost := exchangeCost(increment.Cost, increment.BalanceInfo.Monetary.ExchangeRate)
+				balance.AddValue(refundCost)
+				account.countUnits(-refundCost, utils.MONETARY, cc, balance)
 			}
 		}
 		return 0, nil
@@ -856,8 +949,9 @@ func (cd *CallDescriptor) RefundRounding() error {
 				if acc, err := accountingStorage.GetAccount(increment.BalanceInfo.AccountID); err == nil && acc != nil {
 					account = acc
 					accountsCache[increment.BalanceInfo.AccountID] = account
+					account.setLedgerOrigin(&ledgerOrigin{CGRID: cd.CgrID, Source: LEDGER_REFUND_ROUNDING})
 					// will save the account only once at the end of the function
-					defer accountingStorage.SetAccount(account)
+					defer saveAccount(account)
 				}
 			}
 			if account == nil {
@@ -870,8 +964,9 @@ func (cd *CallDescriptor) RefundRounding() error {
 				if balance = account.BalanceMap[utils.MONETARY].GetBalance(increment.BalanceInfo.Monetary.UUID); balance == nil {
 					return 0, nil
 				}
-				balance.AddValue(-increment.Cost)
-				account.countUnits(increment.Cost, utils.MONETARY, cc, balance)
+				roundingCost := exchangeCost(increment.Cost, increment.BalanceInfo.Monetary.ExchangeRate)
+				balance.AddValue(-roundingCost)
+				account.countUnits(roundingCost, utils.MONETARY, cc, balance)
 			}
 		}
 		return 0, nil
@@ -925,6 +1020,7 @@ func (cd *CallDescriptor) Clone() *CallDescriptor {
 		DryRun:          cd.DryRun,
 		CgrID:           cd.CgrID,
 		RunID:           cd.RunID,
+		ratingData:      cd.ratingData,
 	}
 }
 
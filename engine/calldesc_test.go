@@ -33,7 +33,8 @@ var (
 )
 
 func init() {
-	historyScribe, _ = history.NewMockScribe()
+	mockScribe, _ := history.NewMockScribe()
+	SetHistoryScribe(mockScribe)
 	ratingStorage.Flush("")
 	accountingStorage.Flush("")
 	populateDB()
@@ -487,6 +487,27 @@ func (cdr *CDR) ForkCdr(runId string, RequestTypeFld, directionFld, tenantFld, c
 	return frkStorCdr, nil
 }
 
+// AsCallDescriptor builds the CallDescriptor used to rate the CDR
+func (cdr *CDR) AsCallDescriptor() *CallDescriptor {
+	timeStart := cdr.AnswerTime
+	if timeStart.IsZero() { // Fix for FreeSWITCH unanswered calls
+		timeStart = cdr.SetupTime
+	}
+	return &CallDescriptor{
+		TOR:             cdr.ToR,
+		Direction:       cdr.Direction,
+		Tenant:          cdr.Tenant,
+		Category:        cdr.Category,
+		Subject:         cdr.Subject,
+		Account:         cdr.Account,
+		Destination:     cdr.Destination,
+		TimeStart:       timeStart,
+		TimeEnd:         timeStart.Add(cdr.Usage),
+		DurationIndex:   cdr.Usage,
+		PerformRounding: true,
+	}
+}
+
 func (cdr *CDR) AsExternalCDR() *ExternalCDR {
 	return &ExternalCDR{CGRID: cdr.CGRID,
 		RunID:           cdr.RunID,
@@ -0,0 +1,59 @@
+/*
+Real-time Charging System for Telecom & ISP environments
+Copyright (C) ITsysCOM GmbH
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>
+*/
+
+package engine
+
+import (
+	"errors"
+
+	"github.com/cgrates/cgrates/utils"
+)
+
+// Runs the CDR export job with the given id, set by the service owning the export jobs
+var cdrExportJobRunner func(jobID string) error
+
+func SetCdrExportJobRunner(runner func(jobID string) error) {
+	cdrExportJobRunner = runner
+}
+
+// Runs the CDR export job with the id in ExtraParameters
+func cdrExportAction(ub *Account, sq *StatsQueueTriggered, a *Action, acs Actions) error {
+	if cdrExportJobRunner == nil {
+		return errors.New("CDR_EXPORTS_NOT_ENABLED")
+	}
+	return cdrExportJobRunner(a.ExtraParameters)
+}
+
+// Builds the action timing running a CDR export job out of config
+func NewCdrExportTiming(jobID string, timing *utils.TPTiming) *ActionTiming {
+	at := &ActionTiming{
+		Uuid: utils.GenUUID(),
+		Timing: &RateInterval{
+			Timing: &RITiming{
+				Years:     timing.Years,
+				Months:    timing.Months,
+				MonthDays: timing.MonthDays,
+				WeekDays:  timing.WeekDays,
+				StartTime: timing.StartTime,
+			},
+		},
+		ActionsID: utils.ConcatenatedKey(CDR_EXPORT, jobID), // readable in scheduler logs
+	}
+	at.SetActions(Actions{&Action{Id: jobID, ActionType: CDR_EXPORT, ExtraParameters: jobID}})
+	return at
+}
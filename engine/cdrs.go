@@ -19,11 +19,9 @@ along with this program.  If not, see <http://www.gnu.org/licenses/>
 package engine
 
 import (
-	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"net/http"
-	"path"
 	"reflect"
 	"strings"
 	"time"
@@ -85,7 +83,23 @@ func NewCdrServer(cgrCfg *config.CGRConfig, cdrDb CdrStorage, rater, pubsub, use
 	if stats == nil || reflect.ValueOf(stats).IsNil() {
 		stats = nil
 	}
-	return &CdrServer{cgrCfg: cgrCfg, cdrDb: cdrDb, rals: rater, pubsub: pubsub, users: users, aliases: aliases, stats: stats, guard: Guardian}, nil
+	var replSpool *ReplicationSpool
+	if len(cgrCfg.CDRSCdrReplication) != 0 {
+		var err error
+		if replSpool, err = NewReplicationSpool(cgrCfg.CDRSReplSpoolDir, cgrCfg.HttpSkipTlsVerify); err != nil {
+			return nil, err
+		}
+		if imported, err := replSpool.ImportFallbackFiles(cgrCfg.HttpFailedDir); err != nil {
+			utils.Logger.Err(fmt.Sprintf("<CDRS> Importing replication fallback files from %s, error: %s", cgrCfg.HttpFailedDir, err.Error()))
+		} else if imported != 0 {
+			utils.Logger.Info(fmt.Sprintf("<CDRS> Imported %d replication fallback files from %s into the spool", imported, cgrCfg.HttpFailedDir))
+		}
+		if cgrCfg.CDRSReplRetryInterval > 0 {
+			go replSpool.replayLoop(cgrCfg.CDRSReplRetryInterval)
+		}
+	}
+	return &CdrServer{cgrCfg: cgrCfg, cdrDb: cdrDb, rals: rater, pubsub: pubsub, users: users, aliases: aliases, stats: stats, guard: Guardian,
+		replSpool: replSpool}, nil
 }
 
 type CdrServer struct {
@@ -98,6 +112,7 @@ type CdrServer struct {
 	stats         rpcclient.RpcClientConnection
 	guard         *GuardianLock
 	responseCache *ResponseCache
+	replSpool     *ReplicationSpool // Delivers CDR replications, keeping the failed ones until replayed
 }
 
 func (self *CdrServer) Timezone() string {
@@ -150,8 +165,20 @@ func (self *CdrServer) storeSMCost(smCost *SMCost, checkDuplicate bool) error {
 	return self.cdrDb.SetSMCost(smCost)
 }
 
+var (
+	cdrsProcessedMetric    = utils.Metrics.NewCounter("cgrates_cdrs_processed_total", "CDRs received by CDRS, per processing status.", "status")
+	cdrsRatingErrorsMetric = utils.Metrics.NewCounter("cgrates_cdrs_rating_errors_total", "Derived CDRs which could not be rated by CDRS.")
+)
+
 // Returns error if not able to properly store the CDR, mediation is async since we can always recover offline
 func (self *CdrServer) processCdr(cdr *CDR) (err error) {
+	defer func() {
+		if err != nil {
+			cdrsProcessedMetric.Inc("failed")
+		} else {
+			cdrsProcessedMetric.Inc("processed")
+		}
+	}()
 	if cdr.Direction == "" {
 		cdr.Direction = utils.OUT
 	}
@@ -219,21 +246,30 @@ func (self *CdrServer) deriveRateStoreStatsReplicate(cdr *CDR, store, stats, rep
 			utils.Logger.Err(fmt.Sprintf("<CDRS> Aliasing CDR %+v, got error: %s", cdrRun, err.Error()))
 			continue
 		}
+		if cdrRun.RunID == utils.MetaTax { // Taxes are only calculated, the account is debited by the other runs
+			cdrRun.RequestType = utils.META_RATED
+		}
 		rcvRatedCDRs, err := self.rateCDR(cdrRun)
 		if err != nil {
+			cdrsRatingErrorsMetric.Inc()
 			cdrRun.Cost = -1.0 // If there was an error, mark the CDR
 			cdrRun.ExtraInfo = err.Error()
 			rcvRatedCDRs = []*CDR{cdrRun}
 		}
 		ratedCDRs = append(ratedCDRs, rcvRatedCDRs...)
 	}
-	// Request should be processed by SureTax
+	// Request should be processed by SureTax or by the local tax rules
 	for _, ratedCDR := range ratedCDRs {
 		if ratedCDR.RunID == utils.META_SURETAX {
 			if err := SureTaxProcessCdr(ratedCDR); err != nil {
 				ratedCDR.Cost = -1.0
 				ratedCDR.ExtraInfo = err.Error() // Something failed, write the error in the ExtraInfo
 			}
+		} else if ratedCDR.RunID == utils.MetaTax && ratedCDR.Cost != -1.0 {
+			if err := self.taxCDR(ratedCDR); err != nil {
+				ratedCDR.Cost = -1.0
+				ratedCDR.ExtraInfo = err.Error()
+			}
 		}
 	}
 	// Store rated CDRs
@@ -265,6 +301,16 @@ func (self *CdrServer) deriveRateStoreStatsReplicate(cdr *CDR, store, stats, rep
 	return nil
 }
 
+// Replaces the cost of the CDR with the local taxes calculated by RALs
+func (self *CdrServer) taxCDR(cdr *CDR) error {
+	var tc TaxCost
+	if err := self.rals.Call("Responder.GetCDRTaxes", cdr, &tc); err != nil {
+		return err
+	}
+	tc.applyOnCDR(cdr)
+	return nil
+}
+
 func (self *CdrServer) deriveCdrs(cdr *CDR) ([]*CDR, error) {
 	dfltCDRRun := cdr.Clone()
 	cdrRuns := []*CDR{dfltCDRRun}
@@ -390,23 +436,7 @@ func (self *CdrServer) rateCDR(cdr *CDR) ([]*CDR, error) {
 func (self *CdrServer) getCostFromRater(cdr *CDR) (*CallCost, error) {
 	cc := new(CallCost)
 	var err error
-	timeStart := cdr.AnswerTime
-	if timeStart.IsZero() { // Fix for FreeSWITCH unanswered calls
-		timeStart = cdr.SetupTime
-	}
-	cd := &CallDescriptor{
-		TOR:             cdr.ToR,
-		Direction:       cdr.Direction,
-		Tenant:          cdr.Tenant,
-		Category:        cdr.Category,
-		Subject:         cdr.Subject,
-		Account:         cdr.Account,
-		Destination:     cdr.Destination,
-		TimeStart:       timeStart,
-		TimeEnd:         timeStart.Add(cdr.Usage),
-		DurationIndex:   cdr.Usage,
-		PerformRounding: true,
-	}
+	cd := cdr.AsCallDescriptor()
 	if utils.IsSliceMember([]string{utils.META_PSEUDOPREPAID, utils.META_POSTPAID, utils.META_PREPAID, utils.PSEUDOPREPAID, utils.POSTPAID, utils.PREPAID}, cdr.RequestType) { // Prepaid - Cost can be recalculated in case of missing records from SM
 		err = self.rals.Call("Responder.Debit", cd, cc)
 	} else {
@@ -420,6 +450,7 @@ func (self *CdrServer) getCostFromRater(cdr *CDR) (*CallCost, error) {
 
 // ToDo: Add websocket support
 func (self *CdrServer) replicateCdr(cdr *CDR) error {
+	var syncErr error
 	for _, rplCfg := range self.cgrCfg.CDRSCdrReplication {
 		passesFilters := true
 		for _, cdfFltr := range rplCfg.CdrFilter {
@@ -431,47 +462,42 @@ func (self *CdrServer) replicateCdr(cdr *CDR) error {
 		if !passesFilters { // Not passes filters, ignore this replication
 			continue
 		}
-		var body interface{}
-		var content = ""
-		switch rplCfg.Transport {
-		case utils.META_HTTP_POST:
-			content = utils.CONTENT_FORM
-			body = cdr.AsHttpForm()
-		case utils.META_HTTP_JSON:
-			content = utils.CONTENT_JSON
-			jsn, err := json.Marshal(cdr)
-			if err != nil {
-				return err
-			}
-			body = jsn
+		content, err := replicationContent(cdr, rplCfg.Transport)
+		if err != nil {
+			return err
 		}
 		var errChan chan error
 		if rplCfg.Synchronous {
 			errChan = make(chan error)
 		}
-		go func(body interface{}, rplCfg *config.CdrReplicationCfg, content string, errChan chan error) {
-			fallbackPath := path.Join(
-				self.cgrCfg.HttpFailedDir,
-				rplCfg.FallbackFileName())
-			_, _, err := utils.HttpPoster(
-				rplCfg.Address, self.cgrCfg.HttpSkipTlsVerify, body,
-				content, rplCfg.Attempts, fallbackPath, false) // ToDo: Review caching here after we are sure that the connection leak is gone
-			if err != nil {
+		go func(content []byte, rplCfg *config.CdrReplicationCfg, errChan chan error) {
+			err := self.replSpool.Post(rplCfg.Transport, rplCfg.Address, cdr.CGRID, content, rplCfg.Attempts)
+			if err == utils.ErrReplicationSpooled {
+				utils.Logger.Warning(fmt.Sprintf(
+					"<CDRReplicator> Replicating CDR with CGRID: %s to <%s>, spooled for replay", cdr.CGRID, rplCfg.Address))
+			} else if err != nil {
 				utils.Logger.Err(fmt.Sprintf(
 					"<CDRReplicator> Replicating CDR: %+v, got error: %s", cdr, err.Error()))
-				if rplCfg.Synchronous {
-					errChan <- err
-				}
 			}
 			if rplCfg.Synchronous {
-				errChan <- nil
+				errChan <- err
+			}
+		}(content, rplCfg, errChan)
+		if rplCfg.Synchronous { // Synchronize here, reporting back the first failed or spooled replication
+			if err := <-errChan; err != nil && syncErr == nil {
+				syncErr = err
 			}
-		}(body, rplCfg, content, errChan)
-		if rplCfg.Synchronous { // Synchronize here
-			<-errChan
 		}
 	}
-	return nil
+	return syncErr
+}
+
+// Number of CDR replications waiting in the spool
+func (self *CdrServer) SpooledReplications() int {
+	if self.replSpool == nil {
+		return 0
+	}
+	return self.replSpool.Count()
 }
 
 // Called by rate/re-rate API, FixMe: deprecate it once new APIer structure is operational
@@ -555,6 +581,19 @@ func (self *CdrServer) V1RateCDRs(attrs utils.AttrRateCDRs, reply *string) error
 	return nil
 }
 
+// Replays the CDR replications which could not be delivered, RPC method
+func (self *CdrServer) V1ReplayFailedReplications(attrs utils.AttrReplayFailedReplications, reply *utils.ReplayedReplications) error {
+	if self.replSpool == nil {
+		return utils.ErrReplicationDisabled
+	}
+	replayed, failed, err := self.replSpool.Replay(attrs.Transport, attrs.Address)
+	if err != nil {
+		return utils.NewErrServerError(err)
+	}
+	*reply = utils.ReplayedReplications{Replayed: replayed, Failed: failed}
+	return nil
+}
+
 func (cdrsrv *CdrServer) Call(serviceMethod string, args interface{}, reply interface{}) error {
 	parts := strings.Split(serviceMethod, ".")
 	if len(parts) != 2 {
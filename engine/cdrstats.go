@@ -31,6 +31,7 @@ func NewCdrStatsFromCdrStatsCfg(csCfg *config.CdrStatsConfig) *CdrStats {
 		Id:              csCfg.Id,
 		QueueLength:     csCfg.QueueLength,
 		TimeWindow:      csCfg.TimeWindow,
+		BucketInterval:  csCfg.BucketInterval,
 		Metrics:         csCfg.Metrics,
 		SetupInterval:   csCfg.SetupInterval,
 		TOR:             csCfg.TORs,
@@ -57,6 +58,7 @@ type CdrStats struct {
 	Id              string        // Config id, unique per config instance
 	QueueLength     int           // Number of items in the stats buffer
 	TimeWindow      time.Duration // Will only keep the CDRs who's call setup time is not older than time.Now()-TimeWindow
+	BucketInterval  time.Duration // Aggregate the CDRs in buckets of this interval instead of keeping them individually
 	SaveInterval    time.Duration
 	Metrics         []string        // ASR, ACD, ACC
 	SetupInterval   []time.Time     // CDRFieldFilter on SetupInterval, 2 or less items (>= start interval,< stop_interval)
@@ -182,6 +184,7 @@ func (cs *CdrStats) AcceptCdr(cdr *CDR) bool {
 func (cs *CdrStats) hasGeneralConfigs() bool {
 	return cs.QueueLength == 0 &&
 		cs.TimeWindow == 0 &&
+		cs.BucketInterval == 0 &&
 		cs.SaveInterval == 0 &&
 		len(cs.Metrics) == 0
 }
@@ -189,6 +192,7 @@ func (cs *CdrStats) hasGeneralConfigs() bool {
 func (cs *CdrStats) equalExceptTriggers(other *CdrStats) bool {
 	return cs.QueueLength == other.QueueLength &&
 		cs.TimeWindow == other.TimeWindow &&
+		cs.BucketInterval == other.BucketInterval &&
 		cs.SaveInterval == other.SaveInterval &&
 		reflect.DeepEqual(cs.Metrics, other.Metrics) &&
 		reflect.DeepEqual(cs.SetupInterval, other.SetupInterval) &&
@@ -0,0 +1,73 @@
+/*
+Real-time Charging System for Telecom & ISP environments
+Copyright (C) ITsysCOM GmbH
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>
+*/
+
+package engine
+
+import "github.com/cgrates/cgrates/utils"
+
+// Conversion rate between two currencies
+type ExchangeRate struct {
+	FromCurrency string
+	ToCurrency   string
+	Rate         float64 // units of ToCurrency for one unit of FromCurrency
+}
+
+func (exr *ExchangeRate) GetId() string {
+	return utils.ConcatenatedKey(exr.FromCurrency, exr.ToCurrency)
+}
+
+// Returns the rate converting amounts in fromCurrency into toCurrency, the opposite rate is inverted when the direct one is not defined
+func GetExchangeRate(fromCurrency, toCurrency string) (float64, error) {
+	if fromCurrency == "" || toCurrency == "" || fromCurrency == toCurrency {
+		return 1, nil
+	}
+	exr, err := ratingStorage.GetExchangeRate(utils.ConcatenatedKey(fromCurrency, toCurrency), false)
+	if err == nil && exr.Rate > 0 {
+		return exr.Rate, nil
+	} else if err != nil && err != utils.ErrNotFound {
+		return 0, err
+	}
+	if exr, err = ratingStorage.GetExchangeRate(utils.ConcatenatedKey(toCurrency, fromCurrency), false); err == nil && exr.Rate > 0 {
+		return 1 / exr.Rate, nil
+	} else if err != nil && err != utils.ErrNotFound {
+		return 0, err
+	}
+	return 0, utils.ErrExchangeRateNotFound
+}
+
+// Returns the rate converting the costs of the rating into the currency of the balance, 0 when no conversion is needed
+func (b *Balance) getExchangeRate(ri *RateInterval) (float64, error) {
+	if ri == nil || ri.Rating == nil ||
+		ri.Rating.Currency == "" || b.Currency == "" || ri.Rating.Currency == b.Currency {
+		return 0, nil
+	}
+	return GetExchangeRate(ri.Rating.Currency, b.Currency)
+}
+
+// Returns the rate converting the costs of the rating into the currency the credit is expressed in, 0 when no conversion is needed
+func getCreditExchangeRate(ri *RateInterval, creditCurrency string) (float64, error) {
+	return (&Balance{Currency: creditCurrency}).getExchangeRate(ri)
+}
+
+// Converts the cost with the exchange rate, 0 meaning no conversion
+func exchangeCost(cost, exchangeRate float64) float64 {
+	if exchangeRate == 0 {
+		return cost
+	}
+	return utils.Round(cost*exchangeRate, globalRoundingDecimals, utils.ROUNDING_MIDDLE)
+}
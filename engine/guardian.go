@@ -21,17 +21,22 @@ package engine
 import (
 	"sync"
 	"time"
+
+	"github.com/cgrates/cgrates/utils"
 )
 
 // global package variable
 var Guardian = &GuardianLock{locksMap: make(map[string]chan bool)}
 
+var guardianWaitMetric = utils.Metrics.NewHistogram("cgrates_guardian_lock_wait_seconds", "Time spent waiting to acquire GuardianLock locks.", utils.DefaultLatencyBuckets)
+
 type GuardianLock struct {
 	locksMap map[string]chan bool
 	mu       sync.RWMutex
 }
 
 func (cm *GuardianLock) Guard(handler func() (interface{}, error), timeout time.Duration, names ...string) (reply interface{}, err error) {
+	waitStart := time.Now()
 	var locks []chan bool // take existing locks out of the mutex
 	cm.mu.Lock()
 	for _, name := range names {
@@ -48,6 +53,7 @@ func (cm *GuardianLock) Guard(handler func() (interface{}, error), timeout time.
 	for _, lock := range locks {
 		lock <- true
 	}
+	guardianWaitMetric.Observe(time.Since(waitStart).Seconds())
 
 	funcWaiter := make(chan bool)
 	go func() {
@@ -0,0 +1,70 @@
+/*
+Rating system designed to be used in VoIP Carriers World
+Copyright (C) 2012-2015 ITsysCOM
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>
+*/
+
+package engine
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/cgrates/cgrates/history"
+	"github.com/cgrates/cgrates/utils"
+	"github.com/cgrates/rpcclient"
+)
+
+const HISTORY_QUEUE_SIZE = 10000
+
+func newHistoryQueue(scribe rpcclient.RpcClientConnection) *historyQueue {
+	hq := &historyQueue{scribe: scribe, records: make(chan history.Record, HISTORY_QUEUE_SIZE)}
+	go hq.serve()
+	return hq
+}
+
+// historyQueue sends the changes to the history scribe one by one, in the order they were produced,
+// so the versions of an object are recorded in the right order and the changes do not wait for the scribe
+type historyQueue struct {
+	scribe  rpcclient.RpcClientConnection
+	records chan history.Record
+	pending sync.WaitGroup
+}
+
+// Queues the record, waiting for room in the queue if the scribe cannot keep up so no change is lost
+func (hq *historyQueue) record(rec history.Record) {
+	hq.pending.Add(1)
+	select {
+	case hq.records <- rec:
+	default:
+		utils.Logger.Warning(fmt.Sprintf("<History> Queue full, waiting to record %s: %s", rec.Filename, rec.Id))
+		hq.records <- rec
+	}
+}
+
+// Waits for the queued records to be sent
+func (hq *historyQueue) flush() {
+	hq.pending.Wait()
+}
+
+func (hq *historyQueue) serve() {
+	for rec := range hq.records {
+		var response int
+		if err := hq.scribe.Call("HistoryV1.Record", rec, &response); err != nil {
+			utils.Logger.Err(fmt.Sprintf("<History> Could not record %s: %s, error: %s", rec.Filename, rec.Id, err.Error()))
+		}
+		hq.pending.Done()
+	}
+}
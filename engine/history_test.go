@@ -19,14 +19,19 @@ along with this program.  If not, see <http://www.gnu.org/licenses/>
 package engine
 
 import (
+	"reflect"
+	"strconv"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/cgrates/cgrates/history"
 )
 
 func TestHistoryRatinPlans(t *testing.T) {
-	scribe := historyScribe.(*history.MockScribe)
+	FlushHistory()
+	scribe := historyScribe.scribe.(*history.MockScribe)
 	buf := scribe.GetBuffer(history.RATING_PROFILES_FN)
 	if !strings.Contains(buf.String(), `{"Id":"*out:vdf:0:minu","RatingPlanActivations":[{"ActivationTime":"2012-01-01T00:00:00Z","RatingPlanId":"EVENING","FallbackKeys":null,"CdrStatQueueIds":[""]}]}`) {
 		t.Error("Error in destination history content:", buf.String())
@@ -34,7 +39,8 @@ func TestHistoryRatinPlans(t *testing.T) {
 }
 
 func TestHistoryDestinations(t *testing.T) {
-	scribe := historyScribe.(*history.MockScribe)
+	FlushHistory()
+	scribe := historyScribe.scribe.(*history.MockScribe)
 	buf := scribe.GetBuffer(history.DESTINATIONS_FN)
 	expected := `{"Id":"ALL","Prefixes":["49","41","43"]},
 {"Id":"DST_UK_Mobile_BIG5","Prefixes":["447956"]},
@@ -54,3 +60,82 @@ func TestHistoryDestinations(t *testing.T) {
 		t.Error("Error in destination history content:", buf.String())
 	}
 }
+
+type historyRecorderMock struct {
+	ids []string
+}
+
+func (hr *historyRecorderMock) Call(serviceMethod string, args interface{}, reply interface{}) error {
+	hr.ids = append(hr.ids, args.(history.Record).Id)
+	return nil
+}
+
+func TestHistoryQueueOrder(t *testing.T) {
+	hr := new(historyRecorderMock)
+	hq := newHistoryQueue(hr)
+	var eIds []string
+	for i := 0; i < 100; i++ {
+		id := strconv.Itoa(i)
+		hq.record(history.Record{Id: id, Filename: history.ACCOUNTS_FN})
+		eIds = append(eIds, id)
+	}
+	hq.flush()
+	if !reflect.DeepEqual(eIds, hr.ids) {
+		t.Errorf("Expecting: %+v, received: %+v", eIds, hr.ids)
+	}
+}
+
+type historyRecorderBlockingMock struct {
+	sync.Mutex
+	release chan struct{}
+	ids     []string
+}
+
+func (hr *historyRecorderBlockingMock) Call(serviceMethod string, args interface{}, reply interface{}) error {
+	<-hr.release
+	hr.Lock()
+	hr.ids = append(hr.ids, args.(history.Record).Id)
+	hr.Unlock()
+	return nil
+}
+
+func TestHistoryQueueFullNoDrop(t *testing.T) {
+	hr := &historyRecorderBlockingMock{release: make(chan struct{})}
+	hq := newHistoryQueue(hr)
+	nrRecs := HISTORY_QUEUE_SIZE + 10
+	done := make(chan struct{})
+	go func() {
+		for i := 0; i < nrRecs; i++ {
+			hq.record(history.Record{Id: strconv.Itoa(i), Filename: history.ACCOUNTS_FN})
+		}
+		close(done)
+	}()
+	select {
+	case <-done:
+		t.Fatal("Records queued over the queue size while the scribe was blocked")
+	case <-time.After(50 * time.Millisecond):
+	}
+	close(hr.release)
+	<-done
+	hq.flush()
+	if len(hr.ids) != nrRecs {
+		t.Errorf("Expecting %d records, received: %d", nrRecs, len(hr.ids))
+	}
+}
+
+func TestHistoryAccountNotRecordedOnSave(t *testing.T) {
+	acc := &Account{ID: "cgrates.org:hist_on_save"}
+	if err := accountingStorage.SetAccount(acc); err != nil {
+		t.Fatal(err)
+	}
+	FlushHistory()
+	scribe := historyScribe.scribe.(*history.MockScribe)
+	if strings.Contains(scribe.GetBuffer(history.ACCOUNTS_FN).String(), acc.ID) {
+		t.Error("Account recorded on save:", scribe.GetBuffer(history.ACCOUNTS_FN).String())
+	}
+	RecordAccountHistory(acc)
+	FlushHistory()
+	if !strings.Contains(scribe.GetBuffer(history.ACCOUNTS_FN).String(), acc.ID) {
+		t.Error("Account not recorded:", scribe.GetBuffer(history.ACCOUNTS_FN).String())
+	}
+}
@@ -364,16 +364,16 @@ func (lc *LCRCost) SortLoadDistribution() {
 			haveRatiolessSuppliers = true
 			continue
 		}
-		cdrCount := len(sq.Cdrs)
+		cdrCount := sq.queuedItems()
 		if cdrCount < ratio {
 			supCost.Cost = float64(LOW_PRIORITY_LIMIT + rand.Intn(RAND_LIMIT))
 			continue
 		}
 		if cdrCount%ratio == 0 {
-			supCost.Cost = float64(MED_PRIORITY_LIMIT+rand.Intn(RAND_LIMIT)) + (time.Now().Sub(sq.Cdrs[len(sq.Cdrs)-1].SetupTime).Seconds() / RAND_LIMIT)
+			supCost.Cost = float64(MED_PRIORITY_LIMIT+rand.Intn(RAND_LIMIT)) + (time.Now().Sub(sq.lastSetupTime()).Seconds() / RAND_LIMIT)
 			continue
 		} else {
-			supCost.Cost = float64(HIGH_PRIORITY_LIMIT+rand.Intn(RAND_LIMIT)) + (time.Now().Sub(sq.Cdrs[len(sq.Cdrs)-1].SetupTime).Seconds() / RAND_LIMIT)
+			supCost.Cost = float64(HIGH_PRIORITY_LIMIT+rand.Intn(RAND_LIMIT)) + (time.Now().Sub(sq.lastSetupTime()).Seconds() / RAND_LIMIT)
 			continue
 		}
 	}
@@ -49,7 +49,13 @@ func NewRPCPool(dispatchStrategy string, connAttempts, reconnects int, connectTi
 			if rpcConnCfg.Transport != "" {
 				codec = rpcConnCfg.Transport[1:] // Transport contains always * before codec understood by rpcclient
 			}
-			rpcClient, err = rpcclient.NewRpcClient("tcp", rpcConnCfg.Address, connAttempts, reconnects, connectTimeout, replyTimeout, codec, nil)
+			if rpcConnCfg.TLS {
+				if rpcClient, err = newTLSRPCClient(rpcConnCfg, codec, connAttempts, reconnects, connectTimeout, replyTimeout); rpcClient == nil {
+					return nil, err
+				}
+			} else {
+				rpcClient, err = rpcclient.NewRpcClient("tcp", rpcConnCfg.Address, connAttempts, reconnects, connectTimeout, replyTimeout, codec, nil)
+			}
 		} else {
 			return nil, fmt.Errorf("Unsupported transport: <%s>", rpcConnCfg.Transport)
 		}
@@ -63,3 +69,30 @@ func NewRPCPool(dispatchStrategy string, connAttempts, reconnects int, connectTi
 	}
 	return rpcPool, err
 }
+
+// newTLSRPCClient connects over TLS with the certificates of the connection, defaulting to the ones out of tls config section.
+// rpcclient dials only plain TCP so the TLS connection is passed to it as internal one.
+// Returns nil client on TLS configuration errors, connection errors are returned together with the client.
+func newTLSRPCClient(rpcConnCfg *config.HaPoolConfig, codec string, connAttempts, reconnects int, connectTimeout, replyTimeout time.Duration) (*rpcclient.RpcClient, error) {
+	cfg := config.CgrConfig()
+	caFile, certFile, keyFile := cfg.TLSCACertificate, cfg.TLSClientCertificate, cfg.TLSClientKey
+	if rpcConnCfg.CACertificate != "" {
+		caFile = rpcConnCfg.CACertificate
+	}
+	if rpcConnCfg.ClientCertificate != "" {
+		certFile, keyFile = rpcConnCfg.ClientCertificate, rpcConnCfg.ClientKey
+	}
+	tlsCfg, err := utils.NewTLSClientConfig(certFile, keyFile, caFile)
+	if err != nil {
+		return nil, err
+	}
+	tlsClient, err := utils.NewTLSRpcClient(rpcConnCfg.Address, codec, tlsCfg, connAttempts, reconnects, connectTimeout, replyTimeout)
+	if tlsClient == nil {
+		return nil, err
+	}
+	rpcClient, errInternal := rpcclient.NewRpcClient("", "", connAttempts, reconnects, connectTimeout, replyTimeout, rpcclient.INTERNAL_RPC, tlsClient)
+	if err == nil {
+		err = errInternal
+	}
+	return rpcClient, err
+}
@@ -111,6 +111,8 @@ func LoadTariffPlanFromFolder(tpPath, timezone string, ratingDb RatingStorage, a
 		path.Join(tpPath, utils.USERS_CSV),
 		path.Join(tpPath, utils.ALIASES_CSV),
 		path.Join(tpPath, utils.ResourceLimitsCsv),
+		path.Join(tpPath, utils.ExchangeRatesCsv),
+		path.Join(tpPath, utils.TaxRulesCsv),
 	), "", timezone)
 	if err := loader.LoadAll(); err != nil {
 		return utils.NewErrServerError(err)
@@ -273,6 +273,19 @@ ResGroup1,*string_prefix,Destination,10;20,2014-07-29T15:00:00Z,10,,
 ResGroup1,*cdr_stats,,CDRST1:*min_ASR:34;CDRST_1001:*min_ASR:20,,,,
 ResGroup1,*rsr_fields,,Subject(~^1.*1$);Destination(1002),,,,
 ResGroup2,*destinations,Destination,DST_FS,2014-07-29T15:00:00Z,10,2,
+`
+
+	exchangeRates = `
+#Tag,FromCurrency,ToCurrency,Rate
+EXR_DEFAULT,EUR,USD,1.1
+EXR_DEFAULT,GBP,EUR,1.15
+`
+
+	taxRules = `
+#Tag,Tenant,DestinationIds,TaxName,Percent,PerMinute,Inclusive,Weight
+TAX_DEFAULT,*any,GERMANY,VAT,19,0,false,20
+TAX_DEFAULT,cgrates.org,*any,VAT,20,0,false,10
+TAX_DEFAULT,cgrates.org,GERMANY,EXCISE,0,0.01,true,10
 `
 )
 
@@ -280,7 +293,7 @@ var csvr *TpReader
 
 func init() {
 	csvr = NewTpReader(ratingStorage, accountingStorage, NewStringCSVStorage(',', destinations, timings, rates, destinationRates, ratingPlans, ratingProfiles,
-		sharedGroups, lcrs, actions, actionPlans, actionTriggers, accountActions, derivedCharges, cdrStats, users, aliases, resLimits), testTPID, "")
+		sharedGroups, lcrs, actions, actionPlans, actionTriggers, accountActions, derivedCharges, cdrStats, users, aliases, resLimits, exchangeRates, taxRules), testTPID, "")
 	if err := csvr.LoadDestinations(); err != nil {
 		log.Print("error in LoadDestinations:", err)
 	}
@@ -332,6 +345,12 @@ func init() {
 	if err := csvr.LoadResourceLimits(); err != nil {
 		log.Print("error in LoadResourceLimits:", err)
 	}
+	if err := csvr.LoadExchangeRates(); err != nil {
+		log.Print("error in LoadExchangeRates:", err)
+	}
+	if err := csvr.LoadTaxRules(); err != nil {
+		log.Print("error in LoadTaxRules:", err)
+	}
 	csvr.WriteToDatabase(false, false)
 	ratingStorage.CacheRatingAll("LoaderCSVTests")
 	accountingStorage.CacheAccountingAll("LoaderCSVTests")
@@ -1345,3 +1364,41 @@ func TestLoadResourceLimits(t *testing.T) {
 	}
 
 }
+
+func TestLoadExchangeRates(t *testing.T) {
+	eExrs := map[string]*ExchangeRate{
+		"EUR:USD": &ExchangeRate{FromCurrency: "EUR", ToCurrency: "USD", Rate: 1.1},
+		"GBP:EUR": &ExchangeRate{FromCurrency: "GBP", ToCurrency: "EUR", Rate: 1.15},
+	}
+	if !reflect.DeepEqual(eExrs, csvr.exchangeRates) {
+		t.Errorf("Expecting: %+v, received: %+v", eExrs, csvr.exchangeRates)
+	}
+	if rate, err := GetExchangeRate("EUR", "USD"); err != nil || rate != 1.1 {
+		t.Error("Unexpected rate: ", rate, err)
+	}
+	if rate, err := GetExchangeRate("USD", "EUR"); err != nil || rate != 1/1.1 {
+		t.Error("Unexpected inverted rate: ", rate, err)
+	}
+	if rate, err := GetExchangeRate("", "USD"); err != nil || rate != 1 {
+		t.Error("Unexpected rate without currency: ", rate, err)
+	}
+	if _, err := GetExchangeRate("USD", "GBP"); err != utils.ErrExchangeRateNotFound {
+		t.Error("Unexpected error: ", err)
+	}
+}
+
+func TestLoadTaxRules(t *testing.T) {
+	eTxrs := map[string]*TaxRules{
+		"TAX_DEFAULT": &TaxRules{
+			ID: "TAX_DEFAULT",
+			Rules: []*TaxRule{
+				&TaxRule{Tenant: utils.ANY, DestinationIDs: utils.NewStringMap("GERMANY"), TaxName: "VAT", Percent: 19, Weight: 20},
+				&TaxRule{Tenant: "cgrates.org", DestinationIDs: utils.NewStringMap(utils.ANY), TaxName: "VAT", Percent: 20, Weight: 10},
+				&TaxRule{Tenant: "cgrates.org", DestinationIDs: utils.NewStringMap("GERMANY"), TaxName: "EXCISE", PerMinute: 0.01, Inclusive: true, Weight: 10},
+			},
+		},
+	}
+	if !reflect.DeepEqual(eTxrs, csvr.taxRules) {
+		t.Errorf("Expecting: %s, received: %s", utils.ToJSON(eTxrs), utils.ToJSON(csvr.taxRules))
+	}
+}
@@ -93,11 +93,13 @@ func APItoModelDestinationRate(drs *utils.TPDestinationRate) (result []TpDestina
 func APItoModelRatingPlan(rps *utils.TPRatingPlan) (result []TpRatingPlan) {
 	for _, rp := range rps.RatingPlanBindings {
 		result = append(result, TpRatingPlan{
-			Tpid:         rps.TPid,
-			Tag:          rps.RatingPlanId,
-			DestratesTag: rp.DestinationRatesId,
-			TimingTag:    rp.TimingId,
-			Weight:       rp.Weight,
+			Tpid:          rps.TPid,
+			Tag:           rps.RatingPlanId,
+			DestratesTag:  rp.DestinationRatesId,
+			TimingTag:     rp.TimingId,
+			Weight:        rp.Weight,
+			VolumeCounter: rp.VolumeCounter,
+			Currency:      rp.Currency,
 		})
 	}
 	if len(rps.RatingPlanBindings) == 0 {
@@ -184,6 +186,7 @@ func APItoModelAction(as *utils.TPActions) (result []TpAction) {
 			BalanceDisabled: a.BalanceDisabled,
 			ExtraParameters: a.ExtraParameters,
 			Weight:          a.Weight,
+			BalanceCurrency: a.BalanceCurrency,
 		})
 	}
 	if len(as.Actions) == 0 {
@@ -282,6 +285,48 @@ func APItoModelSharedGroup(sgs *utils.TPSharedGroups) (result []TpSharedGroup) {
 	return
 }
 
+func APItoModelExchangeRate(exrs *utils.TPExchangeRates) (result []TpExchangeRate) {
+	for _, exr := range exrs.ExchangeRates {
+		result = append(result, TpExchangeRate{
+			Tpid:         exrs.TPid,
+			Tag:          exrs.ExchangeRatesId,
+			FromCurrency: exr.FromCurrency,
+			ToCurrency:   exr.ToCurrency,
+			Rate:         exr.Rate,
+		})
+	}
+	if len(exrs.ExchangeRates) == 0 {
+		result = append(result, TpExchangeRate{
+			Tpid: exrs.TPid,
+			Tag:  exrs.ExchangeRatesId,
+		})
+	}
+	return
+}
+
+func APItoModelTaxRule(txrs *utils.TPTaxRules) (result []TpTaxRule) {
+	for _, txr := range txrs.TaxRules {
+		result = append(result, TpTaxRule{
+			Tpid:           txrs.TPid,
+			Tag:            txrs.TaxRulesId,
+			Tenant:         txr.Tenant,
+			DestinationIds: txr.DestinationIds,
+			TaxName:        txr.TaxName,
+			Percent:        txr.Percent,
+			PerMinute:      txr.PerMinute,
+			Inclusive:      txr.Inclusive,
+			Weight:         txr.Weight,
+		})
+	}
+	if len(txrs.TaxRules) == 0 {
+		result = append(result, TpTaxRule{
+			Tpid: txrs.TPid,
+			Tag:  txrs.TaxRulesId,
+		})
+	}
+	return
+}
+
 func APItoModelDerivedCharger(dcs *utils.TPDerivedChargers) (result []TpDerivedCharger) {
 	for _, dc := range dcs.DerivedChargers {
 		result = append(result, TpDerivedCharger{
@@ -355,6 +400,7 @@ func APItoModelCdrStat(stats *utils.TPCdrStats) (result []TpCdrstat) {
 			RatedSubjects:    st.RatedSubjects,
 			CostInterval:     st.CostInterval,
 			ActionTriggers:   st.ActionTriggers,
+			BucketInterval:   st.BucketInterval,
 		})
 	}
 	if len(stats.CdrStats) == 0 {
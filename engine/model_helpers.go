@@ -15,6 +15,9 @@ import (
 func csvLoad(s interface{}, values []string) (interface{}, error) {
 	fieldValueMap := make(map[string]string)
 	st := reflect.TypeOf(s)
+	if len(values) > getColumnCount(s) {
+		return nil, fmt.Errorf("invalid %v number of fields: %d", st.Name(), len(values))
+	}
 	numFields := st.NumField()
 	for i := 0; i < numFields; i++ {
 		field := st.Field(i)
@@ -22,6 +25,9 @@ func csvLoad(s interface{}, values []string) (interface{}, error) {
 		index := field.Tag.Get("index")
 		if index != "" {
 			idx, err := strconv.Atoi(index)
+			if err == nil && len(values) <= idx && field.Tag.Get("optional") == "true" {
+				continue // trailing column missing from older files, keeps the default value
+			}
 			if err != nil || len(values) <= idx {
 				return nil, fmt.Errorf("invalid %v.%v index %v", st.Name(), field.Name, index)
 			}
@@ -165,6 +171,17 @@ func getColumnCount(s interface{}) int {
 	return count
 }
 
+// Returns the number of fields expected on each csv line, -1 for models with optional trailing columns
+func getFieldsPerRecord(s interface{}) int {
+	st := reflect.TypeOf(s)
+	for i := 0; i < st.NumField(); i++ {
+		if st.Field(i).Tag.Get("optional") == "true" {
+			return -1
+		}
+	}
+	return getColumnCount(s)
+}
+
 type TpDestinations []TpDestination
 
 func (tps TpDestinations) GetDestinations() (map[string]*Destination, error) {
@@ -292,6 +309,8 @@ func (tps TpRatingPlans) GetRatingPlans() (map[string][]*utils.TPRatingPlanBindi
 			DestinationRatesId: tpRp.DestratesTag,
 			TimingId:           tpRp.TimingTag,
 			Weight:             tpRp.Weight,
+			VolumeCounter:      tpRp.VolumeCounter,
+			Currency:           tpRp.Currency,
 		}
 		if _, exists := rpbns[tpRp.Tag]; exists {
 			rpbns[tpRp.Tag] = append(rpbns[tpRp.Tag], rpb)
@@ -319,6 +338,8 @@ func GetRateInterval(rpl *utils.TPRatingPlanBinding, dr *utils.DestinationRate)
 			RoundingDecimals: dr.RoundingDecimals,
 			MaxCost:          dr.MaxCost,
 			MaxCostStrategy:  dr.MaxCostStrategy,
+			VolumeCounter:    rpl.VolumeCounter,
+			Currency:         rpl.Currency,
 			tag:              dr.Rate.RateId,
 		},
 	}
@@ -378,6 +399,38 @@ func (tps TpSharedGroups) GetSharedGroups() (map[string][]*utils.TPSharedGroup,
 	return sgs, nil
 }
 
+type TpExchangeRates []TpExchangeRate
+
+func (tps TpExchangeRates) GetExchangeRates() (map[string][]*utils.TPExchangeRate, error) {
+	exrs := make(map[string][]*utils.TPExchangeRate)
+	for _, tpExr := range tps {
+		exrs[tpExr.Tag] = append(exrs[tpExr.Tag], &utils.TPExchangeRate{
+			FromCurrency: tpExr.FromCurrency,
+			ToCurrency:   tpExr.ToCurrency,
+			Rate:         tpExr.Rate,
+		})
+	}
+	return exrs, nil
+}
+
+type TpTaxRules []TpTaxRule
+
+func (tps TpTaxRules) GetTaxRules() (map[string][]*utils.TPTaxRule, error) {
+	txrs := make(map[string][]*utils.TPTaxRule)
+	for _, tpTxr := range tps {
+		txrs[tpTxr.Tag] = append(txrs[tpTxr.Tag], &utils.TPTaxRule{
+			Tenant:         tpTxr.Tenant,
+			DestinationIds: tpTxr.DestinationIds,
+			TaxName:        tpTxr.TaxName,
+			Percent:        tpTxr.Percent,
+			PerMinute:      tpTxr.PerMinute,
+			Inclusive:      tpTxr.Inclusive,
+			Weight:         tpTxr.Weight,
+		})
+	}
+	return txrs, nil
+}
+
 type TpActions []TpAction
 
 func (tps TpActions) GetActions() (map[string][]*utils.TPAction, error) {
@@ -401,6 +454,7 @@ func (tps TpActions) GetActions() (map[string][]*utils.TPAction, error) {
 			BalanceDisabled: tpAc.BalanceDisabled,
 			ExtraParameters: tpAc.ExtraParameters,
 			Weight:          tpAc.Weight,
+			BalanceCurrency: tpAc.BalanceCurrency,
 		}
 		as[tpAc.Tag] = append(as[tpAc.Tag], a)
 	}
@@ -538,12 +592,13 @@ func (tps TpCdrStats) GetCdrStats() (map[string][]*utils.TPCdrStat, error) {
 			RatedSubjects:    tpCs.RatedSubjects,
 			CostInterval:     tpCs.CostInterval,
 			ActionTriggers:   tpCs.ActionTriggers,
+			BucketInterval:   tpCs.BucketInterval,
 		})
 	}
 	return css, nil
 }
 
-func UpdateCdrStats(cs *CdrStats, triggers ActionTriggers, tpCs *utils.TPCdrStat, timezone string) {
+func UpdateCdrStats(cs *CdrStats, triggers ActionTriggers, tpCs *utils.TPCdrStat, timezone string) error {
 	if tpCs.QueueLength != "" && tpCs.QueueLength != "0" {
 		if qi, err := strconv.Atoi(tpCs.QueueLength); err == nil {
 			cs.QueueLength = qi
@@ -558,6 +613,16 @@ func UpdateCdrStats(cs *CdrStats, triggers ActionTriggers, tpCs *utils.TPCdrStat
 			log.Printf("Error parsing TimeWindow %v for cdrs stats %v", tpCs.TimeWindow, cs.Id)
 		}
 	}
+	if tpCs.BucketInterval != "" {
+		d, err := time.ParseDuration(tpCs.BucketInterval)
+		if err != nil || d <= 0 {
+			return fmt.Errorf("invalid BucketInterval %v for cdrs stats %v", tpCs.BucketInterval, cs.Id)
+		}
+		cs.BucketInterval = d
+	}
+	if cs.BucketInterval != 0 && cs.QueueLength != 0 { // Buckets aggregate CDRs so they cannot be counted out by number
+		return fmt.Errorf("QueueLength not supported together with BucketInterval for cdrs stats %v", cs.Id)
+	}
 	if tpCs.SaveInterval != "" {
 		if si, err := time.ParseDuration(tpCs.SaveInterval); err == nil {
 			cs.SaveInterval = si
@@ -716,6 +781,7 @@ func UpdateCdrStats(cs *CdrStats, triggers ActionTriggers, tpCs *utils.TPCdrStat
 	if triggers != nil {
 		cs.Triggers = append(cs.Triggers, triggers...)
 	}
+	return nil
 }
 
 // ValueOrDefault is used to populate empty values with *any or *default if value missing
@@ -23,6 +23,43 @@ func TestModelHelperCsvLoadInt(t *testing.T) {
 	}
 }
 
+func TestModelHelperCsvLoadOptional(t *testing.T) {
+	if fpr := getFieldsPerRecord(TpRatingPlan{}); fpr != -1 {
+		t.Errorf("Unexpected fields per record: %d", fpr)
+	}
+	l, err := csvLoad(TpRatingPlan{}, []string{"RP_RETAIL", "DR_RETAIL", "*any", "10"})
+	tpd, ok := l.(TpRatingPlan)
+	if err != nil || !ok || tpd.Weight != 10 || tpd.VolumeCounter != "" || tpd.Currency != "" {
+		t.Errorf("model load failed: %+v, error: %v", tpd, err)
+	}
+	l, err = csvLoad(TpRatingPlan{}, []string{"RP_RETAIL", "DR_RETAIL", "*any", "10", "VOL_TIER"})
+	if tpd, ok = l.(TpRatingPlan); err != nil || !ok || tpd.VolumeCounter != "VOL_TIER" || tpd.Currency != "" {
+		t.Errorf("model load failed: %+v, error: %v", tpd, err)
+	}
+	if fpr := getFieldsPerRecord(TpAction{}); fpr != -1 {
+		t.Errorf("Unexpected fields per record: %d", fpr)
+	}
+	l, err = csvLoad(TpAction{}, []string{"TOPUP10", "*topup", "", "", "", "*monetary", "*out", "", "*any", "", "", "*unlimited", "", "10", "10", "false", "false", "10"})
+	if tpa, ok := l.(TpAction); err != nil || !ok || tpa.Units != "10" || tpa.BalanceCurrency != "" {
+		t.Errorf("model load failed: %+v, error: %v", tpa, err)
+	}
+	if fpr := getFieldsPerRecord(TpCdrstat{}); fpr != -1 {
+		t.Errorf("Unexpected fields per record: %d", fpr)
+	}
+	l, err = csvLoad(TpCdrstat{}, []string{"CDRST_BUCKETS", "", "1h", "", "ASR", "", "", "", "", "", "", "", "", "", "", "", "", "", "", "", "", "", "", "", "", "1m"})
+	if tpcs, ok := l.(TpCdrstat); err != nil || !ok || tpcs.TimeWindow != "1h" || tpcs.BucketInterval != "1m" {
+		t.Errorf("model load failed: %+v, error: %v", tpcs, err)
+	} else if css, _ := TpCdrStats([]TpCdrstat{tpcs}).GetCdrStats(); len(css["CDRST_BUCKETS"]) != 1 || css["CDRST_BUCKETS"][0].BucketInterval != "1m" {
+		t.Errorf("Unexpected cdr stats: %s", utils.ToJSON(css))
+	}
+	if _, err := csvLoad(TpRatingPlan{}, []string{"RP_RETAIL", "DR_RETAIL", "*any"}); err == nil {
+		t.Error("Expecting error for missing mandatory column")
+	}
+	if _, err := csvLoad(TpRatingPlan{}, []string{"RP_RETAIL", "DR_RETAIL", "*any", "10", "", "EUR", "extra"}); err == nil {
+		t.Error("Expecting error for extra column")
+	}
+}
+
 func TestModelHelperCsvDump(t *testing.T) {
 	tpd := TpDestination{
 		Tag:    "TEST_DEST",
@@ -171,11 +208,13 @@ func TestTPRatingPlanAsExportSlice(t *testing.T) {
 			&utils.TPRatingPlanBinding{
 				DestinationRatesId: "TEST_DSTRATE2",
 				TimingId:           "TEST_TIMING2",
-				Weight:             20.0},
+				Weight:             20.0,
+				VolumeCounter:      "TEST_COUNTER",
+				Currency:           "EUR"},
 		}}
 	expectedSlc := [][]string{
-		[]string{"TEST_RPLAN", "TEST_DSTRATE1", "TEST_TIMING1", "10"},
-		[]string{"TEST_RPLAN", "TEST_DSTRATE2", "TEST_TIMING2", "20"},
+		[]string{"TEST_RPLAN", "TEST_DSTRATE1", "TEST_TIMING1", "10", "", ""},
+		[]string{"TEST_RPLAN", "TEST_DSTRATE2", "TEST_TIMING2", "20", "TEST_COUNTER", "EUR"},
 	}
 
 	ms := APItoModelRatingPlan(tpRpln)
@@ -248,7 +287,8 @@ func TestTPActionsAsExportSlice(t *testing.T) {
 				SharedGroups:    "GROUP1",
 				BalanceWeight:   "10.0",
 				ExtraParameters: "",
-				Weight:          10.0},
+				Weight:          10.0,
+				BalanceCurrency: "EUR"},
 			&utils.TPAction{
 				Identifier:      "*http_post",
 				BalanceType:     "",
@@ -265,8 +305,8 @@ func TestTPActionsAsExportSlice(t *testing.T) {
 		},
 	}
 	expectedSlc := [][]string{
-		[]string{"TEST_ACTIONS", "*topup_reset", "", "", "", "*monetary", utils.OUT, "call", "*any", "special1", "GROUP1", "*never", "", "5.0", "10.0", "", "", "10"},
-		[]string{"TEST_ACTIONS", "*http_post", "http://localhost/&param1=value1", "", "", "", "", "", "", "", "", "", "", "0.0", "0.0", "", "", "20"},
+		[]string{"TEST_ACTIONS", "*topup_reset", "", "", "", "*monetary", utils.OUT, "call", "*any", "special1", "GROUP1", "*never", "", "5.0", "10.0", "", "", "10", "EUR"},
+		[]string{"TEST_ACTIONS", "*http_post", "http://localhost/&param1=value1", "", "", "", "", "", "", "", "", "", "", "0.0", "0.0", "", "", "20", ""},
 	}
 
 	ms := APItoModelAction(tpActs)
@@ -319,6 +359,77 @@ func TestTPSharedGroupsAsExportSlice(t *testing.T) {
 	}
 }
 
+func TestTPExchangeRatesAsExportSlice(t *testing.T) {
+	tpExrs := &utils.TPExchangeRates{
+		TPid:            "TEST_TPID",
+		ExchangeRatesId: "EXR_DEFAULT",
+		ExchangeRates: []*utils.TPExchangeRate{
+			&utils.TPExchangeRate{
+				FromCurrency: "EUR",
+				ToCurrency:   "USD",
+				Rate:         1.1},
+			&utils.TPExchangeRate{
+				FromCurrency: "GBP",
+				ToCurrency:   "EUR",
+				Rate:         1.15},
+		},
+	}
+	expectedSlc := [][]string{
+		[]string{"EXR_DEFAULT", "EUR", "USD", "1.1"},
+		[]string{"EXR_DEFAULT", "GBP", "EUR", "1.15"},
+	}
+	ms := APItoModelExchangeRate(tpExrs)
+	var slc [][]string
+	for _, m := range ms {
+		lc, err := csvDump(m)
+		if err != nil {
+			t.Error("Error dumping to csv: ", err)
+		}
+		slc = append(slc, lc)
+	}
+	if !reflect.DeepEqual(expectedSlc, slc) {
+		t.Errorf("Expecting: %+v, received: %+v", expectedSlc, slc)
+	}
+}
+
+func TestTPTaxRulesAsExportSlice(t *testing.T) {
+	tpTxrs := &utils.TPTaxRules{
+		TPid:       "TEST_TPID",
+		TaxRulesId: "TAX_DEFAULT",
+		TaxRules: []*utils.TPTaxRule{
+			&utils.TPTaxRule{
+				Tenant:         "*any",
+				DestinationIds: "GERMANY",
+				TaxName:        "VAT",
+				Percent:        19,
+				Weight:         20},
+			&utils.TPTaxRule{
+				Tenant:         "cgrates.org",
+				DestinationIds: "GERMANY",
+				TaxName:        "EXCISE",
+				PerMinute:      0.01,
+				Inclusive:      true,
+				Weight:         10},
+		},
+	}
+	expectedSlc := [][]string{
+		[]string{"TAX_DEFAULT", "*any", "GERMANY", "VAT", "19", "0", "false", "20"},
+		[]string{"TAX_DEFAULT", "cgrates.org", "GERMANY", "EXCISE", "0", "0.01", "true", "10"},
+	}
+	ms := APItoModelTaxRule(tpTxrs)
+	var slc [][]string
+	for _, m := range ms {
+		lc, err := csvDump(m)
+		if err != nil {
+			t.Error("Error dumping to csv: ", err)
+		}
+		slc = append(slc, lc)
+	}
+	if !reflect.DeepEqual(expectedSlc, slc) {
+		t.Errorf("Expecting: %+v, received: %+v", expectedSlc, slc)
+	}
+}
+
 //*in,cgrates.org,*any,EU_LANDLINE,LCR_STANDARD,*static,ivo;dan;rif,2012-01-01T00:00:00Z,10
 func TestTPLcrRulesAsExportSlice(t *testing.T) {
 	lcr := &utils.TPLcrRules{
@@ -417,14 +528,15 @@ func TestTPCdrStatsAsExportSlice(t *testing.T) {
 				RatedAccounts:    "dan",
 				RatedSubjects:    "dan",
 				CostInterval:     "0;2",
-				ActionTriggers:   "STANDARD_TRIGGERS"},
+				ActionTriggers:   "STANDARD_TRIGGERS",
+				BucketInterval:   "1m"},
 		},
 	}
 	expectedSlc := [][]string{
 		[]string{"CDRST1", "5", "60m", "10s", "ASR;ACD", "2014-07-29T15:00:00Z;2014-07-29T16:00:00Z", "*voice", "87.139.12.167", "FS_JSON", utils.META_RATED, "*out", "cgrates.org", "call",
-			"dan", "dan", "49", "3m;7m", "5m;10m", "supplier1", "NORMAL_CLEARNING", "default", "rif", "rif", "0;2", "STANDARD_TRIGGERS"},
+			"dan", "dan", "49", "3m;7m", "5m;10m", "supplier1", "NORMAL_CLEARNING", "default", "rif", "rif", "0;2", "STANDARD_TRIGGERS", ""},
 		[]string{"CDRST1", "5", "60m", "9s", "ASR", "2014-07-29T15:00:00Z;2014-07-29T16:00:00Z", "*voice", "87.139.12.167", "FS_JSON", utils.META_RATED, "*out", "cgrates.org", "call",
-			"dan", "dan", "49", "3m;7m", "5m;10m", "supplier1", "NORMAL_CLEARNING", "default", "dan", "dan", "0;2", "STANDARD_TRIGGERS"},
+			"dan", "dan", "49", "3m;7m", "5m;10m", "supplier1", "NORMAL_CLEARNING", "default", "dan", "dan", "0;2", "STANDARD_TRIGGERS", "1m"},
 	}
 	ms := APItoModelCdrStat(cdrStats)
 	var slc [][]string
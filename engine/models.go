@@ -75,13 +75,15 @@ type TpDestinationRate struct {
 }
 
 type TpRatingPlan struct {
-	Id           int64
-	Tpid         string
-	Tag          string  `index:"0" re:"\w+\s*,\s*"`
-	DestratesTag string  `index:"1" re:"\w+\s*,\s*|\*any"`
-	TimingTag    string  `index:"2" re:"\w+\s*,\s*|\*any"`
-	Weight       float64 `index:"3" re:"\d+.?\d*"`
-	CreatedAt    time.Time
+	Id            int64
+	Tpid          string
+	Tag           string  `index:"0" re:"\w+\s*,\s*"`
+	DestratesTag  string  `index:"1" re:"\w+\s*,\s*|\*any"`
+	TimingTag     string  `index:"2" re:"\w+\s*,\s*|\*any"`
+	Weight        float64 `index:"3" re:"\d+.?\d*"`
+	VolumeCounter string  `index:"4" re:"\w*" optional:"true"`
+	Currency      string  `index:"5" re:"\w*" optional:"true"`
+	CreatedAt     time.Time
 }
 
 type TpRatingProfile struct {
@@ -171,6 +173,7 @@ type TpAction struct {
 	BalanceBlocker  string  `index:"15" re:""`
 	BalanceDisabled string  `index:"16" re:""`
 	Weight          float64 `index:"17" re:"\d+\.?\d*\s*"`
+	BalanceCurrency string  `index:"18" re:"\w*" optional:"true"`
 	CreatedAt       time.Time
 }
 
@@ -251,6 +254,30 @@ type TpSharedGroup struct {
 	CreatedAt     time.Time
 }
 
+type TpExchangeRate struct {
+	Id           int64
+	Tpid         string
+	Tag          string  `index:"0" re:"\w+\s*"`
+	FromCurrency string  `index:"1" re:"\w+\s*"`
+	ToCurrency   string  `index:"2" re:"\w+\s*"`
+	Rate         float64 `index:"3" re:"\d+\.?\d*"`
+	CreatedAt    time.Time
+}
+
+type TpTaxRule struct {
+	Id             int64
+	Tpid           string
+	Tag            string  `index:"0" re:"\w+\s*"`
+	Tenant         string  `index:"1" re:"\*any|[0-9A-Za-z_\.]+\s*"`
+	DestinationIds string  `index:"2" re:"[0-9A-Za-z_;]*|\*any"`
+	TaxName        string  `index:"3" re:"\w+\s*"`
+	Percent        float64 `index:"4" re:"\d*\.?\d*"`
+	PerMinute      float64 `index:"5" re:"\d*\.?\d*"`
+	Inclusive      bool    `index:"6" re:""`
+	Weight         float64 `index:"7" re:"\d*\.?\d*"`
+	CreatedAt      time.Time
+}
+
 type TpDerivedCharger struct {
 	Id                   int64
 	Tpid                 string
@@ -327,6 +354,7 @@ type TpCdrstat struct {
 	RatedSubjects    string `index:"22" re:""`
 	CostInterval     string `index:"23" re:""`
 	ActionTriggers   string `index:"24" re:""`
+	BucketInterval   string `index:"25" re:"" optional:"true"`
 	CreatedAt        time.Time
 }
 
@@ -450,6 +478,40 @@ func (t TBLSMCosts) TableName() string {
 	return utils.TBLSMCosts
 }
 
+type TBLHistoryRecords struct {
+	ID         int64
+	ObjectType string
+	ObjectID   string
+	Version    int64
+	Author     string
+	ChangeTime time.Time
+	Deleted    bool
+	Payload    string
+	Diff       string
+}
+
+func (t TBLHistoryRecords) TableName() string {
+	return utils.TBLHistoryRecords
+}
+
+type TBLBalanceLedger struct {
+	ID          int64
+	Account     string
+	BalanceUuid string
+	BalanceID   string
+	BalanceType string
+	Delta       float64
+	Value       float64
+	Cgrid       string
+	ActionsID   string
+	Source      string
+	CreatedAt   time.Time
+}
+
+func (t TBLBalanceLedger) TableName() string {
+	return utils.TBLBalanceLedger
+}
+
 type TpResourceLimit struct {
 	ID               int64
 	Tpid             string
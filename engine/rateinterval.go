@@ -210,6 +210,8 @@ type RIRate struct {
 	MaxCost          float64
 	MaxCostStrategy  string
 	Rates            RateGroups // GroupRateInterval (start time): Rate
+	VolumeCounter    string     // ID of the account counter whose usage is added to GroupIntervalStart, empty for per call groups
+	Currency         string     // currency of the costs, empty for the one of the balances
 	tag              string     // loading validation only
 }
 
@@ -218,6 +220,12 @@ func (rir *RIRate) Stringify() string {
 	for _, r := range rir.Rates {
 		str += r.Stringify()
 	}
+	if rir.VolumeCounter != "" {
+		str += rir.VolumeCounter
+	}
+	if rir.Currency != "" {
+		str += rir.Currency
+	}
 	return utils.Sha1(str)[:8]
 }
 
@@ -0,0 +1,212 @@
+/*
+Real-time Charging System for Telecom & ISP environments
+Copyright (C) ITsysCOM GmbH
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>
+*/
+
+package engine
+
+import (
+	"time"
+
+	"github.com/cgrates/cgrates/utils"
+)
+
+// ratingDataGetter is the source of data queried when rating a CallDescriptor
+type ratingDataGetter interface {
+	GetRatingPlan(key string, skipCache bool) (*RatingPlan, error)
+	GetRatingProfile(key string, skipCache bool) (*RatingProfile, error)
+	GetDestinationIDs(prefix string) (map[string]struct{}, error)
+}
+
+// storageRatingData queries the rating data loaded in the engine's RatingStorage and cache
+type storageRatingData struct{}
+
+func (storageRatingData) GetRatingPlan(key string, skipCache bool) (*RatingPlan, error) {
+	return ratingStorage.GetRatingPlan(key, skipCache)
+}
+
+func (storageRatingData) GetRatingProfile(key string, skipCache bool) (*RatingProfile, error) {
+	return ratingStorage.GetRatingProfile(key, skipCache)
+}
+
+func (storageRatingData) GetDestinationIDs(prefix string) (map[string]struct{}, error) {
+	x, err := CacheGet(utils.DESTINATION_PREFIX + prefix)
+	if err != nil {
+		return nil, err
+	}
+	return x.(map[string]struct{}), nil
+}
+
+// NewRatingSimulator loads the rating data of the tariff plan with tpid out of StorDB.
+// The data is only kept in memory, the live RatingStorage and cache are not touched.
+func NewRatingSimulator(lr LoadReader, tpid, timezone string) (*RatingSimulator, error) {
+	dataDB, _ := NewMapStorage() // isolates the loader from the live DataDB
+	tpr := NewTpReader(dataDB, dataDB, lr, tpid, timezone)
+	for _, loadFunc := range []func() error{tpr.LoadDestinations, tpr.LoadTimings, tpr.LoadRates,
+		tpr.LoadDestinationRates, tpr.LoadRatingPlans, tpr.LoadRatingProfiles} {
+		if err := loadFunc(); err != nil {
+			return nil, err
+		}
+	}
+	if len(tpr.ratingProfiles) == 0 {
+		return nil, utils.ErrNotFound
+	}
+	rs := &RatingSimulator{tpid: tpid, ratingPlans: tpr.ratingPlans, ratingProfiles: tpr.ratingProfiles,
+		destPrefixes: make(map[string]map[string]struct{}), accounts: make(map[string]*Account)}
+	for dstID, dst := range tpr.destinations {
+		for _, prfx := range dst.Prefixes {
+			if _, hasIt := rs.destPrefixes[prfx]; !hasIt {
+				rs.destPrefixes[prfx] = make(map[string]struct{})
+			}
+			rs.destPrefixes[prfx][dstID] = struct{}{}
+		}
+	}
+	return rs, nil
+}
+
+// RatingSimulator rates CallDescriptors and CDRs against a tariff plan which is not loaded in DataDB
+type RatingSimulator struct {
+	tpid           string
+	ratingPlans    map[string]*RatingPlan
+	ratingProfiles map[string]*RatingProfile
+	destPrefixes   map[string]map[string]struct{} // prefix: destination ids
+	accounts       map[string]*Account            // copies of the rated accounts, read once so the volumes stay the same during simulation
+}
+
+func (rs *RatingSimulator) GetRatingPlan(key string, skipCache bool) (*RatingPlan, error) {
+	if rp, hasIt := rs.ratingPlans[key]; hasIt {
+		return rp, nil
+	}
+	return nil, utils.ErrNotFound
+}
+
+func (rs *RatingSimulator) GetRatingProfile(key string, skipCache bool) (*RatingProfile, error) {
+	if rpf, hasIt := rs.ratingProfiles[key]; hasIt {
+		return rpf, nil
+	}
+	return nil, utils.ErrNotFound
+}
+
+func (rs *RatingSimulator) GetDestinationIDs(prefix string) (map[string]struct{}, error) {
+	if dstIDs, hasIt := rs.destPrefixes[prefix]; hasIt {
+		return dstIDs, nil
+	}
+	return nil, utils.ErrNotFound
+}
+
+// GetCost calculates the cost of the CallDescriptor out of simulated tariff plan.
+// User profiles and aliases are applied out of the live services, as in Responder.GetCost.
+func (rs *RatingSimulator) GetCost(cd *CallDescriptor) (*CallCost, error) {
+	if cd.Subject == "" {
+		cd.Subject = cd.Account
+	}
+	if err := LoadUserProfile(cd, utils.EXTRA_FIELDS); err != nil {
+		return nil, err
+	}
+	if err := LoadAlias(
+		&AttrMatchingAlias{
+			Destination: cd.Destination,
+			Direction:   cd.Direction,
+			Tenant:      cd.Tenant,
+			Category:    cd.Category,
+			Account:     cd.Account,
+			Subject:     cd.Subject,
+			Context:     utils.ALIAS_CONTEXT_RATING,
+		}, cd, utils.EXTRA_FIELDS); err != nil && err != utils.ErrNotFound {
+		return nil, err
+	}
+	cd.ratingData = rs
+	cd.volumes = rs.getVolumes(cd)
+	return cd.GetCost()
+}
+
+// getVolumes returns the usage consulted by volume tiered ratings out of the copy of the account, the live one is not read again
+func (rs *RatingSimulator) getVolumes(cd *CallDescriptor) map[string]time.Duration {
+	acntKey := cd.GetAccountKey()
+	acnt, cached := rs.accounts[acntKey]
+	if !cached {
+		acnt, _ = accountingStorage.GetAccount(acntKey) // decoded out of storage, not shared with the live operations
+		rs.accounts[acntKey] = acnt
+	}
+	if acnt == nil {
+		return make(map[string]time.Duration)
+	}
+	return acnt.getVolumes(cd)
+}
+
+// SimulateCDRs re-rates the CDRs and compares their stored costs with the simulated ones.
+// CDRs which would not be rated by CDRS (*raw or *none request type) are ignored.
+func (rs *RatingSimulator) SimulateCDRs(cdrs []*CDR) *RatingSimulation {
+	sim := &RatingSimulation{TPid: rs.tpid, CDRs: make([]*SimulatedCDRCost, 0, len(cdrs))}
+	for _, cdr := range cdrs {
+		if cdr.RunID == utils.MetaRaw || cdr.RequestType == utils.META_NONE {
+			continue
+		}
+		simCost := &SimulatedCDRCost{CGRID: cdr.CGRID, RunID: cdr.RunID, Cost: cdr.Cost, SimulatedCost: -1}
+		if cc, err := rs.GetCost(cdr.AsCallDescriptor()); err != nil {
+			simCost.Error = err.Error()
+		} else {
+			simCost.SimulatedCost = cc.Cost
+		}
+		sim.addCDRCost(simCost)
+	}
+	return sim
+}
+
+// SimulatedCDRCost compares the stored cost of a CDR with the one out of simulated tariff plan
+type SimulatedCDRCost struct {
+	CGRID          string
+	RunID          string
+	Cost           float64 // stored cost, -1 if the CDR was not rated
+	SimulatedCost  float64 // cost out of simulated tariff plan, -1 on errors
+	CostDifference float64 // SimulatedCost - Cost, populated only when both costs are available
+	Error          string  // error received when rating with simulated tariff plan
+}
+
+// RatingSimulation is the result of re-rating CDRs against a tariff plan in StorDB
+type RatingSimulation struct {
+	TPid               string
+	CDRs               []*SimulatedCDRCost
+	ComparedCDRs       int     // number of CDRs having both stored and simulated costs
+	FailedCDRs         int     // number of CDRs which could not be rated with simulated tariff plan
+	TotalCost          float64 // sum of stored costs for compared CDRs
+	TotalSimulatedCost float64 // sum of simulated costs for compared CDRs
+	TotalDifference    float64 // TotalSimulatedCost - TotalCost
+}
+
+func (sim *RatingSimulation) addCDRCost(simCost *SimulatedCDRCost) {
+	sim.CDRs = append(sim.CDRs, simCost)
+	if simCost.Error != "" {
+		sim.FailedCDRs += 1
+		return
+	}
+	if simCost.Cost == -1 {
+		return
+	}
+	simCost.CostDifference = roundCostDifference(simCost.SimulatedCost - simCost.Cost)
+	sim.ComparedCDRs += 1
+	sim.TotalCost = utils.Round(sim.TotalCost+simCost.Cost, globalRoundingDecimals, utils.ROUNDING_MIDDLE)
+	sim.TotalSimulatedCost = utils.Round(sim.TotalSimulatedCost+simCost.SimulatedCost, globalRoundingDecimals, utils.ROUNDING_MIDDLE)
+	sim.TotalDifference = roundCostDifference(sim.TotalSimulatedCost - sim.TotalCost)
+}
+
+// utils.Round expects positive values, differences can be negative
+func roundCostDifference(diff float64) float64 {
+	if diff < 0 {
+		return -utils.Round(-diff, globalRoundingDecimals, utils.ROUNDING_MIDDLE)
+	}
+	return utils.Round(diff, globalRoundingDecimals, utils.ROUNDING_MIDDLE)
+}
@@ -0,0 +1,113 @@
+/*
+Real-time Charging System for Telecom & ISP environments
+Copyright (C) ITsysCOM GmbH
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>
+*/
+package engine
+
+import (
+	"testing"
+	"time"
+
+	"github.com/cgrates/cgrates/utils"
+)
+
+func TestRatingSimulatorSimulateCDRs(t *testing.T) {
+	csvStorage := NewStringCSVStorage(',',
+		`DST_SIM_1002,1002`, ``, `RT_SIM_1CNT,0,0.01,60s,60s,0s`,
+		`DR_SIM_1002,DST_SIM_1002,RT_SIM_1CNT,*up,4,0,`, `RP_SIM,DR_SIM_1002,*any,10`,
+		`*out,simulator.org,call,*any,2012-01-01T00:00:00Z,RP_SIM,,`,
+		``, ``, ``, ``, ``, ``, ``, ``, ``, ``, ``, ``, ``)
+	rs, err := NewRatingSimulator(csvStorage, "TP_SIM", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Simulated data should not reach the live cache
+	if _, err := CacheGet(utils.DESTINATION_PREFIX + "1002"); err == nil {
+		t.Error("Destination prefix should not be cached")
+	}
+	if _, err := CacheGet(utils.RATING_PROFILE_PREFIX + "*out:simulator.org:call:*any"); err == nil {
+		t.Error("Rating profile should not be cached")
+	}
+	tStart := time.Date(2016, 10, 1, 10, 0, 0, 0, time.UTC)
+	cdrs := []*CDR{
+		&CDR{CGRID: "cdr1", RunID: utils.META_DEFAULT, ToR: utils.VOICE, RequestType: utils.META_POSTPAID, Direction: utils.OUT,
+			Tenant: "simulator.org", Category: "call", Account: "1001", Destination: "1002",
+			SetupTime: tStart, AnswerTime: tStart, Usage: time.Duration(90) * time.Second, Cost: 0.05},
+		&CDR{CGRID: "cdr2", RunID: utils.META_DEFAULT, ToR: utils.VOICE, RequestType: utils.META_POSTPAID, Direction: utils.OUT,
+			Tenant: "simulator.org", Category: "call", Account: "1001", Destination: "9999",
+			SetupTime: tStart, AnswerTime: tStart, Usage: time.Duration(60) * time.Second, Cost: 0.1},
+		&CDR{CGRID: "cdr3", RunID: utils.MetaRaw, ToR: utils.VOICE, RequestType: utils.META_POSTPAID, Direction: utils.OUT,
+			Tenant: "simulator.org", Category: "call", Account: "1001", Destination: "1002",
+			SetupTime: tStart, AnswerTime: tStart, Usage: time.Duration(60) * time.Second, Cost: -1},
+		&CDR{CGRID: "cdr4", RunID: utils.META_DEFAULT, ToR: utils.VOICE, RequestType: utils.META_RATED, Direction: utils.OUT,
+			Tenant: "simulator.org", Category: "call", Account: "1001", Destination: "1002",
+			SetupTime: tStart, AnswerTime: tStart, Usage: time.Duration(60) * time.Second, Cost: -1},
+	}
+	sim := rs.SimulateCDRs(cdrs)
+	if sim.TPid != "TP_SIM" || len(sim.CDRs) != 3 {
+		t.Fatalf("Unexpected simulation: %s", utils.ToJSON(sim))
+	}
+	if sim.CDRs[0].CGRID != "cdr1" || sim.CDRs[0].SimulatedCost != 0.02 || sim.CDRs[0].CostDifference != -0.03 {
+		t.Errorf("Unexpected CDR cost: %+v", sim.CDRs[0])
+	}
+	if sim.CDRs[1].CGRID != "cdr2" || sim.CDRs[1].SimulatedCost != -1 || sim.CDRs[1].Error == "" {
+		t.Errorf("Unexpected CDR cost: %+v", sim.CDRs[1])
+	}
+	if sim.CDRs[2].CGRID != "cdr4" || sim.CDRs[2].SimulatedCost != 0.01 || sim.CDRs[2].CostDifference != 0 {
+		t.Errorf("Unexpected CDR cost: %+v", sim.CDRs[2])
+	}
+	if sim.ComparedCDRs != 1 || sim.FailedCDRs != 1 ||
+		sim.TotalCost != 0.05 || sim.TotalSimulatedCost != 0.02 || sim.TotalDifference != -0.03 {
+		t.Errorf("Unexpected simulation totals: %s", utils.ToJSON(sim))
+	}
+}
+
+func TestRatingSimulatorVolumes(t *testing.T) {
+	csvStorage := NewStringCSVStorage(',',
+		`DST_SIMVOL_1002,1002`, ``, `RT_SIMVOL,0,0.02,60s,60s,0s
+RT_SIMVOL,0,0.01,60s,60s,60s`,
+		`DR_SIMVOL_1002,DST_SIMVOL_1002,RT_SIMVOL,*up,4,0,`, `RP_SIMVOL,DR_SIMVOL_1002,*any,10,SIMVOL_COUNTER,`,
+		`*out,simvol.org,call,*any,2012-01-01T00:00:00Z,RP_SIMVOL,,`,
+		``, ``, ``, ``, ``, ``, ``, ``, ``, ``, ``, ``, ``)
+	rs, err := NewRatingSimulator(csvStorage, "TP_SIMVOL", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	acnt := &Account{ID: "simvol.org:1001",
+		UnitCounters: UnitCounters{utils.VOICE: []*UnitCounter{
+			&UnitCounter{CounterType: utils.COUNTER_EVENT, Counters: CounterFilters{
+				&CounterFilter{Value: 60, Filter: &BalanceFilter{ID: utils.StringPointer("SIMVOL_COUNTER"), Type: utils.StringPointer(utils.VOICE)}}}}}}}
+	if err := accountingStorage.SetAccount(acnt); err != nil {
+		t.Fatal(err)
+	}
+	tStart := time.Date(2016, 10, 1, 10, 0, 0, 0, time.UTC)
+	cdrs := []*CDR{
+		&CDR{CGRID: "cdr1", RunID: utils.META_DEFAULT, ToR: utils.VOICE, RequestType: utils.META_POSTPAID, Direction: utils.OUT,
+			Tenant: "simvol.org", Category: "call", Account: "1001", Destination: "1002",
+			SetupTime: tStart, AnswerTime: tStart, Usage: time.Duration(60) * time.Second, Cost: 0.01},
+	}
+	if sim := rs.SimulateCDRs(cdrs); sim.CDRs[0].SimulatedCost != 0.01 { // first minute used already
+		t.Errorf("Unexpected CDR cost: %+v", sim.CDRs[0])
+	}
+	// Live counters changing during simulation do not reach it
+	acnt.UnitCounters[utils.VOICE][0].Counters[0].Value = 0
+	if err := accountingStorage.SetAccount(acnt); err != nil {
+		t.Fatal(err)
+	}
+	if sim := rs.SimulateCDRs(cdrs); sim.CDRs[0].SimulatedCost != 0.01 {
+		t.Errorf("Unexpected CDR cost: %+v", sim.CDRs[0])
+	}
+}
@@ -154,8 +154,9 @@ func (ris RatingInfos) String() string {
 
 func (rpf *RatingProfile) GetRatingPlansForPrefix(cd *CallDescriptor) (err error) {
 	var ris RatingInfos
+	ratingData := cd.getRatingData()
 	for index, rpa := range rpf.RatingPlanActivations.GetActiveForCall(cd) {
-		rpl, err := ratingStorage.GetRatingPlan(rpa.RatingPlanId, false)
+		rpl, err := ratingData.GetRatingPlan(rpa.RatingPlanId, false)
 		if err != nil || rpl == nil {
 			utils.Logger.Err(fmt.Sprintf("Error checking destination: %v", err))
 			continue
@@ -173,8 +174,7 @@ func (rpf *RatingProfile) GetRatingPlansForPrefix(cd *CallDescriptor) (err error
 			}
 		} else {
 			for _, p := range utils.SplitPrefix(cd.Destination, MIN_PREFIX_MATCH) {
-				if x, err := CacheGet(utils.DESTINATION_PREFIX + p); err == nil {
-					destIds := x.(map[string]struct{})
+				if destIds, err := ratingData.GetDestinationIDs(p); err == nil {
 					var bestWeight float64
 					for dID := range destIds {
 						if _, ok := rpl.DestinationRates[dID]; ok {
@@ -258,10 +258,14 @@ type TenantRatingSubject struct {
 }
 
 func RatingProfileSubjectPrefixMatching(key string) (rp *RatingProfile, err error) {
+	return ratingProfileSubjectPrefixMatching(storageRatingData{}, key)
+}
+
+func ratingProfileSubjectPrefixMatching(ratingData ratingDataGetter, key string) (rp *RatingProfile, err error) {
 	if !rpSubjectPrefixMatching || strings.HasSuffix(key, utils.ANY) {
-		return ratingStorage.GetRatingProfile(key, false)
+		return ratingData.GetRatingProfile(key, false)
 	}
-	if rp, err = ratingStorage.GetRatingProfile(key, false); err == nil {
+	if rp, err = ratingData.GetRatingProfile(key, false); err == nil {
 		return rp, err
 	}
 	lastIndex := strings.LastIndex(key, utils.CONCATENATED_KEY_SEP)
@@ -269,7 +273,7 @@ func RatingProfileSubjectPrefixMatching(key string) (rp *RatingProfile, err erro
 	subject := key[lastIndex:]
 	lenSubject := len(subject)
 	for i := 1; i < lenSubject-1; i++ {
-		if rp, err = ratingStorage.GetRatingProfile(baseKey+subject[:lenSubject-i], false); err == nil {
+		if rp, err = ratingData.GetRatingProfile(baseKey+subject[:lenSubject-i], false); err == nil {
 			return rp, err
 		}
 	}
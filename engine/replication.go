@@ -0,0 +1,116 @@
+/*
+Rating system designed to be used in VoIP Carriers World
+Copyright (C) 2012-2015 ITsysCOM
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>
+*/
+
+package engine
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sync"
+
+	"github.com/cgrates/cgrates/utils"
+)
+
+// ReplicationTransport delivers the replicated content to one remote destination
+type ReplicationTransport interface {
+	// Post delivers content synchronously, key groups related content (eg: CGRID) so the transports able to partition can keep it in order
+	Post(content []byte, key string) error
+}
+
+// Builds a ReplicationTransport out of it's configured address
+type ReplicationTransportFunc func(address string, skipTlsVerify bool) (ReplicationTransport, error)
+
+var (
+	replicationTransports = map[string]ReplicationTransportFunc{
+		utils.META_HTTP_POST: func(address string, skipTlsVerify bool) (ReplicationTransport, error) {
+			return NewHTTPTransport(address, skipTlsVerify, utils.CONTENT_FORM), nil
+		},
+		utils.META_HTTP_JSON: func(address string, skipTlsVerify bool) (ReplicationTransport, error) {
+			return NewHTTPTransport(address, skipTlsVerify, utils.CONTENT_JSON), nil
+		},
+		utils.MetaAMQP: func(address string, skipTlsVerify bool) (ReplicationTransport, error) {
+			return NewAMQPTransport(address)
+		},
+		utils.MetaKafka: func(address string, skipTlsVerify bool) (ReplicationTransport, error) {
+			return NewKafkaTransport(address)
+		},
+	}
+	replicationTransportsMux sync.RWMutex
+)
+
+// RegisterReplicationTransport makes a new transport type available to cdr_replication, replacing the one with the same name
+func RegisterReplicationTransport(transport string, f ReplicationTransportFunc) {
+	replicationTransportsMux.Lock()
+	replicationTransports[transport] = f
+	replicationTransportsMux.Unlock()
+}
+
+func newReplicationTransport(transport, address string, skipTlsVerify bool) (ReplicationTransport, error) {
+	replicationTransportsMux.RLock()
+	f, hasIt := replicationTransports[transport]
+	replicationTransportsMux.RUnlock()
+	if !hasIt {
+		return nil, fmt.Errorf("Unsupported replication transport: %s", transport)
+	}
+	return f(address, skipTlsVerify)
+}
+
+// Content replicated for one CDR, form encoded for *http_post, JSON for the others
+func replicationContent(cdr *CDR, transport string) ([]byte, error) {
+	if transport == utils.META_HTTP_POST {
+		return []byte(cdr.AsHttpForm().Encode()), nil
+	}
+	return json.Marshal(cdr)
+}
+
+func NewHTTPTransport(address string, skipTlsVerify bool, contentType string) *HTTPTransport {
+	bodyType := "application/x-www-form-urlencoded"
+	if contentType == utils.CONTENT_JSON {
+		bodyType = "application/json"
+	}
+	return &HTTPTransport{address: address, bodyType: bodyType,
+		client: &http.Client{Transport: &http.Transport{
+			TLSClientConfig:   &tls.Config{InsecureSkipVerify: skipTlsVerify},
+			DisableKeepAlives: true}}}
+}
+
+// HTTPTransport posts the content to an http(s) url
+type HTTPTransport struct {
+	address  string
+	bodyType string
+	client   *http.Client
+}
+
+func (ht *HTTPTransport) Post(content []byte, key string) error {
+	resp, err := ht.client.Post(ht.address, ht.bodyType, bytes.NewBuffer(content))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if _, err := ioutil.ReadAll(resp.Body); err != nil {
+		return err
+	}
+	if resp.StatusCode > 299 {
+		return fmt.Errorf("Unexpected status code received: %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,87 @@
+/*
+Rating system designed to be used in VoIP Carriers World
+Copyright (C) 2012-2015 ITsysCOM
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>
+*/
+
+package engine
+
+import (
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/Shopify/sarama"
+	"github.com/cgrates/cgrates/utils"
+)
+
+const (
+	KafkaTopicParam   = "topic"
+	KafkaDefaultTopic = "cgrates_cdrs"
+	KafkaClientID     = "cgrates"
+)
+
+// NewKafkaTransport builds a transport out of broker addresses and topic, eg: 127.0.0.1:9092,127.0.0.2:9092?topic=cgrates_cdrs
+// Brokers are contacted on first post
+func NewKafkaTransport(address string) (*KafkaTransport, error) {
+	addrSplt := strings.SplitN(address, "?", 2)
+	topic := KafkaDefaultTopic
+	if len(addrSplt) == 2 {
+		qry, err := url.ParseQuery(addrSplt[1])
+		if err != nil {
+			return nil, err
+		}
+		if tpc := qry.Get(KafkaTopicParam); len(tpc) != 0 {
+			topic = tpc
+		}
+	}
+	cfg := sarama.NewConfig()
+	cfg.ClientID = KafkaClientID
+	cfg.Version = sarama.V1_0_0_0
+	cfg.Producer.RequiredAcks = sarama.WaitForAll
+	cfg.Producer.Return.Successes = true
+	cfg.Producer.Retry.Max = 0 // Retries are handled by the replication spool
+	cfg.Producer.Partitioner = sarama.NewHashPartitioner
+	cfg.Net.MaxOpenRequests = 1 // Keep order within partition
+	return &KafkaTransport{brokers: strings.Split(addrSplt[0], utils.FIELDS_SEP), topic: topic, cfg: cfg}, nil
+}
+
+// KafkaTransport produces messages keyed on CGRID so the ones for the same CDR land in the same partition
+type KafkaTransport struct {
+	brokers  []string
+	topic    string
+	cfg      *sarama.Config
+	producer sarama.SyncProducer
+	sync.Mutex
+}
+
+func (kt *KafkaTransport) Post(content []byte, key string) (err error) {
+	kt.Lock()
+	defer kt.Unlock()
+	if kt.producer == nil {
+		if kt.producer, err = sarama.NewSyncProducer(kt.brokers, kt.cfg); err != nil {
+			return
+		}
+	}
+	if _, _, err = kt.producer.SendMessage(&sarama.ProducerMessage{
+		Topic: kt.topic,
+		Key:   sarama.StringEncoder(key),
+		Value: sarama.ByteEncoder(content),
+	}); err != nil {
+		kt.producer.Close() // Reconnect on next post
+		kt.producer = nil
+	}
+	return
+}
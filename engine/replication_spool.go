@@ -0,0 +1,359 @@
+/*
+Rating system designed to be used in VoIP Carriers World
+Copyright (C) 2012-2015 ITsysCOM
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>
+*/
+
+package engine
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cgrates/cgrates/utils"
+)
+
+const (
+	spoolFilePrefix = "rpl_"
+	spoolFileSuffix = ".json"
+	spoolTmpSuffix  = ".tmp"
+	// Files written by the http replication before the spool existed: cdr_<transport>_<escaped address>_<uuid>.form
+	fallbackFilePrefix = "cdr_"
+	fallbackFileSuffix = ".form"
+)
+
+// SpooledReplication is one replication which could not be delivered, persisted as JSON file inside the spool
+type SpooledReplication struct {
+	Transport string
+	Address   string
+	Key       string // Ordering key, CGRID in case of CDRs
+	Content   []byte
+	SpooledAt time.Time
+}
+
+// Replications with the same transport, address and key are delivered in the order they were posted
+func (sr *SpooledReplication) orderKey() string {
+	return utils.ConcatenatedKey(sr.Transport, sr.Address, sr.Key)
+}
+
+// Locks the ordering key across posting and replaying
+func (sr *SpooledReplication) guardKey() string {
+	return "RPL_" + sr.orderKey()
+}
+
+// NewReplicationSpool opens the spool inside spoolDir, creating the directory if missing and indexing the replications left there
+func NewReplicationSpool(spoolDir string, skipTlsVerify bool) (*ReplicationSpool, error) {
+	if err := os.MkdirAll(spoolDir, 0755); err != nil {
+		return nil, err
+	}
+	spool := &ReplicationSpool{dir: spoolDir, skipTlsVerify: skipTlsVerify,
+		transports: make(map[string]ReplicationTransport), pending: make(map[string]int),
+		seq: time.Now().UnixNano()}
+	fNames, err := spool.fileNames()
+	if err != nil {
+		return nil, err
+	}
+	for _, fName := range fNames {
+		sr, err := spool.read(fName)
+		if err != nil {
+			utils.Logger.Warning(fmt.Sprintf("<ReplicationSpool> Cannot read spooled replication %s, error: %s", fName, err.Error()))
+			continue
+		}
+		spool.pending[sr.orderKey()] += 1
+		if seq, err := strconv.ParseInt(strings.TrimSuffix(strings.TrimPrefix(fName, spoolFilePrefix), spoolFileSuffix), 10, 64); err == nil && seq > spool.seq {
+			spool.seq = seq
+		}
+	}
+	return spool, nil
+}
+
+// ReplicationSpool delivers replications over their transports, keeping the failed ones on disk until replayed
+type ReplicationSpool struct {
+	dir           string
+	skipTlsVerify bool
+	transports    map[string]ReplicationTransport // Transports in use, indexed on transport and address
+	pending       map[string]int                  // Number of spooled replications per ordering key
+	seq           int64                           // Last sequence used in file names
+	mux           sync.Mutex                      // Protects transports, pending and seq
+	replayMux     sync.Mutex                      // One replay at a time
+}
+
+// Post delivers the content, trying it for the number of attempts before spooling it.
+// Content is spooled directly if older replications with the same key are still waiting in the spool.
+// Returns utils.ErrReplicationSpooled once the content was written to the spool instead of being delivered.
+func (spool *ReplicationSpool) Post(transport, address, key string, content []byte, attempts int) error {
+	sr := &SpooledReplication{Transport: transport, Address: address, Key: key, Content: content}
+	_, err := Guardian.Guard(func() (interface{}, error) {
+		spool.mux.Lock()
+		isPending := spool.pending[sr.orderKey()] != 0
+		spool.mux.Unlock()
+		if !isPending {
+			delay := utils.Fib()
+			for i := 0; i < attempts; i++ {
+				err := spool.post(sr)
+				if err == nil {
+					return nil, nil
+				}
+				utils.Logger.Warning(fmt.Sprintf("<ReplicationSpool> Posting to <%s> over <%s>, error: <%s>", address, transport, err.Error()))
+				if i < attempts-1 {
+					time.Sleep(delay())
+				}
+			}
+		}
+		if err := spool.write(sr); err != nil {
+			return nil, err
+		}
+		return nil, utils.ErrReplicationSpooled
+	}, 0, sr.guardKey())
+	return err
+}
+
+// Replay retries the spooled replications in the order they were spooled, empty transport or address matching all.
+// Once a replication fails, the newer ones with the same key are kept in the spool so they do not overtake it.
+func (spool *ReplicationSpool) Replay(transport, address string) (replayed, failed int, err error) {
+	spool.replayMux.Lock()
+	defer spool.replayMux.Unlock()
+	fNames, err := spool.fileNames()
+	if err != nil {
+		return
+	}
+	blocked := make(map[string]bool)
+	for _, fName := range fNames {
+		sr, errRead := spool.read(fName)
+		if errRead != nil {
+			if !os.IsNotExist(errRead) {
+				utils.Logger.Warning(fmt.Sprintf("<ReplicationSpool> Cannot read spooled replication %s, error: %s", fName, errRead.Error()))
+			}
+			continue
+		}
+		if (len(transport) != 0 && sr.Transport != transport) ||
+			(len(address) != 0 && sr.Address != address) {
+			continue
+		}
+		if blocked[sr.orderKey()] {
+			failed += 1
+			continue
+		}
+		fileName := fName
+		if _, errPost := Guardian.Guard(func() (interface{}, error) {
+			if err := spool.post(sr); err != nil {
+				return nil, err
+			}
+			return nil, spool.remove(fileName, sr)
+		}, 0, sr.guardKey()); errPost != nil {
+			utils.Logger.Warning(fmt.Sprintf("<ReplicationSpool> Replaying to <%s> over <%s>, error: <%s>", sr.Address, sr.Transport, errPost.Error()))
+			blocked[sr.orderKey()] = true
+			failed += 1
+			continue
+		}
+		replayed += 1
+	}
+	return
+}
+
+// Count returns the number of replications waiting in the spool
+func (spool *ReplicationSpool) Count() (cnt int) {
+	spool.mux.Lock()
+	defer spool.mux.Unlock()
+	for _, keyCnt := range spool.pending {
+		cnt += keyCnt
+	}
+	return
+}
+
+// Replays the spool at fixed intervals, meant to run in it's own goroutine
+func (spool *ReplicationSpool) replayLoop(interval time.Duration) {
+	for {
+		time.Sleep(interval)
+		if replayed, failed, err := spool.Replay("", ""); err != nil {
+			utils.Logger.Err(fmt.Sprintf("<ReplicationSpool> Replay error: %s", err.Error()))
+		} else if replayed != 0 || failed != 0 {
+			utils.Logger.Info(fmt.Sprintf("<ReplicationSpool> Replayed %d replications, %d left in spool", replayed, failed))
+		}
+	}
+}
+
+// Delivers the replication over it's transport, building the transport on first use
+func (spool *ReplicationSpool) post(sr *SpooledReplication) error {
+	trnsprtKey := utils.ConcatenatedKey(sr.Transport, sr.Address)
+	spool.mux.Lock()
+	trnsprt, hasIt := spool.transports[trnsprtKey]
+	if !hasIt {
+		var err error
+		if trnsprt, err = newReplicationTransport(sr.Transport, sr.Address, spool.skipTlsVerify); err != nil {
+			spool.mux.Unlock()
+			return err
+		}
+		spool.transports[trnsprtKey] = trnsprt
+	}
+	spool.mux.Unlock()
+	return trnsprt.Post(sr.Content, sr.Key)
+}
+
+// Persists the replication, written to a temporary file first so the spool never contains partial content
+func (spool *ReplicationSpool) write(sr *SpooledReplication) error {
+	sr.SpooledAt = time.Now()
+	jsn, err := json.Marshal(sr)
+	if err != nil {
+		return err
+	}
+	spool.mux.Lock()
+	defer spool.mux.Unlock()
+	spool.seq += 1
+	filePath := path.Join(spool.dir, fmt.Sprintf("%s%020d%s", spoolFilePrefix, spool.seq, spoolFileSuffix))
+	if err := ioutil.WriteFile(filePath+spoolTmpSuffix, jsn, 0644); err != nil {
+		return err
+	}
+	if err := os.Rename(filePath+spoolTmpSuffix, filePath); err != nil {
+		return err
+	}
+	spool.pending[sr.orderKey()] += 1
+	return nil
+}
+
+func (spool *ReplicationSpool) read(fName string) (*SpooledReplication, error) {
+	jsn, err := ioutil.ReadFile(path.Join(spool.dir, fName))
+	if err != nil {
+		return nil, err
+	}
+	var sr SpooledReplication
+	if err := json.Unmarshal(jsn, &sr); err != nil {
+		return nil, err
+	}
+	return &sr, nil
+}
+
+func (spool *ReplicationSpool) remove(fName string, sr *SpooledReplication) error {
+	if err := os.Remove(path.Join(spool.dir, fName)); err != nil {
+		return err
+	}
+	spool.mux.Lock()
+	if spool.pending[sr.orderKey()] -= 1; spool.pending[sr.orderKey()] <= 0 {
+		delete(spool.pending, sr.orderKey())
+	}
+	spool.mux.Unlock()
+	return nil
+}
+
+// ImportFallbackFiles moves the replications left by older versions inside fallbackDir into the spool, oldest first
+func (spool *ReplicationSpool) ImportFallbackFiles(fallbackDir string) (imported int, err error) {
+	fInfos, err := ioutil.ReadDir(fallbackDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			err = nil
+		}
+		return
+	}
+	var fallbackFiles []os.FileInfo
+	for _, fInfo := range fInfos {
+		if !fInfo.IsDir() && strings.HasPrefix(fInfo.Name(), fallbackFilePrefix) && strings.HasSuffix(fInfo.Name(), fallbackFileSuffix) {
+			fallbackFiles = append(fallbackFiles, fInfo)
+		}
+	}
+	sort.Sort(fileInfosByModTime(fallbackFiles))
+	for _, fInfo := range fallbackFiles {
+		transport, address, hasIt := parseFallbackFileName(fInfo.Name())
+		if !hasIt {
+			utils.Logger.Warning(fmt.Sprintf("<ReplicationSpool> Cannot import fallback file %s, unknown format", fInfo.Name()))
+			continue
+		}
+		filePath := path.Join(fallbackDir, fInfo.Name())
+		content, err := ioutil.ReadFile(filePath)
+		if err != nil {
+			return imported, err
+		}
+		sr := &SpooledReplication{Transport: transport, Address: address, Key: fallbackReplicationKey(transport, content), Content: content}
+		if err := spool.write(sr); err != nil {
+			return imported, err
+		}
+		if err := os.Remove(filePath); err != nil {
+			return imported, err
+		}
+		imported += 1
+	}
+	return
+}
+
+// Extracts transport and address out of the fallback file name, the uuid at the end is ignored
+func parseFallbackFileName(fName string) (transport, address string, hasIt bool) {
+	fName = strings.TrimSuffix(strings.TrimPrefix(fName, fallbackFilePrefix), fallbackFileSuffix)
+	for _, trnsprt := range []string{utils.META_HTTP_POST, utils.META_HTTP_JSON} {
+		if !strings.HasPrefix(fName, trnsprt+"_") {
+			continue
+		}
+		addrUUID := strings.TrimPrefix(fName, trnsprt+"_")
+		sepIdx := strings.LastIndex(addrUUID, "_")
+		if sepIdx == -1 {
+			return
+		}
+		addr, err := url.QueryUnescape(addrUUID[:sepIdx])
+		if err != nil {
+			return
+		}
+		return trnsprt, addr, true
+	}
+	return
+}
+
+// CGRID out of the fallback content so the imported replications keep their ordering key
+func fallbackReplicationKey(transport string, content []byte) string {
+	if transport == utils.META_HTTP_POST { // Form does not carry the CGRID, build it the way the CDR was built
+		vals, err := url.ParseQuery(string(content))
+		if err != nil {
+			return ""
+		}
+		setupTime, err := utils.ParseTimeDetectLayout(vals.Get(utils.SETUP_TIME), "")
+		if err != nil {
+			return ""
+		}
+		return utils.Sha1(vals.Get(utils.ACCID), setupTime.UTC().String())
+	}
+	var cdr CDR
+	if err := json.Unmarshal(content, &cdr); err != nil {
+		return ""
+	}
+	return cdr.CGRID
+}
+
+type fileInfosByModTime []os.FileInfo
+
+func (fis fileInfosByModTime) Len() int           { return len(fis) }
+func (fis fileInfosByModTime) Swap(i, j int)      { fis[i], fis[j] = fis[j], fis[i] }
+func (fis fileInfosByModTime) Less(i, j int) bool { return fis[i].ModTime().Before(fis[j].ModTime()) }
+
+// Spooled file names in spooling order
+func (spool *ReplicationSpool) fileNames() ([]string, error) {
+	fInfos, err := ioutil.ReadDir(spool.dir)
+	if err != nil {
+		return nil, err
+	}
+	var fNames []string
+	for _, fInfo := range fInfos {
+		if !fInfo.IsDir() && strings.HasPrefix(fInfo.Name(), spoolFilePrefix) && strings.HasSuffix(fInfo.Name(), spoolFileSuffix) {
+			fNames = append(fNames, fInfo.Name())
+		}
+	}
+	sort.Strings(fNames)
+	return fNames, nil
+}
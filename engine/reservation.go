@@ -0,0 +1,208 @@
+/*
+Real-time Charging System for Telecom & ISP environments
+Copyright (C) ITsysCOM GmbH
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>
+*/
+
+package engine
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/cgrates/cgrates/utils"
+)
+
+// Value held out of the balances of an account until it is captured or released
+type BalanceReservation struct {
+	ID          string
+	BalanceType string
+	Value       float64
+	ExpiryTime  time.Time // the reservation is released automatically afterwards
+	CreatedAt   time.Time
+}
+
+func (r *BalanceReservation) IsExpired() bool {
+	return !r.ExpiryTime.IsZero() && !r.ExpiryTime.After(time.Now())
+}
+
+// Returns the value held by the active reservations on the balance type
+func (acc *Account) getReservedValue(balanceType string) (reserved float64) {
+	for _, r := range acc.Reservations {
+		if r.BalanceType == balanceType && !r.IsExpired() {
+			reserved += r.Value
+		}
+	}
+	return utils.Round(reserved, globalRoundingDecimals, utils.ROUNDING_MIDDLE)
+}
+
+// Returns the value of the balance type which can still be reserved, the credit limit included for monetary
+func (acc *Account) getUnreservedValue(balanceType string) float64 {
+	var value float64
+	for _, b := range acc.BalanceMap[balanceType] {
+		if b.IsExpired() || !b.IsActive() {
+			continue
+		}
+		value += b.GetValue()
+	}
+	if balanceType == utils.MONETARY {
+		value += acc.CreditLimit
+	}
+	return roundCredit(value - acc.getReservedValue(balanceType))
+}
+
+// Substracts the value from the balances of the type in their debit order, the part not covered goes negative on the default monetary balance
+func (acc *Account) debitReservedValue(balanceType string, value float64) {
+	balances := acc.BalanceMap[balanceType]
+	balances.Sort()
+	for _, b := range balances {
+		if value <= 0 {
+			return
+		}
+		if b.IsExpired() || !b.IsActive() || b.GetValue() <= 0 {
+			continue
+		}
+		debited := math.Min(b.GetValue(), value)
+		b.SubstractValue(debited)
+		value = utils.Round(value-debited, globalRoundingDecimals, utils.ROUNDING_MIDDLE)
+	}
+	if value > 0 && balanceType == utils.MONETARY {
+		acc.GetDefaultMoneyBalance().SubstractValue(value)
+	}
+}
+
+// Removes the reserved values out of the balances so the dry run debits see only what is spendable, to be used on account clones
+func (acc *Account) holdReservations() {
+	for _, r := range acc.Reservations {
+		if !r.IsExpired() {
+			acc.debitReservedValue(r.BalanceType, r.Value)
+		}
+	}
+}
+
+func (acc *Account) removeExpiredReservations() (removed bool) {
+	for id, r := range acc.Reservations {
+		if r.IsExpired() {
+			delete(acc.Reservations, id)
+			removed = true
+		}
+	}
+	return
+}
+
+// Holds the value of the reservation out of the account balances
+func (acc *Account) reserveBalance(r *BalanceReservation) error {
+	if acc.Disabled {
+		return utils.ErrAccountDisabled
+	}
+	if r.Value <= 0 {
+		return fmt.Errorf("invalid reservation value: %v", r.Value)
+	}
+	if r.IsExpired() {
+		return fmt.Errorf("reservation expired at: %v", r.ExpiryTime)
+	}
+	if _, has := acc.Reservations[r.ID]; has {
+		return utils.ErrExists
+	}
+	if !(r.BalanceType == utils.MONETARY && acc.AllowNegative) &&
+		acc.getUnreservedValue(r.BalanceType) < r.Value {
+		return utils.ErrInsufficientCredit
+	}
+	if acc.Reservations == nil {
+		acc.Reservations = make(map[string]*BalanceReservation)
+	}
+	acc.Reservations[r.ID] = r
+	return nil
+}
+
+// Debits value, which cannot exceed the reserved one, and drops the reservation
+func (acc *Account) captureReservation(reservationID string, value float64) error {
+	r, has := acc.Reservations[reservationID]
+	if !has || r.IsExpired() {
+		return utils.ErrNotFound
+	}
+	if value < 0 {
+		return fmt.Errorf("invalid capture value: %v", value)
+	}
+	if value > r.Value {
+		return utils.ErrReservationExceeded
+	}
+	delete(acc.Reservations, reservationID)
+	acc.debitReservedValue(r.BalanceType, value)
+	acc.ExecuteActionTriggers(nil)
+	return nil
+}
+
+// Runs f on the locked account and saves it afterwards
+func guardReservation(accID string, f func(*Account) error) error {
+	_, err := Guardian.Guard(func() (interface{}, error) {
+		acc, err := accountingStorage.GetAccount(accID)
+		if err != nil {
+			return 0, err
+		}
+		acc.setLedgerOrigin(&ledgerOrigin{Source: LEDGER_RESERVATION})
+		if err := f(acc); err != nil {
+			return 0, err
+		}
+		return 0, saveAccount(acc)
+	}, 0, accID)
+	return err
+}
+
+// Reserves a value on the balances of the account, visible as reduced credit until captured, released or expired
+func ReserveBalance(accID string, r *BalanceReservation) error {
+	return guardReservation(accID, func(acc *Account) error {
+		return acc.reserveBalance(r)
+	})
+}
+
+// Debits the captured value, at most the reserved one, releasing the rest of the reservation
+func CaptureReservation(accID, reservationID string, value float64) error {
+	return guardReservation(accID, func(acc *Account) error {
+		return acc.captureReservation(reservationID, value)
+	})
+}
+
+// Drops the reservation without debiting anything
+func ReleaseReservation(accID, reservationID string) error {
+	return guardReservation(accID, func(acc *Account) error {
+		if _, has := acc.Reservations[reservationID]; !has {
+			return utils.ErrNotFound
+		}
+		delete(acc.Reservations, reservationID)
+		return nil
+	})
+}
+
+// Returns an action timing, to be queued in the scheduler, removing the expired reservations of the account once r expires
+func NewReservationExpiryTiming(accID string, r *BalanceReservation) *ActionTiming {
+	expiry := r.ExpiryTime.Local().Add(time.Second).Truncate(time.Second) // the timing has seconds resolution
+	at := &ActionTiming{
+		Uuid: utils.GenUUID(),
+		Timing: &RateInterval{
+			Timing: &RITiming{
+				Years:     utils.Years{expiry.Year()},
+				Months:    utils.Months{expiry.Month()},
+				MonthDays: utils.MonthDays{expiry.Day()},
+				StartTime: expiry.Format("15:04:05"),
+			},
+		},
+		ActionsID: REMOVE_EXPIRED_RESERVATIONS,
+	}
+	at.SetAccountIDs(utils.StringMap{accID: true})
+	at.SetActions(Actions{&Action{ActionType: REMOVE_EXPIRED_RESERVATIONS}})
+	return at
+}
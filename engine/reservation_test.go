@@ -0,0 +1,105 @@
+/*
+Real-time Charging System for Telecom & ISP environments
+Copyright (C) ITsysCOM GmbH
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>
+*/
+
+package engine
+
+import (
+	"testing"
+	"time"
+
+	"github.com/cgrates/cgrates/utils"
+)
+
+func TestReservationReserveCapture(t *testing.T) {
+	acc := &Account{
+		ID:          "cgrates.org:reservation",
+		CreditLimit: 2,
+		BalanceMap: map[string]Balances{
+			utils.MONETARY: Balances{
+				&Balance{Uuid: "res_b1", Value: 4, Weight: 20},
+				&Balance{Uuid: "res_b2", Value: 6, Weight: 10},
+			},
+		},
+	}
+	if err := acc.reserveBalance(&BalanceReservation{ID: "res1", BalanceType: utils.MONETARY, Value: 8}); err != nil {
+		t.Fatal(err)
+	}
+	if err := acc.reserveBalance(&BalanceReservation{ID: "res1", BalanceType: utils.MONETARY, Value: 1}); err != utils.ErrExists {
+		t.Error("Expecting ErrExists, received: ", err)
+	}
+	if err := acc.reserveBalance(&BalanceReservation{ID: "res2", BalanceType: utils.MONETARY, Value: 5}); err != utils.ErrInsufficientCredit {
+		t.Error("Expecting ErrInsufficientCredit, received: ", err)
+	}
+	if err := acc.reserveBalance(&BalanceReservation{ID: "res3", BalanceType: utils.VOICE, Value: 5}); err != utils.ErrInsufficientCredit {
+		t.Error("Expecting ErrInsufficientCredit, received: ", err)
+	}
+	if unreserved := acc.getUnreservedValue(utils.MONETARY); unreserved != 4 {
+		t.Error("Unexpected unreserved value: ", unreserved)
+	}
+	if err := acc.captureReservation("res1", 9); err != utils.ErrReservationExceeded {
+		t.Error("Expecting ErrReservationExceeded, received: ", err)
+	}
+	if err := acc.captureReservation("res1", 5); err != nil {
+		t.Fatal(err)
+	}
+	if len(acc.Reservations) != 0 {
+		t.Errorf("Reservation not removed: %s", utils.ToJSON(acc.Reservations))
+	}
+	// higher weight balance debited first
+	if acc.BalanceMap[utils.MONETARY][0].GetValue() != 0 || acc.BalanceMap[utils.MONETARY][1].GetValue() != 5 {
+		t.Errorf("Unexpected balances: %s", utils.ToJSON(acc.BalanceMap))
+	}
+	if err := acc.captureReservation("res1", 1); err != utils.ErrNotFound {
+		t.Error("Expecting ErrNotFound, received: ", err)
+	}
+}
+
+func TestReservationHold(t *testing.T) {
+	acc := &Account{
+		ID: "cgrates.org:reservation_hold",
+		BalanceMap: map[string]Balances{
+			utils.MONETARY: Balances{&Balance{Uuid: "hold_b1", Value: 3}},
+		},
+		Reservations: map[string]*BalanceReservation{
+			"hold1": &BalanceReservation{ID: "hold1", BalanceType: utils.MONETARY, Value: 2},
+			"hold2": &BalanceReservation{ID: "hold2", BalanceType: utils.MONETARY, Value: 2},
+			"hold3": &BalanceReservation{ID: "hold3", BalanceType: utils.MONETARY, Value: 1, ExpiryTime: time.Now().Add(-time.Second)},
+		},
+	}
+	clone := acc.Clone()
+	clone.holdReservations()
+	// the part not covered by balances goes negative on default one
+	if clone.BalanceMap[utils.MONETARY][0].GetValue() != 0 || clone.GetDefaultMoneyBalance().GetValue() != -1 {
+		t.Errorf("Unexpected balances: %s", utils.ToJSON(clone.BalanceMap))
+	}
+	if acc.BalanceMap[utils.MONETARY][0].GetValue() != 3 {
+		t.Errorf("Original balances modified: %s", utils.ToJSON(acc.BalanceMap))
+	}
+	acc.CleanExpiredStuff()
+	if _, has := acc.Reservations["hold3"]; has || len(acc.Reservations) != 2 {
+		t.Errorf("Unexpected reservations: %s", utils.ToJSON(acc.Reservations))
+	}
+}
+
+func TestReservationExpiryTiming(t *testing.T) {
+	expiry := time.Now().Add(time.Hour)
+	at := NewReservationExpiryTiming("cgrates.org:reservation", &BalanceReservation{ID: "exp1", ExpiryTime: expiry})
+	if start := at.GetNextStartTime(time.Now()); start.Before(expiry) || start.Sub(expiry) > 2*time.Second {
+		t.Errorf("Unexpected start time: %v, expiry: %v", start, expiry)
+	}
+}
@@ -19,33 +19,302 @@ along with this program.  If not, see <http://www.gnu.org/licenses/>
 package engine
 
 import (
+	"fmt"
 	"reflect"
+	"sort"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/cgrates/cgrates/utils"
+	"github.com/cgrates/rpcclient"
 )
 
+// ResourceUsage represents an usage counted
+type ResourceUsage struct {
+	ID         string    // Unique identifier of this resourceUsage, Eg: FreeSWITCH UUID
+	UsageTime  time.Time // So we can expire it later
+	UsageUnits float64   // Number of units used
+}
+
 // ResourceLimit represents a limit imposed for accessing a resource (eg: new calls)
 type ResourceLimit struct {
-	ID             string           // Identifier of this limit
-	Filters        []*RequestFilter // Filters for the request
-	ActivationTime time.Time        // Time when this limit becomes active
-	Weight         float64          // Weight to sort the ResourceLimits
-	Limit          float64          // Limit value
-	ActionTriggers ActionTriggers   // Thresholds to check after changing Limit
-	Used           utils.Int64Slice // []time.Time.Unix() - keep it in this format so we can expire usage automatically
+	ID             string                    // Identifier of this limit
+	Filters        []*RequestFilter          // Filters for the request
+	ActivationTime time.Time                 // Time when this limit becomes active
+	Weight         float64                   // Weight to sort the ResourceLimits
+	Limit          float64                   // Limit value
+	ActionTriggers ActionTriggers            // Thresholds to check after changing Limit
+	Usage          map[string]*ResourceUsage // Keep a record of usage, bounded with timestamps so we can expire too long records
+}
+
+// removeExpiredUnits removes usage records older than ttl, 0 disables expiry
+func (rl *ResourceLimit) removeExpiredUnits(ttl time.Duration) {
+	if ttl == 0 {
+		return
+	}
+	for ruID, rv := range rl.Usage {
+		if time.Since(rv.UsageTime) > ttl {
+			delete(rl.Usage, ruID)
+		}
+	}
+}
+
+// UsedUnits returns the sum of all units recorded as used
+func (rl *ResourceLimit) UsedUnits() (used float64) {
+	for _, ru := range rl.Usage {
+		used += ru.UsageUnits
+	}
+	return
+}
+
+// RecordUsage records a new usage, error if already present
+func (rl *ResourceLimit) RecordUsage(ru *ResourceUsage) error {
+	if rl.Usage == nil {
+		rl.Usage = make(map[string]*ResourceUsage)
+	}
+	if _, hasID := rl.Usage[ru.ID]; hasID {
+		return utils.ErrExists
+	}
+	rl.Usage[ru.ID] = ru
+	return nil
+}
+
+// RemoveUsage removes the usage with the given ID
+func (rl *ResourceLimit) RemoveUsage(ruID string) error {
+	if _, hasID := rl.Usage[ruID]; !hasID {
+		return utils.ErrNotFound
+	}
+	delete(rl.Usage, ruID)
+	return nil
+}
+
+// passFilters returns true if all the filters of the limit are passing the event
+func (rl *ResourceLimit) passFilters(ev map[string]interface{}, cdrStats rpcclient.RpcClientConnection) (bool, error) {
+	for _, fltr := range rl.Filters {
+		if pass, err := fltr.Pass(ev, "", cdrStats); err != nil {
+			if err == utils.ErrNotFound { // Field not in the event, the filter cannot pass
+				return false, nil
+			}
+			return false, err
+		} else if !pass {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// executeActionTriggers fires the triggers when the current usage crosses their threshold
+// Triggers are re-armed once usage goes back over the threshold so they fire again on the next crossing
+func (rl *ResourceLimit) executeActionTriggers() {
+	usedUnits := rl.UsedUnits()
+	rl.ActionTriggers.Sort()
+	for _, at := range rl.ActionTriggers {
+		if at.IsExpired(time.Now()) || !at.IsActive(time.Now()) {
+			continue
+		}
+		var thresholdReached bool
+		switch at.ThresholdType {
+		case utils.TRIGGER_MAX_RESOURCE_USAGE:
+			thresholdReached = usedUnits >= at.ThresholdValue
+		case utils.TRIGGER_MIN_RESOURCE_USAGE:
+			thresholdReached = usedUnits <= at.ThresholdValue
+		}
+		if !thresholdReached {
+			at.Executed = false // Back over the threshold, re-arm
+			continue
+		}
+		if at.Executed { // Already fired for this crossing
+			continue
+		}
+		if err := at.Execute(nil, nil); err != nil { // Marks the trigger as executed
+			utils.Logger.Err(fmt.Sprintf("<RLs> Error executing action trigger %s for ResourceLimit %s: %s", at.ID, rl.ID, err.Error()))
+		}
+	}
+}
+
+// ResourceLimits is a sortable list of ResourceLimit
+type ResourceLimits []*ResourceLimit
+
+func (rls ResourceLimits) Len() int {
+	return len(rls)
+}
+
+func (rls ResourceLimits) Swap(i, j int) {
+	rls[i], rls[j] = rls[j], rls[i]
+}
+
+// Higher weights first
+func (rls ResourceLimits) Less(i, j int) bool {
+	return rls[i].Weight > rls[j].Weight
+}
+
+func (rls ResourceLimits) Sort() {
+	sort.Sort(rls)
+}
+
+func NewResourceLimiterService(dataDB AccountingStorage, cdrStatS rpcclient.RpcClientConnection, usageTTL time.Duration) *ResourceLimiterService {
+	return &ResourceLimiterService{
+		stringIndexes: make(map[string]map[string]utils.StringMap),
+		unindexedRLs:  make(utils.StringMap),
+		usages:        make(map[string]map[string]*ResourceUsage),
+		dataDB:        dataDB,
+		cdrStatS:      cdrStatS,
+		usageTTL:      usageTTL,
+	}
 }
 
 // ResourcesLimiter is the service handling channel limits
 type ResourceLimiterService struct {
 	sync.RWMutex
 	stringIndexes map[string]map[string]utils.StringMap // map[fieldName]map[fieldValue]utils.StringMap[resourceID]
+	unindexedRLs  utils.StringMap                       // ResourceLimits without *string filters, checked on each event
+	usages        map[string]map[string]*ResourceUsage  // Usage of each ResourceLimit, survives reloading the limits out of dataDB
+	dataDB        AccountingStorage                     // So we can query the ResourceLimits
+	cdrStatS      rpcclient.RpcClientConnection         // Needed for *cdr_stats filters
+	usageTTL      time.Duration                         // Expire usage records older than this
+}
+
+// indexStringFilters indexes the *string filters of the ResourceLimits with rlIDs, all ResourceLimits in dataDB if rlIDs is nil
+// The ResourceLimits are read again out of dataDB, the ones not found anymore are removed from indexes
+func (rls *ResourceLimiterService) indexStringFilters(rlIDs []string) error {
+	reindexAll := rlIDs == nil
+	if reindexAll {
+		keys, err := rls.dataDB.GetKeysForPrefix(utils.ResourceLimitsPrefix, true)
+		if err != nil {
+			return err
+		}
+		rlIDs = make([]string, len(keys))
+		for i, key := range keys {
+			rlIDs[i] = key[len(utils.ResourceLimitsPrefix):]
+		}
+	}
+	newStringIndexes := make(map[string]map[string]utils.StringMap)
+	newUnindexedRLs := make(utils.StringMap)
+	removedRLIDs := make(utils.StringMap)
+	for _, rlID := range rlIDs {
+		rl, err := rls.dataDB.GetResourceLimit(rlID, true) // Also caches the ResourceLimit
+		if err != nil {
+			if err == utils.ErrNotFound && !reindexAll { // Removed, only dropped out of indexes
+				removedRLIDs[rlID] = true
+				continue
+			}
+			return err
+		}
+		var hasStringFilter bool
+		for _, fltr := range rl.Filters {
+			if fltr.Type != MetaString {
+				continue
+			}
+			hasStringFilter = true
+			if _, hasIt := newStringIndexes[fltr.FieldName]; !hasIt {
+				newStringIndexes[fltr.FieldName] = make(map[string]utils.StringMap)
+			}
+			for _, fldVal := range fltr.Values {
+				if _, hasIt := newStringIndexes[fltr.FieldName][fldVal]; !hasIt {
+					newStringIndexes[fltr.FieldName][fldVal] = make(utils.StringMap)
+				}
+				newStringIndexes[fltr.FieldName][fldVal][rl.ID] = true
+			}
+		}
+		if !hasStringFilter {
+			newUnindexedRLs[rl.ID] = true
+		}
+	}
+	rls.Lock()
+	defer rls.Unlock()
+	if reindexAll {
+		rls.stringIndexes = newStringIndexes
+		rls.unindexedRLs = newUnindexedRLs
+		storedRLIDs := utils.NewStringMap(rlIDs...)
+		for rlID := range rls.usages {
+			if !storedRLIDs[rlID] {
+				delete(rls.usages, rlID)
+			}
+		}
+		return nil
+	}
+	for rlID := range removedRLIDs {
+		delete(rls.usages, rlID)
+	}
+	for _, rlID := range rlIDs { // Filters could have changed, index them from scratch
+		for _, fldValIdx := range rls.stringIndexes {
+			for _, idxRLIDs := range fldValIdx {
+				delete(idxRLIDs, rlID)
+			}
+		}
+		delete(rls.unindexedRLs, rlID)
+	}
+	for fldName, fldValIdx := range newStringIndexes {
+		if _, hasIt := rls.stringIndexes[fldName]; !hasIt {
+			rls.stringIndexes[fldName] = make(map[string]utils.StringMap)
+		}
+		for fldVal, rlIDs := range fldValIdx {
+			if _, hasIt := rls.stringIndexes[fldName][fldVal]; !hasIt {
+				rls.stringIndexes[fldName][fldVal] = make(utils.StringMap)
+			}
+			rls.stringIndexes[fldName][fldVal].Copy(rlIDs)
+		}
+	}
+	rls.unindexedRLs.Copy(newUnindexedRLs)
+	return nil
+}
+
+// matchingResourceLimitsForEvent returns the active ResourceLimits matching the event, sorted by weight
+// Needs to be called with the lock taken
+func (rls *ResourceLimiterService) matchingResourceLimitsForEvent(ev map[string]interface{}) (ResourceLimits, error) {
+	rlIDs := rls.unindexedRLs.Clone()
+	for fldName, fieldValIf := range ev {
+		fldValIdx, hasIt := rls.stringIndexes[fldName]
+		if !hasIt {
+			continue
+		}
+		fldVal, canCast := utils.CastFieldIfToString(fieldValIf)
+		if !canCast {
+			return nil, fmt.Errorf("Cannot cast field: %s into string", fldName)
+		}
+		if _, hasIt := fldValIdx[fldVal]; !hasIt {
+			continue
+		}
+		rlIDs.Copy(fldValIdx[fldVal])
+	}
+	matchingRLs := make(ResourceLimits, 0)
+	for rlID := range rlIDs {
+		rl, err := rls.dataDB.GetResourceLimit(rlID, false)
+		if err != nil {
+			if err == utils.ErrNotFound { // Removed in the meantime
+				delete(rls.usages, rlID)
+				continue
+			}
+			return nil, err
+		}
+		if _, hasIt := rls.usages[rlID]; !hasIt {
+			if rl.Usage == nil {
+				rl.Usage = make(map[string]*ResourceUsage)
+			}
+			rls.usages[rlID] = rl.Usage
+		}
+		rl.Usage = rls.usages[rlID] // The ResourceLimit could be a new one after cache reload
+		if rl.ActivationTime.After(time.Now()) {
+			continue
+		}
+		if pass, err := rl.passFilters(ev, rls.cdrStatS); err != nil {
+			return nil, utils.NewErrServerError(err)
+		} else if !pass {
+			continue
+		}
+		rl.removeExpiredUnits(rls.usageTTL)
+		matchingRLs = append(matchingRLs, rl)
+	}
+	matchingRLs.Sort()
+	return matchingRLs, nil
 }
 
 // Called to start the service
 func (rls *ResourceLimiterService) Start() error {
+	if err := rls.indexStringFilters(nil); err != nil {
+		return err
+	}
 	return nil
 }
 
@@ -54,6 +323,106 @@ func (rls *ResourceLimiterService) Shutdown() error {
 	return nil
 }
 
+// RPC Methods available internally
+
+// V1ReloadResourceLimits re-reads the ResourceLimits with rlIDs out of dataDB and indexes them, all of them on empty rlIDs
+func (rls *ResourceLimiterService) V1ReloadResourceLimits(rlIDs []string, reply *string) error {
+	if len(rlIDs) == 0 {
+		rlIDs = nil
+	}
+	if err := rls.indexStringFilters(rlIDs); err != nil {
+		return err
+	}
+	*reply = utils.OK
+	return nil
+}
+
+// V1ResourceLimitsForEvent returns the ResourceLimits matching the event
+func (rls *ResourceLimiterService) V1ResourceLimitsForEvent(ev map[string]interface{}, reply *[]*ResourceLimit) error {
+	rls.Lock() // Expiring usage alters the limits
+	defer rls.Unlock()
+	matchingRLs, err := rls.matchingResourceLimitsForEvent(ev)
+	if err != nil {
+		return err
+	}
+	*reply = matchingRLs
+	return nil
+}
+
+// V1AllowUsage checks if there are enough resources left for the usage without recording it
+func (rls *ResourceLimiterService) V1AllowUsage(attrs utils.AttrRLsResourceUsage, allow *bool) error {
+	rls.Lock()
+	defer rls.Unlock()
+	matchingRLs, err := rls.matchingResourceLimitsForEvent(attrs.Event)
+	if err != nil {
+		return err
+	}
+	units := attrs.Units
+	if units == 0 {
+		units = 1
+	}
+	*allow = true
+	for _, rl := range matchingRLs {
+		if rl.UsedUnits()+units > rl.Limit {
+			*allow = false
+			break
+		}
+	}
+	return nil
+}
+
+// V1InitiateResourceUsage records the usage on all ResourceLimits matching the event, if all of them allow it
+func (rls *ResourceLimiterService) V1InitiateResourceUsage(attrs utils.AttrRLsResourceUsage, reply *string) error {
+	if attrs.UsageID == "" {
+		return utils.NewErrMandatoryIeMissing("UsageID")
+	}
+	rls.Lock()
+	defer rls.Unlock()
+	matchingRLs, err := rls.matchingResourceLimitsForEvent(attrs.Event)
+	if err != nil {
+		return err
+	}
+	units := attrs.Units
+	if units == 0 {
+		units = 1
+	}
+	for _, rl := range matchingRLs {
+		if _, hasID := rl.Usage[attrs.UsageID]; hasID {
+			return utils.ErrExists
+		}
+		if rl.UsedUnits()+units > rl.Limit {
+			return utils.ErrResourceUnavailable
+		}
+	}
+	for _, rl := range matchingRLs {
+		rl.RecordUsage(&ResourceUsage{ID: attrs.UsageID, UsageTime: time.Now(), UsageUnits: units})
+		rl.executeActionTriggers()
+	}
+	*reply = utils.OK
+	return nil
+}
+
+// V1TerminateResourceUsage releases the usage recorded on the ResourceLimits matching the event
+func (rls *ResourceLimiterService) V1TerminateResourceUsage(attrs utils.AttrRLsResourceUsage, reply *string) error {
+	if attrs.UsageID == "" {
+		return utils.NewErrMandatoryIeMissing("UsageID")
+	}
+	rls.Lock()
+	defer rls.Unlock()
+	matchingRLs, err := rls.matchingResourceLimitsForEvent(attrs.Event)
+	if err != nil {
+		return err
+	}
+	for _, rl := range matchingRLs {
+		if err := rl.RemoveUsage(attrs.UsageID); err != nil {
+			continue // Usage not recorded or expired
+		}
+		rl.executeActionTriggers()
+	}
+	*reply = utils.OK
+	return nil
+}
+
 // Make the service available as RPC internally
 func (rls *ResourceLimiterService) Call(serviceMethod string, args interface{}, reply interface{}) error {
 	parts := strings.Split(serviceMethod, ".")
@@ -0,0 +1,354 @@
+/*
+Real-time Charging System for Telecom & ISP environments
+Copyright (C) ITsysCOM GmbH
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>
+*/
+package engine
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/cgrates/cgrates/utils"
+)
+
+func TestRLsIndexStringFilters(t *testing.T) {
+	dataDB, _ := NewMapStorage()
+	rls := []*ResourceLimit{
+		&ResourceLimit{
+			ID:     "RL1",
+			Weight: 20,
+			Filters: []*RequestFilter{
+				&RequestFilter{Type: MetaString, FieldName: "Account", Values: []string{"1001", "1002"}},
+				&RequestFilter{Type: MetaRSRFields, Values: []string{"Subject(~^1.*1$)", "Destination(1002)"},
+					rsrFields: utils.ParseRSRFieldsMustCompile("Subject(~^1.*1$);Destination(1002)", utils.INFIELD_SEP),
+				}},
+			ActivationTime: time.Date(2014, 7, 3, 13, 43, 0, 1, time.UTC),
+			Limit:          2,
+			Usage:          make(map[string]*ResourceUsage),
+		},
+		&ResourceLimit{
+			ID:     "RL2",
+			Weight: 10,
+			Filters: []*RequestFilter{
+				&RequestFilter{Type: MetaString, FieldName: "Account", Values: []string{"dan", "1002"}},
+				&RequestFilter{Type: MetaString, FieldName: "Subject", Values: []string{"dan"}},
+			},
+			ActivationTime: time.Date(2014, 7, 3, 13, 43, 0, 1, time.UTC),
+			Limit:          1,
+			Usage:          make(map[string]*ResourceUsage),
+		},
+		&ResourceLimit{
+			ID:     "RL3",
+			Weight: 10,
+			Filters: []*RequestFilter{
+				&RequestFilter{Type: MetaStringPrefix, FieldName: "Destination", Values: []string{"+49"}},
+			},
+			ActivationTime: time.Date(2014, 7, 3, 13, 43, 0, 1, time.UTC),
+			Limit:          1,
+			Usage:          make(map[string]*ResourceUsage),
+		},
+	}
+	for _, rl := range rls {
+		dataDB.SetResourceLimit(rl)
+	}
+	rLS := NewResourceLimiterService(dataDB, nil, 0)
+	if err := rLS.Start(); err != nil {
+		t.Error(err)
+	}
+	eIndexes := map[string]map[string]utils.StringMap{
+		"Account": map[string]utils.StringMap{
+			"1001": utils.StringMap{"RL1": true},
+			"1002": utils.StringMap{"RL1": true, "RL2": true},
+			"dan":  utils.StringMap{"RL2": true},
+		},
+		"Subject": map[string]utils.StringMap{
+			"dan": utils.StringMap{"RL2": true},
+		},
+	}
+	if !reflect.DeepEqual(eIndexes, rLS.stringIndexes) {
+		t.Errorf("Expecting: %+v, received: %+v", eIndexes, rLS.stringIndexes)
+	}
+	if eUnindexed := utils.NewStringMap("RL3"); !reflect.DeepEqual(eUnindexed, rLS.unindexedRLs) {
+		t.Errorf("Expecting: %+v, received: %+v", eUnindexed, rLS.unindexedRLs)
+	}
+}
+
+func TestRLsMatchingResourceLimitsForEvent(t *testing.T) {
+	dataDB, _ := NewMapStorage()
+	rl1 := &ResourceLimit{
+		ID:     "RL1",
+		Weight: 20,
+		Filters: []*RequestFilter{
+			&RequestFilter{Type: MetaString, FieldName: "Account", Values: []string{"1001", "1002"}},
+		},
+		ActivationTime: time.Date(2014, 7, 3, 13, 43, 0, 1, time.UTC),
+		Limit:          2,
+	}
+	rl2 := &ResourceLimit{
+		ID:     "RL2",
+		Weight: 30,
+		Filters: []*RequestFilter{
+			&RequestFilter{Type: MetaString, FieldName: "Account", Values: []string{"1002"}},
+			&RequestFilter{Type: MetaString, FieldName: "Subject", Values: []string{"dan"}},
+		},
+		ActivationTime: time.Date(2014, 7, 3, 13, 43, 0, 1, time.UTC),
+		Limit:          1,
+	}
+	rlInactive := &ResourceLimit{
+		ID:             "RL_INACTIVE",
+		Filters:        []*RequestFilter{&RequestFilter{Type: MetaString, FieldName: "Account", Values: []string{"1002"}}},
+		ActivationTime: time.Now().Add(time.Hour),
+		Limit:          1,
+	}
+	for _, rl := range []*ResourceLimit{rl1, rl2, rlInactive} {
+		dataDB.SetResourceLimit(rl)
+	}
+	rLS := NewResourceLimiterService(dataDB, nil, 0)
+	if err := rLS.Start(); err != nil {
+		t.Error(err)
+	}
+	if mRLs, err := rLS.matchingResourceLimitsForEvent(map[string]interface{}{"Account": "1001"}); err != nil {
+		t.Error(err)
+	} else if len(mRLs) != 1 || mRLs[0].ID != "RL1" {
+		t.Errorf("Received: %+v", mRLs)
+	}
+	if mRLs, err := rLS.matchingResourceLimitsForEvent(map[string]interface{}{"Account": "1002", "Subject": "dan"}); err != nil {
+		t.Error(err)
+	} else if len(mRLs) != 2 || mRLs[0].ID != "RL2" || mRLs[1].ID != "RL1" {
+		t.Errorf("Received: %+v", mRLs)
+	}
+	if mRLs, err := rLS.matchingResourceLimitsForEvent(map[string]interface{}{"Account": "1003"}); err != nil {
+		t.Error(err)
+	} else if len(mRLs) != 0 {
+		t.Errorf("Received: %+v", mRLs)
+	}
+}
+
+func TestRLsResourceUsage(t *testing.T) {
+	dataDB, _ := NewMapStorage()
+	rl := &ResourceLimit{
+		ID: "RL_USAGE",
+		Filters: []*RequestFilter{
+			&RequestFilter{Type: MetaString, FieldName: "Tenant", Values: []string{"rls.org"}},
+		},
+		ActivationTime: time.Date(2014, 7, 3, 13, 43, 0, 1, time.UTC),
+		Limit:          2,
+	}
+	dataDB.SetResourceLimit(rl)
+	rLS := NewResourceLimiterService(dataDB, nil, 0)
+	if err := rLS.Start(); err != nil {
+		t.Error(err)
+	}
+	ev := map[string]interface{}{"Tenant": "rls.org", "Account": "1001"}
+	var allow bool
+	if err := rLS.V1AllowUsage(utils.AttrRLsResourceUsage{UsageID: "session1", Event: ev}, &allow); err != nil {
+		t.Error(err)
+	} else if !allow {
+		t.Error("Usage not allowed")
+	}
+	var reply string
+	if err := rLS.V1InitiateResourceUsage(utils.AttrRLsResourceUsage{UsageID: "session1", Event: ev}, &reply); err != nil {
+		t.Error(err)
+	} else if reply != utils.OK {
+		t.Error("Received reply: ", reply)
+	}
+	if err := rLS.V1InitiateResourceUsage(utils.AttrRLsResourceUsage{UsageID: "session1", Event: ev}, &reply); err != utils.ErrExists {
+		t.Error("Received error: ", err)
+	}
+	if err := rLS.V1AllowUsage(utils.AttrRLsResourceUsage{UsageID: "session2", Event: ev, Units: 2}, &allow); err != nil {
+		t.Error(err)
+	} else if allow {
+		t.Error("Usage allowed over limit")
+	}
+	if err := rLS.V1InitiateResourceUsage(utils.AttrRLsResourceUsage{UsageID: "session2", Event: ev}, &reply); err != nil {
+		t.Error(err)
+	}
+	if err := rLS.V1InitiateResourceUsage(utils.AttrRLsResourceUsage{UsageID: "session3", Event: ev}, &reply); err != utils.ErrResourceUnavailable {
+		t.Error("Received error: ", err)
+	}
+	if err := rLS.V1TerminateResourceUsage(utils.AttrRLsResourceUsage{UsageID: "session1", Event: ev}, &reply); err != nil {
+		t.Error(err)
+	}
+	if err := rLS.V1InitiateResourceUsage(utils.AttrRLsResourceUsage{UsageID: "session3", Event: ev}, &reply); err != nil {
+		t.Error(err)
+	}
+	if cachedRL, err := dataDB.GetResourceLimit("RL_USAGE", false); err != nil {
+		t.Error(err)
+	} else if cachedRL.UsedUnits() != 2 {
+		t.Errorf("Unexpected usage: %+v", cachedRL.Usage)
+	}
+	if err := rLS.V1InitiateResourceUsage(utils.AttrRLsResourceUsage{Event: ev}, &reply); err == nil {
+		t.Error("Should receive error for missing UsageID")
+	}
+}
+
+func TestRLsRemoveExpiredUnits(t *testing.T) {
+	rl := &ResourceLimit{
+		ID:    "RL_EXPIRE",
+		Limit: 2,
+		Usage: map[string]*ResourceUsage{
+			"old": &ResourceUsage{ID: "old", UsageTime: time.Now().Add(-2 * time.Hour), UsageUnits: 1},
+			"new": &ResourceUsage{ID: "new", UsageTime: time.Now(), UsageUnits: 1},
+		},
+	}
+	rl.removeExpiredUnits(0)
+	if len(rl.Usage) != 2 {
+		t.Errorf("Usage expired with ttl 0: %+v", rl.Usage)
+	}
+	rl.removeExpiredUnits(time.Hour)
+	if _, hasIt := rl.Usage["old"]; hasIt || len(rl.Usage) != 1 {
+		t.Errorf("Unexpected usage: %+v", rl.Usage)
+	}
+}
+
+func TestRLsActionTriggers(t *testing.T) {
+	ratingStorage.SetActions("ACT_RL_LOG", Actions{&Action{ActionType: LOG}})
+	ratingStorage.CacheRatingPrefixValues("", map[string][]string{utils.ACTION_PREFIX: []string{utils.ACTION_PREFIX + "ACT_RL_LOG"}})
+	dataDB, _ := NewMapStorage()
+	rl := &ResourceLimit{
+		ID: "RL_TRIGGERS",
+		Filters: []*RequestFilter{
+			&RequestFilter{Type: MetaString, FieldName: "Tenant", Values: []string{"triggers.org"}},
+		},
+		ActivationTime: time.Date(2014, 7, 3, 13, 43, 0, 1, time.UTC),
+		Limit:          10,
+		ActionTriggers: ActionTriggers{
+			&ActionTrigger{ID: "RL_MAX", ThresholdType: utils.TRIGGER_MAX_RESOURCE_USAGE, ThresholdValue: 2, ActionsID: "ACT_RL_LOG"},
+		},
+	}
+	dataDB.SetResourceLimit(rl)
+	rLS := NewResourceLimiterService(dataDB, nil, 0)
+	if err := rLS.Start(); err != nil {
+		t.Error(err)
+	}
+	ev := map[string]interface{}{"Tenant": "triggers.org"}
+	var reply string
+	if err := rLS.V1InitiateResourceUsage(utils.AttrRLsResourceUsage{UsageID: "session1", Event: ev}, &reply); err != nil {
+		t.Error(err)
+	}
+	if storedRL, err := dataDB.GetResourceLimit("RL_TRIGGERS", false); err != nil {
+		t.Error(err)
+	} else if storedRL.ActionTriggers[0].Executed {
+		t.Error("Trigger executed under threshold")
+	}
+	if err := rLS.V1InitiateResourceUsage(utils.AttrRLsResourceUsage{UsageID: "session2", Event: ev}, &reply); err != nil {
+		t.Error(err)
+	}
+	if storedRL, err := dataDB.GetResourceLimit("RL_TRIGGERS", false); err != nil {
+		t.Error(err)
+	} else if !storedRL.ActionTriggers[0].Executed {
+		t.Error("Trigger not executed when reaching threshold")
+	}
+	storedRL, _ := dataDB.GetResourceLimit("RL_TRIGGERS", false)
+	lastExec := storedRL.ActionTriggers[0].LastExecutionTime
+	if err := rLS.V1InitiateResourceUsage(utils.AttrRLsResourceUsage{UsageID: "session3", Event: ev}, &reply); err != nil {
+		t.Error(err)
+	}
+	if storedRL, _ := dataDB.GetResourceLimit("RL_TRIGGERS", false); storedRL.ActionTriggers[0].LastExecutionTime != lastExec {
+		t.Error("Trigger executed again without crossing the threshold")
+	}
+	for _, usageID := range []string{"session2", "session3"} {
+		if err := rLS.V1TerminateResourceUsage(utils.AttrRLsResourceUsage{UsageID: usageID, Event: ev}, &reply); err != nil {
+			t.Error(err)
+		}
+	}
+	if storedRL, _ := dataDB.GetResourceLimit("RL_TRIGGERS", false); storedRL.ActionTriggers[0].Executed {
+		t.Error("Trigger not re-armed when usage went under threshold")
+	}
+}
+
+func TestRLsActionTriggersFilteredActions(t *testing.T) {
+	ratingStorage.SetActions("ACT_RL_FLTR", Actions{&Action{ActionType: LOG, Filter: `{"Type":"*monetary"}`}})
+	ratingStorage.CacheRatingPrefixValues("", map[string][]string{utils.ACTION_PREFIX: []string{utils.ACTION_PREFIX + "ACT_RL_FLTR"}})
+	rl := &ResourceLimit{
+		ID:    "RL_FLTR",
+		Limit: 10,
+		ActionTriggers: ActionTriggers{
+			&ActionTrigger{ID: "RL_MAX", ThresholdType: utils.TRIGGER_MAX_RESOURCE_USAGE, ThresholdValue: 1, ActionsID: "ACT_RL_FLTR"},
+		},
+		Usage: map[string]*ResourceUsage{"session1": &ResourceUsage{ID: "session1", UsageTime: time.Now(), UsageUnits: 1}},
+	}
+	rl.executeActionTriggers() // Should not panic without account
+	if !rl.ActionTriggers[0].Executed {
+		t.Error("Trigger not executed")
+	}
+}
+
+func TestRLsReloadResourceLimits(t *testing.T) {
+	dataDB, _ := NewMapStorage()
+	rl := &ResourceLimit{
+		ID: "RL_RELOAD",
+		Filters: []*RequestFilter{
+			&RequestFilter{Type: MetaString, FieldName: "Account", Values: []string{"1001"}},
+		},
+		ActivationTime: time.Date(2014, 7, 3, 13, 43, 0, 1, time.UTC),
+		Limit:          2,
+	}
+	dataDB.SetResourceLimit(rl)
+	rLS := NewResourceLimiterService(dataDB, nil, 0)
+	if err := rLS.Start(); err != nil {
+		t.Error(err)
+	}
+	var reply string
+	if err := rLS.V1InitiateResourceUsage(utils.AttrRLsResourceUsage{UsageID: "session1",
+		Event: map[string]interface{}{"Account": "1001"}}, &reply); err != nil {
+		t.Error(err)
+	}
+	// Loading the tariff plan again replaces the cached ResourceLimit and adds a new one
+	dataDB.SetResourceLimit(&ResourceLimit{
+		ID: "RL_RELOAD",
+		Filters: []*RequestFilter{
+			&RequestFilter{Type: MetaString, FieldName: "Account", Values: []string{"1001", "1002"}},
+		},
+		ActivationTime: time.Date(2014, 7, 3, 13, 43, 0, 1, time.UTC),
+		Limit:          2,
+	})
+	dataDB.SetResourceLimit(&ResourceLimit{
+		ID: "RL_RELOAD_NEW",
+		Filters: []*RequestFilter{
+			&RequestFilter{Type: MetaString, FieldName: "Account", Values: []string{"1002"}},
+		},
+		ActivationTime: time.Date(2014, 7, 3, 13, 43, 0, 1, time.UTC),
+		Limit:          1,
+	})
+	if err := rLS.V1ReloadResourceLimits([]string{"RL_RELOAD", "RL_RELOAD_NEW"}, &reply); err != nil {
+		t.Error(err)
+	}
+	if mRLs, err := rLS.matchingResourceLimitsForEvent(map[string]interface{}{"Account": "1002"}); err != nil {
+		t.Error(err)
+	} else if len(mRLs) != 2 {
+		t.Errorf("Received: %s", utils.ToJSON(mRLs))
+	} else if usedUnits := mRLs[0].UsedUnits() + mRLs[1].UsedUnits(); usedUnits != 1 {
+		t.Errorf("Usage lost on reload: %s", utils.ToJSON(mRLs))
+	}
+	if err := rLS.V1InitiateResourceUsage(utils.AttrRLsResourceUsage{UsageID: "session1",
+		Event: map[string]interface{}{"Account": "1001"}}, &reply); err != utils.ErrExists {
+		t.Error("Received error: ", err)
+	}
+	dataDB.RemoveResourceLimit("RL_RELOAD_NEW")
+	if err := rLS.V1ReloadResourceLimits([]string{"RL_RELOAD_NEW"}, &reply); err != nil {
+		t.Error(err)
+	}
+	if mRLs, err := rLS.matchingResourceLimitsForEvent(map[string]interface{}{"Account": "1002"}); err != nil {
+		t.Error(err)
+	} else if len(mRLs) != 1 || mRLs[0].ID != "RL_RELOAD" {
+		t.Errorf("Received: %s", utils.ToJSON(mRLs))
+	}
+	if _, hasIt := rLS.usages["RL_RELOAD_NEW"]; hasIt {
+		t.Error("Usage of removed ResourceLimit still kept")
+	}
+}
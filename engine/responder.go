@@ -525,6 +525,18 @@ func (rs *Responder) GetDerivedChargers(attrs *utils.AttrDerivedChargers, dcs *u
 	return nil
 }
 
+func (rs *Responder) GetCDRTaxes(cdr *CDR, reply *TaxCost) error {
+	if rs.Bal != nil {
+		return errors.New("BALANCER_UNSUPPORTED_METHOD")
+	}
+	tc, err := GetCDRTaxes(cdr)
+	if err != nil {
+		return err
+	}
+	*reply = *tc
+	return nil
+}
+
 func (rs *Responder) GetLCR(attrs *AttrGetLcr, reply *LCRCost) error {
 	cacheKey := utils.LCRCachePrefix + attrs.CgrID + attrs.RunID
 	if item, err := rs.getCache().Get(cacheKey); err == nil && item != nil {
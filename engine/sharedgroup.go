@@ -101,6 +101,9 @@ func (sg *SharedGroup) GetBalances(destination, category, direction, balanceType
 			if nUb == nil || nUb.Disabled {
 				continue
 			}
+			if ub.ledger != nil { // changes on shared balances go to the ledger of their account, on behalf of the initiating operation
+				nUb.setLedgerOrigin(ub.ledger.origin)
+			}
 		}
 		//sg.members = append(sg.members, nUb)
 		sb := nUb.getBalancesForPrefix(destination, category, direction, balanceType, sg.Id)
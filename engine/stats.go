@@ -206,13 +206,7 @@ func (s *Stats) ReloadQueues(ids []string, out *int) error {
 func (s *Stats) ResetQueues(ids []string, out *int) error {
 	if len(ids) == 0 {
 		for _, sq := range s.queues {
-			sq.Cdrs = make([]*QCdr, 0)
-			sq.metrics = make(map[string]Metric, len(sq.conf.Metrics))
-			for _, m := range sq.conf.Metrics {
-				if metric := CreateMetric(m); metric != nil {
-					sq.metrics[m] = metric
-				}
-			}
+			sq.resetQueue()
 		}
 	} else {
 		for _, id := range ids {
@@ -221,13 +215,7 @@ func (s *Stats) ResetQueues(ids []string, out *int) error {
 				utils.Logger.Warning(fmt.Sprintf("Cannot reset queue id %v: Not Fund", id))
 				continue
 			}
-			sq.Cdrs = make([]*QCdr, 0)
-			sq.metrics = make(map[string]Metric, len(sq.conf.Metrics))
-			for _, m := range sq.conf.Metrics {
-				if metric := CreateMetric(m); metric != nil {
-					sq.metrics[m] = metric
-				}
-			}
+			sq.resetQueue()
 		}
 	}
 	return nil
@@ -0,0 +1,271 @@
+/*
+Rating system designed to be used in VoIP Carriers World
+Copyright (C) 2012-2015 ITsysCOM
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>
+*/
+
+package engine
+
+import (
+	"time"
+)
+
+// Metrics which can be computed out of StatsBuckets, mandatory for queues using BucketInterval
+type BucketMetric interface {
+	Metric
+	AddBucket(*StatsBucket)
+	RemoveBucket(*StatsBucket)
+}
+
+// StatsBucket aggregates the CDRs received by a StatsQueue within one BucketInterval.
+// Metrics add and remove whole buckets out of the aggregated values. The CDRs are kept too so the bucket
+// at the edge of the TimeWindow drops its expired CDRs one by one, as the queue does without buckets.
+type StatsBucket struct {
+	StartTime        time.Time
+	Cdrs             []*QCdr                  // CDRs not yet out of the TimeWindow, in the order they were added
+	LastSetupTime    time.Time                // SetupTime of the last CDR added, used by load distribution
+	Count            float64                  // All CDRs
+	Answered         float64                  // Answered CDRs
+	UsageSum         time.Duration            // Usage of answered CDRs
+	PddCount         float64                  // CDRs with Pdd defined
+	PddSum           time.Duration            // Pdd of CDRs with Pdd defined
+	CostCount        float64                  // Answered CDRs with Cost
+	CostSum          float64                  // Cost of answered CDRs
+	Usages           map[string]float64       // Number of answered CDRs per Usage, used by percentiles
+	Pdds             map[string]float64       // Number of CDRs per Pdd, used by percentiles
+	Destinations     map[string]int64         // Number of CDRs per destination
+	DisconnectCauses map[string]float64       // Number of not answered CDRs per disconnect cause
+	SupplierCosts    map[string]float64       // Cost of answered CDRs per supplier
+	SupplierUsages   map[string]time.Duration // Usage of answered CDRs per supplier
+}
+
+func NewStatsBucket(startTime time.Time) *StatsBucket {
+	return &StatsBucket{
+		StartTime:        startTime,
+		Usages:           make(map[string]float64),
+		Pdds:             make(map[string]float64),
+		Destinations:     make(map[string]int64),
+		DisconnectCauses: make(map[string]float64),
+		SupplierCosts:    make(map[string]float64),
+		SupplierUsages:   make(map[string]time.Duration),
+	}
+}
+
+// AddQCdr aggregates the cdr into the bucket
+func (sb *StatsBucket) AddQCdr(cdr *QCdr) {
+	sb.Cdrs = append(sb.Cdrs, cdr)
+	sb.Count += 1
+	if cdr.SetupTime.After(sb.LastSetupTime) {
+		sb.LastSetupTime = cdr.SetupTime
+	}
+	if !cdr.AnswerTime.IsZero() {
+		sb.Answered += 1
+		sb.UsageSum += cdr.Usage
+		sb.Usages[cdr.Usage.String()] += 1
+		if cdr.Cost >= 0 {
+			sb.CostCount += 1
+			sb.CostSum += cdr.Cost
+			sb.SupplierCosts[cdr.Supplier] += cdr.Cost
+			sb.SupplierUsages[cdr.Supplier] += cdr.Usage
+		}
+	} else {
+		sb.DisconnectCauses[cdr.DisconnectCause] += 1
+	}
+	if cdr.Pdd != 0 {
+		sb.PddCount += 1
+		sb.PddSum += cdr.Pdd
+		sb.Pdds[cdr.Pdd.String()] += 1
+	}
+	sb.Destinations[cdr.Dest] += 1
+}
+
+// RemoveFirstQCdr takes the oldest cdr out of the bucket and its aggregated values, LastSetupTime is kept
+func (sb *StatsBucket) RemoveFirstQCdr() *QCdr {
+	if len(sb.Cdrs) == 0 {
+		return nil
+	}
+	cdr := sb.Cdrs[0]
+	sb.Cdrs = sb.Cdrs[1:]
+	sb.Count -= 1
+	if !cdr.AnswerTime.IsZero() {
+		sb.Answered -= 1
+		sb.UsageSum -= cdr.Usage
+		decrementBucketCount(sb.Usages, cdr.Usage.String())
+		if cdr.Cost >= 0 {
+			sb.CostCount -= 1
+			sb.CostSum -= cdr.Cost
+			sb.SupplierCosts[cdr.Supplier] -= cdr.Cost
+			sb.SupplierUsages[cdr.Supplier] -= cdr.Usage
+		}
+	} else {
+		decrementBucketCount(sb.DisconnectCauses, cdr.DisconnectCause)
+	}
+	if cdr.Pdd != 0 {
+		sb.PddCount -= 1
+		sb.PddSum -= cdr.Pdd
+		decrementBucketCount(sb.Pdds, cdr.Pdd.String())
+	}
+	if sb.Destinations[cdr.Dest] -= 1; sb.Destinations[cdr.Dest] <= 0 {
+		delete(sb.Destinations, cdr.Dest)
+	}
+	return cdr
+}
+
+func decrementBucketCount(counts map[string]float64, key string) {
+	if counts[key] -= 1; counts[key] <= 0 {
+		delete(counts, key)
+	}
+}
+
+// Number of items out of a duration histogram, ignoring the keys which cannot be parsed
+func bucketDurationCounts(hist map[string]float64) map[time.Duration]float64 {
+	counts := make(map[time.Duration]float64, len(hist))
+	for durStr, cnt := range hist {
+		if dur, err := time.ParseDuration(durStr); err == nil {
+			counts[dur] += cnt
+		}
+	}
+	return counts
+}
+
+func (asr *ASRMetric) AddBucket(sb *StatsBucket) {
+	asr.answered += sb.Answered
+	asr.count += sb.Count
+}
+
+func (asr *ASRMetric) RemoveBucket(sb *StatsBucket) {
+	asr.answered -= sb.Answered
+	asr.count -= sb.Count
+}
+
+func (PDD *PDDMetric) AddBucket(sb *StatsBucket) {
+	PDD.sum += sb.PddSum
+	PDD.count += sb.PddCount
+}
+
+func (PDD *PDDMetric) RemoveBucket(sb *StatsBucket) {
+	PDD.sum -= sb.PddSum
+	PDD.count -= sb.PddCount
+}
+
+func (acd *ACDMetric) AddBucket(sb *StatsBucket) {
+	acd.sum += sb.UsageSum
+	acd.count += sb.Answered
+}
+
+func (acd *ACDMetric) RemoveBucket(sb *StatsBucket) {
+	acd.sum -= sb.UsageSum
+	acd.count -= sb.Answered
+}
+
+func (tcd *TCDMetric) AddBucket(sb *StatsBucket) {
+	tcd.sum += sb.UsageSum
+	tcd.count += sb.Answered
+}
+
+func (tcd *TCDMetric) RemoveBucket(sb *StatsBucket) {
+	tcd.sum -= sb.UsageSum
+	tcd.count -= sb.Answered
+}
+
+func (acc *ACCMetric) AddBucket(sb *StatsBucket) {
+	acc.sum += sb.CostSum
+	acc.count += sb.CostCount
+}
+
+func (acc *ACCMetric) RemoveBucket(sb *StatsBucket) {
+	acc.sum -= sb.CostSum
+	acc.count -= sb.CostCount
+}
+
+func (tcc *TCCMetric) AddBucket(sb *StatsBucket) {
+	tcc.sum += sb.CostSum
+	tcc.count += sb.CostCount
+}
+
+func (tcc *TCCMetric) RemoveBucket(sb *StatsBucket) {
+	tcc.sum -= sb.CostSum
+	tcc.count -= sb.CostCount
+}
+
+// Replays the CDRs per destination so the result is the same as in per-CDR mode
+func (dcc *DCCMetric) AddBucket(sb *StatsBucket) {
+	for dest, cnt := range sb.Destinations {
+		for i := int64(0); i < cnt; i++ {
+			dcc.AddCdr(&QCdr{Dest: dest})
+		}
+	}
+}
+
+func (dcc *DCCMetric) RemoveBucket(sb *StatsBucket) {
+	for dest, cnt := range sb.Destinations {
+		for i := int64(0); i < cnt; i++ {
+			dcc.RemoveCdr(&QCdr{Dest: dest})
+		}
+	}
+}
+
+func (ner *NERMetric) AddBucket(sb *StatsBucket) {
+	ner.effective += ner.bucketEffective(sb)
+	ner.count += sb.Count
+}
+
+func (ner *NERMetric) RemoveBucket(sb *StatsBucket) {
+	ner.effective -= ner.bucketEffective(sb)
+	ner.count -= sb.Count
+}
+
+func (ner *NERMetric) bucketEffective(sb *StatsBucket) (effective float64) {
+	effective = sb.Answered
+	for cause, cnt := range sb.DisconnectCauses {
+		if ner.effectiveCauses[cause] {
+			effective += cnt
+		}
+	}
+	return
+}
+
+func (pm *PercentileMetric) AddBucket(sb *StatsBucket) {
+	for val, cnt := range bucketDurationCounts(pm.source.fromBucket(sb)) {
+		pm.add(val, cnt)
+	}
+}
+
+func (pm *PercentileMetric) RemoveBucket(sb *StatsBucket) {
+	for val, cnt := range bucketDurationCounts(pm.source.fromBucket(sb)) {
+		pm.remove(val, cnt)
+	}
+}
+
+func (scpm *SCPMMetric) AddBucket(sb *StatsBucket) {
+	for supplier, usage := range sb.SupplierUsages {
+		scpm.costs[supplier] += sb.SupplierCosts[supplier]
+		scpm.usages[supplier] += usage
+	}
+}
+
+func (scpm *SCPMMetric) RemoveBucket(sb *StatsBucket) {
+	for supplier, usage := range sb.SupplierUsages {
+		if _, hasIt := scpm.usages[supplier]; !hasIt {
+			continue
+		}
+		scpm.costs[supplier] -= sb.SupplierCosts[supplier]
+		scpm.usages[supplier] -= usage
+		if scpm.usages[supplier] <= 0 {
+			delete(scpm.costs, supplier)
+			delete(scpm.usages, supplier)
+		}
+	}
+}
@@ -19,6 +19,11 @@ along with this program.  If not, see <http://www.gnu.org/licenses/>
 package engine
 
 import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/cgrates/cgrates/utils"
@@ -37,28 +42,105 @@ const ACC = "ACC"
 const TCC = "TCC"
 const PDD = "PDD"
 const DDC = "DDC"
+const NER = "NER"
+const P50_CD = "P50_CD"
+const P95_CD = "P95_CD"
+const P99_CD = "P99_CD"
+const P50_PDD = "P50_PDD"
+const P95_PDD = "P95_PDD"
+const P99_PDD = "P99_PDD"
+const SCPM = "SCPM"
 const STATS_NA = -1
 
-func CreateMetric(metric string) Metric {
-	switch metric {
-	case ASR:
-		return &ASRMetric{}
-	case PDD:
-		return &PDDMetric{}
-	case ACD:
-		return &ACDMetric{}
-	case TCD:
-		return &TCDMetric{}
-	case ACC:
-		return &ACCMetric{}
-	case TCC:
-		return &TCCMetric{}
-	case DDC:
-		return NewDccMetric()
+// Optional interface for metrics computing one value per key (eg: per supplier), next to their global value.
+// The values are published by GetStats as <MetricName>:<Key>
+type MultiValueMetric interface {
+	Metric
+	GetValues() map[string]float64
+}
+
+// Builds a fresh, empty instance of a metric
+type MetricConstructor func() Metric
+
+var (
+	metricsRegistry = make(map[string]MetricConstructor)
+	metricsRegMux   sync.RWMutex
+)
+
+func init() {
+	RegisterMetric(ASR, func() Metric { return &ASRMetric{} })
+	RegisterMetric(PDD, func() Metric { return &PDDMetric{} })
+	RegisterMetric(ACD, func() Metric { return &ACDMetric{} })
+	RegisterMetric(TCD, func() Metric { return &TCDMetric{} })
+	RegisterMetric(ACC, func() Metric { return &ACCMetric{} })
+	RegisterMetric(TCC, func() Metric { return &TCCMetric{} })
+	RegisterMetric(DDC, func() Metric { return NewDccMetric() })
+	RegisterMetric(NER, func() Metric { return NewNERMetric() })
+	RegisterMetric(P50_CD, func() Metric { return newPercentileMetric(50, answeredUsageSource) })
+	RegisterMetric(P95_CD, func() Metric { return newPercentileMetric(95, answeredUsageSource) })
+	RegisterMetric(P99_CD, func() Metric { return newPercentileMetric(99, answeredUsageSource) })
+	RegisterMetric(P50_PDD, func() Metric { return newPercentileMetric(50, pddSource) })
+	RegisterMetric(P95_PDD, func() Metric { return newPercentileMetric(95, pddSource) })
+	RegisterMetric(P99_PDD, func() Metric { return newPercentileMetric(99, pddSource) })
+	RegisterMetric(SCPM, func() Metric { return NewSCPMMetric() })
+}
+
+// RegisterMetric makes a new metric available to the StatsQueues under the given name.
+// The *min_ and *max_ trigger threshold types are derived out of the lower cased name, eg: *max_p95_cd
+func RegisterMetric(name string, constructor MetricConstructor) error {
+	if name == "" || constructor == nil {
+		return utils.ErrMandatoryIeMissing
+	}
+	metricsRegMux.Lock()
+	defer metricsRegMux.Unlock()
+	if _, hasIt := metricsRegistry[name]; hasIt {
+		return utils.ErrExists
 	}
+	metricsRegistry[name] = constructor
+	lowerName := strings.ToLower(name)
+	metricTriggerMux.Lock()
+	METRIC_TRIGGER_MAP["*min_"+lowerName] = name
+	METRIC_TRIGGER_MAP["*max_"+lowerName] = name
+	metricTriggerMux.Unlock()
 	return nil
 }
 
+// RegisteredMetrics returns the names of the metrics which can be used in StatsQueues
+func RegisteredMetrics() []string {
+	metricsRegMux.RLock()
+	defer metricsRegMux.RUnlock()
+	names := make([]string, 0, len(metricsRegistry))
+	for name := range metricsRegistry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// CreateMetric returns a new instance of the registered metric or nil if the name is unknown
+func CreateMetric(metric string) Metric {
+	metricsRegMux.RLock()
+	constructor, hasIt := metricsRegistry[metric]
+	metricsRegMux.RUnlock()
+	if !hasIt {
+		return nil
+	}
+	return constructor()
+}
+
+// Builds the metrics out of their names, unknown ones are logged and ignored
+func newMetrics(names []string) map[string]Metric {
+	metrics := make(map[string]Metric, len(names))
+	for _, m := range names {
+		if metric := CreateMetric(m); metric != nil {
+			metrics[m] = metric
+		} else {
+			utils.Logger.Warning(fmt.Sprintf("<CDRStats> Unknown metric: %s, ignoring", m))
+		}
+	}
+	return metrics
+}
+
 // ASR - Answer-Seizure Ratio
 // successfully answered Calls divided by the total number of Calls attempted and multiplied by 100
 type ASRMetric struct {
@@ -267,3 +349,191 @@ func (dcc *DCCMetric) GetValue() float64 {
 	}
 	return float64(len(dcc.destinations))
 }
+
+// NER - Network Effectiveness Ratio
+// calls answered or rejected by the called party divided by the total number of calls attempted and multiplied by 100
+type NERMetric struct {
+	effectiveCauses utils.StringMap
+	effective       float64
+	count           float64
+}
+
+// Disconnect causes meaning the call reached the called party, considered when no other list is given
+var NERDefaultEffectiveCauses = []string{"NORMAL_CLEARING", "USER_BUSY", "NO_USER_RESPONSE", "NO_ANSWER",
+	"CALL_REJECTED", "ORIGINATOR_CANCEL", "486", "480", "487", "603"}
+
+func NewNERMetric(effectiveCauses ...string) *NERMetric {
+	if len(effectiveCauses) == 0 {
+		effectiveCauses = NERDefaultEffectiveCauses
+	}
+	return &NERMetric{effectiveCauses: utils.NewStringMap(effectiveCauses...)}
+}
+
+func (ner *NERMetric) isEffective(cdr *QCdr) bool {
+	return !cdr.AnswerTime.IsZero() || ner.effectiveCauses[cdr.DisconnectCause]
+}
+
+func (ner *NERMetric) AddCdr(cdr *QCdr) {
+	if ner.isEffective(cdr) {
+		ner.effective += 1
+	}
+	ner.count += 1
+}
+
+func (ner *NERMetric) RemoveCdr(cdr *QCdr) {
+	if ner.isEffective(cdr) {
+		ner.effective -= 1
+	}
+	ner.count -= 1
+}
+
+func (ner *NERMetric) GetValue() float64 {
+	if ner.count == 0 {
+		return STATS_NA
+	}
+	val := ner.effective / ner.count * 100
+	return utils.Round(val, globalRoundingDecimals, utils.ROUNDING_MIDDLE)
+}
+
+// Source of the durations a PercentileMetric is computed on
+type percentileSource struct {
+	fromQCdr   func(*QCdr) (time.Duration, bool) // returns false if the cdr should not be considered
+	fromBucket func(*StatsBucket) map[string]float64
+}
+
+var answeredUsageSource = &percentileSource{
+	fromQCdr:   func(cdr *QCdr) (time.Duration, bool) { return cdr.Usage, !cdr.AnswerTime.IsZero() },
+	fromBucket: func(sb *StatsBucket) map[string]float64 { return sb.Usages },
+}
+
+var pddSource = &percentileSource{
+	fromQCdr:   func(cdr *QCdr) (time.Duration, bool) { return cdr.Pdd, cdr.Pdd != 0 },
+	fromBucket: func(sb *StatsBucket) map[string]float64 { return sb.Pdds },
+}
+
+// Pxx - Percentile (nearest rank) of the durations, in seconds
+// eg: P95_CD is the call duration 95% of the answered calls are not exceeding
+type PercentileMetric struct {
+	percentile float64
+	source     *percentileSource
+	counts     map[time.Duration]float64
+	values     []time.Duration // distinct values, kept sorted
+	total      float64
+}
+
+func newPercentileMetric(percentile float64, source *percentileSource) *PercentileMetric {
+	return &PercentileMetric{percentile: percentile, source: source, counts: make(map[time.Duration]float64)}
+}
+
+func (pm *PercentileMetric) add(val time.Duration, cnt float64) {
+	if _, hasIt := pm.counts[val]; !hasIt {
+		idx := sort.Search(len(pm.values), func(i int) bool { return pm.values[i] >= val })
+		pm.values = append(pm.values, 0)
+		copy(pm.values[idx+1:], pm.values[idx:])
+		pm.values[idx] = val
+	}
+	pm.counts[val] += cnt
+	pm.total += cnt
+}
+
+func (pm *PercentileMetric) remove(val time.Duration, cnt float64) {
+	if _, hasIt := pm.counts[val]; !hasIt {
+		return
+	}
+	pm.counts[val] -= cnt
+	pm.total -= cnt
+	if pm.counts[val] > 0 {
+		return
+	}
+	delete(pm.counts, val)
+	idx := sort.Search(len(pm.values), func(i int) bool { return pm.values[i] >= val })
+	pm.values = append(pm.values[:idx], pm.values[idx+1:]...)
+}
+
+func (pm *PercentileMetric) AddCdr(cdr *QCdr) {
+	if val, ok := pm.source.fromQCdr(cdr); ok {
+		pm.add(val, 1)
+	}
+}
+
+func (pm *PercentileMetric) RemoveCdr(cdr *QCdr) {
+	if val, ok := pm.source.fromQCdr(cdr); ok {
+		pm.remove(val, 1)
+	}
+}
+
+func (pm *PercentileMetric) GetValue() float64 {
+	if pm.total <= 0 {
+		return STATS_NA
+	}
+	rank := math.Ceil(pm.percentile / 100 * pm.total)
+	if rank < 1 {
+		rank = 1
+	}
+	var cumulated float64
+	for _, val := range pm.values {
+		cumulated += pm.counts[val]
+		if cumulated >= rank {
+			return utils.Round(val.Seconds(), globalRoundingDecimals, utils.ROUNDING_MIDDLE)
+		}
+	}
+	return utils.Round(pm.values[len(pm.values)-1].Seconds(), globalRoundingDecimals, utils.ROUNDING_MIDDLE)
+}
+
+// SCPM - Supplier Cost Per Minute
+// the sum of cost of answered calls divided by their duration in minutes, computed per supplier and overall
+type SCPMMetric struct {
+	costs  map[string]float64
+	usages map[string]time.Duration
+}
+
+func NewSCPMMetric() *SCPMMetric {
+	return &SCPMMetric{costs: make(map[string]float64), usages: make(map[string]time.Duration)}
+}
+
+func (scpm *SCPMMetric) AddCdr(cdr *QCdr) {
+	if cdr.AnswerTime.IsZero() || cdr.Cost < 0 {
+		return
+	}
+	scpm.costs[cdr.Supplier] += cdr.Cost
+	scpm.usages[cdr.Supplier] += cdr.Usage
+}
+
+func (scpm *SCPMMetric) RemoveCdr(cdr *QCdr) {
+	if cdr.AnswerTime.IsZero() || cdr.Cost < 0 {
+		return
+	}
+	if _, hasIt := scpm.usages[cdr.Supplier]; !hasIt {
+		return
+	}
+	scpm.costs[cdr.Supplier] -= cdr.Cost
+	scpm.usages[cdr.Supplier] -= cdr.Usage
+	if scpm.usages[cdr.Supplier] <= 0 {
+		delete(scpm.costs, cdr.Supplier)
+		delete(scpm.usages, cdr.Supplier)
+	}
+}
+
+func (scpm *SCPMMetric) GetValue() float64 {
+	var cost float64
+	var usage time.Duration
+	for supplier, supplierUsage := range scpm.usages {
+		cost += scpm.costs[supplier]
+		usage += supplierUsage
+	}
+	if usage == 0 {
+		return STATS_NA
+	}
+	return utils.Round(cost/usage.Minutes(), globalRoundingDecimals, utils.ROUNDING_MIDDLE)
+}
+
+func (scpm *SCPMMetric) GetValues() map[string]float64 {
+	values := make(map[string]float64, len(scpm.usages))
+	for supplier, usage := range scpm.usages {
+		if supplier == "" || usage == 0 {
+			continue
+		}
+		values[supplier] = utils.Round(scpm.costs[supplier]/usage.Minutes(), globalRoundingDecimals, utils.ROUNDING_MIDDLE)
+	}
+	return values
+}
@@ -0,0 +1,125 @@
+/*
+Rating system designed to be used in VoIP Carriers World
+Copyright (C) 2012-2015 ITsysCOM
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>
+*/
+
+package engine
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/cgrates/cgrates/utils"
+)
+
+type testCountMetric struct {
+	count float64
+}
+
+func (tcm *testCountMetric) AddCdr(*QCdr)      { tcm.count += 1 }
+func (tcm *testCountMetric) RemoveCdr(*QCdr)   { tcm.count -= 1 }
+func (tcm *testCountMetric) GetValue() float64 { return tcm.count }
+
+func TestMetricsRegister(t *testing.T) {
+	if err := RegisterMetric(ASR, func() Metric { return &ASRMetric{} }); err != utils.ErrExists {
+		t.Error("Expecting ErrExists, received: ", err)
+	}
+	if err := RegisterMetric("TEST_COUNT", func() Metric { return &testCountMetric{} }); err != nil {
+		t.Error(err)
+	}
+	if METRIC_TRIGGER_MAP["*max_test_count"] != "TEST_COUNT" || METRIC_TRIGGER_MAP["*min_test_count"] != "TEST_COUNT" {
+		t.Errorf("Unexpected trigger map: %+v", METRIC_TRIGGER_MAP)
+	}
+	if !utils.IsSliceMember(RegisteredMetrics(), "TEST_COUNT") {
+		t.Errorf("Metric not registered: %+v", RegisteredMetrics())
+	}
+	sq := NewStatsQueue(&CdrStats{Metrics: []string{"TEST_COUNT", "UNKNOWN"}})
+	if len(sq.metrics) != 1 {
+		t.Errorf("Unexpected metrics: %+v", sq.metrics)
+	}
+	sq.AppendCDR(&CDR{Usage: time.Second})
+	sq.AppendCDR(&CDR{Usage: time.Second})
+	if s := sq.GetStats(); s["TEST_COUNT"] != 2 {
+		t.Errorf("Unexpected stats: %+v", s)
+	}
+}
+
+func TestMetricsPercentile(t *testing.T) {
+	sq := NewStatsQueue(&CdrStats{Metrics: []string{P50_CD, P95_CD, P99_CD, P50_PDD, P95_PDD}, QueueLength: 100})
+	answerTime := time.Date(2014, 7, 14, 14, 25, 0, 0, time.UTC)
+	for i := 1; i <= 100; i++ {
+		sq.AppendCDR(&CDR{AnswerTime: answerTime, Usage: time.Duration(i) * time.Second, PDD: time.Duration(101-i) * 100 * time.Millisecond})
+	}
+	sq.AppendCDR(&CDR{Usage: 1000 * time.Second}) // not answered, evicts first cdr out of the queue
+	eStats := map[string]float64{P50_CD: 51, P95_CD: 96, P99_CD: 100, P50_PDD: 5, P95_PDD: 9.5}
+	if s := sq.GetStats(); !reflect.DeepEqual(eStats, s) {
+		t.Errorf("Expecting: %+v, received: %+v", eStats, s)
+	}
+}
+
+func TestMetricsPercentileEmpty(t *testing.T) {
+	pm := newPercentileMetric(95, answeredUsageSource)
+	if pm.GetValue() != STATS_NA {
+		t.Error("Expecting STATS_NA, received: ", pm.GetValue())
+	}
+	qcdr := &QCdr{AnswerTime: time.Now(), Usage: 10 * time.Second}
+	pm.AddCdr(qcdr)
+	if pm.GetValue() != 10 {
+		t.Error("Unexpected value: ", pm.GetValue())
+	}
+	pm.RemoveCdr(qcdr)
+	if pm.GetValue() != STATS_NA {
+		t.Error("Expecting STATS_NA, received: ", pm.GetValue())
+	}
+}
+
+func TestMetricsNER(t *testing.T) {
+	ner := NewNERMetric()
+	answered := &QCdr{AnswerTime: time.Now(), DisconnectCause: "NORMAL_CLEARING"}
+	busy := &QCdr{DisconnectCause: "USER_BUSY"}
+	failed := &QCdr{DisconnectCause: "NETWORK_OUT_OF_ORDER"}
+	ner.AddCdr(answered)
+	ner.AddCdr(busy)
+	ner.AddCdr(failed)
+	ner.AddCdr(failed)
+	if ner.GetValue() != 50 {
+		t.Error("Unexpected value: ", ner.GetValue())
+	}
+	ner.RemoveCdr(failed)
+	if val := ner.GetValue(); val != utils.Round(200.0/3.0, globalRoundingDecimals, utils.ROUNDING_MIDDLE) {
+		t.Error("Unexpected value: ", val)
+	}
+	custom := NewNERMetric("503")
+	custom.AddCdr(&QCdr{DisconnectCause: "503"})
+	custom.AddCdr(busy)
+	if custom.GetValue() != 50 {
+		t.Error("Unexpected value: ", custom.GetValue())
+	}
+}
+
+func TestMetricsSCPM(t *testing.T) {
+	sq := NewStatsQueue(&CdrStats{Metrics: []string{SCPM}})
+	answerTime := time.Date(2014, 7, 14, 14, 25, 0, 0, time.UTC)
+	sq.AppendCDR(&CDR{AnswerTime: answerTime, Usage: time.Minute, Cost: 2, Supplier: "supplier1"})
+	sq.AppendCDR(&CDR{AnswerTime: answerTime, Usage: 3 * time.Minute, Cost: 3, Supplier: "supplier1"})
+	sq.AppendCDR(&CDR{AnswerTime: answerTime, Usage: 2 * time.Minute, Cost: 1, Supplier: "supplier2"})
+	sq.AppendCDR(&CDR{Usage: 2 * time.Minute, Cost: 10, Supplier: "supplier2"}) // not answered
+	eStats := map[string]float64{SCPM: 1, SCPM + ":supplier1": 1.25, SCPM + ":supplier2": 0.5}
+	if s := sq.GetStats(); !reflect.DeepEqual(eStats, s) {
+		t.Errorf("Expecting: %+v, received: %+v", eStats, s)
+	}
+}
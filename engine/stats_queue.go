@@ -29,38 +29,34 @@ import (
 
 type StatsQueue struct {
 	Cdrs    []*QCdr
+	Buckets []*StatsBucket // Used instead of Cdrs when conf.BucketInterval is set
 	conf    *CdrStats
 	metrics map[string]Metric
 	mux     sync.Mutex
 	dirty   bool
 }
 
-var METRIC_TRIGGER_MAP = map[string]string{
-	"*min_asr": ASR,
-	"*max_asr": ASR,
-	"*min_pdd": PDD,
-	"*max_pdd": PDD,
-	"*min_acd": ACD,
-	"*max_acd": ACD,
-	"*min_tcd": TCD,
-	"*max_tcd": TCD,
-	"*min_acc": ACC,
-	"*max_acc": ACC,
-	"*min_tcc": TCC,
-	"*max_tcc": TCC,
-	"*min_ddc": DDC,
-	"*max_ddc": DDC,
+// Populated by RegisterMetric
+var METRIC_TRIGGER_MAP = make(map[string]string)
+var metricTriggerMux sync.RWMutex
+
+func metricForTrigger(thresholdType string) string {
+	metricTriggerMux.RLock()
+	defer metricTriggerMux.RUnlock()
+	return METRIC_TRIGGER_MAP[thresholdType]
 }
 
 // Simplified cdr structure containing only the necessary info
 type QCdr struct {
-	SetupTime  time.Time
-	AnswerTime time.Time
-	EventTime  time.Time
-	Pdd        time.Duration
-	Usage      time.Duration
-	Cost       float64
-	Dest       string
+	SetupTime       time.Time
+	AnswerTime      time.Time
+	EventTime       time.Time
+	Pdd             time.Duration
+	Usage           time.Duration
+	Cost            float64
+	Dest            string
+	Supplier        string
+	DisconnectCause string
 }
 
 func NewStatsQueue(conf *CdrStats) *StatsQueue {
@@ -81,12 +77,25 @@ func (sq *StatsQueue) UpdateConf(conf *CdrStats) {
 		return
 	}
 	sq.conf = conf
-	sq.Cdrs = make([]*QCdr, 0)
-	sq.metrics = make(map[string]Metric, len(conf.Metrics))
+	sq.resetQueue()
 	sq.dirty = true
-	for _, m := range conf.Metrics {
-		if metric := CreateMetric(m); metric != nil {
-			sq.metrics[m] = metric
+}
+
+// resetQueue drops the queued items and starts with fresh metrics
+func (sq *StatsQueue) resetQueue() {
+	sq.Cdrs = make([]*QCdr, 0)
+	sq.Buckets = make([]*StatsBucket, 0)
+	sq.metrics = newMetrics(sq.conf.Metrics)
+	if sq.conf.BucketInterval == 0 {
+		return
+	}
+	if sq.conf.QueueLength != 0 {
+		utils.Logger.Warning(fmt.Sprintf("<CDRStats> QueueLength not supported with buckets, ignoring it for queue %s", sq.conf.Id))
+	}
+	for mID, metric := range sq.metrics {
+		if _, canBucket := metric.(BucketMetric); !canBucket {
+			utils.Logger.Warning(fmt.Sprintf("<CDRStats> Metric %s does not support buckets, ignoring it for queue %s", mID, sq.conf.Id))
+			delete(sq.metrics, mID)
 		}
 	}
 }
@@ -112,6 +121,17 @@ func (sq *StatsQueue) Save(rdb RatingStorage, adb AccountingStorage) {
 func (sq *StatsQueue) Load(saved *StatsQueue) {
 	sq.mux.Lock()
 	defer sq.mux.Unlock()
+	if sq.conf.BucketInterval != 0 {
+		for _, sb := range saved.Buckets {
+			sq.Buckets = append(sq.Buckets, sb)
+			sq.addBucketToMetrics(sb)
+		}
+		for _, qcdr := range saved.Cdrs { // queue saved before moving to buckets
+			sq.appendQcdr(qcdr, false)
+		}
+		sq.purgeObsoleteCdrs()
+		return
+	}
 	sq.Cdrs = saved.Cdrs
 	for _, qcdr := range saved.Cdrs {
 		sq.appendQcdr(qcdr, false)
@@ -131,7 +151,11 @@ func (sq *StatsQueue) AppendCDR(cdr *CDR) *QCdr {
 
 func (sq *StatsQueue) appendQcdr(qcdr *QCdr, runTrigger bool) {
 	qcdr.EventTime = time.Now() //used for TimeWindow
-	sq.Cdrs = append(sq.Cdrs, qcdr)
+	if sq.conf.BucketInterval != 0 {
+		sq.addToBucket(qcdr)
+	} else {
+		sq.Cdrs = append(sq.Cdrs, qcdr)
+	}
 	sq.addToMetrics(qcdr)
 	sq.purgeObsoleteCdrs()
 	sq.dirty = true
@@ -151,18 +175,18 @@ func (sq *StatsQueue) appendQcdr(qcdr *QCdr, runTrigger bool) {
 				continue
 			}
 
-			if at.MinQueuedItems > 0 && len(sq.Cdrs) < at.MinQueuedItems {
+			if at.MinQueuedItems > 0 && sq.queuedItems() < at.MinQueuedItems {
 				continue
 			}
 			if strings.HasPrefix(at.ThresholdType, "*min_") {
-				if value, ok := stats[METRIC_TRIGGER_MAP[at.ThresholdType]]; ok {
+				if value, ok := stats[metricForTrigger(at.ThresholdType)]; ok {
 					if value > STATS_NA && value <= at.ThresholdValue {
 						at.Execute(nil, sq.Triggered(at))
 					}
 				}
 			}
 			if strings.HasPrefix(at.ThresholdType, "*max_") {
-				if value, ok := stats[METRIC_TRIGGER_MAP[at.ThresholdType]]; ok {
+				if value, ok := stats[metricForTrigger(at.ThresholdType)]; ok {
 					if value > STATS_NA && value >= at.ThresholdValue {
 						at.Execute(nil, sq.Triggered(at))
 					}
@@ -185,18 +209,75 @@ func (sq *StatsQueue) removeFromMetrics(cdr *QCdr) {
 	}
 }
 
+// addToBucket aggregates the cdr into the bucket covering its EventTime, creating it if needed
+func (sq *StatsQueue) addToBucket(qcdr *QCdr) {
+	startTime := qcdr.EventTime.Truncate(sq.conf.BucketInterval)
+	if len(sq.Buckets) == 0 || !sq.Buckets[len(sq.Buckets)-1].StartTime.Equal(startTime) {
+		sq.Buckets = append(sq.Buckets, NewStatsBucket(startTime))
+	}
+	sq.Buckets[len(sq.Buckets)-1].AddQCdr(qcdr)
+}
+
+func (sq *StatsQueue) addBucketToMetrics(sb *StatsBucket) {
+	for _, metric := range sq.metrics {
+		if bMetric, canBucket := metric.(BucketMetric); canBucket {
+			bMetric.AddBucket(sb)
+		}
+	}
+}
+
+func (sq *StatsQueue) removeBucketFromMetrics(sb *StatsBucket) {
+	for _, metric := range sq.metrics {
+		if bMetric, canBucket := metric.(BucketMetric); canBucket {
+			bMetric.RemoveBucket(sb)
+		}
+	}
+}
+
+// Number of CDRs currently considered by the queue
+func (sq *StatsQueue) queuedItems() int {
+	if sq.conf.BucketInterval == 0 {
+		return len(sq.Cdrs)
+	}
+	var cnt float64
+	for _, sb := range sq.Buckets {
+		cnt += sb.Count
+	}
+	return int(cnt)
+}
+
+// SetupTime of the last CDR in the queue
+func (sq *StatsQueue) lastSetupTime() time.Time {
+	if sq.conf.BucketInterval == 0 {
+		if len(sq.Cdrs) == 0 {
+			return time.Time{}
+		}
+		return sq.Cdrs[len(sq.Cdrs)-1].SetupTime
+	}
+	if len(sq.Buckets) == 0 {
+		return time.Time{}
+	}
+	return sq.Buckets[len(sq.Buckets)-1].LastSetupTime
+}
+
 func (sq *StatsQueue) simplifyCdr(cdr *CDR) *QCdr {
 	return &QCdr{
-		SetupTime:  cdr.SetupTime,
-		AnswerTime: cdr.AnswerTime,
-		Pdd:        cdr.PDD,
-		Usage:      cdr.Usage,
-		Cost:       cdr.Cost,
-		Dest:       cdr.Destination,
+		SetupTime:       cdr.SetupTime,
+		AnswerTime:      cdr.AnswerTime,
+		Pdd:             cdr.PDD,
+		Usage:           cdr.Usage,
+		Cost:            cdr.Cost,
+		Dest:            cdr.Destination,
+		Supplier:        cdr.Supplier,
+		DisconnectCause: cdr.DisconnectCause,
 	}
 }
 
 func (sq *StatsQueue) purgeObsoleteCdrs() {
+	if sq.conf.BucketInterval != 0 {
+		sq.purgeObsoleteBuckets()
+		return
+	}
 	if sq.conf.QueueLength > 0 {
 		currentLength := len(sq.Cdrs)
 		if currentLength > sq.conf.QueueLength {
@@ -226,6 +307,34 @@ func (sq *StatsQueue) purgeObsoleteCdrs() {
 	}
 }
 
+// Buckets are removed once all of their interval is out of the TimeWindow, QueueLength is not considered.
+// The expired CDRs of the bucket at the edge of the TimeWindow are removed one by one, so the metrics
+// cover the same CDRs as without buckets.
+func (sq *StatsQueue) purgeObsoleteBuckets() {
+	if sq.conf.TimeWindow == 0 {
+		return
+	}
+	now := time.Now()
+	index := 0
+	for ; index < len(sq.Buckets); index++ {
+		sb := sq.Buckets[index]
+		if now.Sub(sb.StartTime.Add(sq.conf.BucketInterval)) <= sq.conf.TimeWindow {
+			break
+		}
+		sq.removeBucketFromMetrics(sb)
+	}
+	if index > 0 {
+		sq.Buckets = sq.Buckets[index:]
+	}
+	if len(sq.Buckets) == 0 {
+		return
+	}
+	sb := sq.Buckets[0] // might be partially out of the TimeWindow
+	for len(sb.Cdrs) != 0 && now.Sub(sb.Cdrs[0].EventTime) > sq.conf.TimeWindow {
+		sq.removeFromMetrics(sb.RemoveFirstQCdr())
+	}
+}
+
 func (sq *StatsQueue) GetStats() map[string]float64 {
 	sq.mux.Lock()
 	defer sq.mux.Unlock()
@@ -237,6 +346,11 @@ func (sq *StatsQueue) getStats() map[string]float64 {
 	stat := make(map[string]float64, len(sq.metrics))
 	for key, metric := range sq.metrics {
 		stat[key] = metric.GetValue()
+		if multiMetric, isMulti := metric.(MultiValueMetric); isMulti {
+			for subKey, val := range multiMetric.GetValues() {
+				stat[key+utils.CONCATENATED_KEY_SEP+subKey] = val
+			}
+		}
 	}
 	return stat
 }
@@ -19,6 +19,7 @@ along with this program.  If not, see <http://www.gnu.org/licenses/>
 package engine
 
 import (
+	"reflect"
 	"testing"
 	"time"
 
@@ -554,3 +555,118 @@ func TestStatsPurgeLength(t *testing.T) {
 		t.Errorf("Error getting stats: %+v", s)
 	}
 }
+
+func testStatsBucketsCdrs() []*CDR {
+	answerTime := time.Date(2014, 7, 14, 14, 25, 0, 0, time.UTC)
+	return []*CDR{
+		&CDR{SetupTime: answerTime, AnswerTime: answerTime, Usage: 10 * time.Second, PDD: 2 * time.Second, Cost: 1,
+			Destination: "1001", Supplier: "supplier1", DisconnectCause: "NORMAL_CLEARING"},
+		&CDR{SetupTime: answerTime, AnswerTime: answerTime, Usage: 20 * time.Second, PDD: 3 * time.Second, Cost: 2,
+			Destination: "1002", Supplier: "supplier2", DisconnectCause: "NORMAL_CLEARING"},
+		&CDR{SetupTime: answerTime, Usage: 0, PDD: 5 * time.Second, Destination: "1002", Supplier: "supplier1", DisconnectCause: "USER_BUSY"},
+		&CDR{SetupTime: answerTime, Usage: 0, Destination: "1003", Supplier: "supplier2", DisconnectCause: "NETWORK_OUT_OF_ORDER"},
+		&CDR{SetupTime: answerTime, AnswerTime: answerTime, Usage: 20 * time.Second, Cost: 3,
+			Destination: "1001", Supplier: "supplier1", DisconnectCause: "NORMAL_CLEARING"},
+	}
+}
+
+func TestStatsBucketsSameAsCdrs(t *testing.T) {
+	metrics := []string{ASR, ACD, TCD, ACC, TCC, PDD, DDC, NER, P50_CD, P95_PDD, SCPM}
+	sqCdrs := NewStatsQueue(&CdrStats{Metrics: metrics, TimeWindow: time.Hour})
+	sqBuckets := NewStatsQueue(&CdrStats{Metrics: metrics, TimeWindow: time.Hour, BucketInterval: time.Minute})
+	for _, cdr := range testStatsBucketsCdrs() {
+		sqCdrs.AppendCDR(cdr)
+		sqBuckets.AppendCDR(cdr)
+	}
+	if len(sqBuckets.Cdrs) != 0 || len(sqBuckets.Buckets) == 0 {
+		t.Errorf("Unexpected queue, cdrs: %d, buckets: %d", len(sqBuckets.Cdrs), len(sqBuckets.Buckets))
+	}
+	if sqBuckets.queuedItems() != 5 {
+		t.Error("Unexpected queued items: ", sqBuckets.queuedItems())
+	}
+	if eStats, stats := sqCdrs.GetStats(), sqBuckets.GetStats(); !reflect.DeepEqual(eStats, stats) {
+		t.Errorf("Expecting: %+v, received: %+v", eStats, stats)
+	}
+}
+
+func TestStatsBucketsSaveLoad(t *testing.T) {
+	conf := &CdrStats{Id: "BUCKETS", Metrics: []string{ASR, ACD, DDC, NER, P50_CD, SCPM}, TimeWindow: time.Hour, BucketInterval: time.Second}
+	sq := NewStatsQueue(conf)
+	for _, cdr := range testStatsBucketsCdrs() {
+		sq.AppendCDR(cdr)
+	}
+	sq.Save(ratingStorage, accountingStorage)
+	saved, err := accountingStorage.GetCdrStatsQueue(conf.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	recovered := NewStatsQueue(conf)
+	recovered.Load(saved)
+	if eStats, stats := sq.GetStats(), recovered.GetStats(); !reflect.DeepEqual(eStats, stats) {
+		t.Errorf("Expecting: %+v, received: %+v", eStats, stats)
+	}
+}
+
+func TestStatsBucketsPurge(t *testing.T) {
+	sq := NewStatsQueue(&CdrStats{Metrics: []string{ASR, TCD}, TimeWindow: 30 * time.Minute, BucketInterval: time.Minute})
+	cdrs := testStatsBucketsCdrs()
+	sq.AppendCDR(cdrs[0])
+	sq.AppendCDR(cdrs[3])
+	sq.Buckets[0].StartTime = sq.Buckets[0].StartTime.Add(-time.Hour) // age the bucket out of the window
+	sq.AppendCDR(cdrs[1])
+	if len(sq.Buckets) != 1 {
+		t.Fatalf("Unexpected buckets: %s", utils.ToIJSON(sq.Buckets))
+	}
+	if s := sq.GetStats(); s[ASR] != 100 || s[TCD] != 20 {
+		t.Errorf("Unexpected stats: %+v", s)
+	}
+}
+
+func TestStatsBucketsWindowEdge(t *testing.T) {
+	metrics := []string{ASR, ACD, TCD, ACC, TCC, PDD, DDC, NER, P50_CD, P95_PDD, SCPM}
+	sqCdrs := NewStatsQueue(&CdrStats{Metrics: metrics, TimeWindow: time.Hour})
+	sqBuckets := NewStatsQueue(&CdrStats{Metrics: metrics, TimeWindow: time.Hour, BucketInterval: 24 * time.Hour})
+	for _, cdr := range testStatsBucketsCdrs() {
+		sqCdrs.AppendCDR(cdr)
+		sqBuckets.AppendCDR(cdr)
+	}
+	if len(sqBuckets.Buckets) != 1 {
+		t.Fatalf("Unexpected buckets: %s", utils.ToIJSON(sqBuckets.Buckets))
+	}
+	// First CDRs out of the window while their bucket is not
+	for i := 0; i < 2; i++ {
+		sqCdrs.Cdrs[i].EventTime = sqCdrs.Cdrs[i].EventTime.Add(-2 * time.Hour)
+		sqBuckets.Buckets[0].Cdrs[i].EventTime = sqBuckets.Buckets[0].Cdrs[i].EventTime.Add(-2 * time.Hour)
+	}
+	if eStats, stats := sqCdrs.GetStats(), sqBuckets.GetStats(); !reflect.DeepEqual(eStats, stats) {
+		t.Errorf("Expecting: %+v, received: %+v", eStats, stats)
+	}
+	if len(sqBuckets.Buckets) != 1 || sqBuckets.queuedItems() != 3 {
+		t.Errorf("Unexpected buckets: %s", utils.ToIJSON(sqBuckets.Buckets))
+	}
+}
+
+func TestStatsBucketsUpdateCdrStats(t *testing.T) {
+	cs := &CdrStats{Id: "BUCKETS"}
+	if err := UpdateCdrStats(cs, nil, &utils.TPCdrStat{TimeWindow: "1h", BucketInterval: "1m"}, ""); err != nil {
+		t.Error(err)
+	} else if cs.TimeWindow != time.Hour || cs.BucketInterval != time.Minute {
+		t.Errorf("Unexpected cdr stats: %+v", cs)
+	}
+	cs = &CdrStats{Id: "BUCKETS_QUEUE_LENGTH"}
+	if err := UpdateCdrStats(cs, nil, &utils.TPCdrStat{QueueLength: "10", TimeWindow: "1h", BucketInterval: "1m"}, ""); err == nil {
+		t.Error("QueueLength accepted together with BucketInterval")
+	}
+	for _, bucketInterval := range []string{"1x", "0s", "-1m"} {
+		cs = &CdrStats{Id: "BUCKETS_INVALID"}
+		if err := UpdateCdrStats(cs, nil, &utils.TPCdrStat{TimeWindow: "1h", BucketInterval: bucketInterval}, ""); err == nil {
+			t.Errorf("BucketInterval accepted: %s", bucketInterval)
+		}
+	}
+	cs = &CdrStats{Id: "CDRS"}
+	if err := UpdateCdrStats(cs, nil, &utils.TPCdrStat{QueueLength: "10", TimeWindow: "1h"}, ""); err != nil {
+		t.Error(err)
+	} else if cs.TimeWindow != time.Hour || cs.BucketInterval != 0 || cs.QueueLength != 10 {
+		t.Errorf("Unexpected cdr stats: %+v", cs)
+	}
+}
@@ -15,26 +15,26 @@ type CSVStorage struct {
 	readerFunc func(string, rune, int) (*csv.Reader, *os.File, error)
 	// file names
 	destinationsFn, ratesFn, destinationratesFn, timingsFn, destinationratetimingsFn, ratingprofilesFn,
-	sharedgroupsFn, lcrFn, actionsFn, actiontimingsFn, actiontriggersFn, accountactionsFn, derivedChargersFn, cdrStatsFn, usersFn, aliasesFn, resLimitsFn string
+	sharedgroupsFn, lcrFn, actionsFn, actiontimingsFn, actiontriggersFn, accountactionsFn, derivedChargersFn, cdrStatsFn, usersFn, aliasesFn, resLimitsFn, exchangeRatesFn, taxRulesFn string
 }
 
 func NewFileCSVStorage(sep rune,
 	destinationsFn, timingsFn, ratesFn, destinationratesFn, destinationratetimingsFn, ratingprofilesFn, sharedgroupsFn, lcrFn,
-	actionsFn, actiontimingsFn, actiontriggersFn, accountactionsFn, derivedChargersFn, cdrStatsFn, usersFn, aliasesFn, resLimitsFn string) *CSVStorage {
+	actionsFn, actiontimingsFn, actiontriggersFn, accountactionsFn, derivedChargersFn, cdrStatsFn, usersFn, aliasesFn, resLimitsFn, exchangeRatesFn, taxRulesFn string) *CSVStorage {
 	c := new(CSVStorage)
 	c.sep = sep
 	c.readerFunc = openFileCSVStorage
 	c.destinationsFn, c.timingsFn, c.ratesFn, c.destinationratesFn, c.destinationratetimingsFn, c.ratingprofilesFn,
-		c.sharedgroupsFn, c.lcrFn, c.actionsFn, c.actiontimingsFn, c.actiontriggersFn, c.accountactionsFn, c.derivedChargersFn, c.cdrStatsFn, c.usersFn, c.aliasesFn, c.resLimitsFn = destinationsFn, timingsFn,
-		ratesFn, destinationratesFn, destinationratetimingsFn, ratingprofilesFn, sharedgroupsFn, lcrFn, actionsFn, actiontimingsFn, actiontriggersFn, accountactionsFn, derivedChargersFn, cdrStatsFn, usersFn, aliasesFn, resLimitsFn
+		c.sharedgroupsFn, c.lcrFn, c.actionsFn, c.actiontimingsFn, c.actiontriggersFn, c.accountactionsFn, c.derivedChargersFn, c.cdrStatsFn, c.usersFn, c.aliasesFn, c.resLimitsFn, c.exchangeRatesFn, c.taxRulesFn = destinationsFn, timingsFn,
+		ratesFn, destinationratesFn, destinationratetimingsFn, ratingprofilesFn, sharedgroupsFn, lcrFn, actionsFn, actiontimingsFn, actiontriggersFn, accountactionsFn, derivedChargersFn, cdrStatsFn, usersFn, aliasesFn, resLimitsFn, exchangeRatesFn, taxRulesFn
 	return c
 }
 
 func NewStringCSVStorage(sep rune,
 	destinationsFn, timingsFn, ratesFn, destinationratesFn, destinationratetimingsFn, ratingprofilesFn, sharedgroupsFn, lcrFn,
-	actionsFn, actiontimingsFn, actiontriggersFn, accountactionsFn, derivedChargersFn, cdrStatsFn, usersFn, aliasesFn, resLimitsFn string) *CSVStorage {
+	actionsFn, actiontimingsFn, actiontriggersFn, accountactionsFn, derivedChargersFn, cdrStatsFn, usersFn, aliasesFn, resLimitsFn, exchangeRatesFn, taxRulesFn string) *CSVStorage {
 	c := NewFileCSVStorage(sep, destinationsFn, timingsFn, ratesFn, destinationratesFn, destinationratetimingsFn,
-		ratingprofilesFn, sharedgroupsFn, lcrFn, actionsFn, actiontimingsFn, actiontriggersFn, accountactionsFn, derivedChargersFn, cdrStatsFn, usersFn, aliasesFn, resLimitsFn)
+		ratingprofilesFn, sharedgroupsFn, lcrFn, actionsFn, actiontimingsFn, actiontriggersFn, accountactionsFn, derivedChargersFn, cdrStatsFn, usersFn, aliasesFn, resLimitsFn, exchangeRatesFn, taxRulesFn)
 	c.readerFunc = openStringCSVStorage
 	return c
 }
@@ -174,7 +174,7 @@ func (csvs *CSVStorage) GetTpDestinationRates(tpid, tag string, p *utils.Paginat
 }
 
 func (csvs *CSVStorage) GetTpRatingPlans(tpid, tag string, p *utils.Paginator) ([]TpRatingPlan, error) {
-	csvReader, fp, err := csvs.readerFunc(csvs.destinationratetimingsFn, csvs.sep, getColumnCount(TpRatingPlan{}))
+	csvReader, fp, err := csvs.readerFunc(csvs.destinationratetimingsFn, csvs.sep, getFieldsPerRecord(TpRatingPlan{}))
 	if err != nil {
 		//log.Print("Could not load rate plans file: ", err)
 		// allow writing of the other values
@@ -291,7 +291,7 @@ func (csvs *CSVStorage) GetTpLCRs(filter *TpLcrRule) ([]TpLcrRule, error) {
 }
 
 func (csvs *CSVStorage) GetTpActions(tpid, tag string) ([]TpAction, error) {
-	csvReader, fp, err := csvs.readerFunc(csvs.actionsFn, csvs.sep, getColumnCount(TpAction{}))
+	csvReader, fp, err := csvs.readerFunc(csvs.actionsFn, csvs.sep, getFieldsPerRecord(TpAction{}))
 	if err != nil {
 		//log.Print("Could not load action file: ", err)
 		// allow writing of the other values
@@ -433,7 +433,7 @@ func (csvs *CSVStorage) GetTpDerivedChargers(filter *TpDerivedCharger) ([]TpDeri
 }
 
 func (csvs *CSVStorage) GetTpCdrStats(tpid, tag string) ([]TpCdrstat, error) {
-	csvReader, fp, err := csvs.readerFunc(csvs.cdrStatsFn, csvs.sep, getColumnCount(TpCdrstat{}))
+	csvReader, fp, err := csvs.readerFunc(csvs.cdrStatsFn, csvs.sep, getFieldsPerRecord(TpCdrstat{}))
 	if err != nil {
 		//log.Print("Could not load cdr stats file: ", err)
 		// allow writing of the other values
@@ -520,6 +520,62 @@ func (csvs *CSVStorage) GetTpAliases(filter *TpAlias) ([]TpAlias, error) {
 	return tpAliases, nil
 }
 
+func (csvs *CSVStorage) GetTpExchangeRates(tpid, tag string) ([]TpExchangeRate, error) {
+	csvReader, fp, err := csvs.readerFunc(csvs.exchangeRatesFn, csvs.sep, getColumnCount(TpExchangeRate{}))
+	if err != nil {
+		//log.Print("Could not load exchange rates file: ", err)
+		// allow writing of the other values
+		return nil, nil
+	}
+	if fp != nil {
+		defer fp.Close()
+	}
+	var tpExchangeRates []TpExchangeRate
+	for record, err := csvReader.Read(); err != io.EOF; record, err = csvReader.Read() {
+		if err != nil {
+			log.Print("bad line in exchange rates csv: ", err)
+			return nil, err
+		}
+		if tpExr, err := csvLoad(TpExchangeRate{}, record); err != nil {
+			log.Print("error loading exchange rate: ", err)
+			return nil, err
+		} else {
+			exr := tpExr.(TpExchangeRate)
+			exr.Tpid = tpid
+			tpExchangeRates = append(tpExchangeRates, exr)
+		}
+	}
+	return tpExchangeRates, nil
+}
+
+func (csvs *CSVStorage) GetTpTaxRules(tpid, tag string) ([]TpTaxRule, error) {
+	csvReader, fp, err := csvs.readerFunc(csvs.taxRulesFn, csvs.sep, getColumnCount(TpTaxRule{}))
+	if err != nil {
+		//log.Print("Could not load tax rules file: ", err)
+		// allow writing of the other values
+		return nil, nil
+	}
+	if fp != nil {
+		defer fp.Close()
+	}
+	var tpTaxRules []TpTaxRule
+	for record, err := csvReader.Read(); err != io.EOF; record, err = csvReader.Read() {
+		if err != nil {
+			log.Print("bad line in tax rules csv: ", err)
+			return nil, err
+		}
+		if tpTxr, err := csvLoad(TpTaxRule{}, record); err != nil {
+			log.Print("error loading tax rule: ", err)
+			return nil, err
+		} else {
+			txr := tpTxr.(TpTaxRule)
+			txr.Tpid = tpid
+			tpTaxRules = append(tpTaxRules, txr)
+		}
+	}
+	return tpTaxRules, nil
+}
+
 func (csvs *CSVStorage) GetTpResourceLimits(tpid, tag string) (TpResourceLimits, error) {
 	csvReader, fp, err := csvs.readerFunc(csvs.resLimitsFn, csvs.sep, getColumnCount(TpResourceLimit{}))
 	if err != nil {
@@ -24,6 +24,7 @@ import (
 	"encoding/json"
 	"reflect"
 
+	"github.com/cgrates/cgrates/history"
 	"github.com/cgrates/cgrates/utils"
 	"github.com/ugorji/go/codec"
 	"gopkg.in/mgo.v2/bson"
@@ -55,6 +56,13 @@ type RatingStorage interface {
 	SetCdrStats(*CdrStats) error
 	GetCdrStats(string) (*CdrStats, error)
 	GetAllCdrStats() ([]*CdrStats, error)
+	GetExchangeRate(string, bool) (*ExchangeRate, error)
+	SetExchangeRate(*ExchangeRate) error
+	RemoveExchangeRate(string) error
+	GetTaxRules(string, bool) (*TaxRules, error)
+	SetTaxRules(*TaxRules) error
+	RemoveTaxRules(string) error
+	GetAllTaxRules() ([]*TaxRules, error)
 	GetDerivedChargers(string, bool) (*utils.DerivedChargers, error)
 	SetDerivedChargers(string, *utils.DerivedChargers) error
 	GetActions(string, bool) (Actions, error)
@@ -95,6 +103,12 @@ type AccountingStorage interface {
 	GetResourceLimit(string, bool) (*ResourceLimit, error)
 	SetResourceLimit(*ResourceLimit) error
 	RemoveResourceLimit(string) error
+	GetSMGSessionCheckpoints() ([]*SMGSessionCheckpoint, error)
+	SetSMGSessionCheckpoint(*SMGSessionCheckpoint) error
+	AddSMGSessionCallCosts(string, []*CallCost) error
+	RemoveSMGSessionCheckpoint(string) error
+	GetCdrExportJobState(string) (*CdrExportJobState, error)
+	SetCdrExportJobState(*CdrExportJobState) error
 	GetLoadHistory(int, bool) ([]*utils.LoadInstance, error)
 	AddLoadHistory(*utils.LoadInstance, int) error
 	GetStructVersion() (*StructVersion, error)
@@ -107,6 +121,15 @@ type CdrStorage interface {
 	SetSMCost(smc *SMCost) error
 	GetSMCosts(cgrid, runid, originHost, originIDPrfx string) ([]*SMCost, error)
 	GetCDRs(*utils.CDRsFilter, bool) ([]*CDR, int64, error)
+	SetHistoryRecord(*history.ChangeRecord) error
+	GetLastHistoryRecord(objType, objID string) (*history.ChangeRecord, error)
+	GetHistoryRecords(*history.RecordFilter) ([]*history.ChangeRecord, error)
+	BalanceLedgerStorage
+}
+
+type BalanceLedgerStorage interface {
+	SetBalanceLedgerEntries([]*BalanceLedgerEntry) error
+	GetBalanceLedger(*BalanceLedgerFilter) ([]*BalanceLedgerEntry, error)
 }
 
 type LoadStorage interface {
@@ -126,6 +149,8 @@ type LoadReader interface {
 	GetTpRatingPlans(string, string, *utils.Paginator) ([]TpRatingPlan, error)
 	GetTpRatingProfiles(*TpRatingProfile) ([]TpRatingProfile, error)
 	GetTpSharedGroups(string, string) ([]TpSharedGroup, error)
+	GetTpExchangeRates(string, string) ([]TpExchangeRate, error)
+	GetTpTaxRules(string, string) ([]TpTaxRule, error)
 	GetTpCdrStats(string, string) ([]TpCdrstat, error)
 	GetTpLCRs(*TpLcrRule) ([]TpLcrRule, error)
 	GetTpUsers(*TpUser) ([]TpUser, error)
@@ -147,6 +172,8 @@ type LoadWriter interface {
 	SetTpRatingPlans([]TpRatingPlan) error
 	SetTpRatingProfiles([]TpRatingProfile) error
 	SetTpSharedGroups([]TpSharedGroup) error
+	SetTpExchangeRates([]TpExchangeRate) error
+	SetTpTaxRules([]TpTaxRule) error
 	SetTpCdrStats([]TpCdrstat) error
 	SetTpUsers([]TpUser) error
 	SetTpAliases([]TpAlias) error
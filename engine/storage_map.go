@@ -24,6 +24,7 @@ import (
 	"errors"
 	"fmt"
 	"io/ioutil"
+	"strconv"
 	"sync"
 
 	"strings"
@@ -35,6 +36,7 @@ import (
 type MapStorage struct {
 	dict         map[string][]byte
 	tasks        [][]byte
+	ledger       [][]byte
 	ms           Marshaler
 	mu           sync.RWMutex
 	cacheDumpDir string
@@ -75,7 +77,7 @@ func (ms *MapStorage) GetKeysForPrefix(prefix string, skipCache bool) ([]string,
 }
 
 func (ms *MapStorage) CacheRatingAll(loadID string) error {
-	return ms.cacheRating(loadID, nil, nil, nil, nil, nil, nil, nil, nil)
+	return ms.cacheRating(loadID, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil)
 }
 
 func (ms *MapStorage) CacheRatingPrefixes(loadID string, prefixes ...string) error {
@@ -88,6 +90,8 @@ func (ms *MapStorage) CacheRatingPrefixes(loadID string, prefixes ...string) err
 		utils.ACTION_PREFIX:          []string{},
 		utils.ACTION_PLAN_PREFIX:     []string{},
 		utils.SHARED_GROUP_PREFIX:    []string{},
+		utils.ExchangeRatesPrefix:    []string{},
+		utils.TaxRulesPrefix:         []string{},
 	}
 	for _, prefix := range prefixes {
 		if _, found := pm[prefix]; !found {
@@ -95,7 +99,7 @@ func (ms *MapStorage) CacheRatingPrefixes(loadID string, prefixes ...string) err
 		}
 		pm[prefix] = nil
 	}
-	return ms.cacheRating(loadID, pm[utils.DESTINATION_PREFIX], pm[utils.RATING_PLAN_PREFIX], pm[utils.RATING_PROFILE_PREFIX], pm[utils.LCR_PREFIX], pm[utils.DERIVEDCHARGERS_PREFIX], pm[utils.ACTION_PREFIX], pm[utils.ACTION_PLAN_PREFIX], pm[utils.SHARED_GROUP_PREFIX])
+	return ms.cacheRating(loadID, pm[utils.DESTINATION_PREFIX], pm[utils.RATING_PLAN_PREFIX], pm[utils.RATING_PROFILE_PREFIX], pm[utils.LCR_PREFIX], pm[utils.DERIVEDCHARGERS_PREFIX], pm[utils.ACTION_PREFIX], pm[utils.ACTION_PLAN_PREFIX], pm[utils.SHARED_GROUP_PREFIX], pm[utils.ExchangeRatesPrefix], pm[utils.TaxRulesPrefix])
 }
 
 func (ms *MapStorage) CacheRatingPrefixValues(loadID string, prefixes map[string][]string) error {
@@ -108,6 +112,8 @@ func (ms *MapStorage) CacheRatingPrefixValues(loadID string, prefixes map[string
 		utils.ACTION_PREFIX:          []string{},
 		utils.ACTION_PLAN_PREFIX:     []string{},
 		utils.SHARED_GROUP_PREFIX:    []string{},
+		utils.ExchangeRatesPrefix:    []string{},
+		utils.TaxRulesPrefix:         []string{},
 	}
 	for prefix, ids := range prefixes {
 		if _, found := pm[prefix]; !found {
@@ -115,10 +121,10 @@ func (ms *MapStorage) CacheRatingPrefixValues(loadID string, prefixes map[string
 		}
 		pm[prefix] = ids
 	}
-	return ms.cacheRating(loadID, pm[utils.DESTINATION_PREFIX], pm[utils.RATING_PLAN_PREFIX], pm[utils.RATING_PROFILE_PREFIX], pm[utils.LCR_PREFIX], pm[utils.DERIVEDCHARGERS_PREFIX], pm[utils.ACTION_PREFIX], pm[utils.ACTION_PLAN_PREFIX], pm[utils.SHARED_GROUP_PREFIX])
+	return ms.cacheRating(loadID, pm[utils.DESTINATION_PREFIX], pm[utils.RATING_PLAN_PREFIX], pm[utils.RATING_PROFILE_PREFIX], pm[utils.LCR_PREFIX], pm[utils.DERIVEDCHARGERS_PREFIX], pm[utils.ACTION_PREFIX], pm[utils.ACTION_PLAN_PREFIX], pm[utils.SHARED_GROUP_PREFIX], pm[utils.ExchangeRatesPrefix], pm[utils.TaxRulesPrefix])
 }
 
-func (ms *MapStorage) cacheRating(loadID string, dKeys, rpKeys, rpfKeys, lcrKeys, dcsKeys, actKeys, aplKeys, shgKeys []string) error {
+func (ms *MapStorage) cacheRating(loadID string, dKeys, rpKeys, rpfKeys, lcrKeys, dcsKeys, actKeys, aplKeys, shgKeys, exrKeys, txrKeys []string) error {
 	CacheBeginTransaction()
 	if dKeys == nil || (float64(CacheCountEntries(utils.DESTINATION_PREFIX))*utils.DESTINATIONS_LOAD_THRESHOLD < float64(len(dKeys))) {
 		CacheRemPrefixKey(utils.DESTINATION_PREFIX)
@@ -146,6 +152,12 @@ func (ms *MapStorage) cacheRating(loadID string, dKeys, rpKeys, rpfKeys, lcrKeys
 	if shgKeys == nil {
 		CacheRemPrefixKey(utils.SHARED_GROUP_PREFIX) // Forced until we can fine tune it
 	}
+	if exrKeys == nil {
+		CacheRemPrefixKey(utils.ExchangeRatesPrefix)
+	}
+	if txrKeys == nil {
+		CacheRemPrefixKey(utils.TaxRulesPrefix)
+	}
 	for k, _ := range ms.dict {
 		if strings.HasPrefix(k, utils.DESTINATION_PREFIX) {
 			if _, err := ms.GetDestination(k[len(utils.DESTINATION_PREFIX):]); err != nil {
@@ -202,6 +214,20 @@ func (ms *MapStorage) cacheRating(loadID string, dKeys, rpKeys, rpfKeys, lcrKeys
 				return err
 			}
 		}
+		if strings.HasPrefix(k, utils.ExchangeRatesPrefix) {
+			CacheRemKey(k)
+			if _, err := ms.GetExchangeRate(k[len(utils.ExchangeRatesPrefix):], true); err != nil {
+				CacheRollbackTransaction()
+				return err
+			}
+		}
+		if strings.HasPrefix(k, utils.TaxRulesPrefix) {
+			CacheRemKey(k)
+			if _, err := ms.GetTaxRules(k[len(utils.TaxRulesPrefix):], true); err != nil {
+				CacheRollbackTransaction()
+				return err
+			}
+		}
 	}
 	CacheCommitTransaction()
 
@@ -362,10 +388,7 @@ func (ms *MapStorage) SetRatingPlan(rp *RatingPlan) (err error) {
 	w.Write(result)
 	w.Close()
 	ms.dict[utils.RATING_PLAN_PREFIX+rp.Id] = b.Bytes()
-	response := 0
-	if historyScribe != nil {
-		go historyScribe.Call("HistoryV1.Record", rp.GetHistoryRecord(), &response)
-	}
+	recordHistory(rp.GetHistoryRecord())
 	return
 }
 
@@ -396,10 +419,7 @@ func (ms *MapStorage) SetRatingProfile(rpf *RatingProfile) (err error) {
 	defer ms.mu.Unlock()
 	result, err := ms.ms.Marshal(rpf)
 	ms.dict[utils.RATING_PROFILE_PREFIX+rpf.Id] = result
-	response := 0
-	if historyScribe != nil {
-		go historyScribe.Call("HistoryV1.Record", rpf.GetHistoryRecord(false), &response)
-	}
+	recordHistory(rpf.GetHistoryRecord(false))
 	return
 }
 
@@ -410,11 +430,8 @@ func (ms *MapStorage) RemoveRatingProfile(key string) (err error) {
 		if strings.HasPrefix(k, key) {
 			delete(ms.dict, key)
 			CacheRemKey(k)
-			response := 0
 			rpf := &RatingProfile{Id: key}
-			if historyScribe != nil {
-				go historyScribe.Call("HistoryV1.Record", rpf.GetHistoryRecord(true), &response)
-			}
+			recordHistory(rpf.GetHistoryRecord(true))
 		}
 	}
 	return
@@ -484,10 +501,7 @@ func (ms *MapStorage) SetDestination(dest *Destination) (err error) {
 	w.Write(result)
 	w.Close()
 	ms.dict[utils.DESTINATION_PREFIX+dest.Id] = b.Bytes()
-	response := 0
-	if historyScribe != nil {
-		go historyScribe.Call("HistoryV1.Record", dest.GetHistoryRecord(false), &response)
-	}
+	recordHistory(dest.GetHistoryRecord(false))
 	return
 }
 
@@ -520,6 +534,7 @@ func (ms *MapStorage) SetActions(key string, as Actions) (err error) {
 	defer ms.mu.Unlock()
 	result, err := ms.ms.Marshal(&as)
 	ms.dict[utils.ACTION_PREFIX+key] = result
+	recordHistory(as.GetHistoryRecord(key, false))
 	return
 }
 
@@ -527,6 +542,7 @@ func (ms *MapStorage) RemoveActions(key string) (err error) {
 	ms.mu.Lock()
 	defer ms.mu.Unlock()
 	delete(ms.dict, utils.ACTION_PREFIX+key)
+	recordHistory(Actions(nil).GetHistoryRecord(key, true))
 	return
 }
 
@@ -581,6 +597,8 @@ func (ms *MapStorage) SetAccount(ub *Account) (err error) {
 			ac.ActionTriggers = ub.ActionTriggers
 			ac.UnitCounters = ub.UnitCounters
 			ac.AllowNegative = ub.AllowNegative
+			ac.CreditLimit = ub.CreditLimit
+			ac.Reservations = ub.Reservations
 			ac.Disabled = ub.Disabled
 			ub = ac
 		}
@@ -596,6 +614,7 @@ func (ms *MapStorage) RemoveAccount(key string) (err error) {
 	ms.mu.Lock()
 	defer ms.mu.Unlock()
 	delete(ms.dict, utils.ACCOUNT_PREFIX+key)
+	recordHistory((&Account{ID: key}).GetHistoryRecord(true))
 	return
 }
 
@@ -816,6 +835,7 @@ func (ms *MapStorage) SetActionPlan(key string, ats *ActionPlan, overwrite bool)
 		// delete the key
 		delete(ms.dict, utils.ACTION_PLAN_PREFIX+key)
 		CacheRemKey(utils.ACTION_PLAN_PREFIX + key)
+		recordHistory(ats.GetHistoryRecord(key, true))
 		return
 	}
 	if !overwrite {
@@ -833,6 +853,7 @@ func (ms *MapStorage) SetActionPlan(key string, ats *ActionPlan, overwrite bool)
 	defer ms.mu.Unlock()
 	result, err := ms.ms.Marshal(&ats)
 	ms.dict[utils.ACTION_PLAN_PREFIX+key] = result
+	recordHistory(ats.GetHistoryRecord(key, false))
 	return
 }
 
@@ -930,6 +951,97 @@ func (ms *MapStorage) GetCdrStats(key string) (cs *CdrStats, err error) {
 	return
 }
 
+func (ms *MapStorage) GetExchangeRate(key string, skipCache bool) (exr *ExchangeRate, err error) {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+	key = utils.ExchangeRatesPrefix + key
+	if !skipCache {
+		if x, err := CacheGet(key); err == nil {
+			return x.(*ExchangeRate), nil
+		} else {
+			return nil, err
+		}
+	}
+	if values, ok := ms.dict[key]; ok {
+		if err = ms.ms.Unmarshal(values, &exr); err == nil {
+			CacheSet(key, exr)
+		}
+	} else {
+		return nil, utils.ErrNotFound
+	}
+	return
+}
+func (ms *MapStorage) SetExchangeRate(exr *ExchangeRate) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	result, err := ms.ms.Marshal(exr)
+	ms.dict[utils.ExchangeRatesPrefix+exr.GetId()] = result
+	CacheSet(utils.ExchangeRatesPrefix+exr.GetId(), exr)
+	return err
+}
+
+func (ms *MapStorage) RemoveExchangeRate(key string) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	delete(ms.dict, utils.ExchangeRatesPrefix+key)
+	CacheRemKey(utils.ExchangeRatesPrefix + key)
+	return nil
+}
+
+func (ms *MapStorage) GetTaxRules(key string, skipCache bool) (txrs *TaxRules, err error) {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+	key = utils.TaxRulesPrefix + key
+	if !skipCache {
+		if x, err := CacheGet(key); err == nil {
+			return x.(*TaxRules), nil
+		} else {
+			return nil, err
+		}
+	}
+	if values, ok := ms.dict[key]; ok {
+		if err = ms.ms.Unmarshal(values, &txrs); err == nil {
+			CacheSet(key, txrs)
+		}
+	} else {
+		return nil, utils.ErrNotFound
+	}
+	return
+}
+
+func (ms *MapStorage) SetTaxRules(txrs *TaxRules) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	result, err := ms.ms.Marshal(txrs)
+	ms.dict[utils.TaxRulesPrefix+txrs.ID] = result
+	CacheSet(utils.TaxRulesPrefix+txrs.ID, txrs)
+	return err
+}
+
+func (ms *MapStorage) RemoveTaxRules(key string) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	delete(ms.dict, utils.TaxRulesPrefix+key)
+	CacheRemKey(utils.TaxRulesPrefix + key)
+	return nil
+}
+
+func (ms *MapStorage) GetAllTaxRules() (allTxrs []*TaxRules, err error) {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+	for key, value := range ms.dict {
+		if !strings.HasPrefix(key, utils.TaxRulesPrefix) {
+			continue
+		}
+		txrs := &TaxRules{}
+		if err = ms.ms.Unmarshal(value, txrs); err != nil {
+			return nil, err
+		}
+		allTxrs = append(allTxrs, txrs)
+	}
+	return
+}
+
 func (ms *MapStorage) GetAllCdrStats() (css []*CdrStats, err error) {
 	ms.mu.RLock()
 	defer ms.mu.RUnlock()
@@ -963,6 +1075,49 @@ func (ms *MapStorage) GetSMCost(cgrid, source, runid, originHost, originID strin
 	return
 }
 
+func (ms *MapStorage) SetBalanceLedgerEntries(entries []*BalanceLedgerEntry) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	for _, entry := range entries {
+		result, err := ms.ms.Marshal(entry)
+		if err != nil {
+			return err
+		}
+		ms.ledger = append(ms.ledger, result)
+	}
+	return nil
+}
+
+func (ms *MapStorage) GetBalanceLedger(filter *BalanceLedgerFilter) (entries []*BalanceLedgerEntry, err error) {
+	var offset, limit int
+	if filter.Offset != nil {
+		offset = *filter.Offset
+	}
+	if filter.Limit != nil {
+		limit = *filter.Limit
+	}
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+	for _, value := range ms.ledger {
+		if limit != 0 && len(entries) == limit {
+			break
+		}
+		var entry BalanceLedgerEntry
+		if err = ms.ms.Unmarshal(value, &entry); err != nil {
+			return nil, err
+		}
+		if !filter.Passes(&entry) {
+			continue
+		}
+		if offset > 0 {
+			offset--
+			continue
+		}
+		entries = append(entries, &entry)
+	}
+	return
+}
+
 func (ms *MapStorage) SetStructVersion(v *StructVersion) (err error) {
 	ms.mu.Lock()
 	defer ms.mu.Unlock()
@@ -987,12 +1142,146 @@ func (ms *MapStorage) GetStructVersion() (rsv *StructVersion, err error) {
 	return
 }
 
-func (ms *MapStorage) GetResourceLimit(id string, skipCache bool) (*ResourceLimit, error) {
-	return nil, nil
+func (ms *MapStorage) GetResourceLimit(id string, skipCache bool) (rl *ResourceLimit, err error) {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+	key := utils.ResourceLimitsPrefix + id
+	if !skipCache {
+		if x, err := CacheGet(key); err == nil {
+			return x.(*ResourceLimit), nil
+		} else {
+			return nil, err
+		}
+	}
+	values, ok := ms.dict[key]
+	if !ok {
+		return nil, utils.ErrNotFound
+	}
+	if err = ms.ms.Unmarshal(values, &rl); err != nil {
+		return nil, err
+	}
+	for _, fltr := range rl.Filters {
+		if err := fltr.CompileValues(); err != nil {
+			return nil, err
+		}
+	}
+	CacheSet(key, rl)
+	return
 }
 func (ms *MapStorage) SetResourceLimit(rl *ResourceLimit) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	result, err := ms.ms.Marshal(rl)
+	if err != nil {
+		return err
+	}
+	key := utils.ResourceLimitsPrefix + rl.ID
+	ms.dict[key] = result
+	CacheSet(key, rl)
 	return nil
 }
 func (ms *MapStorage) RemoveResourceLimit(id string) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	key := utils.ResourceLimitsPrefix + id
+	delete(ms.dict, key)
+	CacheRemKey(key)
+	return nil
+}
+
+func (ms *MapStorage) GetSMGSessionCheckpoints() (smgCps []*SMGSessionCheckpoint, err error) {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+	for key, values := range ms.dict {
+		if !strings.HasPrefix(key, utils.SMG_SESSIONS_PREFIX) {
+			continue
+		}
+		smgCp := new(SMGSessionCheckpoint)
+		if err = ms.ms.Unmarshal(values, smgCp); err != nil {
+			return nil, err
+		}
+		for i := 0; ; i++ { // CallCosts are indexed on their position in the session
+			ccValues, hasIt := ms.dict[utils.ConcatenatedKey(utils.SMG_SESSION_COSTS_PREFIX+smgCp.GetId(), strconv.Itoa(i))]
+			if !hasIt {
+				break
+			}
+			cc := new(CallCost)
+			if err = ms.ms.Unmarshal(ccValues, cc); err != nil {
+				return nil, err
+			}
+			smgCp.CallCosts = append(smgCp.CallCosts, cc)
+		}
+		smgCps = append(smgCps, smgCp)
+	}
+	return
+}
+
+// Stores the session state, without the CallCosts which are appended by AddSMGSessionCallCosts
+func (ms *MapStorage) SetSMGSessionCheckpoint(smgCp *SMGSessionCheckpoint) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	smgState := *smgCp
+	smgState.CallCosts = nil
+	result, err := ms.ms.Marshal(&smgState)
+	if err != nil {
+		return err
+	}
+	ms.dict[utils.SMG_SESSIONS_PREFIX+smgCp.GetId()] = result
+	return nil
+}
+
+func (ms *MapStorage) AddSMGSessionCallCosts(id string, ccs []*CallCost) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	idx := 0
+	for ; ; idx++ {
+		if _, hasIt := ms.dict[utils.ConcatenatedKey(utils.SMG_SESSION_COSTS_PREFIX+id, strconv.Itoa(idx))]; !hasIt {
+			break
+		}
+	}
+	for _, cc := range ccs {
+		result, err := ms.ms.Marshal(cc)
+		if err != nil {
+			return err
+		}
+		ms.dict[utils.ConcatenatedKey(utils.SMG_SESSION_COSTS_PREFIX+id, strconv.Itoa(idx))] = result
+		idx++
+	}
+	return nil
+}
+
+func (ms *MapStorage) RemoveSMGSessionCheckpoint(id string) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	delete(ms.dict, utils.SMG_SESSIONS_PREFIX+id)
+	for i := 0; ; i++ {
+		ccKey := utils.ConcatenatedKey(utils.SMG_SESSION_COSTS_PREFIX+id, strconv.Itoa(i))
+		if _, hasIt := ms.dict[ccKey]; !hasIt {
+			break
+		}
+		delete(ms.dict, ccKey)
+	}
+	return nil
+}
+
+func (ms *MapStorage) GetCdrExportJobState(jobID string) (jobState *CdrExportJobState, err error) {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+	values, ok := ms.dict[utils.CdrExportJobsPrefix+jobID]
+	if !ok {
+		return nil, utils.ErrNotFound
+	}
+	err = ms.ms.Unmarshal(values, &jobState)
+	return
+}
+
+func (ms *MapStorage) SetCdrExportJobState(jobState *CdrExportJobState) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	result, err := ms.ms.Marshal(jobState)
+	if err != nil {
+		return err
+	}
+	ms.dict[utils.CdrExportJobsPrefix+jobState.JobID] = result
 	return nil
 }
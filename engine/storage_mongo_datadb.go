@@ -49,10 +49,15 @@ const (
 	colPbs    = "pubsub"
 	colUsr    = "users"
 	colCrs    = "cdr_stats"
+	colExr    = "exchange_rates"
+	colTxr    = "tax_rules"
 	colLht    = "load_history"
 	colLogErr = "error_logs"
 	colVer    = "versions"
 	colRL     = "resource_limits"
+	colSmg    = "smg_sessions"
+	colSmc    = "smg_session_costs"
+	colCej    = "cdr_export_jobs"
 )
 
 var (
@@ -286,6 +291,28 @@ func NewMongoStorage(host, port, db, user, pass string, cdrsIndexes []string, ca
 	if err = ndb.C(utils.TBLSMCosts).EnsureIndex(index); err != nil {
 		return nil, err
 	}
+	index = mgo.Index{
+		Key:        []string{"objecttype", "objectid", "version"},
+		Unique:     false,
+		DropDups:   false,
+		Background: false,
+		Sparse:     false,
+	}
+	if err = ndb.C(utils.TBLHistoryRecords).EnsureIndex(index); err != nil {
+		return nil, err
+	}
+	for _, idxKey := range [][]string{{"account", "balanceuuid"}, {"cgrid"}, {"createdat"}} {
+		index = mgo.Index{
+			Key:        idxKey,
+			Unique:     false,
+			DropDups:   false,
+			Background: false,
+			Sparse:     false,
+		}
+		if err = ndb.C(utils.TBLBalanceLedger).EnsureIndex(index); err != nil {
+			return nil, err
+		}
+	}
 	if cacheDumpDir != "" {
 		if err := CacheSetDumperPath(cacheDumpDir); err != nil {
 			utils.Logger.Info("<cache dumper> init error: " + err.Error())
@@ -380,7 +407,7 @@ func (ms *MongoStorage) Flush(ignore string) (err error) {
 }
 
 func (ms *MongoStorage) CacheRatingAll(loadID string) error {
-	return ms.cacheRating(loadID, nil, nil, nil, nil, nil, nil, nil, nil)
+	return ms.cacheRating(loadID, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil)
 }
 
 func (ms *MongoStorage) CacheRatingPrefixes(loadID string, prefixes ...string) error {
@@ -393,6 +420,8 @@ func (ms *MongoStorage) CacheRatingPrefixes(loadID string, prefixes ...string) e
 		utils.ACTION_PREFIX:          []string{},
 		utils.ACTION_PLAN_PREFIX:     []string{},
 		utils.SHARED_GROUP_PREFIX:    []string{},
+		utils.ExchangeRatesPrefix:    []string{},
+		utils.TaxRulesPrefix:         []string{},
 	}
 	for _, prefix := range prefixes {
 		if _, found := pm[prefix]; !found {
@@ -400,7 +429,7 @@ func (ms *MongoStorage) CacheRatingPrefixes(loadID string, prefixes ...string) e
 		}
 		pm[prefix] = nil
 	}
-	return ms.cacheRating(loadID, pm[utils.DESTINATION_PREFIX], pm[utils.RATING_PLAN_PREFIX], pm[utils.RATING_PROFILE_PREFIX], pm[utils.LCR_PREFIX], pm[utils.DERIVEDCHARGERS_PREFIX], pm[utils.ACTION_PREFIX], pm[utils.ACTION_PLAN_PREFIX], pm[utils.SHARED_GROUP_PREFIX])
+	return ms.cacheRating(loadID, pm[utils.DESTINATION_PREFIX], pm[utils.RATING_PLAN_PREFIX], pm[utils.RATING_PROFILE_PREFIX], pm[utils.LCR_PREFIX], pm[utils.DERIVEDCHARGERS_PREFIX], pm[utils.ACTION_PREFIX], pm[utils.ACTION_PLAN_PREFIX], pm[utils.SHARED_GROUP_PREFIX], pm[utils.ExchangeRatesPrefix], pm[utils.TaxRulesPrefix])
 }
 
 func (ms *MongoStorage) CacheRatingPrefixValues(loadID string, prefixes map[string][]string) error {
@@ -413,6 +442,8 @@ func (ms *MongoStorage) CacheRatingPrefixValues(loadID string, prefixes map[stri
 		utils.ACTION_PREFIX:          []string{},
 		utils.ACTION_PLAN_PREFIX:     []string{},
 		utils.SHARED_GROUP_PREFIX:    []string{},
+		utils.ExchangeRatesPrefix:    []string{},
+		utils.TaxRulesPrefix:         []string{},
 	}
 	for prefix, ids := range prefixes {
 		if _, found := pm[prefix]; !found {
@@ -420,10 +451,10 @@ func (ms *MongoStorage) CacheRatingPrefixValues(loadID string, prefixes map[stri
 		}
 		pm[prefix] = ids
 	}
-	return ms.cacheRating(loadID, pm[utils.DESTINATION_PREFIX], pm[utils.RATING_PLAN_PREFIX], pm[utils.RATING_PROFILE_PREFIX], pm[utils.LCR_PREFIX], pm[utils.DERIVEDCHARGERS_PREFIX], pm[utils.ACTION_PREFIX], pm[utils.ACTION_PLAN_PREFIX], pm[utils.SHARED_GROUP_PREFIX])
+	return ms.cacheRating(loadID, pm[utils.DESTINATION_PREFIX], pm[utils.RATING_PLAN_PREFIX], pm[utils.RATING_PROFILE_PREFIX], pm[utils.LCR_PREFIX], pm[utils.DERIVEDCHARGERS_PREFIX], pm[utils.ACTION_PREFIX], pm[utils.ACTION_PLAN_PREFIX], pm[utils.SHARED_GROUP_PREFIX], pm[utils.ExchangeRatesPrefix], pm[utils.TaxRulesPrefix])
 }
 
-func (ms *MongoStorage) cacheRating(loadID string, dKeys, rpKeys, rpfKeys, lcrKeys, dcsKeys, actKeys, aplKeys, shgKeys []string) (err error) {
+func (ms *MongoStorage) cacheRating(loadID string, dKeys, rpKeys, rpfKeys, lcrKeys, dcsKeys, actKeys, aplKeys, shgKeys, exrKeys, txrKeys []string) (err error) {
 	start := time.Now()
 	CacheBeginTransaction()
 	keyResult := struct{ Key string }{}
@@ -647,6 +678,58 @@ func (ms *MongoStorage) cacheRating(loadID string, dKeys, rpKeys, rpfKeys, lcrKe
 	if len(shgKeys) != 0 {
 		utils.Logger.Info("Finished shared groups caching.")
 	}
+
+	if exrKeys == nil {
+		utils.Logger.Info("Caching all exchange rates")
+		iter := db.C(colExr).Find(nil).Select(bson.M{"key": 1}).Iter()
+		exrKeys = make([]string, 0)
+		for iter.Next(&keyResult) {
+			exrKeys = append(exrKeys, utils.ExchangeRatesPrefix+keyResult.Key)
+		}
+		if err := iter.Close(); err != nil {
+			CacheRollbackTransaction()
+			return fmt.Errorf("exchange rates: %s", err.Error())
+		}
+		CacheRemPrefixKey(utils.ExchangeRatesPrefix)
+	} else if len(exrKeys) != 0 {
+		utils.Logger.Info(fmt.Sprintf("Caching exchange rates: %v", exrKeys))
+	}
+	for _, key := range exrKeys {
+		CacheRemKey(key)
+		if _, err = ms.GetExchangeRate(key[len(utils.ExchangeRatesPrefix):], true); err != nil && err != utils.ErrNotFound { // Removed rates are only dropped from cache
+			CacheRollbackTransaction()
+			return fmt.Errorf("exchange rates: %s", err.Error())
+		}
+	}
+	if len(exrKeys) != 0 {
+		utils.Logger.Info("Finished exchange rates caching.")
+	}
+
+	if txrKeys == nil {
+		utils.Logger.Info("Caching all tax rules")
+		iter := db.C(colTxr).Find(nil).Select(bson.M{"id": 1}).Iter()
+		txrKeys = make([]string, 0)
+		for iter.Next(&idResult) {
+			txrKeys = append(txrKeys, utils.TaxRulesPrefix+idResult.Id)
+		}
+		if err := iter.Close(); err != nil {
+			CacheRollbackTransaction()
+			return fmt.Errorf("tax rules: %s", err.Error())
+		}
+		CacheRemPrefixKey(utils.TaxRulesPrefix)
+	} else if len(txrKeys) != 0 {
+		utils.Logger.Info(fmt.Sprintf("Caching tax rules: %v", txrKeys))
+	}
+	for _, key := range txrKeys {
+		CacheRemKey(key)
+		if _, err = ms.GetTaxRules(key[len(utils.TaxRulesPrefix):], true); err != nil && err != utils.ErrNotFound { // Removed rules are only dropped from cache
+			CacheRollbackTransaction()
+			return fmt.Errorf("tax rules: %s", err.Error())
+		}
+	}
+	if len(txrKeys) != 0 {
+		utils.Logger.Info("Finished tax rules caching.")
+	}
 	CacheCommitTransaction()
 	utils.Logger.Info(fmt.Sprintf("Cache rating creation time: %v", time.Since(start)))
 	loadHistList, err := ms.GetLoadHistory(1, true)
@@ -856,9 +939,8 @@ func (ms *MongoStorage) SetRatingPlan(rp *RatingPlan) error {
 		Key   string
 		Value []byte
 	}{Key: rp.Id, Value: b.Bytes()})
-	if err == nil && historyScribe != nil {
-		var response int
-		historyScribe.Call("HistoryV1.Record", rp.GetHistoryRecord(), &response)
+	if err == nil {
+		recordHistory(rp.GetHistoryRecord())
 	}
 	return err
 }
@@ -885,9 +967,8 @@ func (ms *MongoStorage) SetRatingProfile(rp *RatingProfile) error {
 	session, col := ms.conn(colRpf)
 	defer session.Close()
 	_, err := col.Upsert(bson.M{"id": rp.Id}, rp)
-	if err == nil && historyScribe != nil {
-		var response int
-		historyScribe.Call("HistoryV1.Record", rp.GetHistoryRecord(false), &response)
+	if err == nil {
+		recordHistory(rp.GetHistoryRecord(false))
 	}
 	return err
 }
@@ -903,10 +984,7 @@ func (ms *MongoStorage) RemoveRatingProfile(key string) error {
 		}
 		CacheRemKey(utils.RATING_PROFILE_PREFIX + key)
 		rpf := &RatingProfile{Id: result.Id}
-		if historyScribe != nil {
-			var response int
-			go historyScribe.Call("HistoryV1.Record", rpf.GetHistoryRecord(true), &response)
-		}
+		recordHistory(rpf.GetHistoryRecord(true))
 	}
 	return iter.Close()
 }
@@ -993,9 +1071,8 @@ func (ms *MongoStorage) SetDestination(dest *Destination) (err error) {
 		Key   string
 		Value []byte
 	}{Key: dest.Id, Value: b.Bytes()})
-	if err == nil && historyScribe != nil {
-		var response int
-		historyScribe.Call("HistoryV1.Record", dest.GetHistoryRecord(false), &response)
+	if err == nil {
+		recordHistory(dest.GetHistoryRecord(false))
 	}
 	return
 }
@@ -1033,13 +1110,20 @@ func (ms *MongoStorage) SetActions(key string, as Actions) error {
 		Key   string
 		Value Actions
 	}{Key: key, Value: as})
+	if err == nil {
+		recordHistory(as.GetHistoryRecord(key, false))
+	}
 	return err
 }
 
 func (ms *MongoStorage) RemoveActions(key string) error {
 	session, col := ms.conn(colAct)
 	defer session.Close()
-	return col.Remove(bson.M{"key": key})
+	err := col.Remove(bson.M{"key": key})
+	if err == nil {
+		recordHistory(Actions(nil).GetHistoryRecord(key, true))
+	}
+	return err
 }
 
 func (ms *MongoStorage) GetSharedGroup(key string, skipCache bool) (sg *SharedGroup, err error) {
@@ -1087,6 +1171,8 @@ func (ms *MongoStorage) SetAccount(acc *Account) error {
 			ac.ActionTriggers = acc.ActionTriggers
 			ac.UnitCounters = acc.UnitCounters
 			ac.AllowNegative = acc.AllowNegative
+			ac.CreditLimit = acc.CreditLimit
+			ac.Reservations = acc.Reservations
 			ac.Disabled = acc.Disabled
 			acc = ac
 		}
@@ -1100,8 +1186,11 @@ func (ms *MongoStorage) SetAccount(acc *Account) error {
 func (ms *MongoStorage) RemoveAccount(key string) error {
 	session, col := ms.conn(colAcc)
 	defer session.Close()
-	return col.Remove(bson.M{"id": key})
-
+	err := col.Remove(bson.M{"id": key})
+	if err == nil {
+		recordHistory((&Account{ID: key}).GetHistoryRecord(true))
+	}
+	return err
 }
 
 func (ms *MongoStorage) GetCdrStatsQueue(key string) (sq *StatsQueue, err error) {
@@ -1425,7 +1514,9 @@ func (ms *MongoStorage) SetActionPlan(key string, ats *ActionPlan, overwrite boo
 	if len(ats.ActionTimings) == 0 {
 		CacheRemKey(utils.ACTION_PLAN_PREFIX + key)
 		err := col.Remove(bson.M{"key": key})
-		if err != mgo.ErrNotFound {
+		if err == nil {
+			recordHistory(ats.GetHistoryRecord(key, true))
+		} else if err != mgo.ErrNotFound {
 			return err
 		}
 		return nil
@@ -1453,6 +1544,9 @@ func (ms *MongoStorage) SetActionPlan(key string, ats *ActionPlan, overwrite boo
 		Key   string
 		Value []byte
 	}{Key: key, Value: b.Bytes()})
+	if err == nil {
+		recordHistory(ats.GetHistoryRecord(key, false))
+	}
 	return err
 }
 
@@ -1551,6 +1645,107 @@ func (ms *MongoStorage) GetCdrStats(key string) (cs *CdrStats, err error) {
 	return
 }
 
+func (ms *MongoStorage) GetExchangeRate(key string, skipCache bool) (exr *ExchangeRate, err error) {
+	if !skipCache {
+		if x, err := CacheGet(utils.ExchangeRatesPrefix + key); err == nil {
+			return x.(*ExchangeRate), nil
+		} else {
+			return nil, err
+		}
+	}
+	var kv struct {
+		Key   string
+		Value *ExchangeRate
+	}
+	session, col := ms.conn(colExr)
+	defer session.Close()
+	if err = col.Find(bson.M{"key": key}).One(&kv); err != nil {
+		if err == mgo.ErrNotFound {
+			err = utils.ErrNotFound
+		}
+		return nil, err
+	}
+	CacheSet(utils.ExchangeRatesPrefix+key, kv.Value)
+	return kv.Value, nil
+}
+
+func (ms *MongoStorage) SetExchangeRate(exr *ExchangeRate) error {
+	session, col := ms.conn(colExr)
+	defer session.Close()
+	if _, err := col.Upsert(bson.M{"key": exr.GetId()}, &struct {
+		Key   string
+		Value *ExchangeRate
+	}{Key: exr.GetId(), Value: exr}); err != nil {
+		return err
+	}
+	CacheSet(utils.ExchangeRatesPrefix+exr.GetId(), exr)
+	return nil
+}
+
+func (ms *MongoStorage) RemoveExchangeRate(key string) error {
+	session, col := ms.conn(colExr)
+	defer session.Close()
+	if err := col.Remove(bson.M{"key": key}); err != nil && err != mgo.ErrNotFound {
+		return err
+	}
+	CacheRemKey(utils.ExchangeRatesPrefix + key)
+	return nil
+}
+
+func (ms *MongoStorage) GetTaxRules(key string, skipCache bool) (txrs *TaxRules, err error) {
+	if !skipCache {
+		if x, err := CacheGet(utils.TaxRulesPrefix + key); err == nil {
+			return x.(*TaxRules), nil
+		} else {
+			return nil, err
+		}
+	}
+	session, col := ms.conn(colTxr)
+	defer session.Close()
+	txrs = new(TaxRules)
+	if err = col.Find(bson.M{"id": key}).One(txrs); err != nil {
+		if err == mgo.ErrNotFound {
+			err = utils.ErrNotFound
+		}
+		return nil, err
+	}
+	CacheSet(utils.TaxRulesPrefix+key, txrs)
+	return
+}
+
+func (ms *MongoStorage) SetTaxRules(txrs *TaxRules) error {
+	session, col := ms.conn(colTxr)
+	defer session.Close()
+	if _, err := col.Upsert(bson.M{"id": txrs.ID}, txrs); err != nil {
+		return err
+	}
+	CacheSet(utils.TaxRulesPrefix+txrs.ID, txrs)
+	return nil
+}
+
+func (ms *MongoStorage) RemoveTaxRules(key string) error {
+	session, col := ms.conn(colTxr)
+	defer session.Close()
+	if err := col.Remove(bson.M{"id": key}); err != nil && err != mgo.ErrNotFound {
+		return err
+	}
+	CacheRemKey(utils.TaxRulesPrefix + key)
+	return nil
+}
+
+func (ms *MongoStorage) GetAllTaxRules() (allTxrs []*TaxRules, err error) {
+	session, col := ms.conn(colTxr)
+	defer session.Close()
+	iter := col.Find(nil).Iter()
+	var txrs TaxRules
+	for iter.Next(&txrs) {
+		clone := txrs // avoid using the same pointer in append
+		allTxrs = append(allTxrs, &clone)
+	}
+	err = iter.Close()
+	return
+}
+
 func (ms *MongoStorage) GetAllCdrStats() (css []*CdrStats, err error) {
 	session, col := ms.conn(colCrs)
 	defer session.Close()
@@ -1598,3 +1793,97 @@ func (ms *MongoStorage) SetResourceLimit(rl *ResourceLimit) error {
 func (ms *MongoStorage) RemoveResourceLimit(id string) error {
 	return nil
 }
+
+func (ms *MongoStorage) GetSMGSessionCheckpoints() (smgCps []*SMGSessionCheckpoint, err error) {
+	session, col := ms.conn(colSmg)
+	defer session.Close()
+	iter := col.Find(nil).Iter()
+	var kv struct {
+		Key   string
+		Value *SMGSessionCheckpoint
+	}
+	for iter.Next(&kv) {
+		smgCps = append(smgCps, kv.Value)
+		kv.Value = nil // do not decode the next one over this
+	}
+	if err = iter.Close(); err != nil {
+		return nil, err
+	}
+	colCcs := session.DB(ms.db).C(colSmc)
+	for _, smgCp := range smgCps {
+		var kvCcs struct {
+			Key   string
+			Value []*CallCost
+		}
+		if err = colCcs.Find(bson.M{"key": smgCp.GetId()}).One(&kvCcs); err != nil {
+			if err == mgo.ErrNotFound {
+				err = nil
+				continue
+			}
+			return nil, err
+		}
+		smgCp.CallCosts = append(smgCp.CallCosts, kvCcs.Value...)
+	}
+	return
+}
+
+// Stores the session state, without the CallCosts which are appended by AddSMGSessionCallCosts
+func (ms *MongoStorage) SetSMGSessionCheckpoint(smgCp *SMGSessionCheckpoint) (err error) {
+	session, col := ms.conn(colSmg)
+	defer session.Close()
+	smgState := *smgCp
+	smgState.CallCosts = nil
+	_, err = col.Upsert(bson.M{"key": smgCp.GetId()}, &struct {
+		Key   string
+		Value *SMGSessionCheckpoint
+	}{Key: smgCp.GetId(), Value: &smgState})
+	return
+}
+
+func (ms *MongoStorage) AddSMGSessionCallCosts(id string, ccs []*CallCost) (err error) {
+	if len(ccs) == 0 {
+		return
+	}
+	session, col := ms.conn(colSmc)
+	defer session.Close()
+	_, err = col.Upsert(bson.M{"key": id}, bson.M{"$push": bson.M{"value": bson.M{"$each": ccs}}})
+	return
+}
+
+func (ms *MongoStorage) RemoveSMGSessionCheckpoint(id string) error {
+	session, col := ms.conn(colSmg)
+	defer session.Close()
+	if err := col.Remove(bson.M{"key": id}); err != nil && err != mgo.ErrNotFound {
+		return err
+	}
+	if err := session.DB(ms.db).C(colSmc).Remove(bson.M{"key": id}); err != nil && err != mgo.ErrNotFound {
+		return err
+	}
+	return nil
+}
+
+func (ms *MongoStorage) GetCdrExportJobState(jobID string) (jobState *CdrExportJobState, err error) {
+	var kv struct {
+		Key   string
+		Value *CdrExportJobState
+	}
+	session, col := ms.conn(colCej)
+	defer session.Close()
+	if err = col.Find(bson.M{"key": jobID}).One(&kv); err != nil {
+		if err == mgo.ErrNotFound {
+			err = utils.ErrNotFound
+		}
+		return nil, err
+	}
+	return kv.Value, nil
+}
+
+func (ms *MongoStorage) SetCdrExportJobState(jobState *CdrExportJobState) (err error) {
+	session, col := ms.conn(colCej)
+	defer session.Close()
+	_, err = col.Upsert(bson.M{"key": jobState.JobID}, &struct {
+		Key   string
+		Value *CdrExportJobState
+	}{Key: jobState.JobID, Value: jobState})
+	return
+}
@@ -6,7 +6,9 @@ import (
 	"strings"
 	"time"
 
+	"github.com/cgrates/cgrates/history"
 	"github.com/cgrates/cgrates/utils"
+	"gopkg.in/mgo.v2"
 	"gopkg.in/mgo.v2/bson"
 )
 
@@ -301,6 +303,34 @@ func (ms *MongoStorage) GetTpAliases(tp *TpAlias) ([]TpAlias, error) {
 	return results, err
 }
 
+func (ms *MongoStorage) GetTpExchangeRates(tpid, tag string) ([]TpExchangeRate, error) {
+	filter := bson.M{
+		"tpid": tpid,
+	}
+	if tag != "" {
+		filter["tag"] = tag
+	}
+	var results []TpExchangeRate
+	session, col := ms.conn(utils.TBLTPExchangeRates)
+	defer session.Close()
+	err := col.Find(filter).All(&results)
+	return results, err
+}
+
+func (ms *MongoStorage) GetTpTaxRules(tpid, tag string) ([]TpTaxRule, error) {
+	filter := bson.M{
+		"tpid": tpid,
+	}
+	if tag != "" {
+		filter["tag"] = tag
+	}
+	var results []TpTaxRule
+	session, col := ms.conn(utils.TBLTPTaxRules)
+	defer session.Close()
+	err := col.Find(filter).All(&results)
+	return results, err
+}
+
 func (ms *MongoStorage) GetTpResourceLimits(tpid, tag string) (TpResourceLimits, error) {
 	return nil, nil
 }
@@ -551,6 +581,42 @@ func (ms *MongoStorage) SetTpSharedGroups(tps []TpSharedGroup) error {
 	return err
 }
 
+func (ms *MongoStorage) SetTpExchangeRates(tps []TpExchangeRate) error {
+	if len(tps) == 0 {
+		return nil
+	}
+	m := make(map[string]bool)
+	session, col := ms.conn(utils.TBLTPExchangeRates)
+	defer session.Close()
+	tx := col.Bulk()
+	for _, tp := range tps {
+		if found, _ := m[tp.Tag]; !found {
+			m[tp.Tag] = true
+			tx.Upsert(bson.M{"tpid": tp.Tpid, "tag": tp.Tag}, tp)
+		}
+	}
+	_, err := tx.Run()
+	return err
+}
+
+func (ms *MongoStorage) SetTpTaxRules(tps []TpTaxRule) error {
+	if len(tps) == 0 {
+		return nil
+	}
+	m := make(map[string]bool)
+	session, col := ms.conn(utils.TBLTPTaxRules)
+	defer session.Close()
+	tx := col.Bulk()
+	for _, tp := range tps {
+		if found, _ := m[tp.Tag]; !found {
+			m[tp.Tag] = true
+			tx.Upsert(bson.M{"tpid": tp.Tpid, "tag": tp.Tag}, tp)
+		}
+	}
+	_, err := tx.Run()
+	return err
+}
+
 func (ms *MongoStorage) SetTpCdrStats(tps []TpCdrstat) error {
 	if len(tps) == 0 {
 		return nil
@@ -765,6 +831,107 @@ func (ms *MongoStorage) GetSMCosts(cgrid, runid, originHost, originIDPrefix stri
 	return smcs, nil
 }
 
+func (ms *MongoStorage) SetHistoryRecord(chRec *history.ChangeRecord) error {
+	session, col := ms.conn(utils.TBLHistoryRecords)
+	defer session.Close()
+	return col.Insert(chRec)
+}
+
+func (ms *MongoStorage) GetLastHistoryRecord(objType, objID string) (*history.ChangeRecord, error) {
+	session, col := ms.conn(utils.TBLHistoryRecords)
+	defer session.Close()
+	var chRec history.ChangeRecord
+	if err := col.Find(bson.M{"objecttype": objType, "objectid": objID}).Sort("-version").One(&chRec); err != nil {
+		if err == mgo.ErrNotFound {
+			return nil, utils.ErrNotFound
+		}
+		return nil, err
+	}
+	return &chRec, nil
+}
+
+func (ms *MongoStorage) GetHistoryRecords(filter *history.RecordFilter) (chRecs []*history.ChangeRecord, err error) {
+	fltr := bson.M{}
+	if filter.ObjectType != "" {
+		fltr["objecttype"] = filter.ObjectType
+	}
+	if filter.ObjectID != "" {
+		fltr["objectid"] = filter.ObjectID
+	}
+	if !filter.TimeStart.IsZero() || !filter.TimeEnd.IsZero() {
+		timeFltr := bson.M{}
+		if !filter.TimeStart.IsZero() {
+			timeFltr["$gte"] = filter.TimeStart
+		}
+		if !filter.TimeEnd.IsZero() {
+			timeFltr["$lt"] = filter.TimeEnd
+		}
+		fltr["timestamp"] = timeFltr
+	}
+	session, col := ms.conn(utils.TBLHistoryRecords)
+	defer session.Close()
+	q := col.Find(fltr).Sort("timestamp", "version")
+	if filter.Paginator.Limit != nil {
+		q = q.Limit(*filter.Paginator.Limit)
+	}
+	if filter.Paginator.Offset != nil {
+		q = q.Skip(*filter.Paginator.Offset)
+	}
+	if err = q.All(&chRecs); err != nil {
+		return nil, err
+	}
+	return chRecs, nil
+}
+
+func (ms *MongoStorage) SetBalanceLedgerEntries(entries []*BalanceLedgerEntry) error {
+	docs := make([]interface{}, len(entries))
+	for i, entry := range entries {
+		docs[i] = entry
+	}
+	session, col := ms.conn(utils.TBLBalanceLedger)
+	defer session.Close()
+	return col.Insert(docs...)
+}
+
+func (ms *MongoStorage) GetBalanceLedger(filter *BalanceLedgerFilter) (entries []*BalanceLedgerEntry, err error) {
+	fltr := bson.M{}
+	if filter.Account != "" {
+		fltr["account"] = filter.Account
+	}
+	if filter.BalanceUUID != "" {
+		fltr["balanceuuid"] = filter.BalanceUUID
+	}
+	if filter.CGRID != "" {
+		fltr["cgrid"] = filter.CGRID
+	}
+	if filter.ActionsID != "" {
+		fltr["actionsid"] = filter.ActionsID
+	}
+	if !filter.TimeStart.IsZero() || !filter.TimeEnd.IsZero() {
+		timeFltr := bson.M{}
+		if !filter.TimeStart.IsZero() {
+			timeFltr["$gte"] = filter.TimeStart
+		}
+		if !filter.TimeEnd.IsZero() {
+			timeFltr["$lt"] = filter.TimeEnd
+		}
+		fltr["createdat"] = timeFltr
+	}
+	session, col := ms.conn(utils.TBLBalanceLedger)
+	defer session.Close()
+	q := col.Find(fltr).Sort("_id") // insertion order
+	if filter.Paginator.Limit != nil {
+		q = q.Limit(*filter.Paginator.Limit)
+	}
+	if filter.Paginator.Offset != nil {
+		q = q.Skip(*filter.Paginator.Offset)
+	}
+	if err = q.All(&entries); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
 func (ms *MongoStorage) SetCDR(cdr *CDR, allowUpdate bool) (err error) {
 	if cdr.OrderID == 0 {
 		cdr.OrderID = time.Now().UnixNano()
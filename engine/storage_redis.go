@@ -102,7 +102,7 @@ func (rs *RedisStorage) GetKeysForPrefix(prefix string, skipCache bool) ([]strin
 }
 
 func (rs *RedisStorage) CacheRatingAll(loadID string) error {
-	return rs.cacheRating(loadID, nil, nil, nil, nil, nil, nil, nil, nil)
+	return rs.cacheRating(loadID, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil)
 }
 
 func (rs *RedisStorage) CacheRatingPrefixes(loadID string, prefixes ...string) error {
@@ -115,6 +115,8 @@ func (rs *RedisStorage) CacheRatingPrefixes(loadID string, prefixes ...string) e
 		utils.ACTION_PREFIX:          []string{},
 		utils.ACTION_PLAN_PREFIX:     []string{},
 		utils.SHARED_GROUP_PREFIX:    []string{},
+		utils.ExchangeRatesPrefix:    []string{},
+		utils.TaxRulesPrefix:         []string{},
 	}
 	for _, prefix := range prefixes {
 		if _, found := pm[prefix]; !found {
@@ -122,7 +124,7 @@ func (rs *RedisStorage) CacheRatingPrefixes(loadID string, prefixes ...string) e
 		}
 		pm[prefix] = nil
 	}
-	return rs.cacheRating(loadID, pm[utils.DESTINATION_PREFIX], pm[utils.RATING_PLAN_PREFIX], pm[utils.RATING_PROFILE_PREFIX], pm[utils.LCR_PREFIX], pm[utils.DERIVEDCHARGERS_PREFIX], pm[utils.ACTION_PREFIX], pm[utils.ACTION_PLAN_PREFIX], pm[utils.SHARED_GROUP_PREFIX])
+	return rs.cacheRating(loadID, pm[utils.DESTINATION_PREFIX], pm[utils.RATING_PLAN_PREFIX], pm[utils.RATING_PROFILE_PREFIX], pm[utils.LCR_PREFIX], pm[utils.DERIVEDCHARGERS_PREFIX], pm[utils.ACTION_PREFIX], pm[utils.ACTION_PLAN_PREFIX], pm[utils.SHARED_GROUP_PREFIX], pm[utils.ExchangeRatesPrefix], pm[utils.TaxRulesPrefix])
 }
 
 func (rs *RedisStorage) CacheRatingPrefixValues(loadID string, prefixes map[string][]string) error {
@@ -135,6 +137,8 @@ func (rs *RedisStorage) CacheRatingPrefixValues(loadID string, prefixes map[stri
 		utils.ACTION_PREFIX:          []string{},
 		utils.ACTION_PLAN_PREFIX:     []string{},
 		utils.SHARED_GROUP_PREFIX:    []string{},
+		utils.ExchangeRatesPrefix:    []string{},
+		utils.TaxRulesPrefix:         []string{},
 	}
 	for prefix, ids := range prefixes {
 		if _, found := pm[prefix]; !found {
@@ -142,10 +146,10 @@ func (rs *RedisStorage) CacheRatingPrefixValues(loadID string, prefixes map[stri
 		}
 		pm[prefix] = ids
 	}
-	return rs.cacheRating(loadID, pm[utils.DESTINATION_PREFIX], pm[utils.RATING_PLAN_PREFIX], pm[utils.RATING_PROFILE_PREFIX], pm[utils.LCR_PREFIX], pm[utils.DERIVEDCHARGERS_PREFIX], pm[utils.ACTION_PREFIX], pm[utils.ACTION_PLAN_PREFIX], pm[utils.SHARED_GROUP_PREFIX])
+	return rs.cacheRating(loadID, pm[utils.DESTINATION_PREFIX], pm[utils.RATING_PLAN_PREFIX], pm[utils.RATING_PROFILE_PREFIX], pm[utils.LCR_PREFIX], pm[utils.DERIVEDCHARGERS_PREFIX], pm[utils.ACTION_PREFIX], pm[utils.ACTION_PLAN_PREFIX], pm[utils.SHARED_GROUP_PREFIX], pm[utils.ExchangeRatesPrefix], pm[utils.TaxRulesPrefix])
 }
 
-func (rs *RedisStorage) cacheRating(loadID string, dKeys, rpKeys, rpfKeys, lcrKeys, dcsKeys, actKeys, aplKeys, shgKeys []string) (err error) {
+func (rs *RedisStorage) cacheRating(loadID string, dKeys, rpKeys, rpfKeys, lcrKeys, dcsKeys, actKeys, aplKeys, shgKeys, exrKeys, txrKeys []string) (err error) {
 	start := time.Now()
 	CacheBeginTransaction()
 	conn, err := rs.db.Get()
@@ -322,6 +326,48 @@ func (rs *RedisStorage) cacheRating(loadID string, dKeys, rpKeys, rpfKeys, lcrKe
 		utils.Logger.Info("Finished shared groups caching.")
 	}
 
+	if exrKeys == nil {
+		utils.Logger.Info("Caching all exchange rates")
+		if exrKeys, err = conn.Cmd("KEYS", utils.ExchangeRatesPrefix+"*").List(); err != nil {
+			CacheRollbackTransaction()
+			return fmt.Errorf("exchange rates: %s", err.Error())
+		}
+		CacheRemPrefixKey(utils.ExchangeRatesPrefix)
+	} else if len(exrKeys) != 0 {
+		utils.Logger.Info(fmt.Sprintf("Caching exchange rates: %v", exrKeys))
+	}
+	for _, key := range exrKeys {
+		CacheRemKey(key)
+		if _, err = rs.GetExchangeRate(key[len(utils.ExchangeRatesPrefix):], true); err != nil && err != utils.ErrNotFound { // Removed rates are only dropped from cache
+			CacheRollbackTransaction()
+			return fmt.Errorf("exchange rates: %s", err.Error())
+		}
+	}
+	if len(exrKeys) != 0 {
+		utils.Logger.Info("Finished exchange rates caching.")
+	}
+
+	if txrKeys == nil {
+		utils.Logger.Info("Caching all tax rules")
+		if txrKeys, err = conn.Cmd("KEYS", utils.TaxRulesPrefix+"*").List(); err != nil {
+			CacheRollbackTransaction()
+			return fmt.Errorf("tax rules: %s", err.Error())
+		}
+		CacheRemPrefixKey(utils.TaxRulesPrefix)
+	} else if len(txrKeys) != 0 {
+		utils.Logger.Info(fmt.Sprintf("Caching tax rules: %v", txrKeys))
+	}
+	for _, key := range txrKeys {
+		CacheRemKey(key)
+		if _, err = rs.GetTaxRules(key[len(utils.TaxRulesPrefix):], true); err != nil && err != utils.ErrNotFound { // Removed rules are only dropped from cache
+			CacheRollbackTransaction()
+			return fmt.Errorf("tax rules: %s", err.Error())
+		}
+	}
+	if len(txrKeys) != 0 {
+		utils.Logger.Info("Finished tax rules caching.")
+	}
+
 	CacheCommitTransaction()
 	utils.Logger.Info(fmt.Sprintf("Cache rating creation time: %v", time.Since(start)))
 	loadHistList, err := rs.GetLoadHistory(1, true)
@@ -498,9 +544,8 @@ func (rs *RedisStorage) SetRatingPlan(rp *RatingPlan) (err error) {
 	w.Write(result)
 	w.Close()
 	err = rs.db.Cmd("SET", utils.RATING_PLAN_PREFIX+rp.Id, b.Bytes()).Err
-	if err == nil && historyScribe != nil {
-		response := 0
-		go historyScribe.Call("HistoryV1.Record", rp.GetHistoryRecord(), &response)
+	if err == nil {
+		recordHistory(rp.GetHistoryRecord())
 	}
 	return
 }
@@ -527,9 +572,8 @@ func (rs *RedisStorage) GetRatingProfile(key string, skipCache bool) (rpf *Ratin
 func (rs *RedisStorage) SetRatingProfile(rpf *RatingProfile) (err error) {
 	result, err := rs.ms.Marshal(rpf)
 	err = rs.db.Cmd("SET", utils.RATING_PROFILE_PREFIX+rpf.Id, result).Err
-	if err == nil && historyScribe != nil {
-		response := 0
-		go historyScribe.Call("HistoryV1.Record", rpf.GetHistoryRecord(false), &response)
+	if err == nil {
+		recordHistory(rpf.GetHistoryRecord(false))
 	}
 	return
 }
@@ -549,11 +593,8 @@ func (rs *RedisStorage) RemoveRatingProfile(key string) error {
 			return err
 		}
 		CacheRemKey(key)
-		rpf := &RatingProfile{Id: key}
-		if historyScribe != nil {
-			response := 0
-			go historyScribe.Call("HistoryV1.Record", rpf.GetHistoryRecord(true), &response)
-		}
+		rpf := &RatingProfile{Id: key[len(utils.RATING_PROFILE_PREFIX):]}
+		recordHistory(rpf.GetHistoryRecord(true))
 	}
 	return nil
 }
@@ -618,9 +659,8 @@ func (rs *RedisStorage) SetDestination(dest *Destination) (err error) {
 	w.Write(result)
 	w.Close()
 	err = rs.db.Cmd("SET", utils.DESTINATION_PREFIX+dest.Id, b.Bytes()).Err
-	if err == nil && historyScribe != nil {
-		response := 0
-		go historyScribe.Call("HistoryV1.Record", dest.GetHistoryRecord(false), &response)
+	if err == nil {
+		recordHistory(dest.GetHistoryRecord(false))
 	}
 	return
 }
@@ -672,10 +712,7 @@ func (rs *RedisStorage) RemoveDestination(destID string) (err error) {
 		}
 	}
 	dest := &Destination{Id: key}
-	if historyScribe != nil {
-		response := 0
-		go historyScribe.Call("HistoryV1.Record", dest.GetHistoryRecord(true), &response)
-	}*/
+	recordHistory(dest.GetHistoryRecord(true))*/
 
 	return
 }
@@ -700,11 +737,17 @@ func (rs *RedisStorage) GetActions(key string, skipCache bool) (as Actions, err
 func (rs *RedisStorage) SetActions(key string, as Actions) (err error) {
 	result, err := rs.ms.Marshal(&as)
 	err = rs.db.Cmd("SET", utils.ACTION_PREFIX+key, result).Err
+	if err == nil {
+		recordHistory(as.GetHistoryRecord(key, false))
+	}
 	return
 }
 
 func (rs *RedisStorage) RemoveActions(key string) (err error) {
 	err = rs.db.Cmd("DEL", utils.ACTION_PREFIX+key).Err
+	if err == nil {
+		recordHistory(Actions(nil).GetHistoryRecord(key, true))
+	}
 	return
 }
 
@@ -758,6 +801,8 @@ func (rs *RedisStorage) SetAccount(ub *Account) (err error) {
 			ac.ActionTriggers = ub.ActionTriggers
 			ac.UnitCounters = ub.UnitCounters
 			ac.AllowNegative = ub.AllowNegative
+			ac.CreditLimit = ub.CreditLimit
+			ac.Reservations = ub.Reservations
 			ac.Disabled = ub.Disabled
 			ub = ac
 		}
@@ -768,8 +813,10 @@ func (rs *RedisStorage) SetAccount(ub *Account) (err error) {
 }
 
 func (rs *RedisStorage) RemoveAccount(key string) (err error) {
-	return rs.db.Cmd("DEL", utils.ACCOUNT_PREFIX+key).Err
-
+	if err = rs.db.Cmd("DEL", utils.ACCOUNT_PREFIX+key).Err; err == nil {
+		recordHistory((&Account{ID: key}).GetHistoryRecord(true))
+	}
+	return
 }
 
 func (rs *RedisStorage) GetCdrStatsQueue(key string) (sq *StatsQueue, err error) {
@@ -1054,6 +1101,9 @@ func (rs *RedisStorage) SetActionPlan(key string, ats *ActionPlan, overwrite boo
 		// delete the key
 		err = rs.db.Cmd("DEL", utils.ACTION_PLAN_PREFIX+key).Err
 		CacheRemKey(utils.ACTION_PLAN_PREFIX + key)
+		if err == nil {
+			recordHistory(ats.GetHistoryRecord(key, true))
+		}
 		return err
 	}
 	if !overwrite {
@@ -1076,7 +1126,10 @@ func (rs *RedisStorage) SetActionPlan(key string, ats *ActionPlan, overwrite boo
 	w := zlib.NewWriter(&b)
 	w.Write(result)
 	w.Close()
-	return rs.db.Cmd("SET", utils.ACTION_PLAN_PREFIX+key, b.Bytes()).Err
+	if err = rs.db.Cmd("SET", utils.ACTION_PLAN_PREFIX+key, b.Bytes()).Err; err == nil {
+		recordHistory(ats.GetHistoryRecord(key, false))
+	}
+	return
 }
 
 func (rs *RedisStorage) GetAllActionPlans() (ats map[string]*ActionPlan, err error) {
@@ -1157,6 +1210,118 @@ func (rs *RedisStorage) GetCdrStats(key string) (cs *CdrStats, err error) {
 	return
 }
 
+func (rs *RedisStorage) GetExchangeRate(key string, skipCache bool) (exr *ExchangeRate, err error) {
+	key = utils.ExchangeRatesPrefix + key
+	if !skipCache {
+		if x, err := CacheGet(key); err == nil {
+			return x.(*ExchangeRate), nil
+		} else {
+			return nil, err
+		}
+	}
+	rpl := rs.db.Cmd("GET", key)
+	if rpl.Err != nil {
+		return nil, rpl.Err
+	} else if rpl.IsType(redis.Nil) {
+		return nil, utils.ErrNotFound
+	}
+	var values []byte
+	if values, err = rpl.Bytes(); err == nil {
+		if err = rs.ms.Unmarshal(values, &exr); err == nil {
+			CacheSet(key, exr)
+		}
+	}
+	return
+}
+
+func (rs *RedisStorage) SetExchangeRate(exr *ExchangeRate) error {
+	marshaled, err := rs.ms.Marshal(exr)
+	if err != nil {
+		return err
+	}
+	if err = rs.db.Cmd("SET", utils.ExchangeRatesPrefix+exr.GetId(), marshaled).Err; err != nil {
+		return err
+	}
+	CacheSet(utils.ExchangeRatesPrefix+exr.GetId(), exr)
+	return nil
+}
+
+func (rs *RedisStorage) RemoveExchangeRate(key string) error {
+	if err := rs.db.Cmd("DEL", utils.ExchangeRatesPrefix+key).Err; err != nil {
+		return err
+	}
+	CacheRemKey(utils.ExchangeRatesPrefix + key)
+	return nil
+}
+
+func (rs *RedisStorage) GetTaxRules(key string, skipCache bool) (txrs *TaxRules, err error) {
+	key = utils.TaxRulesPrefix + key
+	if !skipCache {
+		if x, err := CacheGet(key); err == nil {
+			return x.(*TaxRules), nil
+		} else {
+			return nil, err
+		}
+	}
+	rpl := rs.db.Cmd("GET", key)
+	if rpl.Err != nil {
+		return nil, rpl.Err
+	} else if rpl.IsType(redis.Nil) {
+		return nil, utils.ErrNotFound
+	}
+	var values []byte
+	if values, err = rpl.Bytes(); err == nil {
+		if err = rs.ms.Unmarshal(values, &txrs); err == nil {
+			CacheSet(key, txrs)
+		}
+	}
+	return
+}
+
+func (rs *RedisStorage) SetTaxRules(txrs *TaxRules) error {
+	marshaled, err := rs.ms.Marshal(txrs)
+	if err != nil {
+		return err
+	}
+	if err = rs.db.Cmd("SET", utils.TaxRulesPrefix+txrs.ID, marshaled).Err; err != nil {
+		return err
+	}
+	CacheSet(utils.TaxRulesPrefix+txrs.ID, txrs)
+	return nil
+}
+
+func (rs *RedisStorage) RemoveTaxRules(key string) error {
+	if err := rs.db.Cmd("DEL", utils.TaxRulesPrefix+key).Err; err != nil {
+		return err
+	}
+	CacheRemKey(utils.TaxRulesPrefix + key)
+	return nil
+}
+
+func (rs *RedisStorage) GetAllTaxRules() (allTxrs []*TaxRules, err error) {
+	conn, err := rs.db.Get()
+	if err != nil {
+		return nil, err
+	}
+	defer rs.db.Put(conn)
+	keys, err := conn.Cmd("KEYS", utils.TaxRulesPrefix+"*").List()
+	if err != nil {
+		return nil, err
+	}
+	for _, key := range keys {
+		value, err := conn.Cmd("GET", key).Bytes()
+		if err != nil {
+			continue
+		}
+		txrs := &TaxRules{}
+		if err = rs.ms.Unmarshal(value, txrs); err != nil {
+			return nil, err
+		}
+		allTxrs = append(allTxrs, txrs)
+	}
+	return
+}
+
 func (rs *RedisStorage) GetAllCdrStats() (css []*CdrStats, err error) {
 	conn, err := rs.db.Get()
 	if err != nil {
@@ -1253,3 +1418,90 @@ func (rs *RedisStorage) RemoveResourceLimit(id string) error {
 	CacheRemKey(key)
 	return nil
 }
+
+func (rs *RedisStorage) GetSMGSessionCheckpoints() (smgCps []*SMGSessionCheckpoint, err error) {
+	conn, err := rs.db.Get()
+	if err != nil {
+		return nil, err
+	}
+	defer rs.db.Put(conn)
+	keys, err := conn.Cmd("KEYS", utils.SMG_SESSIONS_PREFIX+"*").List()
+	if err != nil {
+		return nil, err
+	}
+	for _, key := range keys {
+		values, err := conn.Cmd("GET", key).Bytes()
+		if err != nil {
+			return nil, err
+		}
+		smgCp := new(SMGSessionCheckpoint)
+		if err = rs.ms.Unmarshal(values, smgCp); err != nil {
+			return nil, err
+		}
+		ccsValues, err := conn.Cmd("LRANGE", utils.SMG_SESSION_COSTS_PREFIX+smgCp.GetId(), 0, -1).ListBytes()
+		if err != nil {
+			return nil, err
+		}
+		for _, ccValues := range ccsValues {
+			cc := new(CallCost)
+			if err = rs.ms.Unmarshal(ccValues, cc); err != nil {
+				return nil, err
+			}
+			smgCp.CallCosts = append(smgCp.CallCosts, cc)
+		}
+		smgCps = append(smgCps, smgCp)
+	}
+	return
+}
+
+// Stores the session state, without the CallCosts which are appended by AddSMGSessionCallCosts
+func (rs *RedisStorage) SetSMGSessionCheckpoint(smgCp *SMGSessionCheckpoint) error {
+	smgState := *smgCp
+	smgState.CallCosts = nil
+	result, err := rs.ms.Marshal(&smgState)
+	if err != nil {
+		return err
+	}
+	return rs.db.Cmd("SET", utils.SMG_SESSIONS_PREFIX+smgCp.GetId(), result).Err
+}
+
+func (rs *RedisStorage) AddSMGSessionCallCosts(id string, ccs []*CallCost) error {
+	if len(ccs) == 0 {
+		return nil
+	}
+	args := []interface{}{utils.SMG_SESSION_COSTS_PREFIX + id}
+	for _, cc := range ccs {
+		result, err := rs.ms.Marshal(cc)
+		if err != nil {
+			return err
+		}
+		args = append(args, result)
+	}
+	return rs.db.Cmd("RPUSH", args...).Err
+}
+
+func (rs *RedisStorage) RemoveSMGSessionCheckpoint(id string) error {
+	return rs.db.Cmd("DEL", utils.SMG_SESSIONS_PREFIX+id, utils.SMG_SESSION_COSTS_PREFIX+id).Err
+}
+
+func (rs *RedisStorage) GetCdrExportJobState(jobID string) (jobState *CdrExportJobState, err error) {
+	rpl := rs.db.Cmd("GET", utils.CdrExportJobsPrefix+jobID)
+	if rpl.Err != nil {
+		return nil, rpl.Err
+	} else if rpl.IsType(redis.Nil) {
+		return nil, utils.ErrNotFound
+	}
+	var values []byte
+	if values, err = rpl.Bytes(); err == nil {
+		err = rs.ms.Unmarshal(values, &jobState)
+	}
+	return
+}
+
+func (rs *RedisStorage) SetCdrExportJobState(jobState *CdrExportJobState) error {
+	result, err := rs.ms.Marshal(jobState)
+	if err != nil {
+		return err
+	}
+	return rs.db.Cmd("SET", utils.CdrExportJobsPrefix+jobState.JobID, result).Err
+}
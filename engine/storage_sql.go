@@ -28,6 +28,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/cgrates/cgrates/history"
 	"github.com/cgrates/cgrates/utils"
 	"github.com/jinzhu/gorm"
 )
@@ -170,7 +171,7 @@ func (self *SQLStorage) RemTpData(table, tpid string, args map[string]string) er
 	if len(table) == 0 { // Remove tpid out of all tables
 		for _, tblName := range []string{utils.TBL_TP_TIMINGS, utils.TBL_TP_DESTINATIONS, utils.TBL_TP_RATES, utils.TBL_TP_DESTINATION_RATES, utils.TBL_TP_RATING_PLANS, utils.TBL_TP_RATE_PROFILES,
 			utils.TBL_TP_SHARED_GROUPS, utils.TBL_TP_CDR_STATS, utils.TBL_TP_LCRS, utils.TBL_TP_ACTIONS, utils.TBL_TP_ACTION_PLANS, utils.TBL_TP_ACTION_TRIGGERS, utils.TBL_TP_ACCOUNT_ACTIONS,
-			utils.TBL_TP_DERIVED_CHARGERS, utils.TBL_TP_ALIASES, utils.TBLTPResourceLimits} {
+			utils.TBL_TP_DERIVED_CHARGERS, utils.TBL_TP_ALIASES, utils.TBLTPResourceLimits, utils.TBLTPExchangeRates, utils.TBLTPTaxRules} {
 			if err := tx.Table(tblName).Where("tpid = ?", tpid).Delete(nil).Error; err != nil {
 				tx.Rollback()
 				return err
@@ -630,6 +631,144 @@ func (self *SQLStorage) GetSMCosts(cgrid, runid, originHost, originIDPrefix stri
 	return smCosts, nil
 }
 
+func (self *SQLStorage) SetHistoryRecord(chRec *history.ChangeRecord) error {
+	diff, err := json.Marshal(chRec.Diff)
+	if err != nil {
+		return err
+	}
+	return self.db.Save(&TBLHistoryRecords{
+		ObjectType: chRec.ObjectType,
+		ObjectID:   chRec.ObjectID,
+		Version:    chRec.Version,
+		Author:     chRec.Author,
+		ChangeTime: chRec.Timestamp,
+		Deleted:    chRec.Deleted,
+		Payload:    string(chRec.Payload),
+		Diff:       string(diff),
+	}).Error
+}
+
+func (self *SQLStorage) GetLastHistoryRecord(objType, objID string) (*history.ChangeRecord, error) {
+	var result TBLHistoryRecords
+	if err := self.db.Where(&TBLHistoryRecords{ObjectType: objType, ObjectID: objID}).Order("version desc").First(&result).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, utils.ErrNotFound
+		}
+		return nil, err
+	}
+	return result.AsChangeRecord()
+}
+
+func (self *SQLStorage) GetHistoryRecords(filter *history.RecordFilter) ([]*history.ChangeRecord, error) {
+	q := self.db.Where(&TBLHistoryRecords{ObjectType: filter.ObjectType, ObjectID: filter.ObjectID})
+	if !filter.TimeStart.IsZero() {
+		q = q.Where("change_time >= ?", filter.TimeStart)
+	}
+	if !filter.TimeEnd.IsZero() {
+		q = q.Where("change_time < ?", filter.TimeEnd)
+	}
+	q = q.Order("id")
+	if filter.Paginator.Limit != nil {
+		q = q.Limit(*filter.Paginator.Limit)
+	}
+	if filter.Paginator.Offset != nil {
+		q = q.Offset(*filter.Paginator.Offset)
+	}
+	var results []*TBLHistoryRecords
+	if err := q.Find(&results).Error; err != nil {
+		return nil, err
+	}
+	chRecs := make([]*history.ChangeRecord, len(results))
+	for i, result := range results {
+		chRec, err := result.AsChangeRecord()
+		if err != nil {
+			return nil, err
+		}
+		chRecs[i] = chRec
+	}
+	return chRecs, nil
+}
+
+func (t *TBLHistoryRecords) AsChangeRecord() (*history.ChangeRecord, error) {
+	chRec := &history.ChangeRecord{
+		ObjectType: t.ObjectType,
+		ObjectID:   t.ObjectID,
+		Version:    t.Version,
+		Author:     t.Author,
+		Timestamp:  t.ChangeTime,
+		Deleted:    t.Deleted,
+	}
+	if t.Payload != "" {
+		chRec.Payload = json.RawMessage(t.Payload)
+	}
+	if t.Diff != "" {
+		if err := json.Unmarshal([]byte(t.Diff), &chRec.Diff); err != nil {
+			return nil, err
+		}
+	}
+	return chRec, nil
+}
+
+func (self *SQLStorage) SetBalanceLedgerEntries(entries []*BalanceLedgerEntry) error {
+	tx := self.db.Begin()
+	for _, entry := range entries {
+		if err := tx.Save(&TBLBalanceLedger{
+			Account:     entry.Account,
+			BalanceUuid: entry.BalanceUUID,
+			BalanceID:   entry.BalanceID,
+			BalanceType: entry.BalanceType,
+			Delta:       entry.Delta,
+			Value:       entry.Value,
+			Cgrid:       entry.CGRID,
+			ActionsID:   entry.ActionsID,
+			Source:      entry.Source,
+			CreatedAt:   entry.CreatedAt,
+		}).Error; err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	tx.Commit()
+	return nil
+}
+
+func (self *SQLStorage) GetBalanceLedger(filter *BalanceLedgerFilter) ([]*BalanceLedgerEntry, error) {
+	q := self.db.Where(&TBLBalanceLedger{Account: filter.Account, BalanceUuid: filter.BalanceUUID, Cgrid: filter.CGRID, ActionsID: filter.ActionsID})
+	if !filter.TimeStart.IsZero() {
+		q = q.Where("created_at >= ?", filter.TimeStart)
+	}
+	if !filter.TimeEnd.IsZero() {
+		q = q.Where("created_at < ?", filter.TimeEnd)
+	}
+	q = q.Order("id")
+	if filter.Paginator.Limit != nil {
+		q = q.Limit(*filter.Paginator.Limit)
+	}
+	if filter.Paginator.Offset != nil {
+		q = q.Offset(*filter.Paginator.Offset)
+	}
+	var results []*TBLBalanceLedger
+	if err := q.Find(&results).Error; err != nil {
+		return nil, err
+	}
+	entries := make([]*BalanceLedgerEntry, len(results))
+	for i, result := range results {
+		entries[i] = &BalanceLedgerEntry{
+			Account:     result.Account,
+			BalanceUUID: result.BalanceUuid,
+			BalanceID:   result.BalanceID,
+			BalanceType: result.BalanceType,
+			Delta:       result.Delta,
+			Value:       result.Value,
+			CGRID:       result.Cgrid,
+			ActionsID:   result.ActionsID,
+			Source:      result.Source,
+			CreatedAt:   result.CreatedAt,
+		}
+	}
+	return entries, nil
+}
+
 func (self *SQLStorage) LogActionTrigger(ubId, source string, at *ActionTrigger, as Actions) (err error) {
 	return
 }
@@ -1344,6 +1483,80 @@ func (self *SQLStorage) GetTpAliases(filter *TpAlias) ([]TpAlias, error) {
 	return tpAliases, nil
 }
 
+func (self *SQLStorage) SetTpExchangeRates(exrs []TpExchangeRate) error {
+	if len(exrs) == 0 {
+		return nil //Nothing to set
+	}
+	m := make(map[string]bool)
+
+	tx := self.db.Begin()
+	for _, exr := range exrs {
+		if found, _ := m[exr.Tag]; !found {
+			m[exr.Tag] = true
+			if err := tx.Where(&TpExchangeRate{Tpid: exr.Tpid, Tag: exr.Tag}).Delete(TpExchangeRate{}).Error; err != nil {
+				tx.Rollback()
+				return err
+			}
+		}
+		saved := tx.Save(&exr)
+		if saved.Error != nil {
+			tx.Rollback()
+			return saved.Error
+		}
+	}
+	tx.Commit()
+	return nil
+}
+
+func (self *SQLStorage) GetTpExchangeRates(tpid, tag string) ([]TpExchangeRate, error) {
+	var tpExchangeRates []TpExchangeRate
+	q := self.db.Where("tpid = ?", tpid)
+	if len(tag) != 0 {
+		q = q.Where("tag = ?", tag)
+	}
+	if err := q.Find(&tpExchangeRates).Error; err != nil {
+		return nil, err
+	}
+	return tpExchangeRates, nil
+}
+
+func (self *SQLStorage) SetTpTaxRules(txrs []TpTaxRule) error {
+	if len(txrs) == 0 {
+		return nil //Nothing to set
+	}
+	m := make(map[string]bool)
+
+	tx := self.db.Begin()
+	for _, txr := range txrs {
+		if found, _ := m[txr.Tag]; !found {
+			m[txr.Tag] = true
+			if err := tx.Where(&TpTaxRule{Tpid: txr.Tpid, Tag: txr.Tag}).Delete(TpTaxRule{}).Error; err != nil {
+				tx.Rollback()
+				return err
+			}
+		}
+		saved := tx.Save(&txr)
+		if saved.Error != nil {
+			tx.Rollback()
+			return saved.Error
+		}
+	}
+	tx.Commit()
+	return nil
+}
+
+func (self *SQLStorage) GetTpTaxRules(tpid, tag string) ([]TpTaxRule, error) {
+	var tpTaxRules []TpTaxRule
+	q := self.db.Where("tpid = ?", tpid)
+	if len(tag) != 0 {
+		q = q.Where("tag = ?", tag)
+	}
+	if err := q.Find(&tpTaxRules).Error; err != nil {
+		return nil, err
+	}
+	return tpTaxRules, nil
+}
+
 func (self *SQLStorage) GetTpResourceLimits(tpid, tag string) (TpResourceLimits, error) {
 	var tpResourceLimits TpResourceLimits
 	q := self.db.Where("tpid = ?", tpid)
@@ -305,6 +305,68 @@ func TestStorageTask(t *testing.T) {
 	}
 }
 
+func TestStorageSMGSessionCheckpoint(t *testing.T) {
+	dataDB, _ := NewMapStorage()
+	smgCp := &SMGSessionCheckpoint{SessionID: "12345", RunID: utils.DEFAULT_RUNID, ConnID: "conn1",
+		EventStart:     map[string]interface{}{utils.ACCID: "12345", utils.ACCOUNT: "1001"},
+		CallDescriptor: &CallDescriptor{Direction: utils.OUT, Tenant: "cgrates.org", Category: "call", Subject: "1001", Destination: "1002", LoopIndex: 1},
+		CallCosts:      []*CallCost{&CallCost{Direction: utils.OUT, Destination: "1002", Cost: 0.6}},
+		TotalUsage:     time.Duration(30) * time.Second,
+		LastDebitTime:  time.Date(2016, 7, 1, 10, 0, 0, 0, time.UTC)}
+	if err := dataDB.SetSMGSessionCheckpoint(smgCp); err != nil {
+		t.Fatal(err)
+	}
+	// CallCosts are appended, not stored with the session state
+	for _, cost := range []float64{0.6, 0.3} {
+		if err := dataDB.AddSMGSessionCallCosts(smgCp.GetId(), []*CallCost{&CallCost{Direction: utils.OUT, Destination: "1002", Cost: cost}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if smgCps, err := dataDB.GetSMGSessionCheckpoints(); err != nil {
+		t.Error(err)
+	} else if len(smgCps) != 1 {
+		t.Errorf("Received: %+v", smgCps)
+	} else if rcv := smgCps[0]; rcv.GetId() != smgCp.GetId() || rcv.ConnID != "conn1" || rcv.EventStart[utils.ACCOUNT] != "1001" ||
+		rcv.CallDescriptor.LoopIndex != 1 || len(rcv.CallCosts) != 2 || rcv.CallCosts[0].Cost != 0.6 || rcv.CallCosts[1].Cost != 0.3 ||
+		rcv.TotalUsage != smgCp.TotalUsage || !rcv.LastDebitTime.Equal(smgCp.LastDebitTime) {
+		t.Errorf("Received: %s", utils.ToJSON(rcv))
+	}
+	if err := dataDB.RemoveSMGSessionCheckpoint(smgCp.GetId()); err != nil {
+		t.Error(err)
+	}
+	if smgCps, err := dataDB.GetSMGSessionCheckpoints(); err != nil {
+		t.Error(err)
+	} else if len(smgCps) != 0 {
+		t.Errorf("Received: %+v", smgCps)
+	}
+	// Stored again, eg: after relocation, no CallCosts left from before
+	if err := dataDB.SetSMGSessionCheckpoint(smgCp); err != nil {
+		t.Fatal(err)
+	}
+	if smgCps, err := dataDB.GetSMGSessionCheckpoints(); err != nil {
+		t.Error(err)
+	} else if len(smgCps) != 1 || len(smgCps[0].CallCosts) != 0 {
+		t.Errorf("Received: %s", utils.ToJSON(smgCps))
+	}
+}
+
+func TestStorageCdrExportJobState(t *testing.T) {
+	dataDB, _ := NewMapStorage()
+	if _, err := dataDB.GetCdrExportJobState("daily"); err != utils.ErrNotFound {
+		t.Error("Expecting not found, received: ", err)
+	}
+	jobState := &CdrExportJobState{JobID: "daily", LastOrderID: 123,
+		LastRun: time.Date(2016, 7, 1, 0, 5, 0, 0, time.UTC), LastFile: "/var/spool/cgrates/cdre/daily_20160630.csv"}
+	if err := dataDB.SetCdrExportJobState(jobState); err != nil {
+		t.Fatal(err)
+	}
+	if rcv, err := dataDB.GetCdrExportJobState("daily"); err != nil {
+		t.Error(err)
+	} else if rcv.LastOrderID != jobState.LastOrderID || !rcv.LastRun.Equal(jobState.LastRun) || rcv.LastFile != jobState.LastFile {
+		t.Errorf("Received: %s", utils.ToJSON(rcv))
+	}
+}
+
 /************************** Benchmarks *****************************/
 
 func GetUB() *Account {
@@ -481,3 +543,31 @@ func BenchmarkMarshallerBincStoreRestore(b *testing.B) {
 		ms.Unmarshal(result, ub1)
 	}
 }
+
+func TestStorageCacheExchangeRates(t *testing.T) {
+	ms, _ := NewMapStorage()
+	exr := &ExchangeRate{FromCurrency: "CHF", ToCurrency: "RON", Rate: 4.25}
+	if err := ms.SetExchangeRate(exr); err != nil {
+		t.Fatal(err)
+	}
+	if rcv, err := ms.GetExchangeRate("CHF:RON", false); err != nil || rcv.Rate != 4.25 {
+		t.Errorf("Unexpected exchange rate: %+v, error: %v", rcv, err)
+	}
+	// changes done directly in the db are visible after cache reload
+	ms.dict[utils.ExchangeRatesPrefix+"CHF:RON"], _ = ms.ms.Marshal(&ExchangeRate{FromCurrency: "CHF", ToCurrency: "RON", Rate: 4.5})
+	if rcv, err := ms.GetExchangeRate("CHF:RON", false); err != nil || rcv.Rate != 4.25 {
+		t.Errorf("Unexpected exchange rate: %+v, error: %v", rcv, err)
+	}
+	if err := ms.CacheRatingPrefixes("TestStorageCacheExchangeRates", utils.ExchangeRatesPrefix); err != nil {
+		t.Fatal(err)
+	}
+	if rcv, err := ms.GetExchangeRate("CHF:RON", false); err != nil || rcv.Rate != 4.5 {
+		t.Errorf("Unexpected exchange rate: %+v, error: %v", rcv, err)
+	}
+	if err := ms.RemoveExchangeRate("CHF:RON"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ms.GetExchangeRate("CHF:RON", false); err == nil {
+		t.Error("Exchange rate still cached after removal")
+	}
+}
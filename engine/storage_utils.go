@@ -22,6 +22,7 @@ import (
 	"errors"
 	"fmt"
 	"strconv"
+	"time"
 
 	"github.com/cgrates/cgrates/utils"
 )
@@ -163,6 +164,40 @@ type SMCost struct {
 	CostDetails *CallCost
 }
 
+// State of one SMGeneric session run, checkpointed so the session can be recovered after restart.
+// DataDB stores the CallCosts apart, appended with AddSMGSessionCallCosts so the debits already stored are not written again.
+type SMGSessionCheckpoint struct {
+	SessionID      string // OriginID the session is indexed on
+	RunID          string
+	ConnID         string
+	EventStart     map[string]interface{}
+	CallDescriptor *CallDescriptor
+	CallCosts      []*CallCost
+	ExtraDuration  time.Duration
+	LastUsage      time.Duration
+	LastDebit      time.Duration
+	TotalUsage     time.Duration
+	LastDebitTime  time.Time
+	// Session TTL settings received with the updates, overriding the ones of EventStart
+	SessionTTL         time.Duration
+	SessionTTLLastUsed *time.Duration
+	SessionTTLUsage    *time.Duration
+}
+
+func (smgCp *SMGSessionCheckpoint) GetId() string {
+	return utils.ConcatenatedKey(smgCp.SessionID, smgCp.RunID)
+}
+
+// Progress of a scheduled CDR export job, next run will continue after LastOrderID
+type CdrExportJobState struct {
+	JobID       string
+	LastOrderID int64     // Highest OrderID processed by the job
+	FailedCDRs  []string  // CGRID:RunID of the CDRs which could not be exported, retried on the next runs
+	RangeEnd    time.Time // End of the time range in the last run, start for the next one while LastOrderID is 0
+	LastRun     time.Time // Time of the last run
+	LastFile    string    // Path of the last file written
+}
+
 type AttrCDRSStoreSMCost struct {
 	Cost           *SMCost
 	CheckDuplicate bool
@@ -0,0 +1,154 @@
+/*
+Real-time Charging System for Telecom & ISP environments
+Copyright (C) ITsysCOM GmbH
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>
+*/
+
+package engine
+
+import (
+	"sort"
+	"strconv"
+
+	"github.com/cgrates/cgrates/utils"
+)
+
+// Group of tax rules loaded out of the same TaxRules profile
+type TaxRules struct {
+	ID    string
+	Rules []*TaxRule
+}
+
+// Local tax applied on the cost of the CDRs
+type TaxRule struct {
+	Tenant         string
+	DestinationIDs utils.StringMap // empty matches any destination
+	TaxName        string
+	Percent        float64 // percentage out of the cost
+	PerMinute      float64 // fixed amount per minute of usage, voice CDRs only
+	Inclusive      bool    // the tax is already part of the rated cost
+	Weight         float64
+}
+
+// Checks tenant and destination against the rule, destIDs are the destinations matching the CDR
+func (txr *TaxRule) matches(tenant string, destIDs map[string]struct{}) bool {
+	if txr.Tenant != "" && txr.Tenant != utils.ANY && txr.Tenant != tenant {
+		return false
+	}
+	if len(txr.DestinationIDs) == 0 || txr.DestinationIDs[utils.ANY] {
+		return true
+	}
+	for dID := range destIDs {
+		if txr.DestinationIDs[dID] {
+			return true
+		}
+	}
+	return false
+}
+
+// Calculates the tax out of the cost and usage, rounded with the global decimals
+func (txr *TaxRule) getAmount(cost, usageMinutes float64) (amount float64) {
+	if txr.Percent != 0 {
+		if txr.Inclusive {
+			amount = cost - cost/(1+txr.Percent/100)
+		} else {
+			amount = cost * txr.Percent / 100
+		}
+	}
+	amount += usageMinutes * txr.PerMinute
+	return utils.Round(amount, globalRoundingDecimals, utils.ROUNDING_MIDDLE)
+}
+
+// Taxes calculated for one CDR
+type TaxCost struct {
+	Taxes     map[string]float64 // amount per TaxName
+	Inclusive float64            // taxes already contained in the cost
+	Exclusive float64            // taxes to be added on top of the cost
+}
+
+// Total amount of taxes
+func (tc *TaxCost) GetTotal() float64 {
+	return utils.Round(tc.Inclusive+tc.Exclusive, globalRoundingDecimals, utils.ROUNDING_MIDDLE)
+}
+
+// Returns the tax rules out of cache, ordered on their ID
+func getCachedTaxRules() (allTxrs []*TaxRules) {
+	for _, key := range CacheGetEntriesKeys(utils.TaxRulesPrefix) {
+		if x, err := CacheGet(key); err == nil {
+			allTxrs = append(allTxrs, x.(*TaxRules))
+		}
+	}
+	sort.Sort(taxRulesByID(allTxrs))
+	return
+}
+
+type taxRulesByID []*TaxRules
+
+func (txrs taxRulesByID) Len() int           { return len(txrs) }
+func (txrs taxRulesByID) Swap(i, j int)      { txrs[i], txrs[j] = txrs[j], txrs[i] }
+func (txrs taxRulesByID) Less(i, j int) bool { return txrs[i].ID < txrs[j].ID }
+
+// Applies the local tax rules on the cost of the CDR, only one rule per TaxName is considered, the one with the highest weight.
+// On equal weights the rule listed first wins, profiles being ordered on their ID.
+func GetCDRTaxes(cdr *CDR) (*TaxCost, error) {
+	allTxrs := getCachedTaxRules()
+	destIDs := make(map[string]struct{})
+	for _, p := range utils.SplitPrefix(cdr.Destination, MIN_PREFIX_MATCH) {
+		if x, err := CacheGet(utils.DESTINATION_PREFIX + p); err == nil {
+			for dID := range x.(map[string]struct{}) {
+				destIDs[dID] = struct{}{}
+			}
+		}
+	}
+	matched := make(map[string]*TaxRule)
+	for _, txrs := range allTxrs {
+		for _, txr := range txrs.Rules {
+			if !txr.matches(cdr.Tenant, destIDs) {
+				continue
+			}
+			if prev, has := matched[txr.TaxName]; !has || txr.Weight > prev.Weight {
+				matched[txr.TaxName] = txr
+			}
+		}
+	}
+	var usageMinutes float64
+	if cdr.ToR == utils.VOICE {
+		usageMinutes = cdr.Usage.Minutes()
+	}
+	tc := &TaxCost{Taxes: make(map[string]float64)}
+	for taxName, txr := range matched {
+		amount := txr.getAmount(cdr.Cost, usageMinutes)
+		tc.Taxes[taxName] = amount
+		if txr.Inclusive {
+			tc.Inclusive += amount
+		} else {
+			tc.Exclusive += amount
+		}
+	}
+	return tc, nil
+}
+
+// Writes the taxes on the CDR, its cost becomes the total of the taxes and the amounts are available as extra fields
+func (tc *TaxCost) applyOnCDR(cdr *CDR) {
+	if cdr.ExtraFields == nil {
+		cdr.ExtraFields = make(map[string]string)
+	}
+	for taxName, amount := range tc.Taxes {
+		cdr.ExtraFields[taxName] = strconv.FormatFloat(amount, 'f', -1, 64)
+	}
+	cdr.ExtraFields[utils.TaxNetCost] = strconv.FormatFloat(utils.Round(cdr.Cost-tc.Inclusive, globalRoundingDecimals, utils.ROUNDING_MIDDLE), 'f', -1, 64)
+	cdr.ExtraFields[utils.TaxGrossCost] = strconv.FormatFloat(utils.Round(cdr.Cost+tc.Exclusive, globalRoundingDecimals, utils.ROUNDING_MIDDLE), 'f', -1, 64)
+	cdr.Cost = tc.GetTotal()
+}
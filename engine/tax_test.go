@@ -0,0 +1,159 @@
+/*
+Real-time Charging System for Telecom & ISP environments
+Copyright (C) ITsysCOM GmbH
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>
+*/
+
+package engine
+
+import (
+	"testing"
+	"time"
+
+	"github.com/cgrates/cgrates/config"
+	"github.com/cgrates/cgrates/utils"
+)
+
+func TestTaxRuleGetAmount(t *testing.T) {
+	txr := &TaxRule{TaxName: "VAT", Percent: 20}
+	if amount := txr.getAmount(1.2, 2); amount != 0.24 {
+		t.Errorf("Wrong exclusive amount: %v", amount)
+	}
+	txr.Inclusive = true
+	if amount := txr.getAmount(1.2, 2); amount != 0.2 {
+		t.Errorf("Wrong inclusive amount: %v", amount)
+	}
+	txr = &TaxRule{TaxName: "EXCISE", PerMinute: 0.01}
+	if amount := txr.getAmount(1.2, 2.5); amount != 0.025 {
+		t.Errorf("Wrong per minute amount: %v", amount)
+	}
+}
+
+func TestGetCDRTaxes(t *testing.T) {
+	if err := ratingStorage.SetDestination(&Destination{Id: "GERMANY", Prefixes: []string{"+49"}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := ratingStorage.CacheRatingPrefixes("TestGetCDRTaxes", utils.DESTINATION_PREFIX); err != nil {
+		t.Fatal(err)
+	}
+	cdr := &CDR{Tenant: "cgrates.org", Destination: "+4986517174963", RunID: utils.MetaTax, ToR: utils.VOICE,
+		Usage: time.Duration(2) * time.Minute, Cost: 1, ExtraFields: make(map[string]string)}
+	tc, err := GetCDRTaxes(cdr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the VAT for GERMANY has higher weight than the tenant default
+	if len(tc.Taxes) != 2 || tc.Taxes["VAT"] != 0.19 || tc.Taxes["EXCISE"] != 0.02 ||
+		tc.Exclusive != 0.19 || tc.Inclusive != 0.02 {
+		t.Errorf("Unexpected taxes: %s", utils.ToJSON(tc))
+	}
+	tc.applyOnCDR(cdr)
+	if cdr.Cost != 0.21 || cdr.ExtraFields["VAT"] != "0.19" || cdr.ExtraFields["EXCISE"] != "0.02" ||
+		cdr.ExtraFields[utils.TaxNetCost] != "0.98" || cdr.ExtraFields[utils.TaxGrossCost] != "1.19" {
+		t.Errorf("Unexpected CDR: %s", utils.ToJSON(cdr))
+	}
+	cdr = &CDR{Tenant: "cgrates.org", Destination: "447956933443", Usage: time.Duration(2) * time.Minute, Cost: 1}
+	if tc, err := GetCDRTaxes(cdr); err != nil {
+		t.Error(err)
+	} else if len(tc.Taxes) != 1 || tc.Taxes["VAT"] != 0.2 {
+		t.Errorf("Unexpected taxes: %s", utils.ToJSON(tc))
+	}
+	cdr = &CDR{Tenant: "other.org", Destination: "447956933443", Usage: time.Duration(2) * time.Minute, Cost: 1}
+	if tc, err := GetCDRTaxes(cdr); err != nil {
+		t.Error(err)
+	} else if len(tc.Taxes) != 0 || tc.GetTotal() != 0 {
+		t.Errorf("Unexpected taxes: %s", utils.ToJSON(tc))
+	}
+	// per minute taxes apply only to voice usage
+	cdr = &CDR{Tenant: "cgrates.org", Destination: "+4986517174963", ToR: utils.DATA, Usage: time.Duration(120), Cost: 1}
+	if tc, err := GetCDRTaxes(cdr); err != nil {
+		t.Error(err)
+	} else if len(tc.Taxes) != 2 || tc.Taxes["VAT"] != 0.19 || tc.Taxes["EXCISE"] != 0 {
+		t.Errorf("Unexpected taxes: %s", utils.ToJSON(tc))
+	}
+}
+
+func TestGetCDRTaxesEqualWeight(t *testing.T) {
+	for _, txrs := range []*TaxRules{
+		&TaxRules{ID: "TXR_ECO_Z", Rules: []*TaxRule{&TaxRule{Tenant: "eco.org", TaxName: "ECO", Percent: 2, Weight: 10}}},
+		&TaxRules{ID: "TXR_ECO_A", Rules: []*TaxRule{&TaxRule{Tenant: "eco.org", TaxName: "ECO", Percent: 1, Weight: 10}}},
+	} {
+		if err := ratingStorage.SetTaxRules(txrs); err != nil {
+			t.Fatal(err)
+		}
+		defer ratingStorage.RemoveTaxRules(txrs.ID)
+	}
+	cdr := &CDR{Tenant: "eco.org", Destination: "447956933443", ToR: utils.VOICE, Usage: time.Duration(2) * time.Minute, Cost: 1}
+	for i := 0; i < 10; i++ {
+		if tc, err := GetCDRTaxes(cdr); err != nil {
+			t.Fatal(err)
+		} else if len(tc.Taxes) != 1 || tc.Taxes["ECO"] != 0.01 {
+			t.Fatalf("Unexpected taxes: %s", utils.ToJSON(tc))
+		}
+	}
+}
+
+// Returns the SM costs of the runs debited in session
+type taxTestCdrStorage struct {
+	CdrStorage
+	smCosts []*SMCost
+}
+
+func (self *taxTestCdrStorage) GetSMCosts(cgrid, runid, originHost, originIDPrefix string) (smCosts []*SMCost, err error) {
+	for _, smCost := range self.smCosts {
+		if smCost.CGRID == cgrid && smCost.RunID == runid {
+			smCosts = append(smCosts, smCost)
+		}
+	}
+	return
+}
+
+func TestCdrServerTaxRunNoDebit(t *testing.T) {
+	if err := ratingStorage.SetDestination(&Destination{Id: "GERMANY", Prefixes: []string{"+49"}}); err != nil {
+		t.Fatal(err)
+	}
+	acnt := &Account{ID: utils.ConcatenatedKey("cgrates.org", "taxed"),
+		BalanceMap: map[string]Balances{utils.MONETARY: Balances{&Balance{Value: 10, Weight: 10}}}}
+	if err := accountingStorage.SetAccount(acnt); err != nil {
+		t.Fatal(err)
+	}
+	if err := ratingStorage.SetDerivedChargers(utils.DerivedChargersKey(utils.OUT, "cgrates.org", "call", "taxed", "money"),
+		&utils.DerivedChargers{Chargers: []*utils.DerivedCharger{&utils.DerivedCharger{RunID: utils.MetaTax,
+			RequestTypeField: utils.META_DEFAULT, DirectionField: utils.META_DEFAULT, TenantField: utils.META_DEFAULT,
+			CategoryField: utils.META_DEFAULT, AccountField: utils.META_DEFAULT, SubjectField: utils.META_DEFAULT,
+			DestinationField: utils.META_DEFAULT, SetupTimeField: utils.META_DEFAULT, AnswerTimeField: utils.META_DEFAULT,
+			UsageField: utils.META_DEFAULT}}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := ratingStorage.CacheRatingAll("TestCdrServerTaxRunNoDebit"); err != nil {
+		t.Fatal(err)
+	}
+	tm := time.Date(2016, 10, 18, 10, 0, 0, 0, time.UTC)
+	cdr := &CDR{CGRID: utils.Sha1("taxed1", tm.String()), ToR: utils.VOICE, OriginID: "taxed1", RequestType: utils.META_PREPAID,
+		Direction: utils.OUT, Tenant: "cgrates.org", Category: "call", Account: "taxed", Subject: "money", Destination: "+4986517174963",
+		SetupTime: tm, AnswerTime: tm, Usage: time.Duration(2) * time.Minute, RunID: utils.MetaRaw, Cost: -1,
+		ExtraFields: make(map[string]string)}
+	cdrDb := &taxTestCdrStorage{smCosts: []*SMCost{ // The session has already debited the *default run
+		&SMCost{CGRID: cdr.CGRID, RunID: utils.META_DEFAULT, OriginID: "taxed1", CostDetails: &CallCost{Cost: 1}}}}
+	cdrS := &CdrServer{cgrCfg: config.CgrConfig(), cdrDb: cdrDb, rals: &Responder{}, guard: Guardian}
+	if err := cdrS.deriveRateStoreStatsReplicate(cdr, false, false, false); err != nil {
+		t.Fatal(err)
+	}
+	if acnt, err := accountingStorage.GetAccount(acnt.ID); err != nil {
+		t.Error(err)
+	} else if acnt.BalanceMap[utils.MONETARY][0].GetValue() != 10 {
+		t.Errorf("Account debited by the tax run: %s", utils.ToJSON(acnt))
+	}
+}
@@ -40,6 +40,7 @@ type TimeSpan struct {
 	MatchedSubject, MatchedPrefix, MatchedDestId, RatingPlanId string
 	CompressFactor                                             int
 	ratingInfo                                                 *RatingInfo
+	volumes                                                    map[string]time.Duration // usage to add to the group start of volume tiered ratings, indexed on counter ID
 }
 
 type Increment struct {
@@ -81,6 +82,7 @@ type MonetaryInfo struct {
 	ID           string
 	Value        float64
 	RateInterval *RateInterval
+	ExchangeRate float64 // the debited value is the cost converted with it, 0 when not converted
 }
 
 func (mi *MonetaryInfo) Clone() *MonetaryInfo {
@@ -96,6 +98,7 @@ func (mi *MonetaryInfo) Equal(other *MonetaryInfo) bool {
 		return false
 	}
 	return mi.UUID == other.UUID &&
+		mi.ExchangeRate == other.ExchangeRate &&
 		reflect.DeepEqual(mi.RateInterval, other.RateInterval)
 }
 
@@ -465,10 +468,10 @@ func (ts *TimeSpan) SplitByRateInterval(i *RateInterval, data bool) (nts *TimeSp
 		i.Rating.Rates.Sort()
 		for _, rate := range i.Rating.Rates {
 			//Logger.Debug(fmt.Sprintf("Rate: %+v", rate))
-			if ts.GetGroupStart() < rate.GroupIntervalStart && ts.GetGroupEnd() > rate.GroupIntervalStart {
+			if ts.groupStart(i) < rate.GroupIntervalStart && ts.groupEnd(i) > rate.GroupIntervalStart {
 				//log.Print("Splitting")
 				ts.SetRateInterval(i)
-				splitTime := ts.TimeStart.Add(rate.GroupIntervalStart - ts.GetGroupStart())
+				splitTime := ts.TimeStart.Add(rate.GroupIntervalStart - ts.groupStart(i))
 				nts = &TimeSpan{
 					TimeStart: splitTime,
 					TimeEnd:   ts.TimeEnd,
@@ -634,15 +637,40 @@ func (ts *TimeSpan) SplitByDay() (newTs *TimeSpan) {
 
 // Returns the starting time of this timespan
 func (ts *TimeSpan) GetGroupStart() time.Duration {
+	return ts.groupStart(ts.RateInterval)
+}
+
+func (ts *TimeSpan) GetGroupEnd() time.Duration {
+	return ts.groupEnd(ts.RateInterval)
+}
+
+// Usage counted before the call for volume tiered ratings, zero for the rest
+func (ts *TimeSpan) getVolume(ri *RateInterval) time.Duration {
+	if ri == nil || ri.Rating == nil || ri.Rating.VolumeCounter == "" {
+		return 0
+	}
+	return ts.volumes[ri.Rating.VolumeCounter]
+}
+
+// Starting time of this timespan as seen by the rate groups of the interval
+func (ts *TimeSpan) groupStart(ri *RateInterval) time.Duration {
 	s := ts.DurationIndex - ts.GetDuration()
 	if s < 0 {
 		s = 0
 	}
+	s += ts.getVolume(ri)
+	if s < 0 {
+		s = 0
+	}
 	return s
 }
 
-func (ts *TimeSpan) GetGroupEnd() time.Duration {
-	return ts.DurationIndex
+func (ts *TimeSpan) groupEnd(ri *RateInterval) time.Duration {
+	e := ts.DurationIndex + ts.getVolume(ri)
+	if e < 0 {
+		e = 0
+	}
+	return e
 }
 
 // sets the DurationIndex attribute to reflect new timespan
@@ -655,6 +683,7 @@ func (ts *TimeSpan) SetNewDurationIndex(nts *TimeSpan) {
 }
 
 func (nts *TimeSpan) copyRatingInfo(ts *TimeSpan) {
+	nts.volumes = ts.volumes
 	if ts.ratingInfo == nil {
 		return
 	}
@@ -718,7 +747,7 @@ func (ts *TimeSpan) hasBetterRateIntervalThan(interval *RateInterval) bool {
 		return false
 	}
 	ownPrice, _, _ := ts.RateInterval.GetRateParameters(ts.GetGroupStart())
-	otherPrice, _, _ := interval.GetRateParameters(ts.GetGroupStart())
+	otherPrice, _, _ := interval.GetRateParameters(ts.groupStart(interval))
 	// if own price is smaller than it's better
 	//log.Print(ownPrice, otherPrice)
 	if ownPrice < otherPrice {
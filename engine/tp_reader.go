@@ -36,6 +36,8 @@ type TpReader struct {
 	users             map[string]*UserProfile
 	aliases           map[string]*Alias
 	resLimits         map[string]*utils.TPResourceLimit
+	exchangeRates     map[string]*ExchangeRate
+	taxRules          map[string]*TaxRules
 }
 
 func NewTpReader(rs RatingStorage, as AccountingStorage, lr LoadReader, tpid, timezone string) *TpReader {
@@ -87,6 +89,8 @@ func (tpr *TpReader) Init() {
 	tpr.aliases = make(map[string]*Alias)
 	tpr.derivedChargers = make(map[string]*utils.DerivedChargers)
 	tpr.resLimits = make(map[string]*utils.TPResourceLimit)
+	tpr.exchangeRates = make(map[string]*ExchangeRate)
+	tpr.taxRules = make(map[string]*TaxRules)
 }
 
 func (tpr *TpReader) LoadDestinationsFiltered(tag string) (bool, error) {
@@ -577,6 +581,9 @@ func (tpr *TpReader) LoadActions() (err error) {
 				}
 				acts[idx].Balance.Disabled = utils.BoolPointer(u)
 			}
+			if tpact.BalanceCurrency != "" && tpact.BalanceCurrency != utils.ANY {
+				acts[idx].Balance.Currency = utils.StringPointer(tpact.BalanceCurrency)
+			}
 
 			// load action timings from tags
 			if tpact.TimingTags != "" {
@@ -1052,6 +1059,9 @@ func (tpr *TpReader) LoadAccountActionsFiltered(qriedAA *TpAccountAction) error
 						}
 						acts[idx].Balance.Disabled = utils.BoolPointer(u)
 					}
+					if tpact.BalanceCurrency != "" && tpact.BalanceCurrency != utils.ANY {
+						acts[idx].Balance.Currency = utils.StringPointer(tpact.BalanceCurrency)
+					}
 					// load action timings from tags
 					if tpact.TimingTags != "" {
 						timingIds := strings.Split(tpact.TimingTags, utils.INFIELD_SEP)
@@ -1093,6 +1103,7 @@ func (tpr *TpReader) LoadAccountActionsFiltered(qriedAA *TpAccountAction) error
 		if err := tpr.accountingStorage.SetAccount(ub); err != nil {
 			return err
 		}
+		RecordAccountHistory(ub)
 	}
 	return nil
 }
@@ -1310,7 +1321,9 @@ func (tpr *TpReader) LoadCdrStatsFiltered(tag string, save bool) (err error) {
 			if err != nil {
 				return errors.New(err.Error() + " (SetActionTriggers): " + triggerTag)
 			}
-			UpdateCdrStats(cs, triggers, tpStat, tpr.timezone)
+			if err = UpdateCdrStats(cs, triggers, tpStat, tpr.timezone); err != nil {
+				return err
+			}
 			tpr.cdrStats[tag] = cs
 		}
 	}
@@ -1400,6 +1413,9 @@ func (tpr *TpReader) LoadCdrStatsFiltered(tag string, save bool) (err error) {
 						}
 						acts[idx].Balance.Disabled = utils.BoolPointer(u)
 					}
+					if tpact.BalanceCurrency != "" && tpact.BalanceCurrency != utils.ANY {
+						acts[idx].Balance.Currency = utils.StringPointer(tpact.BalanceCurrency)
+					}
 
 				}
 				tpr.actions[tag] = acts
@@ -1556,6 +1572,82 @@ func (tpr *TpReader) LoadResourceLimits() error {
 	return tpr.LoadResourceLimitsFiltered("")
 }
 
+func (tpr *TpReader) LoadExchangeRatesFiltered(tag string) error {
+	tps, err := tpr.lr.GetTpExchangeRates(tpr.tpid, tag)
+	if err != nil {
+		return err
+	}
+	storExrs, err := TpExchangeRates(tps).GetExchangeRates()
+	if err != nil {
+		return err
+	}
+	for tag, tpExrs := range storExrs {
+		for _, tpExr := range tpExrs {
+			if tpExr.FromCurrency == tpExr.ToCurrency || tpExr.Rate <= 0 {
+				return fmt.Errorf("invalid exchange rate %s: %s to %s at %v", tag, tpExr.FromCurrency, tpExr.ToCurrency, tpExr.Rate)
+			}
+			exr := &ExchangeRate{
+				FromCurrency: tpExr.FromCurrency,
+				ToCurrency:   tpExr.ToCurrency,
+				Rate:         tpExr.Rate,
+			}
+			tpr.exchangeRates[exr.GetId()] = exr
+		}
+	}
+	return nil
+}
+
+func (tpr *TpReader) LoadExchangeRates() error {
+	return tpr.LoadExchangeRatesFiltered("")
+}
+
+func (tpr *TpReader) LoadTaxRulesFiltered(tag string) error {
+	tps, err := tpr.lr.GetTpTaxRules(tpr.tpid, tag)
+	if err != nil {
+		return err
+	}
+	storTxrs, err := TpTaxRules(tps).GetTaxRules()
+	if err != nil {
+		return err
+	}
+	for tag, tpTxrs := range storTxrs {
+		txrs := &TaxRules{ID: tag}
+		for _, tpTxr := range tpTxrs {
+			if tpTxr.Percent < 0 || tpTxr.PerMinute < 0 {
+				return fmt.Errorf("invalid tax rule %s: %s with negative amount", tag, tpTxr.TaxName)
+			}
+			txr := &TaxRule{
+				Tenant:         tpTxr.Tenant,
+				DestinationIDs: utils.ParseStringMap(tpTxr.DestinationIds),
+				TaxName:        tpTxr.TaxName,
+				Percent:        tpTxr.Percent,
+				PerMinute:      tpTxr.PerMinute,
+				Inclusive:      tpTxr.Inclusive,
+				Weight:         tpTxr.Weight,
+			}
+			for dstID := range txr.DestinationIDs {
+				if dstID == utils.ANY {
+					continue
+				}
+				if _, exists := tpr.destinations[dstID]; !exists {
+					if dbExists, err := tpr.ratingStorage.HasData(utils.DESTINATION_PREFIX, dstID); err != nil {
+						return err
+					} else if !dbExists {
+						return fmt.Errorf("could not get destination for tag %v", dstID)
+					}
+				}
+			}
+			txrs.Rules = append(txrs.Rules, txr)
+		}
+		tpr.taxRules[tag] = txrs
+	}
+	return nil
+}
+
+func (tpr *TpReader) LoadTaxRules() error {
+	return tpr.LoadTaxRulesFiltered("")
+}
+
 func (tpr *TpReader) LoadAll() error {
 	var err error
 	if err = tpr.LoadDestinations(); err != nil {
@@ -1609,6 +1701,12 @@ func (tpr *TpReader) LoadAll() error {
 	if err = tpr.LoadResourceLimits(); err != nil {
 		return err
 	}
+	if err = tpr.LoadExchangeRates(); err != nil {
+		return err
+	}
+	if err = tpr.LoadTaxRules(); err != nil {
+		return err
+	}
 	return nil
 }
 
@@ -1771,6 +1869,7 @@ func (tpr *TpReader) WriteToDatabase(flush, verbose bool) (err error) {
 		if err != nil {
 			return err
 		}
+		RecordAccountHistory(ub)
 		if verbose {
 			log.Println("\t", ub.ID)
 		}
@@ -1799,6 +1898,28 @@ func (tpr *TpReader) WriteToDatabase(flush, verbose bool) (err error) {
 			log.Print("\t", sq.Id)
 		}
 	}
+	if verbose {
+		log.Print("Exchange Rates:")
+	}
+	for _, exr := range tpr.exchangeRates {
+		if err = tpr.ratingStorage.SetExchangeRate(exr); err != nil {
+			return err
+		}
+		if verbose {
+			log.Print("\t", exr.GetId(), " : ", exr.Rate)
+		}
+	}
+	if verbose {
+		log.Print("Tax Rules:")
+	}
+	for _, txrs := range tpr.taxRules {
+		if err = tpr.ratingStorage.SetTaxRules(txrs); err != nil {
+			return err
+		}
+		if verbose {
+			log.Print("\t", txrs.ID)
+		}
+	}
 	if verbose {
 		log.Print("Users:")
 	}
@@ -1831,6 +1952,15 @@ func (tpr *TpReader) WriteToDatabase(flush, verbose bool) (err error) {
 		if err != nil {
 			return err
 		}
+		for _, atrID := range tpRL.ActionTriggerIDs {
+			atrs, exists := tpr.actionsTriggers[atrID]
+			if !exists {
+				return fmt.Errorf("could not get action triggers for tag %s", atrID)
+			}
+			for _, atr := range atrs {
+				rl.ActionTriggers = append(rl.ActionTriggers, atr.Clone())
+			}
+		}
 		if err = tpr.accountingStorage.SetResourceLimit(rl); err != nil {
 			return err
 		}
@@ -1898,6 +2028,10 @@ func (tpr *TpReader) ShowStatistics() {
 	log.Print("LCR rules: ", len(tpr.lcrs))
 	// cdr stats
 	log.Print("CDR stats: ", len(tpr.cdrStats))
+	// exchange rates
+	log.Print("Exchange rates: ", len(tpr.exchangeRates))
+	// tax rules
+	log.Print("Tax rules: ", len(tpr.taxRules))
 }
 
 // Returns the identities loaded for a specific category, useful for cache reloads
@@ -1983,6 +2117,30 @@ func (tpr *TpReader) GetLoadedIds(categ string) ([]string, error) {
 			i++
 		}
 		return keys, nil
+	case utils.ExchangeRatesPrefix:
+		keys := make([]string, len(tpr.exchangeRates))
+		i := 0
+		for k := range tpr.exchangeRates {
+			keys[i] = k
+			i++
+		}
+		return keys, nil
+	case utils.TaxRulesPrefix:
+		keys := make([]string, len(tpr.taxRules))
+		i := 0
+		for k := range tpr.taxRules {
+			keys[i] = k
+			i++
+		}
+		return keys, nil
+	case utils.ResourceLimitsPrefix:
+		keys := make([]string, len(tpr.resLimits))
+		i := 0
+		for k := range tpr.resLimits {
+			keys[i] = k
+			i++
+		}
+		return keys, nil
 	}
 	return nil, errors.New("Unsupported load category")
 }
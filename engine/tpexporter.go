@@ -152,6 +152,22 @@ func (self *TPExporter) Run() error {
 		}
 	}
 
+	if storData, err := self.storDb.GetTpExchangeRates(self.tpID, ""); err != nil {
+		return err
+	} else {
+		for _, sd := range storData {
+			toExportMap[utils.ExchangeRatesCsv] = append(toExportMap[utils.ExchangeRatesCsv], sd)
+		}
+	}
+
+	if storData, err := self.storDb.GetTpTaxRules(self.tpID, ""); err != nil {
+		return err
+	} else {
+		for _, sd := range storData {
+			toExportMap[utils.TaxRulesCsv] = append(toExportMap[utils.TaxRulesCsv], sd)
+		}
+	}
+
 	if storData, err := self.storDb.GetTpActions(self.tpID, ""); err != nil {
 		return err
 	} else {
@@ -57,6 +57,8 @@ var fileHandlers = map[string]func(*TPCSVImporter, string) error{
 	utils.LCRS_CSV:              (*TPCSVImporter).importLcrs,
 	utils.USERS_CSV:             (*TPCSVImporter).importUsers,
 	utils.ALIASES_CSV:           (*TPCSVImporter).importAliases,
+	utils.ExchangeRatesCsv:      (*TPCSVImporter).importExchangeRates,
+	utils.TaxRulesCsv:           (*TPCSVImporter).importTaxRules,
 }
 
 func (self *TPCSVImporter) Run() error {
@@ -78,6 +80,8 @@ func (self *TPCSVImporter) Run() error {
 		path.Join(self.DirPath, utils.USERS_CSV),
 		path.Join(self.DirPath, utils.ALIASES_CSV),
 		path.Join(self.DirPath, utils.ResourceLimitsCsv),
+		path.Join(self.DirPath, utils.ExchangeRatesCsv),
+		path.Join(self.DirPath, utils.TaxRulesCsv),
 	)
 	files, _ := ioutil.ReadDir(self.DirPath)
 	for _, f := range files {
@@ -346,3 +350,33 @@ func (self *TPCSVImporter) importAliases(fn string) error {
 	}
 	return self.StorDb.SetTpAliases(tps)
 }
+
+func (self *TPCSVImporter) importExchangeRates(fn string) error {
+	if self.Verbose {
+		log.Printf("Processing file: <%s> ", fn)
+	}
+	tps, err := self.csvr.GetTpExchangeRates(self.TPid, "")
+	if err != nil {
+		return err
+	}
+	for i := 0; i < len(tps); i++ {
+		tps[i].Tpid = self.TPid
+	}
+
+	return self.StorDb.SetTpExchangeRates(tps)
+}
+
+func (self *TPCSVImporter) importTaxRules(fn string) error {
+	if self.Verbose {
+		log.Printf("Processing file: <%s> ", fn)
+	}
+	tps, err := self.csvr.GetTpTaxRules(self.TPid, "")
+	if err != nil {
+		return err
+	}
+	for i := 0; i < len(tps); i++ {
+		tps[i].Tpid = self.TPid
+	}
+
+	return self.StorDb.SetTpTaxRules(tps)
+}
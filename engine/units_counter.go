@@ -88,6 +88,22 @@ func (ucs UnitCounters) addUnits(amount float64, kind string, cc *CallCost, b *B
 	}
 }
 
+// Adds the amount to the counters with the given ID, returns true if any was found
+func (ucs UnitCounters) addVolume(amount float64, kind, counterID string) (found bool) {
+	for _, uc := range ucs[kind] {
+		if uc == nil { // safeguard
+			continue
+		}
+		for _, c := range uc.Counters {
+			if c.Filter != nil && c.Filter.ID != nil && *c.Filter.ID == counterID {
+				c.Value += amount
+				found = true
+			}
+		}
+	}
+	return
+}
+
 func (ucs UnitCounters) resetCounters(a *Action) {
 	for key, counters := range ucs {
 		if a != nil && a.Balance.Type != nil && a.Balance.GetType() != key {
@@ -0,0 +1,356 @@
+/*
+Real-time Charging System for Telecom & ISP environments
+Copyright (C) ITsysCOM GmbH
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>
+*/
+
+package general_tests
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/cgrates/cgrates/engine"
+	"github.com/cgrates/cgrates/utils"
+)
+
+var ratingDbAcntFeatures engine.RatingStorage
+var acntDbAcntFeatures engine.AccountingStorage
+
+// acntFeaturesStep is one operation on the accounts of a tariff plan, followed by its checks
+type acntFeaturesStep struct {
+	descr string
+	do    func() error
+}
+
+// Loads the tariff plan together with its accounts, setup adding the data which cannot be defined out of CSV
+func loadAcntFeaturesTp(t *testing.T, dests, rates, destinationRates, ratingPlans, ratingProfiles, sharedGroups, exchangeRates string,
+	accounts []*engine.Account, setup func() error) {
+	ratingDbAcntFeatures, _ = engine.NewMapStorageJson()
+	engine.SetRatingStorage(ratingDbAcntFeatures)
+	acntDbAcntFeatures, _ = engine.NewMapStorageJson()
+	engine.SetAccountingStorage(acntDbAcntFeatures)
+	timings := `ALWAYS,*any,*any,*any,*any,00:00:00`
+	csvr := engine.NewTpReader(ratingDbAcntFeatures, acntDbAcntFeatures, engine.NewStringCSVStorage(',', dests, timings, rates, destinationRates, ratingPlans, ratingProfiles,
+		sharedGroups, "", "", "", "", "", "", "", "", "", "", exchangeRates, ""), "", "")
+	for _, loadFunc := range []func() error{csvr.LoadTimings, csvr.LoadDestinations, csvr.LoadRates, csvr.LoadDestinationRates,
+		csvr.LoadRatingPlans, csvr.LoadRatingProfiles, csvr.LoadSharedGroups, csvr.LoadExchangeRates} {
+		if err := loadFunc(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	csvr.WriteToDatabase(false, false)
+	for _, acnt := range accounts {
+		if err := acntDbAcntFeatures.SetAccount(acnt); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if setup != nil {
+		if err := setup(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ratingDbAcntFeatures.CacheRatingAll("loadAcntFeaturesTp")
+	acntDbAcntFeatures.CacheAccountingAll("loadAcntFeaturesTp")
+}
+
+func acntFeaturesCallDescriptor(account string, usage time.Duration) *engine.CallDescriptor {
+	tStart := time.Date(2016, 10, 5, 12, 0, 0, 0, time.UTC)
+	return &engine.CallDescriptor{
+		Direction:   utils.OUT,
+		Category:    "call",
+		Tenant:      "cgrates.org",
+		Subject:     account,
+		Account:     account,
+		Destination: "+4986517174963",
+		TimeStart:   tStart,
+		TimeEnd:     tStart.Add(usage),
+	}
+}
+
+func expectMaxSessionDuration(account string, usage, eDur time.Duration) func() error {
+	return func() error {
+		if dur, err := acntFeaturesCallDescriptor(account, usage).GetMaxSessionDuration(); err != nil {
+			return err
+		} else if dur != eDur {
+			return fmt.Errorf("expecting duration: %v, received: %v", eDur, dur)
+		}
+		return nil
+	}
+}
+
+// Rates the call with one of GetCost, Debit or MaxDebit, checking the duration and cost rated
+func expectCallCost(rate func(*engine.CallDescriptor) (*engine.CallCost, error), account string, usage, eDur time.Duration, eCost float64) func() error {
+	return func() error {
+		if cc, err := rate(acntFeaturesCallDescriptor(account, usage)); err != nil {
+			return err
+		} else if cc.GetDuration() != eDur || cc.Cost != eCost {
+			return fmt.Errorf("expecting duration: %v, cost: %v, received: %v, %v, timespans: %s", eDur, eCost, cc.GetDuration(), cc.Cost, utils.ToJSON(cc.Timespans))
+		}
+		return nil
+	}
+}
+
+func expectAccount(acntID string, check func(*engine.Account) bool) func() error {
+	return func() error {
+		if acnt, err := acntDbAcntFeatures.GetAccount(acntID); err != nil {
+			return err
+		} else if !check(acnt) {
+			return fmt.Errorf("unexpected account: %s", utils.ToJSON(acnt))
+		}
+		return nil
+	}
+}
+
+func expectError(do func() error, eErr error) func() error {
+	return func() error {
+		if err := do(); err != eErr {
+			return fmt.Errorf("expecting error: %v, received: %v", eErr, err)
+		}
+		return nil
+	}
+}
+
+func executeActions(acntID string, acts engine.Actions) func() error {
+	return func() error {
+		at := &engine.ActionTiming{}
+		at.SetAccountIDs(utils.StringMap{acntID: true})
+		at.SetActions(acts)
+		return at.Execute()
+	}
+}
+
+func TestAcntFeaturesVolumeTiers(t *testing.T) {
+	loadAcntFeaturesTp(t, `DST_VOL,+49`, `RT_VOL,0,0.02,60s,60s,0s
+RT_VOL,0,0.01,60s,60s,60000s`, `DR_VOL,DST_VOL,RT_VOL,*up,4,0,`, `RP_VOL,DR_VOL,ALWAYS,10,VOL_MONTHLY,`,
+		`*out,cgrates.org,call,*any,2012-01-01T00:00:00Z,RP_VOL,,`, "", "",
+		[]*engine.Account{
+			&engine.Account{ID: "cgrates.org:vol1", // 999 minutes already used this month
+				BalanceMap: map[string]engine.Balances{utils.MONETARY: engine.Balances{&engine.Balance{Uuid: "vol_money", Value: 10}}},
+				UnitCounters: engine.UnitCounters{utils.VOICE: []*engine.UnitCounter{
+					&engine.UnitCounter{CounterType: utils.COUNTER_EVENT, Counters: engine.CounterFilters{
+						&engine.CounterFilter{Value: 59940,
+							Filter: &engine.BalanceFilter{ID: utils.StringPointer("VOL_MONTHLY"), Type: utils.StringPointer(utils.VOICE)}}}}}}},
+		}, nil)
+	for _, step := range []acntFeaturesStep{
+		{"first minute on the initial tier, the next two on the discounted one",
+			expectCallCost((*engine.CallDescriptor).GetCost, "vol1", 3*time.Minute, 3*time.Minute, 0.04)},
+		{"debit across the tier boundary",
+			expectCallCost((*engine.CallDescriptor).Debit, "vol1", 3*time.Minute, 3*time.Minute, 0.04)},
+		{"debited usage counted", expectAccount("cgrates.org:vol1", func(acnt *engine.Account) bool {
+			return acnt.UnitCounters[utils.VOICE][0].Counters[0].Value == 60120 && acnt.BalanceMap[utils.MONETARY][0].GetValue() == 9.96
+		})},
+		{"usage debited lately rated on the discounted tier",
+			expectCallCost((*engine.CallDescriptor).GetCost, "vol1", time.Minute, time.Minute, 0.01)},
+		{"reset counters", executeActions("cgrates.org:vol1", engine.Actions{
+			&engine.Action{ActionType: engine.RESET_COUNTERS,
+				Balance: &engine.BalanceFilter{ID: utils.StringPointer("VOL_MONTHLY"), Type: utils.StringPointer(utils.VOICE)}}})},
+		{"counter reset", expectAccount("cgrates.org:vol1", func(acnt *engine.Account) bool {
+			return acnt.UnitCounters[utils.VOICE][0].Counters[0].Value == 0
+		})},
+		{"reset usage rated on the initial tier",
+			expectCallCost((*engine.CallDescriptor).GetCost, "vol1", time.Minute, time.Minute, 0.02)},
+	} {
+		if err := step.do(); err != nil {
+			t.Errorf("%s: %v", step.descr, err)
+		}
+	}
+}
+
+func TestAcntFeaturesCreditLimit(t *testing.T) {
+	loadAcntFeaturesTp(t, `DST_CL,+49`, `RT_1CNT,0,0.01,1s,1s,0s`, `DR_CL,DST_CL,RT_1CNT,*up,4,0,`, `RP_CL,DR_CL,ALWAYS,10`,
+		`*out,cgrates.org,call,*any,2012-01-01T00:00:00Z,RP_CL,,`, `SG_CL,*any,*lowest,`, "",
+		[]*engine.Account{
+			// cl1 gets its credit limit raised when less than 0.1 is left to spend
+			&engine.Account{ID: "cgrates.org:cl1", CreditLimit: 0.3,
+				BalanceMap: map[string]engine.Balances{
+					utils.MONETARY: engine.Balances{&engine.Balance{Uuid: "cl1_default", ID: utils.META_DEFAULT, Value: 0.3}}},
+				ActionTriggers: engine.ActionTriggers{
+					&engine.ActionTrigger{ID: "CL_TRIGGER", UniqueID: "cl_trigger", ThresholdType: utils.TRIGGER_MIN_CREDIT, ThresholdValue: 0.1,
+						Balance: &engine.BalanceFilter{Type: utils.StringPointer(utils.MONETARY)}, ActionsID: "ACT_RAISE_LIMIT"}}},
+			// cl2 spends the shared balance of cl3 before going into debt
+			&engine.Account{ID: "cgrates.org:cl2", CreditLimit: 0.3,
+				BalanceMap: map[string]engine.Balances{utils.MONETARY: engine.Balances{
+					&engine.Balance{Uuid: "cl2_default", ID: utils.META_DEFAULT},
+					&engine.Balance{Uuid: "cl2_shared", Weight: 10, SharedGroups: utils.NewStringMap("SG_CL")}}}},
+			&engine.Account{ID: "cgrates.org:cl3",
+				BalanceMap: map[string]engine.Balances{utils.MONETARY: engine.Balances{
+					&engine.Balance{Uuid: "cl3_shared", Value: 0.3, Weight: 10, SharedGroups: utils.NewStringMap("SG_CL")}}}},
+		},
+		func() error {
+			if err := ratingDbAcntFeatures.SetActions("ACT_RAISE_LIMIT", engine.Actions{
+				&engine.Action{ActionType: engine.ADD_CREDIT_LIMIT, Balance: &engine.BalanceFilter{Value: &utils.ValueFormula{Static: 0.5}}},
+			}); err != nil {
+				return err
+			}
+			sg, err := ratingDbAcntFeatures.GetSharedGroup("SG_CL", true)
+			if err != nil {
+				return err
+			}
+			sg.MemberIds = utils.NewStringMap("cgrates.org:cl2", "cgrates.org:cl3")
+			return ratingDbAcntFeatures.SetSharedGroup(sg)
+		})
+	for _, step := range []acntFeaturesStep{
+		{"0.3 balance and 0.3 credit limit at 0.01 per second", expectMaxSessionDuration("cl1", 2*time.Minute, time.Minute)},
+		{"debit up to the credit limit",
+			expectCallCost((*engine.CallDescriptor).MaxDebit, "cl1", 2*time.Minute, time.Minute, 0.6)},
+		{"debt on the default balance, limit raised by the trigger once the credit went under 0.1",
+			expectAccount("cgrates.org:cl1", func(acnt *engine.Account) bool {
+				return acnt.BalanceMap[utils.MONETARY][0].GetValue() == -0.3 && acnt.CreditLimit == 0.8
+			})},
+		{"raised credit limit", expectMaxSessionDuration("cl1", 2*time.Minute, 50*time.Second)},
+		{"set and add credit limit actions", executeActions("cgrates.org:cl1", engine.Actions{
+			&engine.Action{ActionType: engine.SET_CREDIT_LIMIT, Weight: 20, Balance: &engine.BalanceFilter{Value: &utils.ValueFormula{Static: 1}}},
+			&engine.Action{ActionType: engine.ADD_CREDIT_LIMIT, Weight: 10, Balance: &engine.BalanceFilter{Value: &utils.ValueFormula{Static: -0.4}}}})},
+		{"credit limit out of actions", expectAccount("cgrates.org:cl1", func(acnt *engine.Account) bool {
+			return acnt.CreditLimit == 0.6
+		})},
+		{"balance at -0.3 leaves 0.3 out of the 0.6 limit", expectMaxSessionDuration("cl1", 2*time.Minute, 30*time.Second)},
+		{"shared balance and credit limit", expectMaxSessionDuration("cl2", 2*time.Minute, time.Minute)},
+		{"debit shared balance then credit limit",
+			expectCallCost((*engine.CallDescriptor).MaxDebit, "cl2", 2*time.Minute, time.Minute, 0.6)},
+		{"shared balance consumed", expectAccount("cgrates.org:cl3", func(acnt *engine.Account) bool {
+			return acnt.BalanceMap[utils.MONETARY][0].GetValue() == 0
+		})},
+		{"debt on the default balance", expectAccount("cgrates.org:cl2", func(acnt *engine.Account) bool {
+			return acnt.GetDefaultMoneyBalance().GetValue() == -0.3
+		})},
+		{"credit limit exhausted", expectMaxSessionDuration("cl2", 2*time.Minute, 0)},
+	} {
+		if err := step.do(); err != nil {
+			t.Errorf("%s: %v", step.descr, err)
+		}
+	}
+}
+
+func TestAcntFeaturesReservations(t *testing.T) {
+	loadAcntFeaturesTp(t, `DST_RES,+49`, `RT_1CNT,0,0.01,1s,1s,0s`, `DR_RES,DST_RES,RT_1CNT,*up,4,0,`, `RP_RES,DR_RES,ALWAYS,10`,
+		`*out,cgrates.org,call,*any,2012-01-01T00:00:00Z,RP_RES,,`, "", "",
+		[]*engine.Account{
+			&engine.Account{ID: "cgrates.org:res1", BalanceMap: map[string]engine.Balances{
+				utils.MONETARY: engine.Balances{&engine.Balance{Uuid: "res1_default", ID: utils.META_DEFAULT, Value: 1}}}},
+		}, nil)
+	reserve := func(id string, value float64, expiry time.Duration) func() error {
+		return func() error {
+			return engine.ReserveBalance("cgrates.org:res1", &engine.BalanceReservation{ID: id, BalanceType: utils.MONETARY, Value: value,
+				ExpiryTime: time.Now().Add(expiry)})
+		}
+	}
+	expiring := &engine.BalanceReservation{ID: "RES_4", BalanceType: utils.MONETARY, Value: 0.5}
+	for _, step := range []acntFeaturesStep{
+		{"reserve", reserve("RES_1", 0.4, time.Hour)},
+		{"reserve over the credit", expectError(reserve("RES_2", 0.7, time.Hour), utils.ErrInsufficientCredit)},
+		{"0.6 out of 1 can still be spent", expectMaxSessionDuration("res1", 2*time.Minute, time.Minute)},
+		{"capture", func() error { return engine.CaptureReservation("cgrates.org:res1", "RES_1", 0.3) }},
+		{"captured value debited", expectAccount("cgrates.org:res1", func(acnt *engine.Account) bool {
+			return acnt.BalanceMap[utils.MONETARY][0].GetValue() == 0.7 && len(acnt.Reservations) == 0
+		})},
+		{"captured reservation released", expectMaxSessionDuration("res1", 2*time.Minute, 70*time.Second)},
+		{"reserve again", reserve("RES_3", 0.5, time.Hour)},
+		{"reserved value held", expectMaxSessionDuration("res1", 2*time.Minute, 20*time.Second)},
+		{"release", func() error { return engine.ReleaseReservation("cgrates.org:res1", "RES_3") }},
+		{"release twice", expectError(func() error { return engine.ReleaseReservation("cgrates.org:res1", "RES_3") }, utils.ErrNotFound)},
+		{"released value available", expectMaxSessionDuration("res1", 2*time.Minute, 70*time.Second)},
+		{"reserve shortly", func() error {
+			expiring.ExpiryTime = time.Now().Add(50 * time.Millisecond)
+			if err := engine.ReserveBalance("cgrates.org:res1", expiring); err != nil {
+				return err
+			}
+			time.Sleep(60 * time.Millisecond)
+			return nil
+		}},
+		{"expired reservations hold nothing even before being removed", expectMaxSessionDuration("res1", 2*time.Minute, 70*time.Second)},
+		{"expire", func() error { return engine.NewReservationExpiryTiming("cgrates.org:res1", expiring).Execute() }},
+		{"expired reservation removed", expectAccount("cgrates.org:res1", func(acnt *engine.Account) bool {
+			return len(acnt.Reservations) == 0
+		})},
+	} {
+		if err := step.do(); err != nil {
+			t.Errorf("%s: %v", step.descr, err)
+		}
+	}
+}
+
+func TestAcntFeaturesCurrency(t *testing.T) {
+	loadAcntFeaturesTp(t, `DST_CUR,+49`, `RT_USD,0,0.1,60s,60s,0s`, `DR_USD,DST_CUR,RT_USD,*up,4,0,`, `RP_USD,DR_USD,ALWAYS,10,,USD`,
+		`*out,cgrates.org,call,*any,2012-01-01T00:00:00Z,RP_USD,,`, "", `EXR_EUR,EUR,USD,1.25`,
+		[]*engine.Account{
+			&engine.Account{ID: "cgrates.org:cur_eur", BalanceMap: map[string]engine.Balances{
+				utils.MONETARY: engine.Balances{&engine.Balance{Uuid: "cur_eur_money", Value: 10, Currency: "EUR"}}}},
+			&engine.Account{ID: "cgrates.org:cur_gbp", BalanceMap: map[string]engine.Balances{
+				utils.MONETARY: engine.Balances{&engine.Balance{Uuid: "cur_gbp_money", Value: 10, Currency: "GBP"}}}},
+			&engine.Account{ID: "cgrates.org:cur_eur_limit", CreditLimit: 0.8, BalanceMap: map[string]engine.Balances{
+				utils.MONETARY: engine.Balances{&engine.Balance{Uuid: "cur_eur_limit_money", ID: utils.META_DEFAULT, Currency: "EUR"}}}},
+		}, nil)
+	var debited *engine.CallCost
+	for _, step := range []acntFeaturesStep{
+		{"inverted exchange rate", func() error {
+			if rate, err := engine.GetExchangeRate("USD", "EUR"); err != nil {
+				return err
+			} else if rate != 0.8 {
+				return fmt.Errorf("expecting rate: 0.8, received: %v", rate)
+			}
+			return nil
+		}},
+		{"cost stays in the currency of the rating plan, the debit is converted", func() (err error) {
+			if debited, err = acntFeaturesCallDescriptor("cur_eur", 3*time.Minute).Debit(); err != nil {
+				return err
+			}
+			if debited.Cost != 0.3 {
+				return fmt.Errorf("expecting cost: 0.3, received: %v", debited.Cost)
+			}
+			for _, ts := range debited.Timespans {
+				for _, incr := range ts.Increments {
+					if incr.BalanceInfo.Monetary == nil || incr.BalanceInfo.Monetary.ExchangeRate != 0.8 {
+						return fmt.Errorf("unexpected increment: %s", utils.ToJSON(incr))
+					}
+				}
+			}
+			return nil
+		}},
+		{"converted value debited", expectAccount("cgrates.org:cur_eur", func(acnt *engine.Account) bool {
+			return acnt.BalanceMap[utils.MONETARY][0].GetValue() == 9.76
+		})},
+		{"refund", func() error {
+			cd := acntFeaturesCallDescriptor("cur_eur", 3*time.Minute)
+			for _, ts := range debited.Timespans {
+				cd.Increments = append(cd.Increments, ts.Increments...)
+			}
+			return cd.RefundIncrements()
+		}},
+		{"converted value refunded", expectAccount("cgrates.org:cur_eur", func(acnt *engine.Account) bool {
+			return acnt.BalanceMap[utils.MONETARY][0].GetValue() == 10
+		})},
+		{"the credit limit of 0.8 EUR pays 1 USD, 10 minutes at 0.1 USD per minute",
+			expectMaxSessionDuration("cur_eur_limit", 20*time.Minute, 10*time.Minute)},
+		{"missing exchange rate", expectError(func() error {
+			_, err := engine.GetExchangeRate("USD", "GBP")
+			return err
+		}, utils.ErrExchangeRateNotFound)},
+		{"debit without exchange rate", func() error {
+			_, err := acntFeaturesCallDescriptor("cur_gbp", time.Minute).Debit()
+			return err
+		}},
+		{"no USD to GBP conversion, the balance cannot pay", expectAccount("cgrates.org:cur_gbp", func(acnt *engine.Account) bool {
+			b := acnt.BalanceMap[utils.MONETARY].GetBalance("cur_gbp_money")
+			return b != nil && b.GetValue() == 10
+		})},
+	} {
+		if err := step.do(); err != nil {
+			t.Errorf("%s: %v", step.descr, err)
+		}
+	}
+}
@@ -19,6 +19,7 @@ along with this program.  If not, see <http://www.gnu.org/licenses/>
 package general_tests
 
 import (
+	"encoding/json"
 	"flag"
 	"io/ioutil"
 	"os"
@@ -163,20 +164,31 @@ func TestCdrsFileFailover(t *testing.T) {
 			break
 		}
 	}
-	filesInDir, _ := ioutil.ReadDir(cdrsMasterCfg.HttpFailedDir)
+	filesInDir, _ := ioutil.ReadDir(cdrsMasterCfg.CDRSReplSpoolDir)
 	if len(filesInDir) == 0 {
-		t.Fatalf("No files in directory: %s", cdrsMasterCfg.HttpFailedDir)
+		t.Fatalf("No files in directory: %s", cdrsMasterCfg.CDRSReplSpoolDir)
 	}
 	var fileName string
 	for _, file := range filesInDir { // First file in directory is the one we need, harder to find it's name out of config
 		fileName = file.Name()
 		break
 	}
-	filePath := path.Join(cdrsMasterCfg.HttpFailedDir, fileName)
+	filePath := path.Join(cdrsMasterCfg.CDRSReplSpoolDir, fileName)
+	var spooled engine.SpooledReplication
 	if readBytes, err := ioutil.ReadFile(filePath); err != nil {
 		t.Error(err)
-	} else if !reflect.DeepEqual(failoverContent[11], readBytes[11]) { // Checking just the prefix should do since some content is dynamic
-		t.Errorf("Expecting: %q, received: %q", string(failoverContent[11]), string(readBytes[11]))
+	} else if err := json.Unmarshal(readBytes, &spooled); err != nil {
+		t.Error(err)
+	} else if spooled.Transport != rplCfg.Transport || spooled.Address != rplCfg.Address {
+		t.Errorf("Unexpected spooled replication: %+v", spooled)
+	} else if !reflect.DeepEqual(failoverContent[11], spooled.Content[11]) { // Checking just the prefix should do since some content is dynamic
+		t.Errorf("Expecting: %q, received: %q", string(failoverContent[11]), string(spooled.Content[11]))
+	}
+	var rpl utils.ReplayedReplications
+	if err := cdrsMasterRpc.Call("CdrsV1.ReplayFailedReplications", utils.AttrReplayFailedReplications{Address: rplCfg.Address}, &rpl); err != nil {
+		t.Error(err)
+	} else if rpl.Replayed != 0 || rpl.Failed != 1 { // Address stays invalid
+		t.Errorf("Unexpected replay: %+v", rpl)
 	}
 	if err := os.Remove(filePath); err != nil {
 		t.Error("Failed removing file: ", filePath)
@@ -0,0 +1,112 @@
+/*
+Real-time Charging System for Telecom & ISP environments
+Copyright (C) ITsysCOM GmbH
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>
+*/
+
+package history
+
+import (
+	"encoding/gob"
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strconv"
+)
+
+func init() { // Empty objects and lists show up as values in diffs, make them transportable over GOB
+	gob.Register(map[string]interface{}{})
+	gob.Register([]interface{}{})
+}
+
+// One field changed between two versions of an object, Old is nil for added fields and New for removed ones
+type FieldDiff struct {
+	Path string // eg: BalanceMap.*monetary[0].Value
+	Old  interface{}
+	New  interface{}
+}
+
+// Compares two JSON payloads field by field, empty payload stands for a missing object
+func diffPayloads(oldPayload, newPayload []byte) ([]*FieldDiff, error) {
+	oldFields, newFields := make(map[string]interface{}), make(map[string]interface{})
+	for _, pld := range []struct {
+		payload []byte
+		fields  map[string]interface{}
+	}{{oldPayload, oldFields}, {newPayload, newFields}} {
+		if len(pld.payload) == 0 {
+			continue
+		}
+		var obj interface{}
+		if err := json.Unmarshal(pld.payload, &obj); err != nil {
+			return nil, err
+		}
+		flattenJSON("", obj, pld.fields)
+	}
+	var diff []*FieldDiff
+	for path, oldVal := range oldFields {
+		if newVal, has := newFields[path]; !has {
+			diff = append(diff, &FieldDiff{Path: path, Old: oldVal})
+		} else if !reflect.DeepEqual(oldVal, newVal) {
+			diff = append(diff, &FieldDiff{Path: path, Old: oldVal, New: newVal})
+		}
+	}
+	for path, newVal := range newFields {
+		if _, has := oldFields[path]; !has {
+			diff = append(diff, &FieldDiff{Path: path, New: newVal})
+		}
+	}
+	sort.Sort(fieldDiffs(diff))
+	return diff, nil
+}
+
+type fieldDiffs []*FieldDiff
+
+func (fds fieldDiffs) Len() int {
+	return len(fds)
+}
+
+func (fds fieldDiffs) Swap(i, j int) {
+	fds[i], fds[j] = fds[j], fds[i]
+}
+
+func (fds fieldDiffs) Less(i, j int) bool {
+	return fds[i].Path < fds[j].Path
+}
+
+// Indexes the leaf values of a decoded JSON object on their path
+func flattenJSON(path string, obj interface{}, fields map[string]interface{}) {
+	switch val := obj.(type) {
+	case map[string]interface{}:
+		if len(val) == 0 {
+			fields[path] = val
+		}
+		for key, fldVal := range val {
+			fldPath := key
+			if path != "" {
+				fldPath = path + "." + key
+			}
+			flattenJSON(fldPath, fldVal, fields)
+		}
+	case []interface{}:
+		if len(val) == 0 {
+			fields[path] = val
+		}
+		for idx, fldVal := range val {
+			flattenJSON(path+"["+strconv.Itoa(idx)+"]", fldVal, fields)
+		}
+	default:
+		fields[path] = val
+	}
+}
@@ -53,8 +53,10 @@ func NewFileScribe(fileRoot string, saveInterval time.Duration) (*FileScribe, er
 	}
 	s := &FileScribe{fileRoot: fileRoot, gitCommand: gitCommand, savePeriod: saveInterval}
 	s.loopChecker = make(chan int)
-	files := []string{DESTINATIONS_FN, RATING_PLANS_FN, RATING_PROFILES_FN}
-	s.gitInit(files)
+	files := []string{DESTINATIONS_FN, RATING_PLANS_FN, RATING_PROFILES_FN, ACCOUNTS_FN, ACTIONS_FN, ACTION_PLANS_FN}
+	if err := s.gitInit(files); err != nil {
+		return nil, err
+	}
 
 	for _, fn := range files {
 		if err := s.load(fn); err != nil {
@@ -65,6 +67,9 @@ func NewFileScribe(fileRoot string, saveInterval time.Duration) (*FileScribe, er
 }
 
 func (s *FileScribe) Record(rec Record, out *int) error {
+	if !utils.IsSliceMember([]string{DESTINATIONS_FN, RATING_PLANS_FN, RATING_PROFILES_FN, ACCOUNTS_FN, ACTIONS_FN, ACTION_PLANS_FN}, rec.Filename) {
+		return fmt.Errorf("<History> Unsupported history file: %s", rec.Filename)
+	}
 	s.mu.Lock()
 	fileToSave := rec.Filename
 	recordsMap[fileToSave] = recordsMap[fileToSave].Modify(&rec)
@@ -97,6 +102,11 @@ func (s *FileScribe) Record(rec Record, out *int) error {
 	return nil
 }
 
+// Records are archived in git files, not queryable
+func (s *FileScribe) GetRecords(filter RecordFilter, reply *[]*ChangeRecord) error {
+	return utils.ErrNotImplemented
+}
+
 func (s *FileScribe) gitInit(files []string) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
@@ -111,17 +121,22 @@ func (s *FileScribe) gitInit(files []string) error {
 		if out, err := cmd.Output(); err != nil {
 			return errors.New(string(out) + " " + err.Error())
 		}
-		log.Print("CREATING FILES")
-		for _, fn := range files {
-			log.Print("FILE: ", fn)
-			if f, err := os.Create(filepath.Join(s.fileRoot, fn)); err != nil {
-				return fmt.Errorf("<History> Error writing %s file: %s", fn, err.Error())
-			} else {
-				f.Close()
-			}
+	}
+	var created bool // Archives started before accounts and actions were recorded miss their files
+	for _, fn := range files {
+		if _, err := os.Stat(filepath.Join(s.fileRoot, fn)); !os.IsNotExist(err) {
+			continue
 		}
-
-		cmd = exec.Command(s.gitCommand, "add", ".")
+		log.Print("<History> Creating file: ", fn)
+		if f, err := os.Create(filepath.Join(s.fileRoot, fn)); err != nil {
+			return fmt.Errorf("<History> Error writing %s file: %s", fn, err.Error())
+		} else {
+			f.Close()
+		}
+		created = true
+	}
+	if created {
+		cmd := exec.Command(s.gitCommand, "add", ".")
 		cmd.Dir = s.fileRoot
 		if out, err := cmd.Output(); err != nil {
 			return errors.New(string(out) + " " + err.Error())
@@ -154,6 +169,7 @@ func (s *FileScribe) load(filename string) error {
 		return fmt.Errorf("<History> Error loading %s: %s", filename, err.Error())
 	}
 	records.Sort()
+	recordsMap[filename] = records
 	return nil
 }
 
@@ -0,0 +1,196 @@
+/*
+Real-time Charging System for Telecom & ISP environments
+Copyright (C) ITsysCOM GmbH
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>
+*/
+
+package history
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/cgrates/cgrates/utils"
+)
+
+const HISTORY_RECORDS_FN = "history_records.jsonl"
+
+// Append-only local file storing one change record per line.
+// Only the file offsets of the records are kept in memory, the records themselves are read from the file when needed.
+type FileRecordStorage struct {
+	mu          sync.RWMutex
+	filePath    string
+	fileSize    int64              // Offset where the next record will be written
+	objOffsets  map[string][]int64 // Offsets of the versions of each object, indexed on type and id
+	typeOffsets map[string][]int64 // Offsets of the records of each object type
+}
+
+func NewFileRecordStorage(fileRoot string) (*FileRecordStorage, error) {
+	if err := os.MkdirAll(fileRoot, os.ModeDir|0755); err != nil {
+		return nil, fmt.Errorf("<History> Error creating history folder: %s", err.Error())
+	}
+	frs := &FileRecordStorage{filePath: filepath.Join(fileRoot, HISTORY_RECORDS_FN),
+		objOffsets: make(map[string][]int64), typeOffsets: make(map[string][]int64)}
+	if err := frs.iterRecords(0, func(offset int64, chRec *ChangeRecord) bool {
+		frs.indexRecord(offset, chRec)
+		return true
+	}); err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	if fi, err := os.Stat(frs.filePath); err == nil {
+		frs.fileSize = fi.Size()
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	}
+	return frs, nil
+}
+
+func (frs *FileRecordStorage) indexRecord(offset int64, chRec *ChangeRecord) {
+	objKey := utils.ConcatenatedKey(chRec.ObjectType, chRec.ObjectID)
+	frs.objOffsets[objKey] = append(frs.objOffsets[objKey], offset)
+	frs.typeOffsets[chRec.ObjectType] = append(frs.typeOffsets[chRec.ObjectType], offset)
+}
+
+// Calls f for each record in the file starting with offset, in the order they were written, stops when f returns false
+func (frs *FileRecordStorage) iterRecords(offset int64, f func(int64, *ChangeRecord) bool) error {
+	fd, err := os.Open(frs.filePath)
+	if err != nil {
+		return err
+	}
+	defer fd.Close()
+	if _, err := fd.Seek(offset, 0); err != nil {
+		return err
+	}
+	rdr := bufio.NewReader(fd)
+	for {
+		line, err := rdr.ReadBytes('\n')
+		if len(line) == 0 && err != nil {
+			break // EOF
+		}
+		var chRec ChangeRecord
+		if errDec := json.Unmarshal(line, &chRec); errDec != nil {
+			return fmt.Errorf("<History> Error decoding record at offset %d in %s: %s", offset, frs.filePath, errDec.Error())
+		}
+		if !f(offset, &chRec) || err != nil {
+			break
+		}
+		offset += int64(len(line))
+	}
+	return nil
+}
+
+// Reads the records written at the given offsets, stops when f returns false
+func (frs *FileRecordStorage) readRecords(offsets []int64, f func(*ChangeRecord) bool) error {
+	if len(offsets) == 0 {
+		return nil
+	}
+	fd, err := os.Open(frs.filePath)
+	if err != nil {
+		return err
+	}
+	defer fd.Close()
+	for _, offset := range offsets {
+		line, err := bufio.NewReader(io.NewSectionReader(fd, offset, frs.fileSize-offset)).ReadBytes('\n')
+		if len(line) == 0 && err != nil {
+			return err
+		}
+		var chRec ChangeRecord
+		if err := json.Unmarshal(line, &chRec); err != nil {
+			return fmt.Errorf("<History> Error decoding record at offset %d in %s: %s", offset, frs.filePath, err.Error())
+		}
+		if !f(&chRec) {
+			break
+		}
+	}
+	return nil
+}
+
+func (frs *FileRecordStorage) SetHistoryRecord(chRec *ChangeRecord) error {
+	line, err := json.Marshal(chRec)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+	frs.mu.Lock()
+	defer frs.mu.Unlock()
+	fd, err := os.OpenFile(frs.filePath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer fd.Close()
+	if _, err := fd.Write(line); err != nil {
+		return err
+	}
+	frs.indexRecord(frs.fileSize, chRec)
+	frs.fileSize += int64(len(line))
+	return nil
+}
+
+func (frs *FileRecordStorage) GetLastHistoryRecord(objType, objID string) (lastRec *ChangeRecord, err error) {
+	frs.mu.RLock()
+	defer frs.mu.RUnlock()
+	offsets, has := frs.objOffsets[utils.ConcatenatedKey(objType, objID)]
+	if !has {
+		return nil, utils.ErrNotFound
+	}
+	if err = frs.readRecords(offsets[len(offsets)-1:], func(chRec *ChangeRecord) bool {
+		lastRec = chRec
+		return false
+	}); err != nil {
+		return nil, err
+	}
+	return
+}
+
+// Uses the offsets index when the filter is on object type or id, otherwise scans the whole file
+func (frs *FileRecordStorage) GetHistoryRecords(filter *RecordFilter) (chRecs []*ChangeRecord, err error) {
+	var offset, limit int
+	if filter.Offset != nil {
+		offset = *filter.Offset
+	}
+	if filter.Limit != nil {
+		limit = *filter.Limit
+	}
+	collect := func(chRec *ChangeRecord) bool {
+		if !filter.Passes(chRec) {
+			return true
+		}
+		if offset > 0 {
+			offset--
+			return true
+		}
+		chRecs = append(chRecs, chRec)
+		return limit == 0 || len(chRecs) < limit
+	}
+	frs.mu.RLock()
+	defer frs.mu.RUnlock()
+	switch {
+	case filter.ObjectType != "" && filter.ObjectID != "":
+		err = frs.readRecords(frs.objOffsets[utils.ConcatenatedKey(filter.ObjectType, filter.ObjectID)], collect)
+	case filter.ObjectType != "":
+		err = frs.readRecords(frs.typeOffsets[filter.ObjectType], collect)
+	default:
+		err = frs.iterRecords(0, func(_ int64, chRec *ChangeRecord) bool { return collect(chRec) })
+	}
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	return chRecs, nil
+}
@@ -38,6 +38,9 @@ func NewMockScribe() (*MockScribe, error) {
 		DESTINATIONS_FN:    bytes.NewBuffer(nil),
 		RATING_PLANS_FN:    bytes.NewBuffer(nil),
 		RATING_PROFILES_FN: bytes.NewBuffer(nil),
+		ACCOUNTS_FN:        bytes.NewBuffer(nil),
+		ACTIONS_FN:         bytes.NewBuffer(nil),
+		ACTION_PLANS_FN:    bytes.NewBuffer(nil),
 	}}, nil
 }
 
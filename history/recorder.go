@@ -0,0 +1,157 @@
+/*
+Real-time Charging System for Telecom & ISP environments
+Copyright (C) ITsysCOM GmbH
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>
+*/
+
+package history
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cgrates/cgrates/utils"
+)
+
+// One version of a recorded object
+type ChangeRecord struct {
+	ObjectType string
+	ObjectID   string
+	Version    int64
+	Author     string
+	Timestamp  time.Time
+	Deleted    bool
+	Payload    json.RawMessage // The object as it was after the change
+	Diff       []*FieldDiff    // Changes compared to the previous version
+}
+
+// Filters the change records returned by HistoryV1.GetRecords, empty fields are not considered
+type RecordFilter struct {
+	ObjectType string    // destinations, rating_plans, rating_profiles, accounts, actions, action_plans
+	ObjectID   string    // Exact match on the object id
+	TimeStart  time.Time // Changes performed at or after this time
+	TimeEnd    time.Time // Changes performed before this time
+	utils.Paginator
+}
+
+// Storage for versioned change records, implemented by the local file and by the StorDB backends
+type RecordStorage interface {
+	SetHistoryRecord(*ChangeRecord) error
+	GetLastHistoryRecord(objType, objID string) (*ChangeRecord, error)
+	GetHistoryRecords(*RecordFilter) ([]*ChangeRecord, error)
+}
+
+// Recorder serves the HistoryV1 API on top of a RecordStorage, versioning the received records and computing their diffs
+type Recorder struct {
+	mu      sync.Mutex
+	storage RecordStorage
+}
+
+func NewRecorder(storage RecordStorage) *Recorder {
+	return &Recorder{storage: storage}
+}
+
+func (r *Recorder) Record(rec Record, out *int) error {
+	if rec.Id == "" || rec.Filename == "" {
+		return utils.ErrMandatoryIeMissing
+	}
+	chRec := &ChangeRecord{ObjectType: rec.ObjectType(), ObjectID: rec.Id, Version: 1,
+		Author: rec.Author, Timestamp: rec.Timestamp, Deleted: rec.Deleted}
+	if chRec.Timestamp.IsZero() {
+		chRec.Timestamp = time.Now()
+	}
+	if !rec.Deleted {
+		chRec.Payload = json.RawMessage(rec.Payload)
+	}
+	r.mu.Lock() // Versions of the same object must be computed one after the other
+	defer r.mu.Unlock()
+	var prevPayload []byte
+	if prevRec, err := r.storage.GetLastHistoryRecord(chRec.ObjectType, chRec.ObjectID); err != nil && err != utils.ErrNotFound {
+		return err
+	} else if prevRec != nil {
+		chRec.Version = prevRec.Version + 1
+		prevPayload = prevRec.Payload
+	}
+	diff, err := diffPayloads(prevPayload, chRec.Payload)
+	if err != nil {
+		return err
+	}
+	chRec.Diff = diff
+	if err := r.storage.SetHistoryRecord(chRec); err != nil {
+		return err
+	}
+	*out = 0
+	return nil
+}
+
+func (r *Recorder) GetRecords(filter RecordFilter, reply *[]*ChangeRecord) error {
+	chRecs, err := r.storage.GetHistoryRecords(&filter)
+	if err != nil {
+		return err
+	}
+	if len(chRecs) == 0 {
+		return utils.ErrNotFound
+	}
+	*reply = chRecs
+	return nil
+}
+
+func (r *Recorder) Call(serviceMethod string, args interface{}, reply interface{}) error {
+	parts := strings.Split(serviceMethod, ".")
+	if len(parts) != 2 {
+		return utils.ErrNotImplemented
+	}
+	// get method
+	method := reflect.ValueOf(r).MethodByName(parts[1])
+	if !method.IsValid() {
+		return utils.ErrNotImplemented
+	}
+
+	// construct the params
+	params := []reflect.Value{reflect.ValueOf(args), reflect.ValueOf(reply)}
+
+	ret := method.Call(params)
+	if len(ret) != 1 {
+		return utils.ErrServerError
+	}
+	if ret[0].Interface() == nil {
+		return nil
+	}
+	err, ok := ret[0].Interface().(error)
+	if !ok {
+		return utils.ErrServerError
+	}
+	return err
+}
+
+// Checks if the record passes the filter, used by backends without query capabilities
+func (filter *RecordFilter) Passes(chRec *ChangeRecord) bool {
+	if filter.ObjectType != "" && chRec.ObjectType != filter.ObjectType {
+		return false
+	}
+	if filter.ObjectID != "" && chRec.ObjectID != filter.ObjectID {
+		return false
+	}
+	if !filter.TimeStart.IsZero() && chRec.Timestamp.Before(filter.TimeStart) {
+		return false
+	}
+	if !filter.TimeEnd.IsZero() && !chRec.Timestamp.Before(filter.TimeEnd) {
+		return false
+	}
+	return true
+}
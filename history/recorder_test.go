@@ -0,0 +1,119 @@
+/*
+Real-time Charging System for Telecom & ISP environments
+Copyright (C) ITsysCOM GmbH
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>
+*/
+
+package history
+
+import (
+	"io/ioutil"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/cgrates/cgrates/utils"
+)
+
+func TestHistoryDiffPayloads(t *testing.T) {
+	eDiff := []*FieldDiff{
+		&FieldDiff{Path: "BalanceMap.*monetary[0].Value", Old: 10.0, New: 7.5},
+		&FieldDiff{Path: "BalanceMap.*sms", New: []interface{}{}},
+		&FieldDiff{Path: "Disabled", Old: false},
+	}
+	if diff, err := diffPayloads([]byte(`{"ID":"cgrates.org:1001","Disabled":false,"BalanceMap":{"*monetary":[{"Value":10}]}}`),
+		[]byte(`{"ID":"cgrates.org:1001","BalanceMap":{"*monetary":[{"Value":7.5}],"*sms":[]}}`)); err != nil {
+		t.Error(err)
+	} else if !reflect.DeepEqual(eDiff, diff) {
+		t.Errorf("Expecting: %s, received: %s", utils.ToJSON(eDiff), utils.ToJSON(diff))
+	}
+	if diff, err := diffPayloads(nil, []byte(`{"Id":"DST_1002","Prefixes":["1002"]}`)); err != nil {
+		t.Error(err)
+	} else if len(diff) != 2 {
+		t.Errorf("Unexpected diff: %s", utils.ToJSON(diff))
+	}
+}
+
+func TestHistoryFileRecorder(t *testing.T) {
+	historyDir, err := ioutil.TempDir("", "cgr_history")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(historyDir)
+	frs, err := NewFileRecordStorage(historyDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rcrd := NewRecorder(frs)
+	tStart := time.Date(2016, 7, 1, 10, 0, 0, 0, time.UTC)
+	var out int
+	for i, rec := range []Record{
+		Record{Id: "DST_1002", Filename: DESTINATIONS_FN, Payload: []byte(`{"Id":"DST_1002","Prefixes":["1002"]}`),
+			Author: "cgr-loader", Timestamp: tStart},
+		Record{Id: "cgrates.org:1001", Filename: ACCOUNTS_FN, Payload: []byte(`{"ID":"cgrates.org:1001","Disabled":false}`),
+			Author: "cgr-engine", Timestamp: tStart.Add(time.Minute)},
+		Record{Id: "DST_1002", Filename: DESTINATIONS_FN, Payload: []byte(`{"Id":"DST_1002","Prefixes":["1002","1003"]}`),
+			Author: "cgr-loader", Timestamp: tStart.Add(2 * time.Minute)},
+		Record{Id: "DST_1002", Filename: DESTINATIONS_FN, Deleted: true,
+			Author: "cgr-loader", Timestamp: tStart.Add(3 * time.Minute)},
+	} {
+		if err := rcrd.Record(rec, &out); err != nil {
+			t.Fatalf("Record %d, error: %s", i, err.Error())
+		}
+	}
+	var chRecs []*ChangeRecord
+	if err := rcrd.GetRecords(RecordFilter{ObjectType: "destinations", ObjectID: "DST_1002"}, &chRecs); err != nil {
+		t.Fatal(err)
+	} else if len(chRecs) != 3 {
+		t.Fatalf("Received: %s", utils.ToJSON(chRecs))
+	}
+	if chRecs[1].Version != 2 || chRecs[1].Author != "cgr-loader" || !chRecs[1].Timestamp.Equal(tStart.Add(2*time.Minute)) ||
+		!reflect.DeepEqual(chRecs[1].Diff, []*FieldDiff{&FieldDiff{Path: "Prefixes[1]", New: "1003"}}) {
+		t.Errorf("Received: %s", utils.ToJSON(chRecs[1]))
+	}
+	if !chRecs[2].Deleted || chRecs[2].Version != 3 || len(chRecs[2].Diff) != 3 {
+		t.Errorf("Received: %s", utils.ToJSON(chRecs[2]))
+	}
+	// Time range filter and pagination
+	limit := 1
+	if err := rcrd.GetRecords(RecordFilter{TimeStart: tStart.Add(time.Minute), TimeEnd: tStart.Add(3 * time.Minute),
+		Paginator: utils.Paginator{Limit: &limit}}, &chRecs); err != nil {
+		t.Fatal(err)
+	} else if len(chRecs) != 1 || chRecs[0].ObjectType != "accounts" {
+		t.Errorf("Received: %s", utils.ToJSON(chRecs))
+	}
+	if err := rcrd.GetRecords(RecordFilter{ObjectType: "destinations", Paginator: utils.Paginator{Offset: &limit}}, &chRecs); err != nil {
+		t.Fatal(err)
+	} else if len(chRecs) != 2 || chRecs[0].Version != 2 || chRecs[1].Version != 3 {
+		t.Errorf("Received: %s", utils.ToJSON(chRecs))
+	}
+	if err := rcrd.GetRecords(RecordFilter{ObjectType: "actions"}, &chRecs); err != utils.ErrNotFound {
+		t.Error(err)
+	}
+	// Versions continue after restart
+	if frs, err = NewFileRecordStorage(historyDir); err != nil {
+		t.Fatal(err)
+	}
+	rcrd = NewRecorder(frs)
+	if err := rcrd.Record(Record{Id: "cgrates.org:1001", Filename: ACCOUNTS_FN, Payload: []byte(`{"ID":"cgrates.org:1001","Disabled":true}`)}, &out); err != nil {
+		t.Fatal(err)
+	}
+	if chRec, err := frs.GetLastHistoryRecord("accounts", "cgrates.org:1001"); err != nil {
+		t.Error(err)
+	} else if chRec.Version != 2 || !reflect.DeepEqual(chRec.Diff, []*FieldDiff{&FieldDiff{Path: "Disabled", Old: false, New: true}}) {
+		t.Errorf("Received: %s", utils.ToJSON(chRec))
+	}
+}
@@ -20,21 +20,34 @@ package history
 
 import (
 	"io"
+	"path/filepath"
 	"reflect"
 	"sort"
+	"strings"
+	"time"
 )
 
 const (
 	DESTINATIONS_FN    = "destinations.json"
 	RATING_PLANS_FN    = "rating_plans.json"
 	RATING_PROFILES_FN = "rating_profiles.json"
+	ACCOUNTS_FN        = "accounts.json"
+	ACTIONS_FN         = "actions.json"
+	ACTION_PLANS_FN    = "action_plans.json"
 )
 
 type Record struct {
-	Id       string
-	Filename string
-	Payload  []byte
-	Deleted  bool
+	Id        string
+	Filename  string
+	Payload   []byte
+	Deleted   bool
+	Author    string    // Component or user performing the change
+	Timestamp time.Time // When the change was performed, set on receive if empty
+}
+
+// ObjectType returns the type of the recorded object, eg: destinations
+func (rec *Record) ObjectType() string {
+	return strings.TrimSuffix(rec.Filename, filepath.Ext(rec.Filename))
 }
 
 type records []*Record
@@ -30,6 +30,7 @@ import (
 
 type Scheduler struct {
 	queue       engine.ActionTimingPriorityList
+	statics     engine.ActionTimingPriorityList // queued again on each reload, eg: CDR export jobs out of config
 	timer       *time.Timer
 	restartLoop chan bool
 	sync.Mutex
@@ -138,10 +139,36 @@ func (s *Scheduler) loadActionPlans() {
 
 		}
 	}
+	for _, at := range s.statics {
+		now := time.Now()
+		if at.GetNextStartTime(now).Before(now) {
+			continue
+		}
+		s.queue = append(s.queue, at)
+	}
 	sort.Sort(s.queue)
 	utils.Logger.Info(fmt.Sprintf("<Scheduler> queued %d action plans", len(s.queue)))
 }
 
+// Queues an action timing which is not part of the stored action plans, it is lost on reload
+func (s *Scheduler) QueueActionTiming(at *engine.ActionTiming) {
+	s.Lock()
+	s.queue = append(s.queue, at)
+	sort.Sort(s.queue)
+	s.Unlock()
+	s.restart()
+}
+
+// Queues an action timing which is not part of the stored action plans, kept over reloads
+func (s *Scheduler) QueueStaticActionTiming(at *engine.ActionTiming) {
+	s.Lock()
+	s.statics = append(s.statics, at)
+	s.queue = append(s.queue, at)
+	sort.Sort(s.queue)
+	s.Unlock()
+	s.restart()
+}
+
 func (s *Scheduler) restart() {
 	if s.schedulerStarted {
 		s.restartLoop <- true
@@ -151,6 +178,13 @@ func (s *Scheduler) restart() {
 	}
 }
 
+// QueueLength returns the number of action timings waiting to be executed
+func (s *Scheduler) QueueLength() int {
+	s.Lock()
+	defer s.Unlock()
+	return len(s.queue)
+}
+
 func (s *Scheduler) GetQueue() engine.ActionTimingPriorityList {
 	return s.queue
 }
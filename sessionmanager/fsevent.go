@@ -64,6 +64,7 @@ const (
 	UNAUTHORIZED_DESTINATION = "-UNAUTHORIZED_DESTINATION"
 	MISSING_PARAMETER        = "-MISSING_PARAMETER"
 	SYSTEM_ERROR             = "-SYSTEM_ERROR"
+	SESSION_TTL_EXPIRED      = "-SESSION_TTL_EXPIRED"
 	MANAGER_REQUEST          = "+MANAGER_REQUEST"
 	USERNAME                 = "Caller-Username"
 	FS_IPv4                  = "FreeSWITCH-IPv4"
@@ -23,6 +23,8 @@ import (
 	"fmt"
 	"log/syslog"
 	"regexp"
+	"strconv"
+	"sync"
 	"time"
 
 	"github.com/cgrates/cgrates/config"
@@ -33,17 +35,30 @@ import (
 )
 
 func NewKamailioSessionManager(smKamCfg *config.SmKamConfig, rater, cdrsrv rpcclient.RpcClientConnection, timezone string) (*KamailioSessionManager, error) {
-	ksm := &KamailioSessionManager{cfg: smKamCfg, rater: rater, cdrsrv: cdrsrv, timezone: timezone, conns: make(map[string]*kamevapi.KamEvapi), sessions: NewSessions()}
+	ksm := &KamailioSessionManager{cfg: smKamCfg, rater: rater, cdrsrv: cdrsrv, timezone: timezone, conns: make(map[string]*kamevapi.KamEvapi), sessions: NewSessions(),
+		dlgListReplies: make(map[string]chan *KamDlgListReply), pendingSyncs: make(map[string]bool), stopSync: make(chan struct{})}
 	return ksm, nil
 }
 
 type KamailioSessionManager struct {
-	cfg      *config.SmKamConfig
-	rater    rpcclient.RpcClientConnection
-	cdrsrv   rpcclient.RpcClientConnection
-	timezone string
-	conns    map[string]*kamevapi.KamEvapi
-	sessions *Sessions
+	cfg            *config.SmKamConfig
+	rater          rpcclient.RpcClientConnection
+	cdrsrv         rpcclient.RpcClientConnection
+	timezone       string
+	conns          map[string]*kamevapi.KamEvapi
+	connsMux       sync.RWMutex
+	pendingSyncs   map[string]bool // Connections which connected before being stored in conns, synced once stored
+	sessions       *Sessions
+	dlgListReplies map[string]chan *KamDlgListReply // Requests for active dialogs waiting for reply, indexed on ReplyTag
+	dlgListMux     sync.Mutex
+	stopSync       chan struct{} // Stops the scheduled syncs
+	stopSyncOnce   sync.Once
+}
+
+func (self *KamailioSessionManager) conn(connId string) *kamevapi.KamEvapi {
+	self.connsMux.RLock()
+	defer self.connsMux.RUnlock()
+	return self.conns[connId]
 }
 
 func (self *KamailioSessionManager) onCgrAuth(evData []byte, connId string) {
@@ -58,7 +73,7 @@ func (self *KamailioSessionManager) onCgrAuth(evData []byte, connId string) {
 	if kev.MissingParameter(self.timezone) {
 		if kar, err := kev.AsKamAuthReply(0.0, "", utils.ErrMandatoryIeMissing); err != nil {
 			utils.Logger.Err(fmt.Sprintf("<SM-Kamailio> Failed building auth reply %s", err.Error()))
-		} else if err = self.conns[connId].Send(kar.String()); err != nil {
+		} else if err = self.conn(connId).Send(kar.String()); err != nil {
 			utils.Logger.Err(fmt.Sprintf("<SM-Kamailio> Failed sending auth reply %s", err.Error()))
 		}
 		return
@@ -80,7 +95,7 @@ func (self *KamailioSessionManager) onCgrAuth(evData []byte, connId string) {
 	}
 	if kar, err := kev.AsKamAuthReply(remainingDuration, supplStr, errMaxSession); err != nil {
 		utils.Logger.Err(fmt.Sprintf("<SM-Kamailio> Failed building auth reply %s", err.Error()))
-	} else if err = self.conns[connId].Send(kar.String()); err != nil {
+	} else if err = self.conn(connId).Send(kar.String()); err != nil {
 		utils.Logger.Err(fmt.Sprintf("<SM-Kamailio> Failed sending auth reply %s", err.Error()))
 	}
 }
@@ -96,7 +111,7 @@ func (self *KamailioSessionManager) onCgrLcrReq(evData []byte, connId string) {
 	kamLcrReply.Event = CGR_LCR_REPLY // Hit the CGR_LCR_REPLY event route on Kamailio side
 	if errReply != nil {
 		utils.Logger.Err(fmt.Sprintf("<SM-Kamailio> Failed building auth reply %s", errReply.Error()))
-	} else if err = self.conns[connId].Send(kamLcrReply.String()); err != nil {
+	} else if err = self.conn(connId).Send(kamLcrReply.String()); err != nil {
 		utils.Logger.Err(fmt.Sprintf("<SM-Kamailio> Failed sending lcr reply %s", err.Error()))
 	}
 }
@@ -161,34 +176,97 @@ func (self *KamailioSessionManager) onCallEnd(evData []byte, connId string) {
 	}
 }
 
+// Kamailio (re)connected to us, it might have lost the dialogs in the meantime
+func (self *KamailioSessionManager) onEvapiConnected(evData []byte, connId string) {
+	self.connsMux.Lock()
+	_, hasConn := self.conns[connId]
+	if !hasConn { // Connected while still connecting, Connect will sync once the connection is stored
+		self.pendingSyncs[connId] = true
+	}
+	self.connsMux.Unlock()
+	if hasConn {
+		self.syncConnSessionsLogged(connId)
+	}
+}
+
+func (self *KamailioSessionManager) syncConnSessionsLogged(connId string) {
+	if err := self.syncConnSessions(connId); err != nil {
+		utils.Logger.Err(fmt.Sprintf("<SM-Kamailio> Error on syncing active dialogs, connection id: %s, error: %s", connId, err.Error()))
+	}
+}
+
+func (self *KamailioSessionManager) onDlgListReply(evData []byte, connId string) {
+	dlgList, err := NewKamDlgListReply(evData)
+	if err != nil {
+		utils.Logger.Err(fmt.Sprintf("<SM-Kamailio> ERROR unmarshalling event: %s, error: %s", evData, err.Error()))
+		return
+	}
+	self.dlgListMux.Lock()
+	replyChan, hasIt := self.dlgListReplies[dlgList.ReplyTag]
+	delete(self.dlgListReplies, dlgList.ReplyTag) // Only first reply is considered
+	self.dlgListMux.Unlock()
+	if !hasIt { // Not requested by us or arrived too late
+		return
+	}
+	replyChan <- dlgList
+}
+
 func (self *KamailioSessionManager) Connect() error {
 	var err error
 	eventHandlers := map[*regexp.Regexp][]func([]byte, string){
-		regexp.MustCompile("CGR_AUTH_REQUEST"): []func([]byte, string){self.onCgrAuth},
-		regexp.MustCompile("CGR_LCR_REQUEST"):  []func([]byte, string){self.onCgrLcrReq},
-		regexp.MustCompile("CGR_CALL_START"):   []func([]byte, string){self.onCallStart},
-		regexp.MustCompile("CGR_CALL_END"):     []func([]byte, string){self.onCallEnd},
+		regexp.MustCompile("CGR_AUTH_REQUEST"):    []func([]byte, string){self.onCgrAuth},
+		regexp.MustCompile("CGR_LCR_REQUEST"):     []func([]byte, string){self.onCgrLcrReq},
+		regexp.MustCompile("CGR_CALL_START"):      []func([]byte, string){self.onCallStart},
+		regexp.MustCompile("CGR_CALL_END"):        []func([]byte, string){self.onCallEnd},
+		regexp.MustCompile("CGR_DLG_LIST_REPLY"):  []func([]byte, string){self.onDlgListReply},
+		regexp.MustCompile("CGR_EVAPI_CONNECTED"): []func([]byte, string){self.onEvapiConnected},
 	}
 	errChan := make(chan error)
+	defer self.stopSyncing()
 	for _, connCfg := range self.cfg.EvapiConns {
 		connId := utils.GenUUID()
-		if self.conns[connId], err = kamevapi.NewKamEvapi(connCfg.Address, connId, connCfg.Reconnects, eventHandlers, utils.Logger.(*syslog.Writer)); err != nil {
+		conn, err := kamevapi.NewKamEvapi(connCfg.Address, connId, connCfg.Reconnects, eventHandlers, utils.Logger.(*syslog.Writer))
+		if err != nil {
 			return err
 		}
+		self.connsMux.Lock()
+		self.conns[connId] = conn
+		pendingSync := self.pendingSyncs[connId]
+		delete(self.pendingSyncs, connId)
+		self.connsMux.Unlock()
+		if pendingSync {
+			go self.syncConnSessionsLogged(connId)
+		}
 		go func() { // Start reading in own goroutine, return on error
-			if err := self.conns[connId].ReadEvents(); err != nil {
+			if err := conn.ReadEvents(); err != nil {
 				errChan <- err
 			}
 		}()
 	}
+	if self.cfg.ChannelSyncInterval != 0 { // Schedule running of the callsync
+		go func() {
+			for { // Schedule sync dialogs to run repetately
+				select {
+				case <-self.stopSync:
+					return
+				case <-time.After(self.cfg.ChannelSyncInterval):
+					self.SyncSessions()
+				}
+			}
+		}()
+	}
 	err = <-errChan // Will keep the Connect locked until the first error in one of the connections
 	return err
 }
 
+func (self *KamailioSessionManager) stopSyncing() {
+	self.stopSyncOnce.Do(func() { close(self.stopSync) })
+}
+
 func (self *KamailioSessionManager) DisconnectSession(ev engine.Event, connId, notify string) error {
 	sessionIds := ev.GetSessionIds()
 	disconnectEv := &KamSessionDisconnect{Event: CGR_SESSION_DISCONNECT, HashEntry: sessionIds[0], HashId: sessionIds[1], Reason: notify}
-	if err := self.conns[connId].Send(disconnectEv.String()); err != nil {
+	if err := self.conn(connId).Send(disconnectEv.String()); err != nil {
 		utils.Logger.Err(fmt.Sprintf("<SM-Kamailio> Failed sending disconnect request, error %s, connection id: %s", err.Error(), connId))
 		return err
 	}
@@ -220,6 +298,7 @@ func (sm *KamailioSessionManager) WarnSessionMinDuration(sessionUuid, connId str
 }
 
 func (self *KamailioSessionManager) Shutdown() error {
+	self.stopSyncing()
 	return nil
 }
 
@@ -227,7 +306,75 @@ func (self *KamailioSessionManager) Sessions() []*Session {
 	return self.sessions.getSessions()
 }
 
+// Sync sessions with the dialogs active on Kamailio side, closing the ones Kamailio does not know anymore
 func (self *KamailioSessionManager) SyncSessions() error {
+	self.connsMux.RLock()
+	connIds := make([]string, 0, len(self.conns))
+	for connId := range self.conns {
+		connIds = append(connIds, connId)
+	}
+	self.connsMux.RUnlock()
+	for _, connId := range connIds {
+		if err := self.syncConnSessions(connId); err != nil {
+			utils.Logger.Err(fmt.Sprintf("<SM-Kamailio> Error on syncing active dialogs, connection id: %s, error: %s", connId, err.Error()))
+		}
+	}
+	return nil
+}
+
+// Sync the sessions of one evapi connection with the dialogs listed by its Kamailio
+func (self *KamailioSessionManager) syncConnSessions(connId string) error {
+	conn := self.conn(connId)
+	if conn == nil {
+		return errors.New("connection not available")
+	}
+	var connSessions []*Session // Collected before querying so sessions started meanwhile are not considered stale
+	for _, s := range self.sessions.getSessions() {
+		if s.connId == connId {
+			connSessions = append(connSessions, s)
+		}
+	}
+	dlgListReq := &KamDlgListRequest{Event: CGR_DLG_LIST, ReplyTag: utils.GenUUID()}
+	replyChan := make(chan *KamDlgListReply, 1)
+	self.dlgListMux.Lock()
+	self.dlgListReplies[dlgListReq.ReplyTag] = replyChan
+	self.dlgListMux.Unlock()
+	defer func() {
+		self.dlgListMux.Lock()
+		delete(self.dlgListReplies, dlgListReq.ReplyTag)
+		self.dlgListMux.Unlock()
+	}()
+	if err := conn.Send(dlgListReq.String()); err != nil {
+		return err
+	}
+	var dlgList *KamDlgListReply
+	select {
+	case dlgList = <-replyChan:
+	case <-time.After(KAM_DLG_LIST_TIMEOUT):
+		return errors.New("timeout waiting for dialogs list")
+	}
+	dlgs, err := dlgList.Dialogs()
+	if err != nil { // Without a valid list all the sessions would be seen as stale
+		return err
+	}
+	activeDlgs := make(map[string]bool)
+	for _, dlg := range dlgs {
+		activeDlgs[dlg.GetUUID()] = true
+	}
+	for _, s := range connSessions {
+		if activeDlgs[s.eventStart.GetUUID()] { // Dialog still active
+			continue
+		}
+		utils.Logger.Warning(fmt.Sprintf("<SM-Kamailio> Sync active dialogs, stale session detected, uuid: %s", s.eventStart.GetUUID()))
+		kev := s.eventStart.(KamEvent)
+		now := time.Now()
+		aTime, _ := kev.GetAnswerTime(utils.META_DEFAULT, self.timezone)
+		kev[CGR_STOPTIME] = strconv.FormatInt(now.Unix(), 10)
+		kev[CGR_DURATION] = strconv.FormatFloat(now.Sub(aTime).Seconds(), 'f', -1, 64)
+		if err := self.sessions.removeSession(s, kev); err != nil { // Stop loop, refund advanced charges and save the costs deducted so far to database
+			utils.Logger.Err(fmt.Sprintf("<SM-Kamailio> Error on removing stale session with uuid: %s, error: %s", s.eventStart.GetUUID(), err.Error()))
+		}
+	}
 	return nil
 }
 
@@ -20,6 +20,8 @@ package sessionmanager
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
 	"strconv"
 	"strings"
 	"time"
@@ -38,6 +40,9 @@ const (
 	CGR_SESSION_DISCONNECT = "CGR_SESSION_DISCONNECT"
 	CGR_CALL_START         = "CGR_CALL_START"
 	CGR_CALL_END           = "CGR_CALL_END"
+	CGR_DLG_LIST           = "CGR_DLG_LIST"
+	CGR_DLG_LIST_REPLY     = "CGR_DLG_LIST_REPLY"
+	CGR_EVAPI_CONNECTED    = "CGR_EVAPI_CONNECTED"
 	CGR_SETUPTIME          = "cgr_setuptime"
 	CGR_ANSWERTIME         = "cgr_answertime"
 	CGR_STOPTIME           = "cgr_stoptime"
@@ -48,6 +53,8 @@ const (
 	KAM_TR_LABEL = "tr_label"
 	HASH_ENTRY   = "h_entry"
 	HASH_ID      = "h_id"
+
+	KAM_DLG_LIST_TIMEOUT = time.Duration(5) * time.Second // Wait for Kamailio to list its dialogs
 )
 
 var primaryFields = []string{EVENT, CALLID, FROM_TAG, HASH_ENTRY, HASH_ID, CGR_ACCOUNT, CGR_SUBJECT, CGR_DESTINATION,
@@ -91,6 +98,67 @@ func (self *KamSessionDisconnect) String() string {
 	return string(mrsh)
 }
 
+// Request for the dialogs active on Kamailio side, answered with CGR_DLG_LIST_REPLY
+type KamDlgListRequest struct {
+	Event    string
+	ReplyTag string // Sent back within reply so we can match it with the request
+}
+
+func (self *KamDlgListRequest) String() string {
+	mrsh, _ := json.Marshal(self)
+	return string(mrsh)
+}
+
+// Reply of Kamailio containing the output of dlg.list RPC command
+type KamDlgListReply struct {
+	Event       string `json:"event"`
+	ReplyTag    string `json:"reply_tag"`
+	JsonrplBody *struct {
+		Result *[]*KamDialog `json:"result"` // nil if missing out of reply
+		Error  *struct {
+			Code    int    `json:"code"`
+			Message string `json:"message"`
+		} `json:"error"`
+	} `json:"jsonrpl_body"` // nil if the RPC command failed
+}
+
+// Dialogs returns the active dialogs, error if the reply does not contain a valid dlg.list result
+func (self *KamDlgListReply) Dialogs() ([]*KamDialog, error) {
+	if self.JsonrplBody == nil {
+		return nil, errors.New("dialogs list not available")
+	}
+	if self.JsonrplBody.Error != nil {
+		return nil, fmt.Errorf("dialogs list error, code: %d, message: %s", self.JsonrplBody.Error.Code, self.JsonrplBody.Error.Message)
+	}
+	if self.JsonrplBody.Result == nil {
+		return nil, errors.New("dialogs list without result")
+	}
+	return *self.JsonrplBody.Result, nil
+}
+
+// One active dialog out of dlg.list
+type KamDialog struct {
+	HashEntry int    `json:"h_entry"`
+	HashId    int    `json:"h_id"`
+	CallId    string `json:"call-id"`
+	Caller    struct {
+		Tag string `json:"tag"`
+	} `json:"caller"`
+}
+
+// Matches KamEvent.GetUUID
+func (kd *KamDialog) GetUUID() string {
+	return kd.CallId + ";" + kd.Caller.Tag
+}
+
+func NewKamDlgListReply(kamEvData []byte) (*KamDlgListReply, error) {
+	dlgList := new(KamDlgListReply)
+	if err := json.Unmarshal(kamEvData, dlgList); err != nil {
+		return nil, err
+	}
+	return dlgList, nil
+}
+
 func NewKamEvent(kamEvData []byte) (KamEvent, error) {
 	kev := make(map[string]string)
 	if err := json.Unmarshal(kamEvData, &kev); err != nil {
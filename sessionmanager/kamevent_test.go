@@ -125,3 +125,50 @@ func TestKevAsCallDescriptor(t *testing.T) {
 		t.Errorf("Expecting: %+v, received: %+v", eCd, cd)
 	}
 }
+
+func TestNewKamDlgListReply(t *testing.T) {
+	evStr := `{"event":"CGR_DLG_LIST_REPLY",
+		"reply_tag":"a8b0fc3d",
+		"jsonrpl_body":{"jsonrpc":"2.0","result":[{"h_entry":1306,"h_id":1468002191,"call-id":"46c01a5c249b469e76333fc6bfa87f6a@0:0:0:0:0:0:0:0",
+			"state":4,"caller":{"tag":"bf71ad59","contact":"sip:1001@127.0.0.1:5060"},"callee":{"tag":"7351fecf"}}],"id":1}}`
+	if dlgList, err := NewKamDlgListReply([]byte(evStr)); err != nil {
+		t.Error(err)
+	} else if dlgList.ReplyTag != "a8b0fc3d" {
+		t.Error("Received: ", dlgList.ReplyTag)
+	} else if dlgs, err := dlgList.Dialogs(); err != nil {
+		t.Error(err)
+	} else if len(dlgs) != 1 {
+		t.Errorf("Received: %+v", dlgs)
+	} else if dlg := dlgs[0]; dlg.HashEntry != 1306 || dlg.HashId != 1468002191 {
+		t.Errorf("Received: %+v", dlg)
+	} else if kev := (KamEvent{CALLID: "46c01a5c249b469e76333fc6bfa87f6a@0:0:0:0:0:0:0:0", FROM_TAG: "bf71ad59"}); dlg.GetUUID() != kev.GetUUID() {
+		t.Errorf("Expecting: %s, received: %s", kev.GetUUID(), dlg.GetUUID())
+	}
+	// Failed dlg.list command
+	if dlgList, err := NewKamDlgListReply([]byte(`{"event":"CGR_DLG_LIST_REPLY","reply_tag":"a8b0fc3d","jsonrpl_body":null}`)); err != nil {
+		t.Error(err)
+	} else if dlgList.JsonrplBody != nil {
+		t.Errorf("Received: %+v", dlgList.JsonrplBody)
+	} else if _, err := dlgList.Dialogs(); err == nil {
+		t.Error("Expecting error for missing body")
+	}
+	// No dialogs active
+	if dlgList, err := NewKamDlgListReply([]byte(`{"event":"CGR_DLG_LIST_REPLY","reply_tag":"a8b0fc3d","jsonrpl_body":{"jsonrpc":"2.0","result":[],"id":1}}`)); err != nil {
+		t.Error(err)
+	} else if dlgs, err := dlgList.Dialogs(); err != nil {
+		t.Error(err)
+	} else if len(dlgs) != 0 {
+		t.Errorf("Received: %+v", dlgs)
+	}
+	// JSON-RPC error reply, should not be seen as no dialogs active
+	for _, evStr := range []string{
+		`{"event":"CGR_DLG_LIST_REPLY","reply_tag":"a8b0fc3d","jsonrpl_body":{"jsonrpc":"2.0","error":{"code":500,"message":"Internal Server Error"},"id":1}}`,
+		`{"event":"CGR_DLG_LIST_REPLY","reply_tag":"a8b0fc3d","jsonrpl_body":{"jsonrpc":"2.0","id":1}}`,
+	} {
+		if dlgList, err := NewKamDlgListReply([]byte(evStr)); err != nil {
+			t.Error(err)
+		} else if _, err := dlgList.Dialogs(); err == nil {
+			t.Errorf("Expecting error for reply: %s", evStr)
+		}
+	}
+}
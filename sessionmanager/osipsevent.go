@@ -53,6 +53,7 @@ const (
 	OSIPS_INSUFFICIENT_FUNDS = "INSUFFICIENT_FUNDS"
 	OSIPS_DIALOG_ID          = "dialog_id"
 	OSIPS_SIPCODE            = "sip_code"
+	OSIPS_MI_DATAGRAM_SIZE   = 65457 // Maximum size of mi_datagram replies, bigger ones are truncated
 )
 
 func NewOsipsEvent(osipsDagramEvent *osipsdagram.OsipsEvent) (*OsipsEvent, error) {
@@ -121,6 +121,18 @@ func (osm *OsipsSessionManager) Connect() (err error) {
 		return
 	}
 	utils.Logger.Info(fmt.Sprintf("<SM-OpenSIPS> Listening for datagram events at <%s>", osm.cfg.ListenUdp))
+	if osm.cfg.ChannelSyncInterval != 0 { // Schedule running of the callsync
+		go func(stopServing chan struct{}) {
+			for {
+				select {
+				case <-stopServing:
+					return
+				case <-time.After(osm.cfg.ChannelSyncInterval):
+					osm.SyncSessions()
+				}
+			}
+		}(osm.stopServing)
+	}
 	evsrv.ServeEvents(osm.stopServing) // Will break through stopServing on error in other places
 	return errors.New("<SM-OpenSIPS> Stopped reading events")
 }
@@ -225,6 +237,7 @@ func (osm *OsipsSessionManager) onOpensipsStart(cdrDagram *osipsdagram.OsipsEven
 	osm.evSubscribeStop <- struct{}{}         // Cancel previous subscribes
 	osm.evSubscribeStop = make(chan struct{}) // Create a fresh communication channel
 	go osm.SubscribeEvents(osm.evSubscribeStop)
+	go osm.SyncSessions() // Dialogs were lost on restart
 }
 
 // Triggered by CDR event
@@ -329,11 +342,61 @@ func (osm *OsipsSessionManager) Sessions() []*Session {
 	return osm.sessions.getSessions()
 }
 
-// Sync sessions with FS
+// Sync sessions with the dialogs active on OpenSIPS, closing the ones OpenSIPS does not know anymore
 func (osm *OsipsSessionManager) SyncSessions() error {
+	sessions := osm.sessions.getSessions() // Collected before querying so sessions started meanwhile are not considered stale
+	if len(sessions) == 0 {
+		return nil
+	}
+	reply, err := osm.miConn.SendCommand([]byte(":dlg_list:\n\n"))
+	if err != nil {
+		utils.Logger.Err(fmt.Sprintf("<SM-OpenSIPS> Error on syncing active dialogs, error: %s", err.Error()))
+		return err
+	}
+	activeDlgs, err := osipsActiveDialogs(reply)
+	if err != nil {
+		utils.Logger.Err(fmt.Sprintf("<SM-OpenSIPS> Error on syncing active dialogs, error: %s", err.Error()))
+		return err
+	}
+	for _, s := range sessions {
+		if activeDlgs[s.eventStart.GetUUID()] { // Dialog still active
+			continue
+		}
+		utils.Logger.Warning(fmt.Sprintf("<SM-OpenSIPS> Sync active dialogs, stale session detected, uuid: %s", s.eventStart.GetUUID()))
+		osipsEv := s.eventStart.(*OsipsEvent)
+		aTime, _ := osipsEv.GetAnswerTime(utils.META_DEFAULT, osm.timezone)
+		osipsEv.osipsEvent.AttrValues[OSIPS_DURATION] = time.Now().Sub(aTime).String()
+		osipsEv.osipsEvent.AttrValues["method"] = "UPDATE" // So we can know it is an end event
+		if err := osm.sessions.removeSession(s, osipsEv); err != nil {
+			utils.Logger.Err(fmt.Sprintf("<SM-OpenSIPS> Error on removing stale session with uuid: %s, error: %s", s.eventStart.GetUUID(), err.Error()))
+		}
+	}
 	return nil
 }
 
+// Returns the callids out of dlg_list MI command reply, eg:
+// 200 OK
+// dialog:: hash=1306:1468002191
+//
+//	state:: 4
+//	callid:: 05dac0aaa716c9814f855f0e8fee6936@0:0:0:0:0:0:0:0
+func osipsActiveDialogs(miReply []byte) (map[string]bool, error) {
+	if !bytes.HasPrefix(miReply, []byte("200 OK")) {
+		return nil, fmt.Errorf("unexpected dlg_list reply: %q", bytes.SplitN(miReply, []byte("\n"), 2)[0])
+	}
+	if len(miReply) >= OSIPS_MI_DATAGRAM_SIZE { // Reply was truncated, we cannot tell which dialogs are missing
+		return nil, errors.New("dlg_list reply too big")
+	}
+	activeDlgs := make(map[string]bool)
+	for _, line := range strings.Split(string(miReply), "\n") {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, CALLID+"::") {
+			activeDlgs[strings.TrimSpace(line[len(CALLID)+2:])] = true
+		}
+	}
+	return activeDlgs, nil
+}
+
 func (osm *OsipsSessionManager) Timezone() string {
 	return osm.timezone
 }
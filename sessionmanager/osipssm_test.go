@@ -19,9 +19,45 @@ along with this program.  If not, see <http://www.gnu.org/licenses/>
 package sessionmanager
 
 import (
+	"bytes"
+	"reflect"
 	"testing"
 )
 
 func TestOsipsSMInterface(t *testing.T) {
 	var _ SessionManager = SessionManager(new(OsipsSessionManager))
 }
+
+func TestOsipsActiveDialogs(t *testing.T) {
+	miReply := `200 OK
+dialog:: hash=1306:1468002191
+	state:: 4
+	user_flags:: 0
+	timestart:: 1430579770
+	callid:: 05dac0aaa716c9814f855f0e8fee6936@0:0:0:0:0:0:0:0
+	from_uri:: sip:1001@127.0.0.1
+	caller_tag:: 87d02470
+dialog:: hash=2541:1751431112
+	state:: 4
+	callid:: c0965d3f42c720397ca1a5be9619c2ef@0:0:0:0:0:0:0:0
+
+`
+	eDlgs := map[string]bool{"05dac0aaa716c9814f855f0e8fee6936@0:0:0:0:0:0:0:0": true, "c0965d3f42c720397ca1a5be9619c2ef@0:0:0:0:0:0:0:0": true}
+	if dlgs, err := osipsActiveDialogs([]byte(miReply)); err != nil {
+		t.Error(err)
+	} else if !reflect.DeepEqual(eDlgs, dlgs) {
+		t.Errorf("Expecting: %+v, received: %+v", eDlgs, dlgs)
+	}
+	if dlgs, err := osipsActiveDialogs([]byte("200 OK\n\n")); err != nil {
+		t.Error(err)
+	} else if len(dlgs) != 0 {
+		t.Errorf("Received: %+v", dlgs)
+	}
+	if _, err := osipsActiveDialogs([]byte("500 command 'dlg_list' not available\n\n")); err == nil {
+		t.Error("Expecting error")
+	}
+	truncated := append([]byte("200 OK\n"), bytes.Repeat([]byte("\tcallid:: 05dac0aaa716c9814f855f0e8fee6936\n"), OSIPS_MI_DATAGRAM_SIZE/40)...)
+	if _, err := osipsActiveDialogs(truncated[:OSIPS_MI_DATAGRAM_SIZE]); err == nil {
+		t.Error("Expecting error")
+	}
+}
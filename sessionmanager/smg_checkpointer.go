@@ -0,0 +1,95 @@
+/*
+Real-time Charging System for Telecom & ISP environments
+Copyright (C) ITsysCOM GmbH
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>
+*/
+
+package sessionmanager
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/cgrates/cgrates/engine"
+	"github.com/cgrates/cgrates/utils"
+)
+
+const SMG_CHECKPOINT_QUEUE_SIZE = 10000
+
+func newSMGCheckpointer(dataDB engine.AccountingStorage) *smgCheckpointer {
+	cp := &smgCheckpointer{dataDB: dataDB, queue: make(chan *smgCheckpoint, SMG_CHECKPOINT_QUEUE_SIZE)}
+	go cp.serve()
+	return cp
+}
+
+// smgCheckpointer writes the session states into dataDB out of the debit path, one by one in the order they were produced,
+// so a session removed or relocated is never written back with an older state
+type smgCheckpointer struct {
+	dataDB  engine.AccountingStorage
+	queue   chan *smgCheckpoint
+	pending sync.WaitGroup
+}
+
+type smgCheckpoint struct {
+	smgCp     *engine.SMGSessionCheckpoint // nil to remove the checkpoint
+	id        string
+	callCosts []*engine.CallCost // CallCosts debited since the previous checkpoint, appended to the stored ones
+}
+
+// Queues the session state, waiting for room in the queue if dataDB cannot keep up so no state is lost
+func (cp *smgCheckpointer) checkpoint(smgCp *engine.SMGSessionCheckpoint, newCallCosts []*engine.CallCost) {
+	cp.queueCheckpoint(&smgCheckpoint{smgCp: smgCp, id: smgCp.GetId(), callCosts: newCallCosts})
+}
+
+// Queues the removal of the session state indexed on id
+func (cp *smgCheckpointer) remove(id string) {
+	cp.queueCheckpoint(&smgCheckpoint{id: id})
+}
+
+func (cp *smgCheckpointer) queueCheckpoint(smgCkp *smgCheckpoint) {
+	cp.pending.Add(1)
+	select {
+	case cp.queue <- smgCkp:
+	default:
+		utils.Logger.Warning(fmt.Sprintf("<SMGeneric> Checkpoint queue full, waiting to checkpoint session: %s", smgCkp.id))
+		cp.queue <- smgCkp
+	}
+}
+
+// Waits for the queued checkpoints to be written
+func (cp *smgCheckpointer) flush() {
+	cp.pending.Wait()
+}
+
+func (cp *smgCheckpointer) serve() {
+	for smgCkp := range cp.queue {
+		if smgCkp.smgCp == nil {
+			if err := cp.dataDB.RemoveSMGSessionCheckpoint(smgCkp.id); err != nil {
+				utils.Logger.Err(fmt.Sprintf("<SMGeneric> Could not remove checkpoint of session: %s, error: %s", smgCkp.id, err.Error()))
+			}
+			cp.pending.Done()
+			continue
+		}
+		if err := cp.dataDB.SetSMGSessionCheckpoint(smgCkp.smgCp); err != nil {
+			utils.Logger.Err(fmt.Sprintf("<SMGeneric> Could not checkpoint session: %s, error: %s", smgCkp.id, err.Error()))
+		}
+		if len(smgCkp.callCosts) != 0 {
+			if err := cp.dataDB.AddSMGSessionCallCosts(smgCkp.id, smgCkp.callCosts); err != nil {
+				utils.Logger.Err(fmt.Sprintf("<SMGeneric> Could not checkpoint CallCosts of session: %s, error: %s", smgCkp.id, err.Error()))
+			}
+		}
+		cp.pending.Done()
+	}
+}
@@ -24,6 +24,7 @@ import (
 
 	"github.com/cenkalti/rpc2"
 	"github.com/cgrates/cgrates/utils"
+	"github.com/cgrates/rpcclient"
 )
 
 const CGR_CONNUUID = "cgr_connid"
@@ -43,11 +44,11 @@ func getClientConnId(clnt *rpc2.Client) string {
 }
 
 func NewSMGExternalConnections() *SMGExternalConnections {
-	return &SMGExternalConnections{conns: make(map[string]*rpc2.Client), connMux: new(sync.Mutex)}
+	return &SMGExternalConnections{conns: make(map[string]rpcclient.RpcClientConnection), connMux: new(sync.Mutex)}
 }
 
 type SMGExternalConnections struct {
-	conns   map[string]*rpc2.Client
+	conns   map[string]rpcclient.RpcClientConnection
 	connMux *sync.Mutex
 }
 
@@ -69,7 +70,7 @@ func (self *SMGExternalConnections) OnClientDisconnect(clnt *rpc2.Client) {
 	}
 }
 
-func (self *SMGExternalConnections) GetConnection(connId string) *rpc2.Client {
+func (self *SMGExternalConnections) GetConnection(connId string) rpcclient.RpcClientConnection {
 	self.connMux.Lock()
 	defer self.connMux.Unlock()
 	return self.conns[connId]
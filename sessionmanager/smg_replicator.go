@@ -0,0 +1,92 @@
+/*
+Real-time Charging System for Telecom & ISP environments
+Copyright (C) ITsysCOM GmbH
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>
+*/
+
+package sessionmanager
+
+import (
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/cgrates/cgrates/engine"
+	"github.com/cgrates/cgrates/utils"
+	"github.com/cgrates/rpcclient"
+)
+
+const (
+	SMG_REPLICATION_QUEUE_SIZE    = 10000
+	SMG_REPLICATION_QUEUE_TIMEOUT = time.Second // How long a full queue is waited for before dropping the replication
+)
+
+func newSMGReplicator(replConns rpcclient.RpcClientConnection) *smgReplicator {
+	r := &smgReplicator{replConns: replConns, queue: make(chan *smgReplication, SMG_REPLICATION_QUEUE_SIZE),
+		queueTimeout: SMG_REPLICATION_QUEUE_TIMEOUT}
+	go r.serve()
+	return r
+}
+
+// smgReplicator sends the session states to the replication peers one by one, in the order they were produced,
+// so a peer never ends up with an older state or a session which was already removed
+type smgReplicator struct {
+	replConns    rpcclient.RpcClientConnection
+	queue        chan *smgReplication
+	queueTimeout time.Duration // waiting for room in a full queue before dropping
+	dropped      int64         // replications dropped since start, accessed atomically
+}
+
+type smgReplication struct {
+	method string
+	smgCp  *engine.SMGSessionCheckpoint
+}
+
+// Queues the replication without waiting for the peers.
+// If the peers cannot keep up and the queue stays full for queueTimeout, the replication is dropped and counted so the debits are not held longer.
+// Passive sessions missing their removal expire on the peers.
+func (r *smgReplicator) replicate(method string, smgCp *engine.SMGSessionCheckpoint) {
+	repl := &smgReplication{method: method, smgCp: smgCp}
+	select {
+	case r.queue <- repl:
+		return
+	default:
+	}
+	utils.Logger.Warning(fmt.Sprintf("<SMGeneric> Replication queue full, waiting to replicate session: %s, runId: %s", smgCp.SessionID, smgCp.RunID))
+	timer := time.NewTimer(r.queueTimeout)
+	defer timer.Stop()
+	select {
+	case r.queue <- repl:
+	case <-timer.C:
+		dropped := atomic.AddInt64(&r.dropped, 1)
+		utils.Logger.Err(fmt.Sprintf("<SMGeneric> Replication queue full for %v, dropping %s for session: %s, runId: %s, replications dropped: %d",
+			r.queueTimeout, method, smgCp.SessionID, smgCp.RunID, dropped))
+	}
+}
+
+// Number of replications dropped since start
+func (r *smgReplicator) droppedReplications() int64 {
+	return atomic.LoadInt64(&r.dropped)
+}
+
+func (r *smgReplicator) serve() {
+	for repl := range r.queue {
+		var reply string
+		if err := r.replConns.Call(repl.method, *repl.smgCp, &reply); err != nil && err.Error() != utils.ErrNotFound.Error() { // Peer might have missed the session
+			utils.Logger.Err(fmt.Sprintf("<SMGeneric> Could not replicate session: %s, runId: %s, method: %s, error: %s",
+				repl.smgCp.SessionID, repl.smgCp.RunID, repl.method, err.Error()))
+		}
+	}
+}
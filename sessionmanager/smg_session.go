@@ -21,6 +21,7 @@ package sessionmanager
 import (
 	"errors"
 	"fmt"
+	"sync"
 	"time"
 
 	"github.com/cgrates/cgrates/engine"
@@ -38,13 +39,66 @@ type SMGSession struct {
 	rater         rpcclient.RpcClientConnection // Connector to Rater service
 	cdrsrv        rpcclient.RpcClientConnection // Connector to CDRS service
 	extconns      *SMGExternalConnections
+	checkpointer  *smgCheckpointer // Checkpoint the session state into dataDB if not nil
+	replicator    *smgReplicator   // Mirror the session state as passive session on the peers if not nil
 	cd            *engine.CallDescriptor
 	sessionCds    []*engine.CallDescriptor
 	callCosts     []*engine.CallCost
-	extraDuration time.Duration // keeps the current duration debited on top of what heas been asked
-	lastUsage     time.Duration // last requested Duration
-	lastDebit     time.Duration // last real debited duration
-	totalUsage    time.Duration // sum of lastUsage
+	cpCallCosts   []*engine.CallCost // copies of the callCosts taken by checkpoints, never changed so the next checkpoints share them
+	storedCCs     int                // number of cpCallCosts already written into dataDB
+	extraDuration time.Duration      // keeps the current duration debited on top of what heas been asked
+	lastUsage     time.Duration      // last requested Duration
+	lastDebit     time.Duration      // last real debited duration
+	totalUsage    time.Duration      // sum of lastUsage
+	lastDebitTime time.Time          // when the last debit was performed
+	ttl           time.Duration      // session ttl settings received with the updates, checkpointed so they survive restarts
+	ttlLastUsed   *time.Duration
+	ttlUsage      *time.Duration
+	mux           sync.RWMutex // protects connId and ttl settings, changed by updates while the debit loop runs
+}
+
+func (self *SMGSession) getConnId() string {
+	self.mux.RLock()
+	defer self.mux.RUnlock()
+	return self.connId
+}
+
+func (self *SMGSession) setConnId(connId string) {
+	self.mux.Lock()
+	self.connId = connId
+	self.mux.Unlock()
+}
+
+// Records the ttl settings of an update, zero values do not override previous ones
+func (self *SMGSession) setTTLSettings(ttl time.Duration, ttlLastUsed, ttlUsage *time.Duration) {
+	self.mux.Lock()
+	if ttl != 0 {
+		self.ttl = ttl
+	}
+	if ttlLastUsed != nil {
+		self.ttlLastUsed = ttlLastUsed
+	}
+	if ttlUsage != nil {
+		self.ttlUsage = ttlUsage
+	}
+	self.mux.Unlock()
+}
+
+// Returns the ttl settings of the session, the ones received with updates overriding the ones of the start event
+func (self *SMGSession) ttlSettings() (ttl time.Duration, ttlLastUsed, ttlUsage *time.Duration) {
+	ttl, ttlLastUsed, ttlUsage = self.eventStart.GetSessionTTL(), self.eventStart.GetSessionTTLLastUsed(), self.eventStart.GetSessionTTLUsage()
+	self.mux.RLock()
+	defer self.mux.RUnlock()
+	if self.ttl != 0 {
+		ttl = self.ttl
+	}
+	if self.ttlLastUsed != nil {
+		ttlLastUsed = self.ttlLastUsed
+	}
+	if self.ttlUsage != nil {
+		ttlUsage = self.ttlUsage
+	}
+	return
 }
 
 // Called in case of automatic debits
@@ -78,6 +132,33 @@ func (self *SMGSession) debitLoop(debitInterval time.Duration) {
 	}
 }
 
+// Continues automatic debits of a session recovered after restart, debiting first the time the engine was down
+func (self *SMGSession) resumeDebitLoop(debitInterval time.Duration) {
+	if !self.lastDebitTime.IsZero() {
+		missedDur := time.Since(self.lastDebitTime) - debitInterval
+		if missedDur < 0 { // Last debit still covers the session, wait for it to be consumed
+			select {
+			case <-self.stopDebit:
+				return
+			case <-time.After(-missedDur):
+			}
+		} else if missedDur > 0 {
+			if maxDebit, err := self.debit(missedDur, nil); err != nil || maxDebit < missedDur {
+				disconnectReason := INSUFFICIENT_FUNDS
+				if err != nil {
+					utils.Logger.Err(fmt.Sprintf("<SMGeneric> Could not complete debit opperation on session: %s, error: %s", self.eventStart.GetUUID(), err.Error()))
+					disconnectReason = SYSTEM_ERROR
+				}
+				if err := self.disconnectSession(disconnectReason); err != nil {
+					utils.Logger.Err(fmt.Sprintf("<SMGeneric> Could not disconnect session: %s, error: %s", self.eventStart.GetUUID(), err.Error()))
+				}
+				return
+			}
+		}
+	}
+	self.debitLoop(debitInterval)
+}
+
 // Attempts to debit a duration, returns maximum duration which can be debitted or error
 func (self *SMGSession) debit(dur time.Duration, lastUsed *time.Duration) (time.Duration, error) {
 	requestedDuration := dur
@@ -101,6 +182,8 @@ func (self *SMGSession) debit(dur time.Duration, lastUsed *time.Duration) (time.
 		self.totalUsage += self.lastUsage
 		ccDuration := self.extraDuration // fake ccDuration
 		self.extraDuration -= dur
+		self.lastDebitTime = time.Now()
+		self.checkpoint()
 		return ccDuration, nil
 	}
 	//utils.Logger.Debug(fmt.Sprintf("dur: %f", dur.Seconds()))
@@ -138,6 +221,8 @@ func (self *SMGSession) debit(dur time.Duration, lastUsed *time.Duration) (time.
 	self.callCosts = append(self.callCosts, cc)
 	self.lastDebit = initialExtraDuration + ccDuration
 	self.totalUsage += self.lastUsage
+	self.lastDebitTime = time.Now()
+	self.checkpoint()
 	//utils.Logger.Debug(fmt.Sprintf("TotalUsage: %f", self.totalUsage.Seconds()))
 
 	if ccDuration >= dur { // we got what we asked to be debited
@@ -226,16 +311,15 @@ func (self *SMGSession) close(endTime time.Time) error {
 
 // Send disconnect order to remote connection
 func (self *SMGSession) disconnectSession(reason string) error {
-	type AttrDisconnectSession struct {
-		EventStart map[string]interface{}
-		Reason     string
+	if self.extconns == nil {
+		return ErrConnectionNotFound
 	}
-	conn := self.extconns.GetConnection(self.connId)
+	conn := self.extconns.GetConnection(self.getConnId())
 	if conn == nil {
 		return ErrConnectionNotFound
 	}
 	var reply string
-	if err := conn.Call("SMGClientV1.DisconnectSession", AttrDisconnectSession{EventStart: self.eventStart, Reason: reason}, &reply); err != nil {
+	if err := conn.Call("SMGClientV1.DisconnectSession", utils.AttrDisconnectSession{EventStart: self.eventStart, Reason: reason}, &reply); err != nil {
 		return err
 	} else if reply != utils.OK {
 		return errors.New(fmt.Sprintf("Unexpected disconnect reply: %s", reply))
@@ -283,6 +367,76 @@ func (self *SMGSession) saveOperations(originID string) error {
 	return nil
 }
 
+// Queues the session state for dataDB so it can be recovered after restart and mirrors it on the replication peers.
+// Only the CallCosts debited since the previous checkpoint are copied and appended to the stored ones.
+func (self *SMGSession) checkpoint() {
+	if self.checkpointer == nil && self.replicator == nil {
+		return
+	}
+	smgCp := self.asCheckpoint()
+	if self.checkpointer != nil {
+		self.checkpointer.checkpoint(smgCp, smgCp.CallCosts[self.storedCCs:])
+		self.storedCCs = len(smgCp.CallCosts)
+	}
+	if self.replicator != nil {
+		self.replicator.replicate("SMGenericV1.SetPassiveSession", smgCp)
+	}
+}
+
+// Removes the state saved for the session indexed on sessionID
+func (self *SMGSession) removeCheckpoint(sessionID string) {
+	if self.checkpointer != nil {
+		self.checkpointer.remove(utils.ConcatenatedKey(sessionID, self.runId))
+		self.storedCCs = 0 // a new checkpoint, eg: after relocation, stores all CallCosts again
+	}
+	if self.replicator != nil {
+		self.replicator.replicate("SMGenericV1.RemovePassiveSession", &engine.SMGSessionCheckpoint{SessionID: sessionID, RunID: self.runId})
+	}
+}
+
+// Snapshot of the session state, safe to be used after the session continues debiting
+func (self *SMGSession) asCheckpoint() *engine.SMGSessionCheckpoint {
+	eventStart := make(map[string]interface{}, len(self.eventStart))
+	for k, v := range self.eventStart {
+		eventStart[k] = v
+	}
+	// Deep copies since the session keeps debiting into them, the same exported fields are kept as when storing the checkpoint.
+	// The CallCosts copied by previous checkpoints are reused, they are not changed anymore until the session closes.
+	var cd *engine.CallDescriptor
+	if self.cd != nil {
+		cd = new(engine.CallDescriptor)
+		if err := utils.Clone(self.cd, cd); err != nil {
+			utils.Logger.Err(fmt.Sprintf("<SMGeneric> Could not copy CallDescriptor of session: %s, runId: %s, error: %s", self.eventStart.GetUUID(), self.runId, err.Error()))
+		}
+	}
+	for _, cc := range self.callCosts[len(self.cpCallCosts):] {
+		cpCC := new(engine.CallCost)
+		if err := utils.Clone(cc, cpCC); err != nil {
+			utils.Logger.Err(fmt.Sprintf("<SMGeneric> Could not copy CallCost of session: %s, runId: %s, error: %s", self.eventStart.GetUUID(), self.runId, err.Error()))
+		}
+		self.cpCallCosts = append(self.cpCallCosts, cpCC)
+	}
+	callCosts := self.cpCallCosts[:len(self.cpCallCosts):len(self.cpCallCosts)] // later appends do not show in this checkpoint
+	self.mux.RLock()
+	defer self.mux.RUnlock()
+	return &engine.SMGSessionCheckpoint{
+		SessionID:          self.eventStart.GetUUID(),
+		RunID:              self.runId,
+		ConnID:             self.connId,
+		EventStart:         eventStart,
+		CallDescriptor:     cd,
+		CallCosts:          callCosts,
+		ExtraDuration:      self.extraDuration,
+		LastUsage:          self.lastUsage,
+		LastDebit:          self.lastDebit,
+		TotalUsage:         self.totalUsage,
+		LastDebitTime:      self.lastDebitTime,
+		SessionTTL:         self.ttl,
+		SessionTTLLastUsed: self.ttlLastUsed,
+		SessionTTLUsage:    self.ttlUsage,
+	}
+}
+
 func (self *SMGSession) TotalUsage() time.Duration {
 	return self.totalUsage
 }
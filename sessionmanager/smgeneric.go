@@ -34,23 +34,36 @@ import (
 
 var ErrPartiallyExecuted = errors.New("Partially executed")
 
-func NewSMGeneric(cgrCfg *config.CGRConfig, rater rpcclient.RpcClientConnection, cdrsrv rpcclient.RpcClientConnection, timezone string, extconns *SMGExternalConnections) *SMGeneric {
-
-	gsm := &SMGeneric{cgrCfg: cgrCfg, rater: rater, cdrsrv: cdrsrv, extconns: extconns, timezone: timezone,
-		sessions: make(map[string][]*SMGSession), sessionTerminators: make(map[string]*smgSessionTerminator), sessionsMux: new(sync.RWMutex), guard: engine.Guardian}
+func NewSMGeneric(cgrCfg *config.CGRConfig, rater rpcclient.RpcClientConnection, cdrsrv rpcclient.RpcClientConnection, dataDB engine.AccountingStorage,
+	replConns rpcclient.RpcClientConnection, timezone string, extconns *SMGExternalConnections) *SMGeneric {
+
+	gsm := &SMGeneric{cgrCfg: cgrCfg, rater: rater, cdrsrv: cdrsrv, dataDB: dataDB, extconns: extconns, timezone: timezone,
+		sessions: make(map[string][]*SMGSession), passiveSessions: make(map[string][]*SMGSession), sessionTerminators: make(map[string]*smgSessionTerminator),
+		passiveSessionTimers: make(map[string]*smgPassiveSessionTimer), sessionsMux: new(sync.RWMutex), guard: engine.Guardian}
+	if dataDB != nil {
+		gsm.checkpointer = newSMGCheckpointer(dataDB)
+	}
+	if replConns != nil {
+		gsm.replicator = newSMGReplicator(replConns)
+	}
 	return gsm
 }
 
 type SMGeneric struct {
-	cgrCfg             *config.CGRConfig // Separate from smCfg since there can be multiple
-	rater              rpcclient.RpcClientConnection
-	cdrsrv             rpcclient.RpcClientConnection
-	timezone           string
-	sessions           map[string][]*SMGSession         //Group sessions per sessionId, multiple runs based on derived charging
-	sessionTerminators map[string]*smgSessionTerminator // terminate and cleanup the session if timer expires
-	extconns           *SMGExternalConnections          // Reference towards external connections manager
-	sessionsMux        *sync.RWMutex                    // Locks sessions map
-	guard              *engine.GuardianLock             // Used to lock on uuid
+	cgrCfg               *config.CGRConfig // Separate from smCfg since there can be multiple
+	rater                rpcclient.RpcClientConnection
+	cdrsrv               rpcclient.RpcClientConnection
+	dataDB               engine.AccountingStorage // Sessions are checkpointed here when not nil
+	checkpointer         *smgCheckpointer         // Writes the session checkpoints into dataDB
+	replicator           *smgReplicator           // Sessions are replicated as passive ones on the peers when not nil
+	timezone             string
+	sessions             map[string][]*SMGSession           //Group sessions per sessionId, multiple runs based on derived charging
+	passiveSessions      map[string][]*SMGSession           // Sessions replicated from peers, activated when we receive updates for them
+	passiveSessionTimers map[string]*smgPassiveSessionTimer // remove the passive sessions not refreshed by the peers
+	sessionTerminators   map[string]*smgSessionTerminator   // terminate and cleanup the session if timer expires
+	extconns             *SMGExternalConnections            // Reference towards external connections manager
+	sessionsMux          *sync.RWMutex                      // Locks sessions map
+	guard                *engine.GuardianLock               // Used to lock on uuid
 }
 type smgSessionTerminator struct {
 	timer       *time.Timer
@@ -58,13 +71,27 @@ type smgSessionTerminator struct {
 	ttl         time.Duration
 	ttlLastUsed *time.Duration
 	ttlUsage    *time.Duration
+	recovered   bool // armed only to end the sessions recovered but not active anymore, dropped on first update
+}
+
+type smgPassiveSessionTimer struct {
+	timer   *time.Timer
+	updated time.Time // last time the peer replicated the session
 }
 
 // Updates the timer for the session to a new ttl and terminate info
 func (self *SMGeneric) resetTerminatorTimer(uuid string, ttl time.Duration, ttlLastUsed, ttlUsage *time.Duration) {
-	self.sessionsMux.RLock()
-	defer self.sessionsMux.RUnlock()
+	self.sessionsMux.Lock()
+	defer self.sessionsMux.Unlock()
+	for _, s := range self.sessions[uuid] { // checkpointed with the session so they are restored after restart
+		s.setTTLSettings(ttl, ttlLastUsed, ttlUsage)
+	}
 	if st, found := self.sessionTerminators[uuid]; found {
+		if st.recovered { // Session is still active, no need to terminate it anymore
+			st.endChan <- true
+			delete(self.sessionTerminators, uuid)
+			return
+		}
 		if ttl != 0 {
 			st.ttl = ttl
 		}
@@ -84,8 +111,13 @@ func (self *SMGeneric) ttlTerminate(s *SMGSession, tmtr *smgSessionTerminator) {
 	if tmtr.ttlUsage != nil {
 		debitUsage = *tmtr.ttlUsage
 	}
-	for _, s := range self.getSession(s.eventStart.GetUUID()) {
-		s.debit(debitUsage, tmtr.ttlLastUsed)
+	if debitUsage != 0 || tmtr.ttlLastUsed != nil {
+		for _, s := range self.getSession(s.eventStart.GetUUID()) {
+			s.debit(debitUsage, tmtr.ttlLastUsed)
+		}
+	}
+	if err := s.disconnectSession(SESSION_TTL_EXPIRED); err != nil && err != ErrConnectionNotFound {
+		utils.Logger.Err(fmt.Sprintf("<SMGeneric> Could not disconnect session: %s, error: %s", s.eventStart.GetUUID(), err.Error()))
 	}
 	self.sessionEnd(s.eventStart.GetUUID(), s.TotalUsage())
 	cdr := s.eventStart.AsStoredCdr(self.cgrCfg, self.timezone)
@@ -98,35 +130,45 @@ func (self *SMGeneric) indexSession(uuid string, s *SMGSession) {
 	self.sessionsMux.Lock()
 	self.sessions[uuid] = append(self.sessions[uuid], s)
 	if self.cgrCfg.SmGenericConfig.SessionTTL != 0 {
-		if _, found := self.sessionTerminators[uuid]; !found {
-			ttl := self.cgrCfg.SmGenericConfig.SessionTTL
-			if ttlEv := s.eventStart.GetSessionTTL(); ttlEv != 0 {
-				ttl = ttlEv
-			}
-			timer := time.NewTimer(ttl)
-			endChan := make(chan bool, 1)
-			terminator := &smgSessionTerminator{
-				timer:       timer,
-				endChan:     endChan,
-				ttl:         ttl,
-				ttlLastUsed: s.eventStart.GetSessionTTLLastUsed(),
-				ttlUsage:    s.eventStart.GetSessionTTLUsage(),
-			}
-			self.sessionTerminators[uuid] = terminator
-			go func() {
-				select {
-				case <-timer.C:
-					self.ttlTerminate(s, terminator)
-				case <-endChan:
-					timer.Stop()
-				}
-			}()
-
-		}
+		self.armSessionTerminator(uuid, s, self.cgrCfg.SmGenericConfig.SessionTTL, false)
 	}
 	self.sessionsMux.Unlock()
 }
 
+// Starts the terminator of the session if not already there, the ttl settings of the session override dfltTTL.
+// Needs to be called under sessionsMux lock.
+func (self *SMGeneric) armSessionTerminator(uuid string, s *SMGSession, dfltTTL time.Duration, recovered bool) {
+	if _, found := self.sessionTerminators[uuid]; found {
+		return
+	}
+	ttl, ttlLastUsed, ttlUsage := s.ttlSettings()
+	if ttl == 0 {
+		ttl = dfltTTL
+	}
+	if recovered && ttlUsage == nil { // Recovered sessions were already debited for the time we were down
+		ttlUsage = new(time.Duration)
+	}
+	timer := time.NewTimer(ttl)
+	endChan := make(chan bool, 1)
+	terminator := &smgSessionTerminator{
+		timer:       timer,
+		endChan:     endChan,
+		ttl:         ttl,
+		ttlLastUsed: ttlLastUsed,
+		ttlUsage:    ttlUsage,
+		recovered:   recovered,
+	}
+	self.sessionTerminators[uuid] = terminator
+	go func() {
+		select {
+		case <-timer.C:
+			self.ttlTerminate(s, terminator)
+		case <-endChan:
+			timer.Stop()
+		}
+	}()
+}
+
 // Remove session from session list, removes all related in case of multiple runs, true if item was found
 func (self *SMGeneric) unindexSession(uuid string) bool {
 	self.sessionsMux.Lock()
@@ -149,6 +191,13 @@ func (self *SMGeneric) getSessions() map[string][]*SMGSession {
 	return self.sessions
 }
 
+// ActiveSessionsCount returns the number of sessions handled, derived runs are not counted separately
+func (self *SMGeneric) ActiveSessionsCount() int {
+	self.sessionsMux.RLock()
+	defer self.sessionsMux.RUnlock()
+	return len(self.sessions)
+}
+
 func (self *SMGeneric) getSessionIDsForPrefix(prefix string) []string {
 	self.sessionsMux.Lock()
 	defer self.sessionsMux.Unlock()
@@ -181,8 +230,9 @@ func (self *SMGeneric) sessionStart(evStart SMGenericEvent, connId string) error
 		stopDebitChan := make(chan struct{})
 		for _, sessionRun := range sessionRuns {
 			s := &SMGSession{eventStart: evStart, connId: connId, runId: sessionRun.DerivedCharger.RunID, timezone: self.timezone,
-				rater: self.rater, cdrsrv: self.cdrsrv, cd: sessionRun.CallDescriptor}
+				rater: self.rater, cdrsrv: self.cdrsrv, extconns: self.extconns, checkpointer: self.checkpointer, replicator: self.replicator, cd: sessionRun.CallDescriptor}
 			self.indexSession(sessionId, s)
+			s.checkpoint()
 			//utils.Logger.Info(fmt.Sprintf("<SMGeneric> Starting session: %s, runId: %s", sessionId, s.runId))
 			if self.cgrCfg.SmGenericConfig.DebitInterval != 0 {
 				s.stopDebit = stopDebitChan
@@ -225,6 +275,7 @@ func (self *SMGeneric) sessionEnd(sessionId string, usage time.Duration) error {
 			if err := s.saveOperations(sessionId); err != nil {
 				utils.Logger.Err(fmt.Sprintf("<SMGeneric> Could not save session: %s, runId: %s, error: %s", sessionId, s.runId, err.Error()))
 			}
+			s.removeCheckpoint(sessionId)
 		}
 		return nil, nil
 	}, time.Duration(2)*time.Second, sessionId)
@@ -246,8 +297,10 @@ func (self *SMGeneric) sessionRelocate(sessionID, initialID string) error {
 			return nil, utils.ErrNotFound
 		}
 		for i, s := range ss {
+			s.removeCheckpoint(initialID)
 			s.eventStart[utils.ACCID] = sessionID // Overwrite initialSessionID with new one
 			self.indexSession(sessionID, s)
+			s.checkpoint()
 			if i == 0 {
 				self.unindexSession(initialID)
 			}
@@ -302,6 +355,7 @@ func (self *SMGeneric) InitiateSession(gev SMGenericEvent, clnt *rpc2.Client) (t
 
 // Execute debits for usage/maxUsage
 func (self *SMGeneric) UpdateSession(gev SMGenericEvent, clnt *rpc2.Client) (time.Duration, error) {
+	self.promotePassiveSessions(gev)
 	if initialID, err := gev.GetFieldAsString(utils.InitialOriginID); err == nil {
 		err := self.sessionRelocate(gev.GetUUID(), initialID)
 		if err == utils.ErrNotFound { // Session was already relocated, create a new  session with this update
@@ -332,6 +386,11 @@ func (self *SMGeneric) UpdateSession(gev SMGenericEvent, clnt *rpc2.Client) (tim
 		utils.Logger.Err(fmt.Sprintf("<SMGeneric> SessionUpdate with no active sessions for event: <%s>", gev.GetUUID()))
 		return nilDuration, utils.ErrServerError
 	}
+	if connId := getClientConnId(clnt); connId != "" {
+		for _, s := range aSessions {
+			s.setConnId(connId) // Client might have reconnected, eg: sessions recovered after restart
+		}
+	}
 	for _, s := range aSessions {
 		if maxDur, err := s.debit(evMaxUsage, lastUsed); err != nil {
 			return nilDuration, err
@@ -344,6 +403,7 @@ func (self *SMGeneric) UpdateSession(gev SMGenericEvent, clnt *rpc2.Client) (tim
 
 // Called on session end, should stop debit loop
 func (self *SMGeneric) TerminateSession(gev SMGenericEvent, clnt *rpc2.Client) error {
+	self.promotePassiveSessions(gev)
 	if initialID, err := gev.GetFieldAsString(utils.InitialOriginID); err == nil {
 		err := self.sessionRelocate(gev.GetUUID(), initialID)
 		if err == utils.ErrNotFound { // Session was already relocated, create a new  session with this update
@@ -498,9 +558,188 @@ func (self *SMGeneric) ProcessCDR(gev SMGenericEvent) error {
 }
 
 func (self *SMGeneric) Connect() error {
+	if self.dataDB == nil {
+		return nil
+	}
+	return self.recoverSessions()
+}
+
+// Restores the sessions checkpointed before restart, resuming their debits and terminators.
+// Sessions still active on the switches will be bound to their new connections on the next update.
+// The others expire via session_ttl or, if not configured, via recovered_session_ttl.
+func (self *SMGeneric) recoverSessions() error {
+	smgCps, err := self.dataDB.GetSMGSessionCheckpoints()
+	if err != nil {
+		return err
+	}
+	stopDebitChans := make(map[string]chan struct{}) // Shared by all runs of a session
+	for _, smgCp := range smgCps {
+		if smgCp.CallDescriptor == nil {
+			utils.Logger.Err(fmt.Sprintf("<SMGeneric> Cannot recover session: %s, runId: %s, missing CallDescriptor", smgCp.SessionID, smgCp.RunID))
+			self.dataDB.RemoveSMGSessionCheckpoint(smgCp.GetId())
+			continue
+		}
+		s := self.sessionFromCheckpoint(smgCp)
+		s.checkpointer = self.checkpointer
+		s.storedCCs = len(smgCp.CallCosts) // already in dataDB
+		s.replicator = self.replicator
+		self.indexSession(smgCp.SessionID, s)
+		if self.cgrCfg.SmGenericConfig.SessionTTL == 0 && self.cgrCfg.SmGenericConfig.RecoveredSessionTTL != 0 {
+			self.sessionsMux.Lock()
+			self.armSessionTerminator(smgCp.SessionID, s, self.cgrCfg.SmGenericConfig.RecoveredSessionTTL, true)
+			self.sessionsMux.Unlock()
+		}
+		utils.Logger.Info(fmt.Sprintf("<SMGeneric> Recovered session: %s, runId: %s", smgCp.SessionID, smgCp.RunID))
+		if self.cgrCfg.SmGenericConfig.DebitInterval != 0 {
+			if _, hasIt := stopDebitChans[smgCp.SessionID]; !hasIt {
+				stopDebitChans[smgCp.SessionID] = make(chan struct{})
+			}
+			s.stopDebit = stopDebitChans[smgCp.SessionID]
+			go s.resumeDebitLoop(self.cgrCfg.SmGenericConfig.DebitInterval)
+		}
+	}
+	return nil
+}
+
+// Builds the session out of its checkpoint, without checkpointing and replication so the caller decides on those
+func (self *SMGeneric) sessionFromCheckpoint(smgCp *engine.SMGSessionCheckpoint) *SMGSession {
+	return &SMGSession{eventStart: SMGenericEvent(smgCp.EventStart), connId: smgCp.ConnID, runId: smgCp.RunID, timezone: self.timezone,
+		rater: self.rater, cdrsrv: self.cdrsrv, extconns: self.extconns, cd: smgCp.CallDescriptor,
+		callCosts: smgCp.CallCosts, extraDuration: smgCp.ExtraDuration, lastUsage: smgCp.LastUsage, lastDebit: smgCp.LastDebit,
+		totalUsage: smgCp.TotalUsage, lastDebitTime: smgCp.LastDebitTime,
+		ttl: smgCp.SessionTTL, ttlLastUsed: smgCp.SessionTTLLastUsed, ttlUsage: smgCp.SessionTTLUsage}
+}
+
+// Stores the session run replicated by a peer, replacing the previous state of the same run
+func (self *SMGeneric) SetPassiveSession(smgCp *engine.SMGSessionCheckpoint) error {
+	if smgCp.SessionID == "" || smgCp.RunID == "" || smgCp.CallDescriptor == nil {
+		return utils.ErrMandatoryIeMissing
+	}
+	s := self.sessionFromCheckpoint(smgCp)
+	self.sessionsMux.Lock()
+	defer self.sessionsMux.Unlock()
+	self.refreshPassiveSession(smgCp.SessionID)
+	for i, pS := range self.passiveSessions[smgCp.SessionID] {
+		if pS.runId == smgCp.RunID {
+			self.passiveSessions[smgCp.SessionID][i] = s
+			return nil
+		}
+	}
+	self.passiveSessions[smgCp.SessionID] = append(self.passiveSessions[smgCp.SessionID], s)
 	return nil
 }
 
+// Removes the session run replicated by a peer, once the peer has ended or relocated it
+func (self *SMGeneric) RemovePassiveSession(sessionID, runID string) error {
+	self.sessionsMux.Lock()
+	defer self.sessionsMux.Unlock()
+	ss, found := self.passiveSessions[sessionID]
+	if !found {
+		return utils.ErrNotFound
+	}
+	for i, s := range ss {
+		if s.runId == runID {
+			ss = append(ss[:i], ss[i+1:]...)
+			break
+		}
+	}
+	if len(ss) == 0 {
+		self.deletePassiveSession(sessionID)
+	} else {
+		self.passiveSessions[sessionID] = ss
+	}
+	return nil
+}
+
+// The ttl of passive sessions: the peer replicates them at least once per session_ttl, otherwise they cannot last more than max_call_duration
+func (self *SMGeneric) passiveSessionTTL() time.Duration {
+	if self.cgrCfg.SmGenericConfig.SessionTTL != 0 {
+		return self.cgrCfg.SmGenericConfig.SessionTTL
+	}
+	return self.cgrCfg.SmGenericConfig.MaxCallDuration
+}
+
+// Postpones the expiry of the passive session, the peer might fail or restart without removing it. Needs to be called under sessionsMux lock.
+func (self *SMGeneric) refreshPassiveSession(sessionID string) {
+	if pTimer, hasIt := self.passiveSessionTimers[sessionID]; hasIt {
+		pTimer.updated = time.Now() // expirePassiveSession will re-arm the timer
+		return
+	}
+	self.passiveSessionTimers[sessionID] = &smgPassiveSessionTimer{updated: time.Now(),
+		timer: time.AfterFunc(self.passiveSessionTTL(), func() { self.expirePassiveSession(sessionID) })}
+}
+
+func (self *SMGeneric) expirePassiveSession(sessionID string) {
+	self.sessionsMux.Lock()
+	defer self.sessionsMux.Unlock()
+	pTimer, hasIt := self.passiveSessionTimers[sessionID]
+	if !hasIt { // Removed or promoted meanwhile
+		return
+	}
+	if remaining := self.passiveSessionTTL() - time.Since(pTimer.updated); remaining > 0 {
+		pTimer.timer.Reset(remaining)
+		return
+	}
+	utils.Logger.Warning(fmt.Sprintf("<SMGeneric> Passive session: %s not replicated within ttl, removing it", sessionID))
+	self.deletePassiveSession(sessionID)
+}
+
+// Needs to be called under sessionsMux lock
+func (self *SMGeneric) deletePassiveSession(sessionID string) {
+	delete(self.passiveSessions, sessionID)
+	if pTimer, hasIt := self.passiveSessionTimers[sessionID]; hasIt {
+		pTimer.timer.Stop()
+		delete(self.passiveSessionTimers, sessionID)
+	}
+}
+
+// PassiveSessionsCount returns the number of sessions replicated from peers, derived runs are not counted separately
+func (self *SMGeneric) PassiveSessionsCount() int {
+	self.sessionsMux.RLock()
+	defer self.sessionsMux.RUnlock()
+	return len(self.passiveSessions)
+}
+
+// Activates the passive sessions targeted by gev, taking over their debits from the peer which was handling them
+func (self *SMGeneric) promotePassiveSessions(gev SMGenericEvent) {
+	sessionIDs := []string{gev.GetUUID()}
+	if initialID, err := gev.GetFieldAsString(utils.InitialOriginID); err == nil {
+		sessionIDs = append(sessionIDs, initialID)
+	}
+	self.sessionsMux.Lock()
+	if sessionIDPrefix, err := gev.GetFieldAsString(utils.OriginIDPrefix); err == nil {
+		for sessionID := range self.passiveSessions {
+			if strings.HasPrefix(sessionID, sessionIDPrefix) {
+				sessionIDs = append(sessionIDs, sessionID)
+			}
+		}
+	}
+	promoted := make(map[string][]*SMGSession)
+	for _, sessionID := range sessionIDs {
+		if ss, found := self.passiveSessions[sessionID]; found {
+			self.deletePassiveSession(sessionID)
+			if _, active := self.sessions[sessionID]; !active {
+				promoted[sessionID] = ss
+			}
+		}
+	}
+	self.sessionsMux.Unlock()
+	for sessionID, ss := range promoted {
+		stopDebitChan := make(chan struct{})
+		for _, s := range ss {
+			s.checkpointer = self.checkpointer
+			s.replicator = self.replicator
+			self.indexSession(sessionID, s)
+			s.checkpoint()
+			if self.cgrCfg.SmGenericConfig.DebitInterval != 0 {
+				s.stopDebit = stopDebitChan
+				go s.resumeDebitLoop(self.cgrCfg.SmGenericConfig.DebitInterval)
+			}
+		}
+		utils.Logger.Info(fmt.Sprintf("<SMGeneric> Activated passive session: %s", sessionID))
+	}
+}
+
 // Used by APIer to retrieve sessions
 func (self *SMGeneric) Sessions() map[string][]*SMGSession {
 	return self.getSessions()
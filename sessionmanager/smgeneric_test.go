@@ -0,0 +1,330 @@
+/*
+Rating system designed to be used in VoIP Carriers World
+Copyright (C) 2012-2015 ITsysCOM
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>
+*/
+package sessionmanager
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cenkalti/rpc2"
+	"github.com/cgrates/cgrates/config"
+	"github.com/cgrates/cgrates/engine"
+	"github.com/cgrates/cgrates/utils"
+)
+
+// testBiRPCClient returns a BiRPC client as indexed by SMGExternalConnections.OnClientConnect
+func testBiRPCClient(connID string) *rpc2.Client {
+	clnt := &rpc2.Client{State: rpc2.NewState()}
+	clnt.State.Set(CGR_CONNUUID, connID)
+	return clnt
+}
+
+func TestSMGRecoverSessions(t *testing.T) {
+	cgrCfg, _ := config.NewDefaultCGRConfig()
+	dataDB, _ := engine.NewMapStorage()
+	checkpointer := newSMGCheckpointer(dataDB)
+	smgEv := SMGenericEvent{utils.EVENT_NAME: "TEST_EVENT", utils.ACCID: "12345", utils.DIRECTION: utils.OUT,
+		utils.ACCOUNT: "1001", utils.DESTINATION: "1002", utils.ANSWER_TIME: "2016-07-01T10:00:00Z"}
+	for _, runID := range []string{utils.META_DEFAULT, "run2"} {
+		s := &SMGSession{eventStart: smgEv, connId: "conn1", runId: runID, checkpointer: checkpointer,
+			cd:         &engine.CallDescriptor{Direction: utils.OUT, Tenant: "cgrates.org", Category: "call", Subject: "1001", Destination: "1002"},
+			totalUsage: time.Duration(30) * time.Second}
+		s.checkpoint()
+	}
+	checkpointer.flush()
+	smg := NewSMGeneric(cgrCfg, new(MockRpcClient), new(MockRpcClient), dataDB, nil, "UTC", NewSMGExternalConnections())
+	if err := smg.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	ss := smg.getSession("12345")
+	if len(ss) != 2 {
+		t.Fatalf("Recovered sessions: %+v", ss)
+	}
+	for _, s := range ss {
+		if s.connId != "conn1" || s.TotalUsage() != time.Duration(30)*time.Second || s.eventStart.GetAccount(utils.META_DEFAULT) != "1001" {
+			t.Errorf("Recovered session: %+v", s)
+		}
+	}
+	// Connection in the event is not trusted
+	updEv := SMGenericEvent{utils.EVENT_NAME: "TEST_EVENT", utils.ACCID: "12345", CGR_CONNUUID: "conn2", utils.USAGE: "0"}
+	if _, err := smg.UpdateSession(updEv, nil); err != nil {
+		t.Error(err)
+	}
+	for _, s := range smg.getSession("12345") {
+		if s.connId != "conn1" {
+			t.Errorf("Session connection: %s", s.connId)
+		}
+	}
+	// Switch reconnected, session bound to the new connection on update
+	if _, err := smg.UpdateSession(updEv, testBiRPCClient("conn2")); err != nil {
+		t.Error(err)
+	}
+	for _, s := range smg.getSession("12345") {
+		if s.connId != "conn2" {
+			t.Errorf("Session connection: %s", s.connId)
+		}
+	}
+	if err := smg.sessionEnd("12345", time.Duration(30)*time.Second); err != nil {
+		t.Error(err)
+	}
+	smg.checkpointer.flush()
+	if smgCps, err := dataDB.GetSMGSessionCheckpoints(); err != nil {
+		t.Error(err)
+	} else if len(smgCps) != 0 {
+		t.Errorf("Checkpoints not removed: %+v", smgCps)
+	}
+}
+
+func TestSMGRecoverSessionsTerminator(t *testing.T) {
+	cgrCfg, _ := config.NewDefaultCGRConfig()
+	cgrCfg.SmGenericConfig.RecoveredSessionTTL = time.Duration(50) * time.Millisecond
+	dataDB, _ := engine.NewMapStorage()
+	checkpointer := newSMGCheckpointer(dataDB)
+	sessionTTL := time.Duration(20) * time.Millisecond
+	for _, originID := range []string{"12345", "67890"} {
+		smgEv := SMGenericEvent{utils.EVENT_NAME: "TEST_EVENT", utils.ACCID: originID, utils.DIRECTION: utils.OUT,
+			utils.ACCOUNT: "1001", utils.DESTINATION: "1002", utils.ANSWER_TIME: "2016-07-01T10:00:00Z"}
+		s := &SMGSession{eventStart: smgEv, connId: "conn1", runId: utils.META_DEFAULT, checkpointer: checkpointer,
+			cd: &engine.CallDescriptor{Direction: utils.OUT, Tenant: "cgrates.org", Category: "call", Subject: "1001", Destination: "1002"}}
+		if originID == "67890" { // TTL received with an update before restart
+			s.setTTLSettings(sessionTTL, nil, nil)
+		}
+		s.checkpoint()
+	}
+	checkpointer.flush()
+	smg := NewSMGeneric(cgrCfg, new(MockRpcClient), new(MockRpcClient), dataDB, nil, "UTC", NewSMGExternalConnections())
+	if err := smg.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	smg.sessionsMux.RLock()
+	if st, found := smg.sessionTerminators["67890"]; !found || st.ttl != sessionTTL {
+		t.Errorf("Terminator: %+v", st)
+	}
+	if st, found := smg.sessionTerminators["12345"]; !found || st.ttl != cgrCfg.SmGenericConfig.RecoveredSessionTTL {
+		t.Errorf("Terminator: %+v", st)
+	}
+	smg.sessionsMux.RUnlock()
+	// Session still active on the switch
+	if _, err := smg.UpdateSession(SMGenericEvent{utils.EVENT_NAME: "TEST_EVENT", utils.ACCID: "12345", utils.USAGE: "0"}, testBiRPCClient("conn2")); err != nil {
+		t.Error(err)
+	}
+	time.Sleep(time.Duration(100) * time.Millisecond)
+	if ss := smg.getSession("67890"); len(ss) != 0 {
+		t.Errorf("Stale session not terminated: %+v", ss)
+	}
+	if ss := smg.getSession("12345"); len(ss) != 1 {
+		t.Errorf("Active session terminated: %+v", ss)
+	}
+}
+
+func TestSMGPassiveSessions(t *testing.T) {
+	cgrCfg, _ := config.NewDefaultCGRConfig()
+	smg := NewSMGeneric(cgrCfg, new(MockRpcClient), new(MockRpcClient), nil, nil, "UTC", NewSMGExternalConnections())
+	smgEv := map[string]interface{}{utils.EVENT_NAME: "TEST_EVENT", utils.ACCID: "12345", utils.DIRECTION: utils.OUT,
+		utils.ACCOUNT: "1001", utils.DESTINATION: "1002", utils.ANSWER_TIME: "2016-07-01T10:00:00Z"}
+	for _, runID := range []string{utils.META_DEFAULT, "run2"} {
+		if err := smg.SetPassiveSession(&engine.SMGSessionCheckpoint{SessionID: "12345", RunID: runID, ConnID: "conn1", EventStart: smgEv,
+			CallDescriptor: &engine.CallDescriptor{Direction: utils.OUT, Tenant: "cgrates.org", Category: "call", Subject: "1001", Destination: "1002"},
+			LastUsage:      time.Duration(10) * time.Second, TotalUsage: time.Duration(20) * time.Second}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Newer state of the same run replaces the old one
+	if err := smg.SetPassiveSession(&engine.SMGSessionCheckpoint{SessionID: "12345", RunID: "run2", ConnID: "conn1", EventStart: smgEv,
+		CallDescriptor: &engine.CallDescriptor{Direction: utils.OUT, Tenant: "cgrates.org", Category: "call", Subject: "1001", Destination: "1002"},
+		LastUsage:      time.Duration(10) * time.Second, TotalUsage: time.Duration(30) * time.Second}); err != nil {
+		t.Fatal(err)
+	}
+	if err := smg.SetPassiveSession(&engine.SMGSessionCheckpoint{SessionID: "12345"}); err != utils.ErrMandatoryIeMissing {
+		t.Error(err)
+	}
+	if smg.PassiveSessionsCount() != 1 || smg.ActiveSessionsCount() != 0 {
+		t.Errorf("Passive sessions: %d, active sessions: %d", smg.PassiveSessionsCount(), smg.ActiveSessionsCount())
+	}
+	// Peer failed, the switch sends us the update for the session
+	updEv := SMGenericEvent{utils.EVENT_NAME: "TEST_EVENT", utils.ACCID: "12345", utils.USAGE: "0"}
+	if _, err := smg.UpdateSession(updEv, testBiRPCClient("conn2")); err != nil {
+		t.Error(err)
+	}
+	if smg.PassiveSessionsCount() != 0 || smg.ActiveSessionsCount() != 1 {
+		t.Errorf("Passive sessions: %d, active sessions: %d", smg.PassiveSessionsCount(), smg.ActiveSessionsCount())
+	}
+	eTotalUsage := map[string]time.Duration{utils.META_DEFAULT: time.Duration(20) * time.Second, "run2": time.Duration(30) * time.Second}
+	ss := smg.getSession("12345")
+	if len(ss) != 2 {
+		t.Fatalf("Active sessions: %+v", ss)
+	}
+	for _, s := range ss {
+		if s.connId != "conn2" || s.TotalUsage() != eTotalUsage[s.runId] {
+			t.Errorf("Promoted session: %+v", s)
+		}
+	}
+	if err := smg.RemovePassiveSession("12345", "run2"); err != utils.ErrNotFound {
+		t.Error(err)
+	}
+}
+
+func TestSMGPassiveSessionsExpire(t *testing.T) {
+	cgrCfg, _ := config.NewDefaultCGRConfig()
+	cgrCfg.SmGenericConfig.SessionTTL = time.Duration(50) * time.Millisecond
+	smg := NewSMGeneric(cgrCfg, new(MockRpcClient), new(MockRpcClient), nil, nil, "UTC", NewSMGExternalConnections())
+	smgCp := &engine.SMGSessionCheckpoint{SessionID: "12345", RunID: utils.META_DEFAULT, ConnID: "conn1",
+		EventStart:     map[string]interface{}{utils.EVENT_NAME: "TEST_EVENT", utils.ACCID: "12345"},
+		CallDescriptor: &engine.CallDescriptor{Direction: utils.OUT, Tenant: "cgrates.org", Category: "call", Subject: "1001", Destination: "1002"}}
+	if err := smg.SetPassiveSession(smgCp); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(time.Duration(30) * time.Millisecond)
+	if err := smg.SetPassiveSession(smgCp); err != nil { // Refreshed by the peer
+		t.Fatal(err)
+	}
+	time.Sleep(time.Duration(40) * time.Millisecond)
+	if smg.PassiveSessionsCount() != 1 {
+		t.Error("Passive session expired before its ttl")
+	}
+	time.Sleep(time.Duration(60) * time.Millisecond)
+	if smg.PassiveSessionsCount() != 0 {
+		t.Error("Passive session not expired")
+	}
+}
+
+// smgReplicationRecorder records the replication calls received by a peer
+type smgReplicationRecorder struct {
+	sync.Mutex
+	calls []string
+}
+
+func (rr *smgReplicationRecorder) Call(method string, args interface{}, reply interface{}) error {
+	time.Sleep(time.Millisecond) // Slow peer, later replications should still arrive after
+	smgCp := args.(engine.SMGSessionCheckpoint)
+	rr.Lock()
+	rr.calls = append(rr.calls, method+":"+smgCp.SessionID)
+	rr.Unlock()
+	return nil
+}
+
+func TestSMGReplicatorOrder(t *testing.T) {
+	rr := new(smgReplicationRecorder)
+	r := newSMGReplicator(rr)
+	var eCalls []string
+	for _, sessionID := range []string{"1", "2", "3", "4", "5"} {
+		r.replicate("SMGenericV1.SetPassiveSession", &engine.SMGSessionCheckpoint{SessionID: sessionID})
+		r.replicate("SMGenericV1.RemovePassiveSession", &engine.SMGSessionCheckpoint{SessionID: sessionID})
+		eCalls = append(eCalls, "SMGenericV1.SetPassiveSession:"+sessionID, "SMGenericV1.RemovePassiveSession:"+sessionID)
+	}
+	time.Sleep(time.Duration(50) * time.Millisecond)
+	rr.Lock()
+	defer rr.Unlock()
+	if !reflect.DeepEqual(eCalls, rr.calls) {
+		t.Errorf("Expecting: %+v, received: %+v", eCalls, rr.calls)
+	}
+}
+
+// smgBlockingPeer holds the replication calls until released
+type smgBlockingPeer struct {
+	called  chan struct{}
+	release chan struct{}
+}
+
+func (p *smgBlockingPeer) Call(method string, args interface{}, reply interface{}) error {
+	select {
+	case p.called <- struct{}{}:
+	default:
+	}
+	<-p.release
+	return nil
+}
+
+func TestSMGReplicatorQueueFull(t *testing.T) {
+	peer := &smgBlockingPeer{called: make(chan struct{}, 1), release: make(chan struct{})}
+	r := &smgReplicator{replConns: peer, queue: make(chan *smgReplication, 1), queueTimeout: time.Duration(10) * time.Millisecond}
+	go r.serve()
+	r.replicate("SMGenericV1.SetPassiveSession", &engine.SMGSessionCheckpoint{SessionID: "1"})
+	<-peer.called                                                                              // Peer holds the first one
+	r.replicate("SMGenericV1.SetPassiveSession", &engine.SMGSessionCheckpoint{SessionID: "2"}) // Queued
+	r.replicate("SMGenericV1.SetPassiveSession", &engine.SMGSessionCheckpoint{SessionID: "3"}) // Dropped after the timeout
+	if dropped := r.droppedReplications(); dropped != 1 {
+		t.Errorf("Dropped replications: %d", dropped)
+	}
+	close(peer.release)
+	r.replicate("SMGenericV1.SetPassiveSession", &engine.SMGSessionCheckpoint{SessionID: "4"}) // Room again once the peer answers
+	if dropped := r.droppedReplications(); dropped != 1 {
+		t.Errorf("Dropped replications: %d", dropped)
+	}
+}
+
+func TestSMGSessionAsCheckpointCopy(t *testing.T) {
+	cc := &engine.CallCost{Direction: utils.OUT, Destination: "1002", Cost: 1,
+		Timespans: engine.TimeSpans{&engine.TimeSpan{TimeStart: time.Date(2016, 7, 1, 10, 0, 0, 0, time.UTC),
+			TimeEnd: time.Date(2016, 7, 1, 10, 1, 0, 0, time.UTC), Cost: 1,
+			Increments: engine.Increments{&engine.Increment{Duration: time.Minute, Cost: 1}}}}}
+	s := &SMGSession{eventStart: SMGenericEvent{utils.ACCID: "12345"}, runId: utils.META_DEFAULT,
+		cd:        &engine.CallDescriptor{Direction: utils.OUT, Tenant: "cgrates.org", Category: "call", Subject: "1001", Destination: "1002", DurationIndex: time.Minute},
+		callCosts: []*engine.CallCost{cc}}
+	smgCp := s.asCheckpoint()
+	// Session goes on merging and debiting
+	cc.Cost = 2
+	cc.Timespans[0].Increments[0].Cost = 2
+	s.cd.DurationIndex = 2 * time.Minute
+	if smgCp.CallCosts[0].Cost != 1 || smgCp.CallCosts[0].Timespans[0].Increments[0].Cost != 1 {
+		t.Errorf("CallCost changed in checkpoint: %s", utils.ToJSON(smgCp.CallCosts))
+	}
+	if smgCp.CallDescriptor.DurationIndex != time.Minute || smgCp.CallDescriptor.Subject != "1001" {
+		t.Errorf("CallDescriptor changed in checkpoint: %s", utils.ToJSON(smgCp.CallDescriptor))
+	}
+}
+
+func TestSMGSessionCheckpointIncremental(t *testing.T) {
+	dataDB, _ := engine.NewMapStorage()
+	checkpointer := newSMGCheckpointer(dataDB)
+	s := &SMGSession{eventStart: SMGenericEvent{utils.ACCID: "12345"}, runId: utils.META_DEFAULT, checkpointer: checkpointer,
+		cd: &engine.CallDescriptor{Direction: utils.OUT, Tenant: "cgrates.org", Category: "call", Subject: "1001", Destination: "1002"}}
+	for i := 1; i <= 3; i++ { // one debit per checkpoint
+		s.callCosts = append(s.callCosts, &engine.CallCost{Direction: utils.OUT, Destination: "1002", Cost: float64(i)})
+		s.checkpoint()
+	}
+	firstCpCC := s.cpCallCosts[0]
+	s.checkpoint()
+	if s.cpCallCosts[0] != firstCpCC {
+		t.Error("CallCost copied again by the next checkpoint")
+	}
+	checkpointer.flush()
+	if smgCps, err := dataDB.GetSMGSessionCheckpoints(); err != nil {
+		t.Fatal(err)
+	} else if len(smgCps) != 1 || len(smgCps[0].CallCosts) != 3 {
+		t.Fatalf("Checkpoints: %s", utils.ToJSON(smgCps))
+	} else {
+		for i, cc := range smgCps[0].CallCosts {
+			if cc.Cost != float64(i+1) {
+				t.Errorf("CallCost %d: %s", i, utils.ToJSON(cc))
+			}
+		}
+	}
+	// Relocated, all CallCosts are stored under the new ID
+	s.removeCheckpoint("12345")
+	s.eventStart[utils.ACCID] = "67890"
+	s.checkpoint()
+	checkpointer.flush()
+	if smgCps, err := dataDB.GetSMGSessionCheckpoints(); err != nil {
+		t.Fatal(err)
+	} else if len(smgCps) != 1 || smgCps[0].SessionID != "67890" || len(smgCps[0].CallCosts) != 3 {
+		t.Errorf("Checkpoints: %s", utils.ToJSON(smgCps))
+	}
+}
@@ -168,6 +168,8 @@ type TPRatingPlanBinding struct {
 	DestinationRatesId string    // The DestinationRate identity
 	TimingId           string    // The timing identity
 	Weight             float64   // Binding priority taken into consideration when more DestinationRates are active on a time slot
+	VolumeCounter      string    // ID of the account counter holding the usage the rate slots of this binding are tiered on
+	Currency           string    // Currency of the rates, empty for the default one
 	timing             *TPTiming // Not exporting it via JSON
 }
 
@@ -291,6 +293,7 @@ type TPAction struct {
 	BalanceBlocker  string
 	BalanceDisabled string
 	Weight          float64 // Action's weight
+	BalanceCurrency string  // Currency of the monetary balance
 }
 
 type TPSharedGroups struct {
@@ -305,6 +308,34 @@ type TPSharedGroup struct {
 	RatingSubject string
 }
 
+type TPExchangeRates struct {
+	TPid            string
+	ExchangeRatesId string
+	ExchangeRates   []*TPExchangeRate
+}
+
+type TPExchangeRate struct {
+	FromCurrency string
+	ToCurrency   string
+	Rate         float64 // units of ToCurrency for one unit of FromCurrency
+}
+
+type TPTaxRules struct {
+	TPid       string
+	TaxRulesId string
+	TaxRules   []*TPTaxRule
+}
+
+type TPTaxRule struct {
+	Tenant         string
+	DestinationIds string  // Destinations the tax applies to, *any or empty for all
+	TaxName        string  // Name of the tax, eg: VAT
+	Percent        float64 // Percentage out of the CDR cost
+	PerMinute      float64 // Fixed amount for each minute of usage
+	Inclusive      bool    // The tax is already included in the rated cost
+	Weight         float64 // Higher weight wins between rules with the same TaxName
+}
+
 type TPLcrRules struct {
 	TPid      string
 	Direction string
@@ -366,6 +397,7 @@ type TPCdrStats struct {
 type TPCdrStat struct {
 	QueueLength      string
 	TimeWindow       string
+	BucketInterval   string
 	SaveInterval     string
 	Metrics          string
 	SetupInterval    string
@@ -571,6 +603,9 @@ type AttrReloadCache struct {
 	DerivedChargers  []string
 	LcrProfiles      []string
 	Aliases          []string
+	ExchangeRateIds  []string
+	TaxRuleIds       []string
+	ResourceLimitIds []string
 }
 
 type AttrCacheStats struct { // Add in the future filters here maybe so we avoid counting complete cache
@@ -1125,6 +1160,7 @@ type AttrSetAccount struct {
 	ActionPlanId     string
 	ActionTriggersId string
 	AllowNegative    *bool
+	CreditLimit      *float64
 	Disabled         *bool
 	ReloadScheduler  bool
 }
@@ -1181,6 +1217,16 @@ type AttrRateCDRs struct {
 	ReplicateCDRs *bool // Replicate results
 }
 
+type AttrReplayFailedReplications struct {
+	Transport string // Only replay replications over this transport, empty for all
+	Address   string // Only replay replications towards this address, empty for all
+}
+
+type ReplayedReplications struct {
+	Replayed int // Delivered and removed out of spool
+	Failed   int // Still waiting in spool
+}
+
 type AttrSetBalance struct {
 	Tenant         string
 	Account        string
@@ -1198,6 +1244,7 @@ type AttrSetBalance struct {
 	SharedGroups   *string
 	Blocker        *bool
 	Disabled       *bool
+	Currency       *string
 }
 
 type TPResourceLimit struct {
@@ -1215,3 +1262,16 @@ type TPRequestFilter struct {
 	FieldName string   // Name of the field providing us the Values to check (used in case of some )
 	Values    []string // Filter definition
 }
+
+// Used by ResourceLimiterService to account resource usage for one event
+type AttrRLsResourceUsage struct {
+	UsageID string                 // Unique identifier of the usage, eg: session ID
+	Event   map[string]interface{} // Event the ResourceLimits are matched against
+	Units   float64                // Number of units requested, defaults to 1
+}
+
+// Sent by SMGeneric towards the component controlling the session when it needs to disconnect it
+type AttrDisconnectSession struct {
+	EventStart map[string]interface{}
+	Reason     string
+}
@@ -32,8 +32,17 @@ var (
 	ErrUserNotFound            = errors.New("USER_NOT_FOUND")
 	ErrInsufficientCredit      = errors.New("INSUFFICIENT_CREDIT")
 	ErrNotConvertible          = errors.New("NOT_CONVERTIBLE")
+	ErrResourceUnavailable     = errors.New("RESOURCE_UNAVAILABLE")
+	ErrUnauthorizedApiKey      = errors.New("UNAUTHORIZED_API_KEY")
+	ErrUnauthorizedMethod      = errors.New("UNAUTHORIZED_METHOD")
+	ErrUnauthorizedTenant      = errors.New("UNAUTHORIZED_TENANT")
+	ErrReplyTimeout            = errors.New("REPLY_TIMEOUT")
+	ErrReservationExceeded     = errors.New("RESERVATION_EXCEEDED")
+	ErrExchangeRateNotFound    = errors.New("EXCHANGE_RATE_NOT_FOUND")
+	ErrReplicationDisabled     = errors.New("REPLICATION_DISABLED")
+	ErrReplicationSpooled      = errors.New("REPLICATION_SPOOLED")
 
-	CdreCdrFormats   = []string{CSV, DRYRUN, CDRE_FIXED_WIDTH}
+	CdreCdrFormats   = []string{CSV, DRYRUN, CDRE_FIXED_WIDTH, MetaJSONL, MetaXML}
 	PrimaryCdrFields = []string{CGRID, CDRSOURCE, CDRHOST, ACCID, TOR, REQTYPE, DIRECTION, TENANT, CATEGORY, ACCOUNT, SUBJECT, DESTINATION, SETUP_TIME, PDD, ANSWER_TIME, USAGE,
 		SUPPLIER, DISCONNECT_CAUSE, COST, RATED, PartialField}
 )
@@ -76,7 +85,11 @@ const (
 	TBL_TP_USERS                 = "tp_users"
 	TBL_TP_ALIASES               = "tp_aliases"
 	TBLSMCosts                   = "sm_costs"
+	TBLHistoryRecords            = "history_records"
+	TBLBalanceLedger             = "balance_ledger"
 	TBLTPResourceLimits          = "tp_resource_limits"
+	TBLTPExchangeRates           = "tp_exchange_rates"
+	TBLTPTaxRules                = "tp_tax_rules"
 	TBL_CDRS                     = "cdrs"
 	TIMINGS_CSV                  = "Timings.csv"
 	DESTINATIONS_CSV             = "Destinations.csv"
@@ -95,6 +108,8 @@ const (
 	USERS_CSV                    = "Users.csv"
 	ALIASES_CSV                  = "Aliases.csv"
 	ResourceLimitsCsv            = "ResourceLimits.csv"
+	ExchangeRatesCsv             = "ExchangeRates.csv"
+	TaxRulesCsv                  = "TaxRules.csv"
 	ROUNDING_UP                  = "*up"
 	ROUNDING_MIDDLE              = "*middle"
 	ROUNDING_DOWN                = "*down"
@@ -150,9 +165,13 @@ const (
 	CSV                          = "csv"
 	FWV                          = "fwv"
 	PartialCSV                   = "partial_csv"
+	PartialJSON                  = "partial_json"
 	DRYRUN                       = "dry_run"
 	META_COMBIMED                = "*combimed"
 	MetaInternal                 = "*internal"
+	MetaFile                     = "*file"
+	MetaGit                      = "*git"
+	MetaStorDB                   = "*stordb"
 	ZERO_RATING_SUBJECT_PREFIX   = "*zero"
 	OK                           = "OK"
 	CDRE_FIXED_WIDTH             = "fwv"
@@ -201,6 +220,11 @@ const (
 	USERS_PREFIX                 = "usr_"
 	ALIASES_PREFIX               = "als_"
 	ResourceLimitsPrefix         = "rl_"
+	ExchangeRatesPrefix          = "exr_"
+	TaxRulesPrefix               = "txr_"
+	SMG_SESSIONS_PREFIX          = "smg_"
+	SMG_SESSION_COSTS_PREFIX     = "scc_"
+	CdrExportJobsPrefix          = "cej_"
 	REVERSE_ALIASES_PREFIX       = "rls_"
 	CDR_STATS_PREFIX             = "cst_"
 	TEMP_DESTINATION_PREFIX      = "tmp_"
@@ -227,6 +251,8 @@ const (
 	META_HTTP_POST               = "*http_post"
 	META_HTTP_JSON               = "*http_json"
 	META_HTTP_JSONRPC            = "*http_jsonrpc"
+	MetaAMQP                     = "*amqp"
+	MetaKafka                    = "*kafka"
 	NANO_MULTIPLIER              = 1000000000
 	CGR_AUTHORIZE                = "CGR_AUTHORIZE"
 	CONFIG_DIR                   = "/etc/cgrates/"
@@ -260,8 +286,12 @@ const (
 	CALL                         = "call"
 	EXTRA_FIELDS                 = "ExtraFields"
 	META_SURETAX                 = "*sure_tax"
+	MetaTax                      = "*tax"
+	TaxNetCost                   = "NetCost"
+	TaxGrossCost                 = "GrossCost"
 	SURETAX                      = "suretax"
 	DIAMETER_AGENT               = "diameter_agent"
+	RADIUS_AGENT                 = "radius_agent"
 	COUNTER_EVENT                = "*event"
 	COUNTER_BALANCE              = "*balance"
 	EVENT_NAME                   = "EventName"
@@ -279,6 +309,9 @@ const (
 	TRIGGER_MIN_BALANCE         = "*min_balance"
 	TRIGGER_MAX_BALANCE         = "*max_balance"
 	TRIGGER_BALANCE_EXPIRED     = "*balance_expired"
+	TRIGGER_MIN_CREDIT          = "*min_credit"
+	TRIGGER_MIN_RESOURCE_USAGE  = "*min_resource_usage"
+	TRIGGER_MAX_RESOURCE_USAGE  = "*max_resource_usage"
 	HIERARCHY_SEP               = ">"
 	META_COMPOSED               = "*composed"
 	NegativePrefix              = "!"
@@ -291,6 +324,7 @@ const (
 	UpdatedAt                   = "UpdatedAt"
 	HandlerArgSep               = "|"
 	FlagForceDuration           = "fd"
+	FlagMSCC                    = "mscc"
 	InstanceID                  = "InstanceID"
 	SessionTTL                  = "SessionTTL"
 	SessionTTLLastUsed          = "SessionTTLLastUsed"
@@ -299,9 +333,22 @@ const (
 	XML                         = "xml"
 	MetaGOBrpc                  = "*gob"
 	MetaJSONrpc                 = "*json"
+	MetaBiJSONrpc               = "*bijson"
+	MetaUnknown                 = "*unknown"
+	MetaJSONL                   = "*jsonl"
+	MetaXML                     = "*xml"
 	MetaDateTime                = "*datetime"
 	MetaMaskedDestination       = "*masked_destination"
 	MetaUnixTimestamp           = "*unix_timestamp"
 	MetaPostCDR                 = "*post_cdr"
 	MetaDumpToFile              = "*dump_to_file"
+	MetaASR                     = "*asr"
+	MetaRAR                     = "*rar"
+	ApiKeysV1Authenticate       = "ApiKeysV1.Authenticate"
+	ApiKeyHeader                = "X-API-Key"
+	MetaLastHour                = "*last_hour"
+	MetaToday                   = "*today"
+	MetaYesterday               = "*yesterday"
+	MetaThisMonth               = "*this_month"
+	MetaLastMonth               = "*last_month"
 )
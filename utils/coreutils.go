@@ -552,6 +552,32 @@ func SizeFmt(num float64, suffix string) string {
 	return fmt.Sprintf("%.1f%s%s", num, "Yi", suffix)
 }
 
+// Returns the [start, end) interval of a relative time range, computed in the location of the reference time
+func RelativeTimeRange(timeRange string, ref time.Time) (start, end time.Time, err error) {
+	year, month, day := ref.Date()
+	switch timeRange {
+	case "": // No range
+	case MetaLastHour:
+		end = time.Date(year, month, day, ref.Hour(), 0, 0, 0, ref.Location())
+		start = end.Add(-time.Hour)
+	case MetaToday:
+		start = time.Date(year, month, day, 0, 0, 0, 0, ref.Location())
+		end = start.AddDate(0, 0, 1)
+	case MetaYesterday:
+		end = time.Date(year, month, day, 0, 0, 0, 0, ref.Location())
+		start = end.AddDate(0, 0, -1)
+	case MetaThisMonth:
+		start = time.Date(year, month, 1, 0, 0, 0, 0, ref.Location())
+		end = start.AddDate(0, 1, 0)
+	case MetaLastMonth:
+		end = time.Date(year, month, 1, 0, 0, 0, 0, ref.Location())
+		start = end.AddDate(0, -1, 0)
+	default:
+		err = fmt.Errorf("unsupported time range: %s", timeRange)
+	}
+	return
+}
+
 func TimeIs0h(t time.Time) bool {
 	return t.Hour() == 0 && t.Minute() == 0 && t.Second() == 0
 }
@@ -0,0 +1,411 @@
+/*
+Real-time Charging System for Telecom & ISP environments
+Copyright (C) ITsysCOM GmbH
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>
+*/
+package utils
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/rpc"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Metrics is the registry of the engine internal metrics, exposed in Prometheus text format on /metrics
+var Metrics = NewMetricsRegistry()
+
+// Buckets in seconds used by latency histograms
+var DefaultLatencyBuckets = []float64{0.0005, 0.001, 0.005, 0.01, 0.05, 0.1, 0.5, 1, 5}
+
+func NewMetricsRegistry() *MetricsRegistry {
+	return &MetricsRegistry{metrics: make(map[string]metricWriter)}
+}
+
+// MetricsRegistry holds the metrics in the order they were registered
+type MetricsRegistry struct {
+	mux     sync.RWMutex
+	metrics map[string]metricWriter
+	names   []string
+}
+
+type metricWriter interface {
+	writeMetric(w io.Writer)
+}
+
+// register returns the metric already registered with the name or the new one
+func (mr *MetricsRegistry) register(name string, m metricWriter) metricWriter {
+	mr.mux.Lock()
+	defer mr.mux.Unlock()
+	if existing, hasIt := mr.metrics[name]; hasIt {
+		return existing
+	}
+	mr.metrics[name] = m
+	mr.names = append(mr.names, name)
+	return m
+}
+
+// NewCounter registers a counter, returning the existing one if the name is already registered
+func (mr *MetricsRegistry) NewCounter(name, help string, labelNames ...string) *MetricCounter {
+	return mr.register(name, &MetricCounter{metricSeries: newMetricSeries(name, help, labelNames)}).(*MetricCounter)
+}
+
+// NewHistogram registers a histogram, returning the existing one if the name is already registered
+func (mr *MetricsRegistry) NewHistogram(name, help string, buckets []float64, labelNames ...string) *MetricHistogram {
+	return mr.register(name, &MetricHistogram{metricSeries: newMetricSeries(name, help, labelNames), buckets: buckets}).(*MetricHistogram)
+}
+
+// SetGaugeFunc registers a gauge whose value is queried out of f on each scrape, replacing previous f
+func (mr *MetricsRegistry) SetGaugeFunc(name, help string, f func() float64) {
+	gauge := mr.register(name, &metricGaugeFunc{name: name, help: help}).(*metricGaugeFunc)
+	gauge.mux.Lock()
+	gauge.f = f
+	gauge.mux.Unlock()
+}
+
+// WriteMetrics writes all the metrics in Prometheus text exposition format
+func (mr *MetricsRegistry) WriteMetrics(w io.Writer) {
+	mr.mux.RLock()
+	metrics := make([]metricWriter, len(mr.names))
+	for i, name := range mr.names {
+		metrics[i] = mr.metrics[name]
+	}
+	mr.mux.RUnlock()
+	for _, m := range metrics {
+		m.writeMetric(w)
+	}
+}
+
+// HTTPHandler serves the metrics, to be registered on /metrics
+func (mr *MetricsRegistry) HTTPHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	var buf bytes.Buffer
+	mr.WriteMetrics(&buf)
+	buf.WriteTo(w)
+}
+
+func newMetricSeries(name, help string, labelNames []string) metricSeries {
+	return metricSeries{name: name, help: help, labelNames: labelNames, labels: make(map[string]string)}
+}
+
+// metricSeries indexes the label values of one metric
+type metricSeries struct {
+	name       string
+	help       string
+	labelNames []string
+	labels     map[string]string // series key: formatted labels
+	mux        sync.RWMutex
+}
+
+// seriesKey builds the key of the series out of label values, caching the formatted labels; needs to be called under lock
+func (ms *metricSeries) seriesKey(labelValues []string) string {
+	key := strings.Join(labelValues, "\xff")
+	if _, hasIt := ms.labels[key]; !hasIt {
+		ms.labels[key] = formatMetricLabels(ms.labelNames, labelValues)
+	}
+	return key
+}
+
+func (ms *metricSeries) sortedKeys() []string {
+	keys := make([]string, 0, len(ms.labels))
+	for key := range ms.labels {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func (ms *metricSeries) writeHeader(w io.Writer, metricType string) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", ms.name, ms.help, ms.name, metricType)
+}
+
+// MetricCounter is a value which only increases, eg: number of calls.
+// The values are updated atomically so counting needs no lock, only new series are added under lock.
+type MetricCounter struct {
+	metricSeries
+	values atomic.Value // map[string]*uint64 with the float64 bits of the values, replaced when adding series
+}
+
+func (mc *MetricCounter) Inc(labelValues ...string) {
+	mc.Add(1, labelValues...)
+}
+
+func (mc *MetricCounter) Add(value float64, labelValues ...string) {
+	valPtr := mc.valuePtr(strings.Join(labelValues, "\xff"))
+	if valPtr == nil {
+		valPtr = mc.addSeries(labelValues)
+	}
+	for {
+		oldBits := atomic.LoadUint64(valPtr)
+		if atomic.CompareAndSwapUint64(valPtr, oldBits, math.Float64bits(math.Float64frombits(oldBits)+value)) {
+			return
+		}
+	}
+}
+
+func (mc *MetricCounter) valuePtr(key string) *uint64 {
+	values, _ := mc.values.Load().(map[string]*uint64)
+	return values[key]
+}
+
+// addSeries copies the values with the new series added so the readers never see the map changing
+func (mc *MetricCounter) addSeries(labelValues []string) *uint64 {
+	mc.mux.Lock()
+	defer mc.mux.Unlock()
+	key := mc.seriesKey(labelValues)
+	values, _ := mc.values.Load().(map[string]*uint64)
+	if valPtr, hasIt := values[key]; hasIt { // added meanwhile
+		return valPtr
+	}
+	newValues := make(map[string]*uint64, len(values)+1)
+	for k, v := range values {
+		newValues[k] = v
+	}
+	valPtr := new(uint64)
+	newValues[key] = valPtr
+	mc.values.Store(newValues)
+	return valPtr
+}
+
+// Value returns the counter of the series, mostly for tests
+func (mc *MetricCounter) Value(labelValues ...string) float64 {
+	if valPtr := mc.valuePtr(strings.Join(labelValues, "\xff")); valPtr != nil {
+		return math.Float64frombits(atomic.LoadUint64(valPtr))
+	}
+	return 0
+}
+
+func (mc *MetricCounter) writeMetric(w io.Writer) {
+	mc.mux.RLock()
+	defer mc.mux.RUnlock()
+	mc.writeHeader(w, "counter")
+	for _, key := range mc.sortedKeys() {
+		fmt.Fprintf(w, "%s%s %s\n", mc.name, mc.labels[key], formatMetricValue(math.Float64frombits(atomic.LoadUint64(mc.valuePtr(key)))))
+	}
+}
+
+// MetricHistogram counts observations in buckets, eg: request latencies
+type MetricHistogram struct {
+	metricSeries
+	buckets []float64 // upper bounds, sorted ascending
+	series  map[string]*histogramSeries
+}
+
+type histogramSeries struct {
+	bucketCounts []uint64 // not cumulative, one per bucket
+	count        uint64
+	sum          float64
+}
+
+func (mh *MetricHistogram) Observe(value float64, labelValues ...string) {
+	mh.mux.Lock()
+	defer mh.mux.Unlock()
+	if mh.series == nil {
+		mh.series = make(map[string]*histogramSeries)
+	}
+	key := mh.seriesKey(labelValues)
+	hs, hasIt := mh.series[key]
+	if !hasIt {
+		hs = &histogramSeries{bucketCounts: make([]uint64, len(mh.buckets))}
+		mh.series[key] = hs
+	}
+	if idx := sort.SearchFloat64s(mh.buckets, value); idx < len(mh.buckets) {
+		hs.bucketCounts[idx] += 1
+	}
+	hs.count += 1
+	hs.sum += value
+}
+
+// Count returns the number of observations in the series, mostly for tests
+func (mh *MetricHistogram) Count(labelValues ...string) uint64 {
+	mh.mux.RLock()
+	defer mh.mux.RUnlock()
+	if hs, hasIt := mh.series[strings.Join(labelValues, "\xff")]; hasIt {
+		return hs.count
+	}
+	return 0
+}
+
+func (mh *MetricHistogram) writeMetric(w io.Writer) {
+	mh.mux.RLock()
+	defer mh.mux.RUnlock()
+	mh.writeHeader(w, "histogram")
+	for _, key := range mh.sortedKeys() {
+		hs := mh.series[key]
+		labels := mh.labels[key]
+		leSep := "{"
+		if labels != "" {
+			labels = labels[:len(labels)-1] // le is appended to the existing labels
+			leSep = ","
+		}
+		var cumulative uint64
+		for i, bound := range mh.buckets {
+			cumulative += hs.bucketCounts[i]
+			fmt.Fprintf(w, "%s_bucket%s%sle=\"%s\"} %d\n", mh.name, labels, leSep, formatMetricValue(bound), cumulative)
+		}
+		fmt.Fprintf(w, "%s_bucket%s%sle=\"+Inf\"} %d\n", mh.name, labels, leSep, hs.count)
+		fmt.Fprintf(w, "%s_sum%s %s\n", mh.name, mh.labels[key], formatMetricValue(hs.sum))
+		fmt.Fprintf(w, "%s_count%s %d\n", mh.name, mh.labels[key], hs.count)
+	}
+}
+
+// metricGaugeFunc is a value which can go up and down, queried when scraping
+type metricGaugeFunc struct {
+	name string
+	help string
+	f    func() float64
+	mux  sync.RWMutex
+}
+
+func (mg *metricGaugeFunc) writeMetric(w io.Writer) {
+	mg.mux.RLock()
+	f := mg.f
+	mg.mux.RUnlock()
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s gauge\n", mg.name, mg.help, mg.name)
+	if f != nil {
+		fmt.Fprintf(w, "%s %s\n", mg.name, formatMetricValue(f()))
+	}
+}
+
+var metricLabelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func formatMetricLabels(labelNames, labelValues []string) string {
+	if len(labelNames) == 0 {
+		return ""
+	}
+	lbls := make([]string, len(labelNames))
+	for i, lblName := range labelNames {
+		var lblVal string
+		if i < len(labelValues) {
+			lblVal = labelValues[i]
+		}
+		lbls[i] = fmt.Sprintf("%s=\"%s\"", lblName, metricLabelEscaper.Replace(lblVal))
+	}
+	return "{" + strings.Join(lbls, ",") + "}"
+}
+
+func formatMetricValue(value float64) string {
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+var (
+	rpcCallsMetric   = Metrics.NewCounter("cgrates_rpc_calls_total", "Number of RPC calls served, per method and status.", "method", "status")
+	rpcLatencyMetric = Metrics.NewHistogram("cgrates_rpc_call_duration_seconds", "Duration of RPC calls, per method.", DefaultLatencyBuckets, "method")
+)
+
+// rpcMethods are the methods registered on the RPC servers.
+// Only these are used as metric labels so the clients cannot create new series with made up method names.
+var rpcMethods = struct {
+	sync.RWMutex
+	names map[string]bool
+}{names: make(map[string]bool)}
+
+// registerRPCMethods records the methods of rcvr the same way net/rpc exposes them, name defaults to the type name
+func registerRPCMethods(name string, rcvr interface{}) {
+	rcvrType := reflect.TypeOf(rcvr)
+	if name == "" {
+		name = reflect.Indirect(reflect.ValueOf(rcvr)).Type().Name()
+	}
+	rpcMethods.Lock()
+	for i := 0; i < rcvrType.NumMethod(); i++ {
+		if method := rcvrType.Method(i); method.PkgPath == "" { // exported
+			rpcMethods.names[name+"."+method.Name] = true
+		}
+	}
+	rpcMethods.Unlock()
+}
+
+func registerRPCMethod(method string) {
+	rpcMethods.Lock()
+	rpcMethods.names[method] = true
+	rpcMethods.Unlock()
+}
+
+// rpcMethodLabel returns the method if registered, *unknown otherwise
+func rpcMethodLabel(method string) string {
+	rpcMethods.RLock()
+	defer rpcMethods.RUnlock()
+	if rpcMethods.names[method] {
+		return method
+	}
+	return MetaUnknown
+}
+
+func observeRPCCall(method string, start time.Time, failed bool) {
+	method = rpcMethodLabel(method)
+	status := "ok"
+	if failed {
+		status = "error"
+	}
+	rpcCallsMetric.Inc(method, status)
+	rpcLatencyMetric.Observe(time.Since(start).Seconds(), method)
+}
+
+func newMetricsServerCodec(codec rpc.ServerCodec) rpc.ServerCodec {
+	return &metricsServerCodec{ServerCodec: codec, calls: make(map[uint64]rpcCallStart)}
+}
+
+// metricsServerCodec measures the calls of one connection, from reading the request until writing its response
+type metricsServerCodec struct {
+	rpc.ServerCodec
+	calls map[uint64]rpcCallStart // indexed on request sequence
+	mux   sync.Mutex
+}
+
+type rpcCallStart struct {
+	method string
+	start  time.Time
+}
+
+func (c *metricsServerCodec) ReadRequestHeader(r *rpc.Request) error {
+	err := c.ServerCodec.ReadRequestHeader(r)
+	if err == nil {
+		c.mux.Lock()
+		c.calls[r.Seq] = rpcCallStart{method: r.ServiceMethod, start: time.Now()}
+		c.mux.Unlock()
+	}
+	return err
+}
+
+func (c *metricsServerCodec) WriteResponse(r *rpc.Response, body interface{}) error {
+	c.mux.Lock()
+	call, hasIt := c.calls[r.Seq]
+	delete(c.calls, r.Seq)
+	c.mux.Unlock()
+	if hasIt {
+		observeRPCCall(call.method, call.start, r.Error != "")
+	}
+	return c.ServerCodec.WriteResponse(r, body)
+}
+
+// metricsBiRPCHandler wraps a BiRPC handler so its calls are measured
+func metricsBiRPCHandler(method string, handlerFunc interface{}) interface{} {
+	fn := reflect.ValueOf(handlerFunc)
+	return reflect.MakeFunc(fn.Type(), func(in []reflect.Value) []reflect.Value {
+		start := time.Now()
+		out := fn.Call(in)
+		observeRPCCall(method, start, !out[0].IsNil())
+		return out
+	}).Interface()
+}
@@ -0,0 +1,153 @@
+/*
+Real-time Charging System for Telecom & ISP environments
+Copyright (C) ITsysCOM GmbH
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>
+*/
+package utils
+
+import (
+	"bytes"
+	"net"
+	"net/rpc"
+	"net/rpc/jsonrpc"
+	"sync"
+	"testing"
+)
+
+func TestMetricsRegistryWriteMetrics(t *testing.T) {
+	mr := NewMetricsRegistry()
+	cntr := mr.NewCounter("test_calls_total", "Test calls.", "method")
+	cntr.Inc("Test.Call")
+	cntr.Add(2, "Test.\"Quoted\"")
+	if mr.NewCounter("test_calls_total", "Test calls.", "method") != cntr {
+		t.Error("Expecting the registered counter")
+	}
+	hist := mr.NewHistogram("test_duration_seconds", "Test durations.", []float64{0.1, 1})
+	hist.Observe(0.05)
+	hist.Observe(0.5)
+	hist.Observe(2)
+	mr.SetGaugeFunc("test_queue_length", "Test queue.", func() float64 { return 3 })
+	eOut := `# HELP test_calls_total Test calls.
+# TYPE test_calls_total counter
+test_calls_total{method="Test.\"Quoted\""} 2
+test_calls_total{method="Test.Call"} 1
+# HELP test_duration_seconds Test durations.
+# TYPE test_duration_seconds histogram
+test_duration_seconds_bucket{le="0.1"} 1
+test_duration_seconds_bucket{le="1"} 2
+test_duration_seconds_bucket{le="+Inf"} 3
+test_duration_seconds_sum 2.55
+test_duration_seconds_count 3
+# HELP test_queue_length Test queue.
+# TYPE test_queue_length gauge
+test_queue_length 3
+`
+	var buf bytes.Buffer
+	mr.WriteMetrics(&buf)
+	if buf.String() != eOut {
+		t.Errorf("Expecting:\n%s\nreceived:\n%s", eOut, buf.String())
+	}
+}
+
+func TestMetricsHistogramLabels(t *testing.T) {
+	mr := NewMetricsRegistry()
+	hist := mr.NewHistogram("test_duration_seconds", "Test durations.", []float64{1}, "method")
+	hist.Observe(0.5, "Test.Call")
+	eOut := `# HELP test_duration_seconds Test durations.
+# TYPE test_duration_seconds histogram
+test_duration_seconds_bucket{method="Test.Call",le="1"} 1
+test_duration_seconds_bucket{method="Test.Call",le="+Inf"} 1
+test_duration_seconds_sum{method="Test.Call"} 0.5
+test_duration_seconds_count{method="Test.Call"} 1
+`
+	var buf bytes.Buffer
+	mr.WriteMetrics(&buf)
+	if buf.String() != eOut {
+		t.Errorf("Expecting:\n%s\nreceived:\n%s", eOut, buf.String())
+	}
+}
+
+type MetricsTestService struct{}
+
+func (MetricsTestService) Ping(arg string, reply *string) error {
+	if arg == "" {
+		return ErrMandatoryIeMissing
+	}
+	*reply = OK
+	return nil
+}
+
+func TestMetricsServerCodec(t *testing.T) {
+	srv := rpc.NewServer()
+	srv.Register(MetricsTestService{})
+	registerRPCMethods("", MetricsTestService{})
+	srvConn, clntConn := net.Pipe()
+	go srv.ServeCodec(newMetricsServerCodec(jsonrpc.NewServerCodec(srvConn)))
+	clnt := jsonrpc.NewClient(clntConn)
+	defer clnt.Close()
+	okCalls := rpcCallsMetric.Value("MetricsTestService.Ping", "ok")
+	errCalls := rpcCallsMetric.Value("MetricsTestService.Ping", "error")
+	observed := rpcLatencyMetric.Count("MetricsTestService.Ping")
+	unknownCalls := rpcCallsMetric.Value(MetaUnknown, "error")
+	var reply string
+	if err := clnt.Call("MetricsTestService.Ping", "cgrates", &reply); err != nil {
+		t.Error(err)
+	}
+	if err := clnt.Call("MetricsTestService.Ping", "", &reply); err == nil {
+		t.Error("Expecting error")
+	}
+	if rcv := rpcCallsMetric.Value("MetricsTestService.Ping", "ok"); rcv != okCalls+1 {
+		t.Errorf("Received ok calls: %v", rcv)
+	}
+	if rcv := rpcCallsMetric.Value("MetricsTestService.Ping", "error"); rcv != errCalls+1 {
+		t.Errorf("Received error calls: %v", rcv)
+	}
+	if rcv := rpcLatencyMetric.Count("MetricsTestService.Ping"); rcv != observed+2 {
+		t.Errorf("Received observations: %v", rcv)
+	}
+	// methods not registered are not used as labels
+	if err := clnt.Call("MetricsTestService.NotRegistered", "cgrates", &reply); err == nil {
+		t.Error("Expecting error")
+	}
+	if rcv := rpcCallsMetric.Value("MetricsTestService.NotRegistered", "error"); rcv != 0 {
+		t.Errorf("Received calls for unregistered method: %v", rcv)
+	}
+	if rcv := rpcCallsMetric.Value(MetaUnknown, "error"); rcv != unknownCalls+1 {
+		t.Errorf("Received unknown calls: %v", rcv)
+	}
+}
+
+func TestMetricsCounterConcurrent(t *testing.T) {
+	mr := NewMetricsRegistry()
+	cntr := mr.NewCounter("test_calls_total", "Test calls.", "method")
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			for j := 0; j < 100; j++ {
+				cntr.Inc("Test.Call")
+				cntr.Add(0.5, "Test.Other")
+			}
+			wg.Done()
+		}()
+	}
+	wg.Wait()
+	if rcv := cntr.Value("Test.Call"); rcv != 1000 {
+		t.Errorf("Received: %v", rcv)
+	}
+	if rcv := cntr.Value("Test.Other"); rcv != 500 {
+		t.Errorf("Received: %v", rcv)
+	}
+}
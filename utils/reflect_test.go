@@ -71,3 +71,25 @@ func TestReflectFieldAsString(t *testing.T) {
 		t.Error("Received: %s", strVal)
 	}
 }
+
+func TestReflectFieldAsStringOnMap(t *testing.T) {
+	myMap := map[string]interface{}{"Title": "Title1", "Count": 5, "Count64": int64(6), "Val": 7.3}
+	if strVal, err := ReflectFieldAsString(myMap, "Title", ""); err != nil {
+		t.Error(err)
+	} else if strVal != "Title1" {
+		t.Errorf("Received: %s", strVal)
+	}
+	if strVal, err := ReflectFieldAsString(myMap, "Count64", ""); err != nil {
+		t.Error(err)
+	} else if strVal != "6" {
+		t.Errorf("Received: %s", strVal)
+	}
+	if strVal, err := ReflectFieldAsString(myMap, "Val", ""); err != nil {
+		t.Error(err)
+	} else if strVal != "7.3" {
+		t.Errorf("Received: %s", strVal)
+	}
+	if _, err := ReflectFieldAsString(myMap, "NonExisting", ""); err != ErrNotFound {
+		t.Error(err)
+	}
+}
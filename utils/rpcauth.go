@@ -0,0 +1,378 @@
+/*
+Real-time Charging System for Telecom & ISP environments
+Copyright (C) ITsysCOM GmbH
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>
+*/
+package utils
+
+import (
+	"bufio"
+	"encoding/gob"
+	"io"
+	"net"
+	"net/rpc"
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/cenkalti/rpc2"
+)
+
+// ApiKey defines what the holder of an API key can access over the RPC servers
+type ApiKey struct {
+	Tenants []string // tenants accessible with the key, *any for all of them
+	Methods []string // prefixes of the methods which can be called, eg: ApierV1.GetAccount or CdrsV2.; *any for all of them
+}
+
+func (ak *ApiKey) AllowsMethod(method string) bool {
+	for _, mPrfx := range ak.Methods {
+		if mPrfx == ANY || strings.HasPrefix(method, mPrfx) {
+			return true
+		}
+	}
+	return false
+}
+
+func (ak *ApiKey) AllowsTenant(tenant string) bool {
+	for _, tnt := range ak.Tenants {
+		if tnt == ANY || tnt == tenant {
+			return true
+		}
+	}
+	return false
+}
+
+// authorizeArgs checks the Tenant, Tenants, Account and Accounts fields (or map keys) of the request params.
+// Nested events (eg: Event, EventStart) and lists of them are checked the same way, up to maxAuthorizeDepth levels.
+// Empty Tenants are restricted to the ones of the key, an empty Tenant is populated only if the key has one tenant.
+func (ak *ApiKey) authorizeArgs(args interface{}) error {
+	if ak.AllowsTenant(ANY) {
+		return nil
+	}
+	return ak.authorizeValue(reflect.ValueOf(args), false, 0)
+}
+
+const maxAuthorizeDepth = 4
+
+// authorizeValue checks one value of the params, nested maps are considered only if they carry Tenant or Account keys
+func (ak *ApiKey) authorizeValue(v reflect.Value, nested bool, depth int) error {
+	if depth > maxAuthorizeDepth {
+		return nil
+	}
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+	switch v.Kind() {
+	case reflect.Struct:
+		if err := ak.authorizeStruct(v); err != nil {
+			return err
+		}
+		for i := 0; i < v.NumField(); i++ {
+			if v.Type().Field(i).PkgPath != "" { // unexported
+				continue
+			}
+			if err := ak.authorizeValue(v.Field(i), true, depth+1); err != nil {
+				return err
+			}
+		}
+	case reflect.Map:
+		if v.Type().Key().Kind() != reflect.String {
+			return nil
+		}
+		if err := ak.authorizeMap(v, nested); err != nil {
+			return err
+		}
+		for _, key := range v.MapKeys() {
+			if err := ak.authorizeValue(v.MapIndex(key), true, depth+1); err != nil {
+				return err
+			}
+		}
+	case reflect.Slice, reflect.Array:
+		switch v.Type().Elem().Kind() {
+		case reflect.Ptr, reflect.Interface, reflect.Struct, reflect.Map, reflect.Slice:
+		default: // nothing to check inside
+			return nil
+		}
+		for i := 0; i < v.Len(); i++ { // list of events, each checked as the params themselves
+			if err := ak.authorizeValue(v.Index(i), nested, depth+1); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (ak *ApiKey) authorizeStruct(v reflect.Value) error {
+	if fld := v.FieldByName("Tenant"); fld.IsValid() && fld.Kind() == reflect.String {
+		if fld.String() == "" && len(ak.Tenants) == 1 && fld.CanSet() {
+			fld.SetString(ak.Tenants[0])
+		}
+		if !ak.AllowsTenant(fld.String()) {
+			return ErrUnauthorizedTenant
+		}
+	}
+	if fld := v.FieldByName("Tenants"); fld.IsValid() && fld.Type() == reflect.TypeOf([]string{}) {
+		if fld.Len() == 0 && fld.CanSet() {
+			fld.Set(reflect.ValueOf(append([]string{}, ak.Tenants...)))
+		}
+		for _, tnt := range fld.Interface().([]string) {
+			if !ak.AllowsTenant(tnt) {
+				return ErrUnauthorizedTenant
+			}
+		}
+	}
+	if fld := v.FieldByName("Account"); fld.IsValid() && fld.Kind() == reflect.String {
+		if !ak.allowsAccountID(fld.String()) {
+			return ErrUnauthorizedTenant
+		}
+	}
+	for _, fldName := range []string{"Accounts", "AccountIds", "AccountIDs"} {
+		if fld := v.FieldByName(fldName); fld.IsValid() && fld.Type() == reflect.TypeOf([]string{}) {
+			for _, acntID := range fld.Interface().([]string) {
+				if !ak.allowsAccountID(acntID) {
+					return ErrUnauthorizedTenant
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// authorizeMap checks the Tenant and Account keys of an event, nested ones are skipped when not having any of them
+func (ak *ApiKey) authorizeMap(v reflect.Value, nested bool) error {
+	tntKey := reflect.ValueOf(TENANT).Convert(v.Type().Key())
+	acntKey := reflect.ValueOf(ACCOUNT).Convert(v.Type().Key())
+	tntVal, acntVal := v.MapIndex(tntKey), v.MapIndex(acntKey)
+	if nested && !tntVal.IsValid() && !acntVal.IsValid() {
+		return nil
+	}
+	if v.IsNil() {
+		return ErrUnauthorizedTenant
+	}
+	tnt := ""
+	if tntVal.IsValid() {
+		if tntStr, canCast := CastFieldIfToString(tntVal.Interface()); canCast {
+			tnt = tntStr
+		}
+	}
+	if tnt == "" && len(ak.Tenants) == 1 {
+		if elmKind := v.Type().Elem().Kind(); elmKind == reflect.String || elmKind == reflect.Interface {
+			tnt = ak.Tenants[0]
+			v.SetMapIndex(tntKey, reflect.ValueOf(tnt).Convert(v.Type().Elem()))
+		}
+	}
+	if !ak.AllowsTenant(tnt) {
+		return ErrUnauthorizedTenant
+	}
+	if acntVal.IsValid() {
+		if acnt, canCast := CastFieldIfToString(acntVal.Interface()); canCast && !ak.allowsAccountID(acnt) {
+			return ErrUnauthorizedTenant
+		}
+	}
+	return nil
+}
+
+// Account values in the tenant:account format should point to an allowed tenant
+func (ak *ApiKey) allowsAccountID(acntID string) bool {
+	if idx := strings.Index(acntID, CONCATENATED_KEY_SEP); idx != -1 {
+		return ak.AllowsTenant(acntID[:idx])
+	}
+	return true
+}
+
+func NewRPCAuthorizer(apiKeys map[string]*ApiKey, trustedAddrs []string) *RPCAuthorizer {
+	ra := &RPCAuthorizer{apiKeys: apiKeys, trustedAddrs: make(map[string]bool),
+		biRPCClientKeys: make(map[*rpc2.Client]*ApiKey)}
+	for _, addr := range trustedAddrs {
+		ra.trustedAddrs[addr] = true
+	}
+	return ra
+}
+
+// RPCAuthorizer checks the requests received by the RPC servers against the configured API keys.
+// Clients provide the key either in the X-API-Key HTTP header or by calling ApiKeysV1.Authenticate once per connection.
+type RPCAuthorizer struct {
+	apiKeys         map[string]*ApiKey
+	trustedAddrs    map[string]bool // clients connecting from these hosts are not authorized
+	biRPCClientKeys map[*rpc2.Client]*ApiKey
+	bcKeysMux       sync.RWMutex
+}
+
+// GetApiKey returns the authorization of the key, nil if the key is unknown
+func (ra *RPCAuthorizer) GetApiKey(key string) *ApiKey {
+	return ra.apiKeys[key]
+}
+
+func (ra *RPCAuthorizer) IsTrusted(remoteAddr string) bool {
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		host = remoteAddr
+	}
+	return ra.trustedAddrs[host]
+}
+
+// Authorize checks if the method can be called with the args by the holder of apiKey
+func (ra *RPCAuthorizer) Authorize(apiKey *ApiKey, method string, args interface{}) error {
+	if method == ApiKeysV1Authenticate {
+		return nil
+	}
+	if apiKey == nil {
+		return ErrUnauthorizedApiKey
+	}
+	if !apiKey.AllowsMethod(method) {
+		return ErrUnauthorizedMethod
+	}
+	return apiKey.authorizeArgs(args)
+}
+
+// NewServerCodec wraps the codec of a connection so its requests are authorized before being dispatched
+func (ra *RPCAuthorizer) NewServerCodec(codec rpc.ServerCodec, apiKey string) rpc.ServerCodec {
+	return &authServerCodec{ServerCodec: codec, authz: ra, apiKey: ra.GetApiKey(apiKey)}
+}
+
+// authServerCodec authorizes the requests of one connection, rejecting them with an error reply
+type authServerCodec struct {
+	rpc.ServerCodec
+	authz  *RPCAuthorizer
+	apiKey *ApiKey // authorization of the connection, nil until authenticated
+	method string  // method of the request being read
+}
+
+func (c *authServerCodec) ReadRequestHeader(r *rpc.Request) error {
+	err := c.ServerCodec.ReadRequestHeader(r)
+	c.method = r.ServiceMethod
+	return err
+}
+
+func (c *authServerCodec) ReadRequestBody(body interface{}) error {
+	if err := c.ServerCodec.ReadRequestBody(body); err != nil || body == nil {
+		return err
+	}
+	if c.method == ApiKeysV1Authenticate {
+		if key, canCast := body.(*string); canCast {
+			if c.apiKey = c.authz.GetApiKey(*key); c.apiKey == nil {
+				return ErrUnauthorizedApiKey
+			}
+		}
+	}
+	return c.authz.Authorize(c.apiKey, c.method, body)
+}
+
+// BiRPCTrusted is set in the state of BiRPC clients connecting from trusted addresses or from within the process
+const BiRPCTrusted = "*trusted"
+
+// BiRPCHandler wraps a BiRPC handler so it is only invoked for trusted or authorized clients.
+// Untrusted BiRPC clients authenticate with ApiKeysV1.Authenticate.
+func (ra *RPCAuthorizer) BiRPCHandler(method string, handlerFunc interface{}) interface{} {
+	fn := reflect.ValueOf(handlerFunc)
+	return reflect.MakeFunc(fn.Type(), func(in []reflect.Value) []reflect.Value {
+		clnt := in[0].Interface().(*rpc2.Client)
+		if trusted, _ := clnt.State.Get(BiRPCTrusted); trusted == true {
+			return fn.Call(in)
+		}
+		argv := reflect.New(in[1].Type()) // addressable copy so tenants can be populated
+		argv.Elem().Set(in[1])
+		if err := ra.Authorize(ra.biRPCClientKey(clnt), method, argv.Interface()); err != nil {
+			return []reflect.Value{reflect.ValueOf(&err).Elem()}
+		}
+		in[1] = argv.Elem()
+		return fn.Call(in)
+	}).Interface()
+}
+
+// BiRPCAuthenticate is the ApiKeysV1.Authenticate handler for BiRPC clients
+func (ra *RPCAuthorizer) BiRPCAuthenticate(clnt *rpc2.Client, key string, reply *string) error {
+	apiKey := ra.GetApiKey(key)
+	if apiKey == nil {
+		return ErrUnauthorizedApiKey
+	}
+	ra.bcKeysMux.Lock()
+	_, hasKey := ra.biRPCClientKeys[clnt]
+	ra.biRPCClientKeys[clnt] = apiKey
+	ra.bcKeysMux.Unlock()
+	if !hasKey {
+		go func() { // forget the client once disconnected
+			<-clnt.DisconnectNotify()
+			ra.bcKeysMux.Lock()
+			delete(ra.biRPCClientKeys, clnt)
+			ra.bcKeysMux.Unlock()
+		}()
+	}
+	*reply = OK
+	return nil
+}
+
+func (ra *RPCAuthorizer) biRPCClientKey(clnt *rpc2.Client) *ApiKey {
+	ra.bcKeysMux.RLock()
+	defer ra.bcKeysMux.RUnlock()
+	return ra.biRPCClientKeys[clnt]
+}
+
+// ApiKeysV1 serves ApiKeysV1.Authenticate over net/rpc, the key itself is checked by the connection codec
+type ApiKeysV1 struct{}
+
+func (ApiKeysV1) Authenticate(apiKey string, reply *string) error {
+	*reply = OK
+	return nil
+}
+
+// newGobServerCodec mirrors the codec used by rpc.ServeConn so it can be wrapped
+func newGobServerCodec(conn io.ReadWriteCloser) rpc.ServerCodec {
+	buf := bufio.NewWriter(conn)
+	return &gobServerCodec{rwc: conn, dec: gob.NewDecoder(conn), enc: gob.NewEncoder(buf), encBuf: buf}
+}
+
+type gobServerCodec struct {
+	rwc    io.ReadWriteCloser
+	dec    *gob.Decoder
+	enc    *gob.Encoder
+	encBuf *bufio.Writer
+	closed bool
+}
+
+func (c *gobServerCodec) ReadRequestHeader(r *rpc.Request) error {
+	return c.dec.Decode(r)
+}
+
+func (c *gobServerCodec) ReadRequestBody(body interface{}) error {
+	return c.dec.Decode(body)
+}
+
+func (c *gobServerCodec) WriteResponse(r *rpc.Response, body interface{}) (err error) {
+	if err = c.enc.Encode(r); err != nil {
+		if c.encBuf.Flush() == nil { // gob couldn't encode the header, should not happen so close the connection
+			c.Close()
+		}
+		return
+	}
+	if err = c.enc.Encode(body); err != nil {
+		if c.encBuf.Flush() == nil { // was a gob problem encoding the body but the header has been written
+			c.Close()
+		}
+		return
+	}
+	return c.encBuf.Flush()
+}
+
+func (c *gobServerCodec) Close() error {
+	if c.closed {
+		return nil
+	}
+	c.closed = true
+	return c.rwc.Close()
+}
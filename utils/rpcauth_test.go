@@ -0,0 +1,223 @@
+/*
+Real-time Charging System for Telecom & ISP environments
+Copyright (C) ITsysCOM GmbH
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>
+*/
+package utils
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/rpc"
+	"net/rpc/jsonrpc"
+	"reflect"
+	"testing"
+
+	"github.com/cenkalti/rpc2"
+)
+
+type RPCAuthTestArgs struct {
+	Tenant  string
+	Tenants []string
+	Account string
+}
+
+type RPCAuthTestService struct{}
+
+func (RPCAuthTestService) Echo(args RPCAuthTestArgs, reply *RPCAuthTestArgs) error {
+	*reply = args
+	return nil
+}
+
+func TestRPCAuthorizerAuthorize(t *testing.T) {
+	authz := NewRPCAuthorizer(map[string]*ApiKey{
+		"key1":  &ApiKey{Tenants: []string{"reseller1.org"}, Methods: []string{"ApierV1.GetAccount", "CdrsV2."}},
+		"key2":  &ApiKey{Tenants: []string{"reseller1.org", "reseller2.org"}, Methods: []string{ANY}},
+		"admin": &ApiKey{Tenants: []string{ANY}, Methods: []string{ANY}}}, nil)
+	key1, key2 := authz.GetApiKey("key1"), authz.GetApiKey("key2")
+	if err := authz.Authorize(nil, "ApierV1.GetAccount", &RPCAuthTestArgs{Tenant: "reseller1.org"}); err != ErrUnauthorizedApiKey {
+		t.Error(err)
+	}
+	if err := authz.Authorize(key1, "ApierV1.RemAccount", &RPCAuthTestArgs{Tenant: "reseller1.org"}); err != ErrUnauthorizedMethod {
+		t.Error(err)
+	}
+	if err := authz.Authorize(key1, "ApierV1.GetAccount", &RPCAuthTestArgs{Tenant: "cgrates.org"}); err != ErrUnauthorizedTenant {
+		t.Error(err)
+	}
+	if err := authz.Authorize(key1, "ApierV1.GetAccount", &RPCAuthTestArgs{Tenant: "reseller1.org", Account: "cgrates.org:1001"}); err != ErrUnauthorizedTenant {
+		t.Error(err)
+	}
+	args := &RPCAuthTestArgs{Account: "1001"}
+	if err := authz.Authorize(key1, "CdrsV2.GetCdrs", args); err != nil {
+		t.Error(err)
+	} else if !reflect.DeepEqual(args, &RPCAuthTestArgs{Tenant: "reseller1.org", Tenants: []string{"reseller1.org"}, Account: "1001"}) {
+		t.Errorf("Received: %+v", args)
+	}
+	if err := authz.Authorize(key2, "ApierV1.GetAccount", &RPCAuthTestArgs{}); err != ErrUnauthorizedTenant { // multiple tenants, cannot populate
+		t.Error(err)
+	}
+	if err := authz.Authorize(key2, "CdrsV2.GetCdrs", &RPCAuthTestArgs{Tenant: "reseller2.org", Tenants: []string{"reseller1.org", "cgrates.org"}}); err != ErrUnauthorizedTenant {
+		t.Error(err)
+	}
+	ev := map[string]interface{}{ACCOUNT: "1001"}
+	if err := authz.Authorize(key1, "CdrsV2.ProcessCdr", ev); err != nil {
+		t.Error(err)
+	} else if ev[TENANT] != "reseller1.org" {
+		t.Errorf("Received: %+v", ev)
+	}
+	if err := authz.Authorize(key2, "SMGenericV1.InitiateSession", map[string]interface{}{TENANT: "cgrates.org"}); err != ErrUnauthorizedTenant {
+		t.Error(err)
+	}
+	if err := authz.Authorize(authz.GetApiKey("admin"), "ApierV1.RemAccount", &RPCAuthTestArgs{Tenant: "cgrates.org"}); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestRPCAuthorizerIsTrusted(t *testing.T) {
+	authz := NewRPCAuthorizer(nil, []string{"127.0.0.1"})
+	if !authz.IsTrusted("127.0.0.1:43210") {
+		t.Error("Should be trusted")
+	}
+	if authz.IsTrusted("192.168.1.1:43210") {
+		t.Error("Should not be trusted")
+	}
+}
+
+func TestServerAuthorizeHttpFunc(t *testing.T) {
+	srv := new(Server)
+	srv.SetAuthorizer(NewRPCAuthorizer(map[string]*ApiKey{"key1": &ApiKey{Tenants: []string{"cgrates.org"}}}, []string{"127.0.0.1"}))
+	handler := srv.AuthorizeHttpFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Write([]byte(OK))
+	})
+	for _, tc := range []struct {
+		remoteAddr, apiKey string
+		eCode              int
+	}{
+		{"127.0.0.1:43210", "", http.StatusOK},
+		{"192.168.1.1:43210", "", http.StatusUnauthorized},
+		{"192.168.1.1:43210", "unknown", http.StatusUnauthorized},
+		{"192.168.1.1:43210", "key1", http.StatusOK},
+	} {
+		req, err := http.NewRequest("GET", "/metrics", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.RemoteAddr = tc.remoteAddr
+		req.Header.Set(ApiKeyHeader, tc.apiKey)
+		rec := httptest.NewRecorder()
+		handler(rec, req)
+		if rec.Code != tc.eCode {
+			t.Errorf("From %s with key <%s> expecting: %d, received: %d", tc.remoteAddr, tc.apiKey, tc.eCode, rec.Code)
+		}
+	}
+}
+
+func TestRPCAuthorizerServerCodec(t *testing.T) {
+	authz := NewRPCAuthorizer(map[string]*ApiKey{
+		"key1": &ApiKey{Tenants: []string{"reseller1.org"}, Methods: []string{"RPCAuthTestService."}}}, nil)
+	srv := rpc.NewServer()
+	srv.Register(RPCAuthTestService{})
+	srv.RegisterName("ApiKeysV1", new(ApiKeysV1))
+	srvConn, clntConn := net.Pipe()
+	go srv.ServeCodec(authz.NewServerCodec(jsonrpc.NewServerCodec(srvConn), ""))
+	clnt := jsonrpc.NewClient(clntConn)
+	defer clnt.Close()
+	var reply RPCAuthTestArgs
+	if err := clnt.Call("RPCAuthTestService.Echo", RPCAuthTestArgs{Tenant: "reseller1.org"}, &reply); err == nil ||
+		err.Error() != ErrUnauthorizedApiKey.Error() {
+		t.Error(err)
+	}
+	var rplAuth string
+	if err := clnt.Call(ApiKeysV1Authenticate, "unknown", &rplAuth); err == nil || err.Error() != ErrUnauthorizedApiKey.Error() {
+		t.Error(err)
+	}
+	if err := clnt.Call(ApiKeysV1Authenticate, "key1", &rplAuth); err != nil {
+		t.Error(err)
+	} else if rplAuth != OK {
+		t.Error("Received: ", rplAuth)
+	}
+	if err := clnt.Call("RPCAuthTestService.Echo", RPCAuthTestArgs{Tenant: "cgrates.org"}, &reply); err == nil ||
+		err.Error() != ErrUnauthorizedTenant.Error() {
+		t.Error(err)
+	}
+	if err := clnt.Call("RPCAuthTestService.Echo", RPCAuthTestArgs{Account: "1001"}, &reply); err != nil {
+		t.Error(err)
+	} else if reply.Tenant != "reseller1.org" || !reflect.DeepEqual(reply.Tenants, []string{"reseller1.org"}) {
+		t.Errorf("Received: %+v", reply)
+	}
+}
+
+type RPCAuthTestEventArgs struct {
+	UsageID  string
+	Accounts []string
+	Event    map[string]interface{}
+	Events   []map[string]interface{}
+}
+
+func TestRPCAuthorizerAuthorizeNested(t *testing.T) {
+	authz := NewRPCAuthorizer(map[string]*ApiKey{
+		"key1": &ApiKey{Tenants: []string{"reseller1.org"}, Methods: []string{ANY}},
+		"key2": &ApiKey{Tenants: []string{"reseller1.org", "reseller2.org"}, Methods: []string{ANY}}}, nil)
+	key1, key2 := authz.GetApiKey("key1"), authz.GetApiKey("key2")
+	args := &RPCAuthTestEventArgs{UsageID: "session1", Event: map[string]interface{}{ACCOUNT: "1001"}}
+	if err := authz.Authorize(key1, "RLsV1.InitiateResourceUsage", args); err != nil {
+		t.Error(err)
+	} else if args.Event[TENANT] != "reseller1.org" {
+		t.Errorf("Received: %+v", args.Event)
+	}
+	if err := authz.Authorize(key1, "RLsV1.InitiateResourceUsage",
+		&RPCAuthTestEventArgs{Event: map[string]interface{}{TENANT: "cgrates.org", ACCOUNT: "1001"}}); err != ErrUnauthorizedTenant {
+		t.Error(err)
+	}
+	if err := authz.Authorize(key2, "RLsV1.InitiateResourceUsage", &RPCAuthTestEventArgs{Event: map[string]interface{}{ACCOUNT: "1001"}}); err != ErrUnauthorizedTenant {
+		t.Error(err)
+	}
+	if err := authz.Authorize(key2, "RLsV1.InitiateResourceUsage", &RPCAuthTestEventArgs{Event: map[string]interface{}{"Destination": "1002"}}); err != nil {
+		t.Error(err) // Not an event with tenant information
+	}
+	if err := authz.Authorize(key2, "ApierV1.GetAccounts", &RPCAuthTestEventArgs{Accounts: []string{"reseller2.org:1001", "cgrates.org:1001"}}); err != ErrUnauthorizedTenant {
+		t.Error(err)
+	}
+	if err := authz.Authorize(key2, "CdrsV2.ProcessCdrs", &RPCAuthTestEventArgs{Events: []map[string]interface{}{
+		map[string]interface{}{TENANT: "reseller2.org", ACCOUNT: "1001"},
+		map[string]interface{}{TENANT: "cgrates.org", ACCOUNT: "1001"}}}); err != ErrUnauthorizedTenant {
+		t.Error(err)
+	}
+	if err := authz.Authorize(key2, "CdrsV2.ProcessCdrs", []*RPCAuthTestArgs{&RPCAuthTestArgs{Tenant: "reseller2.org"}, &RPCAuthTestArgs{Tenant: "cgrates.org"}}); err != ErrUnauthorizedTenant {
+		t.Error(err)
+	}
+}
+
+func TestRPCAuthorizerBiRPCHandler(t *testing.T) {
+	authz := NewRPCAuthorizer(map[string]*ApiKey{
+		"key1": &ApiKey{Tenants: []string{"reseller1.org"}, Methods: []string{ANY}}}, []string{"127.0.0.1"})
+	handler := authz.BiRPCHandler("SMGenericV1.InitiateSession", func(clnt *rpc2.Client, ev map[string]interface{}, reply *string) error {
+		*reply = OK
+		return nil
+	}).(func(*rpc2.Client, map[string]interface{}, *string) error)
+	var reply string
+	untrusted := &rpc2.Client{State: rpc2.NewState()}
+	if err := handler(untrusted, map[string]interface{}{TENANT: "reseller1.org"}, &reply); err != ErrUnauthorizedApiKey {
+		t.Error(err)
+	}
+	trusted := &rpc2.Client{State: rpc2.NewState()}
+	trusted.State.Set(BiRPCTrusted, true)
+	if err := handler(trusted, map[string]interface{}{TENANT: "cgrates.org"}, &reply); err != nil {
+		t.Error(err)
+	} else if reply != OK {
+		t.Error("Received: ", reply)
+	}
+}
@@ -19,6 +19,7 @@ package utils
 
 import (
 	"bytes"
+	"crypto/tls"
 	"fmt"
 	"io"
 	"log"
@@ -26,8 +27,10 @@ import (
 	"net/http"
 	"net/rpc"
 	"net/rpc/jsonrpc"
+	"sync"
 
 	"github.com/cenkalti/rpc2"
+	rpc2_jsonrpc "github.com/cenkalti/rpc2/jsonrpc"
 	"golang.org/x/net/websocket"
 )
 import _ "net/http/pprof"
@@ -36,15 +39,26 @@ type Server struct {
 	rpcEnabled  bool
 	httpEnabled bool
 	bijsonSrv   *rpc2.Server
+	authz       *RPCAuthorizer // authorizes the RPC requests when set
+	httpRPCOnce sync.Once      // registers the RPC handlers once for both HTTP and HTTPS listeners
+}
+
+// Enables API key authorization for the RPC requests, needs to be set before registering BiJSON handlers
+func (s *Server) SetAuthorizer(authz *RPCAuthorizer) {
+	s.authz = authz
+	rpc.RegisterName("ApiKeysV1", new(ApiKeysV1))
+	registerRPCMethods("ApiKeysV1", new(ApiKeysV1))
 }
 
 func (s *Server) RpcRegister(rcvr interface{}) {
 	rpc.Register(rcvr)
+	registerRPCMethods("", rcvr)
 	s.rpcEnabled = true
 }
 
 func (s *Server) RpcRegisterName(name string, rcvr interface{}) {
 	rpc.RegisterName(name, rcvr)
+	registerRPCMethods(name, rcvr)
 	s.rpcEnabled = true
 }
 
@@ -53,12 +67,31 @@ func (s *Server) RegisterHttpFunc(pattern string, handler func(http.ResponseWrit
 	s.httpEnabled = true
 }
 
+// AuthorizeHttpFunc restricts handler to trusted addresses and holders of valid API keys when the authorization is enabled
+func (s *Server) AuthorizeHttpFunc(handler func(http.ResponseWriter, *http.Request)) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, req *http.Request) {
+		if s.authz != nil && !s.authz.IsTrusted(req.RemoteAddr) &&
+			s.authz.GetApiKey(req.Header.Get(ApiKeyHeader)) == nil {
+			http.Error(w, ErrUnauthorizedApiKey.Error(), http.StatusUnauthorized)
+			return
+		}
+		handler(w, req)
+	}
+}
+
 // Registers a new BiJsonRpc name
 func (s *Server) BijsonRegisterName(method string, handlerFunc interface{}) {
 	if s.bijsonSrv == nil {
 		s.bijsonSrv = rpc2.NewServer()
+		if s.authz != nil {
+			s.bijsonSrv.Handle(ApiKeysV1Authenticate, s.authz.BiRPCAuthenticate)
+		}
+	}
+	if s.authz != nil {
+		handlerFunc = s.authz.BiRPCHandler(method, handlerFunc)
 	}
-	s.bijsonSrv.Handle(method, handlerFunc)
+	registerRPCMethod(method)
+	s.bijsonSrv.Handle(method, metricsBiRPCHandler(method, handlerFunc))
 }
 
 //Registers a new handler for OnConnect event
@@ -71,6 +104,14 @@ func (s *Server) BijsonRegisterOnDisconnect(f func(*rpc2.Client)) {
 	s.bijsonSrv.OnDisconnect(f)
 }
 
+// newServerCodec wraps the codec of a connection with metrics and, for untrusted peers, authorization
+func (s *Server) newServerCodec(codec rpc.ServerCodec, remoteAddr, apiKey string) rpc.ServerCodec {
+	if s.authz != nil && !s.authz.IsTrusted(remoteAddr) {
+		codec = s.authz.NewServerCodec(codec, apiKey)
+	}
+	return newMetricsServerCodec(codec)
+}
+
 func (s *Server) ServeJSON(addr string) {
 	if !s.rpcEnabled {
 		return
@@ -80,6 +121,23 @@ func (s *Server) ServeJSON(addr string) {
 		log.Fatal("ServeJSON listen error:", e)
 	}
 	Logger.Info(fmt.Sprintf("Starting CGRateS JSON server at %s.", addr))
+	s.acceptJSON(lJSON)
+}
+
+// ServeJSONTLS serves JSON-RPC requests over TLS
+func (s *Server) ServeJSONTLS(addr string, tlsCfg *tls.Config) {
+	if !s.rpcEnabled {
+		return
+	}
+	lJSON, e := tls.Listen("tcp", addr, tlsCfg)
+	if e != nil {
+		log.Fatal("ServeJSONTLS listen error:", e)
+	}
+	Logger.Info(fmt.Sprintf("Starting CGRateS JSON TLS server at %s.", addr))
+	s.acceptJSON(lJSON)
+}
+
+func (s *Server) acceptJSON(lJSON net.Listener) {
 	for {
 		conn, err := lJSON.Accept()
 		if err != nil {
@@ -87,9 +145,8 @@ func (s *Server) ServeJSON(addr string) {
 			continue
 		}
 		//utils.Logger.Info(fmt.Sprintf("<CGRServer> New incoming connection: %v", conn.RemoteAddr()))
-		go jsonrpc.ServeConn(conn)
+		go rpc.ServeCodec(s.newServerCodec(jsonrpc.NewServerCodec(conn), conn.RemoteAddr().String(), ""))
 	}
-
 }
 
 func (s *Server) ServeGOB(addr string) {
@@ -101,6 +158,23 @@ func (s *Server) ServeGOB(addr string) {
 		log.Fatal("ServeGOB listen error:", e)
 	}
 	Logger.Info(fmt.Sprintf("Starting CGRateS GOB server at %s.", addr))
+	s.acceptGOB(lGOB)
+}
+
+// ServeGOBTLS serves GOB-RPC requests over TLS
+func (s *Server) ServeGOBTLS(addr string, tlsCfg *tls.Config) {
+	if !s.rpcEnabled {
+		return
+	}
+	lGOB, e := tls.Listen("tcp", addr, tlsCfg)
+	if e != nil {
+		log.Fatal("ServeGOBTLS listen error:", e)
+	}
+	Logger.Info(fmt.Sprintf("Starting CGRateS GOB TLS server at %s.", addr))
+	s.acceptGOB(lGOB)
+}
+
+func (s *Server) acceptGOB(lGOB net.Listener) {
 	for {
 		conn, err := lGOB.Accept()
 		if err != nil {
@@ -109,28 +183,49 @@ func (s *Server) ServeGOB(addr string) {
 		}
 
 		//utils.Logger.Info(fmt.Sprintf("<CGRServer> New incoming connection: %v", conn.RemoteAddr()))
-		go rpc.ServeConn(conn)
+		go rpc.ServeCodec(s.newServerCodec(newGobServerCodec(conn), conn.RemoteAddr().String(), ""))
 	}
 }
 
 func (s *Server) ServeHTTP(addr string) {
-	if s.rpcEnabled {
+	if !s.registerHTTPRPC() {
+		return
+	}
+	Logger.Info(fmt.Sprintf("Starting CGRateS HTTP server at %s.", addr))
+	http.ListenAndServe(addr, nil)
+}
+
+// ServeHTTPTLS serves the same handlers as ServeHTTP over HTTPS
+func (s *Server) ServeHTTPTLS(addr string, tlsCfg *tls.Config) {
+	if !s.registerHTTPRPC() {
+		return
+	}
+	Logger.Info(fmt.Sprintf("Starting CGRateS HTTPS server at %s.", addr))
+	httpSrv := &http.Server{Addr: addr, TLSConfig: tlsCfg}
+	if err := httpSrv.ListenAndServeTLS("", ""); err != nil { // certificates are already part of tlsCfg
+		log.Fatal("ServeHTTPTLS listen error:", err)
+	}
+}
+
+// registerHTTPRPC registers the JSON-RPC handlers on the HTTP mux, returns false if there is nothing to serve over HTTP
+func (s *Server) registerHTTPRPC() bool {
+	s.httpRPCOnce.Do(func() {
+		if !s.rpcEnabled {
+			return
+		}
 		http.HandleFunc("/jsonrpc", func(w http.ResponseWriter, req *http.Request) {
 			defer req.Body.Close()
 			w.Header().Set("Content-Type", "application/json")
-			res := NewRPCRequest(req.Body).Call()
+			rpcReq := NewRPCRequest(req.Body)
+			res := rpcReq.CallWithCodec(s.newServerCodec(jsonrpc.NewServerCodec(rpcReq), req.RemoteAddr, req.Header.Get(ApiKeyHeader)))
 			io.Copy(w, res)
 		})
 		http.Handle("/ws", websocket.Handler(func(ws *websocket.Conn) {
-			jsonrpc.ServeConn(ws)
+			rpc.ServeCodec(s.newServerCodec(jsonrpc.NewServerCodec(ws), ws.Request().RemoteAddr, ws.Request().Header.Get(ApiKeyHeader)))
 		}))
 		s.httpEnabled = true
-	}
-	if !s.httpEnabled {
-		return
-	}
-	Logger.Info(fmt.Sprintf("Starting CGRateS HTTP server at %s.", addr))
-	http.ListenAndServe(addr, nil)
+	})
+	return s.httpEnabled
 }
 
 func (s *Server) ServeBiJSON(addr string) {
@@ -142,7 +237,49 @@ func (s *Server) ServeBiJSON(addr string) {
 		log.Fatal("ServeBiJSON listen error:", e)
 	}
 	Logger.Info(fmt.Sprintf("Starting CGRateS BiJSON server at %s.", addr))
-	s.bijsonSrv.Accept(lBiJSON)
+	s.acceptBiJSON(lBiJSON)
+}
+
+// ServeBiJSONTLS serves bidirectional JSON-RPC requests over TLS
+func (s *Server) ServeBiJSONTLS(addr string, tlsCfg *tls.Config) {
+	if s.bijsonSrv == nil {
+		return
+	}
+	lBiJSON, e := tls.Listen("tcp", addr, tlsCfg)
+	if e != nil {
+		log.Fatal("ServeBiJSONTLS listen error:", e)
+	}
+	Logger.Info(fmt.Sprintf("Starting CGRateS BiJSON TLS server at %s.", addr))
+	s.acceptBiJSON(lBiJSON)
+}
+
+func (s *Server) acceptBiJSON(lBiJSON net.Listener) {
+	for {
+		conn, err := lBiJSON.Accept()
+		if err != nil {
+			Logger.Err(fmt.Sprintf("<CGRServer> BiJSON accept error: %v", err))
+			continue
+		}
+		go s.serveBiJSONConn(conn, s.authz == nil || s.authz.IsTrusted(conn.RemoteAddr().String()))
+	}
+}
+
+// ServeBiJSONConn serves bidirectional JSON-RPC requests over one connection, blocking until it is closed.
+// Used directly by components running in the same process (eg: DiameterAgent) so SMG can reach them back, hence trusted.
+func (s *Server) ServeBiJSONConn(conn io.ReadWriteCloser) {
+	s.serveBiJSONConn(conn, true)
+}
+
+func (s *Server) serveBiJSONConn(conn io.ReadWriteCloser, trusted bool) {
+	if s.bijsonSrv == nil {
+		conn.Close()
+		return
+	}
+	state := rpc2.NewState()
+	if trusted {
+		state.Set(BiRPCTrusted, true)
+	}
+	s.bijsonSrv.ServeCodecWithState(rpc2_jsonrpc.NewJSONCodec(conn), state)
 }
 
 // rpcRequest represents a RPC request.
@@ -181,3 +318,10 @@ func (r *rpcRequest) Call() io.Reader {
 	<-r.done
 	return r.rw
 }
+
+// CallWithCodec is like Call but decodes the request and encodes the response with codec
+func (r *rpcRequest) CallWithCodec(codec rpc.ServerCodec) io.Reader {
+	go rpc.ServeCodec(codec)
+	<-r.done
+	return r.rw
+}
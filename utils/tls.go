@@ -0,0 +1,338 @@
+/*
+Real-time Charging System for Telecom & ISP environments
+Copyright (C) ITsysCOM GmbH
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>
+*/
+package utils
+
+import (
+	"bufio"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/rpc"
+	"net/rpc/jsonrpc"
+	"sync"
+	"time"
+)
+
+// NewTLSServerConfig builds the configuration of the TLS listeners.
+// With clientAuth the clients need to present a certificate signed by the CAs in caFile, which is mandatory then.
+func NewTLSServerConfig(certFile, keyFile, caFile string, clientAuth bool) (*tls.Config, error) {
+	if clientAuth && caFile == "" {
+		return nil, errors.New("client authentication requires a CA certificate")
+	}
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, err
+	}
+	tlsCfg := &tls.Config{Certificates: []tls.Certificate{cert}}
+	if !clientAuth {
+		return tlsCfg, nil
+	}
+	if tlsCfg.ClientCAs, err = loadCertPool(caFile); err != nil {
+		return nil, err
+	}
+	tlsCfg.ClientAuth = tls.RequireAndVerifyClientCert
+	return tlsCfg, nil
+}
+
+// NewTLSClientConfig builds the configuration of the outbound TLS connections.
+// The server certificates are verified against caFile, or system CAs if empty. certFile is optional, presented to servers requiring client authentication.
+func NewTLSClientConfig(certFile, keyFile, caFile string) (*tls.Config, error) {
+	tlsCfg := new(tls.Config)
+	if certFile != "" {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, err
+		}
+		tlsCfg.Certificates = []tls.Certificate{cert}
+	}
+	if caFile != "" {
+		var err error
+		if tlsCfg.RootCAs, err = loadCertPool(caFile); err != nil {
+			return nil, err
+		}
+	}
+	return tlsCfg, nil
+}
+
+func loadCertPool(caFile string) (*x509.CertPool, error) {
+	caPEM, err := ioutil.ReadFile(caFile)
+	if err != nil {
+		return nil, err
+	}
+	certPool := x509.NewCertPool()
+	if !certPool.AppendCertsFromPEM(caPEM) {
+		return nil, fmt.Errorf("no certificates found in <%s>", caFile)
+	}
+	return certPool, nil
+}
+
+// NewTLSRpcClient connects to a JSON or GOB RPC server over TLS.
+// The client is returned also on connection errors, it will try to reconnect on the next call.
+func NewTLSRpcClient(addr, codec string, tlsCfg *tls.Config, connectAttempts, reconnects int,
+	connectTimeout, replyTimeout time.Duration) (client *TLSRpcClient, err error) {
+	if codec != JSON && codec != GOB {
+		return nil, fmt.Errorf("Unsupported codec: <%s>", codec)
+	}
+	client = &TLSRpcClient{addr: addr, codec: codec, tlsCfg: tlsCfg, reconnects: reconnects,
+		connectTimeout: connectTimeout, replyTimeout: replyTimeout}
+	for i := 0; i < connectAttempts; i++ {
+		if i != 0 {
+			time.Sleep(time.Duration(i) * time.Second)
+		}
+		if err = client.connect(); err == nil {
+			break
+		}
+	}
+	return
+}
+
+// TLSRpcClient is a RPC connection over TLS, reconnecting when the connection is lost
+type TLSRpcClient struct {
+	addr           string
+	codec          string // <json|gob>
+	tlsCfg         *tls.Config
+	reconnects     int // <-1 for infinite | nb>
+	connectTimeout time.Duration
+	replyTimeout   time.Duration
+	connection     *tlsRpcConn
+	connMux        sync.RWMutex
+	reconnectMux   sync.Mutex // one reconnect at a time
+}
+
+func (client *TLSRpcClient) connect() error {
+	conn, err := tls.DialWithDialer(&net.Dialer{Timeout: client.connectTimeout}, "tcp", client.addr, client.tlsCfg)
+	if err != nil {
+		return err
+	}
+	client.connMux.Lock()
+	if client.connection != nil {
+		client.connection.close()
+	}
+	if client.codec == JSON {
+		client.connection = newTLSRpcConn(jsonrpc.NewClientCodec(conn))
+	} else {
+		client.connection = newTLSRpcConn(newGobClientCodec(conn))
+	}
+	client.connMux.Unlock()
+	return nil
+}
+
+// reconnect replaces the failed connection, once for all the calls which failed on it.
+// Retries with increasing delays, the same way rpcclient does for the plain connections.
+func (client *TLSRpcClient) reconnect(failedConn *tlsRpcConn) (err error) {
+	client.reconnectMux.Lock()
+	defer client.reconnectMux.Unlock()
+	client.connMux.RLock()
+	reconnected := client.connection != failedConn
+	client.connMux.RUnlock()
+	if reconnected { // another call already reconnected
+		return nil
+	}
+	for i := 0; client.reconnects == -1 || i < client.reconnects; i++ {
+		if i != 0 {
+			time.Sleep(time.Duration(i) * time.Second)
+		}
+		if err = client.connect(); err == nil {
+			return nil
+		}
+	}
+	return fmt.Errorf("reconnect error: %v", err)
+}
+
+// Call reconnects and repeats the request on network errors, unless reconnects are disabled
+func (client *TLSRpcClient) Call(serviceMethod string, args interface{}, reply interface{}) error {
+	conn, err := client.call(serviceMethod, args, reply)
+	if !isTLSNetworkError(err) || client.reconnects == 0 {
+		return err
+	}
+	if errReconnect := client.reconnect(conn); errReconnect != nil {
+		return errReconnect
+	}
+	_, err = client.call(serviceMethod, args, reply)
+	return err
+}
+
+// call returns also the connection used so a failure can be matched with the connection it happened on.
+// On reply timeout the call is dropped from the pending ones, a late reply is discarded.
+func (client *TLSRpcClient) call(serviceMethod string, args interface{}, reply interface{}) (*tlsRpcConn, error) {
+	client.connMux.RLock()
+	conn := client.connection
+	client.connMux.RUnlock()
+	if conn == nil {
+		return nil, rpc.ErrShutdown
+	}
+	seq, rpcCall := conn.send(serviceMethod, args, reply)
+	select {
+	case <-rpcCall.Done:
+		return conn, rpcCall.Error
+	case <-time.After(client.replyTimeout):
+		if conn.remove(seq) == nil { // reply arrived meanwhile and is being decoded into reply
+			<-rpcCall.Done
+			return conn, rpcCall.Error
+		}
+		return conn, ErrReplyTimeout
+	}
+}
+
+func newTLSRpcConn(codec rpc.ClientCodec) *tlsRpcConn {
+	conn := &tlsRpcConn{codec: codec, pending: make(map[uint64]*rpc.Call)}
+	go conn.readReplies()
+	return conn
+}
+
+// tlsRpcConn is the client side of one RPC connection, like rpc.Client but with pending calls which can be dropped
+type tlsRpcConn struct {
+	codec   rpc.ClientCodec
+	sendMux sync.Mutex // one request written at a time
+	mux     sync.Mutex // protects the fields below
+	seq     uint64
+	pending map[uint64]*rpc.Call
+	closed  bool // no more requests accepted, set by close or when reading replies fails
+}
+
+// send writes the request, the call is answered on Done channel
+func (conn *tlsRpcConn) send(serviceMethod string, args interface{}, reply interface{}) (uint64, *rpc.Call) {
+	rpcCall := &rpc.Call{ServiceMethod: serviceMethod, Args: args, Reply: reply, Done: make(chan *rpc.Call, 1)}
+	conn.sendMux.Lock()
+	defer conn.sendMux.Unlock()
+	conn.mux.Lock()
+	if conn.closed {
+		conn.mux.Unlock()
+		rpcCall.Error = rpc.ErrShutdown
+		rpcCall.Done <- rpcCall
+		return 0, rpcCall
+	}
+	conn.seq++
+	seq := conn.seq
+	conn.pending[seq] = rpcCall
+	conn.mux.Unlock()
+	if err := conn.codec.WriteRequest(&rpc.Request{ServiceMethod: serviceMethod, Seq: seq}, args); err != nil {
+		if conn.remove(seq) != nil { // not answered by readReplies on a failed connection
+			rpcCall.Error = err
+			rpcCall.Done <- rpcCall
+		}
+	}
+	return seq, rpcCall
+}
+
+// remove drops the pending call, nil if it was already answered
+func (conn *tlsRpcConn) remove(seq uint64) *rpc.Call {
+	conn.mux.Lock()
+	defer conn.mux.Unlock()
+	rpcCall := conn.pending[seq]
+	delete(conn.pending, seq)
+	return rpcCall
+}
+
+// readReplies answers the pending calls until the connection fails, then fails the ones left
+func (conn *tlsRpcConn) readReplies() {
+	var err error
+	for err == nil {
+		var resp rpc.Response
+		if err = conn.codec.ReadResponseHeader(&resp); err != nil {
+			break
+		}
+		rpcCall := conn.remove(resp.Seq)
+		if rpcCall == nil { // dropped on reply timeout
+			err = conn.codec.ReadResponseBody(nil)
+			continue
+		}
+		if resp.Error != "" {
+			rpcCall.Error = rpc.ServerError(resp.Error)
+			err = conn.codec.ReadResponseBody(nil)
+		} else if err = conn.codec.ReadResponseBody(rpcCall.Reply); err != nil {
+			rpcCall.Error = errors.New("reading body " + err.Error())
+		}
+		rpcCall.Done <- rpcCall
+	}
+	conn.sendMux.Lock()
+	conn.mux.Lock()
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	if conn.closed { // closed on our side
+		err = rpc.ErrShutdown
+	}
+	conn.closed = true
+	for seq, rpcCall := range conn.pending {
+		delete(conn.pending, seq)
+		rpcCall.Error = err
+		rpcCall.Done <- rpcCall
+	}
+	conn.mux.Unlock()
+	conn.sendMux.Unlock()
+}
+
+func (conn *tlsRpcConn) close() error {
+	conn.mux.Lock()
+	conn.closed = true
+	conn.mux.Unlock()
+	return conn.codec.Close()
+}
+
+// newGobClientCodec mirrors the codec used by rpc.NewClient so it can be used with tlsRpcConn
+func newGobClientCodec(conn io.ReadWriteCloser) rpc.ClientCodec {
+	buf := bufio.NewWriter(conn)
+	return &gobClientCodec{rwc: conn, dec: gob.NewDecoder(conn), enc: gob.NewEncoder(buf), encBuf: buf}
+}
+
+type gobClientCodec struct {
+	rwc    io.ReadWriteCloser
+	dec    *gob.Decoder
+	enc    *gob.Encoder
+	encBuf *bufio.Writer
+}
+
+func (c *gobClientCodec) WriteRequest(r *rpc.Request, body interface{}) (err error) {
+	if err = c.enc.Encode(r); err != nil {
+		return
+	}
+	if err = c.enc.Encode(body); err != nil {
+		return
+	}
+	return c.encBuf.Flush()
+}
+
+func (c *gobClientCodec) ReadResponseHeader(r *rpc.Response) error {
+	return c.dec.Decode(r)
+}
+
+func (c *gobClientCodec) ReadResponseBody(body interface{}) error {
+	return c.dec.Decode(body)
+}
+
+func (c *gobClientCodec) Close() error {
+	return c.rwc.Close()
+}
+
+// isTLSNetworkError detects the errors out of a lost connection, the reply timeouts are left to the caller
+func isTLSNetworkError(err error) bool {
+	if err == nil {
+		return false
+	}
+	if err == rpc.ErrShutdown || err == io.EOF || err == io.ErrUnexpectedEOF {
+		return true
+	}
+	_, isNetErr := err.(net.Error)
+	return isNetErr
+}
@@ -0,0 +1,305 @@
+/*
+Real-time Charging System for Telecom & ISP environments
+Copyright (C) ITsysCOM GmbH
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>
+*/
+package utils
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/rpc"
+	"net/rpc/jsonrpc"
+	"os"
+	"path"
+	"testing"
+	"time"
+)
+
+type TLSTestService struct{}
+
+func (TLSTestService) Echo(arg string, reply *string) error {
+	*reply = arg
+	return nil
+}
+
+func (TLSTestService) Delay(delay time.Duration, reply *string) error {
+	time.Sleep(delay)
+	*reply = OK
+	return nil
+}
+
+// writeTestCertificate generates a self-signed certificate for 127.0.0.1, usable by both servers and clients
+func writeTestCertificate(dir string) (certFile, keyFile string, err error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "cgrates test"},
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	certDER, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		return
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return
+	}
+	certFile, keyFile = path.Join(dir, "cgrates.crt"), path.Join(dir, "cgrates.key")
+	if err = ioutil.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certDER}), 0600); err != nil {
+		return
+	}
+	err = ioutil.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600)
+	return
+}
+
+func TestTLSRpcClient(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "cgr_tls")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+	certFile, keyFile, err := writeTestCertificate(tmpDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srvTLSCfg, err := NewTLSServerConfig(certFile, keyFile, certFile, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l, err := tls.Listen("tcp", "127.0.0.1:0", srvTLSCfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	srv := rpc.NewServer()
+	srv.Register(TLSTestService{})
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go srv.ServeCodec(jsonrpc.NewServerCodec(conn))
+		}
+	}()
+	// client certificate signed by the CA trusted by server
+	clntTLSCfg, err := NewTLSClientConfig(certFile, keyFile, certFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	clnt, err := NewTLSRpcClient(l.Addr().String(), JSON, clntTLSCfg, 1, 0, time.Second, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var reply string
+	if err := clnt.Call("TLSTestService.Echo", "cgrates.org", &reply); err != nil {
+		t.Error(err)
+	} else if reply != "cgrates.org" {
+		t.Errorf("Received: %s", reply)
+	}
+	// server requires client certificates
+	clntTLSCfg, err = NewTLSClientConfig("", "", certFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if clnt, err = NewTLSRpcClient(l.Addr().String(), JSON, clntTLSCfg, 1, 0, time.Second, time.Second); err == nil {
+		if err = clnt.Call("TLSTestService.Echo", "cgrates.org", &reply); err == nil {
+			t.Error("Expecting error for missing client certificate")
+		}
+	}
+	// server certificate is not signed by system CAs
+	if clntTLSCfg, err = NewTLSClientConfig(certFile, keyFile, ""); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = NewTLSRpcClient(l.Addr().String(), JSON, clntTLSCfg, 1, 0, time.Second, time.Second); err == nil {
+		t.Error("Expecting error for untrusted server certificate")
+	}
+}
+
+func TestTLSRpcClientReconnect(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "cgr_tls")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+	certFile, keyFile, err := writeTestCertificate(tmpDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srvTLSCfg, err := NewTLSServerConfig(certFile, keyFile, "", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l, err := tls.Listen("tcp", "127.0.0.1:0", srvTLSCfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	srv := rpc.NewServer()
+	srv.Register(TLSTestService{})
+	srvConns := make(chan net.Conn, 10)
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			srvConns <- conn
+			go srv.ServeCodec(jsonrpc.NewServerCodec(conn))
+		}
+	}()
+	clntTLSCfg, err := NewTLSClientConfig("", "", certFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	clnt, err := NewTLSRpcClient(l.Addr().String(), JSON, clntTLSCfg, 1, 2, time.Second, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var reply string
+	if err := clnt.Call("TLSTestService.Echo", "cgrates.org", &reply); err != nil {
+		t.Fatal(err)
+	}
+	(<-srvConns).Close() // drop the connection on server side
+	time.Sleep(10 * time.Millisecond)
+	reply = ""
+	if err := clnt.Call("TLSTestService.Echo", "itsyscom.com", &reply); err != nil {
+		t.Error(err)
+	} else if reply != "itsyscom.com" {
+		t.Errorf("Received: %s", reply)
+	}
+	<-srvConns // reconnected one
+	// without reconnects the error is returned
+	if clnt, err = NewTLSRpcClient(l.Addr().String(), JSON, clntTLSCfg, 1, 0, time.Second, time.Second); err != nil {
+		t.Fatal(err)
+	}
+	(<-srvConns).Close()
+	time.Sleep(10 * time.Millisecond)
+	if err := clnt.Call("TLSTestService.Echo", "cgrates.org", &reply); err == nil {
+		t.Error("Expecting error on dropped connection without reconnects")
+	}
+}
+
+func TestNewTLSClientConfigInvalidCA(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "cgr_tls")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+	caFile := path.Join(tmpDir, "ca.crt")
+	if err := ioutil.WriteFile(caFile, []byte("not a certificate"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewTLSClientConfig("", "", caFile); err == nil {
+		t.Error("Expecting error for invalid CA file")
+	}
+}
+
+func TestTLSRpcClientReplyTimeout(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "cgr_tls")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+	certFile, keyFile, err := writeTestCertificate(tmpDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srvTLSCfg, err := NewTLSServerConfig(certFile, keyFile, "", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l, err := tls.Listen("tcp", "127.0.0.1:0", srvTLSCfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	srv := rpc.NewServer()
+	srv.Register(TLSTestService{})
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go srv.ServeConn(conn)
+		}
+	}()
+	clntTLSCfg, err := NewTLSClientConfig("", "", certFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	clnt, err := NewTLSRpcClient(l.Addr().String(), GOB, clntTLSCfg, 1, 0, time.Second, time.Duration(50)*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var reply string
+	if err := clnt.Call("TLSTestService.Delay", time.Duration(100)*time.Millisecond, &reply); err != ErrReplyTimeout {
+		t.Errorf("Expecting: %v, received: %v", ErrReplyTimeout, err)
+	}
+	clnt.connection.mux.Lock()
+	pendingNr := len(clnt.connection.pending)
+	clnt.connection.mux.Unlock()
+	if pendingNr != 0 {
+		t.Errorf("Pending calls: %d", pendingNr)
+	}
+	time.Sleep(time.Duration(100) * time.Millisecond) // late reply is discarded
+	if reply != "" {
+		t.Errorf("Received: %s", reply)
+	}
+	if err := clnt.Call("TLSTestService.Echo", "cgrates.org", &reply); err != nil {
+		t.Error(err)
+	} else if reply != "cgrates.org" {
+		t.Errorf("Received: %s", reply)
+	}
+}
+
+func TestNewTLSServerConfigClientAuth(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "cgr_tls")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+	certFile, keyFile, err := writeTestCertificate(tmpDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewTLSServerConfig(certFile, keyFile, "", true); err == nil {
+		t.Error("Expecting error for client authentication without CA certificate")
+	}
+	if tlsCfg, err := NewTLSServerConfig(certFile, keyFile, certFile, false); err != nil {
+		t.Error(err)
+	} else if tlsCfg.ClientAuth != tls.NoClientCert || tlsCfg.ClientCAs != nil {
+		t.Errorf("Client verification without client authentication: %+v", tlsCfg)
+	}
+}
@@ -669,6 +669,27 @@ func TestEndOfMonth(t *testing.T) {
 	}
 }
 
+func TestRelativeTimeRange(t *testing.T) {
+	ref := time.Date(2016, time.March, 1, 10, 20, 30, 0, time.UTC)
+	for timeRange, eRange := range map[string][]time.Time{
+		"":            []time.Time{time.Time{}, time.Time{}},
+		MetaLastHour:  []time.Time{time.Date(2016, time.March, 1, 9, 0, 0, 0, time.UTC), time.Date(2016, time.March, 1, 10, 0, 0, 0, time.UTC)},
+		MetaToday:     []time.Time{time.Date(2016, time.March, 1, 0, 0, 0, 0, time.UTC), time.Date(2016, time.March, 2, 0, 0, 0, 0, time.UTC)},
+		MetaYesterday: []time.Time{time.Date(2016, time.February, 29, 0, 0, 0, 0, time.UTC), time.Date(2016, time.March, 1, 0, 0, 0, 0, time.UTC)},
+		MetaThisMonth: []time.Time{time.Date(2016, time.March, 1, 0, 0, 0, 0, time.UTC), time.Date(2016, time.April, 1, 0, 0, 0, 0, time.UTC)},
+		MetaLastMonth: []time.Time{time.Date(2016, time.February, 1, 0, 0, 0, 0, time.UTC), time.Date(2016, time.March, 1, 0, 0, 0, 0, time.UTC)},
+	} {
+		if start, end, err := RelativeTimeRange(timeRange, ref); err != nil {
+			t.Errorf("%s: %v", timeRange, err)
+		} else if !start.Equal(eRange[0]) || !end.Equal(eRange[1]) {
+			t.Errorf("%s: expecting: %v - %v, received: %v - %v", timeRange, eRange[0], eRange[1], start, end)
+		}
+	}
+	if _, _, err := RelativeTimeRange("*next_week", ref); err == nil {
+		t.Error("Expecting error for unsupported time range")
+	}
+}
+
 func TestParseHierarchyPath(t *testing.T) {
 	eHP := HierarchyPath([]string{"Root", "CGRateS"})
 	if hp := ParseHierarchyPath("Root>CGRateS", ""); !reflect.DeepEqual(hp, eHP) {